@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"news-api/db"
+)
+
+// runBackfill implements the backfill command: `news-api backfill --source
+// URL --since 2023-01-01` walks the archive pages of a feed, and with
+// `--wayback` its Wayback Machine captures, to ingest articles older than the
+// current feed window. It returns the exit status: 2 for usage errors, 1 if
+// the source could not be backfilled.
+func runBackfill(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	source := flags.String("source", "", "feed URL to backfill")
+	since := flags.String("since", "", "oldest publication date to ingest, as YYYY-MM-DD")
+	maxPages := flags.Int("max-pages", 50, "maximum archive pages and Wayback Machine captures to fetch, each")
+	wayback := flags.Bool("wayback", false, "also ingest the feed's captures by the Wayback Machine")
+	delay := flags.Duration("delay", time.Second, "pause between two page requests")
+	dryRun := flags.Bool("dry-run", false, "fetch and process the pages without writing to the database")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	*source = strings.TrimSpace(*source)
+	if *source == "" || *since == "" {
+		fmt.Fprintln(stderr, "backfill requires --source and --since")
+		return 2
+	}
+	sinceDate, err := time.Parse("2006-01-02", *since)
+	if err != nil {
+		fmt.Fprintln(stderr, "--since must be a date formatted as YYYY-MM-DD")
+		return 2
+	}
+	if *maxPages < 1 {
+		fmt.Fprintln(stderr, "--max-pages must be at least 1")
+		return 2
+	}
+
+	if err := openFetchDB(envDefault("DB_PATH", "./news.db"), *dryRun); err != nil {
+		fmt.Fprintf(stderr, "Failed to open database: %v\n", err)
+		return 1
+	}
+	configureIngestion()
+
+	report, err := db.Backfill(db.BackfillOptions{
+		Source:   *source,
+		Since:    sinceDate,
+		MaxPages: *maxPages,
+		Wayback:  *wayback,
+		Delay:    *delay,
+		DryRun:   *dryRun,
+		Progress: func(page string, counts db.SourceReport) {
+			fmt.Fprintf(stdout, "%s: %d items, %d new, %d duplicates, %d before %s\n", page, counts.Items, counts.New, counts.Duplicates, counts.BeforeSince, *since)
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to backfill %s: %v\n", *source, err)
+		return 1
+	}
+	fmt.Fprintln(stdout)
+	printIngestReport(stdout, report, *dryRun)
+	return 0
+}
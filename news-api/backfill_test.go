@@ -0,0 +1,17 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBackfillUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, runBackfill([]string{"--since", "2023-01-01"}, &stdout, &stderr), "--source is required")
+	assert.Equal(t, 2, runBackfill([]string{"--source", "https://example.com/feed"}, &stdout, &stderr), "--since is required")
+	assert.Equal(t, 2, runBackfill([]string{"--source", "https://example.com/feed", "--since", "January 2023"}, &stdout, &stderr))
+	assert.Equal(t, 2, runBackfill([]string{"--source", "https://example.com/feed", "--since", "2023-01-01", "--max-pages", "0"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "YYYY-MM-DD")
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/ratelimit"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client", "203.0.113.5:4321", nil, "203.0.113.5"},
+		{"untrusted peer cannot spoof", "203.0.113.5:4321", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "203.0.113.5"},
+		{"trusted proxy", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"spoofed hop before client", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.7"}, "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.7, 192.0.2.1, 10.9.9.9"}, "198.51.100.7"},
+		{"only proxies", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "10.2.2.2, 10.9.9.9"}, "10.2.2.2"},
+		{"real ip header", "192.0.2.1:80", map[string]string{"X-Real-IP": "198.51.100.8"}, "198.51.100.8"},
+		{"garbage header", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "not-an-ip"}, "10.1.2.3"},
+		{"ipv6 proxy", "[2001:db8::1]:443", map[string]string{"X-Forwarded-For": "2001:db8::99"}, "2001:db8::99"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.remoteAddr
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, c.want, clientIP(req, trusted))
+		})
+	}
+
+	_, err = parseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+	_, err = parseTrustedProxies([]string{"proxy.internal"})
+	assert.Error(t, err)
+}
+
+func TestRealIPMiddlewareRateLimitsPerClient(t *testing.T) {
+	limiter = ratelimit.NewMemory(1, 1)
+	trusted, err := parseTrustedProxies([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	handlerToTest := realIPMiddleware(trusted)(rateLimitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	request := func(client string) int {
+		req := httptest.NewRequest("GET", "/news", nil)
+		req.RemoteAddr = "10.0.0.1:5000"
+		req.Header.Set("X-Forwarded-For", client)
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusOK, request("198.51.100.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.1"))
+	assert.Equal(t, http.StatusOK, request("198.51.100.2"), "clients behind the same proxy are limited separately")
+}
@@ -0,0 +1,38 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2024, 4, 9, 10, 17, 30, 0, time.UTC) // a Tuesday
+	for _, tc := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 4, 9, 10, 30, 0, 0, time.UTC)},
+		{"0 8 * * *", time.Date(2024, 4, 10, 8, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 4, 10, 9, 0, 0, 0, time.UTC)},
+		{"30 7 * * 7", time.Date(2024, 4, 14, 7, 30, 0, 0, time.UTC)},
+		{"0 6 1 */3 *", time.Date(2024, 7, 1, 6, 0, 0, 0, time.UTC)},
+		{"0 0 13 * fri", time.Date(2024, 4, 12, 0, 0, 0, 0, time.UTC)}, // day of month or Friday
+		{"0 12 29 feb *", time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 feb *", time.Time{}},
+	} {
+		s, err := Parse(tc.expr)
+		require.NoError(t, err, tc.expr)
+		assert.Equal(t, tc.want, s.Next(from), tc.expr)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
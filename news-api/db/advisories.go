@@ -0,0 +1,394 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/models"
+)
+
+// AdvisorySources are vendor security advisory sources fetched with the feeds,
+// for vendors whose RSS feeds say little beyond a title. Each is a CSAF 2.0
+// document, a ROLIE feed of CSAF documents or a CVRF 1.2 document (see
+// parseAdvisories). Their items are stored in the Cybersecurity category.
+var AdvisorySources []string
+
+const (
+	// maxAdvisoryBytes bounds the response read for each advisory document.
+	maxAdvisoryBytes = 16 << 20
+	// maxRolieEntries is the number of the newest documents of a ROLIE feed
+	// fetched in each cycle.
+	maxRolieEntries = 25
+
+	// msrcVulnerabilityURL links the vulnerabilities of MSRC CVRF documents,
+	// which carry no URL of their own.
+	msrcVulnerabilityURL = "https://msrc.microsoft.com/update-guide/vulnerability/"
+)
+
+// advisorySeverityWeights rank advisories by the severity their vendor gives
+// them, on top of the Cybersecurity keywords: a critical advisory alone is at
+// the top of the Cybersecurity scale.
+var advisorySeverityWeights = map[string]int{
+	"critical": 20,
+	"high":     12,
+	"medium":   6,
+	"low":      2,
+}
+
+// advisorySeverityPattern finds the severity line that advisory items start
+// with.
+var advisorySeverityPattern = regexp.MustCompile(`(?i)^severity: (critical|high|medium|low)\b`)
+
+func isAdvisorySource(source string) bool {
+	return slices.Contains(AdvisorySources, source)
+}
+
+// advisoryHit returns the ranking rule of an advisory's vendor severity, or
+// false if the article is not a vendor or GitHub advisory.
+func advisoryHit(article models.NewsArticle) (KeywordHit, bool) {
+	if !isAdvisorySource(article.SourceURL) && !isGHSASource(article.SourceURL) {
+		return KeywordHit{}, false
+	}
+	match := advisorySeverityPattern.FindStringSubmatch(article.Description)
+	if match == nil {
+		return KeywordHit{}, false
+	}
+	severity := strings.ToLower(match[1])
+	return KeywordHit{Keyword: "severity: " + severity, Weight: advisorySeverityWeights[severity], Rule: "advisory"}, true
+}
+
+// fetchAdvisoryFeed fetches an advisory source and converts it into a feed,
+// fetching the documents a ROLIE feed links to.
+func fetchAdvisoryFeed(client *http.Client, source string) (*gofeed.Feed, error) {
+	body, err := fetchAdvisoryDocument(client, source)
+	if err != nil {
+		return nil, err
+	}
+	var rolie rolieFeed
+	if json.Unmarshal(body, &rolie) == nil && rolie.Feed != nil {
+		return fetchRolieDocuments(client, rolie)
+	}
+	return parseAdvisories(body)
+}
+
+func fetchAdvisoryDocument(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxAdvisoryBytes))
+}
+
+// parseAdvisories converts a CSAF 2.0 JSON document into a feed of one item,
+// or a CVRF 1.2 XML document into a feed with an item per vulnerability.
+func parseAdvisories(body []byte) (*gofeed.Feed, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("empty advisory document")
+	}
+	if trimmed[0] == '<' {
+		return parseCVRF(trimmed)
+	}
+	var doc csafDocument
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse CSAF document: %v", err)
+	}
+	item := doc.feedItem()
+	if item == nil {
+		return nil, fmt.Errorf("CSAF document has no tracking ID or references")
+	}
+	return &gofeed.Feed{Title: doc.Document.Publisher.Name, Items: []*gofeed.Item{item}}, nil
+}
+
+// rolieFeed is a ROLIE feed (RFC 8322), which CSAF providers use to list their
+// documents.
+type rolieFeed struct {
+	Feed *struct {
+		Title string `json:"title"`
+		Entry []struct {
+			ID      string `json:"id"`
+			Updated string `json:"updated"`
+			Link    []struct {
+				Rel  string `json:"rel"`
+				Href string `json:"href"`
+			} `json:"link"`
+			Content struct {
+				Src string `json:"src"`
+			} `json:"content"`
+		} `json:"entry"`
+	} `json:"feed"`
+}
+
+// fetchRolieDocuments fetches the newest documents of a ROLIE feed. Documents
+// that fail are logged and skipped, so one broken link does not hold back the
+// others.
+func fetchRolieDocuments(client *http.Client, rolie rolieFeed) (*gofeed.Feed, error) {
+	entries := rolie.Feed.Entry
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Updated > entries[j].Updated })
+	feed := &gofeed.Feed{Title: rolie.Feed.Title}
+	for i := 0; i < len(entries) && i < maxRolieEntries; i++ {
+		url := entries[i].Content.Src
+		for _, link := range entries[i].Link {
+			if url == "" && link.Rel == "self" {
+				url = link.Href
+			}
+		}
+		if url == "" {
+			continue
+		}
+		body, err := fetchAdvisoryDocument(client, url)
+		if err == nil {
+			var documents *gofeed.Feed
+			if documents, err = parseAdvisories(body); err == nil {
+				feed.Items = append(feed.Items, documents.Items...)
+				continue
+			}
+		}
+		log.Printf("Error fetching advisory %s: %v", url, err)
+	}
+	return feed, nil
+}
+
+// csafDocument holds the parts of a CSAF 2.0 document that make an article.
+type csafDocument struct {
+	Document struct {
+		Title             string `json:"title"`
+		AggregateSeverity struct {
+			Text string `json:"text"`
+		} `json:"aggregate_severity"`
+		Notes []struct {
+			Category string `json:"category"`
+			Title    string `json:"title"`
+			Text     string `json:"text"`
+		} `json:"notes"`
+		Publisher struct {
+			Name string `json:"name"`
+		} `json:"publisher"`
+		References []struct {
+			Category string `json:"category"`
+			URL      string `json:"url"`
+		} `json:"references"`
+		Tracking struct {
+			ID                 string `json:"id"`
+			CurrentReleaseDate string `json:"current_release_date"`
+			InitialReleaseDate string `json:"initial_release_date"`
+		} `json:"tracking"`
+	} `json:"document"`
+	Vulnerabilities []struct {
+		CVE    string `json:"cve"`
+		Scores []struct {
+			CVSSv3 struct {
+				BaseScore float64 `json:"baseScore"`
+			} `json:"cvss_v3"`
+			CVSSv2 struct {
+				BaseScore float64 `json:"baseScore"`
+			} `json:"cvss_v2"`
+		} `json:"scores"`
+	} `json:"vulnerabilities"`
+}
+
+// feedItem converts a CSAF document into a feed item, or returns nil if it
+// has no tracking ID or no reference to link to.
+func (d csafDocument) feedItem() *gofeed.Item {
+	doc := d.Document
+	if doc.Tracking.ID == "" {
+		return nil
+	}
+	var cves []string
+	var score float64
+	for _, vuln := range d.Vulnerabilities {
+		if vuln.CVE != "" && !slices.Contains(cves, vuln.CVE) {
+			cves = append(cves, vuln.CVE)
+		}
+		for _, s := range vuln.Scores {
+			score = max(score, s.CVSSv3.BaseScore, s.CVSSv2.BaseScore)
+		}
+	}
+	var summary string
+	for _, note := range doc.Notes {
+		if note.Category == "summary" || (summary == "" && note.Category == "description") {
+			summary = note.Text
+		}
+	}
+
+	// The self reference is the advisory's page; the first other one is the
+	// best stand-in for vendors that omit it.
+	var link string
+	for _, ref := range doc.References {
+		if ref.Category == "self" && link == "" {
+			link = ref.URL
+		}
+	}
+	for _, ref := range doc.References {
+		if link == "" {
+			link = ref.URL
+		}
+	}
+	if link == "" {
+		return nil
+	}
+
+	item := &gofeed.Item{
+		Title:       firstNonEmpty(doc.Title, doc.Tracking.ID),
+		Link:        link,
+		GUID:        doc.Tracking.ID,
+		Description: advisoryDescription(advisorySeverity(doc.AggregateSeverity.Text, score), score, cves, summary),
+	}
+	if doc.Publisher.Name != "" {
+		item.Author = &gofeed.Person{Name: doc.Publisher.Name}
+	}
+	for _, date := range []string{doc.Tracking.CurrentReleaseDate, doc.Tracking.InitialReleaseDate} {
+		if t, err := time.Parse(time.RFC3339, date); err == nil {
+			t = t.UTC()
+			item.PublishedParsed = &t
+			break
+		}
+	}
+	return item
+}
+
+// cvrfDocument holds the parts of a CVRF 1.2 document, such as MSRC's monthly
+// security updates, that make articles. Elements are matched by local name, so
+// the CVRF namespaces need not be spelled out.
+type cvrfDocument struct {
+	Title    string `xml:"DocumentTitle"`
+	Tracking struct {
+		ID                 string `xml:"Identification>ID"`
+		CurrentReleaseDate string `xml:"CurrentReleaseDate"`
+	} `xml:"DocumentTracking"`
+	Publisher     string `xml:"DocumentPublisher>ContactDetails"`
+	Vulnerability []struct {
+		Title string `xml:"Title"`
+		CVE   string `xml:"CVE"`
+		Notes []struct {
+			Type  string `xml:"Type,attr"`
+			Title string `xml:"Title,attr"`
+			Text  string `xml:",chardata"`
+		} `xml:"Notes>Note"`
+		Threats []struct {
+			Type        string `xml:"Type,attr"`
+			Description string `xml:"Description"`
+		} `xml:"Threats>Threat"`
+		Scores []struct {
+			BaseScore string `xml:"BaseScore"`
+		} `xml:"CVSSScoreSets>ScoreSet"`
+		Revisions []struct {
+			Date string `xml:"Date"`
+		} `xml:"RevisionHistory>Revision"`
+	} `xml:"Vulnerability"`
+}
+
+// parseCVRF converts the vulnerabilities of a CVRF document that have a CVE
+// into items linking to their MSRC update guide page.
+func parseCVRF(body []byte) (*gofeed.Feed, error) {
+	var doc cvrfDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse CVRF document: %v", err)
+	}
+	released, _ := time.Parse(time.RFC3339, doc.Tracking.CurrentReleaseDate)
+	feed := &gofeed.Feed{Title: firstNonEmpty(doc.Title, doc.Tracking.ID)}
+	for _, vuln := range doc.Vulnerability {
+		cve := strings.TrimSpace(vuln.CVE)
+		if cve == "" {
+			continue
+		}
+		var severity, summary string
+		for _, threat := range vuln.Threats {
+			if threat.Type == "Severity" && severity == "" {
+				severity = threat.Description
+			}
+		}
+		var score float64
+		for _, set := range vuln.Scores {
+			if s, err := strconv.ParseFloat(strings.TrimSpace(set.BaseScore), 64); err == nil {
+				score = max(score, s)
+			}
+		}
+		for _, note := range vuln.Notes {
+			if note.Type == "Description" || (summary == "" && note.Type == "General") {
+				summary = strings.TrimSpace(note.Text)
+			}
+		}
+		item := &gofeed.Item{
+			Title:       cve + ": " + firstNonEmpty(vuln.Title, doc.Title),
+			Link:        msrcVulnerabilityURL + cve,
+			GUID:        cve,
+			Description: advisoryDescription(advisorySeverity(severity, score), score, []string{cve}, summary),
+		}
+		date := released
+		if n := len(vuln.Revisions); n > 0 {
+			if t, err := time.Parse(time.RFC3339, vuln.Revisions[n-1].Date); err == nil {
+				date = t
+			}
+		}
+		if !date.IsZero() {
+			date = date.UTC()
+			item.PublishedParsed = &date
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+// advisorySeverity normalizes a vendor severity to critical, high, medium or
+// low, falling back to the CVSS base score. It returns "" if neither is known.
+func advisorySeverity(vendor string, score float64) string {
+	switch strings.ToLower(strings.TrimSpace(vendor)) {
+	case "critical":
+		return "critical"
+	case "high", "important":
+		return "high"
+	case "medium", "moderate":
+		return "medium"
+	case "low":
+		return "low"
+	}
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	case score > 0:
+		return "low"
+	}
+	return ""
+}
+
+// advisoryDescription leads with the severity, score and CVEs of an advisory,
+// which rank it and link it to the CVEs, followed by its summary.
+func advisoryDescription(severity string, score float64, cves []string, summary string) string {
+	var lines []string
+	if severity != "" {
+		line := "Severity: " + strings.ToUpper(severity[:1]) + severity[1:]
+		if score > 0 {
+			line += " (CVSS " + strconv.FormatFloat(score, 'f', 1, 64) + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(cves) > 0 {
+		lines = append(lines, "CVEs: "+strings.Join(cves, ", "))
+	}
+	if summary = strings.TrimSpace(summary); summary != "" {
+		lines = append(lines, summary)
+	}
+	return strings.Join(lines, "\n")
+}
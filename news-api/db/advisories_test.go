@@ -0,0 +1,146 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+const testCSAF = `{
+	"document": {
+		"title": "Cisco IOS XE Web UI Privilege Escalation Vulnerability",
+		"aggregate_severity": {"text": "Critical"},
+		"publisher": {"name": "Cisco"},
+		"notes": [
+			{"category": "legal_disclaimer", "text": "Provided as is."},
+			{"category": "summary", "text": "Cisco is aware of active exploitation of the web UI."}
+		],
+		"references": [
+			{"category": "external", "url": "https://nvd.nist.gov/vuln/detail/CVE-2023-20198"},
+			{"category": "self", "url": "https://sec.cloudapps.cisco.com/security/center/content/CiscoSecurityAdvisory/cisco-sa-iosxe-webui-privesc-j22SaA4z"}
+		],
+		"tracking": {"id": "cisco-sa-iosxe-webui-privesc-j22SaA4z", "current_release_date": "%s", "initial_release_date": "2023-10-16T15:00:00Z"}
+	},
+	"vulnerabilities": [
+		{"cve": "CVE-2023-20198", "scores": [{"cvss_v3": {"baseScore": 10.0}}]},
+		{"cve": "CVE-2023-20273", "scores": [{"cvss_v3": {"baseScore": 7.2}}]}
+	]
+}`
+
+const testCVRF = `<?xml version="1.0" encoding="utf-8"?>
+<cvrfdoc xmlns="http://www.icasi.org/CVRF/schema/cvrf/1.1" xmlns:vuln="http://www.icasi.org/CVRF/schema/vuln/1.1">
+	<DocumentTitle>May 2024 Security Updates</DocumentTitle>
+	<DocumentTracking>
+		<Identification><ID>2024-May</ID></Identification>
+		<CurrentReleaseDate>2024-05-14T07:00:00Z</CurrentReleaseDate>
+	</DocumentTracking>
+	<vuln:Vulnerability>
+		<vuln:Title>Windows DWM Core Library Elevation of Privilege Vulnerability</vuln:Title>
+		<vuln:CVE>CVE-2024-30051</vuln:CVE>
+		<vuln:Threats>
+			<vuln:Threat Type="Impact"><vuln:Description>Elevation of Privilege</vuln:Description></vuln:Threat>
+			<vuln:Threat Type="Severity"><vuln:Description>Important</vuln:Description></vuln:Threat>
+		</vuln:Threats>
+		<vuln:CVSSScoreSets><vuln:ScoreSet><vuln:BaseScore>7.8</vuln:BaseScore></vuln:ScoreSet></vuln:CVSSScoreSets>
+	</vuln:Vulnerability>
+	<vuln:Vulnerability>
+		<vuln:Title>Windows MSHTML Platform Security Feature Bypass Vulnerability</vuln:Title>
+		<vuln:CVE>CVE-2024-30040</vuln:CVE>
+		<vuln:CVSSScoreSets><vuln:ScoreSet><vuln:BaseScore>8.8</vuln:BaseScore></vuln:ScoreSet></vuln:CVSSScoreSets>
+		<vuln:RevisionHistory><vuln:Revision><vuln:Date>2024-05-15T08:00:00Z</vuln:Date></vuln:Revision></vuln:RevisionHistory>
+	</vuln:Vulnerability>
+	<vuln:Vulnerability>
+		<vuln:Title>Advisory without a CVE</vuln:Title>
+	</vuln:Vulnerability>
+</cvrfdoc>`
+
+func TestParseAdvisories(t *testing.T) {
+	feed, err := parseAdvisories([]byte(fmt.Sprintf(testCSAF, "2023-10-20T08:00:00Z")))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 1)
+	item := feed.Items[0]
+	assert.Equal(t, "Cisco IOS XE Web UI Privilege Escalation Vulnerability", item.Title)
+	assert.Equal(t, "https://sec.cloudapps.cisco.com/security/center/content/CiscoSecurityAdvisory/cisco-sa-iosxe-webui-privesc-j22SaA4z", item.Link)
+	assert.Equal(t, "cisco-sa-iosxe-webui-privesc-j22SaA4z", item.GUID)
+	assert.Equal(t, "Severity: Critical (CVSS 10.0)\nCVEs: CVE-2023-20198, CVE-2023-20273\nCisco is aware of active exploitation of the web UI.", item.Description)
+	assert.Equal(t, "Cisco", item.Author.Name)
+	assert.Equal(t, time.Date(2023, 10, 20, 8, 0, 0, 0, time.UTC), *item.PublishedParsed)
+
+	feed, err = parseAdvisories([]byte(testCVRF))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 2)
+	item = feed.Items[0]
+	assert.Equal(t, "CVE-2024-30051: Windows DWM Core Library Elevation of Privilege Vulnerability", item.Title)
+	assert.Equal(t, "https://msrc.microsoft.com/update-guide/vulnerability/CVE-2024-30051", item.Link)
+	assert.Equal(t, "CVE-2024-30051", item.GUID)
+	assert.Equal(t, "Severity: High (CVSS 7.8)\nCVEs: CVE-2024-30051", item.Description)
+	assert.Equal(t, time.Date(2024, 5, 14, 7, 0, 0, 0, time.UTC), *item.PublishedParsed)
+	// Without a vendor severity, the CVSS score decides.
+	assert.Equal(t, "Severity: High (CVSS 8.8)\nCVEs: CVE-2024-30040", feed.Items[1].Description)
+	assert.Equal(t, time.Date(2024, 5, 15, 8, 0, 0, 0, time.UTC), *feed.Items[1].PublishedParsed)
+
+	_, err = parseAdvisories([]byte(`{"document": {"title": "No tracking"}}`))
+	assert.Error(t, err)
+	_, err = parseAdvisories([]byte(`not an advisory`))
+	assert.Error(t, err)
+}
+
+func TestAdvisorySeverity(t *testing.T) {
+	assert.Equal(t, "high", advisorySeverity("Important", 9.8))
+	assert.Equal(t, "medium", advisorySeverity("Moderate", 0))
+	assert.Equal(t, "critical", advisorySeverity("", 9.0))
+	assert.Equal(t, "medium", advisorySeverity("unrated", 4.3))
+	assert.Equal(t, "low", advisorySeverity("", 0.1))
+	assert.Equal(t, "", advisorySeverity("", 0))
+}
+
+func TestIngestRolieAdvisories(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	released := time.Now().UTC().Format(time.RFC3339)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rolie.json":
+			fmt.Fprintf(w, `{"feed": {"title": "Cisco CSAF", "entry": [
+				{"id": "old", "updated": "2020-01-01T00:00:00Z", "link": [{"rel": "self", "href": "%[1]s/missing.json"}]},
+				{"id": "new", "updated": "%[2]s", "content": {"src": "%[1]s/cisco-sa.json"}}
+			]}}`, server.URL, released)
+		case "/cisco-sa.json":
+			fmt.Fprintf(w, testCSAF, released)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	source := server.URL + "/rolie.json"
+	defer func(sources []string) { AdvisorySources = sources }(AdvisorySources)
+	AdvisorySources = []string{source}
+
+	fetchAndCacheNews([]string{source})
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	article := articles[0]
+	assert.Equal(t, "Cybersecurity", article.Category)
+	assert.Equal(t, 100, article.Severity)
+
+	hits := rankHits(article, models.OrgSettings{})
+	assert.Contains(t, hits, KeywordHit{Keyword: "severity: critical", Weight: 20, Rule: "advisory"})
+
+	cves, err := GetTopCVEs(0, time.Now().Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, cves, 2)
+	assert.ElementsMatch(t, []string{"CVE-2023-20198", "CVE-2023-20273"}, []string{cves[0].CVE, cves[1].CVE})
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+func createAuditTables() error {
+	createAuditSQL := `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		before_value TEXT,
+		after_value TEXT,
+		remote_addr TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_log (action, id);
+	`
+	if _, err := db.Exec(createAuditSQL); err != nil {
+		return fmt.Errorf("failed to create audit_log table: %v", err)
+	}
+	return nil
+}
+
+// RecordAudit appends an entry to the audit log. Before and After are stored as
+// given and should already be JSON; empty values are stored as NULL.
+func RecordAudit(entry models.AuditEntry) (models.AuditEntry, error) {
+	if db == nil {
+		return models.AuditEntry{}, fmt.Errorf("database connection is nil")
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	res, err := db.Exec("INSERT INTO audit_log(actor, action, target, before_value, after_value, remote_addr, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		entry.Actor, entry.Action, entry.Target, nullableJSON(entry.Before), nullableJSON(entry.After), entry.RemoteAddr, entry.CreatedAt)
+	if err != nil {
+		return models.AuditEntry{}, err
+	}
+	entry.ID, err = res.LastInsertId()
+	return entry, err
+}
+
+// AuditFilter selects audit log entries, newest first. Zero values mean "no restriction".
+type AuditFilter struct {
+	Action string
+	Target string
+	Actor  string
+	// BeforeID returns only entries older than the given entry, for paging.
+	BeforeID int64
+	Limit    int
+}
+
+// GetAuditLog returns the audit log entries matching the filter, newest first.
+func GetAuditLog(f AuditFilter) ([]models.AuditEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	whereClauses := []string{}
+	args := []interface{}{}
+	if f.Action != "" {
+		whereClauses = append(whereClauses, "action = ?")
+		args = append(args, f.Action)
+	}
+	if f.Target != "" {
+		whereClauses = append(whereClauses, "target = ?")
+		args = append(args, f.Target)
+	}
+	if f.Actor != "" {
+		whereClauses = append(whereClauses, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.BeforeID > 0 {
+		whereClauses = append(whereClauses, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+
+	query := "SELECT id, actor, action, target, before_value, after_value, remote_addr, created_at FROM audit_log"
+	if len(whereClauses) > 0 {
+		query += " WHERE " + strings.Join(whereClauses, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after *string
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Target, &before, &after, &entry.RemoteAddr, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			entry.Before = json.RawMessage(*before)
+		}
+		if after != nil {
+			entry.After = json.RawMessage(*after)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func nullableJSON(v json.RawMessage) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+	return string(v)
+}
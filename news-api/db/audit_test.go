@@ -0,0 +1,37 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	setupTestDB(t)
+
+	_, err := RecordAudit(models.AuditEntry{Actor: "admin", Action: "org.create", Target: "org:1", After: json.RawMessage(`{"name":"Acme"}`)})
+	require.NoError(t, err)
+	_, err = RecordAudit(models.AuditEntry{Actor: "admin:alice", Action: "keywords.set", Target: "org:1", Before: json.RawMessage(`{}`), After: json.RawMessage(`{"acme":3}`)})
+	require.NoError(t, err)
+
+	entries, err := GetAuditLog(AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "keywords.set", entries[0].Action)
+	assert.JSONEq(t, `{"acme":3}`, string(entries[0].After))
+	assert.Nil(t, entries[1].Before)
+
+	entries, err = GetAuditLog(AuditFilter{Actor: "admin"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "org.create", entries[0].Action)
+
+	entries, err = GetAuditLog(AuditFilter{BeforeID: entries[0].ID + 1, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "org.create", entries[0].Action)
+}
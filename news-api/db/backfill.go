@@ -0,0 +1,228 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// nextPageLink matches the link to the next page of a paginated feed
+// (RFC 5005), in Atom feeds and in RSS feeds using the Atom namespace.
+var nextPageLink = regexp.MustCompile(`<(?:atom:)?link\s[^>]*rel=["']next["'][^>]*>`)
+
+var hrefAttribute = regexp.MustCompile(`href=["']([^"']+)["']`)
+
+// BackfillOptions configures a backfill of a source's history.
+type BackfillOptions struct {
+	Source string
+	// Since skips the items published before it. Pages stop being walked
+	// once a page only has items before it.
+	Since time.Time
+	// MaxPages bounds the archive pages and the Wayback Machine captures
+	// fetched, each.
+	MaxPages int
+	// Wayback also processes the captures of the feed by the Wayback
+	// Machine since Since.
+	Wayback bool
+	// Delay is the pause between two page requests.
+	Delay  time.Duration
+	DryRun bool
+	// Progress, if set, is called with the counts of every page processed.
+	Progress func(page string, counts SourceReport)
+}
+
+// Backfill ingests the history of a source beyond its current feed window:
+// the pages of a paginated feed, followed through their rel="next" links or
+// WordPress's ?paged= parameter, and optionally the feed's captures by the
+// Wayback Machine. Pages are processed like replayed recordings, so the
+// items seen from the source and its health are left alone. With DryRun
+// nothing is written.
+func Backfill(opts BackfillOptions) (IngestReport, error) {
+	total := newIngestReport()
+	if db == nil {
+		return *total, fmt.Errorf("database connection is nil")
+	}
+	if !recordableSource(opts.Source) {
+		return *total, ErrUnrecordableSource
+	}
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = 50
+	}
+	b := &backfill{
+		BackfillOptions: opts,
+		client:          fetcher.Client(30 * time.Second),
+		total:           total,
+		items:           map[string]bool{},
+		seen:            map[string]bool{},
+	}
+
+	pageURL := opts.Source
+	for page := 1; page <= opts.MaxPages && pageURL != ""; page++ {
+		if page > 1 {
+			time.Sleep(opts.Delay)
+		}
+		body, err := b.get(pageURL)
+		if err != nil {
+			if page == 1 {
+				return *total, err
+			}
+			// Archives commonly end with a 404.
+			log.Printf("Backfill of %s stopped at %s: %v", opts.Source, pageURL, err)
+			break
+		}
+		feed, fresh, err := b.process(pageURL, body)
+		if err != nil {
+			if page == 1 {
+				return *total, err
+			}
+			log.Printf("Backfill of %s stopped at %s: %v", opts.Source, pageURL, err)
+			break
+		}
+		// Sites ignoring the page parameter serve the first page again.
+		if !fresh || olderThan(feed, opts.Since) {
+			break
+		}
+		pageURL = nextPage(pageURL, opts.Source, body, feed, page)
+	}
+
+	if opts.Wayback {
+		snapshots, err := waybackSnapshots(b.client, opts.Source, opts.Since)
+		if err != nil {
+			return *total, fmt.Errorf("failed to list wayback machine captures: %v", err)
+		}
+		for _, snapshot := range spread(snapshots, opts.MaxPages) {
+			time.Sleep(opts.Delay)
+			body, err := b.get(snapshot.RawURL())
+			if err != nil {
+				log.Printf("Skipping capture %s: %v", snapshot.RawURL(), err)
+				continue
+			}
+			if _, _, err := b.process(snapshot.RawURL(), body); err != nil {
+				log.Printf("Skipping capture %s: %v", snapshot.RawURL(), err)
+			}
+		}
+	}
+	return *total, nil
+}
+
+type backfill struct {
+	BackfillOptions
+	client *http.Client
+	total  *IngestReport
+	// items holds the keys of the items found so far, and seen the articles
+	// a dry run would insert.
+	items map[string]bool
+	seen  map[string]bool
+}
+
+func (b *backfill) get(pageURL string) ([]byte, error) {
+	resp, err := b.client.Get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http error: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxFixtureBytes))
+}
+
+// process runs a page through the caching pipeline unless all its items were
+// found on earlier pages, and reports whether any was new.
+func (b *backfill) process(pageURL string, body []byte) (*gofeed.Feed, bool, error) {
+	feed, err := gofeed.NewParser().Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	fresh := false
+	for _, item := range feed.Items {
+		if key := itemKey(item); !b.items[key] {
+			b.items[key] = true
+			fresh = true
+		}
+	}
+	if !fresh {
+		return feed, false, nil
+	}
+	page := recordedFeed{fixture: models.FeedFixture{SourceURL: b.Source, StatusCode: http.StatusOK, Status: "200 OK"}, body: body}
+	report := ingest([]string{b.Source}, ingestOptions{
+		dryRun: b.DryRun,
+		replay: map[string]recordedFeed{b.Source: page},
+		since:  b.Since,
+		seen:   b.seen,
+	})
+	b.total.add(report)
+	if b.Progress != nil && len(report.Sources) > 0 {
+		b.Progress(pageURL, report.Sources[0])
+	}
+	return feed, true, nil
+}
+
+// olderThan reports whether all dated items of a feed were published before t.
+func olderThan(feed *gofeed.Feed, t time.Time) bool {
+	dated := false
+	for _, item := range feed.Items {
+		if item.PublishedParsed == nil {
+			continue
+		}
+		if !item.PublishedParsed.Before(t) {
+			return false
+		}
+		dated = true
+	}
+	return dated
+}
+
+// nextPage returns the URL of the page after a feed page: its rel="next"
+// link, or for WordPress feeds the next ?paged= page of the source, or ""
+// if there is none.
+func nextPage(pageURL, source string, body []byte, feed *gofeed.Feed, page int) string {
+	if tag := nextPageLink.Find(body); tag != nil {
+		if m := hrefAttribute.FindSubmatch(tag); m != nil {
+			base, err := url.Parse(pageURL)
+			next, err2 := url.Parse(strings.ReplaceAll(string(m[1]), "&amp;", "&"))
+			if err == nil && err2 == nil {
+				return base.ResolveReference(next).String()
+			}
+		}
+	}
+	if !strings.Contains(strings.ToLower(feed.Generator), "wordpress") {
+		return ""
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Set("paged", strconv.Itoa(page+1))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// spread picks up to n snapshots evenly across the list, keeping the first
+// and last.
+func spread(snapshots []waybackSnapshot, n int) []waybackSnapshot {
+	if len(snapshots) <= n {
+		return snapshots
+	}
+	if n == 1 {
+		return snapshots[len(snapshots)-1:]
+	}
+	picked := make([]waybackSnapshot, n)
+	for i := range picked {
+		picked[i] = snapshots[i*(len(snapshots)-1)/(n-1)]
+	}
+	return picked
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfill(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	pages := map[string][]string{
+		"1": {itemXML("p1", "Hackers breach a water utility network", "Wed, 01 May 2024 10:00:00 GMT")},
+		"2": {
+			itemXML("p2", "Ransomware gang leaks hospital patient records", "Fri, 01 Mar 2024 10:00:00 GMT"),
+			itemXML("p3", "Zero-day exploited in VPN appliances", "Mon, 05 Jun 2023 10:00:00 GMT"),
+		},
+		"3": {itemXML("old", "Botnet hijacks home routers worldwide", "Thu, 01 Dec 2022 10:00:00 GMT")},
+		"4": {itemXML("older", "Spyware campaign targets journalists", "Tue, 01 Nov 2022 10:00:00 GMT")},
+	}
+	var server *httptest.Server
+	var requested []string
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/cdx/search/cdx":
+			assert.Equal(t, "20230101000000", r.URL.Query().Get("from"))
+			fmt.Fprintf(w, `[["timestamp","original"],["20230701000000","%[1]s/feed"],["20240101000000","%[1]s/feed"]]`, server.URL)
+			return
+		case strings.HasPrefix(r.URL.Path, "/web/20230701000000id_/"):
+			writeTestFeed(w, "", itemXML("wb", "Supply chain attack hits package registry", "Sat, 01 Jul 2023 10:00:00 GMT"), pages["2"][1])
+			return
+		case strings.HasPrefix(r.URL.Path, "/web/"):
+			writeTestFeed(w, "", pages["2"]...)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		items, ok := pages[page]
+		if !ok || r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		n, _ := strconv.Atoi(page)
+		next := fmt.Sprintf(`<atom:link href="/feed?page=%d&amp;x=1" rel="next" xmlns:atom="http://www.w3.org/2005/Atom"/>`, n+1)
+		writeTestFeed(w, next, items...)
+	}))
+	defer server.Close()
+	defer func(base string) { WaybackURL = base }(WaybackURL)
+	WaybackURL = server.URL
+	source := server.URL + "/feed"
+
+	var progress []string
+	opts := BackfillOptions{
+		Source:   source,
+		Since:    time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		Wayback:  true,
+		DryRun:   true,
+		Progress: func(page string, counts SourceReport) { progress = append(progress, page) },
+	}
+	report, err := Backfill(opts)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.New)
+	require.Len(t, report.Sources, 1)
+	assert.Equal(t, 1, report.Sources[0].BeforeSince)
+	assert.Equal(t, 1, report.Duplicates, "items found again in a capture are duplicates")
+	assert.Len(t, progress, 4, "the capture repeating an archive page is skipped")
+	assert.NotContains(t, requested, "/feed?page=4&x=1", "pages before since end the walk")
+	count, err := GetArticleCount()
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	opts.DryRun = false
+	opts.Wayback = false
+	report, err = Backfill(opts)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.New)
+	count, err = GetArticleCount()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = Backfill(BackfillOptions{Source: server.URL + "/missing", Since: opts.Since})
+	assert.Error(t, err)
+}
+
+func writeTestFeed(w http.ResponseWriter, head string, items ...string) {
+	w.Header().Set("Content-Type", "application/rss+xml")
+	fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>%s`, head)
+	for _, item := range items {
+		fmt.Fprint(w, item)
+	}
+	fmt.Fprint(w, `</channel></rss>`)
+}
@@ -0,0 +1,321 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// BodyArchiveConfig controls the offline copy of article bodies.
+type BodyArchiveConfig struct {
+	// MinSeverity is the severity an article needs for its body to be kept.
+	MinSeverity int
+	// Batch is the number of pages fetched after each caching cycle.
+	Batch int
+	// ExcludedSources lists feeds whose articles are never archived, e.g.
+	// publishers that do not allow it.
+	ExcludedSources []string
+}
+
+const (
+	// bodyFetchTimeout bounds fetching an article page for its body.
+	bodyFetchTimeout = 15 * time.Second
+	// bodyMaxBytes limits how much of an article page is read.
+	bodyMaxBytes = 4 << 20
+)
+
+var bodyClient = fetcher.PoliteClient(bodyFetchTimeout)
+
+func createArticleBodyTables() error {
+	createBodiesSQL := `
+	CREATE TABLE IF NOT EXISTS article_bodies (
+		url TEXT PRIMARY KEY,
+		status INTEGER NOT NULL,
+		html TEXT NOT NULL DEFAULT '',
+		text TEXT NOT NULL DEFAULT '',
+		fetched_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createBodiesSQL); err != nil {
+		return fmt.Errorf("failed to create article body tables: %v", err)
+	}
+	return nil
+}
+
+// StartBodyArchive archives the bodies of newly qualifying articles after
+// each caching cycle.
+func StartBodyArchive(cfg BodyArchiveConfig) {
+	RegisterCycleHook(func() {
+		if stored, err := ArchiveArticleBodies(cfg); err != nil {
+			log.Printf("Error archiving article bodies: %v", err)
+		} else if stored > 0 {
+			log.Printf("Archived %d article bodies.", stored)
+		}
+	})
+}
+
+// ArchiveArticleBodies fetches and stores the readable body of up to
+// cfg.Batch articles at or above cfg.MinSeverity that have none yet, most
+// recent first. Pages are fetched once: a failed fetch is recorded and not
+// retried. It returns the number of bodies stored.
+func ArchiveArticleBodies(cfg BodyArchiveConfig) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	query := `SELECT url FROM articles WHERE severity >= ? AND ` + notDeletedCondition + ` AND NOT ` + deadLinkCondition + `
+		AND url NOT IN (SELECT url FROM article_bodies)`
+	args := []interface{}{cfg.MinSeverity}
+	if len(cfg.ExcludedSources) > 0 {
+		query += " AND sourceUrl NOT IN (?" + strings.Repeat(", ?", len(cfg.ExcludedSources)-1) + ")"
+		for _, source := range cfg.ExcludedSources {
+			args = append(args, source)
+		}
+	}
+	query += " GROUP BY url ORDER BY MAX(publishedAt) DESC LIMIT ?"
+	args = append(args, cfg.Batch)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, u)
+	}
+	rows.Close()
+
+	stored := 0
+	for _, link := range urls {
+		status, body, text, paywalled := fetchArticleBody(link)
+		if paywalled {
+			if err := markPaywalled(link); err != nil {
+				return stored, fmt.Errorf("failed to flag paywalled article: %v", err)
+			}
+		}
+		if _, err := db.Exec("INSERT OR REPLACE INTO article_bodies(url, status, html, text, fetched_at) VALUES(?, ?, ?, ?, ?)",
+			link, status, body, text, time.Now().UTC()); err != nil {
+			return stored, fmt.Errorf("failed to store article body: %v", err)
+		}
+		if text != "" {
+			stored++
+		}
+	}
+	return stored, nil
+}
+
+// fetchArticleBody returns the HTTP status of an article page with its
+// sanitized readable HTML and plain text, which are empty if the page could
+// not be fetched or has no recognizable body, and whether the page is
+// paywalled (see isPaywalledPage).
+func fetchArticleBody(link string) (int, string, string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0, "", "", false
+	}
+	resp, err := bodyClient.Get(link)
+	if err != nil {
+		log.Printf("Error fetching %s for its body: %v", link, err)
+		return 0, "", "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, "", "", isPaywalledPage(resp.StatusCode, "")
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return resp.StatusCode, "", "", false
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, bodyMaxBytes))
+	if err != nil {
+		log.Printf("Error reading %s for its body: %v", link, err)
+		return 0, "", "", false
+	}
+	body, text := extractReadable(bytes.NewReader(page), resp.Request.URL)
+	return resp.StatusCode, body, text, isPaywalledPage(resp.StatusCode, string(page))
+}
+
+// unreadableElements are dropped before looking for the article body.
+var unreadableElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "nav": true, "header": true, "footer": true,
+	"aside": true, "form": true, "iframe": true, "svg": true, "button": true, "template": true,
+}
+
+// blockElements end a paragraph in the plain text of a body.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "li": true, "blockquote": true, "pre": true, "br": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "figcaption": true,
+}
+
+// extractReadable finds the main content of an HTML page, in the spirit of
+// reader modes: the <article> or <main> element if there is one, otherwise
+// the element holding the most paragraph text. Relative links are resolved
+// against base. It returns the content as sanitized HTML and as plain text.
+func extractReadable(r io.Reader, base *url.URL) (string, string) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", ""
+	}
+	removeUnreadable(doc)
+
+	content := findElement(doc, "article")
+	if content == nil {
+		content = findElement(doc, "main")
+	}
+	if content == nil {
+		content = densestParagraphParent(doc)
+	}
+	if content == nil {
+		return "", ""
+	}
+
+	var text strings.Builder
+	writeText(&text, content)
+	plain := strings.TrimSpace(collapseBlankLines(text.String()))
+	if plain == "" {
+		return "", ""
+	}
+
+	resolveLinks(content, base)
+	var buf bytes.Buffer
+	for c := content.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", ""
+		}
+	}
+	return strings.TrimSpace(ugcPolicy.Sanitize(buf.String())), plain
+}
+
+func removeUnreadable(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && unreadableElements[c.Data]) {
+			n.RemoveChild(c)
+		} else {
+			removeUnreadable(c)
+		}
+		c = next
+	}
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// densestParagraphParent returns the element whose <p> children hold the most text.
+func densestParagraphParent(doc *html.Node) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		length := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "p" {
+				var text strings.Builder
+				writeText(&text, c)
+				length += len(strings.TrimSpace(text.String()))
+			}
+			walk(c)
+		}
+		if length > bestLen {
+			best, bestLen = n, length
+		}
+	}
+	walk(doc)
+	return best
+}
+
+// writeText writes the text of a node, collapsing whitespace and separating
+// block elements by blank lines.
+func writeText(w *strings.Builder, n *html.Node) {
+	if n.Type == html.TextNode {
+		if words := strings.Fields(n.Data); len(words) > 0 {
+			if w.Len() > 0 && !strings.HasSuffix(w.String(), "\n") && !strings.HasSuffix(w.String(), " ") {
+				if first := n.Data[0]; first == ' ' || first == '\t' || first == '\n' || first == '\r' {
+					w.WriteByte(' ')
+				}
+			}
+			w.WriteString(strings.Join(words, " "))
+			if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\t' || last == '\n' || last == '\r' {
+				w.WriteByte(' ')
+			}
+		}
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeText(w, c)
+	}
+	if n.Type == html.ElementNode && blockElements[n.Data] {
+		w.WriteString("\n\n")
+	}
+}
+
+// collapseBlankLines trims each line and leaves at most one blank line between paragraphs.
+func collapseBlankLines(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			blank = len(lines) > 0
+			continue
+		}
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func resolveLinks(n *html.Node, base *url.URL) {
+	if n.Type == html.ElementNode {
+		for i, attr := range n.Attr {
+			if attr.Key == "href" || attr.Key == "src" {
+				if u, err := base.Parse(strings.TrimSpace(attr.Val)); err == nil {
+					n.Attr[i].Val = u.String()
+				}
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		resolveLinks(c, base)
+	}
+}
+
+// GetArticleBody returns the archived body of an article, or sql.ErrNoRows if
+// the article does not belong to the organization or has no archived body.
+func GetArticleBody(orgID, articleID int64) (models.ArticleBody, error) {
+	if db == nil {
+		return models.ArticleBody{}, fmt.Errorf("database connection is nil")
+	}
+	var body models.ArticleBody
+	err := db.QueryRow(`SELECT a.id, a.url, b.html, b.text, b.fetched_at
+		FROM articles a JOIN article_bodies b ON b.url = a.url
+		WHERE a.id = ? AND a.org_id = ? AND a.deleted_at IS NULL AND b.text != ''`, articleID, orgID).
+		Scan(&body.ArticleID, &body.URL, &body.HTML, &body.Text, &body.FetchedAt)
+	return body, err
+}
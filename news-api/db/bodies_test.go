@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractReadable(t *testing.T) {
+	base, _ := url.Parse("https://example.com/news/story")
+	page := `<html><head><title>Story</title><script>var x = 1;</script></head><body>
+		<nav><a href="/">Home</a></nav>
+		<article>
+			<h1>Zero-day exploited</h1>
+			<p>Attackers are <b>exploiting</b> a flaw in <a href="/vendor" onclick="steal()">Vendor</a> gateways.</p>
+			<script>track()</script>
+			<p>Patch now.</p>
+		</article>
+		<footer>Copyright</footer>
+	</body></html>`
+
+	body, text := extractReadable(strings.NewReader(page), base)
+	assert.Equal(t, "Zero-day exploited\n\nAttackers are exploiting a flaw in Vendor gateways.\n\nPatch now.", text)
+	assert.Contains(t, body, `<a href="https://example.com/vendor"`)
+	assert.Contains(t, body, "<b>exploiting</b>")
+	assert.NotContains(t, body, "onclick")
+	assert.NotContains(t, body, "track()")
+	assert.NotContains(t, body, "Home")
+
+	// Without <article> or <main>, the element with the most paragraph text wins.
+	page = `<body><div><p>Menu</p></div><div class="content"><p>First paragraph of the story.</p><p>Second one.</p></div></body>`
+	_, text = extractReadable(strings.NewReader(page), base)
+	assert.Equal(t, "First paragraph of the story.\n\nSecond one.", text)
+
+	_, text = extractReadable(strings.NewReader(`<body><img src="x.png"></body>`), base)
+	assert.Empty(t, text)
+}
+
+func TestArchiveArticleBodies(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	var fetched []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+			return
+		}
+		fetched = append(fetched, r.URL.Path)
+		if r.URL.Path == "/removed" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><body><main><p>Full story about the supply chain backdoor.</p></main></body></html>`))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	articles := []models.NewsArticle{
+		{Title: "High", URL: server.URL + "/high", SourceURL: "feed", Severity: 80, PublishedAt: now},
+		{Title: "Low", URL: server.URL + "/low", SourceURL: "feed", Severity: 5, PublishedAt: now},
+		{Title: "Removed", URL: server.URL + "/removed", SourceURL: "feed", Severity: 80, PublishedAt: now},
+		{Title: "Opted out", URL: server.URL + "/opted-out", SourceURL: "no-archive", Severity: 80, PublishedAt: now},
+		{Title: "High", URL: server.URL + "/high", SourceURL: "feed", Severity: 80, OrgID: 2, PublishedAt: now},
+	}
+	for _, a := range articles {
+		require.NoError(t, InsertArticle(a))
+	}
+
+	cfg := BodyArchiveConfig{MinSeverity: 25, Batch: 10, ExcludedSources: []string{"no-archive"}}
+	stored, err := ArchiveArticleBodies(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored)
+	assert.ElementsMatch(t, []string{"/high", "/removed"}, fetched)
+
+	// Pages are only fetched once, including failures.
+	fetched = nil
+	stored, err = ArchiveArticleBodies(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stored)
+	assert.Empty(t, fetched)
+
+	// The body is searchable and viewable from every organization with the article.
+	found, err := QueryArticles(ArticleFilter{Search: "BACKDOOR"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "High", found[0].Title)
+
+	body, err := GetArticleBody(0, found[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Full story about the supply chain backdoor.", body.Text)
+	assert.Equal(t, "<p>Full story about the supply chain backdoor.</p>", body.HTML)
+	_, err = GetArticleBody(2, found[0].ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "articles of other organizations are not visible")
+
+	removed, err := QueryArticles(ArticleFilter{Search: "Removed"})
+	require.NoError(t, err)
+	require.Len(t, removed, 1)
+	_, err = GetArticleBody(0, removed[0].ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
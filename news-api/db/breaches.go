@@ -0,0 +1,110 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+func createBreachTables() error {
+	var existing int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'breaches'").Scan(&existing); err != nil {
+		return fmt.Errorf("failed to inspect schema: %v", err)
+	}
+	createBreachesSQL := `
+	CREATE TABLE IF NOT EXISTS breaches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		article_id INTEGER NOT NULL UNIQUE,
+		org_id INTEGER NOT NULL DEFAULT 0,
+		organization TEXT NOT NULL,
+		records INTEGER NOT NULL DEFAULT 0,
+		reported_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_breaches_org_reported ON breaches (org_id, reported_at);
+	`
+	if _, err := db.Exec(createBreachesSQL); err != nil {
+		return fmt.Errorf("failed to create breaches table: %v", err)
+	}
+	if existing == 0 {
+		return backfillArticles("breaches", recordArticleBreach)
+	}
+	return nil
+}
+
+// recordArticleBreach stores the data breach an article's title and
+// description announce, if any, dated by the stored article's publish date.
+func recordArticleBreach(articleID int64, article models.NewsArticle) error {
+	breach, ok := extract.DataBreach(article.Title + " " + article.Description)
+	if !ok {
+		return nil
+	}
+	_, err := db.Exec(`INSERT OR REPLACE INTO breaches(article_id, org_id, organization, records, reported_at)
+		SELECT id, org_id, ?, ?, publishedAt FROM articles WHERE id = ?`, breach.Organization, breach.Records, articleID)
+	return err
+}
+
+// BreachFilter selects breaches for GetBreaches.
+type BreachFilter struct {
+	OrgID int64
+	// Organization matches breached organizations containing it, ignoring case.
+	Organization string
+	MinRecords   int64
+	StartDate    time.Time
+	EndDate      time.Time
+	Limit        int
+	Offset       int
+}
+
+// GetBreaches returns the breaches matching the filter, most recently
+// reported first, with the article that reported each.
+func GetBreaches(f BreachFilter) ([]models.Breach, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	whereClauses := []string{"b.org_id = ?", "a.deleted_at IS NULL"}
+	args := []interface{}{f.OrgID}
+	if f.Organization != "" {
+		whereClauses = append(whereClauses, "LOWER(b.organization) LIKE ?")
+		args = append(args, "%"+strings.ToLower(f.Organization)+"%")
+	}
+	if f.MinRecords > 0 {
+		whereClauses = append(whereClauses, "b.records >= ?")
+		args = append(args, f.MinRecords)
+	}
+	if !f.StartDate.IsZero() {
+		whereClauses = append(whereClauses, "b.reported_at >= ?")
+		args = append(args, utcTimestamp(f.StartDate))
+	}
+	if !f.EndDate.IsZero() {
+		whereClauses = append(whereClauses, "b.reported_at <= ?")
+		args = append(args, utcTimestamp(f.EndDate))
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	args = append(args, limit, f.Offset)
+
+	rows, err := db.Query(`SELECT b.id, b.organization, b.records, b.reported_at, a.id, a.title, a.url, a.sourceUrl
+		FROM breaches b JOIN articles a ON a.id = b.article_id
+		WHERE `+strings.Join(whereClauses, " AND ")+`
+		ORDER BY b.reported_at DESC, b.id DESC
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breaches := []models.Breach{}
+	for rows.Next() {
+		var b models.Breach
+		if err := rows.Scan(&b.ID, &b.Organization, &b.Records, &b.ReportedAt, &b.ArticleID, &b.Title, &b.URL, &b.SourceURL); err != nil {
+			return nil, err
+		}
+		breaches = append(breaches, b)
+	}
+	return breaches, rows.Err()
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBreaches(t *testing.T) {
+	setupTestDB(t)
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, article := range []models.NewsArticle{
+		{Title: "Ticketmaster confirms data breach", Description: "560 million customers affected.", URL: "u1", PublishedAt: day},
+		{Title: "Acme Bank breach exposes 20,000 accounts", URL: "u2", PublishedAt: day.Add(24 * time.Hour)},
+		{Title: "Patch Tuesday fixes 60 bugs", URL: "u3", PublishedAt: day},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	breaches, err := GetBreaches(BreachFilter{})
+	require.NoError(t, err)
+	require.Len(t, breaches, 2)
+	assert.Equal(t, "Acme Bank", breaches[0].Organization)
+	assert.Equal(t, int64(20_000), breaches[0].Records)
+	assert.Equal(t, "u2", breaches[0].URL)
+	assert.Equal(t, "Ticketmaster", breaches[1].Organization)
+	assert.Equal(t, int64(560_000_000), breaches[1].Records)
+	assert.True(t, day.Equal(breaches[1].ReportedAt))
+
+	breaches, err = GetBreaches(BreachFilter{MinRecords: 1_000_000})
+	require.NoError(t, err)
+	require.Len(t, breaches, 1)
+	assert.Equal(t, "Ticketmaster", breaches[0].Organization)
+
+	breaches, err = GetBreaches(BreachFilter{Organization: "acme", EndDate: day})
+	require.NoError(t, err)
+	assert.Empty(t, breaches)
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"news-api/models"
+)
+
+// CustomCategories are the categories defined in the categories file. Their
+// sources take precedence over the built-in source lists, and their keyword
+// rules replace the built-in rules of a category with the same name.
+var CustomCategories []models.Category
+
+// builtinCategories are the categories the built-in source lists map to.
+var builtinCategories = []string{"Cybersecurity", "Tech", "Defense"}
+
+// LoadCategories reads category definitions from a JSON file holding an array
+// of {"name": ..., "sources": [...], "keywords": {"keyword": weight}}.
+// Keywords are matched case-insensitively.
+func LoadCategories(path string) ([]models.Category, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read categories file: %v", err)
+	}
+	var categories []models.Category
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return nil, fmt.Errorf("failed to parse categories file: %v", err)
+	}
+
+	names := map[string]bool{}
+	sources := map[string]string{}
+	for i, c := range categories {
+		c.Name = strings.TrimSpace(c.Name)
+		if c.Name == "" {
+			return nil, fmt.Errorf("category %d has no name", i+1)
+		}
+		if names[strings.ToLower(c.Name)] {
+			return nil, fmt.Errorf("category %q is defined twice", c.Name)
+		}
+		names[strings.ToLower(c.Name)] = true
+
+		for _, source := range c.Sources {
+			if other, ok := sources[source]; ok {
+				return nil, fmt.Errorf("source %s belongs to both %q and %q", source, other, c.Name)
+			}
+			sources[source] = c.Name
+		}
+
+		keywords := make(map[string]int, len(c.Keywords))
+		for keyword, weight := range c.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				keywords[keyword] = weight
+			}
+		}
+		c.Keywords = keywords
+		categories[i] = c
+	}
+	return categories, nil
+}
+
+// CategorySources returns the sources of all custom categories.
+func CategorySources() []string {
+	var sources []string
+	for _, c := range CustomCategories {
+		sources = append(sources, c.Sources...)
+	}
+	return sources
+}
+
+// CategoryNames lists the built-in categories, with ExposureCategory when
+// paste sites are monitored and TelegramCategory when Telegram channels are,
+// followed by the custom ones.
+func CategoryNames() []string {
+	names := append([]string{}, builtinCategories...)
+	if len(PasteSources) > 0 {
+		names = append(names, ExposureCategory)
+	}
+	if len(telegramSources) > 0 {
+		names = append(names, TelegramCategory)
+	}
+	for _, c := range CustomCategories {
+		if !containsFold(names, c.Name) {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// customCategoryForSource returns the custom category a source belongs to.
+func customCategoryForSource(sourceURL string) (string, bool) {
+	for _, c := range CustomCategories {
+		for _, s := range c.Sources {
+			if s == sourceURL {
+				return c.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// customKeywords returns the keyword rules of a custom category, or nil if the
+// category is not custom or defines none.
+func customKeywords(category string) map[string]int {
+	for _, c := range CustomCategories {
+		if strings.EqualFold(c.Name, category) && len(c.Keywords) > 0 {
+			return c.Keywords
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func createArticleCategoryTables() error {
+	createArticleCategoriesSQL := `
+	CREATE TABLE IF NOT EXISTS article_categories (
+		article_id INTEGER NOT NULL,
+		category TEXT NOT NULL,
+		PRIMARY KEY (article_id, category)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_categories_category ON article_categories (category);
+	`
+	if _, err := db.Exec(createArticleCategoriesSQL); err != nil {
+		return fmt.Errorf("failed to create article category tables: %v", err)
+	}
+	return nil
+}
+
+// recordArticleCategories stores the secondary categories of an article, the
+// categories after its primary one in article.Categories.
+func recordArticleCategories(articleID int64, article models.NewsArticle) error {
+	for _, category := range article.Categories {
+		if strings.EqualFold(category, article.Category) {
+			continue
+		}
+		if _, err := db.Exec("INSERT OR IGNORE INTO article_categories(article_id, category) VALUES(?, ?)", articleID, category); err != nil {
+			return fmt.Errorf("failed to record category of article %d: %v", articleID, err)
+		}
+	}
+	return nil
+}
+
+// articleCategoriesColumn selects an article's primary category followed by
+// its secondary ones, joined by tagSeparator.
+func articleCategoriesColumn(alias string) string {
+	return "COALESCE(" + alias + ".category, '') || COALESCE(char(31) || (SELECT GROUP_CONCAT(ac.category, char(31)) FROM article_categories ac WHERE ac.article_id = " + alias + ".id), '')"
+}
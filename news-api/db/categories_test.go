@@ -0,0 +1,87 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCategoriesFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "categories.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadCategories(t *testing.T) {
+	categories, err := LoadCategories(writeCategoriesFile(t, `[
+		{"name": " Cloud ", "sources": ["https://cloud.example/feed"], "keywords": {"Misconfiguration": 5, "S3 bucket": 3, " ": 1}},
+		{"name": "OT/ICS", "sources": ["https://ics.example/rss"]}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	assert.Equal(t, "Cloud", categories[0].Name)
+	assert.Equal(t, map[string]int{"misconfiguration": 5, "s3 bucket": 3}, categories[0].Keywords)
+
+	_, err = LoadCategories(writeCategoriesFile(t, `[{"name": "Cloud"}, {"name": "cloud"}]`))
+	assert.ErrorContains(t, err, "defined twice")
+	_, err = LoadCategories(writeCategoriesFile(t, `[{"name": "A", "sources": ["s"]}, {"name": "B", "sources": ["s"]}]`))
+	assert.ErrorContains(t, err, "belongs to both")
+	_, err = LoadCategories(writeCategoriesFile(t, `[{"sources": ["s"]}]`))
+	assert.ErrorContains(t, err, "no name")
+	_, err = LoadCategories(writeCategoriesFile(t, `{`))
+	assert.Error(t, err)
+}
+
+func TestCustomCategories(t *testing.T) {
+	CustomCategories = []models.Category{
+		{Name: "Cloud", Sources: []string{"https://cloud.example/feed"}, Keywords: map[string]int{"misconfiguration": 5, "bucket": 3}},
+		{Name: "Tech", Sources: []string{"https://www.bleepingcomputer.com/feed/"}},
+		{Name: "Defense", Keywords: map[string]int{"drone": 7}},
+	}
+	defer func() { CustomCategories = nil }()
+
+	assert.Equal(t, "Cloud", getCategoryForSource("https://cloud.example/feed"))
+	assert.Equal(t, "Tech", getCategoryForSource("https://www.bleepingcomputer.com/feed/"), "custom sources take precedence")
+	assert.Equal(t, "Defense", getCategoryForSource("https://www.defenseone.com/rss/all/"))
+
+	assert.Equal(t, 8, calculateRank(models.NewsArticle{Title: "Bucket misconfiguration exposes data", Category: "Cloud"}))
+	assert.Equal(t, 1, calculateRank(models.NewsArticle{Title: "New gadget", Category: "Tech"}), "built-in rules apply when a category defines no keywords")
+	assert.Equal(t, 7, calculateRank(models.NewsArticle{Title: "Drone strike", Category: "Defense"}), "custom keywords replace the built-in rules")
+
+	assert.Equal(t, []string{"Cybersecurity", "Tech", "Defense", "Cloud"}, CategoryNames())
+	assert.Equal(t, []string{"https://cloud.example/feed", "https://www.bleepingcomputer.com/feed/"}, CategorySources())
+}
+
+func TestSecondaryCategories(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	now := time.Now()
+	item := &gofeed.Item{Title: "Ransomware attack on defense contractor exposes missile designs", Description: "The breach hit a supplier of the army and navy.", Link: "https://example.com/contractor", PublishedParsed: &now}
+	article := articleFromItem("https://www.bleepingcomputer.com/feed/", item)
+	assert.Equal(t, "Cybersecurity", article.Category)
+	assert.Equal(t, []string{"Cybersecurity", "Defense"}, article.Categories)
+	require.NoError(t, InsertArticle(article))
+	require.NoError(t, InsertArticle(articleFromItem("https://www.defenseone.com/rss/all/", &gofeed.Item{Title: "Navy orders new frigates", Link: "https://example.com/frigates", PublishedParsed: &now})))
+
+	defense, err := QueryArticles(ArticleFilter{Category: "Defense", SortBy: "idAsc"})
+	require.NoError(t, err)
+	require.Len(t, defense, 2)
+	assert.Equal(t, []string{"Cybersecurity", "Defense"}, defense[0].Categories)
+	assert.Equal(t, []string{"Defense"}, defense[1].Categories)
+	assert.True(t, ArticleFilter{Category: "Defense"}.Matches(defense[0]))
+	assert.False(t, ArticleFilter{Category: "Tech"}.Matches(defense[0]))
+
+	// The threat score only counts the primary category.
+	score, err := GetOrgThreatScore(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, score.Categories["Cybersecurity"].TotalArticles)
+	assert.Equal(t, 1, score.Categories["Defense"].TotalArticles)
+}
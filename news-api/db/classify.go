@@ -0,0 +1,85 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"news-api/models"
+)
+
+// InferCategories classifies the articles of sources that no category lists,
+// which would otherwise all be "General", by the keyword rules of each
+// category.
+var InferCategories = true
+
+// minInferenceScore is the keyword weight an article needs for a category to
+// be inferred: one high impact keyword, or a few weaker ones.
+const minInferenceScore = 5
+
+// inferCategory returns the category whose keyword rules an article matches
+// best, or false if none matches well enough or two match equally.
+func inferCategory(article models.NewsArticle) (string, bool) {
+	best, bestScore, tie := "", 0, false
+	for category, score := range categoryScores(article) {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = category, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if bestScore < minInferenceScore || tie {
+		return "", false
+	}
+	return best, true
+}
+
+// secondaryCategories returns the categories other than its own that an
+// article matches well enough to be inferred, best first, e.g. Defense for a
+// breach at a defense contractor.
+func secondaryCategories(article models.NewsArticle) []string {
+	scores := categoryScores(article)
+	var categories []string
+	for category, score := range scores {
+		if score >= minInferenceScore && !strings.EqualFold(category, article.Category) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if scores[categories[i]] != scores[categories[j]] {
+			return scores[categories[i]] > scores[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	return categories
+}
+
+// categoryScores weighs an article against the keyword rules of each
+// category. Unlike ranking, keywords must match whole words, so that e.g.
+// "ai" does not match "said".
+func categoryScores(article models.NewsArticle) map[string]int {
+	text := " " + keywordText(article.Title+" "+article.Description) + " "
+	scores := map[string]int{}
+	for _, category := range CategoryNames() {
+		if category == ExposureCategory || category == TelegramCategory {
+			// Pastes and Telegram posts are recognized by their source, not
+			// their prose.
+			continue
+		}
+		for keyword, weight := range categoryKeywords(category) {
+			if strings.Contains(text, " "+keywordText(keyword)+" ") {
+				scores[category] += weight
+			}
+		}
+	}
+	return scores
+}
+
+// keywordText lowercases s and replaces everything but letters and digits
+// with single spaces, so that keywords can be matched as whole words.
+func keywordText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
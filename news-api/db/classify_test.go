@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+
+	"news-api/models"
+)
+
+func TestInferCategory(t *testing.T) {
+	tests := []struct {
+		title, description string
+		want               string
+	}{
+		{"Zero-day exploited to deploy ransomware", "Attackers used the flaw against hospitals.", "Cybersecurity"},
+		{"Startup raises funding for quantum computing chips", "", "Tech"},
+		{"Missile strike hits port as troops advance", "", "Defense"},
+		// "ai" is not a word of "said", nor "app" of "happy".
+		{"The minister said she was happy with the talks", "", "General"},
+		// A single weak keyword is not enough.
+		{"Quarterly report on data usage", "", "General"},
+	}
+	for _, tt := range tests {
+		category, ok := inferCategory(models.NewsArticle{Title: tt.title, Description: tt.description})
+		if !ok {
+			category = "General"
+		}
+		assert.Equal(t, tt.want, category, tt.title)
+	}
+
+	now := time.Now()
+	item := &gofeed.Item{Title: "Critical vulnerability exploited in VPN appliances", Link: "https://example.com/vpn", PublishedParsed: &now}
+	assert.Equal(t, "Cybersecurity", articleFromItem("https://blog.example.com/feed", item).Category)
+	assert.Equal(t, "Defense", articleFromItem("https://www.defenseone.com/rss/all/", item).Category, "listed sources keep their category")
+
+	defer func(infer bool) { InferCategories = infer }(InferCategories)
+	InferCategories = false
+	assert.Equal(t, "General", articleFromItem("https://blog.example.com/feed", item).Category)
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// maxSeenItems bounds the item keys kept per source and organization. Feeds
+// list far fewer items, so an item only drops out of the set once it has
+// left the feed.
+const maxSeenItems = 1000
+
+func createSourceCursorTables() error {
+	createSeenItemsSQL := `
+	DROP TABLE IF EXISTS source_cursors;
+	CREATE TABLE IF NOT EXISTS source_seen_items (
+		source_url TEXT NOT NULL,
+		org_id INTEGER NOT NULL,
+		item_key TEXT NOT NULL,
+		seen_at DATETIME NOT NULL,
+		PRIMARY KEY (source_url, org_id, item_key)
+	);
+	`
+	if _, err := db.Exec(createSeenItemsSQL); err != nil {
+		return fmt.Errorf("failed to create source seen item tables: %v", err)
+	}
+	return nil
+}
+
+// seenItems returns the keys of the items of a source that every
+// organization fetching it has processed in an earlier cycle. Items are
+// skipped by key rather than by date, so backdated and late items are still
+// processed.
+func seenItems(source string, feeds []orgFeed) (map[string]bool, error) {
+	var seen map[string]bool
+	for _, feed := range feeds {
+		rows, err := db.Query("SELECT item_key FROM source_seen_items WHERE source_url = ? AND org_id = ?", source, feed.orgID)
+		if err != nil {
+			return nil, err
+		}
+		orgSeen := map[string]bool{}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if seen == nil || seen[key] {
+				orgSeen[key] = true
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		seen = orgSeen
+	}
+	return seen, nil
+}
+
+// saveSeenItems records the keys of the items of a source processed by each
+// organization fetching it, keeping the maxSeenItems most recently seen.
+func saveSeenItems(source string, feeds []orgFeed, keys []string, now time.Time) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, feed := range feeds {
+		for _, key := range keys {
+			_, err := tx.Exec(`INSERT INTO source_seen_items(source_url, org_id, item_key, seen_at) VALUES(?, ?, ?, ?)
+				ON CONFLICT(source_url, org_id, item_key) DO UPDATE SET seen_at = excluded.seen_at`,
+				source, feed.orgID, key, now)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`DELETE FROM source_seen_items WHERE source_url = ? AND org_id = ? AND item_key NOT IN (
+				SELECT item_key FROM source_seen_items WHERE source_url = ? AND org_id = ? ORDER BY seen_at DESC LIMIT ?)`,
+			source, feed.orgID, source, feed.orgID, maxSeenItems)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeenItems(t *testing.T) {
+	setupTestDB(t)
+	_, err := db.Exec("DELETE FROM source_seen_items")
+	require.NoError(t, err)
+	source := "https://news.example/feed"
+	feeds := []orgFeed{{orgID: 1}, {orgID: 2}}
+
+	seen, err := seenItems(source, feeds)
+	require.NoError(t, err)
+	assert.Empty(t, seen)
+
+	now := time.Now().UTC()
+	require.NoError(t, saveSeenItems(source, feeds[:1], []string{"a", "b"}, now))
+	seen, err = seenItems(source, feeds)
+	require.NoError(t, err)
+	assert.Empty(t, seen, "items are only skipped once every organization has seen them")
+
+	require.NoError(t, saveSeenItems(source, feeds, []string{"b", "c"}, now))
+	seen, err = seenItems(source, feeds)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"b": true, "c": true}, seen)
+
+	// Only the most recently seen items are kept.
+	keys := make([]string, maxSeenItems)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("item-%d", i)
+	}
+	require.NoError(t, saveSeenItems(source, feeds, keys, now.Add(time.Minute)))
+	seen, err = seenItems(source, feeds)
+	require.NoError(t, err)
+	assert.Len(t, seen, maxSeenItems)
+	assert.False(t, seen["b"])
+}
+
+func TestFetchSkipsProcessedItems(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM source_seen_items")
+	require.NoError(t, err)
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	items := []string{
+		itemXML("old", "Hackers breach a water utility network", "Mon, 29 Apr 2024 10:00:00 GMT"),
+		itemXML("new", "Ransomware gang leaks hospital patient records", "Wed, 01 May 2024 10:00:00 GMT"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>`)
+		for _, item := range items {
+			fmt.Fprint(w, item)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	}))
+	defer server.Close()
+	source := server.URL + "/feed"
+
+	fetchAndCacheNews([]string{source})
+	count, err := GetArticleCount()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// Items seen before are not processed again, so a deleted article does
+	// not come back, while new items are stored even when they are backdated.
+	_, err = db.Exec("DELETE FROM articles WHERE guid = 'old'")
+	require.NoError(t, err)
+	items = append(items,
+		itemXML("newer", "Zero-day exploited in VPN appliances", "Thu, 02 May 2024 10:00:00 GMT"),
+		itemXML("late", "Botnet hijacks routers at an internet provider", "Sun, 28 Apr 2024 10:00:00 GMT"))
+	fetchAndCacheNews([]string{source})
+
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	var guids []string
+	for _, a := range articles {
+		guids = append(guids, a.GUID)
+	}
+	assert.ElementsMatch(t, []string{"new", "newer", "late"}, guids)
+}
+
+func itemXML(guid, title, published string) string {
+	return fmt.Sprintf(`<item><guid>%s</guid><title>%s</title><link>https://news.example/%s</link><description>%s, according to a report published today by researchers.</description><pubDate>%s</pubDate></item>`,
+		guid, title, guid, title, published)
+}
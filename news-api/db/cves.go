@@ -0,0 +1,122 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+func createCVETables() error {
+	var existing int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'article_cves'").Scan(&existing); err != nil {
+		return fmt.Errorf("failed to inspect schema: %v", err)
+	}
+	createCVEsSQL := `
+	CREATE TABLE IF NOT EXISTS article_cves (
+		article_id INTEGER NOT NULL,
+		cve TEXT NOT NULL,
+		PRIMARY KEY (article_id, cve)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_cves_cve ON article_cves (cve);
+	`
+	if _, err := db.Exec(createCVEsSQL); err != nil {
+		return fmt.Errorf("failed to create article_cves table: %v", err)
+	}
+	if existing == 0 {
+		return backfillArticles("CVE mentions", recordArticleCVEs)
+	}
+	return nil
+}
+
+// backfillArticles runs record over the articles stored before it was
+// called on new articles, when its table is created.
+func backfillArticles(what string, record func(int64, models.NewsArticle) error) error {
+	rows, err := db.Query("SELECT id, title, COALESCE(description, ''), content FROM articles")
+	if err != nil {
+		return fmt.Errorf("failed to read articles for %s: %v", what, err)
+	}
+	var articles []models.NewsArticle
+	for rows.Next() {
+		var a models.NewsArticle
+		if err := rows.Scan(&a.ID, &a.Title, &a.Description, &a.Content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read articles for %s: %v", what, err)
+		}
+		articles = append(articles, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, a := range articles {
+		if err := record(a.ID, a); err != nil {
+			return fmt.Errorf("failed to record %s: %v", what, err)
+		}
+	}
+	return nil
+}
+
+// recordArticleCVEs stores the CVE identifiers an article mentions in its
+// title, description or full text.
+func recordArticleCVEs(articleID int64, article models.NewsArticle) error {
+	for _, cve := range extract.CVEs(article.Title + " " + article.Description + " " + article.Content) {
+		if _, err := db.Exec("INSERT OR IGNORE INTO article_cves(article_id, cve) VALUES(?, ?)", articleID, cve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTopCVEs returns the CVEs mentioned by the most of an organization's
+// articles published since the given time, with when they were first and last
+// mentioned by any of its articles.
+func GetTopCVEs(orgID int64, since time.Time, limit int) ([]models.CVEMention, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT w.cve, w.mentions,
+			(SELECT MIN(a.publishedAt) FROM article_cves c JOIN articles a ON a.id = c.article_id WHERE c.cve = w.cve AND a.org_id = ? AND a.deleted_at IS NULL),
+			(SELECT MAX(a.publishedAt) FROM article_cves c JOIN articles a ON a.id = c.article_id WHERE c.cve = w.cve AND a.org_id = ? AND a.deleted_at IS NULL)
+		FROM (SELECT c.cve, COUNT(*) AS mentions, MAX(a.publishedAt) AS latest
+			FROM article_cves c JOIN articles a ON a.id = c.article_id
+			WHERE a.org_id = ? AND a.deleted_at IS NULL AND a.publishedAt >= ?
+			GROUP BY c.cve) w
+		ORDER BY w.mentions DESC, w.latest DESC, w.cve
+		LIMIT ?`, orgID, orgID, orgID, utcTimestamp(since), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []models.CVEMention{}
+	for rows.Next() {
+		var m models.CVEMention
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&m.CVE, &m.Mentions, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		if m.FirstSeen, err = parseStoredTime(firstSeen); err != nil {
+			return nil, err
+		}
+		if m.LastSeen, err = parseStoredTime(lastSeen); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
+
+// parseStoredTime parses a timestamp returned by an SQL aggregate, which the
+// driver does not convert to a time.Time.
+func parseStoredTime(value string) (time.Time, error) {
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
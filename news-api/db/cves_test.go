@@ -0,0 +1,42 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTopCVEs(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.Add(-30 * 24 * time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "CVE-2024-1111 disclosed", URL: "u1", PublishedAt: old},
+		{Title: "Exploit for CVE-2024-1111", URL: "u2", PublishedAt: now.Add(-2 * time.Hour)},
+		{Title: "Patch out", Description: "Fixes cve-2024-1111 and CVE-2024-2222", URL: "u3", PublishedAt: now.Add(-time.Hour)},
+		{Title: "CVE-2024-3333 from long ago", URL: "u4", PublishedAt: old},
+		{Title: "Unrelated story", URL: "u5", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	top, err := GetTopCVEs(0, now.Add(-7*24*time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+
+	assert.Equal(t, "CVE-2024-1111", top[0].CVE)
+	assert.Equal(t, 2, top[0].Mentions)
+	assert.True(t, old.Equal(top[0].FirstSeen), "first seen %v", top[0].FirstSeen)
+	assert.True(t, now.Add(-time.Hour).Equal(top[0].LastSeen), "last seen %v", top[0].LastSeen)
+
+	assert.Equal(t, "CVE-2024-2222", top[1].CVE)
+	assert.Equal(t, 1, top[1].Mentions)
+
+	top, err = GetTopCVEs(0, now.Add(-7*24*time.Hour), 1)
+	require.NoError(t, err)
+	assert.Len(t, top, 1)
+}
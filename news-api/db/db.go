@@ -1,29 +1,27 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"log"
-	"net"
-	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"news-api/fetcher"
 	"news-api/models"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/microcosm-cc/bluemonday"
 	"github.com/mmcdole/gofeed"
-	"github.com/pemistahl/lingua-go"
 )
 
 var db *sql.DB
-var detector lingua.LanguageDetector
 
 // dbMutex protects database write operations to prevent race conditions
 // during CSV import and RSS caching jobs.
@@ -42,11 +40,28 @@ func InitDB(dataSourceName string) error {
 		title TEXT NOT NULL,
 		description TEXT,
 		imageUrl TEXT,
-		url TEXT NOT NULL UNIQUE,
+		url TEXT NOT NULL,
 		sourceUrl TEXT NOT NULL,
 		publishedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
 		rank INTEGER DEFAULT 0,
-		category TEXT DEFAULT ''
+		category TEXT DEFAULT '',
+		org_id INTEGER NOT NULL DEFAULT 0,
+		author TEXT NOT NULL DEFAULT '',
+		guid TEXT NOT NULL DEFAULT '',
+		severity INTEGER NOT NULL DEFAULT 0,
+		link_status INTEGER NOT NULL DEFAULT 0,
+		link_checked_at DATETIME,
+		description_html TEXT NOT NULL DEFAULT '',
+		content TEXT NOT NULL DEFAULT '',
+		ingested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		url_hash TEXT NOT NULL DEFAULT '',
+		media_type TEXT NOT NULL DEFAULT '',
+		duration INTEGER NOT NULL DEFAULT 0,
+		audio_url TEXT NOT NULL DEFAULT '',
+		paywalled INTEGER NOT NULL DEFAULT 0,
+		archive_url TEXT NOT NULL DEFAULT '',
+		archive_checked_at DATETIME,
+		deleted_at DATETIME
 	);
 	`
 	_, err = db.Exec(createTableSQL)
@@ -54,33 +69,214 @@ func InitDB(dataSourceName string) error {
 		return fmt.Errorf("failed to create articles table: %v", err)
 	}
 
+	if err := migrateArticlesTable(); err != nil {
+		return err
+	}
+	if err := migratePublishedAtToUTC(); err != nil {
+		return err
+	}
+	if err := migrateArticleColumns(); err != nil {
+		return err
+	}
+	if err := migrateURLHashes(); err != nil {
+		return err
+	}
+	if err := backfillSeverity(); err != nil {
+		return err
+	}
+
 	// Create indexes for faster queries
 	createIndexesSQL := `
 	CREATE INDEX IF NOT EXISTS idx_sourceUrl ON articles (sourceUrl);
 	CREATE INDEX IF NOT EXISTS idx_publishedAt ON articles (publishedAt);
+	CREATE INDEX IF NOT EXISTS idx_org_severity ON articles (org_id, severity);
+	CREATE INDEX IF NOT EXISTS idx_link_checked_at ON articles (link_checked_at);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_org_url ON articles (org_id, url);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_org_source_guid ON articles (org_id, sourceUrl, guid) WHERE guid != '';
+	CREATE INDEX IF NOT EXISTS idx_org_publishedAt ON articles (org_id, publishedAt);
+	CREATE INDEX IF NOT EXISTS idx_org_ingested_at ON articles (org_id, ingested_at);
+	CREATE INDEX IF NOT EXISTS idx_imageUrl ON articles (imageUrl);
+	CREATE INDEX IF NOT EXISTS idx_deleted_at ON articles (deleted_at) WHERE deleted_at IS NOT NULL;
 	`
 	_, err = db.Exec(createIndexesSQL)
 	if err != nil {
 		return fmt.Errorf("failed to create indexes: %v", err)
 	}
 
-	// Optimize language detector to only load models for relevant languages
-	detector = lingua.NewLanguageDetectorBuilder().
-		FromLanguages(lingua.English, lingua.German, lingua.French, lingua.Spanish, lingua.Russian, lingua.Chinese).
-		WithPreloadedLanguageModels().
-		Build()
+	if err := createOrgTables(); err != nil {
+		return err
+	}
+
+	if err := createIntegrationTables(); err != nil {
+		return err
+	}
+
+	if err := createUserTables(); err != nil {
+		return err
+	}
+
+	if err := createSavedSearchTables(); err != nil {
+		return err
+	}
+
+	if err := createAuditTables(); err != nil {
+		return err
+	}
+
+	if err := createSourceTables(); err != nil {
+		return err
+	}
+
+	if err := createTagTables(); err != nil {
+		return err
+	}
+
+	if err := migrateRansomwareTags(); err != nil {
+		return err
+	}
+
+	if err := createCVETables(); err != nil {
+		return err
+	}
+
+	if err := createBreachTables(); err != nil {
+		return err
+	}
+
+	if err := createProfileTables(); err != nil {
+		return err
+	}
+
+	if err := createFeedbackTables(); err != nil {
+		return err
+	}
+
+	if err := createEngagementTables(); err != nil {
+		return err
+	}
+
+	if err := createPocketTables(); err != nil {
+		return err
+	}
+
+	if err := createKEVTables(); err != nil {
+		return err
+	}
+
+	if err := createReportTables(); err != nil {
+		return err
+	}
+
+	if err := createMessageTemplateTables(); err != nil {
+		return err
+	}
+
+	if err := createDeliveryTables(); err != nil {
+		return err
+	}
+
+	if err := createRawItemTables(); err != nil {
+		return err
+	}
+
+	if err := createThreatOverrideTables(); err != nil {
+		return err
+	}
+
+	if err := createThreatSnapshotTables(); err != nil {
+		return err
+	}
+
+	if err := createSourceCursorTables(); err != nil {
+		return err
+	}
+
+	if err := createArticleBodyTables(); err != nil {
+		return err
+	}
+
+	if err := createLeaseTables(); err != nil {
+		return err
+	}
+
+	if err := createHealthTables(); err != nil {
+		return err
+	}
+
+	if err := createNewsletterTables(); err != nil {
+		return err
+	}
+	if err := createTelegramTables(); err != nil {
+		return err
+	}
+
+	if err := createArticleCategoryTables(); err != nil {
+		return err
+	}
+
+	if err := createEnrichmentTables(); err != nil {
+		return err
+	}
+
+	if err := createJobTables(); err != nil {
+		return err
+	}
+
+	if err := createFixtureTables(); err != nil {
+		return err
+	}
+
+	if err := createWaybackSaveTables(); err != nil {
+		return err
+	}
+
+	if err := createMaintenanceTables(); err != nil {
+		return err
+	}
 
 	log.Println("Database initialized successfully.")
 	return nil
 }
 
+// InitReadOnlyDB opens an existing database, e.g. a restored snapshot, so that
+// it is never written to. The schema is neither created nor migrated, so the
+// database must come from the same version of the service.
+func InitReadOnlyDB(path string) error {
+	var err error
+	db, err = sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	var id int64
+	if err := db.QueryRow("SELECT id FROM articles LIMIT 1").Scan(&id); err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read database %s: %v", path, err)
+	}
+	log.Println("Database opened read-only.")
+	return nil
+}
+
 func calculateRank(article models.NewsArticle) int {
 	rank := 0
 	content := strings.ToLower(article.Title + " " + article.Description)
 
+	for keyword, score := range categoryKeywords(article.Category) {
+		if strings.Contains(content, keyword) {
+			rank += score
+		}
+	}
+
+	return rank
+}
+
+// categoryKeywords returns the keyword weights used to rank articles of a category.
+func categoryKeywords(category string) map[string]int {
+	if custom := customKeywords(category); custom != nil {
+		return custom
+	}
+
 	var keywords map[string]int
 
-	switch article.Category {
+	switch category {
 	case "Cybersecurity":
 		keywords = map[string]int{
 			// High Impact (Score 5): Direct, immediate threats
@@ -99,156 +295,550 @@ func calculateRank(article models.NewsArticle) int {
 			// Low Impact (Score 1): General tech news, reviews, minor updates
 			"review": 1, "gadget": 1, "app": 1, "software": 1, "hardware": 1, "update": 1, "guide": 1, "tips": 1,
 		}
+	case "Defense":
+		keywords = map[string]int{
+			// High Impact (Score 5): Attacks, escalation and mobilization
+			"missile strike": 5, "airstrike": 5, "drone strike": 5, "invasion": 5, "escalation": 5, "mobilization": 5, "nuclear threat": 5, "declaration of war": 5,
+			// Medium Impact (Score 3): Active conflict, sanctions and deployments
+			"strike": 3, "missile": 3, "conflict": 3, "sanctions": 3, "troops": 3, "offensive": 3, "deployment": 3, "casualties": 3, "ceasefire": 3, "hypersonic": 3,
+			// Low Impact (Score 1): General defense news, procurement and policy
+			"defense": 1, "defence": 1, "military": 1, "army": 1, "navy": 1, "air force": 1, "pentagon": 1, "nato": 1, "procurement": 1, "contract": 1,
+		}
+	case ExposureCategory:
+		keywords = map[string]int{
+			// High Impact (Score 5): Secrets and bulk personal data
+			"private key": 5, "api_key": 5, "api key": 5, "aws_secret_access_key": 5, "password:": 5, "combolist": 5, "credit card": 5, "cvv": 5, "ssn": 5,
+			// Medium Impact (Score 3): Dumps and leaks
+			"dump": 3, "leak": 3, "leaked": 3, "database": 3, "credentials": 3, "password": 3, "passwd": 3, "token": 3, "breach": 3, "[redacted]": 3,
+			// Low Impact (Score 1): Indicators that a paste is about an organization
+			"email": 1, "login": 1, "username": 1, "config": 1, "internal": 1, "vpn": 1,
+		}
+	case TelegramCategory:
+		keywords = map[string]int{
+			// High Impact (Score 5): Claimed breaches and leaks
+			"leaked": 5, "data leak": 5, "database dump": 5, "we hacked": 5, "hacked by": 5, "ransomware": 5, "for sale": 5, "ddos": 5, "stealer logs": 5,
+			// Medium Impact (Score 3): Targets, access and tooling
+			"leak": 3, "dump": 3, "breach": 3, "hacked": 3, "exploit": 3, "access": 3, "credentials": 3, "database": 3, "attack": 3, "target": 3,
+			// Low Impact (Score 1): Channel chatter that may concern a target
+			"data": 1, "files": 1, "download": 1, "proof": 1, "government": 1, "bank": 1,
+		}
 	default: // General or unknown category
 		keywords = map[string]int{
 			"news": 1, "update": 1, "report": 1,
 		}
 	}
-
-	for keyword, score := range keywords {
-		if strings.Contains(content, keyword) {
-			rank += score
-		}
-	}
-
-	return rank
+	return keywords
 }
 
 func InsertArticle(article models.NewsArticle) error {
-	stmt, err := db.Prepare("INSERT OR IGNORE INTO articles(title, description, imageUrl, url, sourceUrl, publishedAt, rank, category) VALUES(?, ?, ?, ?, ?, ?, ?, ?)")
+	_, err := insertArticle(article)
+	return err
+}
+
+// insertArticle stores an article and reports whether it was new. Articles whose
+// URL is already stored, possibly as a variant such as http instead of https
+// (see normalizeArticleURL), are ignored, as are articles whose source already
+// published an item with the same GUID under a different URL.
+func insertArticle(article models.NewsArticle) (bool, error) {
+	if article.Severity == 0 {
+		article.Severity = Severity(article.Category, article.Rank)
+	}
+	stmt, err := db.Prepare("INSERT OR IGNORE INTO articles(title, description, description_html, content, imageUrl, url, url_hash, sourceUrl, publishedAt, ingested_at, rank, severity, category, org_id, author, guid, media_type, duration, audio_url, paywalled) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		log.Printf("Error preparing insert statement for article %s: %v", article.Title, err)
-		return err
+		return false, err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(article.Title, article.Description, article.ImageURL, article.URL, article.SourceURL, article.PublishedAt, article.Rank, article.Category)
+	res, err := stmt.Exec(article.Title, article.Description, article.DescriptionHTML, article.Content, article.ImageURL, article.URL, urlHash(article.URL), article.SourceURL, article.PublishedAt.UTC(), time.Now().UTC(), article.Rank, article.Severity, article.Category, article.OrgID, article.Author, article.GUID, article.MediaType, article.Duration, article.AudioURL, article.Paywalled)
 	if err != nil {
 		log.Printf("Error inserting article %s: %v", article.Title, err)
+		return false, err
 	}
-	return err
+	affected, err := res.RowsAffected()
+	if err != nil || affected == 0 {
+		return false, err
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		if err := autoTagArticle(id, article); err != nil {
+			log.Printf("Error tagging article %s: %v", article.Title, err)
+		}
+		if err := recordArticleCVEs(id, article); err != nil {
+			log.Printf("Error recording CVE mentions of article %s: %v", article.Title, err)
+		}
+		if err := recordArticleBreach(id, article); err != nil {
+			log.Printf("Error recording breach of article %s: %v", article.Title, err)
+		}
+		if err := recordArticleCategories(id, article); err != nil {
+			log.Printf("Error recording categories of article %s: %v", article.Title, err)
+		}
+		if err := recordEnrichment(id, false); err != nil {
+			log.Printf("Error recording enrichment of article %s: %v", article.Title, err)
+		}
+		if article.MediaType == MediaTypePodcast {
+			if err := storeShowNotes(article); err != nil {
+				log.Printf("Error indexing show notes of episode %s: %v", article.Title, err)
+			}
+		}
+	}
+	return true, nil
 }
 
 // ThreatScore represents the calculated threat score and its corresponding phrase.
+// Articles are counted as low, medium or high by their severity.
 type ThreatScore struct {
 	LowRankCount    int    `json:"lowRankCount"`
 	MediumRankCount int    `json:"mediumRankCount"`
 	HighRankCount   int    `json:"highRankCount"`
 	TotalArticles   int    `json:"totalArticles"`
 	ThreatLevel     string `json:"threatLevel"`
+	// ComputedLevel and Override are set when an administrator pinned the
+	// threat level; ThreatLevel is then the pinned level.
+	ComputedLevel string                 `json:"computedLevel,omitempty"`
+	Override      *models.ThreatOverride `json:"override,omitempty"`
+	// Categories breaks the counts down by article category.
+	Categories map[string]CategoryThreat `json:"categories,omitempty"`
+	// TopArticles are the most severe articles of each bucket.
+	TopArticles *ThreatTopArticles `json:"topArticles,omitempty"`
+	// Trend compares the score with the previous 24 hours.
+	Trend *ThreatTrend `json:"trend,omitempty"`
+}
+
+// CategoryThreat is the threat score of one category's articles.
+type CategoryThreat struct {
+	LowRankCount    int    `json:"lowRankCount"`
+	MediumRankCount int    `json:"mediumRankCount"`
+	HighRankCount   int    `json:"highRankCount"`
+	TotalArticles   int    `json:"totalArticles"`
+	ThreatLevel     string `json:"threatLevel"`
 }
 
+// ThreatArticle is an article counted by a threat score.
+type ThreatArticle struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Severity int    `json:"severity"`
+}
+
+// ThreatTopArticles lists the most severe articles of each threat score
+// bucket, up to topThreatArticles each.
+type ThreatTopArticles struct {
+	High   []ThreatArticle `json:"high"`
+	Medium []ThreatArticle `json:"medium"`
+	Low    []ThreatArticle `json:"low"`
+}
+
+// topThreatArticles is the number of articles listed per bucket.
+const topThreatArticles = 3
+
 // GetTodayThreatScore calculates the threat score based on articles published in the last 24 hours.
 func GetTodayThreatScore() (ThreatScore, error) {
-	var lowRankCount, mediumRankCount, highRankCount int
-	var totalArticles int
+	return GetOrgThreatScore(0)
+}
 
+// GetOrgThreatScore calculates the threat score for an organization's articles
+// ingested in the last 24 hours. Articles published before then are old posts
+// a feed backfilled and do not count. Organization 0 is the shared feed.
+func GetOrgThreatScore(orgID int64) (ThreatScore, error) {
 	// Calculate the time 24 hours ago from the current time.
 	twentyFourHoursAgo := time.Now().Add(-24 * time.Hour)
 
-	rows, err := db.Query("SELECT rank FROM articles WHERE publishedAt >= ?", twentyFourHoursAgo.Format("2006-01-02 15:04:05"))
+	tally, err := tallyThreat(orgID, twentyFourHoursAgo, time.Time{})
 	if err != nil {
 		return ThreatScore{}, err
 	}
+	score := tally.score()
+	if err := addThreatTrend(orgID, &score, twentyFourHoursAgo); err != nil {
+		return ThreatScore{}, err
+	}
+	return score, applyThreatOverride(orgID, &score)
+}
+
+// tallyThreat counts the articles of an organization ingested and published
+// since from, and ingested before until unless it is zero.
+func tallyThreat(orgID int64, from, until time.Time) (threatTally, error) {
+	var tally threatTally
+	query := "SELECT severity, category, title, url FROM articles WHERE org_id = ? AND " + notDeletedCondition + " AND ingested_at >= ? AND publishedAt >= ?"
+	args := []interface{}{orgID, utcTimestamp(from), utcTimestamp(from)}
+	if !until.IsZero() {
+		query += " AND ingested_at < ?"
+		args = append(args, utcTimestamp(until))
+	}
+	rows, err := db.Query(query+" ORDER BY severity DESC, publishedAt DESC", args...)
+	if err != nil {
+		return tally, err
+	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var rank int
-		if err := rows.Scan(&rank); err != nil {
-			log.Printf("Error scanning rank for threat score: %v", err)
+		var article ThreatArticle
+		if err := rows.Scan(&article.Severity, &article.Category, &article.Title, &article.URL); err != nil {
+			log.Printf("Error scanning severity for threat score: %v", err)
 			continue
 		}
-		totalArticles++
-		// Define rank ranges for low, medium, high
-		if rank < 2 { // Ranks 0-1 are considered low
-			lowRankCount++
-		} else if rank < 5 { // Ranks 2-4 are medium
-			mediumRankCount++
-		} else { // Ranks 5+ are high
-			highRankCount++
+		tally.add(article)
+	}
+	return tally, rows.Err()
+}
+
+// ThreatTrend compares a threat score with that of the 24 hours before.
+type ThreatTrend struct {
+	PreviousLevel        string `json:"previousLevel"`
+	PreviousTotal        int    `json:"previousTotalArticles"`
+	TotalArticlesDelta   int    `json:"totalArticlesDelta"`
+	HighRankCountDelta   int    `json:"highRankCountDelta"`
+	MediumRankCountDelta int    `json:"mediumRankCountDelta"`
+	LowRankCountDelta    int    `json:"lowRankCountDelta"`
+	// Direction is "worse" or "better" when the level changed, and "same"
+	// otherwise.
+	Direction string `json:"direction"`
+}
+
+// threatLevelSeverity orders threat levels from least to most severe.
+var threatLevelSeverity = map[string]int{"No Threats Reported": 0, "Business as Usual": 1, "Attention": 2, "Code Red": 3}
+
+// addThreatTrend compares a score computed from the articles since windowStart
+// with the 24 hours before. Both levels are the ones the articles give,
+// ignoring overrides.
+func addThreatTrend(orgID int64, score *ThreatScore, windowStart time.Time) error {
+	previous, err := tallyThreat(orgID, windowStart.Add(-24*time.Hour), windowStart)
+	if err != nil {
+		return err
+	}
+	prev := newThreatScore(previous.counts[bucketLow], previous.counts[bucketMedium], previous.counts[bucketHigh])
+	trend := &ThreatTrend{
+		PreviousLevel:        prev.ThreatLevel,
+		PreviousTotal:        prev.TotalArticles,
+		TotalArticlesDelta:   score.TotalArticles - prev.TotalArticles,
+		HighRankCountDelta:   score.HighRankCount - prev.HighRankCount,
+		MediumRankCountDelta: score.MediumRankCount - prev.MediumRankCount,
+		LowRankCountDelta:    score.LowRankCount - prev.LowRankCount,
+		Direction:            "same",
+	}
+	switch current, before := threatLevelSeverity[score.ThreatLevel], threatLevelSeverity[prev.ThreatLevel]; {
+	case current > before:
+		trend.Direction = "worse"
+	case current < before:
+		trend.Direction = "better"
+	}
+	score.Trend = trend
+	return nil
+}
+
+// threatTally accumulates the articles counted by a threat score. Articles
+// must be added most severe first.
+type threatTally struct {
+	counts     [3]int
+	categories map[string]*[3]int
+	top        [3][]ThreatArticle
+}
+
+// add counts an article and returns its bucket.
+func (t *threatTally) add(article ThreatArticle) int {
+	bucket := threatBucket(article.Severity)
+	t.counts[bucket]++
+	if t.categories == nil {
+		t.categories = map[string]*[3]int{}
+	}
+	if t.categories[article.Category] == nil {
+		t.categories[article.Category] = &[3]int{}
+	}
+	t.categories[article.Category][bucket]++
+	if len(t.top[bucket]) < topThreatArticles {
+		t.top[bucket] = append(t.top[bucket], article)
+	}
+	return bucket
+}
+
+// score returns the threat score of the articles added.
+func (t *threatTally) score() ThreatScore {
+	score := newThreatScore(t.counts[bucketLow], t.counts[bucketMedium], t.counts[bucketHigh])
+	if score.TotalArticles == 0 {
+		return score
+	}
+	score.Categories = make(map[string]CategoryThreat, len(t.categories))
+	for category, c := range t.categories {
+		s := newThreatScore(c[bucketLow], c[bucketMedium], c[bucketHigh])
+		score.Categories[category] = CategoryThreat{
+			LowRankCount:    s.LowRankCount,
+			MediumRankCount: s.MediumRankCount,
+			HighRankCount:   s.HighRankCount,
+			TotalArticles:   s.TotalArticles,
+			ThreatLevel:     s.ThreatLevel,
 		}
 	}
+	score.TopArticles = &ThreatTopArticles{High: t.top[bucketHigh], Medium: t.top[bucketMedium], Low: t.top[bucketLow]}
+	return score
+}
+
+// Threat score buckets, in the order of threatBucket's results.
+const (
+	bucketLow = iota
+	bucketMedium
+	bucketHigh
+)
+
+// threatBucket classifies an article's severity as low, medium or high.
+func threatBucket(severity int) int {
+	if severity < SeverityMedium {
+		return bucketLow
+	} else if severity < SeverityHigh {
+		return bucketMedium
+	}
+	return bucketHigh
+}
 
+// newThreatScore derives the threat level from the bucket counts.
+func newThreatScore(low, medium, high int) ThreatScore {
+	total := low + medium + high
 	var threatLevel string
-	if totalArticles == 0 {
+	if total == 0 {
 		threatLevel = "No Threats Reported"
-	} else if highRankCount > 0 {
+	} else if high > 0 {
 		threatLevel = "Code Red"
-	} else if mediumRankCount > 0 {
+	} else if medium > 0 {
 		threatLevel = "Attention"
 	} else {
 		threatLevel = "Business as Usual"
 	}
 
 	return ThreatScore{
-		LowRankCount:    lowRankCount,
-		MediumRankCount: mediumRankCount,
-		HighRankCount:   highRankCount,
-		TotalArticles:   totalArticles,
+		LowRankCount:    low,
+		MediumRankCount: medium,
+		HighRankCount:   high,
+		TotalArticles:   total,
 		ThreatLevel:     threatLevel,
-	}, nil
+	}
 }
 
 func GetArticlesFromDB(sourceFilter string, categoryFilter string, searchFilter string, limit int, startDate, endDate time.Time, sortBy string) ([]models.NewsArticle, error) {
-	if db == nil {
-		return nil, fmt.Errorf("database connection is nil")
-	}
-	var articles []models.NewsArticle
-	query := "SELECT title, description, imageUrl, url, sourceUrl, publishedAt, rank, category FROM articles"
-	args := []interface{}{}
+	return QueryArticles(ArticleFilter{
+		Source:    sourceFilter,
+		Category:  categoryFilter,
+		Search:    searchFilter,
+		Limit:     limit,
+		StartDate: startDate,
+		EndDate:   endDate,
+		SortBy:    sortBy,
+	})
+}
+
+// ArticleFilter describes which articles to return and in what order. Zero
+// values mean "no restriction"; "all" is accepted for Source and Category.
+// Results are always limited to a single organization, 0 being the shared feed,
+// and never include articles in the trash.
+type ArticleFilter struct {
+	OrgID    int64
+	Source   string
+	Category string
+	Search   string
+	Author   string
+	// Tag restricts results to articles with the given tag. Matches does not check it.
+	Tag string
+	// Sector restricts results to articles tagged with the given sector.
+	Sector string
+	// MediaType restricts results to articles of the given media type, "text"
+	// selecting those without one.
+	MediaType string
+	// Paywalled, when set, restricts results to paywalled articles or to
+	// articles free to read.
+	Paywalled *bool
+	MinRank   int
+	// MinSeverity restricts results to articles with at least this 0-100 severity.
+	MinSeverity int
+	// IncludeDead includes articles whose link was found to be dead.
+	IncludeDead bool
+	StartDate   time.Time
+	EndDate     time.Time
+	// ByIngestion applies StartDate and EndDate to when articles were ingested
+	// instead of when they were published.
+	ByIngestion bool
+	// AfterID restricts results to articles stored after the given article ID.
+	AfterID int64
+	// BeforeID restricts results to articles stored before the given article ID.
+	BeforeID int64
+	SortBy   string
+	Limit    int
+	// Offset skips that many articles, for paging through results.
+	Offset int
+}
 
-	whereClauses := []string{}
+// ArticleSortKeys are the values of ArticleFilter.SortBy. The default,
+// publishedAt, sorts the newest articles first. SortBy may also be "id" or
+// "idAsc", which sort by storage order and are not offered to clients.
+var ArticleSortKeys = []string{"publishedAt", "rank", "severity", "ingestedAt"}
 
-	if sourceFilter != "" && sourceFilter != "all" {
+// where builds the SQL conditions and arguments for the filter.
+func (f ArticleFilter) where() ([]string, []interface{}) {
+	whereClauses := []string{"org_id = ?", notDeletedCondition}
+	args := []interface{}{f.OrgID}
+
+	if f.Source != "" && f.Source != "all" {
 		whereClauses = append(whereClauses, "sourceUrl = ?")
-		args = append(args, sourceFilter)
+		args = append(args, f.Source)
+	}
+
+	if f.Category != "" && f.Category != "all" {
+		whereClauses = append(whereClauses, "(category = ? OR id IN (SELECT article_id FROM article_categories WHERE category = ?))")
+		args = append(args, f.Category, f.Category)
+	}
+
+	if f.Search != "" {
+		whereClauses = append(whereClauses, "(LOWER(title) LIKE ? OR LOWER(description) LIKE ? OR url IN (SELECT url FROM article_bodies WHERE LOWER(text) LIKE ?))")
+		searchPattern := "%" + strings.ToLower(f.Search) + "%"
+		args = append(args, searchPattern, searchPattern, searchPattern)
+	}
+
+	if f.Author != "" {
+		whereClauses = append(whereClauses, "LOWER(author) LIKE ?")
+		args = append(args, "%"+strings.ToLower(f.Author)+"%")
+	}
+
+	if f.Tag != "" {
+		whereClauses = append(whereClauses, "id IN (SELECT at.article_id FROM article_tags at JOIN tags t ON t.id = at.tag_id WHERE t.name = ?)")
+		args = append(args, f.Tag)
+	}
+
+	if f.Sector != "" {
+		whereClauses = append(whereClauses, "id IN (SELECT at.article_id FROM article_tags at JOIN tags t ON t.id = at.tag_id WHERE t.kind = ? AND t.name = ?)")
+		args = append(args, TagKindSector, f.Sector)
+	}
+
+	if f.MediaType != "" {
+		whereClauses = append(whereClauses, "media_type = ?")
+		args = append(args, mediaTypeValue(f.MediaType))
+	}
+
+	if f.Paywalled != nil {
+		whereClauses = append(whereClauses, "paywalled = ?")
+		args = append(args, *f.Paywalled)
+	}
+
+	if f.MinRank > 0 {
+		whereClauses = append(whereClauses, "rank >= ?")
+		args = append(args, f.MinRank)
+	}
+
+	if f.MinSeverity > 0 {
+		whereClauses = append(whereClauses, "severity >= ?")
+		args = append(args, f.MinSeverity)
+	}
+
+	dateColumn := "publishedAt"
+	if f.ByIngestion {
+		dateColumn = "ingested_at"
+	}
+	if !f.StartDate.IsZero() {
+		whereClauses = append(whereClauses, dateColumn+" >= ?")
+		args = append(args, utcTimestamp(f.StartDate))
+	}
+	if !f.EndDate.IsZero() {
+		whereClauses = append(whereClauses, dateColumn+" <= ?")
+		args = append(args, utcTimestamp(f.EndDate))
 	}
 
-	if categoryFilter != "" && categoryFilter != "all" {
-		whereClauses = append(whereClauses, "category = ?")
-		args = append(args, categoryFilter)
+	if !f.IncludeDead {
+		whereClauses = append(whereClauses, "NOT "+deadLinkCondition)
 	}
 
-	if searchFilter != "" {
-		whereClauses = append(whereClauses, "(LOWER(title) LIKE ? OR LOWER(description) LIKE ?)")
-		searchPattern := "%" + strings.ToLower(searchFilter) + "%"
-		args = append(args, searchPattern, searchPattern)
+	if f.AfterID > 0 {
+		whereClauses = append(whereClauses, "id > ?")
+		args = append(args, f.AfterID)
 	}
+	if f.BeforeID > 0 {
+		whereClauses = append(whereClauses, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+
+	return whereClauses, args
+}
 
-	if !startDate.IsZero() {
-		whereClauses = append(whereClauses, "publishedAt >= ?")
-		args = append(args, startDate.Format("2006-01-02 15:04:05"))
+// Matches reports whether an article satisfies the filter's content criteria
+// (organization, source, category, search, author, sector, media type,
+// paywall, minimum rank and severity, dead links). It mirrors the SQL conditions so
+// newly ingested articles can be checked without a query, except that Search
+// does not look at archived bodies, which new articles do not have yet.
+func (f ArticleFilter) Matches(article models.NewsArticle) bool {
+	if article.OrgID != f.OrgID {
+		return false
+	}
+	if f.Source != "" && f.Source != "all" && article.SourceURL != f.Source {
+		return false
+	}
+	if f.Category != "" && f.Category != "all" && article.Category != f.Category && !slices.Contains(article.Categories, f.Category) {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(strings.ToLower(article.Title), search) && !strings.Contains(strings.ToLower(article.Description), search) {
+			return false
+		}
+	}
+	if f.Author != "" && !strings.Contains(strings.ToLower(article.Author), strings.ToLower(f.Author)) {
+		return false
+	}
+	if f.Sector != "" && !inSector(article, f.Sector) {
+		return false
 	}
-	if !endDate.IsZero() {
-		whereClauses = append(whereClauses, "publishedAt <= ?")
-		args = append(args, endDate.Format("2006-01-02 15:04:05"))
+	if f.MediaType != "" && article.MediaType != mediaTypeValue(f.MediaType) {
+		return false
 	}
+	if f.Paywalled != nil && article.Paywalled != *f.Paywalled {
+		return false
+	}
+	if !f.IncludeDead && article.LinkDead {
+		return false
+	}
+	return article.Rank >= f.MinRank && article.Severity >= f.MinSeverity
+}
 
-	if len(whereClauses) > 0 {
-		query += " WHERE " + strings.Join(whereClauses, " AND ")
+// QueryArticles returns the articles matching a filter.
+func QueryArticles(f ArticleFilter) ([]models.NewsArticle, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
 	}
+	var articles []models.NewsArticle
+	query := "SELECT " + articleColumns + " FROM articles"
 
-	if sortBy == "rank" {
+	whereClauses, args := f.where()
+	query += " WHERE " + strings.Join(whereClauses, " AND ")
+
+	switch f.SortBy {
+	case "rank":
 		query += " ORDER BY rank DESC"
-	} else {
+	case "severity":
+		query += " ORDER BY severity DESC, publishedAt DESC"
+	case "ingestedAt":
+		query += " ORDER BY ingested_at DESC, publishedAt DESC"
+	case "id":
+		query += " ORDER BY id DESC"
+	case "idAsc":
+		query += " ORDER BY id"
+	default:
 		query += " ORDER BY publishedAt DESC"
 	}
 
-	if limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, limit)
+	if f.Limit > 0 || f.Offset > 0 {
+		// A negative LIMIT is no limit in SQLite.
+		limit := f.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, f.Offset)
 	}
 
 	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Printf("Error executing query in GetArticlesFromDB: %v", err)
+		log.Printf("Error executing query in QueryArticles: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var article models.NewsArticle
-		if err := rows.Scan(&article.Title, &article.Description, &article.ImageURL, &article.URL, &article.SourceURL, &article.PublishedAt, &article.Rank, &article.Category); err != nil {
+		article, err := scanArticle(rows)
+		if err != nil {
 			log.Printf("Error scanning article: %v", err)
 			continue
 		}
@@ -258,106 +848,435 @@ func GetArticlesFromDB(sourceFilter string, categoryFilter string, searchFilter
 	return articles, nil
 }
 
-func StartCachingJob(rssSources []string) {
-	fetchAndCacheNews(rssSources)
+// LastArticleID returns the highest ID ever given to an article, 0 if none
+// was stored. Unlike the highest ID in the table, it does not go back down
+// when the newest articles are deleted.
+func LastArticleID() (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	var id int64
+	err := db.QueryRow("SELECT COALESCE((SELECT seq FROM sqlite_sequence WHERE name = 'articles'), 0)").Scan(&id)
+	return id, err
+}
 
-	ticker := time.NewTicker(15 * time.Minute)
-	go func() {
-		for range ticker.C {
-			log.Println("Running scheduled news caching job...")
-			fetchAndCacheNews(rssSources)
-		}
-	}()
+// utcTimestamp formats t for comparison with publishedAt and ingested_at,
+// which are stored in UTC.
+func utcTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
 }
 
-func fetchAndCacheNews(rssSources []string) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout: 10 * time.Second,
+// storedArticleColumns lists the columns of the articles table in the order scanArticle expects.
+const storedArticleColumns = "id, title, description, description_html, imageUrl, url, sourceUrl, publishedAt, ingested_at, rank, severity, category, org_id, author, guid, media_type, duration, audio_url, paywalled"
+
+// articleColumns selects an article for scanArticle, including its source icon.
+var articleColumns = qualifiedArticleColumns("articles")
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// qualifiedArticleColumns returns articleColumns prefixed with a table alias for joins.
+func qualifiedArticleColumns(alias string) string {
+	cols := strings.Split(storedArticleColumns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	cols = append(cols, alias+"."+deadLinkCondition, alias+".archive_url", "COALESCE((SELECT icon_url FROM sources WHERE sources.url = "+alias+".sourceUrl), '')", articleTagsColumn(alias), articleCategoriesColumn(alias))
+	return strings.Join(cols, ", ")
+}
+
+// articleScanTargets returns the Scan destinations matching articleColumns.
+func articleScanTargets(article *models.NewsArticle) []interface{} {
+	return []interface{}{&article.ID, &article.Title, &article.Description, &article.DescriptionHTML, &article.ImageURL, &article.URL, &article.SourceURL, &article.PublishedAt, &article.IngestedAt, &article.Rank, &article.Severity, &article.Category, &article.OrgID, &article.Author, &article.GUID, &article.MediaType, &article.Duration, &article.AudioURL, &article.Paywalled, &article.LinkDead, &article.ArchiveURL, &article.SourceIcon, (*tagList)(&article.Tags), (*tagList)(&article.Categories)}
+}
+
+// scanArticle reads an article selected with articleColumns.
+func scanArticle(row rowScanner) (models.NewsArticle, error) {
+	var article models.NewsArticle
+	err := row.Scan(articleScanTargets(&article)...)
+	return article, err
+}
+
+// GetArticleByID returns a single article, even one in the trash, or
+// sql.ErrNoRows if it does not exist.
+func GetArticleByID(id int64) (models.NewsArticle, error) {
+	if db == nil {
+		return models.NewsArticle{}, fmt.Errorf("database connection is nil")
+	}
+	return scanArticle(db.QueryRow("SELECT "+articleColumns+" FROM articles WHERE id = ?", id))
+}
+
+// GetOrgArticle returns an article of an organization, or sql.ErrNoRows if the
+// organization has no article with that id or it is in the trash. With withContent set, Content holds
+// the article's full text, which is its description unless that was truncated.
+func GetOrgArticle(orgID, id int64, withContent bool) (models.NewsArticle, error) {
+	if db == nil {
+		return models.NewsArticle{}, fmt.Errorf("database connection is nil")
+	}
+	if !withContent {
+		return scanArticle(db.QueryRow("SELECT "+articleColumns+" FROM articles WHERE id = ? AND org_id = ? AND "+notDeletedCondition, id, orgID))
+	}
+	var article models.NewsArticle
+	err := db.QueryRow("SELECT "+articleColumns+", CASE WHEN content != '' THEN content ELSE description END FROM articles WHERE id = ? AND org_id = ? AND "+notDeletedCondition, id, orgID).
+		Scan(append(articleScanTargets(&article), &article.Content)...)
+	return article, err
+}
+
+// StartCachingJob schedules the "caching" job, which fetches the sources now
+// and every 15 minutes while this instance is the leader (see IsLeader).
+func StartCachingJob(rssSources []string) {
+	err := RegisterJob(Job{Name: "caching", Schedule: Every(15 * time.Minute), RunAtStart: true, Run: func() error {
+		log.Println("Running scheduled news caching job...")
+		fetchAndCacheNews(rssSources)
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling the caching job: %v", err)
 	}
-	client.Transport = &userAgentTransport{RoundTripper: transport}
+}
+
+func fetchAndCacheNews(rssSources []string) {
+	ingest(rssSources, ingestOptions{})
+}
 
+// FetchOnce runs one caching cycle over the sources outside the caching job,
+// e.g. from the command line, and reports what it stored. With dryRun the
+// sources are fetched and their articles processed and scored, but nothing is
+// written: articles that would be inserted are counted as new.
+func FetchOnce(rssSources []string, dryRun bool) IngestReport {
+	return ingest(rssSources, ingestOptions{dryRun: dryRun})
+}
+
+// ingestOptions changes how a caching cycle runs.
+type ingestOptions struct {
+	// dryRun processes and scores the articles without writing anything.
+	dryRun bool
+	// replay holds recorded responses, by source, processed instead of
+	// fetching the sources. Only the replayed sources are processed.
+	replay map[string]recordedFeed
+	// since skips the items published before it, for backfills.
+	since time.Time
+	// seen carries the articles a dry run would insert across several
+	// cycles, such as the pages of a backfill.
+	seen map[string]bool
+}
+
+func ingest(rssSources []string, opts ingestOptions) IngestReport {
+	dryRun := opts.dryRun
+	started := time.Now()
 	fp := gofeed.NewParser()
-	fp.Client = client
+	fp.Client = fetcher.Client(10 * time.Second)
 
 	var wg sync.WaitGroup
-	p := bluemonday.StripTagsPolicy()
 
-	articleChan := make(chan models.NewsArticle, 100)
+	articleChan := make(chan fetchedArticle, 100)
+	insertDone := make(chan struct{})
+	// newArticles counts the articles stored per source. It belongs to the
+	// insert goroutine until insertDone is closed, like the report's
+	// duplicate counts and rank distribution.
+	newArticles := map[string]int{}
+	report := newIngestReport()
+	seen := opts.seen
+	if seen == nil {
+		seen = map[string]bool{}
+	}
 
 	go func() {
-		for article := range articleChan {
-			InsertArticle(article) // This runs strictly one at a time
+		defer close(insertDone)
+		for fetched := range articleChan {
+			if dryRun {
+				isNew, err := wouldInsert(fetched.article, seen)
+				if err != nil {
+					log.Printf("Error looking up article %s: %v", fetched.article.Title, err)
+				} else if isNew {
+					newArticles[fetched.article.SourceURL]++
+					report.countNew(fetched.article)
+				} else {
+					report.countDuplicate(fetched.article.SourceURL)
+				}
+				continue
+			}
+			// This runs strictly one at a time
+			isNew, err := insertArticle(fetched.article)
+			if err != nil {
+				continue
+			}
+			if !isNew {
+				report.countDuplicate(fetched.article.SourceURL)
+				continue
+			}
+			newArticles[fetched.article.SourceURL]++
+			report.countNew(fetched.article)
+			if ArchiveRawItems {
+				if err := archiveRawItem(fetched.article.SourceURL, fetched.item); err != nil {
+					log.Printf("Error archiving feed item %s: %v", fetched.article.Title, err)
+				}
+			}
+			runArticleHooks(fetched.article)
 		}
 	}()
 
+	// Each source is fetched once and its articles are stored for every
+	// organization that lists it. The shared feed is organization 0 and only
+	// uses the built-in scoring.
+	targets := map[string][]orgFeed{}
 	for _, source := range rssSources {
+		targets[source] = append(targets[source], orgFeed{})
+	}
+	orgFeeds, err := getOrgFeeds()
+	if err != nil {
+		log.Printf("Error loading organization sources: %v", err)
+	}
+	for _, feed := range orgFeeds {
+		for _, source := range feed.settings.Sources {
+			if _, replayed := opts.replay[source]; opts.replay != nil && !replayed {
+				continue
+			}
+			targets[source] = append(targets[source], feed)
+		}
+	}
+
+	// Items seen in an earlier cycle are not processed again. The keys of the
+	// items fetched are saved once they are stored.
+	var fetchedMutex sync.Mutex
+	fetchedKeys := map[string][]string{}
+	fetchErrors := map[string]error{}
+
+	// sourceCounts holds the report's per-source counts of the fetching
+	// goroutines, merged once they are done.
+	sourceCounts := map[string]*SourceReport{}
+	for source := range targets {
+		sourceCounts[source] = &SourceReport{URL: source}
+	}
+
+	for source, feeds := range targets {
 		wg.Add(1)
-		go func(source string) {
+		go func(source string, feeds []orgFeed) {
 			defer wg.Done()
-			feed, err := fp.ParseURL(source)
+			counts := sourceCounts[source]
+			telegram := isTelegramSource(source)
+			if dryRun && (source == NewsletterSource || telegram) {
+				// Fetching newsletters and Telegram posts consumes them.
+				counts.Skipped = true
+				return
+			}
+			paste := isPasteSource(source)
+			ghsa := isGHSASource(source)
+			advisory := ghsa || isAdvisorySource(source)
+			var feed *gofeed.Feed
+			var err error
+			if paste {
+				feed, err = fetchPasteFeed(fp.Client, source)
+			} else if telegram {
+				feed, err = fetchTelegramFeed(fp.Client, source)
+			} else if source == NewsletterSource {
+				feed, err = fetchNewsletterFeed()
+			} else if ghsa {
+				feed, err = fetchGHSAFeed(fp.Client, source)
+			} else if advisory {
+				feed, err = fetchAdvisoryFeed(fp.Client, source)
+			} else {
+				if recorded, ok := opts.replay[source]; ok {
+					feed, err = parseRecordedFeed(fp, recorded.fixture, recorded.body)
+				} else {
+					feed, err = fetchFeed(fp, source, !dryRun)
+				}
+				if err == nil && isYouTubeSource(source) {
+					annotateYouTubeItems(fp.Client, feed.Items)
+				}
+				if err == nil && isGoogleAlertsSource(source) {
+					normalizeGoogleAlertsItems(feed.Items)
+				}
+			}
 			if err != nil {
 				log.Printf("Error parsing feed from %s for caching: %v", source, err)
+				fetchedMutex.Lock()
+				fetchErrors[source] = err
+				fetchedMutex.Unlock()
+				counts.Error = err.Error()
 				return
 			}
+			if !dryRun && opts.replay == nil {
+				refreshSourceInfo(source, feed)
+			}
+			counts.Items = len(feed.Items)
 
+			// Replays process every item of the recording.
+			var seen map[string]bool
+			if opts.replay == nil {
+				if seen, err = seenItems(source, feeds); err != nil {
+					log.Printf("Error reading the items seen from %s: %v", source, err)
+				}
+			}
+			keys := make([]string, 0, len(feed.Items))
 			for _, item := range feed.Items {
-				// Language detection
-				textToDetect := item.Title + " " + item.Description
-				lang, _ := detector.DetectLanguageOf(textToDetect)
-				if lang != lingua.English {
-					log.Printf("Skipping non-English article: %s (Source: %s)", item.Title, source)
+				key := itemKey(item)
+				keys = append(keys, key)
+				if seen[key] {
+					counts.Seen++
+					continue
+				}
+				if item.PublishedParsed != nil && item.PublishedParsed.Before(opts.since) {
+					counts.BeforeSince++
 					continue
 				}
 
-				category := getCategoryForSource(source)
-
-				article := models.NewsArticle{
-					Title:       item.Title,
-					Description: p.Sanitize(item.Description),
-					URL:         item.Link,
-					SourceURL:   source,
-					Category:    category,
+				// Pastes are mostly dumps rather than prose, and neither they
+				// nor advisories are worth a preview image. Telegram posts
+				// come with their media and are often too short to tell
+				// their language.
+				if !paste && !telegram && !advisory && !isEnglish(feed, item) {
+					log.Printf("Skipping non-English article: %s (Source: %s)", item.Title, source)
+					counts.NonEnglish++
+					continue
 				}
-				article.Rank = calculateRank(article)
 
-				if item.Image != nil {
-					article.ImageURL = item.Image.URL
+				article := articleFromItem(source, item)
+				if reason := filteredOut(article); reason != "" {
+					log.Printf("Skipping filtered article: %s (Source: %s): %s", item.Title, source, reason)
+					counts.Filtered++
+					continue
 				}
-				if item.PublishedParsed != nil {
-					article.PublishedAt = *item.PublishedParsed
-				} else if feed.PublishedParsed != nil {
-					article.PublishedAt = *feed.PublishedParsed
-				} else {
-					article.PublishedAt = time.Now()
+				if article.ImageURL == "" && FetchPreviewImages && !paste && !telegram && !advisory && !dryRun {
+					article.ImageURL = resolvePreviewImage(article.URL)
+				}
+				if article.PublishedAt.IsZero() {
+					if feed.PublishedParsed != nil {
+						article.PublishedAt = feed.PublishedParsed.UTC()
+					} else {
+						article.PublishedAt = time.Now().UTC()
+					}
 				}
 
-				// Send to the channel instead of writing to DB
-				articleChan <- article
+				for _, feed := range feeds {
+					orgArticle := article
+					orgArticle.OrgID = feed.orgID
+					orgArticle.Rank = scoreForOrg(orgArticle, feed.settings)
+					orgArticle.Severity = Severity(orgArticle.Category, orgArticle.Rank)
+					// Send to the channel instead of writing to DB
+					articleChan <- fetchedArticle{article: orgArticle, item: item}
+				}
 			}
-		}(source)
+			fetchedMutex.Lock()
+			fetchedKeys[source] = keys
+			fetchedMutex.Unlock()
+		}(source, feeds)
 	}
 
 	wg.Wait()
 	close(articleChan)
+	<-insertDone
+	report.merge(sourceCounts, newArticles)
+	if dryRun || opts.replay != nil {
+		return *report
+	}
+	for source, keys := range fetchedKeys {
+		if err := saveSeenItems(source, targets[source], keys, time.Now()); err != nil {
+			log.Printf("Error saving the items seen from %s: %v", source, err)
+		}
+	}
+	fetches := make(map[string]sourceFetch, len(targets))
+	for source := range targets {
+		fetches[source] = sourceFetch{err: fetchErrors[source], newArticles: newArticles[source]}
+	}
+	if err := recordIngestCycle(started, time.Now(), fetches); err != nil {
+		log.Printf("Error recording caching cycle: %v", err)
+	}
 	log.Println("News caching job completed.")
+	runCycleHooks()
+	return *report
+}
+
+// fetchedArticle is an article on its way to the database with the feed item
+// it was built from.
+type fetchedArticle struct {
+	article models.NewsArticle
+	item    *gofeed.Item
 }
 
-type userAgentTransport struct {
-	http.RoundTripper
+// articleFromItem builds an article from a feed item. PublishedAt is zero when
+// the item carries no date.
+func articleFromItem(source string, item *gofeed.Item) models.NewsArticle {
+	article := models.NewsArticle{
+		Title:     normalizeText(item.Title),
+		URL:       item.Link,
+		SourceURL: source,
+		Category:  getCategoryForSource(source),
+		Author:    itemAuthor(item),
+		GUID:      item.GUID,
+	}
+	description, descriptionHTML := sanitizeDescription(item.Description)
+	description = normalizeDescription(source, description)
+	article.Description = truncateText(description, DescriptionMaxLength)
+	if article.Description == description {
+		article.DescriptionHTML = descriptionHTML
+	} else {
+		// The full text moves to Content; the markup would carry all of it
+		// back into /news responses, so it is dropped.
+		article.Content = description
+	}
+	if item.Image != nil {
+		article.ImageURL = item.Image.URL
+	}
+	if item.PublishedParsed != nil {
+		article.PublishedAt = item.PublishedParsed.UTC()
+	}
+	if article.Category == "General" && InferCategories {
+		if _, listed := customCategoryForSource(source); !listed {
+			if category, ok := inferCategory(article); ok {
+				article.Category = category
+			}
+		}
+	}
+	article.Categories = append([]string{article.Category}, secondaryCategories(article)...)
+	article.Paywalled = isPaywalledSource(source, article.URL)
+	if isGHSASource(source) {
+		article.Tags = item.Categories
+	}
+	if isYouTubeSource(source) {
+		article.MediaType = MediaTypeVideo
+		article.Duration = youtubeDuration(item)
+	} else if isTelegramSource(source) {
+		applyTelegramMedia(&article, item)
+	} else {
+		applyPodcastEpisode(&article, item)
+	}
+	return runEnrichers(article)
 }
 
-func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/108.0.0.0 Safari/537.36")
-	return t.RoundTripper.RoundTrip(req)
+// itemAuthor returns the names of a feed item's authors.
+func itemAuthor(item *gofeed.Item) string {
+	var names []string
+	for _, person := range item.Authors {
+		if person != nil && strings.TrimSpace(person.Name) != "" {
+			names = append(names, strings.TrimSpace(person.Name))
+		}
+	}
+	if len(names) == 0 && item.Author != nil {
+		return strings.TrimSpace(item.Author.Name)
+	}
+	return strings.Join(names, ", ")
 }
 
 func getCategoryForSource(sourceURL string) string {
+	if category, ok := customCategoryForSource(sourceURL); ok {
+		return category
+	}
+	if isPasteSource(sourceURL) {
+		return ExposureCategory
+	}
+	if isTelegramSource(sourceURL) {
+		return TelegramCategory
+	}
+	if isAdvisorySource(sourceURL) {
+		return "Cybersecurity"
+	}
+	if isGHSASource(sourceURL) {
+		return ghsaCategory
+	}
+
 	// Define your source-to-category mapping here
 	cybersecuritySources := []string{
 		"https://www.bleepingcomputer.com/feed/",
@@ -421,31 +1340,80 @@ func ClearAllArticlesForTest() error {
 	if db == nil {
 		return nil
 	}
-	_, err := db.Exec("DELETE FROM articles")
+	_, err := db.Exec("DELETE FROM article_tags; DELETE FROM article_categories; DELETE FROM article_enrichment; DELETE FROM reprocess_queue; DELETE FROM article_cves; DELETE FROM breaches; DELETE FROM article_bodies; DELETE FROM articles")
 	return err
 }
 
-// GetAllArticlesStream returns a sql.Rows object for streaming all articles.
-// The caller is responsible for closing the rows.
+// GetAllArticlesStream returns a sql.Rows object for streaming all articles of
+// the shared feed. The caller is responsible for closing the rows.
 func GetAllArticlesStream() (*sql.Rows, error) {
+	return GetOrgArticlesStream(context.Background(), 0)
+}
+
+// GetOrgArticlesStream returns a sql.Rows object for streaming all articles of
+// an organization, which stops when ctx is done. The caller is responsible for
+// closing the rows.
+func GetOrgArticlesStream(ctx context.Context, orgID int64) (*sql.Rows, error) {
+	return StreamArticles(ctx, ArticleFilter{OrgID: orgID, IncludeDead: true})
+}
+
+// StreamArticles returns a sql.Rows object for streaming the title,
+// description, imageUrl, url, sourceUrl, publishedAt, rank and category of the
+// articles matching the filter, newest first, or oldest first if SortBy is
+// "publishedAtAsc". Articles published at the same time are ordered by ID, so
+// that the same articles are always streamed in the same order. Limit is
+// ignored. The caller is responsible for closing the rows.
+func StreamArticles(ctx context.Context, f ArticleFilter) (*sql.Rows, error) {
 	if db == nil {
 		return nil, fmt.Errorf("database connection is nil")
 	}
-	query := "SELECT title, description, imageUrl, url, sourceUrl, publishedAt, rank, category FROM articles ORDER BY publishedAt DESC"
-	rows, err := db.Query(query)
+	whereClauses, args := f.where()
+	order := " ORDER BY publishedAt DESC, id DESC"
+	if f.SortBy == "publishedAtAsc" {
+		order = " ORDER BY publishedAt, id"
+	}
+	query := "SELECT title, description, imageUrl, url, sourceUrl, publishedAt, rank, category FROM articles WHERE " +
+		strings.Join(whereClauses, " AND ") + order
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	return rows, nil
 }
 
-// GetArticleCount returns the number of articles in the database.
+// ExportState identifies the articles a filter selects, so that an export of
+// them can be resumed as long as they did not change.
+type ExportState struct {
+	// LastID is the newest article ID selected, 0 if there is none.
+	LastID int64
+	Count  int
+	// Revision is the last time one of the articles was enriched, which
+	// changes when they are reprocessed.
+	Revision string
+}
+
+// GetExportState returns the state of the articles matching the filter.
+func GetExportState(f ArticleFilter) (ExportState, error) {
+	if db == nil {
+		return ExportState{}, fmt.Errorf("database connection is nil")
+	}
+	whereClauses, args := f.where()
+	where := strings.Join(whereClauses, " AND ")
+	var state ExportState
+	err := db.QueryRow(`SELECT COALESCE(MAX(id), 0), COUNT(*),
+		COALESCE((SELECT MAX(processed_at) FROM article_enrichment WHERE article_id IN (SELECT id FROM articles WHERE `+where+`)), '')
+		FROM articles WHERE `+where, append(append([]interface{}{}, args...), args...)...).Scan(&state.LastID, &state.Count, &state.Revision)
+	return state, err
+}
+
+// GetArticleCount returns the number of articles in the database, not
+// counting those in the trash.
 func GetArticleCount() (int, error) {
 	if db == nil {
 		return 0, fmt.Errorf("database connection is nil")
 	}
 	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM articles").Scan(&count)
+	err := db.QueryRow("SELECT COUNT(*) FROM articles WHERE " + notDeletedCondition).Scan(&count)
 	return count, err
 }
 
@@ -477,7 +1445,7 @@ func LoadArticlesFromCSV(filePath string) error {
 	}
 
 	// Prepare the insert statement
-	stmt, err := db.Prepare("INSERT OR IGNORE INTO articles(title, description, imageUrl, url, sourceUrl, publishedAt, rank, category) VALUES(?, ?, ?, ?, ?, ?, ?, ?)")
+	stmt, err := db.Prepare("INSERT OR IGNORE INTO articles(title, description, imageUrl, url, url_hash, sourceUrl, publishedAt, ingested_at, rank, severity, category) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %v", err)
 	}
@@ -513,7 +1481,7 @@ func LoadArticlesFromCSV(filePath string) error {
 			continue
 		}
 
-		_, err = stmt.Exec(record[0], record[1], record[2], record[3], record[4], publishedAt, rank, record[7])
+		_, err = stmt.Exec(record[0], record[1], record[2], record[3], urlHash(record[3]), record[4], publishedAt.UTC(), time.Now().UTC(), rank, Severity(record[7], rank), record[7])
 		if err != nil {
 			log.Printf("Error inserting article from CSV: %v", err)
 			continue
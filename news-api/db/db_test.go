@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"news-api/fetcher"
 	"news-api/models"
 
 	"github.com/stretchr/testify/assert"
@@ -23,6 +24,9 @@ func setupTestDB(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to clear articles table: %v", err)
 	}
+
+	// Test servers are local; only the politeness tests need a host delay.
+	fetcher.Configure(fetcher.Config{RespectRobots: true})
 }
 
 // teardownTestDB is a no-op since the in-memory database is ephemeral.
@@ -70,6 +74,33 @@ func TestCalculateRank(t *testing.T) {
 			},
 			expected: 5, // review(1) + gadget(1) + tips(1) + software(1) + update(1)
 		},
+		{
+			name: "Defense High Impact",
+			article: models.NewsArticle{
+				Title:       "Missile strike on port signals escalation",
+				Description: "Neighbouring states announce troops mobilization.",
+				Category:    "Defense",
+			},
+			expected: 24, // missile strike(5) + strike(3) + missile(3) + escalation(5) + troops(3) + mobilization(5)
+		},
+		{
+			name: "Defense Medium Impact",
+			article: models.NewsArticle{
+				Title:       "New sanctions follow border conflict",
+				Description: "Casualties reported as the ceasefire holds.",
+				Category:    "Defense",
+			},
+			expected: 12, // sanctions(3) + conflict(3) + casualties(3) + ceasefire(3)
+		},
+		{
+			name: "Defense Low Impact",
+			article: models.NewsArticle{
+				Title:       "Navy awards procurement contract",
+				Description: "The Pentagon announced the deal.",
+				Category:    "Defense",
+			},
+			expected: 4, // navy(1) + procurement(1) + contract(1) + pentagon(1)
+		},
 		{
 			name: "General Category",
 			article: models.NewsArticle{
@@ -96,6 +127,10 @@ func TestCalculateRank(t *testing.T) {
 			assert.Equal(t, tc.expected, rank, "Rank calculation was incorrect")
 		})
 	}
+
+	strike := calculateRank(models.NewsArticle{Title: "Missile strike hits airbase", Category: "Defense"})
+	gadget := calculateRank(models.NewsArticle{Title: "Review of the new gadget", Category: "Tech"})
+	assert.Greater(t, strike, gadget, "a missile strike should outrank a gadget review")
 }
 
 func TestGetCategoryForSource(t *testing.T) {
@@ -201,6 +236,84 @@ func TestGetTodayThreatScoreLevels(t *testing.T) {
 	}
 }
 
+func TestGetTodayThreatScoreBreakdown(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	now := time.Now()
+	articles := []models.NewsArticle{
+		{Title: "Hospital ransomware", URL: "u1", Category: "Cybersecurity", Severity: 90, PublishedAt: now},
+		{Title: "Zero-day in VPN", URL: "u2", Category: "Cybersecurity", Severity: 60, PublishedAt: now},
+		{Title: "Botnet takedown", URL: "u3", Category: "Cybersecurity", Severity: 40, PublishedAt: now},
+		{Title: "Router bug", URL: "u4", Category: "Cybersecurity", Severity: 30, PublishedAt: now},
+		{Title: "Drone contract", URL: "u5", Category: "Defense", Severity: 15, PublishedAt: now},
+		{Title: "New phone", URL: "u6", Category: "Tech", Severity: 1, PublishedAt: now},
+		{Title: "Last week", URL: "u7", Category: "Tech", Severity: 95, PublishedAt: now.Add(-48 * time.Hour)},
+	}
+	for _, article := range articles {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	score, err := GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, 4, score.HighRankCount)
+	assert.Equal(t, CategoryThreat{HighRankCount: 4, TotalArticles: 4, ThreatLevel: "Code Red"}, score.Categories["Cybersecurity"])
+	assert.Equal(t, CategoryThreat{MediumRankCount: 1, TotalArticles: 1, ThreatLevel: "Attention"}, score.Categories["Defense"])
+	assert.Equal(t, CategoryThreat{LowRankCount: 1, TotalArticles: 1, ThreatLevel: "Business as Usual"}, score.Categories["Tech"])
+
+	require.NotNil(t, score.TopArticles)
+	var high []string
+	for _, a := range score.TopArticles.High {
+		high = append(high, a.Title)
+	}
+	assert.Equal(t, []string{"Hospital ransomware", "Zero-day in VPN", "Botnet takedown"}, high, "the three most severe, most severe first")
+	assert.Equal(t, []ThreatArticle{{Title: "Drone contract", URL: "u5", Category: "Defense", Severity: 15}}, score.TopArticles.Medium)
+	assert.Len(t, score.TopArticles.Low, 1)
+}
+
+func TestGetTodayThreatScoreTrend(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+
+	now := time.Now()
+	yesterday := now.Add(-30 * time.Hour)
+	articles := []models.NewsArticle{
+		{Title: "Today medium", URL: "u1", Severity: 15, PublishedAt: now},
+		{Title: "Today low", URL: "u2", Severity: 1, PublishedAt: now},
+		{Title: "Yesterday high", URL: "u3", Severity: 90, PublishedAt: yesterday},
+		{Title: "Yesterday high 2", URL: "u4", Severity: 50, PublishedAt: yesterday},
+		{Title: "Yesterday low", URL: "u5", Severity: 2, PublishedAt: yesterday},
+		{Title: "Backfilled", URL: "u6", Severity: 90, PublishedAt: now.Add(-100 * time.Hour)},
+	}
+	for _, article := range articles {
+		require.NoError(t, InsertArticle(article))
+	}
+	_, err := db.Exec("UPDATE articles SET ingested_at = ? WHERE title LIKE 'Yesterday%'", yesterday.UTC())
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE articles SET ingested_at = ? WHERE title = 'Backfilled'", yesterday.UTC())
+	require.NoError(t, err)
+
+	score, err := GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, "Attention", score.ThreatLevel)
+	assert.Equal(t, &ThreatTrend{
+		PreviousLevel:        "Code Red",
+		PreviousTotal:        3,
+		TotalArticlesDelta:   -1,
+		HighRankCountDelta:   -2,
+		MediumRankCountDelta: 1,
+		LowRankCountDelta:    0,
+		Direction:            "better",
+	}, score.Trend)
+
+	// An override does not change the comparison of the computed levels.
+	_, err = SetThreatOverride(models.ThreatOverride{Level: "Code Red", Reason: "drill", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	score, err = GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, "better", score.Trend.Direction)
+}
+
 func TestGetArticleCount(t *testing.T) {
 	setupTestDB(t)
 	defer teardownTestDB()
@@ -364,3 +477,93 @@ Invalid Rank,Description,,https://example.com/3,https://source.example.com,2024-
 	assert.Len(t, articles, 1)
 	assert.Equal(t, "Valid Article", articles[0].Title)
 }
+
+func TestGUIDDeduplicationAndAuthorFilter(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+
+	isNew, err := insertArticle(models.NewsArticle{Title: "Breach at Acme", URL: "https://news.example/a?utm=1", SourceURL: "src", GUID: "post-42", Author: "Jane Doe", PublishedAt: now})
+	require.NoError(t, err)
+	assert.True(t, isNew)
+
+	// The feed changed the item's URL but kept its GUID.
+	isNew, err = insertArticle(models.NewsArticle{Title: "Breach at Acme (updated)", URL: "https://news.example/a", SourceURL: "src", GUID: "post-42", PublishedAt: now})
+	require.NoError(t, err)
+	assert.False(t, isNew)
+
+	// GUIDs are only unique within a source, and items without one are keyed by URL alone.
+	for _, article := range []models.NewsArticle{
+		{Title: "Other feed", URL: "https://other.example/1", SourceURL: "other", GUID: "post-42", Author: "John Roe", PublishedAt: now},
+		{Title: "No GUID 1", URL: "https://news.example/b", SourceURL: "src", PublishedAt: now},
+		{Title: "No GUID 2", URL: "https://news.example/c", SourceURL: "src", PublishedAt: now},
+	} {
+		isNew, err = insertArticle(article)
+		require.NoError(t, err)
+		assert.True(t, isNew, article.Title)
+	}
+
+	articles, err := QueryArticles(ArticleFilter{Author: "jane"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Jane Doe", articles[0].Author)
+	assert.Equal(t, "post-42", articles[0].GUID)
+	assert.True(t, ArticleFilter{Author: "DOE"}.Matches(articles[0]))
+	assert.False(t, ArticleFilter{Author: "roe"}.Matches(articles[0]))
+}
+
+func TestMigratePublishedAtToUTC(t *testing.T) {
+	setupTestDB(t)
+	_, err := db.Exec("INSERT INTO articles(title, description, imageUrl, url, sourceUrl, publishedAt) VALUES('Old', '', '', 'https://example.com/old', 'src', '2024-03-01 23:30:00.5-05:00')")
+	require.NoError(t, err)
+	require.NoError(t, migratePublishedAtToUTC())
+
+	articles, err := QueryArticles(ArticleFilter{StartDate: time.Date(2024, 3, 2, 4, 0, 0, 0, time.UTC), EndDate: time.Date(2024, 3, 2, 5, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.True(t, articles[0].PublishedAt.Equal(time.Date(2024, 3, 2, 4, 30, 0, 500e6, time.UTC)), articles[0].PublishedAt)
+}
+
+func TestIngestedAt(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Backfilled", URL: "https://example.com/backfilled", SourceURL: "src", Category: "Cybersecurity", PublishedAt: now.Add(-30 * 24 * time.Hour), Rank: 30}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Stale", URL: "https://example.com/stale", SourceURL: "src", Category: "Cybersecurity", PublishedAt: now.Add(-time.Hour), Rank: 30}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Fresh", URL: "https://example.com/fresh", SourceURL: "src", Category: "Cybersecurity", PublishedAt: now.Add(-2 * time.Hour), Rank: 1}))
+	// Stale carries a recent date but was ingested days ago.
+	_, err := db.Exec("UPDATE articles SET ingested_at = ? WHERE title = 'Stale'", now.Add(-72*time.Hour).UTC())
+	require.NoError(t, err)
+
+	score, err := GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, 1, score.TotalArticles)
+	assert.Equal(t, 0, score.HighRankCount)
+
+	recent, err := QueryArticles(ArticleFilter{StartDate: now.Add(-24 * time.Hour), ByIngestion: true, SortBy: "ingestedAt"})
+	require.NoError(t, err)
+	require.Len(t, recent, 2)
+	assert.ElementsMatch(t, []string{"Backfilled", "Fresh"}, []string{recent[0].Title, recent[1].Title})
+	assert.WithinDuration(t, now, recent[0].IngestedAt, time.Minute)
+
+	published, err := QueryArticles(ArticleFilter{StartDate: now.Add(-24 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, published, 2)
+	assert.Equal(t, "Stale", published[0].Title)
+	assert.Equal(t, "Fresh", published[1].Title)
+}
+
+func TestInitReadOnlyDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.db")
+	require.NoError(t, InitDB(path))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Snapshot", URL: "https://example.com/a", SourceURL: "src", PublishedAt: time.Now()}))
+	require.NoError(t, db.Close())
+
+	require.NoError(t, InitReadOnlyDB(path))
+	defer setupTestDB(t)
+	articles, err := QueryArticles(ArticleFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Snapshot", articles[0].Title)
+	assert.Error(t, InsertArticle(models.NewsArticle{Title: "New", URL: "https://example.com/b", SourceURL: "src", PublishedAt: time.Now()}))
+
+	assert.Error(t, InitReadOnlyDB(filepath.Join(t.TempDir(), "missing.db")))
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// Delivery statuses. Delivered messages are deleted from the queue.
+const (
+	DeliveryPending = "pending"
+	DeliveryDead    = "dead"
+)
+
+func createDeliveryTables() error {
+	createDeliveriesSQL := `
+	CREATE TABLE IF NOT EXISTS deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		sink TEXT NOT NULL,
+		target TEXT NOT NULL DEFAULT '',
+		content_type TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_deliveries_due ON deliveries (sink, status, next_attempt_at);
+	`
+	if _, err := db.Exec(createDeliveriesSQL); err != nil {
+		return fmt.Errorf("failed to create deliveries table: %v", err)
+	}
+	return nil
+}
+
+const deliveryColumns = "id, sink, target, content_type, payload, status, attempts, next_attempt_at, last_error, created_at"
+
+func scanDelivery(row rowScanner) (models.Delivery, error) {
+	var d models.Delivery
+	var payload []byte
+	err := row.Scan(&d.ID, &d.Sink, &d.Target, &d.ContentType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt)
+	d.Payload = string(payload)
+	return d, err
+}
+
+// EnqueueDelivery adds a message to the delivery queue, due immediately.
+func EnqueueDelivery(d models.Delivery) (models.Delivery, error) {
+	if db == nil {
+		return d, fmt.Errorf("database connection is nil")
+	}
+	d.Status = DeliveryPending
+	d.CreatedAt = time.Now().UTC()
+	d.NextAttemptAt = d.CreatedAt
+	res, err := db.Exec("INSERT INTO deliveries(sink, target, content_type, payload, status, next_attempt_at, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		d.Sink, d.Target, d.ContentType, []byte(d.Payload), d.Status, d.NextAttemptAt, d.CreatedAt)
+	if err != nil {
+		return d, fmt.Errorf("failed to enqueue delivery: %v", err)
+	}
+	d.ID, err = res.LastInsertId()
+	return d, err
+}
+
+// GetDueDeliveries returns up to limit pending deliveries of a sink whose next
+// attempt is not after now, oldest first.
+func GetDueDeliveries(sink string, now time.Time, limit int) ([]models.Delivery, error) {
+	return queryDeliveries("SELECT "+deliveryColumns+" FROM deliveries WHERE sink = ? AND status = ? AND next_attempt_at <= ? ORDER BY id LIMIT ?",
+		sink, DeliveryPending, now.UTC(), limit)
+}
+
+// CompleteDeliveries removes delivered messages from the queue.
+func CompleteDeliveries(ids []int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	_, err := db.Exec("DELETE FROM deliveries WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+")", args...)
+	return err
+}
+
+// RescheduleDeliveries stores the attempts, status, next attempt and last
+// error of deliveries that failed.
+func RescheduleDeliveries(deliveries []models.Delivery) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, d := range deliveries {
+		if _, err := tx.Exec("UPDATE deliveries SET attempts = ?, status = ?, next_attempt_at = ?, last_error = ? WHERE id = ?",
+			d.Attempts, d.Status, d.NextAttemptAt.UTC(), d.LastError, d.ID); err != nil {
+			return fmt.Errorf("failed to reschedule delivery %d: %v", d.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// DeliveryFilter selects deliveries, newest first. Zero values mean "no restriction".
+type DeliveryFilter struct {
+	Sink   string
+	Status string
+	// BeforeID returns only deliveries older than the given one, for paging.
+	BeforeID int64
+	Limit    int
+}
+
+func (f DeliveryFilter) where() (string, []interface{}) {
+	whereClauses := []string{}
+	args := []interface{}{}
+	if f.Sink != "" {
+		whereClauses = append(whereClauses, "sink = ?")
+		args = append(args, f.Sink)
+	}
+	if f.Status != "" {
+		whereClauses = append(whereClauses, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.BeforeID > 0 {
+		whereClauses = append(whereClauses, "id < ?")
+		args = append(args, f.BeforeID)
+	}
+	if len(whereClauses) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(whereClauses, " AND "), args
+}
+
+// GetDeliveries returns the deliveries matching the filter, newest first.
+func GetDeliveries(f DeliveryFilter) ([]models.Delivery, error) {
+	where, args := f.where()
+	query := "SELECT " + deliveryColumns + " FROM deliveries" + where + " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	return queryDeliveries(query, args...)
+}
+
+func queryDeliveries(query string, args ...interface{}) ([]models.Delivery, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []models.Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// GetDelivery returns a delivery, or sql.ErrNoRows.
+func GetDelivery(id int64) (models.Delivery, error) {
+	if db == nil {
+		return models.Delivery{}, fmt.Errorf("database connection is nil")
+	}
+	return scanDelivery(db.QueryRow("SELECT "+deliveryColumns+" FROM deliveries WHERE id = ?", id))
+}
+
+// ReplayDeliveries makes the deliveries matching the filter pending and due
+// now, with their attempts reset. The filter's Limit is ignored. It returns
+// the number of deliveries replayed.
+func ReplayDeliveries(f DeliveryFilter) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	where, args := f.where()
+	args = append([]interface{}{DeliveryPending, time.Now().UTC()}, args...)
+	res, err := db.Exec("UPDATE deliveries SET status = ?, attempts = 0, next_attempt_at = ?"+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to replay deliveries: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// ReplayDelivery makes a delivery pending and due now, with its attempts
+// reset. It returns sql.ErrNoRows if the delivery does not exist.
+func ReplayDelivery(id int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("UPDATE deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ?", DeliveryPending, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteDeliveries deletes the deliveries matching the filter, ignoring its
+// Limit, and returns how many were deleted.
+func DeleteDeliveries(f DeliveryFilter) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	where, args := f.where()
+	res, err := db.Exec("DELETE FROM deliveries"+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete deliveries: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteDelivery deletes a delivery, returning sql.ErrNoRows if it does not exist.
+func DeleteDelivery(id int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM deliveries WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryQueue(t *testing.T) {
+	setupTestDB(t)
+	var ids []int64
+	for _, sink := range []string{"webhook", "splunk", "splunk"} {
+		d, err := EnqueueDelivery(models.Delivery{Sink: sink, Target: "https://hooks.example.com", ContentType: "application/json", Payload: `{"n":1}`})
+		require.NoError(t, err)
+		ids = append(ids, d.ID)
+	}
+	now := time.Now().Add(time.Second)
+
+	due, err := GetDueDeliveries("splunk", now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 2)
+	assert.Equal(t, ids[1], due[0].ID)
+	assert.Equal(t, `{"n":1}`, due[0].Payload)
+
+	due[0].Attempts, due[0].LastError, due[0].NextAttemptAt = 1, "503", now.Add(time.Minute)
+	due[1].Attempts, due[1].LastError, due[1].Status = 8, "503", DeliveryDead
+	require.NoError(t, RescheduleDeliveries(due))
+	due, err = GetDueDeliveries("splunk", now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due, "one is backing off, the other is dead")
+
+	dead, err := GetDeliveries(DeliveryFilter{Status: DeliveryDead})
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Equal(t, "503", dead[0].LastError)
+
+	n, err := ReplayDeliveries(DeliveryFilter{Sink: "splunk", Status: DeliveryDead})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	d, err := GetDelivery(ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, DeliveryPending, d.Status)
+	assert.Zero(t, d.Attempts)
+	require.NoError(t, ReplayDelivery(ids[1]))
+	due, err = GetDueDeliveries("splunk", now, 10)
+	require.NoError(t, err)
+	assert.Len(t, due, 2)
+
+	require.NoError(t, CompleteDeliveries([]int64{ids[1], ids[2]}))
+	page, err := GetDeliveries(DeliveryFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, "webhook", page[0].Sink)
+
+	require.NoError(t, DeleteDelivery(ids[0]))
+	assert.ErrorIs(t, DeleteDelivery(ids[0]), sql.ErrNoRows)
+	assert.ErrorIs(t, ReplayDelivery(ids[0]), sql.ErrNoRows)
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// MaxImpressionBatch caps how many articles one impression report can count.
+const MaxImpressionBatch = 1000
+
+func createEngagementTables() error {
+	createEngagementSQL := `
+	CREATE TABLE IF NOT EXISTS article_engagement (
+		article_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		impressions INTEGER NOT NULL DEFAULT 0,
+		clicks INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (article_id, day)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_engagement_day ON article_engagement (day);
+	`
+	if _, err := db.Exec(createEngagementSQL); err != nil {
+		return fmt.Errorf("failed to create article_engagement table: %v", err)
+	}
+	return nil
+}
+
+// engagementDay is the UTC day engagement is counted under.
+func engagementDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// RecordClick counts a click on an article.
+func RecordClick(articleID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec(`INSERT INTO article_engagement(article_id, day, clicks) VALUES(?, ?, 1)
+		ON CONFLICT(article_id, day) DO UPDATE SET clicks = clicks + 1`, articleID, engagementDay(time.Now()))
+	return err
+}
+
+// RecordImpressions counts an impression of each of the articles of an
+// organization among articleIDs and returns how many were counted. Unknown
+// articles and those of other organizations are ignored.
+func RecordImpressions(orgID int64, articleIDs []int64) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	if len(articleIDs) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(articleIDs)), ", ")
+	args := []interface{}{engagementDay(time.Now()), orgID}
+	for _, id := range articleIDs {
+		args = append(args, id)
+	}
+	res, err := db.Exec(`INSERT INTO article_engagement(article_id, day, impressions)
+		SELECT id, ?, 1 FROM articles WHERE org_id = ? AND `+notDeletedCondition+` AND id IN (`+placeholders+`)
+		ON CONFLICT(article_id, day) DO UPDATE SET impressions = impressions + 1`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// GetEngagementStats sums the impressions and clicks counted on the UTC days
+// from start to end on the articles matching the filter, per source and per
+// category, most clicked first.
+func GetEngagementStats(f ArticleFilter, start, end time.Time) (models.EngagementStats, error) {
+	if db == nil {
+		return models.EngagementStats{}, fmt.Errorf("database connection is nil")
+	}
+	whereClauses, args := f.where()
+	args = append([]interface{}{engagementDay(start), engagementDay(end)}, args...)
+	rows, err := db.Query(`SELECT a.sourceUrl, a.category, SUM(e.impressions), SUM(e.clicks)
+		FROM article_engagement e JOIN articles a ON a.id = e.article_id
+		WHERE e.day >= ? AND e.day <= ? AND a.id IN (SELECT id FROM articles WHERE `+strings.Join(whereClauses, " AND ")+`)
+		GROUP BY a.sourceUrl, a.category`, args...)
+	if err != nil {
+		return models.EngagementStats{}, err
+	}
+	defer rows.Close()
+
+	bySource := map[string]*models.EngagementCount{}
+	byCategory := map[string]*models.EngagementCount{}
+	add := func(groups map[string]*models.EngagementCount, key string, impressions, clicks int) {
+		c, ok := groups[key]
+		if !ok {
+			c = &models.EngagementCount{Key: key}
+			groups[key] = c
+		}
+		c.Impressions += impressions
+		c.Clicks += clicks
+	}
+	var stats models.EngagementStats
+	for rows.Next() {
+		var source, category string
+		var impressions, clicks int
+		if err := rows.Scan(&source, &category, &impressions, &clicks); err != nil {
+			return models.EngagementStats{}, err
+		}
+		stats.Impressions += impressions
+		stats.Clicks += clicks
+		add(bySource, source, impressions, clicks)
+		add(byCategory, category, impressions, clicks)
+	}
+	if err := rows.Err(); err != nil {
+		return models.EngagementStats{}, err
+	}
+	stats.ClickThroughRate = models.ClickThroughRate(stats.Impressions, stats.Clicks)
+	stats.BySource = sortedEngagementCounts(bySource)
+	stats.ByCategory = sortedEngagementCounts(byCategory)
+	return stats, nil
+}
+
+func sortedEngagementCounts(groups map[string]*models.EngagementCount) []models.EngagementCount {
+	counts := make([]models.EngagementCount, 0, len(groups))
+	for _, c := range groups {
+		c.ClickThroughRate = models.ClickThroughRate(c.Impressions, c.Clicks)
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		if counts[i].Impressions != counts[j].Impressions {
+			return counts[i].Impressions > counts[j].Impressions
+		}
+		return counts[i].Key < counts[j].Key
+	})
+	return counts
+}
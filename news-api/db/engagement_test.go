@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngagement(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().Add(-time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "SCADA exploit in the wild", URL: "u1", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: now},
+		{Title: "Startup funding round", URL: "u2", SourceURL: "s2", Category: "Tech", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+	stored, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	idByURL := map[string]int64{}
+	for _, a := range stored {
+		idByURL[a.URL] = a.ID
+	}
+
+	for i := 0; i < 2; i++ {
+		counted, err := RecordImpressions(0, []int64{idByURL["u1"], idByURL["u2"], 9999})
+		require.NoError(t, err)
+		assert.Equal(t, 2, counted)
+	}
+	counted, err := RecordImpressions(1, []int64{idByURL["u1"]})
+	require.NoError(t, err)
+	assert.Zero(t, counted, "articles of other organizations are not counted")
+	require.NoError(t, RecordClick(idByURL["u1"]))
+
+	stats, err := GetEngagementStats(ArticleFilter{}, time.Now().Add(-24*time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Impressions)
+	assert.Equal(t, 1, stats.Clicks)
+	assert.Equal(t, 0.25, stats.ClickThroughRate)
+	assert.Equal(t, []models.EngagementCount{
+		{Key: "s1", Impressions: 2, Clicks: 1, ClickThroughRate: 0.5},
+		{Key: "s2", Impressions: 2},
+	}, stats.BySource)
+	assert.Equal(t, "Cybersecurity", stats.ByCategory[0].Key)
+
+	stats, err = GetEngagementStats(ArticleFilter{Category: "Tech"}, time.Now().Add(-24*time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Impressions)
+	assert.Zero(t, stats.Clicks)
+
+	stats, err = GetEngagementStats(ArticleFilter{}, time.Now().Add(-72*time.Hour), time.Now().Add(-48*time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, stats.Impressions)
+}
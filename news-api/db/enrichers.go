@@ -0,0 +1,111 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"news-api/models"
+)
+
+// Enricher is a self-contained enrichment step, such as entity recognition or
+// translation, run over every article after the built-in steps, both at ingest
+// and when articles are reprocessed.
+type Enricher interface {
+	// Name identifies the step in the enrichment status and its metrics.
+	Name() string
+	// Version is bumped whenever the step's output changes, so that the
+	// reprocessing queue re-runs it over the articles it already processed.
+	Version() int
+	// Process returns the enriched article. On error the article is kept as
+	// it was before the step.
+	Process(article models.NewsArticle) (models.NewsArticle, error)
+}
+
+// EnricherStats are the metrics of a registered enricher since startup.
+type EnricherStats struct {
+	Name      string `json:"name"`
+	Order     int    `json:"order"`
+	Processed int    `json:"processed"`
+	Errors    int    `json:"errors"`
+	AvgMicros int64  `json:"avgMicros"`
+
+	totalTime time.Duration
+}
+
+type registeredEnricher struct {
+	enricher Enricher
+	stats    *EnricherStats
+}
+
+var (
+	enrichersMutex sync.Mutex
+	enrichers      []registeredEnricher
+)
+
+// RegisterEnricher adds an enrichment step. Steps run by ascending order, and
+// in registration order for equal orders. Each step is tracked as an
+// enrichment stage, so articles stored before it was registered are
+// backfilled by the reprocessing queue. Register steps before ingestion
+// starts; registering a name twice panics.
+func RegisterEnricher(order int, enricher Enricher) {
+	name := enricher.Name()
+	for _, stage := range EnrichmentStages {
+		if stage.Name == name {
+			panic(fmt.Sprintf("enrichment stage %q registered twice", name))
+		}
+	}
+	EnrichmentStages = append(EnrichmentStages, EnrichmentStage{Name: name, Version: enricher.Version(), Reprocessable: true})
+
+	enrichersMutex.Lock()
+	defer enrichersMutex.Unlock()
+	// A new slice, so that running steps never see it reordered.
+	steps := append(append([]registeredEnricher{}, enrichers...), registeredEnricher{enricher: enricher, stats: &EnricherStats{Name: name, Order: order}})
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].stats.Order < steps[j].stats.Order })
+	enrichers = steps
+}
+
+// runEnrichers passes an article through the registered enrichers in order.
+func runEnrichers(article models.NewsArticle) models.NewsArticle {
+	enrichersMutex.Lock()
+	steps := enrichers
+	enrichersMutex.Unlock()
+	for _, step := range steps {
+		start := time.Now()
+		enriched, err := step.enricher.Process(article)
+		elapsed := time.Since(start)
+
+		enrichersMutex.Lock()
+		step.stats.Processed++
+		step.stats.totalTime += elapsed
+		if err != nil {
+			step.stats.Errors++
+		}
+		enrichersMutex.Unlock()
+
+		if err != nil {
+			log.Printf("Error enriching article %s with %s: %v", article.URL, step.stats.Name, err)
+			continue
+		}
+		article = enriched
+	}
+	return article
+}
+
+// GetEnricherStats returns the metrics of the registered enrichers, in the
+// order they run.
+func GetEnricherStats() []EnricherStats {
+	enrichersMutex.Lock()
+	defer enrichersMutex.Unlock()
+	stats := make([]EnricherStats, 0, len(enrichers))
+	for _, step := range enrichers {
+		copied := *step.stats
+		if copied.Processed > 0 {
+			copied.AvgMicros = (copied.totalTime / time.Duration(copied.Processed)).Microseconds()
+		}
+		stats = append(stats, copied)
+	}
+	return stats
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+type testEnricher struct {
+	name    string
+	process func(models.NewsArticle) (models.NewsArticle, error)
+}
+
+func (e testEnricher) Name() string { return e.name }
+func (e testEnricher) Version() int { return 2 }
+func (e testEnricher) Process(article models.NewsArticle) (models.NewsArticle, error) {
+	return e.process(article)
+}
+
+func TestRegisterEnricher(t *testing.T) {
+	defer func(stages []EnrichmentStage, steps []registeredEnricher) {
+		EnrichmentStages, enrichers = stages, steps
+	}(EnrichmentStages, enrichers)
+
+	RegisterEnricher(20, testEnricher{name: "shout", process: func(a models.NewsArticle) (models.NewsArticle, error) {
+		a.Title = strings.ToUpper(a.Title)
+		return a, nil
+	}})
+	RegisterEnricher(10, testEnricher{name: "actor", process: func(a models.NewsArticle) (models.NewsArticle, error) {
+		// Runs first, so sees the title as it came from the feed.
+		if strings.Contains(a.Title, "Lazarus") {
+			a.Tags = append(a.Tags, "Lazarus Group")
+		}
+		return a, nil
+	}})
+	RegisterEnricher(30, testEnricher{name: "broken", process: func(a models.NewsArticle) (models.NewsArticle, error) {
+		a.Title = ""
+		return a, errors.New("model unavailable")
+	}})
+	assert.Panics(t, func() { RegisterEnricher(0, testEnricher{name: "ioc"}) })
+
+	article := articleFromItem("https://example.com/feed", &gofeed.Item{Title: "Lazarus targets exchanges", Link: "https://example.com/lazarus"})
+	assert.Equal(t, "LAZARUS TARGETS EXCHANGES", article.Title)
+	assert.Equal(t, []string{"Lazarus Group"}, article.Tags)
+
+	assert.Contains(t, EnrichmentStages, EnrichmentStage{Name: "shout", Version: 2, Reprocessable: true})
+	stats := GetEnricherStats()
+	require.Len(t, stats, 3)
+	assert.Equal(t, []string{"actor", "shout", "broken"}, []string{stats[0].Name, stats[1].Name, stats[2].Name})
+	assert.Equal(t, 1, stats[1].Processed)
+	assert.Zero(t, stats[1].Errors)
+	assert.Equal(t, 1, stats[2].Errors)
+}
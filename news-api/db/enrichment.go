@@ -0,0 +1,218 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// EnrichmentStage is a step of enrichment. Its version is bumped whenever its
+// rules change, which makes the articles enriched by an older version stale:
+// the reprocessing queue re-runs the stage over them.
+type EnrichmentStage struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	// Reprocessable is false for stages that only run at ingest, such as
+	// language detection, which decides whether an article is stored at all.
+	Reprocessable bool `json:"reprocessable"`
+}
+
+// EnrichmentStages are the enrichment steps tracked per article. A new stage,
+// such as summarization, is appended here, and the queue backfills it over the
+// articles stored before it existed.
+var EnrichmentStages = []EnrichmentStage{
+	{Name: "language", Version: 1},
+	{Name: "sanitize", Version: 1, Reprocessable: true},
+	{Name: "rank", Version: 1, Reprocessable: true},
+	// IOC extraction: the automatic tags, CVE mentions and breaches.
+	{Name: "ioc", Version: 1, Reprocessable: true},
+	{Name: "categories", Version: 1, Reprocessable: true},
+}
+
+// StageStatus counts the articles a stage is current and stale for.
+type StageStatus struct {
+	EnrichmentStage
+	Current int `json:"current"`
+	Stale   int `json:"stale"`
+}
+
+// EnrichmentStatus reports the progress of enrichment backfills.
+type EnrichmentStatus struct {
+	// Queued is the number of articles waiting in the reprocessing queue.
+	Queued int           `json:"queued"`
+	Stages []StageStatus `json:"stages"`
+	// Enrichers are the metrics of the registered enrichers.
+	Enrichers []EnricherStats `json:"enrichers,omitempty"`
+}
+
+func createEnrichmentTables() error {
+	createEnrichmentSQL := `
+	CREATE TABLE IF NOT EXISTS article_enrichment (
+		article_id INTEGER NOT NULL,
+		stage TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		processed_at DATETIME NOT NULL,
+		PRIMARY KEY (article_id, stage)
+	);
+	CREATE TABLE IF NOT EXISTS reprocess_queue (
+		article_id INTEGER PRIMARY KEY,
+		enqueued_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createEnrichmentSQL); err != nil {
+		return fmt.Errorf("failed to create enrichment tables: %v", err)
+	}
+	return nil
+}
+
+// recordEnrichment marks the given stages as run over an article at their
+// current versions.
+func recordEnrichment(articleID int64, reprocessedOnly bool) error {
+	now := time.Now().UTC()
+	for _, stage := range EnrichmentStages {
+		if reprocessedOnly && !stage.Reprocessable {
+			continue
+		}
+		if _, err := db.Exec("INSERT OR REPLACE INTO article_enrichment(article_id, stage, version, processed_at) VALUES(?, ?, ?, ?)",
+			articleID, stage.Name, stage.Version, now); err != nil {
+			return fmt.Errorf("failed to record enrichment of article %d: %v", articleID, err)
+		}
+	}
+	return nil
+}
+
+// staleEnrichmentCondition matches the articles, aliased a, that a
+// reprocessable stage never ran over or ran over at an older version.
+func staleEnrichmentCondition() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for _, stage := range EnrichmentStages {
+		if !stage.Reprocessable {
+			continue
+		}
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM article_enrichment e WHERE e.article_id = a.id AND e.stage = ? AND e.version >= ?)")
+		args = append(args, stage.Name, stage.Version)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// EnqueueReprocess queues the articles with an archived feed item for
+// reprocessing: those with stale enrichment, or all of them. It returns the
+// number of articles newly queued.
+func EnqueueReprocess(all bool) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	query := "INSERT OR IGNORE INTO reprocess_queue(article_id, enqueued_at) SELECT a.id, ? FROM articles a " + rawItemJoin
+	args := []interface{}{time.Now().UTC()}
+	if !all {
+		condition, conditionArgs := staleEnrichmentCondition()
+		query += " WHERE " + condition
+		args = append(args, conditionArgs...)
+	}
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue articles for reprocessing: %v", err)
+	}
+	queued, _ := res.RowsAffected()
+	return int(queued), nil
+}
+
+// ProcessReprocessQueue reprocesses up to batch queued articles, oldest first,
+// like ReprocessArticles, and removes them from the queue. It returns the
+// number of articles updated.
+func ProcessReprocessQueue(batch int) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT a.id, a.org_id, a.sourceUrl, COALESCE(a.imageUrl, ''), r.data FROM reprocess_queue q JOIN articles a ON a.id = q.article_id "+rawItemJoin+
+		" ORDER BY q.article_id LIMIT ?", batch)
+	if err != nil {
+		return 0, err
+	}
+	var items []rawItemRow
+	for rows.Next() {
+		var row rawItemRow
+		if err := rows.Scan(&row.article.ID, &row.article.OrgID, &row.article.SourceURL, &row.article.ImageURL, &row.data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		items = append(items, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	updated := 0
+	settings := map[int64]models.OrgSettings{}
+	for _, row := range items {
+		ok, err := reprocessArticle(row, settings)
+		if err != nil {
+			return updated, err
+		}
+		if ok {
+			updated++
+		}
+		if _, err := db.Exec("DELETE FROM reprocess_queue WHERE article_id = ?", row.article.ID); err != nil {
+			return updated, err
+		}
+	}
+	// Articles deleted, or whose archived item is gone, since they were queued.
+	if len(items) < batch {
+		if _, err := db.Exec("DELETE FROM reprocess_queue WHERE article_id NOT IN (SELECT a.id FROM articles a " + rawItemJoin + ")"); err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// StartReprocessQueue queues the articles with stale enrichment, e.g. after a
+// stage was added or changed, and reprocesses batch queued articles after
+// each caching cycle, so that backfills never hold up ingestion for long.
+func StartReprocessQueue(batch int) {
+	if queued, err := EnqueueReprocess(false); err != nil {
+		log.Printf("Error queuing stale articles for reprocessing: %v", err)
+	} else if queued > 0 {
+		log.Printf("Queued %d articles with stale enrichment for reprocessing.", queued)
+	}
+	RegisterCycleHook(func() {
+		if updated, err := ProcessReprocessQueue(batch); err != nil {
+			log.Printf("Error processing the reprocessing queue: %v", err)
+		} else if updated > 0 {
+			log.Printf("Reprocessed %d queued articles.", updated)
+		}
+	})
+}
+
+// GetEnrichmentStatus counts the queued articles and, for each stage, the
+// articles it is current and stale for.
+func GetEnrichmentStatus() (EnrichmentStatus, error) {
+	if db == nil {
+		return EnrichmentStatus{}, fmt.Errorf("database connection is nil")
+	}
+	var status EnrichmentStatus
+	if err := db.QueryRow("SELECT COUNT(*) FROM reprocess_queue").Scan(&status.Queued); err != nil {
+		return status, err
+	}
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM articles").Scan(&total); err != nil {
+		return status, err
+	}
+	for _, stage := range EnrichmentStages {
+		s := StageStatus{EnrichmentStage: stage}
+		if err := db.QueryRow("SELECT COUNT(*) FROM article_enrichment WHERE stage = ? AND version >= ? AND article_id IN (SELECT id FROM articles)",
+			stage.Name, stage.Version).Scan(&s.Current); err != nil {
+			return status, err
+		}
+		s.Stale = total - s.Current
+		status.Stages = append(status.Stages, s)
+	}
+	status.Enrichers = GetEnricherStats()
+	return status, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+func TestReprocessQueue(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM raw_items")
+	require.NoError(t, err)
+
+	const source = "https://www.bleepingcomputer.com/feed/"
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stale := &gofeed.Item{Title: "Zero-day exploited by APT41", Link: "https://news.example/zero-day", GUID: "zd-1", PublishedParsed: &published}
+	current := &gofeed.Item{Title: "Ransomware hits hospital", Link: "https://news.example/ransomware", GUID: "rw-1", PublishedParsed: &published}
+	// Stored before enrichment was tracked.
+	_, err = db.Exec("INSERT INTO articles(title, description, imageUrl, url, sourceUrl, publishedAt, rank, category, guid) VALUES(?, '', '', ?, ?, ?, 0, 'Tech', ?)",
+		"old title", stale.Link, source, published, stale.GUID)
+	require.NoError(t, err)
+	_, err = insertArticle(models.NewsArticle{Title: current.Title, URL: current.Link, GUID: current.GUID, SourceURL: source, PublishedAt: published})
+	require.NoError(t, err)
+	require.NoError(t, archiveRawItem(source, stale))
+	require.NoError(t, archiveRawItem(source, current))
+
+	queued, err := EnqueueReprocess(false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued)
+	status, err := GetEnrichmentStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Queued)
+	require.Len(t, status.Stages, len(EnrichmentStages))
+	for _, stage := range status.Stages {
+		assert.Equal(t, 1, stage.Current, stage.Name)
+		assert.Equal(t, 1, stage.Stale, stage.Name)
+	}
+
+	updated, err := ProcessReprocessQueue(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	articles, err := QueryArticles(ArticleFilter{Search: "APT41"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, []string{"APT41"}, articles[0].Tags)
+
+	status, err = GetEnrichmentStatus()
+	require.NoError(t, err)
+	assert.Zero(t, status.Queued)
+	for _, stage := range status.Stages {
+		if stage.Reprocessable {
+			assert.Zero(t, stage.Stale, stage.Name)
+		} else {
+			// Ingest-only stages are never backfilled.
+			assert.Equal(t, 1, stage.Stale, stage.Name)
+		}
+	}
+	queued, err = EnqueueReprocess(false)
+	require.NoError(t, err)
+	assert.Zero(t, queued)
+
+	// A new version of a stage makes every article stale again.
+	defer func(stages []EnrichmentStage) { EnrichmentStages = stages }(EnrichmentStages)
+	EnrichmentStages = append(append([]EnrichmentStage{}, EnrichmentStages...), EnrichmentStage{Name: "summary", Version: 1, Reprocessable: true})
+	queued, err = EnqueueReprocess(false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+	queued, err = EnqueueReprocess(true)
+	require.NoError(t, err)
+	assert.Zero(t, queued, "already queued")
+
+	updated, err = ProcessReprocessQueue(1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+	status, err = GetEnrichmentStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Queued)
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// KeywordHit is a ranking rule that matched an article.
+type KeywordHit struct {
+	Keyword string `json:"keyword"`
+	Weight  int    `json:"weight"`
+	// Rule is "category" for the category's keywords, "keyword" for an
+	// organization's keyword rules, "watchlist" for its watchlist terms and
+	// "advisory" for the vendor severity of a security advisory.
+	Rule string `json:"rule"`
+}
+
+// ExplainedArticle is an article counted by the threat score with the keyword
+// hits behind its rank.
+type ExplainedArticle struct {
+	ID       int64        `json:"id"`
+	Title    string       `json:"title"`
+	URL      string       `json:"url"`
+	Category string       `json:"category"`
+	Rank     int          `json:"rank"`
+	Severity int          `json:"severity"`
+	Hits     []KeywordHit `json:"hits"`
+}
+
+// ThreatExplanation is a threat score with the articles counted in each bucket,
+// most severe first.
+type ThreatExplanation struct {
+	ThreatScore
+	High   []ExplainedArticle `json:"high"`
+	Medium []ExplainedArticle `json:"medium"`
+	Low    []ExplainedArticle `json:"low"`
+}
+
+// rankHits returns the rules that match an article under the current ranking:
+// its category's keywords, the organization's keyword rules and watchlist
+// terms, and the vendor severity of advisories, strongest first. Their weights
+// add up to the article's rank.
+func rankHits(article models.NewsArticle, settings models.OrgSettings) []KeywordHit {
+	content := strings.ToLower(article.Title + " " + article.Description)
+	var hits []KeywordHit
+	for keyword, weight := range categoryKeywords(article.Category) {
+		if strings.Contains(content, keyword) {
+			hits = append(hits, KeywordHit{Keyword: keyword, Weight: weight, Rule: "category"})
+		}
+	}
+	for keyword, weight := range settings.Keywords {
+		if strings.Contains(content, keyword) {
+			hits = append(hits, KeywordHit{Keyword: keyword, Weight: weight, Rule: "keyword"})
+		}
+	}
+	for _, term := range settings.Watchlist {
+		if strings.Contains(content, term) {
+			hits = append(hits, KeywordHit{Keyword: term, Weight: watchlistWeight, Rule: "watchlist"})
+		}
+	}
+	if hit, ok := advisoryHit(article); ok {
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Weight != hits[j].Weight {
+			return hits[i].Weight > hits[j].Weight
+		}
+		return hits[i].Keyword < hits[j].Keyword
+	})
+	return hits
+}
+
+// GetOrgThreatExplanation returns the threat score of an organization together
+// with the articles behind it. Ranks and severities are the stored ones; hits
+// are computed with the current rules, so they may not add up to the rank of
+// articles stored before the rules changed.
+func GetOrgThreatExplanation(orgID int64) (ThreatExplanation, error) {
+	if db == nil {
+		return ThreatExplanation{}, fmt.Errorf("database connection is nil")
+	}
+	var settings models.OrgSettings
+	if orgID != 0 {
+		var err error
+		if settings, err = GetOrgSettings(orgID); err != nil {
+			return ThreatExplanation{}, err
+		}
+	}
+
+	cutoff := time.Now().Add(-24 * time.Hour)
+	articles, err := QueryArticles(ArticleFilter{OrgID: orgID, StartDate: cutoff, ByIngestion: true, SortBy: "severity"})
+	if err != nil {
+		return ThreatExplanation{}, err
+	}
+
+	explanation := ThreatExplanation{High: []ExplainedArticle{}, Medium: []ExplainedArticle{}, Low: []ExplainedArticle{}}
+	var tally threatTally
+	for _, article := range articles {
+		if article.PublishedAt.Before(cutoff) {
+			continue
+		}
+		explained := ExplainedArticle{
+			ID:       article.ID,
+			Title:    article.Title,
+			URL:      article.URL,
+			Category: article.Category,
+			Rank:     article.Rank,
+			Severity: article.Severity,
+			Hits:     rankHits(article, settings),
+		}
+		switch tally.add(ThreatArticle{Title: article.Title, URL: article.URL, Category: article.Category, Severity: article.Severity}) {
+		case bucketHigh:
+			explanation.High = append(explanation.High, explained)
+		case bucketMedium:
+			explanation.Medium = append(explanation.Medium, explained)
+		default:
+			explanation.Low = append(explanation.Low, explained)
+		}
+	}
+	explanation.ThreatScore = tally.score()
+	if err := addThreatTrend(orgID, &explanation.ThreatScore, cutoff); err != nil {
+		return ThreatExplanation{}, err
+	}
+	return explanation, applyThreatOverride(orgID, &explanation.ThreatScore)
+}
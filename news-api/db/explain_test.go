@@ -0,0 +1,63 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetOrgThreatExplanation(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	now := time.Now()
+	for _, article := range []models.NewsArticle{
+		{Title: "Zero-day ransomware attack", URL: "u1", Category: "Cybersecurity", PublishedAt: now.Add(-time.Hour)},
+		{Title: "Phishing wave", URL: "u2", Category: "Cybersecurity", PublishedAt: now.Add(-2 * time.Hour)},
+		{Title: "Quiet day", URL: "u3", Category: "Cybersecurity", PublishedAt: now.Add(-3 * time.Hour)},
+		{Title: "Old zero-day", URL: "u4", Category: "Cybersecurity", PublishedAt: now.Add(-48 * time.Hour)},
+	} {
+		article.Rank = calculateRank(article)
+		require.NoError(t, InsertArticle(article))
+	}
+
+	explanation, err := GetOrgThreatExplanation(0)
+	require.NoError(t, err)
+	assert.Equal(t, "Code Red", explanation.ThreatLevel)
+	assert.Equal(t, 3, explanation.TotalArticles)
+
+	require.Len(t, explanation.High, 1)
+	high := explanation.High[0]
+	assert.Equal(t, "Zero-day ransomware attack", high.Title)
+	assert.Equal(t, 16, high.Rank)
+	assert.Equal(t, []KeywordHit{
+		{Keyword: "ransomware attack", Weight: 5, Rule: "category"},
+		{Keyword: "zero-day", Weight: 5, Rule: "category"},
+		{Keyword: "attack", Weight: 3, Rule: "category"},
+		{Keyword: "ransomware", Weight: 3, Rule: "category"},
+	}, high.Hits)
+
+	require.Len(t, explanation.Medium, 1)
+	assert.Equal(t, "Phishing wave", explanation.Medium[0].Title)
+	require.Len(t, explanation.Low, 1)
+	assert.Empty(t, explanation.Low[0].Hits)
+
+	score, err := GetOrgThreatScore(0)
+	require.NoError(t, err)
+	assert.Equal(t, score, explanation.ThreatScore)
+}
+
+func TestRankHitsIncludeOrgRules(t *testing.T) {
+	article := models.NewsArticle{Title: "Fortinet breach", Category: "Cybersecurity"}
+	settings := models.OrgSettings{Keywords: map[string]int{"breach": 2}, Watchlist: []string{"fortinet"}}
+	hits := rankHits(article, settings)
+	assert.Equal(t, []KeywordHit{
+		{Keyword: "fortinet", Weight: 5, Rule: "watchlist"},
+		{Keyword: "breach", Weight: 3, Rule: "category"},
+		{Keyword: "breach", Weight: 2, Rule: "keyword"},
+	}, hits)
+	assert.Equal(t, 10, scoreForOrg(article, settings))
+}
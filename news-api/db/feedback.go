@@ -0,0 +1,277 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// Feedback votes.
+const (
+	VoteUp   = 1
+	VoteDown = -1
+)
+
+const (
+	// maxFeedbackWeight bounds the rank adjustment the feedback model gives
+	// a single term and a whole article.
+	maxFeedbackWeight = 20
+	// feedbackMinVotes is how many votes a term needs before the model weighs it.
+	feedbackMinVotes = 3
+	// feedbackPrior damps the weights of terms with few votes.
+	feedbackPrior = 2
+	// feedbackModelTerms caps how many terms a model keeps, strongest first.
+	feedbackModelTerms = 500
+)
+
+// FeedbackModelTTL is how long a learned feedback model is reused before it
+// is learned again from the votes.
+var FeedbackModelTTL = 10 * time.Minute
+
+func createFeedbackTables() error {
+	createFeedbackSQL := `
+	CREATE TABLE IF NOT EXISTS article_feedback (
+		user_id INTEGER NOT NULL,
+		article_id INTEGER NOT NULL,
+		vote INTEGER NOT NULL,
+		voted_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, article_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_feedback_article ON article_feedback (article_id);
+	`
+	if _, err := db.Exec(createFeedbackSQL); err != nil {
+		return fmt.Errorf("failed to create article_feedback table: %v", err)
+	}
+	forgetFeedbackModels()
+	return nil
+}
+
+// SetFeedback records a user's relevance vote on an article of their
+// organization, VoteUp or VoteDown, replacing any earlier vote. A vote of 0
+// retracts it. It returns sql.ErrNoRows if the user cannot see the article.
+func SetFeedback(userID, articleID int64, vote int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if vote == 0 {
+		_, err := db.Exec("DELETE FROM article_feedback WHERE user_id = ? AND article_id = ?", userID, articleID)
+		return err
+	}
+	if vote != VoteUp && vote != VoteDown {
+		return fmt.Errorf("invalid vote %d", vote)
+	}
+	var visible int
+	err := db.QueryRow("SELECT 1 FROM articles a JOIN users u ON u.id = ? WHERE a.id = ? AND a.org_id = u.org_id AND a.deleted_at IS NULL", userID, articleID).Scan(&visible)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO article_feedback(user_id, article_id, vote, voted_at) VALUES(?, ?, ?, ?)
+		ON CONFLICT(user_id, article_id) DO UPDATE SET vote = excluded.vote, voted_at = excluded.voted_at`,
+		userID, articleID, vote, time.Now().UTC())
+	return err
+}
+
+// GetFeedbackStats aggregates the votes on the articles matching the filter:
+// the totals and the votes per source and per category, most votes first.
+func GetFeedbackStats(f ArticleFilter) (models.FeedbackStats, error) {
+	if db == nil {
+		return models.FeedbackStats{}, fmt.Errorf("database connection is nil")
+	}
+	whereClauses, args := f.where()
+	rows, err := db.Query(`SELECT a.sourceUrl, a.category,
+			SUM(CASE WHEN fb.vote > 0 THEN 1 ELSE 0 END), SUM(CASE WHEN fb.vote < 0 THEN 1 ELSE 0 END)
+		FROM article_feedback fb JOIN articles a ON a.id = fb.article_id
+		WHERE a.id IN (SELECT id FROM articles WHERE `+strings.Join(whereClauses, " AND ")+`)
+		GROUP BY a.sourceUrl, a.category`, args...)
+	if err != nil {
+		return models.FeedbackStats{}, err
+	}
+	defer rows.Close()
+
+	bySource := map[string]*models.FeedbackCount{}
+	byCategory := map[string]*models.FeedbackCount{}
+	add := func(groups map[string]*models.FeedbackCount, key string, up, down int) {
+		c, ok := groups[key]
+		if !ok {
+			c = &models.FeedbackCount{Key: key}
+			groups[key] = c
+		}
+		c.Up += up
+		c.Down += down
+	}
+	var stats models.FeedbackStats
+	for rows.Next() {
+		var source, category string
+		var up, down int
+		if err := rows.Scan(&source, &category, &up, &down); err != nil {
+			return models.FeedbackStats{}, err
+		}
+		stats.Up += up
+		stats.Down += down
+		add(bySource, source, up, down)
+		add(byCategory, category, up, down)
+	}
+	if err := rows.Err(); err != nil {
+		return models.FeedbackStats{}, err
+	}
+	stats.BySource = sortedFeedbackCounts(bySource)
+	stats.ByCategory = sortedFeedbackCounts(byCategory)
+	return stats, nil
+}
+
+func sortedFeedbackCounts(groups map[string]*models.FeedbackCount) []models.FeedbackCount {
+	counts := make([]models.FeedbackCount, 0, len(groups))
+	for _, c := range groups {
+		counts = append(counts, *c)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if ti, tj := counts[i].Up+counts[i].Down, counts[j].Up+counts[j].Down; ti != tj {
+			return ti > tj
+		}
+		return counts[i].Key < counts[j].Key
+	})
+	return counts
+}
+
+// FeedbackModel holds the rank adjustments learned from an organization's
+// votes, per stemmed term.
+type FeedbackModel map[string]int
+
+type cachedFeedbackModel struct {
+	model     FeedbackModel
+	learnedAt time.Time
+}
+
+var (
+	feedbackModelsMutex sync.Mutex
+	feedbackModels      = map[int64]cachedFeedbackModel{}
+)
+
+// GetFeedbackModel returns the feedback model of an organization, learning
+// it again from the votes on its articles once it is older than
+// FeedbackModelTTL.
+func GetFeedbackModel(orgID int64) (FeedbackModel, error) {
+	feedbackModelsMutex.Lock()
+	defer feedbackModelsMutex.Unlock()
+	if cached, ok := feedbackModels[orgID]; ok && time.Since(cached.learnedAt) < FeedbackModelTTL {
+		return cached.model, nil
+	}
+	model, err := learnFeedbackModel(orgID)
+	if err != nil {
+		return nil, err
+	}
+	feedbackModels[orgID] = cachedFeedbackModel{model: model, learnedAt: time.Now()}
+	return model, nil
+}
+
+// learnFeedbackModel weighs each term of the voted articles by the balance of
+// up and down votes on the articles using it, damped by feedbackPrior.
+func learnFeedbackModel(orgID int64) (FeedbackModel, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT a.title, COALESCE(a.description, ''), SUM(fb.vote), COUNT(*)
+		FROM article_feedback fb JOIN articles a ON a.id = fb.article_id
+		WHERE a.org_id = ?
+		GROUP BY a.id`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type termVotes struct{ balance, votes int }
+	terms := map[string]*termVotes{}
+	for rows.Next() {
+		var title, description string
+		var balance, votes int
+		if err := rows.Scan(&title, &description, &balance, &votes); err != nil {
+			return nil, err
+		}
+		for stem := range articleStems(models.NewsArticle{Title: title, Description: description}) {
+			t, ok := terms[stem]
+			if !ok {
+				t = &termVotes{}
+				terms[stem] = t
+			}
+			t.balance += balance
+			t.votes += votes
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	type weighted struct {
+		stem   string
+		weight int
+	}
+	var candidates []weighted
+	for stem, t := range terms {
+		if t.votes < feedbackMinVotes {
+			continue
+		}
+		weight := int(math.Round(maxFeedbackWeight * float64(t.balance) / float64(t.votes+feedbackPrior)))
+		if weight != 0 {
+			candidates = append(candidates, weighted{stem, weight})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if ai, aj := abs(candidates[i].weight), abs(candidates[j].weight); ai != aj {
+			return ai > aj
+		}
+		return candidates[i].stem < candidates[j].stem
+	})
+	if len(candidates) > feedbackModelTerms {
+		candidates = candidates[:feedbackModelTerms]
+	}
+	model := make(FeedbackModel, len(candidates))
+	for _, c := range candidates {
+		model[c.stem] = c.weight
+	}
+	return model, nil
+}
+
+// Apply adds the model's adjustment for the terms of each article's title
+// and description to its rank, bounded by maxFeedbackWeight per article.
+func (m FeedbackModel) Apply(articles []models.NewsArticle) {
+	if len(m) == 0 {
+		return
+	}
+	for i := range articles {
+		adjustment := 0
+		for stem := range articleStems(articles[i]) {
+			adjustment += m[stem]
+		}
+		articles[i].Rank += max(-maxFeedbackWeight, min(maxFeedbackWeight, adjustment))
+	}
+}
+
+// articleStems returns the distinct stemmed terms of an article's title and description.
+func articleStems(article models.NewsArticle) map[string]bool {
+	stems := map[string]bool{}
+	for _, term := range extract.Terms(article.Title + " " + article.Description) {
+		stems[extract.Stem(term)] = true
+	}
+	return stems
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// forgetFeedbackModels drops the cached models, which belong to the previous
+// database when it is opened again.
+func forgetFeedbackModels() {
+	feedbackModelsMutex.Lock()
+	feedbackModels = map[int64]cachedFeedbackModel{}
+	feedbackModelsMutex.Unlock()
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedback(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().Add(-time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "SCADA exploit in the wild", URL: "u1", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: now},
+		{Title: "New SCADA vulnerability", URL: "u2", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: now},
+		{Title: "Startup funding round", URL: "u3", SourceURL: "s2", Category: "Tech", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+	stored, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	idByURL := map[string]int64{}
+	for _, a := range stored {
+		idByURL[a.URL] = a.ID
+	}
+	ids := []int64{idByURL["u1"], idByURL["u2"], idByURL["u3"]}
+	var users []int64
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := CreateUser(name, "correct horse battery")
+		require.NoError(t, err)
+		users = append(users, user.ID)
+	}
+	for _, user := range users {
+		require.NoError(t, SetFeedback(user, ids[0], VoteUp))
+		require.NoError(t, SetFeedback(user, ids[1], VoteUp))
+		require.NoError(t, SetFeedback(user, ids[2], VoteDown))
+	}
+	// A second vote replaces the first, and 0 retracts it.
+	require.NoError(t, SetFeedback(users[0], ids[2], VoteUp))
+	require.NoError(t, SetFeedback(users[0], ids[2], 0))
+	assert.ErrorIs(t, SetFeedback(users[0], 9999, VoteUp), sql.ErrNoRows)
+
+	stats, err := GetFeedbackStats(ArticleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 6, stats.Up)
+	assert.Equal(t, 2, stats.Down)
+	assert.Equal(t, []models.FeedbackCount{{Key: "s1", Up: 6}, {Key: "s2", Down: 2}}, stats.BySource)
+	assert.Equal(t, []models.FeedbackCount{{Key: "Cybersecurity", Up: 6}, {Key: "Tech", Down: 2}}, stats.ByCategory)
+
+	model, err := GetFeedbackModel(0)
+	require.NoError(t, err)
+	assert.Positive(t, model["scada"])
+	_, weighted := model["funding"]
+	assert.False(t, weighted, "funding has too few votes")
+
+	articles := []models.NewsArticle{{Title: "SCADA patch released", Rank: 5}, {Title: "Phone review", Rank: 5}}
+	model.Apply(articles)
+	assert.Greater(t, articles[0].Rank, 5)
+	assert.Equal(t, 5, articles[1].Rank)
+}
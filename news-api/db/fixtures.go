@@ -0,0 +1,239 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/models"
+)
+
+// FeedFixtureDir is the directory where recorded feed responses are saved.
+// Each recording is a body file, <name>.xml, next to a metadata file,
+// <name>.json, so that the body can be edited to narrow down a bug.
+var FeedFixtureDir = "fixtures"
+
+// maxFixtureBytes bounds the size of a recorded feed body.
+const maxFixtureBytes = 16 << 20
+
+// ErrUnrecordableSource is returned when asked to record a source that is not
+// fetched as a plain RSS or Atom feed, such as paste sites, advisories and
+// newsletters.
+var ErrUnrecordableSource = errors.New("only RSS and Atom sources can be recorded")
+
+var fixtureNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+func createFixtureTables() error {
+	createRecordingsSQL := `
+	CREATE TABLE IF NOT EXISTS feed_recordings (
+		source_url TEXT PRIMARY KEY,
+		remaining INTEGER NOT NULL,
+		requested_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createRecordingsSQL); err != nil {
+		return fmt.Errorf("failed to create feed_recordings table: %v", err)
+	}
+	return nil
+}
+
+func recordableSource(source string) bool {
+	return source != NewsletterSource && !isPasteSource(source) && !isGHSASource(source) && !isAdvisorySource(source)
+}
+
+// RecordFeed saves the responses of the next fetches of a source as fixtures.
+// Asking again replaces the number of fetches left to record.
+func RecordFeed(source string, fetches int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if !recordableSource(source) {
+		return ErrUnrecordableSource
+	}
+	_, err := db.Exec(`INSERT INTO feed_recordings(source_url, remaining, requested_at) VALUES(?, ?, ?)
+		ON CONFLICT(source_url) DO UPDATE SET remaining = excluded.remaining, requested_at = excluded.requested_at`,
+		source, fetches, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to request a recording of %s: %v", source, err)
+	}
+	return nil
+}
+
+// takeRecording reports whether the current fetch of a source is to be
+// recorded, counting it against the fetches left to record.
+func takeRecording(source string) bool {
+	result, err := db.Exec("UPDATE feed_recordings SET remaining = remaining - 1 WHERE source_url = ? AND remaining > 0", source)
+	if err != nil {
+		log.Printf("Error checking for a recording of %s: %v", source, err)
+		return false
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false
+	}
+	if _, err := db.Exec("DELETE FROM feed_recordings WHERE source_url = ? AND remaining <= 0", source); err != nil {
+		log.Printf("Error clearing the recording of %s: %v", source, err)
+	}
+	return true
+}
+
+// GetFeedRecordings returns the recordings still to be made, oldest request
+// first.
+func GetFeedRecordings() ([]models.FeedRecording, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT source_url, remaining, requested_at FROM feed_recordings ORDER BY requested_at, source_url")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	recordings := []models.FeedRecording{}
+	for rows.Next() {
+		var r models.FeedRecording
+		if err := rows.Scan(&r.SourceURL, &r.Remaining, &r.RequestedAt); err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, r)
+	}
+	return recordings, rows.Err()
+}
+
+// fetchFeed fetches and parses a plain RSS or Atom feed. With record, the
+// response is saved as a fixture if a recording of the source was requested.
+func fetchFeed(fp *gofeed.Parser, source string, record bool) (*gofeed.Feed, error) {
+	if !record || !takeRecording(source) {
+		return fp.ParseURL(source)
+	}
+	resp, err := fp.Client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFixtureBytes))
+	if err != nil {
+		return nil, err
+	}
+	fixture := models.FeedFixture{
+		SourceURL:   source,
+		RecordedAt:  time.Now().UTC(),
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if fixture, err = saveFeedFixture(fixture, body); err != nil {
+		log.Printf("Error saving the recording of %s: %v", source, err)
+	} else {
+		log.Printf("Recorded %s as fixture %s.", source, fixture.Name)
+	}
+	return parseRecordedFeed(fp, fixture, body)
+}
+
+// parseRecordedFeed parses a recorded response like gofeed's ParseURL.
+func parseRecordedFeed(fp *gofeed.Parser, fixture models.FeedFixture, body []byte) (*gofeed.Feed, error) {
+	if fixture.StatusCode < 200 || fixture.StatusCode >= 300 {
+		return nil, gofeed.HTTPError{StatusCode: fixture.StatusCode, Status: fixture.Status}
+	}
+	return fp.Parse(bytes.NewReader(body))
+}
+
+// saveFeedFixture writes a recorded response to FeedFixtureDir under a name
+// made of its source and time.
+func saveFeedFixture(fixture models.FeedFixture, body []byte) (models.FeedFixture, error) {
+	if err := os.MkdirAll(FeedFixtureDir, 0o755); err != nil {
+		return fixture, err
+	}
+	slug := strings.Trim(fixtureNameUnsafe.ReplaceAllString(strings.TrimPrefix(strings.TrimPrefix(fixture.SourceURL, "https://"), "http://"), "-"), "-")
+	if len(slug) > 80 {
+		slug = slug[:80]
+	}
+	fixture.Name = slug + "-" + fixture.RecordedAt.Format("20060102T150405.000Z")
+	fixture.Size = len(body)
+	meta, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fixture, err
+	}
+	if err := os.WriteFile(filepath.Join(FeedFixtureDir, fixture.Name+".xml"), body, 0o644); err != nil {
+		return fixture, err
+	}
+	return fixture, os.WriteFile(filepath.Join(FeedFixtureDir, fixture.Name+".json"), meta, 0o644)
+}
+
+// GetFeedFixtures lists the recorded fixtures, newest first.
+func GetFeedFixtures() ([]models.FeedFixture, error) {
+	paths, err := filepath.Glob(filepath.Join(FeedFixtureDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	fixtures := []models.FeedFixture{}
+	for _, path := range paths {
+		fixture, err := readFixtureMeta(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			log.Printf("Skipping unreadable fixture %s: %v", path, err)
+			continue
+		}
+		fixtures = append(fixtures, fixture)
+	}
+	sort.Slice(fixtures, func(i, j int) bool { return fixtures[i].RecordedAt.After(fixtures[j].RecordedAt) })
+	return fixtures, nil
+}
+
+func readFixtureMeta(name string) (models.FeedFixture, error) {
+	var fixture models.FeedFixture
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fixture, os.ErrNotExist
+	}
+	data, err := os.ReadFile(filepath.Join(FeedFixtureDir, name+".json"))
+	if err != nil {
+		return fixture, err
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("invalid fixture metadata: %v", err)
+	}
+	fixture.Name = name
+	return fixture, nil
+}
+
+// LoadFeedFixture returns a recorded fixture and its body. It returns an
+// error satisfying errors.Is(err, os.ErrNotExist) if there is none by name.
+func LoadFeedFixture(name string) (models.FeedFixture, []byte, error) {
+	fixture, err := readFixtureMeta(name)
+	if err != nil {
+		return fixture, nil, err
+	}
+	body, err := os.ReadFile(filepath.Join(FeedFixtureDir, name+".xml"))
+	return fixture, body, err
+}
+
+// ReplayFeedFixture runs a recorded response through the caching pipeline in
+// place of fetching its source, and reports what it stored, or with dryRun
+// what it would store. Unlike a caching cycle, a replay processes every item
+// regardless of the source's cursor, and leaves the cursor, the source's
+// health and the cycle hooks alone.
+func ReplayFeedFixture(name string, dryRun bool) (IngestReport, error) {
+	if db == nil {
+		return IngestReport{}, fmt.Errorf("database connection is nil")
+	}
+	fixture, body, err := LoadFeedFixture(name)
+	if err != nil {
+		return IngestReport{}, err
+	}
+	replay := map[string]recordedFeed{fixture.SourceURL: {fixture: fixture, body: body}}
+	return ingest([]string{fixture.SourceURL}, ingestOptions{dryRun: dryRun, replay: replay}), nil
+}
+
+// recordedFeed is a fixture replayed in place of fetching its source.
+type recordedFeed struct {
+	fixture models.FeedFixture
+	body    []byte
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplayFeed(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM source_seen_items")
+	require.NoError(t, err)
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+	defer func(dir string) { FeedFixtureDir = dir }(FeedFixtureDir)
+	FeedFixtureDir = t.TempDir()
+
+	items := []string{
+		itemXML("a", "Hackers breach a water utility network", "Mon, 29 Apr 2024 10:00:00 GMT"),
+		itemXML("b", "Ransomware gang leaks hospital patient records", "Wed, 01 May 2024 10:00:00 GMT"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>`)
+		for _, item := range items {
+			fmt.Fprint(w, item)
+		}
+		fmt.Fprint(w, `</channel></rss>`)
+	}))
+	defer server.Close()
+	source := server.URL + "/feed"
+
+	assert.ErrorIs(t, RecordFeed(NewsletterSource, 1), ErrUnrecordableSource)
+	require.NoError(t, RecordFeed(source, 1))
+	recordings, err := GetFeedRecordings()
+	require.NoError(t, err)
+	require.Len(t, recordings, 1)
+	assert.Equal(t, 1, recordings[0].Remaining)
+
+	fetchAndCacheNews([]string{source})
+	fetchAndCacheNews([]string{source})
+	fixtures, err := GetFeedFixtures()
+	require.NoError(t, err)
+	require.Len(t, fixtures, 1, "only the requested fetches are recorded")
+	assert.Equal(t, source, fixtures[0].SourceURL)
+	assert.Equal(t, http.StatusOK, fixtures[0].StatusCode)
+	recordings, err = GetFeedRecordings()
+	require.NoError(t, err)
+	assert.Empty(t, recordings)
+
+	// The replay processes the recorded items even though they were seen
+	// before, and finds them stored already.
+	items = nil
+	report, err := ReplayFeedFixture(fixtures[0].Name, true)
+	require.NoError(t, err)
+	require.Len(t, report.Sources, 1)
+	assert.Equal(t, 2, report.Sources[0].Items)
+	assert.Equal(t, 0, report.Sources[0].Seen)
+	assert.Equal(t, 2, report.Duplicates)
+
+	_, err = db.Exec("DELETE FROM articles WHERE guid = 'a'")
+	require.NoError(t, err)
+	report, err = ReplayFeedFixture(fixtures[0].Name, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.New)
+	count, err := GetArticleCount()
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = ReplayFeedFixture("../"+fixtures[0].Name, true)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,262 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/models"
+)
+
+// GitHubToken authenticates the GitHub GraphQL API calls of GHSA ingestion.
+// Any token works; the advisory database needs no scopes.
+var GitHubToken string
+
+// ghsaEndpoint is the GitHub GraphQL API, replaced by tests.
+var ghsaEndpoint = "https://api.github.com/graphql"
+
+const (
+	// ghsaBatch is the number of the most recently updated vulnerable
+	// packages fetched per ecosystem in each cycle.
+	ghsaBatch = 100
+	// ghsaSourcePrefix starts the source URL of each ecosystem, which is the
+	// ecosystem's page of the GitHub Advisory Database.
+	ghsaSourcePrefix = "https://github.com/advisories?query=type%3Areviewed+ecosystem%3A"
+	// ghsaCategory is where advisories are stored: they concern the packages
+	// engineers depend on more than the threats the Cybersecurity feeds follow.
+	ghsaCategory = "Tech"
+)
+
+// ghsaEcosystemNames map the accepted ecosystem names to the values of the
+// GraphQL SecurityAdvisoryEcosystem enum.
+var ghsaEcosystemNames = map[string]string{
+	"go":        "GO",
+	"npm":       "NPM",
+	"pypi":      "PIP",
+	"pip":       "PIP",
+	"maven":     "MAVEN",
+	"nuget":     "NUGET",
+	"rubygems":  "RUBYGEMS",
+	"composer":  "COMPOSER",
+	"rust":      "RUST",
+	"crates.io": "RUST",
+	"erlang":    "ERLANG",
+	"actions":   "ACTIONS",
+	"pub":       "PUB",
+	"swift":     "SWIFT",
+}
+
+// ghsaSources maps the source URL of each ingested ecosystem to its enum
+// value.
+var ghsaSources = map[string]string{}
+
+const ghsaQuery = `query($ecosystem: SecurityAdvisoryEcosystem!, $first: Int!) {
+  securityVulnerabilities(ecosystem: $ecosystem, first: $first, orderBy: {field: UPDATED_AT, direction: DESC}) {
+    nodes {
+      package { name }
+      vulnerableVersionRange
+      firstPatchedVersion { identifier }
+      advisory {
+        ghsaId summary description permalink severity publishedAt withdrawnAt
+        cvss { score }
+        identifiers { type value }
+      }
+    }
+  }
+}`
+
+// SetGHSAEcosystems selects the ecosystems of the GitHub Advisory Database
+// to ingest, such as go, npm and pypi. It must be called before the first
+// caching cycle.
+func SetGHSAEcosystems(names []string) error {
+	sources := map[string]string{}
+	for _, name := range names {
+		ecosystem, ok := ghsaEcosystemNames[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return fmt.Errorf("unknown ecosystem %q", name)
+		}
+		sources[ghsaSourcePrefix+strings.ToLower(ecosystem)] = ecosystem
+	}
+	ghsaSources = sources
+	return nil
+}
+
+// GHSASources returns the source URLs of the selected ecosystems, to be
+// fetched with the feeds.
+func GHSASources() []string {
+	sources := make([]string, 0, len(ghsaSources))
+	for source := range ghsaSources {
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func isGHSASource(source string) bool {
+	_, ok := ghsaSources[source]
+	return ok
+}
+
+// ghsaResponse is the GraphQL response to ghsaQuery.
+type ghsaResponse struct {
+	Data struct {
+		SecurityVulnerabilities struct {
+			Nodes []struct {
+				Package struct {
+					Name string `json:"name"`
+				} `json:"package"`
+				VulnerableVersionRange string `json:"vulnerableVersionRange"`
+				FirstPatchedVersion    *struct {
+					Identifier string `json:"identifier"`
+				} `json:"firstPatchedVersion"`
+				Advisory ghsaAdvisory `json:"advisory"`
+			} `json:"nodes"`
+		} `json:"securityVulnerabilities"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type ghsaAdvisory struct {
+	GHSAID      string     `json:"ghsaId"`
+	Summary     string     `json:"summary"`
+	Description string     `json:"description"`
+	Permalink   string     `json:"permalink"`
+	Severity    string     `json:"severity"`
+	PublishedAt time.Time  `json:"publishedAt"`
+	WithdrawnAt *time.Time `json:"withdrawnAt"`
+	CVSS        struct {
+		Score float64 `json:"score"`
+	} `json:"cvss"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+}
+
+// fetchGHSAFeed queries the advisories of an ecosystem's most recently
+// updated vulnerable packages and converts them into a feed with an item per
+// advisory, whose categories are the affected packages. Withdrawn advisories
+// are left out.
+func fetchGHSAFeed(client *http.Client, source string) (*gofeed.Feed, error) {
+	if GitHubToken == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"query":     ghsaQuery,
+		"variables": map[string]interface{}{"ecosystem": ghsaSources[source], "first": ghsaBatch},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, ghsaEndpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "bearer "+GitHubToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAdvisoryBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parseGHSAResponse(body)
+}
+
+// parseGHSAResponse groups the vulnerable packages of a GraphQL response by
+// advisory.
+func parseGHSAResponse(body []byte) (*gofeed.Feed, error) {
+	var response ghsaResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub advisories: %v", err)
+	}
+	if len(response.Errors) > 0 {
+		return nil, fmt.Errorf("GitHub GraphQL error: %s", response.Errors[0].Message)
+	}
+
+	type advisoryPackages struct {
+		advisory ghsaAdvisory
+		names    []string
+		ranges   []string
+	}
+	var order []string
+	advisories := map[string]*advisoryPackages{}
+	for _, node := range response.Data.SecurityVulnerabilities.Nodes {
+		advisory := node.Advisory
+		if advisory.GHSAID == "" || advisory.WithdrawnAt != nil {
+			continue
+		}
+		entry, ok := advisories[advisory.GHSAID]
+		if !ok {
+			entry = &advisoryPackages{advisory: advisory}
+			advisories[advisory.GHSAID] = entry
+			order = append(order, advisory.GHSAID)
+		}
+		affected := node.Package.Name + " " + node.VulnerableVersionRange
+		if node.FirstPatchedVersion != nil {
+			affected += ", fixed in " + node.FirstPatchedVersion.Identifier
+		}
+		if !slices.Contains(entry.names, node.Package.Name) {
+			entry.names = append(entry.names, node.Package.Name)
+		}
+		entry.ranges = append(entry.ranges, affected)
+	}
+
+	feed := &gofeed.Feed{Title: "GitHub Advisory Database"}
+	for _, id := range order {
+		entry := advisories[id]
+		advisory := entry.advisory
+		var cves []string
+		for _, identifier := range advisory.Identifiers {
+			if identifier.Type == "CVE" {
+				cves = append(cves, identifier.Value)
+			}
+		}
+		lines := []string{"Packages: " + strings.Join(entry.names, ", ")}
+		for _, affected := range entry.ranges {
+			lines = append(lines, "Affected: "+affected)
+		}
+		lines = append(lines, advisory.Description)
+		description := advisoryDescription(advisorySeverity(advisory.Severity, advisory.CVSS.Score), advisory.CVSS.Score, cves, strings.Join(lines, "\n"))
+		published := advisory.PublishedAt.UTC()
+		feed.Items = append(feed.Items, &gofeed.Item{
+			Title:           firstNonEmpty(advisory.Summary, id),
+			Link:            firstNonEmpty(advisory.Permalink, "https://github.com/advisories/"+url.PathEscape(id)),
+			GUID:            id,
+			Description:     description,
+			Categories:      entry.names,
+			PublishedParsed: &published,
+		})
+	}
+	return feed, nil
+}
+
+// ghsaPackageTags returns the package tags of a GHSA article, whose Tags hold
+// the affected packages until it is stored.
+func ghsaPackageTags(article models.NewsArticle) []models.Tag {
+	if !isGHSASource(article.SourceURL) {
+		return nil
+	}
+	var tags []models.Tag
+	for _, name := range article.Tags {
+		if name, ok := NormalizeTagName(name); ok {
+			tags = append(tags, models.Tag{Name: name, Kind: TagKindPackage})
+		}
+	}
+	return tags
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testGHSAResponse = `{"data": {"securityVulnerabilities": {"nodes": [
+	{"package": {"name": "golang.org/x/net"}, "vulnerableVersionRange": "< 0.17.0", "firstPatchedVersion": {"identifier": "0.17.0"},
+	 "advisory": {"ghsaId": "GHSA-4374-p667-p6c8", "summary": "HTTP/2 rapid reset can cause excessive work in net/http",
+	  "description": "A malicious HTTP/2 client can cause excessive server resource consumption.",
+	  "permalink": "https://github.com/advisories/GHSA-4374-p667-p6c8", "severity": "HIGH", "publishedAt": "%[1]s",
+	  "cvss": {"score": 7.5}, "identifiers": [{"type": "GHSA", "value": "GHSA-4374-p667-p6c8"}, {"type": "CVE", "value": "CVE-2023-44487"}]}},
+	{"package": {"name": "google.golang.org/grpc"}, "vulnerableVersionRange": ">= 1.58.0, < 1.58.3", "firstPatchedVersion": {"identifier": "1.58.3"},
+	 "advisory": {"ghsaId": "GHSA-4374-p667-p6c8", "summary": "HTTP/2 rapid reset can cause excessive work in net/http",
+	  "permalink": "https://github.com/advisories/GHSA-4374-p667-p6c8", "severity": "HIGH", "publishedAt": "%[1]s",
+	  "cvss": {"score": 7.5}, "identifiers": [{"type": "CVE", "value": "CVE-2023-44487"}]}},
+	{"package": {"name": "github.com/example/gone"}, "vulnerableVersionRange": "<= 1.0.0",
+	 "advisory": {"ghsaId": "GHSA-xxxx-xxxx-xxxx", "summary": "Withdrawn", "severity": "LOW", "publishedAt": "%[1]s", "withdrawnAt": "%[1]s"}}
+]}}}`
+
+func TestParseGHSAResponse(t *testing.T) {
+	feed, err := parseGHSAResponse([]byte(fmt.Sprintf(testGHSAResponse, "2023-10-10T21:28:24Z")))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 1)
+	item := feed.Items[0]
+	assert.Equal(t, "HTTP/2 rapid reset can cause excessive work in net/http", item.Title)
+	assert.Equal(t, "https://github.com/advisories/GHSA-4374-p667-p6c8", item.Link)
+	assert.Equal(t, "GHSA-4374-p667-p6c8", item.GUID)
+	assert.Equal(t, "Severity: High (CVSS 7.5)\nCVEs: CVE-2023-44487\n"+
+		"Packages: golang.org/x/net, google.golang.org/grpc\n"+
+		"Affected: golang.org/x/net < 0.17.0, fixed in 0.17.0\n"+
+		"Affected: google.golang.org/grpc >= 1.58.0, < 1.58.3, fixed in 1.58.3\n"+
+		"A malicious HTTP/2 client can cause excessive server resource consumption.", item.Description)
+	assert.Equal(t, time.Date(2023, 10, 10, 21, 28, 24, 0, time.UTC), *item.PublishedParsed)
+	assert.Equal(t, []string{"golang.org/x/net", "google.golang.org/grpc"}, item.Categories)
+
+	_, err = parseGHSAResponse([]byte(`{"errors": [{"message": "Bad credentials"}]}`))
+	assert.EqualError(t, err, "GitHub GraphQL error: Bad credentials")
+}
+
+func TestSetGHSAEcosystems(t *testing.T) {
+	defer func(sources map[string]string) { ghsaSources = sources }(ghsaSources)
+	require.NoError(t, SetGHSAEcosystems([]string{"Go", "pypi"}))
+	assert.ElementsMatch(t, []string{ghsaSourcePrefix + "go", ghsaSourcePrefix + "pip"}, GHSASources())
+	assert.Error(t, SetGHSAEcosystems([]string{"cobol"}))
+}
+
+func TestIngestGHSA(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	var ecosystem interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		ecosystem = request.Variables["ecosystem"]
+		fmt.Fprintf(w, testGHSAResponse, time.Now().UTC().Format(time.RFC3339))
+	}))
+	defer server.Close()
+	defer func(endpoint, token string, sources map[string]string) {
+		ghsaEndpoint, GitHubToken, ghsaSources = endpoint, token, sources
+	}(ghsaEndpoint, GitHubToken, ghsaSources)
+	ghsaEndpoint, GitHubToken = server.URL, "test-token"
+	require.NoError(t, SetGHSAEcosystems([]string{"go"}))
+
+	fetchAndCacheNews(GHSASources())
+	assert.Equal(t, "GO", ecosystem)
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	article := articles[0]
+	assert.Equal(t, "Tech", article.Category)
+	assert.Equal(t, ghsaSourcePrefix+"go", article.SourceURL)
+	assert.Subset(t, article.Tags, []string{"golang.org/x/net", "google.golang.org/grpc"})
+	assert.GreaterOrEqual(t, article.Rank, advisorySeverityWeights["high"])
+
+	tags, err := GetTags(0, TagKindPackage)
+	require.NoError(t, err)
+	assert.Len(t, tags, 2)
+}
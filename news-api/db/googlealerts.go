@@ -0,0 +1,54 @@
+package db
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// isGoogleAlertsSource reports whether a source is a Google Alerts feed, such
+// as https://www.google.com/alerts/feeds/0123/4567.
+func isGoogleAlertsSource(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.") == "google.com" && strings.HasPrefix(u.Path, "/alerts/feeds/")
+}
+
+// normalizeGoogleAlertsItems undoes what Google Alerts does to the items it
+// relays: links go through a google.com/url redirect, which is replaced by
+// the article URL so that copies of the article from its own feed are not
+// stored twice, and titles carry the markup highlighting the alert's terms.
+// The snippet of the article, given as the entry content, becomes its
+// description.
+func normalizeGoogleAlertsItems(items []*gofeed.Item) {
+	for _, item := range items {
+		item.Link = unwrapGoogleRedirect(item.Link)
+		item.Title = stripPolicy.Sanitize(normalizeText(item.Title))
+		if strings.TrimSpace(item.Description) == "" {
+			item.Description, item.Content = item.Content, ""
+		}
+	}
+}
+
+// unwrapGoogleRedirect returns the target of a google.com/url redirect, or
+// the link unchanged if it is not one.
+func unwrapGoogleRedirect(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Path != "/url" {
+		return link
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if host != "google.com" && !strings.HasPrefix(host, "google.") {
+		return link
+	}
+	query := u.Query()
+	for _, param := range []string{"url", "q"} {
+		if target, err := url.Parse(query.Get(param)); err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != "" {
+			return target.String()
+		}
+	}
+	return link
+}
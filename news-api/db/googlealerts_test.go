@@ -0,0 +1,57 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+const testGoogleAlertsFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:idx="urn:atom-extension:indexing">
+<id>tag:google.com,2005:reader/user/0123/state/com.google/alerts/4567</id>
+<title>Google Alert - ransomware</title>
+<entry>
+<id>tag:google.com,2013:googlealerts/feed:1234567890</id>
+<title type="html">&lt;b&gt;Ransomware&lt;/b&gt; gang leaks data of hospital &amp;amp; clinics</title>
+<link href="https://www.google.com/url?rct=j&amp;sa=t&amp;url=https://www.example.com/news/hospital-leak%3Futm_source%3Dalerts&amp;ct=ga&amp;cd=CAIyGjE&amp;usg=AOvVaw0"/>
+<published>2024-10-03T14:00:00Z</published>
+<content type="html">The &lt;b&gt;ransomware&lt;/b&gt; group published 40 GB of patient records ...</content>
+</entry>
+</feed>`
+
+func TestUnwrapGoogleRedirect(t *testing.T) {
+	assert.Equal(t, "https://example.com/a?b=1", unwrapGoogleRedirect("https://www.google.com/url?rct=j&sa=t&url=https%3A%2F%2Fexample.com%2Fa%3Fb%3D1&ct=ga"))
+	assert.Equal(t, "https://example.com/a", unwrapGoogleRedirect("https://www.google.co.uk/url?q=https://example.com/a&sa=U"))
+	assert.Equal(t, "https://www.google.com/url?url=javascript:alert(1)", unwrapGoogleRedirect("https://www.google.com/url?url=javascript:alert(1)"))
+	assert.Equal(t, "https://example.com/url?url=https://other.com/", unwrapGoogleRedirect("https://example.com/url?url=https://other.com/"))
+	assert.True(t, isGoogleAlertsSource("https://www.google.com/alerts/feeds/0123/4567"))
+	assert.False(t, isGoogleAlertsSource("https://www.google.com/url?q=https://example.com/alerts/feeds/1"))
+}
+
+func TestGoogleAlertsArticles(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	feed, err := gofeed.NewParser().Parse(strings.NewReader(testGoogleAlertsFeed))
+	require.NoError(t, err)
+	normalizeGoogleAlertsItems(feed.Items)
+	source := "https://www.google.com/alerts/feeds/0123/4567"
+	article := articleFromItem(source, feed.Items[0])
+	assert.Equal(t, "Ransomware gang leaks data of hospital & clinics", article.Title)
+	assert.Equal(t, "https://www.example.com/news/hospital-leak?utm_source=alerts", article.URL)
+	assert.Equal(t, "The ransomware group published 40 GB of patient records ...", article.Description)
+
+	// The article's own feed published it first, so the alert is a duplicate.
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Ransomware gang leaks data of hospital & clinics", URL: "https://example.com/news/hospital-leak", SourceURL: "https://example.com/feed", PublishedAt: time.Now()}))
+	require.NoError(t, InsertArticle(article))
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "https://example.com/feed", articles[0].SourceURL)
+}
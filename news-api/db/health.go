@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ingestCyclesKept is the number of caching cycles whose summary is kept.
+const ingestCyclesKept = 100
+
+func createHealthTables() error {
+	createHealthSQL := `
+	CREATE TABLE IF NOT EXISTS source_health (
+		url TEXT PRIMARY KEY,
+		last_success_at DATETIME,
+		last_error TEXT NOT NULL DEFAULT '',
+		last_error_at DATETIME,
+		new_articles INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS ingest_cycles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		sources INTEGER NOT NULL,
+		failed_sources INTEGER NOT NULL,
+		articles INTEGER NOT NULL
+	);
+	`
+	if _, err := db.Exec(createHealthSQL); err != nil {
+		return fmt.Errorf("failed to create health tables: %v", err)
+	}
+	return nil
+}
+
+// sourceFetch is the outcome of fetching one source in a caching cycle.
+type sourceFetch struct {
+	err         error
+	newArticles int
+}
+
+// recordIngestCycle stores the outcome of a caching cycle: the health of each
+// fetched source and a summary of the cycle. Sources no longer fetched are
+// forgotten.
+func recordIngestCycle(started, finished time.Time, fetches map[string]sourceFetch) error {
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	cycle := models.IngestCycle{StartedAt: started.UTC(), FinishedAt: finished.UTC(), Sources: len(fetches)}
+	sources := make([]interface{}, 0, len(fetches))
+	for source, fetch := range fetches {
+		sources = append(sources, source)
+		if fetch.err != nil {
+			cycle.FailedSources++
+			_, err = tx.Exec(`INSERT INTO source_health(url, last_error, last_error_at) VALUES(?, ?, ?)
+				ON CONFLICT(url) DO UPDATE SET last_error = excluded.last_error, last_error_at = excluded.last_error_at`,
+				source, fetch.err.Error(), cycle.FinishedAt)
+		} else {
+			cycle.Articles += fetch.newArticles
+			_, err = tx.Exec(`INSERT INTO source_health(url, last_success_at, new_articles) VALUES(?, ?, ?)
+				ON CONFLICT(url) DO UPDATE SET last_success_at = excluded.last_success_at, new_articles = excluded.new_articles,
+					last_error = '', last_error_at = NULL`,
+				source, cycle.FinishedAt, fetch.newArticles)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to record the health of %s: %v", source, err)
+		}
+	}
+	if len(sources) > 0 {
+		if _, err := tx.Exec("DELETE FROM source_health WHERE url NOT IN (?"+strings.Repeat(", ?", len(sources)-1)+")", sources...); err != nil {
+			return fmt.Errorf("failed to forget removed sources: %v", err)
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO ingest_cycles(started_at, finished_at, sources, failed_sources, articles) VALUES(?, ?, ?, ?, ?)",
+		cycle.StartedAt, cycle.FinishedAt, cycle.Sources, cycle.FailedSources, cycle.Articles); err != nil {
+		return fmt.Errorf("failed to record caching cycle: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM ingest_cycles WHERE id <= (SELECT MAX(id) FROM ingest_cycles) - ?", ingestCyclesKept); err != nil {
+		return fmt.Errorf("failed to prune caching cycles: %v", err)
+	}
+	return tx.Commit()
+}
+
+// GetSourceHealth returns the ingestion state of the sources fetched in the
+// last caching cycle, ordered by URL.
+func GetSourceHealth() ([]models.SourceHealth, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT url, last_success_at, last_error, last_error_at, new_articles FROM source_health ORDER BY url")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var health []models.SourceHealth
+	for rows.Next() {
+		var h models.SourceHealth
+		var lastSuccess, lastError sql.NullTime
+		if err := rows.Scan(&h.URL, &lastSuccess, &h.LastError, &lastError, &h.NewArticles); err != nil {
+			return nil, err
+		}
+		if lastSuccess.Valid {
+			h.LastSuccessAt = &lastSuccess.Time
+		}
+		if lastError.Valid {
+			h.LastErrorAt = &lastError.Time
+		}
+		health = append(health, h)
+	}
+	return health, rows.Err()
+}
+
+// GetLastIngestCycle returns the summary of the last caching cycle, or
+// sql.ErrNoRows if none completed yet.
+func GetLastIngestCycle() (models.IngestCycle, error) {
+	if db == nil {
+		return models.IngestCycle{}, fmt.Errorf("database connection is nil")
+	}
+	var c models.IngestCycle
+	err := db.QueryRow("SELECT started_at, finished_at, sources, failed_sources, articles FROM ingest_cycles ORDER BY id DESC LIMIT 1").
+		Scan(&c.StartedAt, &c.FinishedAt, &c.Sources, &c.FailedSources, &c.Articles)
+	return c, err
+}
+
+// Ping checks that the database can be reached.
+func Ping() error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	return db.Ping()
+}
+
+// RecordIngestCycleForTest records a caching cycle with the given fetch
+// errors per source, for tests of other packages.
+func RecordIngestCycleForTest(started, finished time.Time, sources map[string]error) error {
+	fetches := make(map[string]sourceFetch, len(sources))
+	for source, err := range sources {
+		fetches[source] = sourceFetch{err: err}
+	}
+	return recordIngestCycle(started, finished, fetches)
+}
+
+// PoolStats returns the statistics of the database connection pool.
+func PoolStats() sql.DBStats {
+	if db == nil {
+		return sql.DBStats{}
+	}
+	return db.Stats()
+}
+
+// GetIngestCycles returns the summaries of the last caching cycles, oldest
+// first. Only the last ingestCyclesKept cycles are kept.
+func GetIngestCycles() ([]models.IngestCycle, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT started_at, finished_at, sources, failed_sources, articles FROM ingest_cycles ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cycles := []models.IngestCycle{}
+	for rows.Next() {
+		var c models.IngestCycle
+		if err := rows.Scan(&c.StartedAt, &c.FinishedAt, &c.Sources, &c.FailedSources, &c.Articles); err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, c)
+	}
+	return cycles, rows.Err()
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestHealth(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM source_seen_items; DELETE FROM source_health; DELETE FROM ingest_cycles")
+	require.NoError(t, err)
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed" {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprint(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Test</title>`)
+		fmt.Fprint(w, itemXML("a", "Hackers breach a water utility network", "Mon, 29 Apr 2024 10:00:00 GMT"))
+		fmt.Fprint(w, itemXML("b", "Ransomware gang leaks hospital patient records", "Wed, 01 May 2024 10:00:00 GMT"))
+		fmt.Fprint(w, `</channel></rss>`)
+	}))
+	defer server.Close()
+	good, broken := server.URL+"/feed", server.URL+"/broken"
+
+	_, err = GetLastIngestCycle()
+	assert.Error(t, err, "no cycle has run yet")
+
+	fetchAndCacheNews([]string{good, broken})
+	cycle, err := GetLastIngestCycle()
+	require.NoError(t, err)
+	assert.Equal(t, 2, cycle.Sources)
+	assert.Equal(t, 1, cycle.FailedSources)
+	assert.Equal(t, 2, cycle.Articles)
+
+	health, err := GetSourceHealth()
+	require.NoError(t, err)
+	require.Len(t, health, 2)
+	byURL := map[string]int{health[0].URL: 0, health[1].URL: 1}
+	ok, failed := health[byURL[good]], health[byURL[broken]]
+	require.NotNil(t, ok.LastSuccessAt)
+	assert.Equal(t, 2, ok.NewArticles)
+	assert.Empty(t, ok.LastError)
+	assert.Nil(t, failed.LastSuccessAt)
+	assert.NotEmpty(t, failed.LastError)
+	assert.NotNil(t, failed.LastErrorAt)
+
+	// A second cycle finds nothing new, and removed sources are forgotten.
+	fetchAndCacheNews([]string{good})
+	cycle, err = GetLastIngestCycle()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cycle.Articles)
+	assert.Equal(t, 0, cycle.FailedSources)
+	health, err = GetSourceHealth()
+	require.NoError(t, err)
+	require.Len(t, health, 1)
+	assert.Equal(t, good, health[0].URL)
+	assert.Equal(t, 0, health[0].NewArticles)
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"sync"
+
+	"news-api/models"
+)
+
+// ArticleHook is called for every article the caching job stores for the first time.
+type ArticleHook func(models.NewsArticle)
+
+// CycleHook is called after each caching cycle has stored all of its articles.
+type CycleHook func()
+
+var (
+	hooksMutex   sync.RWMutex
+	articleHooks []ArticleHook
+	cycleHooks   []CycleHook
+)
+
+// RegisterArticleHook adds a hook that runs for each newly cached article.
+// Hooks run on the insert goroutine, so slow work should be handed off.
+func RegisterArticleHook(hook ArticleHook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	articleHooks = append(articleHooks, hook)
+}
+
+// RegisterCycleHook adds a hook that runs when a caching cycle completes.
+func RegisterCycleHook(hook CycleHook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	cycleHooks = append(cycleHooks, hook)
+}
+
+func runArticleHooks(article models.NewsArticle) {
+	hooksMutex.RLock()
+	hooks := articleHooks
+	hooksMutex.RUnlock()
+	for _, hook := range hooks {
+		hook(article)
+	}
+}
+
+func runCycleHooks() {
+	hooksMutex.RLock()
+	hooks := cycleHooks
+	hooksMutex.RUnlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
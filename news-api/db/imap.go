@@ -0,0 +1,200 @@
+package db
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// imapTimeout bounds a whole mailbox poll.
+const imapTimeout = 2 * time.Minute
+
+// maxNewsletterPoll bounds the messages read from the mailbox per poll; the
+// rest stay unseen for the next one.
+const maxNewsletterPoll = 50
+
+// imapConn speaks just enough IMAP4rev1 (RFC 3501) to read the unseen
+// messages of a folder.
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+// pollNewsletterMailbox stores the unseen messages of an IMAP folder as
+// newsletters and marks them seen.
+func pollNewsletterMailbox(mailbox string) error {
+	u, err := url.Parse(mailbox)
+	if err != nil {
+		return fmt.Errorf("invalid mailbox URL: %v", err)
+	}
+	host := u.Host
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	switch u.Scheme {
+	case "imaps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "993")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	case "imap":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "143")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	default:
+		return fmt.Errorf("unsupported mailbox scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", host, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(imapTimeout))
+
+	c := &imapConn{conn: conn, r: bufio.NewReader(conn)}
+	greeting, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return fmt.Errorf("unexpected greeting %q", greeting)
+	}
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		password, _ := u.User.Password()
+		if _, err := c.command("LOGIN " + imapQuote(u.User.Username()) + " " + imapQuote(password)); err != nil {
+			return err
+		}
+	}
+	defer c.command("LOGOUT")
+
+	folder := strings.TrimPrefix(u.Path, "/")
+	if folder == "" {
+		folder = "INBOX"
+	}
+	if _, err := c.command("SELECT " + imapQuote(folder)); err != nil {
+		return err
+	}
+	untagged, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return err
+	}
+	var uids []string
+	for _, line := range untagged {
+		if rest, ok := strings.CutPrefix(line.text, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	if len(uids) > maxNewsletterPoll {
+		uids = uids[:maxNewsletterPoll]
+	}
+
+	for _, uid := range uids {
+		if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+			return fmt.Errorf("unexpected message UID %q", uid)
+		}
+		untagged, err := c.command("UID FETCH " + uid + " BODY.PEEK[]")
+		if err != nil {
+			return err
+		}
+		var raw []byte
+		for _, line := range untagged {
+			if line.literal != nil {
+				raw = line.literal
+				break
+			}
+		}
+		if raw == nil {
+			continue
+		}
+		newsletter, err := ParseNewsletter(raw)
+		if err != nil {
+			log.Printf("Skipping unreadable newsletter %s: %v", uid, err)
+		} else if err := StoreNewsletter(newsletter); err != nil {
+			log.Printf("Skipping newsletter %s: %v", uid, err)
+		}
+		if _, err := c.command("UID STORE " + uid + ` +FLAGS.SILENT (\Seen)`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imapResponse is an untagged response line and the literal it carries, if
+// any.
+type imapResponse struct {
+	text    string
+	literal []byte
+}
+
+// command sends a tagged command and returns its untagged responses, or an
+// error unless it completes with OK.
+func (c *imapConn) command(cmd string) ([]imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return nil, err
+	}
+	var untagged []imapResponse
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if status, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(status, "OK") {
+				verb, _, _ := strings.Cut(cmd, " ")
+				return nil, fmt.Errorf("IMAP %s failed: %s", verb, status)
+			}
+			return untagged, nil
+		}
+		response := imapResponse{text: line}
+		if size, ok := imapLiteralSize(line); ok {
+			response.literal = make([]byte, size)
+			if _, err := io.ReadFull(c.r, response.literal); err != nil {
+				return nil, err
+			}
+			// The rest of the response, e.g. the closing parenthesis of a FETCH.
+			if _, err := c.readLine(); err != nil {
+				return nil, err
+			}
+		}
+		untagged = append(untagged, response)
+	}
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read IMAP response: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapLiteralSize returns the size announced by a line ending with a literal
+// "{N}".
+func imapLiteralSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	start := strings.LastIndexByte(line, '{')
+	if start < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[start+1 : len(line)-1])
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// imapQuote quotes an IMAP string argument.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
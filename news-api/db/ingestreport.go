@@ -0,0 +1,139 @@
+package db
+
+import (
+	"sort"
+	"strconv"
+
+	"news-api/models"
+)
+
+// SourceReport counts what a caching cycle did with the items of a source.
+// New and Duplicates count articles, one per organization listing the source.
+type SourceReport struct {
+	URL   string `json:"url"`
+	Error string `json:"error,omitempty"`
+	// Skipped is set for sources a dry run cannot fetch without side
+	// effects, such as newsletters.
+	Skipped bool `json:"skipped,omitempty"`
+	// Items is the number of items in the feed.
+	Items int `json:"items"`
+	// Seen counts the items processed by an earlier cycle.
+	Seen       int `json:"seen"`
+	NonEnglish int `json:"nonEnglish"`
+	Filtered   int `json:"filtered"`
+	// BeforeSince counts the items a backfill skipped as too old.
+	BeforeSince int `json:"beforeSince,omitempty"`
+	New         int `json:"new"`
+	Duplicates  int `json:"duplicates"`
+}
+
+// IngestReport summarizes a caching cycle.
+type IngestReport struct {
+	Sources    []SourceReport `json:"sources"`
+	New        int            `json:"new"`
+	Duplicates int            `json:"duplicates"`
+	// Ranks counts the new articles by rank, and Severities by severity
+	// bucket: low, medium and high as in the threat score.
+	Ranks      map[int]int    `json:"ranks"`
+	Severities map[string]int `json:"severities"`
+
+	duplicates map[string]int
+}
+
+func newIngestReport() *IngestReport {
+	return &IngestReport{Ranks: map[int]int{}, Severities: map[string]int{}, duplicates: map[string]int{}}
+}
+
+func (r *IngestReport) countNew(article models.NewsArticle) {
+	r.New++
+	r.Ranks[article.Rank]++
+	switch {
+	case article.Severity >= SeverityHigh:
+		r.Severities["high"]++
+	case article.Severity >= SeverityMedium:
+		r.Severities["medium"]++
+	default:
+		r.Severities["low"]++
+	}
+}
+
+func (r *IngestReport) countDuplicate(source string) {
+	r.Duplicates++
+	r.duplicates[source]++
+}
+
+// merge adds the per-source counts of the fetching goroutines and the insert
+// goroutine, sorted by source.
+func (r *IngestReport) merge(sources map[string]*SourceReport, newArticles map[string]int) {
+	for url, counts := range sources {
+		counts.New = newArticles[url]
+		counts.Duplicates = r.duplicates[url]
+		r.Sources = append(r.Sources, *counts)
+	}
+	sort.Slice(r.Sources, func(i, j int) bool { return r.Sources[i].URL < r.Sources[j].URL })
+}
+
+// add sums the counts of another report, such as a page of a backfill.
+func (r *IngestReport) add(other IngestReport) {
+	r.New += other.New
+	r.Duplicates += other.Duplicates
+	for rank, n := range other.Ranks {
+		r.Ranks[rank] += n
+	}
+	for severity, n := range other.Severities {
+		r.Severities[severity] += n
+	}
+	for _, source := range other.Sources {
+		i := sort.Search(len(r.Sources), func(i int) bool { return r.Sources[i].URL >= source.URL })
+		if i == len(r.Sources) || r.Sources[i].URL != source.URL {
+			r.Sources = append(r.Sources[:i], append([]SourceReport{{URL: source.URL}}, r.Sources[i:]...)...)
+		}
+		sum := &r.Sources[i]
+		if source.Error != "" {
+			sum.Error = source.Error
+		}
+		sum.Items += source.Items
+		sum.Seen += source.Seen
+		sum.NonEnglish += source.NonEnglish
+		sum.Filtered += source.Filtered
+		sum.BeforeSince += source.BeforeSince
+		sum.New += source.New
+		sum.Duplicates += source.Duplicates
+	}
+}
+
+// RankBuckets returns the ranks of Ranks in ascending order.
+func (r *IngestReport) RankBuckets() []int {
+	ranks := make([]int, 0, len(r.Ranks))
+	for rank := range r.Ranks {
+		ranks = append(ranks, rank)
+	}
+	sort.Ints(ranks)
+	return ranks
+}
+
+// wouldInsert reports whether insertArticle would store an article: whether
+// neither the database nor an earlier article of the same dry run, recorded
+// in seen, has its URL or GUID for its organization.
+func wouldInsert(article models.NewsArticle, seen map[string]bool) (bool, error) {
+	org := strconv.FormatInt(article.OrgID, 10)
+	keys := []string{org + " url " + article.URL}
+	if article.GUID != "" {
+		keys = append(keys, org+" guid "+article.SourceURL+" "+article.GUID)
+	}
+	for _, key := range keys {
+		if seen[key] {
+			return false, nil
+		}
+	}
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM articles WHERE org_id = ? AND (url = ? OR (guid != '' AND guid = ? AND sourceUrl = ?)))",
+		article.OrgID, article.URL, article.GUID, article.SourceURL).Scan(&exists)
+	if err != nil || exists {
+		return false, err
+	}
+	for _, key := range keys {
+		seen[key] = true
+	}
+	return true, nil
+}
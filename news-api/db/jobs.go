@@ -0,0 +1,245 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/cron"
+	"news-api/models"
+)
+
+// JobSchedule tells when a background job next runs.
+type JobSchedule interface {
+	// Next returns the first time after t the job runs, or the zero time if
+	// it never does.
+	Next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (d everySchedule) Next(t time.Time) time.Time { return t.Add(time.Duration(d)) }
+
+// ParseJobSchedule parses a cron expression (see cron.Parse) or
+// "@every <duration>", e.g. "@every 15m", for a job run at a fixed interval.
+func ParseJobSchedule(spec string) (JobSchedule, error) {
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(spec), "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", rest)
+		}
+		return everySchedule(d), nil
+	}
+	return cron.Parse(spec)
+}
+
+// Every returns the "@every" schedule spec of an interval.
+func Every(d time.Duration) string {
+	return "@every " + d.String()
+}
+
+// JobScheduleOverrides replaces the schedule of background jobs by name, e.g.
+// {"caching": "*/5 * * * *"}. Set it before the jobs are registered.
+var JobScheduleOverrides map[string]string
+
+// Job is a background job run on a schedule by the leader instance.
+type Job struct {
+	Name string
+	// Schedule is a cron expression or "@every <duration>".
+	Schedule string
+	// RunAtStart also runs the job when it is registered.
+	RunAtStart bool
+	Run        func() error
+}
+
+type scheduledJob struct {
+	Job
+	schedule JobSchedule
+
+	mu      sync.Mutex
+	running bool
+	nextRun time.Time
+}
+
+var (
+	jobsMutex sync.Mutex
+	jobs      = map[string]*scheduledJob{}
+)
+
+func createJobTables() error {
+	createJobsSQL := `
+	CREATE TABLE IF NOT EXISTS scheduled_jobs (
+		name TEXT PRIMARY KEY,
+		paused INTEGER NOT NULL DEFAULT 0,
+		last_started_at DATETIME,
+		last_finished_at DATETIME,
+		last_error TEXT NOT NULL DEFAULT ''
+	);
+	`
+	if _, err := db.Exec(createJobsSQL); err != nil {
+		return fmt.Errorf("failed to create scheduled_jobs table: %v", err)
+	}
+	return nil
+}
+
+// RegisterJob schedules a job, under the schedule of JobScheduleOverrides if
+// it has one. Runs are skipped while this instance is not the leader, while
+// the job is paused and while its previous run has not finished.
+func RegisterJob(job Job) error {
+	if override, ok := JobScheduleOverrides[job.Name]; ok {
+		job.Schedule = override
+	}
+	schedule, err := ParseJobSchedule(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for job %s: %v", job.Name, err)
+	}
+	j := &scheduledJob{Job: job, schedule: schedule}
+
+	jobsMutex.Lock()
+	if _, exists := jobs[job.Name]; exists {
+		jobsMutex.Unlock()
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	jobs[job.Name] = j
+	jobsMutex.Unlock()
+
+	go j.loop()
+	return nil
+}
+
+func (j *scheduledJob) loop() {
+	if j.RunAtStart {
+		j.tick()
+	}
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Job %s never runs under schedule %q.", j.Name, j.Schedule)
+			return
+		}
+		j.mu.Lock()
+		j.nextRun = next
+		j.mu.Unlock()
+		time.Sleep(time.Until(next))
+		j.tick()
+	}
+}
+
+// tick runs the job unless it must be skipped.
+func (j *scheduledJob) tick() {
+	if !IsLeader() {
+		return
+	}
+	if paused, err := jobPaused(j.Name); err != nil {
+		log.Printf("Error checking whether job %s is paused: %v", j.Name, err)
+		return
+	} else if paused {
+		return
+	}
+	j.mu.Lock()
+	if j.running {
+		j.mu.Unlock()
+		log.Printf("Skipping job %s, its previous run has not finished.", j.Name)
+		return
+	}
+	j.running = true
+	j.mu.Unlock()
+	defer func() {
+		j.mu.Lock()
+		j.running = false
+		j.mu.Unlock()
+	}()
+
+	started := time.Now().UTC()
+	if _, err := db.Exec(`INSERT INTO scheduled_jobs(name, last_started_at) VALUES(?, ?)
+		ON CONFLICT(name) DO UPDATE SET last_started_at = excluded.last_started_at`, j.Name, started); err != nil {
+		log.Printf("Error recording the start of job %s: %v", j.Name, err)
+	}
+	runErr := j.Run()
+	lastError := ""
+	if runErr != nil {
+		lastError = runErr.Error()
+		log.Printf("Job %s failed: %v", j.Name, runErr)
+	}
+	if _, err := db.Exec("UPDATE scheduled_jobs SET last_finished_at = ?, last_error = ? WHERE name = ?", time.Now().UTC(), lastError, j.Name); err != nil {
+		log.Printf("Error recording the end of job %s: %v", j.Name, err)
+	}
+}
+
+func jobPaused(name string) (bool, error) {
+	var paused bool
+	err := db.QueryRow("SELECT paused FROM scheduled_jobs WHERE name = ?", name).Scan(&paused)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return paused, err
+}
+
+// SetJobPaused pauses or resumes a registered job, on every instance. It
+// returns sql.ErrNoRows if no job has the name. A run in progress is not
+// interrupted.
+func SetJobPaused(name string, paused bool) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	jobsMutex.Lock()
+	_, ok := jobs[name]
+	jobsMutex.Unlock()
+	if !ok {
+		return sql.ErrNoRows
+	}
+	_, err := db.Exec(`INSERT INTO scheduled_jobs(name, paused) VALUES(?, ?)
+		ON CONFLICT(name) DO UPDATE SET paused = excluded.paused`, name, paused)
+	if err != nil {
+		return fmt.Errorf("failed to update job %s: %v", name, err)
+	}
+	return nil
+}
+
+// GetJobs returns the registered jobs by name, with their last run as
+// recorded by the leader.
+func GetJobs() ([]models.ScheduledJob, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	jobsMutex.Lock()
+	registered := make([]*scheduledJob, 0, len(jobs))
+	for _, j := range jobs {
+		registered = append(registered, j)
+	}
+	jobsMutex.Unlock()
+	sort.Slice(registered, func(a, b int) bool { return registered[a].Name < registered[b].Name })
+
+	result := make([]models.ScheduledJob, 0, len(registered))
+	for _, j := range registered {
+		job := models.ScheduledJob{Name: j.Name, Schedule: j.Schedule}
+		j.mu.Lock()
+		if !j.nextRun.IsZero() {
+			next := j.nextRun
+			job.NextRunAt = &next
+		}
+		j.mu.Unlock()
+
+		var started, finished sql.NullTime
+		err := db.QueryRow("SELECT paused, last_started_at, last_finished_at, last_error FROM scheduled_jobs WHERE name = ?", j.Name).
+			Scan(&job.Paused, &started, &finished, &job.LastError)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if started.Valid {
+			job.LastStartedAt = &started.Time
+			if finished.Valid && !finished.Time.Before(started.Time) {
+				job.LastFinishedAt = &finished.Time
+			} else {
+				job.Running = true
+			}
+		}
+		result = append(result, job)
+	}
+	return result, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJobSchedule(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 7, 30, 0, time.UTC)
+	every, err := ParseJobSchedule(Every(15 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, start.Add(15*time.Minute), every.Next(start))
+	hourly, err := ParseJobSchedule("@hourly")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC), hourly.Next(start))
+
+	for _, spec := range []string{"@every soon", "@every -1m", "* * *"} {
+		_, err := ParseJobSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestScheduledJobs(t *testing.T) {
+	setupTestDB(t)
+	defer func(overrides map[string]string) { JobScheduleOverrides = overrides }(JobScheduleOverrides)
+	JobScheduleOverrides = map[string]string{"test-failing": "@every 24h"}
+	defer func() {
+		jobsMutex.Lock()
+		delete(jobs, "test-counting")
+		delete(jobs, "test-failing")
+		jobsMutex.Unlock()
+	}()
+
+	runs := 0
+	require.NoError(t, RegisterJob(Job{Name: "test-counting", Schedule: "@yearly", Run: func() error {
+		runs++
+		return nil
+	}}))
+	require.NoError(t, RegisterJob(Job{Name: "test-failing", Schedule: "@daily", RunAtStart: true, Run: func() error {
+		return errors.New("upstream unavailable")
+	}}))
+	assert.Error(t, RegisterJob(Job{Name: "test-counting", Schedule: "@daily", Run: func() error { return nil }}))
+
+	require.Eventually(t, func() bool {
+		jobs, err := GetJobs()
+		require.NoError(t, err)
+		for _, job := range jobs {
+			if job.Name == "test-failing" {
+				return job.Schedule == "@every 24h" && job.LastError == "upstream unavailable" && job.LastFinishedAt != nil && job.NextRunAt != nil
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+
+	jobsMutex.Lock()
+	counting := jobs["test-counting"]
+	jobsMutex.Unlock()
+	counting.tick()
+	assert.Equal(t, 1, runs)
+	require.NoError(t, SetJobPaused("test-counting", true))
+	counting.tick()
+	assert.Equal(t, 1, runs, "paused jobs do not run")
+	require.NoError(t, SetJobPaused("test-counting", false))
+	counting.tick()
+	assert.Equal(t, 2, runs)
+
+	assert.ErrorIs(t, SetJobPaused("missing", true), sql.ErrNoRows)
+}
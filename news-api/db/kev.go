@@ -0,0 +1,138 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// DefaultKEVCatalogURL is CISA's Known Exploited Vulnerabilities catalog.
+const DefaultKEVCatalogURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+// kevDateLayout is the layout of the dates in the KEV catalog.
+const kevDateLayout = "2006-01-02"
+
+func createKEVTables() error {
+	createKEVSQL := `
+	CREATE TABLE IF NOT EXISTS kev_entries (
+		cve TEXT PRIMARY KEY,
+		vendor TEXT NOT NULL,
+		product TEXT NOT NULL,
+		name TEXT NOT NULL,
+		date_added TEXT NOT NULL,
+		due_date TEXT NOT NULL,
+		ransomware INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_kev_entries_due_date ON kev_entries (due_date);
+	`
+	if _, err := db.Exec(createKEVSQL); err != nil {
+		return fmt.Errorf("failed to create kev_entries table: %v", err)
+	}
+	return nil
+}
+
+type kevCatalog struct {
+	Vulnerabilities []struct {
+		CVE                        string `json:"cveID"`
+		VendorProject              string `json:"vendorProject"`
+		Product                    string `json:"product"`
+		VulnerabilityName          string `json:"vulnerabilityName"`
+		DateAdded                  string `json:"dateAdded"`
+		DueDate                    string `json:"dueDate"`
+		KnownRansomwareCampaignUse string `json:"knownRansomwareCampaignUse"`
+	} `json:"vulnerabilities"`
+}
+
+// StartKEVSync schedules the "kev-sync" job, which downloads the KEV catalog
+// from catalogURL now and then every interval, while this instance is the
+// leader.
+func StartKEVSync(catalogURL string, interval time.Duration) {
+	err := RegisterJob(Job{Name: "kev-sync", Schedule: Every(interval), RunAtStart: true, Run: func() error {
+		n, err := SyncKEV(catalogURL)
+		if err != nil {
+			return fmt.Errorf("failed to sync the KEV catalog: %v", err)
+		}
+		log.Printf("Synced %d KEV catalog entries.", n)
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling the KEV sync: %v", err)
+	}
+}
+
+// SyncKEV stores the entries of the KEV catalog at catalogURL, replacing
+// those stored before, and returns their number. Entries without a valid due
+// date are skipped.
+func SyncKEV(catalogURL string) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	resp, err := fetcher.Client(time.Minute).Get(catalogURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch KEV catalog: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to fetch KEV catalog: %s", resp.Status)
+	}
+	var catalog kevCatalog
+	if err := json.NewDecoder(resp.Body).Decode(&catalog); err != nil {
+		return 0, fmt.Errorf("failed to decode KEV catalog: %v", err)
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM kev_entries"); err != nil {
+		return 0, fmt.Errorf("failed to clear KEV entries: %v", err)
+	}
+	stored := 0
+	for _, v := range catalog.Vulnerabilities {
+		if _, err := time.Parse(kevDateLayout, v.DueDate); err != nil || v.CVE == "" {
+			continue
+		}
+		_, err := tx.Exec("INSERT OR REPLACE INTO kev_entries(cve, vendor, product, name, date_added, due_date, ransomware) VALUES(?, ?, ?, ?, ?, ?, ?)",
+			v.CVE, v.VendorProject, v.Product, v.VulnerabilityName, v.DateAdded, v.DueDate, v.KnownRansomwareCampaignUse == "Known")
+		if err != nil {
+			return 0, fmt.Errorf("failed to store KEV entry %s: %v", v.CVE, err)
+		}
+		stored++
+	}
+	return stored, tx.Commit()
+}
+
+// GetKEVDeadlines returns the KEV entries due on the UTC days from start to
+// end, earliest first.
+func GetKEVDeadlines(start, end time.Time) ([]models.KEVEntry, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT cve, vendor, product, name, date_added, due_date, ransomware FROM kev_entries
+		WHERE due_date >= ? AND due_date <= ? ORDER BY due_date, cve`,
+		start.UTC().Format(kevDateLayout), end.UTC().Format(kevDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []models.KEVEntry
+	for rows.Next() {
+		var e models.KEVEntry
+		var added, due string
+		if err := rows.Scan(&e.CVE, &e.Vendor, &e.Product, &e.Name, &added, &due, &e.Ransomware); err != nil {
+			return nil, err
+		}
+		e.DateAdded, _ = time.Parse(kevDateLayout, added)
+		e.DueDate, _ = time.Parse(kevDateLayout, due)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncKEV(t *testing.T) {
+	setupTestDB(t)
+	catalog := `{"vulnerabilities": [
+		{"cveID": "CVE-2024-3400", "vendorProject": "Palo Alto Networks", "product": "PAN-OS", "vulnerabilityName": "PAN-OS Command Injection Vulnerability", "dateAdded": "2024-04-12", "dueDate": "2024-04-19", "knownRansomwareCampaignUse": "Unknown"},
+		{"cveID": "CVE-2023-4966", "vendorProject": "Citrix", "product": "NetScaler", "vulnerabilityName": "Citrix Bleed", "dateAdded": "2023-10-18", "dueDate": "2023-11-08", "knownRansomwareCampaignUse": "Known"},
+		{"cveID": "CVE-2000-0001", "vendorProject": "Example", "product": "Broken", "vulnerabilityName": "No due date", "dateAdded": "2024-01-01", "dueDate": ""}
+	]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(catalog))
+	}))
+	defer server.Close()
+
+	n, err := SyncKEV(server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	entries, err := GetKEVDeadlines(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "CVE-2023-4966", entries[0].CVE)
+	assert.True(t, entries[0].Ransomware)
+	assert.Equal(t, time.Date(2024, 4, 19, 0, 0, 0, 0, time.UTC), entries[1].DueDate)
+	assert.Equal(t, "PAN-OS", entries[1].Product)
+
+	// A later sync replaces the catalog.
+	catalog = `{"vulnerabilities": []}`
+	_, err = SyncKEV(server.URL)
+	require.NoError(t, err)
+	entries, err = GetKEVDeadlines(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	server.Close()
+	_, err = SyncKEV(server.URL)
+	assert.Error(t, err)
+}
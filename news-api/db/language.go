@@ -0,0 +1,83 @@
+package db
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/pemistahl/lingua-go"
+)
+
+// LanguageWorkers bounds how many items have their language detected at once
+// across all sources.
+var LanguageWorkers = runtime.NumCPU()
+
+// detectionLanguages are the languages the detector tells apart. Only English
+// articles are kept; the others are the languages feeds are likely to mix in.
+var detectionLanguages = []lingua.Language{lingua.English, lingua.German, lingua.French, lingua.Spanish, lingua.Russian, lingua.Chinese}
+
+var (
+	detectorOnce  sync.Once
+	detector      lingua.LanguageDetector
+	detectorSlots chan struct{}
+)
+
+// SetDetectionLanguages replaces the detected languages by their ISO 639-1
+// codes. English is always included. With English alone, detection is
+// disabled and every item is kept. It must be called before the first
+// caching cycle.
+func SetDetectionLanguages(codes []string) error {
+	languages := []lingua.Language{lingua.English}
+	for _, code := range codes {
+		language := lingua.GetLanguageFromIsoCode639_1(lingua.GetIsoCode639_1FromValue(strings.TrimSpace(code)))
+		if language == lingua.Unknown {
+			return fmt.Errorf("unknown language code %q", code)
+		}
+		if language != lingua.English {
+			languages = append(languages, language)
+		}
+	}
+	detectionLanguages = languages
+	return nil
+}
+
+// languageDetector builds the detector on first use. Language models are
+// loaded lazily, when a text needs them.
+func languageDetector() lingua.LanguageDetector {
+	detectorOnce.Do(func() {
+		detectorSlots = make(chan struct{}, max(1, LanguageWorkers))
+		if len(detectionLanguages) > 1 {
+			detector = lingua.NewLanguageDetectorBuilder().FromLanguages(detectionLanguages...).Build()
+		}
+	})
+	return detector
+}
+
+// isEnglish reports whether a feed item is in English. Items that declare
+// English, or whose feed does, are not analyzed.
+func isEnglish(feed *gofeed.Feed, item *gofeed.Item) bool {
+	if item.DublinCoreExt != nil && len(item.DublinCoreExt.Language) > 0 {
+		if declaresEnglish(item.DublinCoreExt.Language[0]) {
+			return true
+		}
+	} else if declaresEnglish(feed.Language) {
+		return true
+	}
+
+	d := languageDetector()
+	if d == nil {
+		return true
+	}
+	detectorSlots <- struct{}{}
+	defer func() { <-detectorSlots }()
+	lang, _ := d.DetectLanguageOf(item.Title + " " + item.Description)
+	return lang == lingua.English
+}
+
+// declaresEnglish reports whether a language tag such as "en" or "en-US" is English.
+func declaresEnglish(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return tag == "en" || strings.HasPrefix(tag, "en-") || strings.HasPrefix(tag, "en_")
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/mmcdole/gofeed/extensions"
+	"github.com/pemistahl/lingua-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsEnglish(t *testing.T) {
+	german := &gofeed.Item{Title: "Neue Sicherheitslücke in Routern", Description: "Angreifer nutzen die Schwachstelle bereits aktiv aus, warnt das Bundesamt."}
+	english := &gofeed.Item{Title: "New flaw in routers", Description: "Attackers are already exploiting the vulnerability, the agency warns."}
+
+	assert.True(t, isEnglish(&gofeed.Feed{}, english))
+	assert.False(t, isEnglish(&gofeed.Feed{}, german))
+	assert.False(t, isEnglish(&gofeed.Feed{Language: "de-DE"}, german))
+
+	// Declared English is trusted without detection.
+	assert.True(t, isEnglish(&gofeed.Feed{Language: "en-us"}, german))
+	german.DublinCoreExt = &ext.DublinCoreExtension{Language: []string{"en"}}
+	assert.True(t, isEnglish(&gofeed.Feed{}, german))
+	german.DublinCoreExt.Language = []string{"de"}
+	assert.False(t, isEnglish(&gofeed.Feed{Language: "en"}, german), "the item's language wins over the feed's")
+}
+
+func TestSetDetectionLanguages(t *testing.T) {
+	defer func(languages []lingua.Language) { detectionLanguages = languages }(detectionLanguages)
+
+	assert.NoError(t, SetDetectionLanguages([]string{"de", " JA "}))
+	assert.Equal(t, []lingua.Language{lingua.English, lingua.German, lingua.Japanese}, detectionLanguages)
+	assert.NoError(t, SetDetectionLanguages([]string{"en"}))
+	assert.Equal(t, []lingua.Language{lingua.English}, detectionLanguages)
+	assert.Error(t, SetDetectionLanguages([]string{"xx"}))
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"news-api/fetcher"
+)
+
+// LinkCheckConfig controls the dead-link checker.
+type LinkCheckConfig struct {
+	// Sample is the number of article URLs checked per run.
+	Sample int
+	// Interval is the time between runs.
+	Interval time.Duration
+	// PruneAfter moves articles whose link has been dead for this long to the
+	// trash. Zero keeps them. Articles with an archive link are kept.
+	PruneAfter time.Duration
+	// ArchiveFallback looks up a Wayback Machine capture of dead links, up to
+	// Sample links per run.
+	ArchiveFallback bool
+}
+
+// deadLinkCondition matches articles whose link responded 404 or 410. Other
+// failures, such as timeouts or server errors, are usually temporary.
+const deadLinkCondition = "link_status IN (404, 410)"
+
+// archiveRetryAfter is how long to wait before looking up a dead link again
+// after the Wayback Machine had no capture of it.
+const archiveRetryAfter = 7 * 24 * time.Hour
+
+var linkCheckClient = fetcher.PoliteClient(10 * time.Second)
+
+var waybackClient = fetcher.Client(15 * time.Second)
+
+// StartLinkChecker schedules the "link-check" job, which checks a sample of
+// stored article URLs every interval, oldest checks first, and prunes
+// long-dead articles if configured.
+func StartLinkChecker(cfg LinkCheckConfig) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	err := RegisterJob(Job{Name: "link-check", Schedule: Every(cfg.Interval), Run: func() error {
+		checked, dead, err := CheckLinks(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to check article links: %v", err)
+		}
+		log.Printf("Checked %d article links, %d dead.", checked, dead)
+		if cfg.ArchiveFallback {
+			found, err := ResolveArchiveLinks(cfg.Sample)
+			if err != nil {
+				return fmt.Errorf("failed to look up archived copies: %v", err)
+			}
+			if found > 0 {
+				log.Printf("Found archived copies of %d dead article links.", found)
+			}
+		}
+		if cfg.PruneAfter > 0 {
+			pruned, err := PruneDeadArticles(time.Now().Add(-cfg.PruneAfter))
+			if err != nil {
+				return fmt.Errorf("failed to prune dead articles: %v", err)
+			}
+			if pruned > 0 {
+				log.Printf("Moved %d articles with dead links to the trash.", pruned)
+			}
+		}
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling the link checker: %v", err)
+	}
+}
+
+// CheckLinks verifies up to cfg.Sample article URLs that were never checked or
+// were checked longest ago, and records their HTTP status. Links already known
+// to be dead are not checked again. It returns the number of URLs checked and
+// how many of them are dead.
+func CheckLinks(cfg LinkCheckConfig) (int, int, error) {
+	if db == nil {
+		return 0, 0, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT url FROM articles WHERE `+notDeletedCondition+` AND NOT `+deadLinkCondition+`
+		GROUP BY url ORDER BY MAX(link_checked_at IS NOT NULL), MAX(link_checked_at), MAX(publishedAt) DESC LIMIT ?`, cfg.Sample)
+	if err != nil {
+		return 0, 0, err
+	}
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		urls = append(urls, u)
+	}
+	rows.Close()
+
+	dead := 0
+	for _, link := range urls {
+		u, err := url.Parse(link)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		status := linkStatus(link)
+
+		if _, err := db.Exec("UPDATE articles SET link_status = ?, link_checked_at = ? WHERE url = ?", status, time.Now().UTC(), link); err != nil {
+			return len(urls), dead, err
+		}
+		if status == http.StatusNotFound || status == http.StatusGone {
+			dead++
+		}
+		if isPaywalledPage(status, "") {
+			if err := markPaywalled(link); err != nil {
+				return len(urls), dead, err
+			}
+		}
+	}
+	return len(urls), dead, nil
+}
+
+// linkStatus returns the final HTTP status of a link, or 0 if it could not be
+// fetched. Servers that do not support HEAD are asked with GET.
+func linkStatus(link string) int {
+	resp, err := linkCheckClient.Head(link)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = linkCheckClient.Get(link)
+	}
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// ResolveArchiveLinks looks up the newest Wayback Machine capture of up to
+// limit dead article links without one, highest ranked first, and stores it
+// as the articles' archive link. Links the Wayback Machine has no capture of
+// are looked up again after a week. It returns how many captures were found.
+func ResolveArchiveLinks(limit int) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT url FROM articles WHERE `+notDeletedCondition+` AND `+deadLinkCondition+` AND archive_url = ''
+		AND (archive_checked_at IS NULL OR archive_checked_at < ?)
+		GROUP BY url ORDER BY MAX(rank) DESC, MAX(publishedAt) DESC LIMIT ?`, time.Now().Add(-archiveRetryAfter).UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, u)
+	}
+	rows.Close()
+
+	found := 0
+	for _, link := range urls {
+		snapshot, ok, err := latestWaybackSnapshot(waybackClient, link)
+		if err != nil {
+			// The Wayback Machine is often slow or rate limiting; the link is
+			// looked up again on the next run.
+			log.Printf("Error looking up an archived copy of %s: %v", link, err)
+			continue
+		}
+		archiveURL := ""
+		if ok {
+			archiveURL = snapshot.PageURL()
+			found++
+		}
+		if _, err := db.Exec("UPDATE articles SET archive_url = ?, archive_checked_at = ? WHERE url = ?", archiveURL, time.Now().UTC(), link); err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+// PruneDeadArticles moves the articles whose link was found dead before the
+// cutoff and that have no archive link to the trash, and returns how many were
+// moved.
+func PruneDeadArticles(cutoff time.Time) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	res, err := db.Exec("UPDATE articles SET deleted_at = ? WHERE "+notDeletedCondition+" AND "+deadLinkCondition+" AND archive_url = '' AND link_checked_at < ?",
+		time.Now().UTC(), cutoff.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune articles: %v", err)
+	}
+	pruned, _ := res.RowsAffected()
+	return int(pruned), nil
+}
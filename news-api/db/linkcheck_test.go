@@ -0,0 +1,136 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckLinksAndPrune(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/no-head":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	for _, path := range []string{"/ok", "/gone", "/missing", "/no-head", "/flaky"} {
+		require.NoError(t, InsertArticle(models.NewsArticle{Title: path, URL: server.URL + path, SourceURL: "src", PublishedAt: now}))
+	}
+	// A copy in another organization shares the link status.
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "/gone", URL: server.URL + "/gone", SourceURL: "src", OrgID: 3, PublishedAt: now}))
+
+	checked, dead, err := CheckLinks(LinkCheckConfig{Sample: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 5, checked)
+	assert.Equal(t, 2, dead)
+	assert.Contains(t, requests, "GET /no-head")
+
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	assert.Len(t, articles, 3, "dead links are hidden by default")
+	articles, err = QueryArticles(ArticleFilter{IncludeDead: true})
+	require.NoError(t, err)
+	require.Len(t, articles, 5)
+	deadTitles := []string{}
+	for _, a := range articles {
+		if a.LinkDead {
+			deadTitles = append(deadTitles, a.Title)
+		}
+	}
+	assert.ElementsMatch(t, []string{"/gone", "/missing"}, deadTitles)
+	orgArticles, err := QueryArticles(ArticleFilter{OrgID: 3})
+	require.NoError(t, err)
+	assert.Empty(t, orgArticles)
+
+	// Dead links are not checked again.
+	requests = nil
+	checked, _, err = CheckLinks(LinkCheckConfig{Sample: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 3, checked)
+
+	pruned, err := PruneDeadArticles(now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, pruned, "links found dead after the cutoff are kept")
+	pruned, err = PruneDeadArticles(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 3, pruned)
+	count, err := GetArticleCount()
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestResolveArchiveLinks(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	lookups := 0
+	wayback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		assert.Equal(t, "/cdx/search/cdx", r.URL.Path)
+		assert.Equal(t, "-1", r.URL.Query().Get("limit"))
+		if strings.HasSuffix(r.URL.Query().Get("url"), "/never-archived") {
+			w.Write([]byte("[]"))
+			return
+		}
+		fmt.Fprintf(w, `[["timestamp","original"],["20240301120000",%q]]`, r.URL.Query().Get("url"))
+	}))
+	defer wayback.Close()
+	defer func(base string) { WaybackURL = base }(WaybackURL)
+	WaybackURL = wayback.URL
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	now := time.Now()
+	for _, path := range []string{"/archived", "/never-archived"} {
+		require.NoError(t, InsertArticle(models.NewsArticle{Title: path, URL: server.URL + path, SourceURL: "src", PublishedAt: now}))
+	}
+	_, dead, err := CheckLinks(LinkCheckConfig{Sample: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, dead)
+
+	found, err := ResolveArchiveLinks(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, found)
+	articles, err := QueryArticles(ArticleFilter{IncludeDead: true})
+	require.NoError(t, err)
+	archiveURLs := map[string]string{}
+	for _, a := range articles {
+		archiveURLs[a.Title] = a.ArchiveURL
+	}
+	assert.Equal(t, map[string]string{"/archived": wayback.URL + "/web/20240301120000/" + server.URL + "/archived", "/never-archived": ""}, archiveURLs)
+
+	// Links without a capture are not looked up again right away.
+	lookups = 0
+	found, err = ResolveArchiveLinks(10)
+	require.NoError(t, err)
+	assert.Zero(t, found)
+	assert.Zero(t, lookups)
+
+	pruned, err := PruneDeadArticles(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned, "articles with an archive link are kept")
+}
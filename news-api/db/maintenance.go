@@ -0,0 +1,412 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceOperations are the operations RunMaintenance can run, in the
+// order it runs them: "integrity_check" verifies the database file,
+// "analyze" refreshes the statistics the query planner uses, and "vacuum"
+// rebuilds the file without its free pages, returning them to the file
+// system.
+var MaintenanceOperations = []string{"integrity_check", "analyze", "vacuum"}
+
+// ErrMaintenanceRunning is returned by RunMaintenance while another run is in
+// progress.
+var ErrMaintenanceRunning = errors.New("database maintenance is already running")
+
+var maintenanceMutex sync.Mutex
+
+// DBStats describes the size of the database and what it is made of.
+type DBStats struct {
+	// Path is the database file, empty for an in-memory database.
+	Path string `json:"path,omitempty"`
+	// FileSize and WALSize are the sizes of the database file and of its
+	// write-ahead log, in bytes.
+	FileSize  int64 `json:"fileSize"`
+	WALSize   int64 `json:"walSize"`
+	PageSize  int64 `json:"pageSize"`
+	PageCount int64 `json:"pageCount"`
+	// FreePages are the pages left unused by deleted rows, which VACUUM
+	// returns to the file system, and Fragmentation their share of the
+	// pages.
+	FreePages     int64           `json:"freePages"`
+	Fragmentation float64         `json:"fragmentation"`
+	Tables        []TableStats    `json:"tables"`
+	Indexes       []IndexStats    `json:"indexes"`
+	LastRun       *MaintenanceRun `json:"lastMaintenance,omitempty"`
+}
+
+// TableStats counts the rows of a table. SizeBytes is only known when SQLite
+// is built with the dbstat table (SQLITE_ENABLE_DBSTAT_VTAB).
+type TableStats struct {
+	Name      string `json:"name"`
+	Rows      int64  `json:"rows"`
+	SizeBytes *int64 `json:"sizeBytes,omitempty"`
+}
+
+// IndexStats gives the size of an index. Without the dbstat table, the size
+// is estimated from the length of the indexed values.
+type IndexStats struct {
+	Name      string `json:"name"`
+	Table     string `json:"table"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Estimated bool   `json:"estimated,omitempty"`
+}
+
+// MaintenanceRun records a run of RunMaintenance.
+type MaintenanceRun struct {
+	ID         int64     `json:"id"`
+	Operations []string  `json:"operations"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Integrity is "ok" or the problems found by the integrity check, empty
+	// if it did not run.
+	Integrity string `json:"integrity,omitempty"`
+	// SizeBefore and SizeAfter are the sizes of the database in bytes.
+	SizeBefore int64  `json:"sizeBefore"`
+	SizeAfter  int64  `json:"sizeAfter"`
+	Error      string `json:"error,omitempty"`
+}
+
+func createMaintenanceTables() error {
+	createRunsSQL := `
+	CREATE TABLE IF NOT EXISTS maintenance_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		operations TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		integrity TEXT NOT NULL DEFAULT '',
+		size_before INTEGER NOT NULL,
+		size_after INTEGER NOT NULL,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	`
+	if _, err := db.Exec(createRunsSQL); err != nil {
+		return fmt.Errorf("failed to create maintenance_runs table: %v", err)
+	}
+	return nil
+}
+
+// StartMaintenanceJob schedules the "db-maintenance" job, which runs the
+// given operations every Sunday at 04:00, unless overridden in
+// JobScheduleOverrides.
+func StartMaintenanceJob(operations []string) {
+	err := RegisterJob(Job{Name: "db-maintenance", Schedule: "0 4 * * 0", Run: func() error {
+		run, err := RunMaintenance(operations)
+		if err != nil {
+			return fmt.Errorf("failed to maintain the database: %v", err)
+		}
+		if run.Integrity != "" && run.Integrity != "ok" {
+			return fmt.Errorf("database integrity check failed: %s", run.Integrity)
+		}
+		log.Printf("Database maintenance done: %s, %d bytes before, %d after.", strings.Join(run.Operations, ", "), run.SizeBefore, run.SizeAfter)
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling database maintenance: %v", err)
+	}
+}
+
+// GetDBStats returns the sizes of the database, its tables and its indexes.
+// Every table is counted, which takes a while on large databases.
+func GetDBStats() (DBStats, error) {
+	if db == nil {
+		return DBStats{}, fmt.Errorf("database connection is nil")
+	}
+	var stats DBStats
+	var err error
+	if stats.Path, err = databasePath(); err != nil {
+		return stats, err
+	}
+	if stats.Path != "" {
+		if info, err := os.Stat(stats.Path); err == nil {
+			stats.FileSize = info.Size()
+		}
+		if info, err := os.Stat(stats.Path + "-wal"); err == nil {
+			stats.WALSize = info.Size()
+		}
+	}
+	if err := db.QueryRow("PRAGMA page_size").Scan(&stats.PageSize); err != nil {
+		return stats, err
+	}
+	if err := db.QueryRow("PRAGMA page_count").Scan(&stats.PageCount); err != nil {
+		return stats, err
+	}
+	if err := db.QueryRow("PRAGMA freelist_count").Scan(&stats.FreePages); err != nil {
+		return stats, err
+	}
+	if stats.PageCount > 0 {
+		stats.Fragmentation = float64(stats.FreePages) / float64(stats.PageCount)
+	}
+
+	sizes, measured := objectSizes()
+	rows, err := db.Query("SELECT type, name, tbl_name FROM sqlite_master WHERE type IN ('table', 'index') AND name NOT LIKE 'sqlite_%' ORDER BY type DESC, name")
+	if err != nil {
+		return stats, err
+	}
+	type object struct{ kind, name, table string }
+	var objects []object
+	for rows.Next() {
+		var o object
+		if err := rows.Scan(&o.kind, &o.name, &o.table); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		objects = append(objects, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	stats.Tables, stats.Indexes = []TableStats{}, []IndexStats{}
+	for _, o := range objects {
+		if o.kind == "table" {
+			t := TableStats{Name: o.name}
+			if err := db.QueryRow("SELECT COUNT(*) FROM " + quoteIdentifier(o.name)).Scan(&t.Rows); err != nil {
+				return stats, fmt.Errorf("failed to count the rows of %s: %v", o.name, err)
+			}
+			if size, ok := sizes[o.name]; ok {
+				t.SizeBytes = &size
+			}
+			stats.Tables = append(stats.Tables, t)
+			continue
+		}
+		index := IndexStats{Name: o.name, Table: o.table, SizeBytes: sizes[o.name]}
+		if !measured {
+			if index.SizeBytes, err = estimateIndexSize(o.name, o.table); err != nil {
+				return stats, fmt.Errorf("failed to estimate the size of %s: %v", o.name, err)
+			}
+			index.Estimated = true
+		}
+		stats.Indexes = append(stats.Indexes, index)
+	}
+
+	run, err := lastMaintenanceRun()
+	if err != nil {
+		return stats, err
+	}
+	stats.LastRun = run
+	return stats, nil
+}
+
+// databasePath returns the file of the main database, empty if in memory.
+func databasePath() (string, error) {
+	rows, err := db.Query("PRAGMA database_list")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		}
+		if name == "main" {
+			return file, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// objectSizes returns the bytes used by each table and index according to
+// the dbstat table, and false if SQLite was built without it.
+func objectSizes() (map[string]int64, bool) {
+	rows, err := db.Query("SELECT name, SUM(pgsize) FROM dbstat GROUP BY name")
+	if err != nil {
+		return nil, false
+	}
+	defer rows.Close()
+	sizes := map[string]int64{}
+	for rows.Next() {
+		var name string
+		var size int64
+		if err := rows.Scan(&name, &size); err != nil {
+			return nil, false
+		}
+		sizes[name] = size
+	}
+	return sizes, rows.Err() == nil
+}
+
+// estimateIndexSize estimates the bytes used by an index as the length of
+// its keys plus the row IDs and cell headers, about 8 bytes per row.
+func estimateIndexSize(index, table string) (int64, error) {
+	rows, err := db.Query("PRAGMA index_info(" + quoteIdentifier(index) + ")")
+	if err != nil {
+		return 0, err
+	}
+	var lengths []string
+	for rows.Next() {
+		var seqno, cid int
+		var column *string
+		if err := rows.Scan(&seqno, &cid, &column); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		// Expressions have no column name.
+		if column != nil {
+			lengths = append(lengths, "IFNULL(LENGTH(CAST("+quoteIdentifier(*column)+" AS BLOB)), 0)")
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	keyBytes := "0"
+	if len(lengths) > 0 {
+		keyBytes = "SUM(" + strings.Join(lengths, " + ") + ")"
+	}
+	var size int64
+	err = db.QueryRow("SELECT IFNULL(" + keyBytes + ", 0) + COUNT(*) * 8 FROM " + quoteIdentifier(table)).Scan(&size)
+	return size, err
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// RunMaintenance runs the given MaintenanceOperations, all of them if none is
+// given, and records the run. Operations run in the order of
+// MaintenanceOperations. VACUUM holds the write lock while it rebuilds the
+// file. It returns ErrMaintenanceRunning if a run is in progress.
+func RunMaintenance(operations []string) (MaintenanceRun, error) {
+	ops, err := maintenanceOperations(operations)
+	if err != nil {
+		return MaintenanceRun{}, err
+	}
+	if !maintenanceMutex.TryLock() {
+		return MaintenanceRun{}, ErrMaintenanceRunning
+	}
+	defer maintenanceMutex.Unlock()
+	return runMaintenance(ops)
+}
+
+// StartMaintenance starts RunMaintenance in the background, since VACUUM
+// outlasts a request on large databases, and returns the operations it runs.
+// The run is reported by GetDBStats once finished.
+func StartMaintenance(operations []string) ([]string, error) {
+	ops, err := maintenanceOperations(operations)
+	if err != nil {
+		return nil, err
+	}
+	if !maintenanceMutex.TryLock() {
+		return nil, ErrMaintenanceRunning
+	}
+	go func() {
+		defer maintenanceMutex.Unlock()
+		if _, err := runMaintenance(ops); err != nil {
+			log.Printf("Error maintaining the database: %v", err)
+		}
+	}()
+	return ops, nil
+}
+
+// maintenanceOperations validates the requested operations and puts them in
+// the order they run.
+func maintenanceOperations(requested []string) ([]string, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	for _, op := range requested {
+		if !slices.Contains(MaintenanceOperations, op) {
+			return nil, fmt.Errorf("unknown maintenance operation %q", op)
+		}
+	}
+	ops := []string{}
+	for _, op := range MaintenanceOperations {
+		if len(requested) == 0 || slices.Contains(requested, op) {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func runMaintenance(operations []string) (MaintenanceRun, error) {
+	run := MaintenanceRun{Operations: operations, StartedAt: time.Now().UTC()}
+	var err error
+	if run.SizeBefore, err = databaseSize(); err != nil {
+		return run, err
+	}
+	for _, op := range run.Operations {
+		switch op {
+		case "integrity_check":
+			run.Integrity, err = checkIntegrity()
+		case "analyze":
+			_, err = db.Exec("ANALYZE")
+		case "vacuum":
+			_, err = db.Exec("VACUUM")
+		}
+		if err != nil {
+			run.Error = fmt.Sprintf("%s: %v", op, err)
+			break
+		}
+	}
+	if size, sizeErr := databaseSize(); sizeErr == nil {
+		run.SizeAfter = size
+	}
+	run.FinishedAt = time.Now().UTC()
+
+	result, recordErr := db.Exec(`INSERT INTO maintenance_runs(operations, started_at, finished_at, integrity, size_before, size_after, error)
+		VALUES(?, ?, ?, ?, ?, ?, ?)`, strings.Join(run.Operations, ","), run.StartedAt, run.FinishedAt, run.Integrity, run.SizeBefore, run.SizeAfter, run.Error)
+	if recordErr == nil {
+		run.ID, _ = result.LastInsertId()
+	}
+	if run.Error != "" {
+		return run, errors.New(run.Error)
+	}
+	if recordErr != nil {
+		return run, fmt.Errorf("failed to record the maintenance run: %v", recordErr)
+	}
+	return run, nil
+}
+
+// checkIntegrity returns "ok" or the first problems found by SQLite's
+// integrity check.
+func checkIntegrity() (string, error) {
+	rows, err := db.Query("PRAGMA integrity_check(100)")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return "", err
+		}
+		problems = append(problems, problem)
+	}
+	return strings.Join(problems, "\n"), rows.Err()
+}
+
+func databaseSize() (int64, error) {
+	var size int64
+	err := db.QueryRow("SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()").Scan(&size)
+	return size, err
+}
+
+func lastMaintenanceRun() (*MaintenanceRun, error) {
+	var run MaintenanceRun
+	var operations string
+	err := db.QueryRow(`SELECT id, operations, started_at, finished_at, integrity, size_before, size_after, error
+		FROM maintenance_runs ORDER BY id DESC LIMIT 1`).Scan(&run.ID, &operations, &run.StartedAt, &run.FinishedAt, &run.Integrity, &run.SizeBefore, &run.SizeAfter, &run.Error)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.Operations = strings.Split(operations, ",")
+	return &run, nil
+}
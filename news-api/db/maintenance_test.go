@@ -0,0 +1,68 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBStatsAndMaintenance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.db")
+	require.NoError(t, InitDB(path))
+	defer setupTestDB(t)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, InsertArticle(models.NewsArticle{Title: "Article", URL: fmt.Sprintf("https://news.example/%d", i), SourceURL: "src", PublishedAt: time.Now()}))
+	}
+	_, err := db.Exec("DELETE FROM articles WHERE id > 10")
+	require.NoError(t, err)
+
+	stats, err := GetDBStats()
+	require.NoError(t, err)
+	assert.Equal(t, path, stats.Path)
+	assert.Positive(t, stats.FileSize)
+	assert.Equal(t, stats.PageSize*stats.PageCount, stats.FileSize)
+	assert.Nil(t, stats.LastRun)
+	var articles *TableStats
+	for i, table := range stats.Tables {
+		if table.Name == "articles" {
+			articles = &stats.Tables[i]
+		}
+	}
+	require.NotNil(t, articles)
+	assert.EqualValues(t, 10, articles.Rows)
+	var index *IndexStats
+	for i, idx := range stats.Indexes {
+		if idx.Name == "idx_sourceUrl" {
+			index = &stats.Indexes[i]
+		}
+	}
+	require.NotNil(t, index)
+	assert.Equal(t, "articles", index.Table)
+	assert.Positive(t, index.SizeBytes)
+
+	_, err = RunMaintenance([]string{"defragment"})
+	assert.Error(t, err)
+	maintenanceMutex.Lock()
+	_, err = RunMaintenance(nil)
+	maintenanceMutex.Unlock()
+	assert.ErrorIs(t, err, ErrMaintenanceRunning)
+
+	run, err := RunMaintenance([]string{"vacuum", "integrity_check"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"integrity_check", "vacuum"}, run.Operations, "operations run in a fixed order")
+	assert.Equal(t, "ok", run.Integrity)
+	assert.LessOrEqual(t, run.SizeAfter, run.SizeBefore)
+
+	stats, err = GetDBStats()
+	require.NoError(t, err)
+	assert.Zero(t, stats.FreePages, "vacuum returns free pages")
+	require.NotNil(t, stats.LastRun)
+	assert.Equal(t, run.ID, stats.LastRun.ID)
+	assert.Equal(t, run.Operations, stats.LastRun.Operations)
+}
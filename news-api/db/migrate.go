@@ -0,0 +1,131 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// addColumnIfMissing adds a column to a table created by an older version of
+// the schema. SQLite has no ADD COLUMN IF NOT EXISTS.
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %v", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", table, err)
+		}
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %v", table, column, err)
+	}
+	return nil
+}
+
+// migrateArticlesTable upgrades an articles table from before multi-tenancy.
+// URLs used to be globally unique; they are now unique per organization, which
+// SQLite can only express by rebuilding the table.
+func migrateArticlesTable() error {
+	if err := addColumnIfMissing("articles", "org_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+
+	var schema string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'articles'").Scan(&schema); err != nil {
+		return fmt.Errorf("failed to read articles schema: %v", err)
+	}
+	if !strings.Contains(schema, "url TEXT NOT NULL UNIQUE") {
+		return nil
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rebuildSQL := `
+	CREATE TABLE articles_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		imageUrl TEXT,
+		url TEXT NOT NULL,
+		sourceUrl TEXT NOT NULL,
+		publishedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+		rank INTEGER DEFAULT 0,
+		category TEXT DEFAULT '',
+		org_id INTEGER NOT NULL DEFAULT 0
+	);
+	INSERT INTO articles_new (id, title, description, imageUrl, url, sourceUrl, publishedAt, rank, category, org_id)
+		SELECT id, title, description, imageUrl, url, sourceUrl, publishedAt, rank, category, org_id FROM articles;
+	DROP TABLE articles;
+	ALTER TABLE articles_new RENAME TO articles;
+	`
+	if _, err := tx.Exec(rebuildSQL); err != nil {
+		return fmt.Errorf("failed to rebuild articles table: %v", err)
+	}
+	return tx.Commit()
+}
+
+// migrateArticleColumns adds the article columns introduced after the articles
+// table was last rebuilt.
+func migrateArticleColumns() error {
+	columns := []struct{ name, definition string }{
+		{"author", "TEXT NOT NULL DEFAULT ''"},
+		{"guid", "TEXT NOT NULL DEFAULT ''"},
+		{"severity", "INTEGER NOT NULL DEFAULT 0"},
+		{"link_status", "INTEGER NOT NULL DEFAULT 0"},
+		{"link_checked_at", "DATETIME"},
+		{"description_html", "TEXT NOT NULL DEFAULT ''"},
+		{"content", "TEXT NOT NULL DEFAULT ''"},
+		{"ingested_at", "DATETIME"},
+		{"url_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"media_type", "TEXT NOT NULL DEFAULT ''"},
+		{"duration", "INTEGER NOT NULL DEFAULT 0"},
+		{"audio_url", "TEXT NOT NULL DEFAULT ''"},
+		{"paywalled", "INTEGER NOT NULL DEFAULT 0"},
+		{"archive_url", "TEXT NOT NULL DEFAULT ''"},
+		{"archive_checked_at", "DATETIME"},
+		{"deleted_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing("articles", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	// Articles stored before ingestion times were recorded count as ingested
+	// when they were published.
+	if _, err := db.Exec("UPDATE articles SET ingested_at = publishedAt WHERE ingested_at IS NULL"); err != nil {
+		return fmt.Errorf("failed to backfill ingestion times: %v", err)
+	}
+	return nil
+}
+
+// migratePublishedAtToUTC rewrites publish dates stored with a non-UTC offset
+// in UTC, so that they compare correctly with the UTC bounds of date filters.
+func migratePublishedAtToUTC() error {
+	_, err := db.Exec(`UPDATE articles SET publishedAt = strftime('%Y-%m-%d %H:%M:%f', publishedAt)
+		WHERE publishedAt GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND publishedAt NOT GLOB '*[+-]00:00'`)
+	if err != nil {
+		return fmt.Errorf("failed to convert publish dates to UTC: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNewsletterMessage = "From: =?utf-8?q?tl=3Bdr_sec?= <clint@tldrsec.com>\r\n" +
+	"To: feeds@example.com\r\n" +
+	"Subject: [tl;dr sec] #250 - Cloud IR, Supply Chain Attacks\r\n" +
+	"Message-ID: <250@tldrsec.com>\r\n" +
+	"Date: Thu, 03 Oct 2024 14:00:00 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/alternative; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Supply chain: a malicious npm package steals SSH keys =E2=80=94 https://example.com/npm-ssh\r\n" +
+	"--b1\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"%s\r\n" +
+	"--b1--\r\n"
+
+const testNewsletterHTML = `<html><body>
+<p><a href="https://tldrsec.com/p/250?view=browser">View in browser</a></p>
+<p><b>Cloud IR</b>: <a href="https://example.com/aws-ir">Responding to a compromised AWS account</a> walks through the first hour of an incident.</p>
+<ul><li><a href="https://example.com/xz-timeline">A timeline of the xz backdoor</a> - from the first commit to discovery. <a href="https://example.com/xz-timeline">Read more</a></li></ul>
+<p><a href="https://twitter.com/clintgibler">Follow me on Twitter for more</a></p>
+<p><a href="mailto:clint@tldrsec.com">Reply to this newsletter by email</a></p>
+<p><a href="https://tldrsec.com/unsubscribe?id=1">Unsubscribe from this list</a></p>
+</body></html>`
+
+func testNewsletterRaw() []byte {
+	encoded := base64Lines(testNewsletterHTML)
+	return []byte(fmt.Sprintf(testNewsletterMessage, encoded))
+}
+
+// base64Lines encodes s as a mail body, in lines of 76 characters.
+func base64Lines(s string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(s))
+	var lines []string
+	for len(encoded) > 76 {
+		lines = append(lines, encoded[:76])
+		encoded = encoded[76:]
+	}
+	return strings.Join(append(lines, encoded), "\r\n")
+}
+
+func TestParseNewsletter(t *testing.T) {
+	n, err := ParseNewsletter(testNewsletterRaw())
+	require.NoError(t, err)
+	assert.Equal(t, "<250@tldrsec.com>", n.MessageID)
+	assert.Equal(t, "tl;dr sec <clint@tldrsec.com>", n.From)
+	assert.Equal(t, "[tl;dr sec] #250 - Cloud IR, Supply Chain Attacks", n.Subject)
+	assert.Equal(t, time.Date(2024, 10, 3, 14, 0, 0, 0, time.UTC), n.Date)
+	assert.Equal(t, testNewsletterHTML, n.HTML)
+	assert.Equal(t, "Supply chain: a malicious npm package steals SSH keys — https://example.com/npm-ssh", strings.TrimSpace(n.Text))
+
+	_, err = ParseNewsletter([]byte("not a message"))
+	assert.Error(t, err)
+}
+
+func TestNewsletterItems(t *testing.T) {
+	received := time.Date(2024, 10, 3, 14, 5, 0, 0, time.UTC)
+	items := newsletterItems(Newsletter{From: "tl;dr sec <clint@tldrsec.com>", HTML: testNewsletterHTML}, received)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Responding to a compromised AWS account", items[0].Title)
+	assert.Equal(t, "https://example.com/aws-ir", items[0].Link)
+	assert.Equal(t, "Cloud IR : Responding to a compromised AWS account walks through the first hour of an incident.", items[0].Description)
+	assert.Equal(t, "tl;dr sec", items[0].Authors[0].Name)
+	assert.Equal(t, received, *items[0].PublishedParsed)
+	assert.Equal(t, "A timeline of the xz backdoor", items[1].Title)
+
+	items = newsletterItems(Newsletter{From: "news@risky.biz", Text: "Risky Bulletin\n\nOkta's support system was breached:\nhttps://example.com/okta.\n\nUnsubscribe: https://risky.biz/unsubscribe\n"}, received)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Okta's support system was breached", items[0].Title)
+	assert.Equal(t, "https://example.com/okta", items[0].Link)
+	assert.Equal(t, "news@risky.biz", items[0].Authors[0].Name)
+}
+
+func TestIngestNewsletters(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	n, err := ParseNewsletter(testNewsletterRaw())
+	require.NoError(t, err)
+	require.NoError(t, StoreNewsletter(n))
+	require.NoError(t, StoreNewsletter(n), "a newsletter received twice is stored once")
+	assert.Error(t, StoreNewsletter(Newsletter{Subject: "Empty"}))
+
+	fetchAndCacheNews([]string{NewsletterSource})
+	articles, err := QueryArticles(ArticleFilter{Source: NewsletterSource})
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+
+	// Processed newsletters are not extracted again.
+	fetchAndCacheNews([]string{NewsletterSource})
+	articles, err = QueryArticles(ArticleFilter{Source: NewsletterSource})
+	require.NoError(t, err)
+	assert.Len(t, articles, 2)
+}
+
+func TestPollNewsletterMailbox(t *testing.T) {
+	setupTestDB(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	raw := testNewsletterRaw()
+	var commands []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+			commands = append(commands, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "UID SEARCH"):
+				fmt.Fprint(conn, "* SEARCH 7\r\n")
+			case strings.HasPrefix(cmd, "UID FETCH"):
+				fmt.Fprintf(conn, "* 1 FETCH (UID 7 BODY[] {%d}\r\n%s)\r\n", len(raw), raw)
+			case cmd == "LOGOUT":
+				fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+				return
+			}
+			fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		}
+	}()
+
+	require.NoError(t, pollNewsletterMailbox("imap://feeds%40example.com:s3cret@"+listener.Addr().String()+"/Newsletters"))
+	<-done
+	assert.Equal(t, []string{
+		`LOGIN "feeds@example.com" "s3cret"`,
+		`SELECT "Newsletters"`,
+		"UID SEARCH UNSEEN",
+		"UID FETCH 7 BODY.PEEK[]",
+		`UID STORE 7 +FLAGS.SILENT (\Seen)`,
+		"LOGOUT",
+	}, commands)
+
+	var subject string
+	require.NoError(t, db.QueryRow("SELECT subject FROM newsletter_messages WHERE message_id = ?", "250@tldrsec.com").Scan(&subject))
+	assert.Equal(t, "[tl;dr sec] #250 - Cloud IR, Supply Chain Attacks", subject)
+
+	assert.Error(t, pollNewsletterMailbox("pop3://example.com"))
+}
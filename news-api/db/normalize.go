@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DescriptionMaxLength truncates descriptions longer than this many
+// characters at a word boundary, with an ellipsis, keeping the full text as
+// the article's content. Zero keeps them whole.
+var DescriptionMaxLength = 1000
+
+// Boilerplate holds per-source patterns removed from descriptions, keyed by
+// feed URL; "*" applies to every source. They extend defaultBoilerplate.
+var Boilerplate map[string][]*regexp.Regexp
+
+// defaultBoilerplate are trailers that publishing platforms append to feed
+// descriptions.
+var defaultBoilerplate = []*regexp.Regexp{
+	// WordPress: "The post <title> appeared first on <site>."
+	regexp.MustCompile(`(?i)\s*\bThe post .+ appeared first on .+$`),
+	regexp.MustCompile(`(?i)\s*\b(?:read more|continue reading|read the full (?:story|article)|click here to read)\b[^.!?]*$`),
+}
+
+// excerptMarker matches the "[…]" and "[...]" excerpt endings of WordPress feeds.
+var excerptMarker = regexp.MustCompile(`\s*\[(?:…|\.\.\.)\]\s*$`)
+
+// LoadBoilerplate reads per-source boilerplate patterns from a JSON file
+// mapping feed URLs (or "*" for all) to arrays of regular expressions.
+func LoadBoilerplate(path string) (map[string][]*regexp.Regexp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read boilerplate file: %v", err)
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse boilerplate file: %v", err)
+	}
+	patterns := make(map[string][]*regexp.Regexp, len(raw))
+	for source, exprs := range raw {
+		for _, expr := range exprs {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid boilerplate pattern for %s: %v", source, err)
+			}
+			patterns[source] = append(patterns[source], re)
+		}
+	}
+	return patterns, nil
+}
+
+// normalizeText decodes HTML entities, including doubly encoded ones such as
+// "&amp;#8217;", and collapses whitespace.
+func normalizeText(s string) string {
+	for i := 0; i < 3 && strings.Contains(s, "&"); i++ {
+		decoded := html.UnescapeString(s)
+		if decoded == s {
+			break
+		}
+		s = decoded
+	}
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizeDescription cleans the plain text of a description from source:
+// entities and whitespace are normalized and boilerplate is removed.
+func normalizeDescription(source, description string) string {
+	description = normalizeText(description)
+	patterns := append(append(append([]*regexp.Regexp{}, defaultBoilerplate...), Boilerplate["*"]...), Boilerplate[source]...)
+	for _, re := range patterns {
+		description = strings.TrimSpace(re.ReplaceAllString(description, ""))
+	}
+	return excerptMarker.ReplaceAllString(description, "…")
+}
+
+// truncateText shortens s to at most limit characters, cutting at the last
+// word boundary and appending an ellipsis. A limit of zero or less keeps s whole.
+func truncateText(s string, limit int) string {
+	if limit <= 0 || utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	runes := []rune(s)
+	cut := string(runes[:limit-1])
+	if i := strings.LastIndexAny(cut, " \t\n"); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:.-–—") + "…"
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeText(t *testing.T) {
+	assert.Equal(t, "Microsoft’s patch & more", normalizeText("Microsoft&amp;#8217;s  patch\n\t&amp;amp; more "))
+	assert.Equal(t, "AT&T breach", normalizeText("AT&amp;T breach"))
+	assert.Equal(t, "5 < 6", normalizeText("5 &lt; 6"))
+}
+
+func TestNormalizeDescription(t *testing.T) {
+	defer func() { Boilerplate = nil }()
+
+	for in, want := range map[string]string{
+		"Attackers exploited the flaw. The post Zero-day in VPN appeared first on Example News.": "Attackers exploited the flaw.",
+		"Attackers exploited the flaw. Read more":                                                "Attackers exploited the flaw.",
+		"Attackers exploited the flaw. Continue reading Zero-day in VPN at The Verge":            "Attackers exploited the flaw.",
+		"Attackers exploited the flaw and [&#8230;]":                                             "Attackers exploited the flaw and…",
+		"Readers more likely to patch.":                                                          "Readers more likely to patch.",
+	} {
+		assert.Equal(t, want, normalizeDescription("src", in), in)
+	}
+
+	path := filepath.Join(t.TempDir(), "boilerplate.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"src": ["(?i)\\s*Sponsored by .*$"], "*": ["^ADVERTISEMENT\\s*"]}`), 0o644))
+	patterns, err := LoadBoilerplate(path)
+	require.NoError(t, err)
+	Boilerplate = patterns
+	assert.Equal(t, "Story text.", normalizeDescription("src", "ADVERTISEMENT Story text. Sponsored by Acme"))
+	assert.Equal(t, "Story text. Sponsored by Acme", normalizeDescription("other", "ADVERTISEMENT Story text. Sponsored by Acme"))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"src": ["(unclosed"]}`), 0o644))
+	_, err = LoadBoilerplate(path)
+	assert.Error(t, err)
+}
+
+func TestArticleFromItemTruncatesDescription(t *testing.T) {
+	defer func(limit int) { DescriptionMaxLength = limit }(DescriptionMaxLength)
+	DescriptionMaxLength = 20
+
+	article := articleFromItem("src", &gofeed.Item{Title: "t", Description: "<p>Attackers exploited the flaw in VPN appliances.</p>"})
+	assert.Equal(t, "Attackers exploited…", article.Description)
+	assert.Equal(t, "Attackers exploited the flaw in VPN appliances.", article.Content)
+	assert.Empty(t, article.DescriptionHTML)
+
+	article = articleFromItem("src", &gofeed.Item{Title: "t", Description: "Short enough."})
+	assert.Equal(t, "Short enough.", article.Description)
+	assert.Empty(t, article.Content)
+}
+
+func TestGetOrgArticle(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Long", Description: "Attackers…", Content: "Attackers exploited the flaw.", URL: "https://example.com/long", SourceURL: "src", PublishedAt: time.Now()}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Short", Description: "Whole.", URL: "https://example.com/short", SourceURL: "src", PublishedAt: time.Now().Add(-time.Hour)}))
+	articles, err := QueryArticles(ArticleFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+
+	long, err := GetOrgArticle(0, articles[0].ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, "Attackers…", long.Description)
+	assert.Empty(t, long.Content)
+
+	long, err = GetOrgArticle(0, articles[0].ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Attackers exploited the flaw.", long.Content)
+
+	short, err := GetOrgArticle(0, articles[1].ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, "Whole.", short.Content)
+
+	_, err = GetOrgArticle(1, articles[0].ID, true)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
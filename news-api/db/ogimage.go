@@ -0,0 +1,183 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// FetchPreviewImages enables scraping og:image/twitter:image from article pages
+// when a feed item has no image.
+var FetchPreviewImages = true
+
+const (
+	// previewImageTimeout bounds fetching an article page for its preview image.
+	previewImageTimeout = 5 * time.Second
+	// previewImageMaxBytes limits how much of a page is read; the meta tags are in the head.
+	previewImageMaxBytes = 512 << 10
+	// previewImageCacheSize bounds the number of remembered lookups.
+	previewImageCacheSize = 10000
+)
+
+// previewImageCache remembers resolved preview images by article URL, including
+// pages without one, so each page is fetched at most once per process.
+var previewImageCache = struct {
+	sync.Mutex
+	images       map[string]string
+	hits, misses int64
+}{images: map[string]string{}}
+
+// PreviewImageCacheStats reports the size and hit rate of the preview image cache.
+func PreviewImageCacheStats() models.CacheStats {
+	previewImageCache.Lock()
+	defer previewImageCache.Unlock()
+	return models.NewCacheStats(len(previewImageCache.images), 0, previewImageCache.hits, previewImageCache.misses)
+}
+
+// resolvePreviewImage returns the preview image of an article page, or an empty
+// string if it has none or cannot be fetched. Articles already stored reuse the
+// stored image instead of fetching the page again.
+func resolvePreviewImage(pageURL string) string {
+	previewImageCache.Lock()
+	image, ok := previewImageCache.images[pageURL]
+	if ok {
+		previewImageCache.hits++
+	} else {
+		previewImageCache.misses++
+	}
+	previewImageCache.Unlock()
+	if ok {
+		return image
+	}
+
+	if stored, found := storedImageURL(pageURL); found {
+		image = stored
+	} else {
+		image = fetchPreviewImage(pageURL)
+	}
+
+	previewImageCache.Lock()
+	if len(previewImageCache.images) >= previewImageCacheSize {
+		previewImageCache.images = map[string]string{}
+	}
+	previewImageCache.images[pageURL] = image
+	previewImageCache.Unlock()
+	return image
+}
+
+// storedImageURL returns the image of an already stored article with the given
+// URL, and whether any such article exists.
+func storedImageURL(pageURL string) (string, bool) {
+	var image string
+	err := db.QueryRow("SELECT COALESCE(imageUrl, '') FROM articles WHERE url = ? ORDER BY imageUrl DESC LIMIT 1", pageURL).Scan(&image)
+	return image, err == nil
+}
+
+func fetchPreviewImage(pageURL string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") {
+		return ""
+	}
+
+	resp, err := fetcher.PoliteClient(previewImageTimeout).Get(pageURL)
+	if err != nil {
+		log.Printf("Error fetching %s for its preview image: %v", pageURL, err)
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return ""
+	}
+	return parsePreviewImage(io.LimitReader(resp.Body, previewImageMaxBytes), resp.Request.URL)
+}
+
+// parsePreviewImage reads the og:image (preferred) or twitter:image meta tag of
+// an HTML document, resolved against base. It stops at the end of the head.
+func parsePreviewImage(r io.Reader, base *url.URL) string {
+	var ogImage, twitterImage string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return absoluteHTTPURL(base, ogImage, twitterImage)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return absoluteHTTPURL(base, ogImage, twitterImage)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" {
+				return absoluteHTTPURL(base, ogImage, twitterImage)
+			}
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+			var key, content string
+			for {
+				attr, value, more := z.TagAttr()
+				switch strings.ToLower(string(attr)) {
+				case "property", "name":
+					key = strings.ToLower(string(value))
+				case "content":
+					content = strings.TrimSpace(string(value))
+				}
+				if !more {
+					break
+				}
+			}
+			switch key {
+			case "og:image", "og:image:url", "og:image:secure_url":
+				if ogImage == "" {
+					ogImage = content
+				}
+			case "twitter:image", "twitter:image:src":
+				if twitterImage == "" {
+					twitterImage = content
+				}
+			}
+		}
+	}
+}
+
+func absoluteHTTPURL(base *url.URL, candidates ...string) string {
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		u, err := base.Parse(candidate)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		return u.String()
+	}
+	return ""
+}
+
+// IsArticleImage reports whether a stored article uses imageURL as its image,
+// or a source uses it as its icon.
+func IsArticleImage(imageURL string) (bool, error) {
+	if db == nil {
+		return false, fmt.Errorf("database connection is nil")
+	}
+	var found int
+	err := db.QueryRow("SELECT 1 FROM articles WHERE imageUrl = ? UNION ALL SELECT 1 FROM sources WHERE icon_url = ? LIMIT 1", imageURL, imageURL).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePreviewImage(t *testing.T) {
+	base, _ := url.Parse("https://news.example/2024/story")
+	for name, tc := range map[string]struct {
+		page, want string
+	}{
+		"og image":           {`<head><meta property="og:image" content="https://cdn.example/a.jpg"></head>`, "https://cdn.example/a.jpg"},
+		"og wins":            {`<meta name="twitter:image" content="/t.png"><meta content="/og.png" property="og:image" />`, "https://news.example/og.png"},
+		"twitter fallback":   {`<meta name="twitter:image" content="t.png">`, "https://news.example/2024/t.png"},
+		"ignored after head": {`<head></head><body><meta property="og:image" content="/late.png"></body>`, ""},
+		"non-http scheme":    {`<meta property="og:image" content="javascript:alert(1)">`, ""},
+	} {
+		assert.Equal(t, tc.want, parsePreviewImage(strings.NewReader(tc.page), base), name)
+	}
+}
+
+func TestResolvePreviewImage(t *testing.T) {
+	setupTestDB(t)
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><meta property="og:image" content="/cover.jpg"></head></html>`))
+	}))
+	defer server.Close()
+
+	pageURL := server.URL + "/article"
+	assert.Equal(t, server.URL+"/cover.jpg", resolvePreviewImage(pageURL))
+	assert.Equal(t, server.URL+"/cover.jpg", resolvePreviewImage(pageURL))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches), "lookups are cached")
+
+	// Pages of stored articles are not fetched again.
+	storedURL := server.URL + "/stored"
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Stored", URL: storedURL, ImageURL: "https://cdn.example/stored.jpg", PublishedAt: time.Now()}))
+	assert.Equal(t, "https://cdn.example/stored.jpg", resolvePreviewImage(storedURL))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
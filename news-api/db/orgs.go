@@ -0,0 +1,396 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ErrOrgExists is returned when creating an organization whose name is taken.
+var ErrOrgExists = errors.New("organization already exists")
+
+// watchlistWeight is the rank added for each watchlist term an article mentions,
+// the same as the built-in high-impact keywords.
+const watchlistWeight = 5
+
+func createOrgTables() error {
+	createOrgsSQL := `
+	CREATE TABLE IF NOT EXISTS organizations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS org_sources (
+		org_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		PRIMARY KEY (org_id, url)
+	);
+	CREATE TABLE IF NOT EXISTS org_keywords (
+		org_id INTEGER NOT NULL,
+		keyword TEXT NOT NULL,
+		weight INTEGER NOT NULL,
+		PRIMARY KEY (org_id, keyword)
+	);
+	CREATE TABLE IF NOT EXISTS org_watchlist (
+		org_id INTEGER NOT NULL,
+		term TEXT NOT NULL,
+		PRIMARY KEY (org_id, term)
+	);
+	`
+	if _, err := db.Exec(createOrgsSQL); err != nil {
+		return fmt.Errorf("failed to create organization tables: %v", err)
+	}
+	return nil
+}
+
+// CreateOrganization registers a new tenant.
+func CreateOrganization(name string) (models.Organization, error) {
+	if db == nil {
+		return models.Organization{}, fmt.Errorf("database connection is nil")
+	}
+	now := time.Now().UTC()
+	res, err := db.Exec("INSERT INTO organizations(name, created_at) VALUES(?, ?)", name, now)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return models.Organization{}, ErrOrgExists
+		}
+		return models.Organization{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Organization{}, err
+	}
+	return models.Organization{ID: id, Name: name, CreatedAt: now}, nil
+}
+
+// GetOrganizations lists all tenants by name.
+func GetOrganizations() ([]models.Organization, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT id, name, created_at FROM organizations ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, org)
+	}
+	return orgs, rows.Err()
+}
+
+// GetOrganization returns a tenant, or sql.ErrNoRows if it does not exist.
+func GetOrganization(id int64) (models.Organization, error) {
+	if db == nil {
+		return models.Organization{}, fmt.Errorf("database connection is nil")
+	}
+	var org models.Organization
+	err := db.QueryRow("SELECT id, name, created_at FROM organizations WHERE id = ?", id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	return org, err
+}
+
+// GetUserOrg returns the organization of a user, or sql.ErrNoRows if the user does not exist.
+func GetUserOrg(username string) (int64, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	var orgID int64
+	err := db.QueryRow("SELECT org_id FROM users WHERE username = ?", username).Scan(&orgID)
+	return orgID, err
+}
+
+// SetUserOrg moves a user into an organization, or back to the shared feed with 0.
+func SetUserOrg(username string, orgID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("UPDATE users SET org_id = ? WHERE username = ?", orgID, username)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateAPIKey issues a new API key for an organization. Only a hash of the key
+// is stored, so the returned secret cannot be retrieved again.
+func CreateAPIKey(orgID int64, name string) (string, models.APIKey, error) {
+	if db == nil {
+		return "", models.APIKey{}, fmt.Errorf("database connection is nil")
+	}
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", models.APIKey{}, err
+	}
+	secret := "tf_" + hex.EncodeToString(raw)
+	key := models.APIKey{OrgID: orgID, Name: name, CreatedAt: time.Now().UTC()}
+
+	res, err := db.Exec("INSERT INTO api_keys(org_id, name, key_hash, created_at) VALUES(?, ?, ?, ?)", orgID, name, hashToken(secret), key.CreatedAt)
+	if err != nil {
+		return "", models.APIKey{}, err
+	}
+	key.ID, err = res.LastInsertId()
+	return secret, key, err
+}
+
+// GetAPIKeys lists an organization's API keys without their secrets.
+func GetAPIKeys(orgID int64) ([]models.APIKey, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT id, org_id, name, created_at, last_used_at FROM api_keys WHERE org_id = ? ORDER BY id", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&key.ID, &key.OrgID, &key.Name, &key.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			key.LastUsedAt = &lastUsed.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey revokes an organization's API key, returning sql.ErrNoRows if it does not exist.
+func DeleteAPIKey(orgID, keyID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM api_keys WHERE id = ? AND org_id = ?", keyID, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = db.Exec("DELETE FROM ranking_profiles WHERE principal = ?", APIKeyPrincipal(keyID))
+	return err
+}
+
+// GetOrgByAPIKey resolves an API key to its organization ID.
+func GetOrgByAPIKey(secret string) (int64, error) {
+	key, err := GetAPIKey(secret)
+	return key.OrgID, err
+}
+
+// GetAPIKey resolves an API key secret to the key, returning
+// ErrInvalidCredentials if it is unknown, and records its use.
+func GetAPIKey(secret string) (models.APIKey, error) {
+	if db == nil {
+		return models.APIKey{}, fmt.Errorf("database connection is nil")
+	}
+	var key models.APIKey
+	err := db.QueryRow("SELECT id, org_id, name, created_at FROM api_keys WHERE key_hash = ?", hashToken(secret)).Scan(&key.ID, &key.OrgID, &key.Name, &key.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.APIKey{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	db.Exec("UPDATE api_keys SET last_used_at = ? WHERE key_hash = ?", time.Now().UTC(), hashToken(secret))
+	return key, nil
+}
+
+// GetOrgSettings returns an organization's sources, keyword rules and watchlist.
+func GetOrgSettings(orgID int64) (models.OrgSettings, error) {
+	if db == nil {
+		return models.OrgSettings{}, fmt.Errorf("database connection is nil")
+	}
+	settings := models.OrgSettings{Sources: []string{}, Keywords: map[string]int{}, Watchlist: []string{}}
+
+	rows, err := db.Query("SELECT url FROM org_sources WHERE org_id = ? ORDER BY url", orgID)
+	if err != nil {
+		return settings, err
+	}
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return settings, err
+		}
+		settings.Sources = append(settings.Sources, url)
+	}
+	rows.Close()
+
+	rows, err = db.Query("SELECT keyword, weight FROM org_keywords WHERE org_id = ?", orgID)
+	if err != nil {
+		return settings, err
+	}
+	for rows.Next() {
+		var keyword string
+		var weight int
+		if err := rows.Scan(&keyword, &weight); err != nil {
+			rows.Close()
+			return settings, err
+		}
+		settings.Keywords[keyword] = weight
+	}
+	rows.Close()
+
+	rows, err = db.Query("SELECT term FROM org_watchlist WHERE org_id = ? ORDER BY term", orgID)
+	if err != nil {
+		return settings, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return settings, err
+		}
+		settings.Watchlist = append(settings.Watchlist, term)
+	}
+	return settings, rows.Err()
+}
+
+// AddOrgSource adds a feed to an organization's source list.
+func AddOrgSource(orgID int64, url string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec("INSERT OR IGNORE INTO org_sources(org_id, url) VALUES(?, ?)", orgID, url)
+	return err
+}
+
+// RemoveOrgSource removes a feed from an organization's source list, returning
+// sql.ErrNoRows if it was not configured.
+func RemoveOrgSource(orgID int64, url string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM org_sources WHERE org_id = ? AND url = ?", orgID, url)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetOrgKeywords replaces an organization's keyword scoring rules. Keywords are
+// matched case-insensitively.
+func SetOrgKeywords(orgID int64, keywords map[string]int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM org_keywords WHERE org_id = ?", orgID); err != nil {
+		return err
+	}
+	for keyword, weight := range keywords {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if keyword == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO org_keywords(org_id, keyword, weight) VALUES(?, ?, ?)", orgID, keyword, weight); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetOrgWatchlist replaces an organization's watchlist. Terms are matched
+// case-insensitively.
+func SetOrgWatchlist(orgID int64, terms []string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM org_watchlist WHERE org_id = ?", orgID); err != nil {
+		return err
+	}
+	for _, term := range terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO org_watchlist(org_id, term) VALUES(?, ?)", orgID, term); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// orgFeed is an organization's source list along with its scoring rules.
+type orgFeed struct {
+	orgID    int64
+	settings models.OrgSettings
+}
+
+// getOrgFeeds loads the settings of every organization with at least one source.
+func getOrgFeeds() ([]orgFeed, error) {
+	rows, err := db.Query("SELECT DISTINCT org_id FROM org_sources ORDER BY org_id")
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	feeds := make([]orgFeed, 0, len(ids))
+	for _, id := range ids {
+		settings, err := GetOrgSettings(id)
+		if err != nil {
+			return nil, err
+		}
+		feeds = append(feeds, orgFeed{orgID: id, settings: settings})
+	}
+	return feeds, nil
+}
+
+// scoreForOrg adds an organization's keyword and watchlist weights to the
+// built-in rank of an article.
+func scoreForOrg(article models.NewsArticle, settings models.OrgSettings) int {
+	rank := 0
+	for _, hit := range rankHits(article, settings) {
+		rank += hit.Weight
+	}
+	return rank
+}
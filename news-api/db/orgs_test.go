@@ -0,0 +1,137 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateArticlesTableFromGlobalURLs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+	old, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = old.Exec(`CREATE TABLE articles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		description TEXT,
+		imageUrl TEXT,
+		url TEXT NOT NULL UNIQUE,
+		sourceUrl TEXT NOT NULL,
+		publishedAt DATETIME DEFAULT CURRENT_TIMESTAMP,
+		rank INTEGER DEFAULT 0,
+		category TEXT DEFAULT ''
+	);
+	INSERT INTO articles(title, description, imageUrl, url, sourceUrl, rank) VALUES('Existing', '', '', 'https://example.com/a', 'src', 4);`)
+	require.NoError(t, err)
+	require.NoError(t, old.Close())
+
+	require.NoError(t, InitDB(path))
+	defer db.Close()
+
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Existing", articles[0].Title)
+	assert.Equal(t, int64(0), articles[0].OrgID)
+
+	// The same URL can now be stored once per organization.
+	isNew, err := insertArticle(models.NewsArticle{Title: "Copy", URL: "https://example.com/a", OrgID: 7, PublishedAt: time.Now()})
+	require.NoError(t, err)
+	assert.True(t, isNew)
+	isNew, err = insertArticle(models.NewsArticle{Title: "Dup", URL: "https://example.com/a", OrgID: 7, PublishedAt: time.Now()})
+	require.NoError(t, err)
+	assert.False(t, isNew)
+}
+
+func TestOrganizationsAndAPIKeys(t *testing.T) {
+	setupTestDB(t)
+
+	org, err := CreateOrganization("Acme")
+	require.NoError(t, err)
+	_, err = CreateOrganization("acme")
+	assert.ErrorIs(t, err, ErrOrgExists)
+
+	secret, key, err := CreateAPIKey(org.ID, "ci")
+	require.NoError(t, err)
+	assert.NotEmpty(t, secret)
+
+	orgID, err := GetOrgByAPIKey(secret)
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, orgID)
+	_, err = GetOrgByAPIKey("tf_unknown")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	keys, err := GetAPIKeys(org.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	require.NoError(t, DeleteAPIKey(org.ID, key.ID))
+	_, err = GetOrgByAPIKey(secret)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestOrgSettingsAndScoring(t *testing.T) {
+	setupTestDB(t)
+	org, err := CreateOrganization("Acme")
+	require.NoError(t, err)
+
+	require.NoError(t, AddOrgSource(org.ID, "https://acme.example/feed"))
+	require.NoError(t, SetOrgKeywords(org.ID, map[string]int{"Acme": 4}))
+	require.NoError(t, SetOrgWatchlist(org.ID, []string{" Fortinet "}))
+
+	settings, err := GetOrgSettings(org.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://acme.example/feed"}, settings.Sources)
+	assert.Equal(t, map[string]int{"acme": 4}, settings.Keywords)
+	assert.Equal(t, []string{"fortinet"}, settings.Watchlist)
+
+	feeds, err := getOrgFeeds()
+	require.NoError(t, err)
+	require.Len(t, feeds, 1)
+	assert.Equal(t, org.ID, feeds[0].orgID)
+
+	article := models.NewsArticle{Title: "Fortinet flaw exploited at Acme", Category: "Cybersecurity"}
+	assert.Equal(t, calculateRank(article)+4+watchlistWeight, scoreForOrg(article, settings))
+	assert.Equal(t, calculateRank(article), scoreForOrg(article, models.OrgSettings{}))
+
+	assert.ErrorIs(t, RemoveOrgSource(org.ID, "https://other.example/feed"), sql.ErrNoRows)
+	require.NoError(t, RemoveOrgSource(org.ID, "https://acme.example/feed"))
+}
+
+func TestArticlesAreScopedToOrganizations(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	passwordIterations = 1000
+
+	now := time.Now()
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Shared", URL: "u1", Rank: 6, PublishedAt: now}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Private", URL: "u1", Rank: 1, OrgID: 3, PublishedAt: now}))
+
+	shared, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, "Shared", shared[0].Title)
+
+	private, err := QueryArticles(ArticleFilter{OrgID: 3})
+	require.NoError(t, err)
+	require.Len(t, private, 1)
+	assert.Equal(t, "Private", private[0].Title)
+
+	score, err := GetOrgThreatScore(3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, score.LowRankCount)
+	assert.Equal(t, 0, score.HighRankCount)
+
+	// Users cannot bookmark articles outside their organization.
+	user, err := CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+	assert.ErrorIs(t, SetBookmark(user.ID, private[0].ID, true), sql.ErrNoRows)
+	require.NoError(t, SetBookmark(user.ID, shared[0].ID, true))
+}
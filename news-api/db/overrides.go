@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"news-api/models"
+)
+
+// ThreatLevels are the levels a threat override can pin, from highest to lowest.
+var ThreatLevels = []string{"Code Red", "Attention", "Business as Usual"}
+
+func createThreatOverrideTables() error {
+	createOverridesSQL := `
+	CREATE TABLE IF NOT EXISTS threat_overrides (
+		org_id INTEGER PRIMARY KEY,
+		level TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		expires_at DATETIME NOT NULL,
+		created_by TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createOverridesSQL); err != nil {
+		return fmt.Errorf("failed to create threat_overrides table: %v", err)
+	}
+	return nil
+}
+
+// SetThreatOverride pins an organization's threat level until the override
+// expires, replacing any previous override.
+func SetThreatOverride(o models.ThreatOverride) (models.ThreatOverride, error) {
+	if db == nil {
+		return o, fmt.Errorf("database connection is nil")
+	}
+	o.ExpiresAt = o.ExpiresAt.UTC()
+	o.CreatedAt = time.Now().UTC()
+	_, err := db.Exec(`INSERT INTO threat_overrides(org_id, level, reason, expires_at, created_by, created_at) VALUES(?, ?, ?, ?, ?, ?)
+		ON CONFLICT(org_id) DO UPDATE SET level = excluded.level, reason = excluded.reason, expires_at = excluded.expires_at,
+		created_by = excluded.created_by, created_at = excluded.created_at`,
+		o.OrgID, o.Level, o.Reason, o.ExpiresAt, o.CreatedBy, o.CreatedAt)
+	return o, err
+}
+
+// GetThreatOverride returns an organization's active override, or
+// sql.ErrNoRows if it has none or it expired.
+func GetThreatOverride(orgID int64) (models.ThreatOverride, error) {
+	if db == nil {
+		return models.ThreatOverride{}, fmt.Errorf("database connection is nil")
+	}
+	o := models.ThreatOverride{OrgID: orgID}
+	err := db.QueryRow("SELECT level, reason, expires_at, created_by, created_at FROM threat_overrides WHERE org_id = ?", orgID).
+		Scan(&o.Level, &o.Reason, &o.ExpiresAt, &o.CreatedBy, &o.CreatedAt)
+	if err != nil {
+		return o, err
+	}
+	if !o.ExpiresAt.After(time.Now()) {
+		return o, sql.ErrNoRows
+	}
+	return o, nil
+}
+
+// ClearThreatOverride removes an organization's override, returning
+// sql.ErrNoRows if it had no active one.
+func ClearThreatOverride(orgID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM threat_overrides WHERE org_id = ? AND expires_at > ?", orgID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// applyThreatOverride replaces the computed threat level with the
+// organization's active override, keeping the computed level alongside.
+func applyThreatOverride(orgID int64, score *ThreatScore) error {
+	o, err := GetThreatOverride(orgID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	score.ComputedLevel = score.ThreatLevel
+	score.ThreatLevel = o.Level
+	score.Override = &o
+	return nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreatOverride(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM threat_overrides")
+	require.NoError(t, err)
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Quiet", URL: "u1", Rank: 1, PublishedAt: time.Now()}))
+
+	score, err := GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, "Business as Usual", score.ThreatLevel)
+	assert.Nil(t, score.Override)
+
+	_, err = SetThreatOverride(models.ThreatOverride{Level: "Code Red", Reason: "IR-42", ExpiresAt: time.Now().Add(time.Hour), CreatedBy: "admin"})
+	require.NoError(t, err)
+	score, err = GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, "Code Red", score.ThreatLevel)
+	assert.Equal(t, "Business as Usual", score.ComputedLevel)
+	require.NotNil(t, score.Override)
+	assert.Equal(t, "IR-42", score.Override.Reason)
+
+	other, err := GetOrgThreatScore(5)
+	require.NoError(t, err)
+	assert.Nil(t, other.Override, "overrides are per organization")
+
+	require.NoError(t, ClearThreatOverride(0))
+	assert.ErrorIs(t, ClearThreatOverride(0), sql.ErrNoRows)
+
+	_, err = SetThreatOverride(models.ThreatOverride{Level: "Attention", ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = GetThreatOverride(0)
+	assert.ErrorIs(t, err, sql.ErrNoRows, "expired overrides are ignored")
+	score, err = GetTodayThreatScore()
+	require.NoError(t, err)
+	assert.Equal(t, "Business as Usual", score.ThreatLevel)
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// ExposureCategory is the category of the items of paste-site monitors, where
+// leaked credentials and data often show up before a breach is reported.
+const ExposureCategory = "Exposure"
+
+// PasteSources are the paste-monitoring sources fetched with the feeds. They
+// may be RSS, Atom or JSON Feed documents, or JSON APIs listing pastes (see
+// parsePasteFeed). Their items are stored in ExposureCategory.
+var PasteSources []string
+
+// maxPasteFeedBytes bounds the response read from a paste source.
+const maxPasteFeedBytes = 8 << 20
+
+// pasteBaseURL is where pastes that are only known by their ID are linked to,
+// as psbdmp-style APIs list Pastebin pastes.
+const pasteBaseURL = "https://pastebin.com/"
+
+// credentialPattern matches "user@example.com:password" lines of credential
+// dumps. The passwords are not stored.
+var credentialPattern = regexp.MustCompile(`([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})[:;|]\S+`)
+
+func isPasteSource(source string) bool {
+	return slices.Contains(PasteSources, source)
+}
+
+// fetchPasteFeed fetches a paste source and converts it into a feed.
+func fetchPasteFeed(client *http.Client, source string) (*gofeed.Feed, error) {
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPasteFeedBytes))
+	if err != nil {
+		return nil, err
+	}
+	return parsePasteFeed(body)
+}
+
+// pasteItem is a paste listed by a paste-monitoring JSON API. Field names
+// vary between services, so the common spellings are all accepted.
+type pasteItem struct {
+	ID          json.RawMessage `json:"id"`
+	Key         string          `json:"key"`
+	Title       string          `json:"title"`
+	Tags        string          `json:"tags"`
+	Text        string          `json:"text"`
+	Content     string          `json:"content"`
+	Snippet     string          `json:"snippet"`
+	URL         string          `json:"url"`
+	Link        string          `json:"link"`
+	Time        json.RawMessage `json:"time"`
+	Date        json.RawMessage `json:"date"`
+	CreatedAt   json.RawMessage `json:"created_at"`
+	Description string          `json:"description"`
+}
+
+// parsePasteFeed reads a feed document or a JSON array of pastes, bare or
+// under "data", "items" or "results". Pastes without a URL link to Pastebin by
+// their ID. Credentials in the items are redacted.
+func parsePasteFeed(body []byte) (*gofeed.Feed, error) {
+	var feed *gofeed.Feed
+	trimmed := bytes.TrimSpace(body)
+	// JSON Feed documents declare their version; other JSON is a paste API.
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') && !bytes.Contains(trimmed, []byte("jsonfeed.org/version")) {
+		pastes, err := decodePastes(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		feed = &gofeed.Feed{}
+		for _, paste := range pastes {
+			if item := paste.feedItem(); item != nil {
+				feed.Items = append(feed.Items, item)
+			}
+		}
+	} else {
+		var err error
+		if feed, err = gofeed.NewParser().Parse(bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range feed.Items {
+		item.Title = redactCredentials(item.Title)
+		item.Description = redactCredentials(item.Description)
+		item.Content = redactCredentials(item.Content)
+	}
+	return feed, nil
+}
+
+// decodePastes reads a JSON array of pastes, bare or wrapped in an object.
+func decodePastes(data []byte) ([]pasteItem, error) {
+	var pastes []pasteItem
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &pastes); err != nil {
+			return nil, fmt.Errorf("failed to parse pastes: %v", err)
+		}
+		return pastes, nil
+	}
+	var wrapped struct {
+		Data    []pasteItem `json:"data"`
+		Items   []pasteItem `json:"items"`
+		Results []pasteItem `json:"results"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("failed to parse pastes: %v", err)
+	}
+	return slices.Concat(wrapped.Data, wrapped.Items, wrapped.Results), nil
+}
+
+// feedItem converts a paste into a feed item, or returns nil if it has
+// neither an ID nor a URL.
+func (p pasteItem) feedItem() *gofeed.Item {
+	id := p.Key
+	if raw := strings.Trim(string(p.ID), `"`); raw != "" && raw != "null" {
+		id = raw
+	}
+	link := firstNonEmpty(p.URL, p.Link)
+	if link == "" && id != "" {
+		link = pasteBaseURL + id
+	}
+	if link == "" {
+		return nil
+	}
+	item := &gofeed.Item{
+		Title:       strings.TrimSpace(p.Title),
+		Description: firstNonEmpty(p.Text, p.Content, p.Snippet, p.Description),
+		Link:        link,
+		GUID:        firstNonEmpty(id, link),
+	}
+	if item.Title == "" {
+		item.Title = "Paste " + firstNonEmpty(id, link)
+		if tags := strings.TrimSpace(p.Tags); tags != "" {
+			item.Title += ": " + tags
+		}
+	}
+	for _, raw := range []json.RawMessage{p.Time, p.Date, p.CreatedAt} {
+		if t, ok := parsePasteTime(raw); ok {
+			item.PublishedParsed = &t
+			break
+		}
+	}
+	return item
+}
+
+// parsePasteTime reads a Unix timestamp, in seconds or milliseconds, or an
+// RFC 3339 or "2006-01-02 15:04:05" date.
+func parsePasteTime(raw json.RawMessage) (time.Time, bool) {
+	s := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if s == "" || s == "null" {
+		return time.Time{}, false
+	}
+	if n, err := strconv.ParseFloat(s, 64); err == nil && n > 0 {
+		if n > 1e12 {
+			return time.UnixMilli(int64(n)).UTC(), true
+		}
+		return time.Unix(int64(n), 0).UTC(), true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// redactCredentials keeps the accounts of "email:password" pairs and drops
+// the passwords.
+func redactCredentials(s string) string {
+	return credentialPattern.ReplaceAllString(s, "$1:[redacted]")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
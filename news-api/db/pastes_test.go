@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePasteFeed(t *testing.T) {
+	feed, err := parsePasteFeed([]byte(`[
+		{"id": "aB3dE5", "tags": "acme.com", "time": 1714557600, "text": "admin@acme.com:hunter2\nvpn.acme.com"},
+		{"id": 42, "title": "Config dump", "date": "2024-05-01 10:00:00", "url": "https://paste.example/42"},
+		{"tags": "nothing to link"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 2)
+	item := feed.Items[0]
+	assert.Equal(t, "Paste aB3dE5: acme.com", item.Title)
+	assert.Equal(t, "https://pastebin.com/aB3dE5", item.Link)
+	assert.Equal(t, "aB3dE5", item.GUID)
+	assert.Equal(t, "admin@acme.com:[redacted]\nvpn.acme.com", item.Description)
+	require.NotNil(t, item.PublishedParsed)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *item.PublishedParsed)
+	item = feed.Items[1]
+	assert.Equal(t, "Config dump", item.Title)
+	assert.Equal(t, "https://paste.example/42", item.Link)
+	assert.Equal(t, "42", item.GUID)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *item.PublishedParsed)
+
+	feed, err = parsePasteFeed([]byte(`{"data": [{"key": "xyz", "time": "1714557600000"}]}`))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "https://pastebin.com/xyz", feed.Items[0].Link)
+	assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), *feed.Items[0].PublishedParsed)
+
+	feed, err = parsePasteFeed([]byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Pastes</title>` +
+		`<item><guid>p1</guid><title>Leak of bob@corp.example|s3cret!</title><link>https://paste.example/p1</link></item></channel></rss>`))
+	require.NoError(t, err)
+	require.Len(t, feed.Items, 1)
+	assert.Equal(t, "Leak of bob@corp.example:[redacted]", feed.Items[0].Title)
+
+	_, err = parsePasteFeed([]byte(`not a feed`))
+	assert.Error(t, err)
+}
+
+func TestIngestPasteSource(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"id": "leak1", "tags": "combolist", "time": %d, "text": "database dump: ops@victim.example:Winter2024!"},`+
+			`{"id": "boring1", "time": %d, "text": "hello world"}]`, time.Now().Unix(), time.Now().Unix())
+	}))
+	defer server.Close()
+	source := server.URL + "/api/search"
+	defer func(sources []string) { PasteSources = sources }(PasteSources)
+	PasteSources = []string{source}
+
+	fetchAndCacheNews([]string{source})
+	articles, err := QueryArticles(ArticleFilter{SortBy: "severity"})
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+	leak := articles[0]
+	assert.Equal(t, ExposureCategory, leak.Category)
+	assert.Equal(t, "https://pastebin.com/leak1", leak.URL)
+	assert.NotContains(t, leak.Description, "Winter2024!")
+	assert.GreaterOrEqual(t, leak.Severity, SeverityHigh)
+	assert.Less(t, articles[1].Severity, SeverityMedium)
+	assert.Contains(t, CategoryNames(), ExposureCategory)
+}
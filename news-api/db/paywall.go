@@ -0,0 +1,63 @@
+package db
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PaywalledSources lists the feeds, by URL, and the sites, by domain (which
+// includes their subdomains), whose articles are paywalled. A domain also
+// flags articles linking to the site from other sources, e.g. aggregators.
+var PaywalledSources []string
+
+// paywallMarkers recognize paywalled article pages: schema.org's
+// isAccessibleForFree, which publishers set for search engines, the
+// article:content_tier meta tag and the paywall containers of common
+// subscription platforms.
+var paywallMarkers = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)"isAccessibleForFree"\s*:\s*"?false`),
+	regexp.MustCompile(`(?i)<meta[^>]+(?:property|name)=["']article:content_tier["'][^>]+content=["'](?:locked|metered)["']`),
+	regexp.MustCompile(`(?i)<meta[^>]+content=["'](?:locked|metered)["'][^>]+(?:property|name)=["']article:content_tier["']`),
+	regexp.MustCompile(`(?i)\b(?:class|id)=["'][^"']*\b(?:paywall|subscriber-only|subscription-required|piano-offer|tp-modal)\b`),
+}
+
+// isPaywalledSource reports whether an article from source linking to
+// articleURL is paywalled according to PaywalledSources.
+func isPaywalledSource(source, articleURL string) bool {
+	var host string
+	if u, err := url.Parse(articleURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	for _, entry := range PaywalledSources {
+		if entry == source {
+			return true
+		}
+		domain := strings.TrimPrefix(strings.ToLower(entry), "www.")
+		if host != "" && !strings.Contains(domain, "/") && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPaywalledPage reports whether an article page is paywalled, judging by
+// its HTTP status (402 Payment Required) and markup.
+func isPaywalledPage(status int, page string) bool {
+	if status == http.StatusPaymentRequired {
+		return true
+	}
+	for _, re := range paywallMarkers {
+		if re.MatchString(page) {
+			return true
+		}
+	}
+	return false
+}
+
+// markPaywalled flags the articles with the given URL as paywalled.
+func markPaywalled(link string) error {
+	_, err := db.Exec("UPDATE articles SET paywalled = 1 WHERE url = ? AND paywalled = 0", link)
+	return err
+}
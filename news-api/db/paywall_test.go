@@ -0,0 +1,70 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+func TestIsPaywalledPage(t *testing.T) {
+	assert.True(t, isPaywalledPage(http.StatusPaymentRequired, ""))
+	assert.True(t, isPaywalledPage(http.StatusOK, `<script type="application/ld+json">{"@type": "NewsArticle", "isAccessibleForFree": "False"}</script>`))
+	assert.True(t, isPaywalledPage(http.StatusOK, `<meta property="article:content_tier" content="locked">`))
+	assert.True(t, isPaywalledPage(http.StatusOK, `<div class="article-body paywall">Subscribe to read</div>`))
+	assert.False(t, isPaywalledPage(http.StatusOK, `<script type="application/ld+json">{"isAccessibleForFree": true}</script>`))
+	assert.False(t, isPaywalledPage(http.StatusOK, `<meta property="article:content_tier" content="free"><p>Paywalls are annoying.</p>`))
+}
+
+func TestPaywalledArticles(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(sources []string) { PaywalledSources = sources }(PaywalledSources)
+	PaywalledSources = []string{"https://feeds.example.com/premium", "wsj.com"}
+
+	assert.True(t, isPaywalledSource("https://feeds.example.com/premium", "https://example.com/a"))
+	assert.True(t, isPaywalledSource("https://www.google.com/alerts/feeds/1/2", "https://www.wsj.com/articles/breach"))
+	assert.False(t, isPaywalledSource("https://example.com/feed", "https://notwsj.com/a"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/locked" {
+			w.Write([]byte(`<html><head><script type="application/ld+json">{"isAccessibleForFree": false}</script></head><body><article><p>The first paragraph of the story.</p></article></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><body><article><p>The whole story.</p></article></body></html>`))
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	article := articleFromItem("https://feeds.example.com/premium", &gofeed.Item{Title: "Premium analysis", Link: "https://example.com/premium", PublishedParsed: &now})
+	assert.True(t, article.Paywalled)
+	require.NoError(t, InsertArticle(article))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Metered story", URL: server.URL + "/locked", SourceURL: "https://example.com/feed", Category: "Cybersecurity", Severity: 90, PublishedAt: now}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Free story", URL: server.URL + "/free", SourceURL: "https://example.com/feed", Category: "Cybersecurity", Severity: 90, PublishedAt: now}))
+
+	// Archiving the bodies finds the paywall of the metered story.
+	_, err := ArchiveArticleBodies(BodyArchiveConfig{MinSeverity: 50, Batch: 10})
+	require.NoError(t, err)
+
+	paywalled, free := true, false
+	articles, err := QueryArticles(ArticleFilter{Paywalled: &paywalled, SortBy: "idAsc"})
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+	assert.Equal(t, "Premium analysis", articles[0].Title)
+	assert.Equal(t, "Metered story", articles[1].Title)
+	assert.True(t, articles[1].Paywalled)
+	assert.True(t, ArticleFilter{Paywalled: &paywalled}.Matches(articles[1]))
+
+	articles, err = QueryArticles(ArticleFilter{Paywalled: &free})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Free story", articles[0].Title)
+	assert.True(t, ArticleFilter{Paywalled: &free}.Matches(articles[0]))
+}
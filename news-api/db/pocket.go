@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"news-api/models"
+)
+
+func createPocketTables() error {
+	createPocketSQL := `
+	CREATE TABLE IF NOT EXISTS pocket_accounts (
+		user_id INTEGER PRIMARY KEY,
+		access_token TEXT NOT NULL,
+		username TEXT NOT NULL,
+		connected_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS pocket_requests (
+		state_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		request_token TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createPocketSQL); err != nil {
+		return fmt.Errorf("failed to create pocket tables: %v", err)
+	}
+	return nil
+}
+
+// StartPocketAuthorization remembers the request token of a user's Pocket
+// authorization until ttl passes, under the random state that identifies it
+// when Pocket redirects back. Only a hash of the state is stored.
+func StartPocketAuthorization(state string, userID int64, requestToken string, ttl time.Duration) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	now := time.Now().UTC()
+	if _, err := db.Exec("DELETE FROM pocket_requests WHERE expires_at < ?", now); err != nil {
+		return fmt.Errorf("failed to prune pocket requests: %v", err)
+	}
+	_, err := db.Exec("INSERT INTO pocket_requests(state_hash, user_id, request_token, expires_at) VALUES(?, ?, ?, ?)",
+		hashToken(state), userID, requestToken, now.Add(ttl))
+	return err
+}
+
+// FinishPocketAuthorization returns the user and request token of the
+// authorization identified by state and forgets it, so it can only be
+// finished once. It returns sql.ErrNoRows for unknown or expired states.
+func FinishPocketAuthorization(state string) (int64, string, error) {
+	if db == nil {
+		return 0, "", fmt.Errorf("database connection is nil")
+	}
+	var userID int64
+	var requestToken string
+	err := db.QueryRow("DELETE FROM pocket_requests WHERE state_hash = ? AND expires_at >= ? RETURNING user_id, request_token",
+		hashToken(state), time.Now().UTC()).Scan(&userID, &requestToken)
+	return userID, requestToken, err
+}
+
+// SavePocketAccount connects a Pocket account to a user, replacing the
+// previous one.
+func SavePocketAccount(userID int64, accessToken, username string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec("INSERT OR REPLACE INTO pocket_accounts(user_id, access_token, username, connected_at) VALUES(?, ?, ?, ?)",
+		userID, accessToken, username, time.Now().UTC())
+	return err
+}
+
+// GetPocketAccount returns the Pocket account of a user, or sql.ErrNoRows if
+// none is connected.
+func GetPocketAccount(userID int64) (models.PocketAccount, error) {
+	if db == nil {
+		return models.PocketAccount{}, fmt.Errorf("database connection is nil")
+	}
+	var account models.PocketAccount
+	err := db.QueryRow("SELECT access_token, username, connected_at FROM pocket_accounts WHERE user_id = ?", userID).
+		Scan(&account.AccessToken, &account.Username, &account.ConnectedAt)
+	return account, err
+}
+
+// DeletePocketAccount disconnects the Pocket account of a user. It returns
+// sql.ErrNoRows if none was connected.
+func DeletePocketAccount(userID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM pocket_accounts WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPocketAccounts(t *testing.T) {
+	setupTestDB(t)
+	user, err := CreateUser("reader", "correct horse battery")
+	require.NoError(t, err)
+
+	require.NoError(t, StartPocketAuthorization("state", user.ID, "request-token", time.Minute))
+	require.NoError(t, StartPocketAuthorization("expired", user.ID, "old-token", -time.Minute))
+	userID, requestToken, err := FinishPocketAuthorization("state")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+	assert.Equal(t, "request-token", requestToken)
+	_, _, err = FinishPocketAuthorization("state")
+	assert.ErrorIs(t, err, sql.ErrNoRows, "an authorization can only be finished once")
+	_, _, err = FinishPocketAuthorization("expired")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = GetPocketAccount(user.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	require.NoError(t, SavePocketAccount(user.ID, "old", "reader@example.com"))
+	require.NoError(t, SavePocketAccount(user.ID, "access-token", "reader@example.com"))
+	account, err := GetPocketAccount(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", account.AccessToken)
+	assert.Equal(t, "reader@example.com", account.Username)
+
+	require.NoError(t, DeletePocketAccount(user.ID))
+	assert.ErrorIs(t, DeletePocketAccount(user.ID), sql.ErrNoRows)
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/models"
+)
+
+// MediaTypePodcast is the media type of podcast episodes: items with an audio
+// enclosure.
+const MediaTypePodcast = "podcast"
+
+// audioExtensions recognize audio enclosures that are served without a type.
+var audioExtensions = []string{".mp3", ".m4a", ".aac", ".ogg", ".opus", ".wav"}
+
+// podcastEpisode returns the audio URL and the duration in seconds, 0 if
+// unknown, of a podcast episode, or false if the item has no audio
+// enclosure.
+func podcastEpisode(item *gofeed.Item) (string, int, bool) {
+	for _, enclosure := range item.Enclosures {
+		if enclosure == nil || enclosure.URL == "" {
+			continue
+		}
+		audio := strings.HasPrefix(strings.ToLower(enclosure.Type), "audio/")
+		if enclosure.Type == "" {
+			ext := strings.ToLower(path.Ext(strings.SplitN(enclosure.URL, "?", 2)[0]))
+			audio = slices.Contains(audioExtensions, ext)
+		}
+		if !audio {
+			continue
+		}
+		duration := 0
+		if item.ITunesExt != nil {
+			duration = parseEpisodeDuration(item.ITunesExt.Duration)
+		}
+		return enclosure.URL, duration, true
+	}
+	return "", 0, false
+}
+
+// parseEpisodeDuration reads an itunes:duration, given in seconds or as
+// "MM:SS" or "HH:MM:SS". It returns 0 if the duration is missing or invalid.
+func parseEpisodeDuration(s string) int {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+	seconds := 0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// showNotes returns the markup of a podcast episode's show notes: its full
+// content, or else its iTunes summary or description.
+func showNotes(item *gofeed.Item) string {
+	if strings.TrimSpace(item.Content) != "" {
+		return item.Content
+	}
+	if item.ITunesExt != nil && strings.TrimSpace(item.ITunesExt.Summary) != "" {
+		return item.ITunesExt.Summary
+	}
+	return item.Description
+}
+
+// applyPodcastEpisode marks an article built from a podcast episode, keeping
+// its show notes in Content, and reports whether the item is one.
+func applyPodcastEpisode(article *models.NewsArticle, item *gofeed.Item) bool {
+	audioURL, duration, ok := podcastEpisode(item)
+	if !ok {
+		return false
+	}
+	article.MediaType = MediaTypePodcast
+	article.AudioURL = audioURL
+	article.Duration = duration
+	if article.URL == "" {
+		article.URL = audioURL
+	}
+	if article.ImageURL == "" && item.ITunesExt != nil {
+		article.ImageURL = item.ITunesExt.Image
+	}
+	if notes := normalizeText(stripPolicy.Sanitize(showNotes(item))); notes != "" {
+		article.Content = notes
+		if article.Description == "" {
+			article.Description = truncateText(notes, DescriptionMaxLength)
+		}
+	}
+	return true
+}
+
+// storeShowNotes indexes the show notes of a newly stored podcast episode as
+// its archived body, which /news searches and /news/{id}/body serves. The
+// episode page is then never fetched by the body archive.
+func storeShowNotes(article models.NewsArticle) error {
+	if article.Content == "" {
+		return nil
+	}
+	_, err := db.Exec("INSERT OR IGNORE INTO article_bodies(url, status, html, text, fetched_at) VALUES(?, ?, ?, ?, ?)",
+		article.URL, http.StatusOK, "", article.Content, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store show notes: %v", err)
+	}
+	return nil
+}
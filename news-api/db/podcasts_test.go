@@ -0,0 +1,82 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodcastEpisode(t *testing.T) {
+	item := &gofeed.Item{Enclosures: []*gofeed.Enclosure{
+		{URL: "https://example.com/cover.jpg", Type: "image/jpeg"},
+		{URL: "https://cdn.example.com/ep42.mp3?source=rss"},
+	}}
+	audioURL, duration, ok := podcastEpisode(item)
+	assert.True(t, ok)
+	assert.Equal(t, "https://cdn.example.com/ep42.mp3?source=rss", audioURL)
+	assert.Zero(t, duration)
+
+	_, _, ok = podcastEpisode(&gofeed.Item{Enclosures: []*gofeed.Enclosure{{URL: "https://example.com/talk.mp4", Type: "video/mp4"}}})
+	assert.False(t, ok)
+
+	assert.Equal(t, 3723, parseEpisodeDuration("1:02:03"))
+	assert.Equal(t, 1503, parseEpisodeDuration("25:03"))
+	assert.Equal(t, 1800, parseEpisodeDuration("1800"))
+	assert.Zero(t, parseEpisodeDuration("about an hour"))
+}
+
+func TestIngestPodcast(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Risky Business</title>
+<item>
+	<title>Risky Business #742 -- Okta support system breached</title>
+	<link>https://risky.biz/RB742/</link>
+	<guid>rb742</guid>
+	<pubDate>%s</pubDate>
+	<description>This week's news.</description>
+	<content:encoded><![CDATA[<p>This week's news, then a sponsor interview about <b>passkey rollouts</b> in large enterprises.</p>]]></content:encoded>
+	<enclosure url="https://media.example.com/RB742.mp3" length="57000000" type="audio/mpeg"/>
+	<itunes:duration>59:31</itunes:duration>
+	<itunes:image href="https://risky.biz/cover.png"/>
+</item>
+</channel></rss>`, time.Now().UTC().Format(time.RFC1123Z))
+	}))
+	defer server.Close()
+
+	fetchAndCacheNews([]string{server.URL})
+	episodes, err := QueryArticles(ArticleFilter{MediaType: MediaTypePodcast})
+	require.NoError(t, err)
+	require.Len(t, episodes, 1)
+	episode := episodes[0]
+	assert.Equal(t, "https://media.example.com/RB742.mp3", episode.AudioURL)
+	assert.Equal(t, 59*60+31, episode.Duration)
+	assert.Equal(t, "https://risky.biz/cover.png", episode.ImageURL)
+	assert.Equal(t, "This week's news.", episode.Description)
+
+	// The show notes are searchable although the description does not have them.
+	found, err := QueryArticles(ArticleFilter{Search: "passkey rollouts"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, episode.ID, found[0].ID)
+	body, err := GetArticleBody(0, episode.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "This week's news, then a sponsor interview about passkey rollouts in large enterprises.", body.Text)
+
+	texts, err := QueryArticles(ArticleFilter{MediaType: "text"})
+	require.NoError(t, err)
+	assert.Empty(t, texts)
+}
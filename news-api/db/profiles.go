@@ -0,0 +1,108 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"news-api/models"
+)
+
+// MaxProfileTerms caps how many terms a ranking profile can weigh.
+const MaxProfileTerms = 100
+
+func createProfileTables() error {
+	createProfilesSQL := `
+	CREATE TABLE IF NOT EXISTS ranking_profiles (
+		principal TEXT NOT NULL,
+		term TEXT NOT NULL,
+		weight INTEGER NOT NULL,
+		PRIMARY KEY (principal, term)
+	);
+	`
+	if _, err := db.Exec(createProfilesSQL); err != nil {
+		return fmt.Errorf("failed to create ranking_profiles table: %v", err)
+	}
+	return nil
+}
+
+// UserPrincipal names a user as the owner of a ranking profile.
+func UserPrincipal(userID int64) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// APIKeyPrincipal names an API key as the owner of a ranking profile.
+func APIKeyPrincipal(keyID int64) string {
+	return fmt.Sprintf("key:%d", keyID)
+}
+
+// GetRankingProfile returns the term weights of a principal's ranking
+// profile, empty if it has none.
+func GetRankingProfile(principal string) (map[string]int, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT term, weight FROM ranking_profiles WHERE principal = ?", principal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	weights := map[string]int{}
+	for rows.Next() {
+		var term string
+		var weight int
+		if err := rows.Scan(&term, &weight); err != nil {
+			return nil, err
+		}
+		weights[term] = weight
+	}
+	return weights, rows.Err()
+}
+
+// SetRankingProfile replaces a principal's ranking profile. Terms are matched
+// case-insensitively; an empty profile deletes it.
+func SetRankingProfile(principal string, weights map[string]int) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM ranking_profiles WHERE principal = ?", principal); err != nil {
+		return err
+	}
+	for term, weight := range weights {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" || weight == 0 {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO ranking_profiles(principal, term, weight) VALUES(?, ?, ?)", principal, term, weight); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ApplyRankingProfile adds the weights of the profile terms each article
+// mentions in its title or description to its rank.
+func ApplyRankingProfile(articles []models.NewsArticle, weights map[string]int) {
+	for i := range articles {
+		content := strings.ToLower(articles[i].Title + " " + articles[i].Description)
+		for term, weight := range weights {
+			if strings.Contains(content, term) {
+				articles[i].Rank += weight
+			}
+		}
+	}
+}
+
+// SortByRank orders articles by descending rank, keeping the order of
+// articles of equal rank.
+func SortByRank(articles []models.NewsArticle) {
+	sort.SliceStable(articles, func(i, j int) bool { return articles[i].Rank > articles[j].Rank })
+}
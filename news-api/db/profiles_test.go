@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankingProfiles(t *testing.T) {
+	setupTestDB(t)
+	principal := UserPrincipal(7)
+	require.NoError(t, SetRankingProfile(principal, map[string]int{" OT/ICS ": 10, "Funding": -5, "ignored": 0}))
+	weights, err := GetRankingProfile(principal)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"ot/ics": 10, "funding": -5}, weights)
+
+	articles := []models.NewsArticle{
+		{Title: "Startup closes funding round", Rank: 8},
+		{Title: "Exploit hits OT/ICS networks", Rank: 3},
+		{Title: "Unrelated", Rank: 5},
+	}
+	ApplyRankingProfile(articles, weights)
+	SortByRank(articles)
+	assert.Equal(t, []int{13, 5, 3}, []int{articles[0].Rank, articles[1].Rank, articles[2].Rank})
+	assert.Equal(t, "Exploit hits OT/ICS networks", articles[0].Title)
+
+	require.NoError(t, SetRankingProfile(principal, nil))
+	weights, err = GetRankingProfile(principal)
+	require.NoError(t, err)
+	assert.Empty(t, weights)
+}
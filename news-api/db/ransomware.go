@@ -0,0 +1,101 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// RansomwareGroups is the dictionary of ransomware groups and their aliases
+// that articles are tagged with.
+var RansomwareGroups = extract.DefaultRansomwareGroups
+
+// LoadRansomwareGroups reads a ransomware group dictionary from a JSON file
+// mapping group names to arrays of aliases.
+func LoadRansomwareGroups(path string) (extract.RansomwareDictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ransomware groups file: %v", err)
+	}
+	var groups extract.RansomwareDictionary
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("failed to parse ransomware groups file: %v", err)
+	}
+	for group, aliases := range groups {
+		if _, ok := NormalizeTagName(group); !ok {
+			return nil, fmt.Errorf("invalid ransomware group name %q", group)
+		}
+		for _, alias := range aliases {
+			if strings.TrimSpace(alias) == "" {
+				return nil, fmt.Errorf("ransomware group %q has an empty alias", group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// migrateRansomwareTags moves the tags of the built-in ransomware groups,
+// which were threat actor tags before groups were tagged separately, to
+// TagKindRansomware.
+func migrateRansomwareTags() error {
+	for group := range extract.DefaultRansomwareGroups {
+		if _, err := db.Exec("UPDATE tags SET kind = ? WHERE name = ? AND kind = ?", TagKindRansomware, group, TagKindActor); err != nil {
+			return fmt.Errorf("failed to migrate ransomware tags: %v", err)
+		}
+	}
+	return nil
+}
+
+// GetRansomwareLog returns the mentions of ransomware groups by the articles
+// matching the filter, newest first, one per group and article, with the
+// victims each article names. A non-empty group restricts the log to that
+// group. The filter's Limit and Offset page through mentions.
+func GetRansomwareLog(f ArticleFilter, group string) ([]models.RansomwareMention, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	whereClauses, args := f.where()
+	query := `SELECT t.name,
+			COALESCE((SELECT GROUP_CONCAT(v.name, char(31)) FROM article_tags vat JOIN tags v ON v.id = vat.tag_id
+				WHERE vat.article_id = a.id AND v.kind = '` + TagKindVictim + `'), ''),
+			` + qualifiedArticleColumns("a") + `
+		FROM articles a
+		JOIN article_tags at ON at.article_id = a.id
+		JOIN tags t ON t.id = at.tag_id AND t.kind = ?
+		WHERE a.id IN (SELECT id FROM articles WHERE ` + strings.Join(whereClauses, " AND ") + `)`
+	args = append([]interface{}{TagKindRansomware}, args...)
+	if group != "" {
+		query += " AND t.name = ?"
+		args = append(args, group)
+	}
+	query += " ORDER BY a.publishedAt DESC, a.id DESC, t.name"
+	if f.Limit > 0 || f.Offset > 0 {
+		limit := f.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, f.Offset)
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mentions := []models.RansomwareMention{}
+	for rows.Next() {
+		var m models.RansomwareMention
+		targets := append([]interface{}{&m.Group, (*tagList)(&m.Victims)}, articleScanTargets(&m.Article)...)
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, m)
+	}
+	return mentions, rows.Err()
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRansomwareLog(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().Add(-time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "LockBit claims attack on Acme Logistics", URL: "u1", PublishedAt: now.Add(-48 * time.Hour)},
+		{Title: "ALPHV and LockBit affiliates overlap", URL: "u2", PublishedAt: now.Add(-24 * time.Hour)},
+		{Title: "Lazarus targets exchanges", URL: "u3", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	mentions, err := GetRansomwareLog(ArticleFilter{}, "")
+	require.NoError(t, err)
+	require.Len(t, mentions, 3)
+	assert.Equal(t, "BlackCat", mentions[0].Group)
+	assert.Equal(t, "u2", mentions[0].Article.URL)
+	assert.Equal(t, "LockBit", mentions[1].Group)
+	assert.Equal(t, "u1", mentions[2].Article.URL)
+	assert.Equal(t, []string{"Acme Logistics"}, mentions[2].Victims)
+
+	mentions, err = GetRansomwareLog(ArticleFilter{Limit: 1, Offset: 1}, "LockBit")
+	require.NoError(t, err)
+	require.Len(t, mentions, 1)
+	assert.Equal(t, "u1", mentions[0].Article.URL)
+
+	tags, err := GetTags(0, TagKindVictim)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "Acme Logistics", tags[0].Name)
+}
+
+func TestMigrateRansomwareTags(t *testing.T) {
+	setupTestDB(t)
+	_, err := db.Exec("INSERT INTO tags(name, kind) VALUES('LockBit', ?), ('Lazarus', ?)", TagKindActor, TagKindActor)
+	require.NoError(t, err)
+	require.NoError(t, migrateRansomwareTags())
+
+	var kind string
+	require.NoError(t, db.QueryRow("SELECT kind FROM tags WHERE name = 'LockBit'").Scan(&kind))
+	assert.Equal(t, TagKindRansomware, kind)
+	require.NoError(t, db.QueryRow("SELECT kind FROM tags WHERE name = 'Lazarus'").Scan(&kind))
+	assert.Equal(t, TagKindActor, kind)
+}
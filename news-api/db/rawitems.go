@@ -0,0 +1,223 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"news-api/models"
+)
+
+// ArchiveRawItems enables keeping the parsed feed item behind every new article,
+// so enrichment can be re-run later without fetching the feed again.
+var ArchiveRawItems = true
+
+// reprocessBatchSize is the number of archived items ReprocessArticles loads at a time.
+const reprocessBatchSize = 200
+
+func createRawItemTables() error {
+	createRawItemsSQL := `
+	CREATE TABLE IF NOT EXISTS raw_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		source_url TEXT NOT NULL,
+		item_key TEXT NOT NULL,
+		fetched_at DATETIME NOT NULL,
+		data BLOB NOT NULL,
+		UNIQUE(source_url, item_key)
+	);
+	`
+	if _, err := db.Exec(createRawItemsSQL); err != nil {
+		return fmt.Errorf("failed to create raw_items table: %v", err)
+	}
+	return nil
+}
+
+// itemKey identifies a feed item within its source: its GUID, or its link when
+// it has none. Stored articles are matched to their item the same way.
+func itemKey(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	return item.Link
+}
+
+// archiveRawItem stores a feed item as gzip-compressed JSON. Items already
+// archived are left unchanged.
+func archiveRawItem(source string, item *gofeed.Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT OR IGNORE INTO raw_items(source_url, item_key, fetched_at, data) VALUES(?, ?, ?, ?)",
+		source, itemKey(item), time.Now().UTC(), buf.Bytes())
+	return err
+}
+
+func decodeRawItem(data []byte) (*gofeed.Item, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	var item gofeed.Item
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// rawItemJoin matches articles to their archived feed item.
+const rawItemJoin = "JOIN raw_items r ON r.source_url = a.sourceUrl AND r.item_key = CASE WHEN a.guid != '' THEN a.guid ELSE a.url END"
+
+// GetRawItem returns the archived feed item of an article, or sql.ErrNoRows if
+// the article does not exist or its item was not archived.
+func GetRawItem(articleID int64) (*gofeed.Item, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	var data []byte
+	if err := db.QueryRow("SELECT r.data FROM articles a "+rawItemJoin+" WHERE a.id = ?", articleID).Scan(&data); err != nil {
+		return nil, err
+	}
+	return decodeRawItem(data)
+}
+
+// ReprocessArticles re-runs enrichment over every article with an archived feed
+// item: the description is sanitized again under the current SanitizePolicy, the rank recomputed with the
+// current rules and the automatic tags reassigned. Manual tags, images and
+// publication dates are kept. It returns the number of articles updated.
+func ReprocessArticles() (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	settings := map[int64]models.OrgSettings{}
+	updated := 0
+	var lastID int64
+	for {
+		batch, err := loadRawItemBatch(lastID)
+		if err != nil {
+			return updated, err
+		}
+		if len(batch) == 0 {
+			return updated, nil
+		}
+
+		dbMutex.Lock()
+		for _, stored := range batch {
+			lastID = stored.article.ID
+			ok, err := reprocessArticle(stored, settings)
+			if err != nil {
+				dbMutex.Unlock()
+				return updated, err
+			}
+			if ok {
+				updated++
+			}
+		}
+		dbMutex.Unlock()
+	}
+}
+
+// reprocessArticle re-runs enrichment over a stored article from its archived
+// item, caching organization settings in settings. It returns false if the
+// item cannot be decoded. The caller holds dbMutex.
+func reprocessArticle(stored rawItemRow, settings map[int64]models.OrgSettings) (bool, error) {
+	item, err := decodeRawItem(stored.data)
+	if err != nil {
+		log.Printf("Error decoding archived item of article %d: %v", stored.article.ID, err)
+		return false, nil
+	}
+	article := articleFromItem(stored.article.SourceURL, item)
+	article.ID = stored.article.ID
+	article.OrgID = stored.article.OrgID
+	if article.ImageURL == "" {
+		article.ImageURL = stored.article.ImageURL
+	}
+	orgSettings, ok := settings[article.OrgID]
+	if !ok && article.OrgID != 0 {
+		if orgSettings, err = GetOrgSettings(article.OrgID); err != nil {
+			return false, err
+		}
+		settings[article.OrgID] = orgSettings
+	}
+	article.Rank = scoreForOrg(article, orgSettings)
+	article.Severity = Severity(article.Category, article.Rank)
+	if err := updateEnrichedArticle(article); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type rawItemRow struct {
+	article models.NewsArticle
+	data    []byte
+}
+
+func loadRawItemBatch(afterID int64) ([]rawItemRow, error) {
+	rows, err := db.Query("SELECT a.id, a.org_id, a.sourceUrl, COALESCE(a.imageUrl, ''), r.data FROM articles a "+rawItemJoin+
+		" WHERE a.id > ? ORDER BY a.id LIMIT ?", afterID, reprocessBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch []rawItemRow
+	for rows.Next() {
+		var row rawItemRow
+		if err := rows.Scan(&row.article.ID, &row.article.OrgID, &row.article.SourceURL, &row.article.ImageURL, &row.data); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+func updateEnrichedArticle(article models.NewsArticle) error {
+	_, err := db.Exec("UPDATE articles SET title = ?, description = ?, description_html = ?, content = ?, imageUrl = ?, rank = ?, severity = ?, category = ?, author = ? WHERE id = ?",
+		article.Title, article.Description, article.DescriptionHTML, article.Content, article.ImageURL, article.Rank, article.Severity, article.Category, article.Author, article.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update article %d: %v", article.ID, err)
+	}
+	if _, err := db.Exec("DELETE FROM article_tags WHERE article_id = ? AND manual = 0", article.ID); err != nil {
+		return fmt.Errorf("failed to clear tags of article %d: %v", article.ID, err)
+	}
+	if _, err := db.Exec("DELETE FROM article_categories WHERE article_id = ?", article.ID); err != nil {
+		return fmt.Errorf("failed to clear categories of article %d: %v", article.ID, err)
+	}
+	if err := recordArticleCategories(article.ID, article); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM article_cves WHERE article_id = ?", article.ID); err != nil {
+		return fmt.Errorf("failed to clear CVE mentions of article %d: %v", article.ID, err)
+	}
+	if err := recordArticleCVEs(article.ID, article); err != nil {
+		return err
+	}
+	if _, err := db.Exec("DELETE FROM breaches WHERE article_id = ?", article.ID); err != nil {
+		return fmt.Errorf("failed to clear breach of article %d: %v", article.ID, err)
+	}
+	if err := recordArticleBreach(article.ID, article); err != nil {
+		return err
+	}
+	if err := autoTagArticle(article.ID, article); err != nil {
+		return err
+	}
+	return recordEnrichment(article.ID, true)
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveAndReprocessRawItems(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM raw_items")
+	require.NoError(t, err)
+
+	const source = "https://www.bleepingcomputer.com/feed/"
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	item := &gofeed.Item{
+		Title:           "Zero-day exploited by APT41",
+		Description:     "<p>Attackers exploit a <b>zero-day</b> vulnerability.</p>",
+		Link:            "https://news.example/zero-day",
+		GUID:            "zd-1",
+		PublishedParsed: &published,
+		Authors:         []*gofeed.Person{{Name: "Jane Doe"}},
+	}
+	// The stored copy predates the current rules: stale rank, no tags.
+	_, err = db.Exec("INSERT INTO articles(title, description, imageUrl, url, sourceUrl, publishedAt, rank, category, guid) VALUES(?, ?, '', ?, ?, ?, ?, ?, ?)",
+		"old title", "old", item.Link, source, published, 0, "Tech", item.GUID)
+	require.NoError(t, err)
+	require.NoError(t, archiveRawItem(source, item))
+	require.NoError(t, archiveRawItem(source, &gofeed.Item{GUID: "zd-1", Title: "changed"}))
+	_, err = insertArticle(models.NewsArticle{Title: "Not archived", URL: "https://news.example/other", SourceURL: source, PublishedAt: published})
+	require.NoError(t, err)
+
+	articles, err := QueryArticles(ArticleFilter{Search: "old title"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	id := articles[0].ID
+
+	archived, err := GetRawItem(id)
+	require.NoError(t, err)
+	assert.Equal(t, item.Title, archived.Title)
+	assert.Equal(t, "Jane Doe", archived.Authors[0].Name)
+
+	updated, err := ReprocessArticles()
+	require.NoError(t, err)
+	assert.Equal(t, 1, updated)
+
+	article, err := GetArticleByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, item.Title, article.Title)
+	assert.Equal(t, "Attackers exploit a zero-day vulnerability.", article.Description)
+	assert.Equal(t, "Jane Doe", article.Author)
+	assert.Equal(t, "Cybersecurity", article.Category)
+	assert.Equal(t, calculateRank(article), article.Rank)
+	assert.Greater(t, article.Rank, 0)
+	assert.Equal(t, []string{"APT41"}, article.Tags)
+	assert.True(t, article.PublishedAt.Equal(published))
+
+	_, err = GetRawItem(id + 1)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,241 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ErrReportJobExists is returned when a report job's name is already taken.
+var ErrReportJobExists = errors.New("report job already exists")
+
+// Report run statuses.
+const (
+	ReportRunSucceeded = "succeeded"
+	ReportRunFailed    = "failed"
+)
+
+// maxReportRuns is the number of runs kept in the history of each report job.
+const maxReportRuns = 100
+
+func createReportTables() error {
+	createReportsSQL := `
+	CREATE TABLE IF NOT EXISTS report_jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id INTEGER NOT NULL DEFAULT 0,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		source TEXT NOT NULL DEFAULT '',
+		category TEXT NOT NULL DEFAULT '',
+		tag TEXT NOT NULL DEFAULT '',
+		search TEXT NOT NULL DEFAULT '',
+		min_severity INTEGER NOT NULL DEFAULT 0,
+		max_articles INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		schedule TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		target TEXT NOT NULL,
+		template_id INTEGER NOT NULL DEFAULT 0,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		next_run_at DATETIME NOT NULL,
+		last_success_at DATETIME,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS report_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job_id INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL,
+		status TEXT NOT NULL,
+		articles INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT ''
+	);
+	CREATE INDEX IF NOT EXISTS idx_report_runs_job ON report_runs (job_id, id);
+	`
+	if _, err := db.Exec(createReportsSQL); err != nil {
+		return fmt.Errorf("failed to create report tables: %v", err)
+	}
+	return addColumnIfMissing("report_jobs", "template_id", "INTEGER NOT NULL DEFAULT 0")
+}
+
+const reportJobColumns = "id, org_id, name, source, category, tag, search, min_severity, max_articles, format, schedule, channel, target, template_id, enabled, next_run_at, last_success_at, consecutive_failures, created_at"
+
+func scanReportJob(row rowScanner) (models.ReportJob, error) {
+	var j models.ReportJob
+	var lastSuccess sql.NullTime
+	err := row.Scan(&j.ID, &j.OrgID, &j.Name, &j.Source, &j.Category, &j.Tag, &j.Search, &j.MinSeverity, &j.MaxArticles,
+		&j.Format, &j.Schedule, &j.Channel, &j.Target, &j.TemplateID, &j.Enabled, &j.NextRunAt, &lastSuccess, &j.ConsecutiveFailures, &j.CreatedAt)
+	if lastSuccess.Valid {
+		j.LastSuccessAt = &lastSuccess.Time
+	}
+	return j, err
+}
+
+// ReportFilter converts a report job into the filter selecting the articles
+// ingested between since and until, highest severity first.
+func ReportFilter(j models.ReportJob, since, until time.Time) ArticleFilter {
+	return ArticleFilter{
+		OrgID: j.OrgID, Source: j.Source, Category: j.Category, Tag: j.Tag, Search: j.Search, MinSeverity: j.MinSeverity,
+		StartDate: since, EndDate: until, ByIngestion: true, SortBy: "severity", Limit: j.MaxArticles,
+	}
+}
+
+// CreateReportJob stores a report job.
+func CreateReportJob(j models.ReportJob) (models.ReportJob, error) {
+	if db == nil {
+		return j, fmt.Errorf("database connection is nil")
+	}
+	j.CreatedAt = time.Now().UTC()
+	j.NextRunAt = j.NextRunAt.UTC()
+	res, err := db.Exec(`INSERT INTO report_jobs(org_id, name, source, category, tag, search, min_severity, max_articles, format, schedule, channel, target, template_id, enabled, next_run_at, created_at)
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		j.OrgID, j.Name, j.Source, j.Category, j.Tag, j.Search, j.MinSeverity, j.MaxArticles, j.Format, j.Schedule, j.Channel, j.Target, j.TemplateID, j.Enabled, j.NextRunAt, j.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return j, ErrReportJobExists
+		}
+		return j, err
+	}
+	j.ID, err = res.LastInsertId()
+	return j, err
+}
+
+// UpdateReportJob replaces the definition of a report job, keeping its run
+// state. It returns sql.ErrNoRows if the job does not exist.
+func UpdateReportJob(j models.ReportJob) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec(`UPDATE report_jobs SET org_id = ?, name = ?, source = ?, category = ?, tag = ?, search = ?, min_severity = ?, max_articles = ?,
+		format = ?, schedule = ?, channel = ?, target = ?, template_id = ?, enabled = ?, next_run_at = ? WHERE id = ?`,
+		j.OrgID, j.Name, j.Source, j.Category, j.Tag, j.Search, j.MinSeverity, j.MaxArticles, j.Format, j.Schedule, j.Channel, j.Target, j.TemplateID, j.Enabled, j.NextRunAt.UTC(), j.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrReportJobExists
+		}
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetReportJob returns a report job, or sql.ErrNoRows.
+func GetReportJob(id int64) (models.ReportJob, error) {
+	if db == nil {
+		return models.ReportJob{}, fmt.Errorf("database connection is nil")
+	}
+	return scanReportJob(db.QueryRow("SELECT "+reportJobColumns+" FROM report_jobs WHERE id = ?", id))
+}
+
+// GetReportJobs lists the report jobs by name.
+func GetReportJobs() ([]models.ReportJob, error) {
+	return queryReportJobs("SELECT " + reportJobColumns + " FROM report_jobs ORDER BY name")
+}
+
+// GetDueReportJobs lists the enabled report jobs whose next run is not after now.
+func GetDueReportJobs(now time.Time) ([]models.ReportJob, error) {
+	return queryReportJobs("SELECT "+reportJobColumns+" FROM report_jobs WHERE enabled = 1 AND next_run_at <= ? ORDER BY next_run_at", now.UTC())
+}
+
+func queryReportJobs(query string, args ...interface{}) ([]models.ReportJob, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []models.ReportJob{}
+	for rows.Next() {
+		j, err := scanReportJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// DeleteReportJob deletes a report job and its run history. It returns
+// sql.ErrNoRows if the job does not exist.
+func DeleteReportJob(id int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM report_jobs WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = db.Exec("DELETE FROM report_runs WHERE job_id = ?", id)
+	return err
+}
+
+// RecordReportRun adds a run to the history of its job, schedules the job's
+// next run and returns the updated job. A successful run resets the job's
+// consecutive failures; a failed one increments them.
+func RecordReportRun(run models.ReportRun, nextRunAt time.Time) (models.ReportJob, error) {
+	if db == nil {
+		return models.ReportJob{}, fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return models.ReportJob{}, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("INSERT INTO report_runs(job_id, started_at, finished_at, status, articles, error) VALUES(?, ?, ?, ?, ?, ?)",
+		run.JobID, run.StartedAt.UTC(), run.FinishedAt.UTC(), run.Status, run.Articles, run.Error); err != nil {
+		return models.ReportJob{}, fmt.Errorf("failed to record report run: %v", err)
+	}
+	if run.Status == ReportRunSucceeded {
+		_, err = tx.Exec("UPDATE report_jobs SET next_run_at = ?, last_success_at = ?, consecutive_failures = 0 WHERE id = ?",
+			nextRunAt.UTC(), run.StartedAt.UTC(), run.JobID)
+	} else {
+		_, err = tx.Exec("UPDATE report_jobs SET next_run_at = ?, consecutive_failures = consecutive_failures + 1 WHERE id = ?",
+			nextRunAt.UTC(), run.JobID)
+	}
+	if err != nil {
+		return models.ReportJob{}, fmt.Errorf("failed to update report job %d: %v", run.JobID, err)
+	}
+	if _, err := tx.Exec("DELETE FROM report_runs WHERE job_id = ? AND id NOT IN (SELECT id FROM report_runs WHERE job_id = ? ORDER BY id DESC LIMIT ?)",
+		run.JobID, run.JobID, maxReportRuns); err != nil {
+		return models.ReportJob{}, fmt.Errorf("failed to prune report runs: %v", err)
+	}
+	job, err := scanReportJob(tx.QueryRow("SELECT "+reportJobColumns+" FROM report_jobs WHERE id = ?", run.JobID))
+	if err != nil {
+		return models.ReportJob{}, err
+	}
+	return job, tx.Commit()
+}
+
+// GetReportRuns returns the most recent runs of a report job, newest first.
+func GetReportRuns(jobID int64, limit int) ([]models.ReportRun, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT id, job_id, started_at, finished_at, status, articles, error FROM report_runs WHERE job_id = ? ORDER BY id DESC LIMIT ?", jobID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []models.ReportRun{}
+	for rows.Next() {
+		var run models.ReportRun
+		if err := rows.Scan(&run.ID, &run.JobID, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Articles, &run.Error); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
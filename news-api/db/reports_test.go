@@ -0,0 +1,65 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportJobs(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	job, err := CreateReportJob(models.ReportJob{
+		Name: "Daily digest", Category: "Cybersecurity", MaxArticles: 20, Format: "markdown",
+		Schedule: "0 8 * * *", Channel: "webhook", Target: "https://example.com/hook", Enabled: true, NextRunAt: now.Add(-time.Minute),
+	})
+	require.NoError(t, err)
+	_, err = CreateReportJob(models.ReportJob{Name: "daily DIGEST", MaxArticles: 1, Format: "json", Schedule: "@daily", Channel: "webhook", Target: "x", NextRunAt: now})
+	assert.ErrorIs(t, err, ErrReportJobExists)
+
+	due, err := GetDueReportJobs(now)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "Cybersecurity", due[0].Category)
+	assert.Nil(t, due[0].LastSuccessAt)
+
+	job, err = RecordReportRun(models.ReportRun{JobID: job.ID, StartedAt: now, FinishedAt: now, Status: ReportRunFailed, Error: "timeout"}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, job.ConsecutiveFailures)
+	assert.Nil(t, job.LastSuccessAt)
+	due, err = GetDueReportJobs(now)
+	require.NoError(t, err)
+	assert.Empty(t, due, "the next run is in an hour")
+
+	later := now.Add(time.Hour)
+	job, err = RecordReportRun(models.ReportRun{JobID: job.ID, StartedAt: later, FinishedAt: later, Status: ReportRunSucceeded, Articles: 3}, later.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, job.ConsecutiveFailures)
+	require.NotNil(t, job.LastSuccessAt)
+	assert.True(t, job.LastSuccessAt.Equal(later))
+
+	runs, err := GetReportRuns(job.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, ReportRunSucceeded, runs[0].Status)
+	assert.Equal(t, "timeout", runs[1].Error)
+
+	job.Enabled = false
+	require.NoError(t, UpdateReportJob(job))
+	due, err = GetDueReportJobs(later.Add(48 * time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, due, "disabled jobs never come due")
+
+	require.NoError(t, DeleteReportJob(job.ID))
+	_, err = GetReportJob(job.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	runs, err = GetReportRuns(job.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+	assert.ErrorIs(t, UpdateReportJob(job), sql.ErrNoRows)
+}
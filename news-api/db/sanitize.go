@@ -0,0 +1,32 @@
+package db
+
+import (
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// Sanitization policies for the markup of feed descriptions.
+const (
+	// SanitizeStrict keeps descriptions as plain text only.
+	SanitizeStrict = "strict"
+	// SanitizeUGC also keeps the description's basic markup (paragraphs, lists,
+	// emphasis, links, images), sanitized for display, in DescriptionHTML.
+	SanitizeUGC = "ugc"
+)
+
+// SanitizePolicy is the deployment's sanitization policy.
+var SanitizePolicy = SanitizeStrict
+
+var (
+	stripPolicy = bluemonday.StripTagsPolicy()
+	ugcPolicy   = bluemonday.UGCPolicy().RequireNoFollowOnLinks(true).AddTargetBlankToFullyQualifiedLinks(true)
+)
+
+// sanitizeDescription returns the plain text of a feed description and, under
+// SanitizeUGC, its sanitized markup.
+func sanitizeDescription(description string) (string, string) {
+	text := stripPolicy.Sanitize(description)
+	if SanitizePolicy != SanitizeUGC {
+		return text, ""
+	}
+	return text, ugcPolicy.Sanitize(description)
+}
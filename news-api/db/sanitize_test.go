@@ -0,0 +1,30 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeDescription(t *testing.T) {
+	defer func(policy string) { SanitizePolicy = policy }(SanitizePolicy)
+	description := `<p>Patch <strong>now</strong>:</p><ul><li>CVE-2024-1234</li></ul><script>alert(1)</script><a href="https://vendor.example/advisory" onclick="x()">advisory</a>`
+
+	SanitizePolicy = SanitizeStrict
+	text, markup := sanitizeDescription(description)
+	assert.Equal(t, "Patch now:CVE-2024-1234advisory", text)
+	assert.Empty(t, markup)
+
+	SanitizePolicy = SanitizeUGC
+	text, markup = sanitizeDescription(description)
+	assert.Equal(t, "Patch now:CVE-2024-1234advisory", text, "the plain text does not depend on the policy")
+	assert.Contains(t, markup, "<p>Patch <strong>now</strong>:</p><ul><li>CVE-2024-1234</li></ul>")
+	assert.Contains(t, markup, `<a href="https://vendor.example/advisory" rel="nofollow noopener" target="_blank">advisory</a>`)
+	assert.NotContains(t, markup, "script")
+	assert.NotContains(t, markup, "onclick")
+
+	article := articleFromItem("src", &gofeed.Item{Title: "T", Description: description})
+	assert.Equal(t, text, article.Description)
+	assert.Equal(t, markup, article.DescriptionHTML)
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ErrSavedSearchExists is returned when a user already has a saved search with the same name.
+var ErrSavedSearchExists = errors.New("saved search name already exists")
+
+func createSavedSearchTables() error {
+	createSavedSearchesSQL := `
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		category TEXT NOT NULL DEFAULT '',
+		search TEXT NOT NULL DEFAULT '',
+		min_rank INTEGER NOT NULL DEFAULT 0,
+		min_severity INTEGER NOT NULL DEFAULT 0,
+		notify_url TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		UNIQUE (user_id, name)
+	);
+	CREATE INDEX IF NOT EXISTS idx_saved_searches_notify ON saved_searches (notify_url);
+	`
+	if _, err := db.Exec(createSavedSearchesSQL); err != nil {
+		return fmt.Errorf("failed to create saved_searches table: %v", err)
+	}
+	if err := addColumnIfMissing("saved_searches", "min_severity", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return addColumnIfMissing("saved_searches", "template_id", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// savedSearchColumns resolves the organization through the owning user so a
+// search always follows its user between organizations.
+const savedSearchColumns = "id, user_id, COALESCE((SELECT org_id FROM users WHERE users.id = saved_searches.user_id), 0), name, source, category, search, min_rank, min_severity, notify_url, template_id, created_at"
+
+func scanSavedSearch(row rowScanner) (models.SavedSearch, error) {
+	var s models.SavedSearch
+	err := row.Scan(&s.ID, &s.UserID, &s.OrgID, &s.Name, &s.Source, &s.Category, &s.Search, &s.MinRank, &s.MinSeverity, &s.NotifyURL, &s.TemplateID, &s.CreatedAt)
+	return s, err
+}
+
+// SavedSearchFilter converts a saved search into the equivalent article filter.
+func SavedSearchFilter(s models.SavedSearch) ArticleFilter {
+	return ArticleFilter{OrgID: s.OrgID, Source: s.Source, Category: s.Category, Search: s.Search, MinRank: s.MinRank, MinSeverity: s.MinSeverity}
+}
+
+// CreateSavedSearch stores a named filter combination for a user.
+func CreateSavedSearch(s models.SavedSearch) (models.SavedSearch, error) {
+	if db == nil {
+		return s, fmt.Errorf("database connection is nil")
+	}
+	s.CreatedAt = time.Now().UTC()
+	res, err := db.Exec("INSERT INTO saved_searches(user_id, name, source, category, search, min_rank, min_severity, notify_url, template_id, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		s.UserID, s.Name, s.Source, s.Category, s.Search, s.MinRank, s.MinSeverity, s.NotifyURL, s.TemplateID, s.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return s, ErrSavedSearchExists
+		}
+		return s, err
+	}
+	s.ID, err = res.LastInsertId()
+	return s, err
+}
+
+// GetSavedSearches lists a user's saved searches by name.
+func GetSavedSearches(userID int64) ([]models.SavedSearch, error) {
+	return querySavedSearches("SELECT "+savedSearchColumns+" FROM saved_searches WHERE user_id = ? ORDER BY name", userID)
+}
+
+// GetNotifyingSavedSearches lists every saved search with a notification target.
+func GetNotifyingSavedSearches() ([]models.SavedSearch, error) {
+	return querySavedSearches("SELECT " + savedSearchColumns + " FROM saved_searches WHERE notify_url != ''")
+}
+
+func querySavedSearches(query string, args ...interface{}) ([]models.SavedSearch, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []models.SavedSearch{}
+	for rows.Next() {
+		s, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, s)
+	}
+	return searches, rows.Err()
+}
+
+// GetSavedSearch returns one of a user's saved searches, or sql.ErrNoRows.
+func GetSavedSearch(userID, id int64) (models.SavedSearch, error) {
+	if db == nil {
+		return models.SavedSearch{}, fmt.Errorf("database connection is nil")
+	}
+	return scanSavedSearch(db.QueryRow("SELECT "+savedSearchColumns+" FROM saved_searches WHERE id = ? AND user_id = ?", id, userID))
+}
+
+// DeleteSavedSearch removes one of a user's saved searches, returning sql.ErrNoRows if it does not exist.
+func DeleteSavedSearch(userID, id int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM saved_searches WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearches(t *testing.T) {
+	setupTestDB(t)
+	passwordIterations = 1000
+	user, err := CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+
+	search, err := CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ransomware", Search: "ransomware", MinRank: 5, NotifyURL: "https://hooks.example.com/x"})
+	require.NoError(t, err)
+	assert.NotZero(t, search.ID)
+
+	_, err = CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ransomware"})
+	assert.ErrorIs(t, err, ErrSavedSearchExists)
+
+	_, err = CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Quiet"})
+	require.NoError(t, err)
+
+	searches, err := GetSavedSearches(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, searches, 2)
+
+	notifying, err := GetNotifyingSavedSearches()
+	require.NoError(t, err)
+	require.Len(t, notifying, 1)
+	assert.Equal(t, "Ransomware", notifying[0].Name)
+
+	_, err = GetSavedSearch(user.ID+1, search.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, DeleteSavedSearch(user.ID, search.ID))
+	assert.ErrorIs(t, DeleteSavedSearch(user.ID, search.ID), sql.ErrNoRows)
+}
+
+func TestArticleFilterMatchesQuery(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	now := time.Now()
+	articles := []models.NewsArticle{
+		{Title: "LockBit ransomware hits hospital", URL: "u1", SourceURL: "s1", Category: "Cybersecurity", Rank: 8, PublishedAt: now},
+		{Title: "Ransomware roundup", URL: "u2", SourceURL: "s2", Category: "Cybersecurity", Rank: 2, PublishedAt: now},
+		{Title: "New phone released", URL: "u3", SourceURL: "s2", Category: "Tech", Rank: 9, PublishedAt: now},
+	}
+	for _, a := range articles {
+		require.NoError(t, InsertArticle(a))
+	}
+
+	filter := ArticleFilter{Category: "Cybersecurity", Search: "RANSOMWARE", MinRank: 5}
+	got, err := QueryArticles(filter)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "u1", got[0].URL)
+
+	for _, a := range articles {
+		assert.Equal(t, a.URL == "u1", filter.Matches(a), a.URL)
+	}
+}
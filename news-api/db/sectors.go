@@ -0,0 +1,87 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// Sectors is the dictionary of sector keywords articles are tagged with.
+var Sectors = extract.DefaultSectors
+
+// LoadSectors reads a sector dictionary from a JSON file mapping sector names
+// to arrays of keywords. Sector names are lower-cased.
+func LoadSectors(path string) (extract.SectorDictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sectors file: %v", err)
+	}
+	var raw extract.SectorDictionary
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse sectors file: %v", err)
+	}
+	sectors := make(extract.SectorDictionary, len(raw))
+	for sector, keywords := range raw {
+		name, ok := NormalizeTagName(strings.ToLower(sector))
+		if !ok {
+			return nil, fmt.Errorf("invalid sector name %q", sector)
+		}
+		if len(keywords) == 0 {
+			return nil, fmt.Errorf("sector %q has no keywords", sector)
+		}
+		for _, keyword := range keywords {
+			if strings.TrimSpace(keyword) == "" {
+				return nil, fmt.Errorf("sector %q has an empty keyword", sector)
+			}
+		}
+		sectors[name] = append(sectors[name], keywords...)
+	}
+	return sectors, nil
+}
+
+// inSector reports whether an article's title or description places it in
+// the sector, as its sector tags do.
+func inSector(article models.NewsArticle, sector string) bool {
+	for _, s := range Sectors.Match(article.Title + " " + article.Description) {
+		if s == sector {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSectorCounts counts the articles matching the filter in each sector,
+// and how many of them are of high severity, most articles first. Articles
+// in several sectors count in each.
+func GetSectorCounts(f ArticleFilter) ([]models.SectorCount, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	whereClauses, args := f.where()
+	query := `SELECT t.name, COUNT(*), COALESCE(SUM(CASE WHEN a.severity >= ? THEN 1 ELSE 0 END), 0)
+		FROM articles a
+		JOIN article_tags at ON at.article_id = a.id
+		JOIN tags t ON t.id = at.tag_id AND t.kind = ?
+		WHERE a.id IN (SELECT id FROM articles WHERE ` + strings.Join(whereClauses, " AND ") + `)
+		GROUP BY t.name
+		ORDER BY COUNT(*) DESC, t.name`
+	rows, err := db.Query(query, append([]interface{}{SeverityHigh, TagKindSector}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.SectorCount{}
+	for rows.Next() {
+		var c models.SectorCount
+		if err := rows.Scan(&c.Sector, &c.Articles, &c.HighSeverity); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectors(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().Add(-time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "Ransomware shuts hospital systems", URL: "u1", Rank: 30, Category: "Cybersecurity", PublishedAt: now},
+		{Title: "Bank phishing wave", Description: "Patients of a clinic were targeted too.", URL: "u2", PublishedAt: now},
+		{Title: "New phone review", URL: "u3", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	articles, err := QueryArticles(ArticleFilter{Sector: "healthcare"})
+	require.NoError(t, err)
+	assert.Len(t, articles, 2)
+	articles, err = QueryArticles(ArticleFilter{Sector: "finance"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "u2", articles[0].URL)
+	assert.True(t, ArticleFilter{Sector: "finance"}.Matches(articles[0]))
+	assert.False(t, ArticleFilter{Sector: "energy"}.Matches(articles[0]))
+
+	counts, err := GetSectorCounts(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.Equal(t, "healthcare", counts[0].Sector)
+	assert.Equal(t, 2, counts[0].Articles)
+	assert.Equal(t, 1, counts[0].HighSeverity)
+	assert.Equal(t, models.SectorCount{Sector: "finance", Articles: 1}, counts[1])
+}
+
+func TestLoadSectors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sectors.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Maritime": ["vessel", "port"]}`), 0o600))
+	sectors, err := LoadSectors(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vessel", "port"}, sectors["maritime"])
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"maritime": []}`), 0o600))
+	_, err = LoadSectors(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity thresholds of the threat score buckets: below SeverityMedium is low,
+// SeverityHigh and above is high.
+const (
+	SeverityMedium = 10
+	SeverityHigh   = 25
+)
+
+// defaultSeverityScale is the rank that maps to severity 100 in categories
+// without a calibration of their own.
+const defaultSeverityScale = 20
+
+// severityScales calibrates the built-in categories. Tech keywords mark
+// relevance rather than threats, so a Tech article needs twice the rank of a
+// Cybersecurity article for the same severity.
+var severityScales = map[string]int{
+	"Cybersecurity": 20,
+	"Defense":       20,
+	"Tech":          40,
+	// A single leaked secret in a paste is already serious.
+	ExposureCategory: 15,
+}
+
+// Severity maps an article's raw keyword rank onto a 0-100 scale calibrated
+// per category, so articles of different categories can be compared. Every
+// positive rank has a positive severity.
+func Severity(category string, rank int) int {
+	if rank <= 0 {
+		return 0
+	}
+	scale := severityScale(category)
+	severity := (rank*100 + scale - 1) / scale
+	if severity > 100 {
+		return 100
+	}
+	return severity
+}
+
+// severityScale returns the rank that maps to severity 100 in a category. For
+// custom categories it is the sum of their four strongest keywords, but never
+// less than the default.
+func severityScale(category string) int {
+	if keywords := customKeywords(category); keywords != nil {
+		weights := make([]int, 0, len(keywords))
+		for _, w := range keywords {
+			weights = append(weights, w)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(weights)))
+		scale := 0
+		for i := 0; i < len(weights) && i < 4; i++ {
+			scale += weights[i]
+		}
+		if scale > defaultSeverityScale {
+			return scale
+		}
+		return defaultSeverityScale
+	}
+	if scale, ok := severityScales[category]; ok {
+		return scale
+	}
+	return defaultSeverityScale
+}
+
+// backfillSeverity computes the severity of articles stored before it existed.
+func backfillSeverity() error {
+	rows, err := db.Query("SELECT DISTINCT COALESCE(category, '') FROM articles WHERE severity = 0 AND rank > 0")
+	if err != nil {
+		return fmt.Errorf("failed to find articles without severity: %v", err)
+	}
+	var categories []string
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			rows.Close()
+			return err
+		}
+		categories = append(categories, category)
+	}
+	rows.Close()
+
+	for _, category := range categories {
+		scale := severityScale(category)
+		_, err := db.Exec("UPDATE articles SET severity = MIN(100, (rank * 100 + ? - 1) / ?) WHERE COALESCE(category, '') = ? AND severity = 0 AND rank > 0",
+			scale, scale, category)
+		if err != nil {
+			return fmt.Errorf("failed to backfill severity: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverity(t *testing.T) {
+	assert.Equal(t, 0, Severity("Cybersecurity", 0))
+	assert.Equal(t, 0, Severity("Cybersecurity", -3))
+	assert.Equal(t, 25, Severity("Cybersecurity", 5))
+	assert.Equal(t, 100, Severity("Cybersecurity", 24))
+	assert.Equal(t, 13, Severity("Tech", 5), "Tech ranks are worth half as much")
+	assert.Equal(t, 3, Severity("Tech", 1), "positive ranks never round to zero")
+	assert.Equal(t, 25, Severity("General", 5))
+
+	CustomCategories = []models.Category{{Name: "Cloud", Keywords: map[string]int{"a": 10, "b": 10, "c": 10, "d": 10, "e": 10}}}
+	defer func() { CustomCategories = nil }()
+	assert.Equal(t, 25, Severity("Cloud", 10), "custom categories scale by their four strongest keywords")
+}
+
+func TestSeverityFilterSortAndBackfill(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	now := time.Now()
+	for _, article := range []models.NewsArticle{
+		{Title: "gadget", URL: "u1", Category: "Tech", Rank: 8, PublishedAt: now},
+		{Title: "zero-day", URL: "u2", Category: "Cybersecurity", Rank: 6, PublishedAt: now.Add(-time.Hour)},
+		{Title: "quiet", URL: "u3", Category: "Cybersecurity", Rank: 0, PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	articles, err := QueryArticles(ArticleFilter{SortBy: "severity"})
+	require.NoError(t, err)
+	require.Len(t, articles, 3)
+	assert.Equal(t, "zero-day", articles[0].Title)
+	assert.Equal(t, 30, articles[0].Severity)
+	assert.Equal(t, 20, articles[1].Severity)
+
+	articles, err = QueryArticles(ArticleFilter{MinSeverity: 25})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.True(t, ArticleFilter{MinSeverity: 25}.Matches(articles[0]))
+
+	// Articles stored before severity existed get it on startup.
+	_, err = db.Exec("UPDATE articles SET severity = 0")
+	require.NoError(t, err)
+	require.NoError(t, backfillSeverity())
+	articles, err = QueryArticles(ArticleFilter{SortBy: "severity"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{30, 20, 0}, []int{articles[0].Severity, articles[1].Severity, articles[2].Severity})
+}
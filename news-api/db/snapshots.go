@@ -0,0 +1,233 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"news-api/models"
+)
+
+// threatSnapshotInterval is how often the threat score is recorded. A snapshot
+// stands for at most this long in summaries.
+const threatSnapshotInterval = time.Hour
+
+func createThreatSnapshotTables() error {
+	createSnapshotsSQL := `
+	CREATE TABLE IF NOT EXISTS threat_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		org_id INTEGER NOT NULL,
+		taken_at DATETIME NOT NULL,
+		threat_level TEXT NOT NULL,
+		score TEXT NOT NULL,
+		article_ids TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_threat_snapshots_org_time ON threat_snapshots (org_id, taken_at);
+	`
+	if _, err := db.Exec(createSnapshotsSQL); err != nil {
+		return fmt.Errorf("failed to create threat_snapshots table: %v", err)
+	}
+	return nil
+}
+
+// StartThreatSnapshots schedules the "threat-snapshots" job, which records the
+// threat score of the shared feed and of every organization now and then
+// every threatSnapshotInterval.
+func StartThreatSnapshots() {
+	err := RegisterJob(Job{Name: "threat-snapshots", Schedule: Every(threatSnapshotInterval), RunAtStart: true, Run: func() error {
+		takeAllThreatSnapshots()
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling threat snapshots: %v", err)
+	}
+}
+
+func takeAllThreatSnapshots() {
+	orgIDs := []int64{0}
+	orgs, err := GetOrganizations()
+	if err != nil {
+		log.Printf("Error listing organizations for threat snapshots: %v", err)
+	}
+	for _, org := range orgs {
+		orgIDs = append(orgIDs, org.ID)
+	}
+	for _, orgID := range orgIDs {
+		if err := TakeThreatSnapshot(orgID, time.Now()); err != nil {
+			log.Printf("Error taking threat snapshot for organization %d: %v", orgID, err)
+		}
+	}
+}
+
+// TakeThreatSnapshot stores an organization's current threat score with the IDs
+// of the articles it was computed from.
+func TakeThreatSnapshot(orgID int64, takenAt time.Time) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	explanation, err := GetOrgThreatExplanation(orgID)
+	if err != nil {
+		return err
+	}
+	ids := []int64{}
+	for _, bucket := range [][]ExplainedArticle{explanation.High, explanation.Medium, explanation.Low} {
+		for _, article := range bucket {
+			ids = append(ids, article.ID)
+		}
+	}
+	score, err := json.Marshal(explanation.ThreatScore)
+	if err != nil {
+		return err
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO threat_snapshots(org_id, taken_at, threat_level, score, article_ids) VALUES(?, ?, ?, ?, ?)",
+		orgID, takenAt.UTC(), explanation.ThreatLevel, string(score), string(idsJSON))
+	return err
+}
+
+// ThreatSnapshot is a threat score recorded by the "threat-snapshots" job.
+type ThreatSnapshot struct {
+	TakenAt time.Time `json:"takenAt"`
+	ThreatScore
+}
+
+// GetThreatSnapshots returns an organization's threat scores recorded between
+// start and end, oldest first. A zero start or end leaves the range open.
+func GetThreatSnapshots(orgID int64, start, end time.Time) ([]ThreatSnapshot, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	query := "SELECT taken_at, threat_level, score FROM threat_snapshots WHERE org_id = ?"
+	args := []interface{}{orgID}
+	if !start.IsZero() {
+		query += " AND taken_at >= ?"
+		args = append(args, start.UTC())
+	}
+	if !end.IsZero() {
+		query += " AND taken_at <= ?"
+		args = append(args, end.UTC())
+	}
+	rows, err := db.Query(query+" ORDER BY taken_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []ThreatSnapshot{}
+	for rows.Next() {
+		var snapshot ThreatSnapshot
+		var level, score string
+		if err := rows.Scan(&snapshot.TakenAt, &level, &score); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(score), &snapshot.ThreatScore); err != nil {
+			return nil, fmt.Errorf("invalid threat snapshot score: %v", err)
+		}
+		snapshot.ThreatLevel = level
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetThreatSummary adds up how long an organization's threat level spent at
+// each level between start and end, which is capped at the current time. Each snapshot counts until the next one,
+// but for no longer than threatSnapshotInterval.
+func GetThreatSummary(orgID int64, start, end time.Time) (models.ThreatSummary, error) {
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	summary := models.ThreatSummary{Start: start.UTC(), End: end.UTC(), Hours: map[string]float64{}}
+	if db == nil {
+		return summary, fmt.Errorf("database connection is nil")
+	}
+	for _, level := range append(ThreatLevels, "No Threats Reported") {
+		summary.Hours[level] = 0
+	}
+
+	// The snapshot in effect at start is the last one before it.
+	rows, err := db.Query(`SELECT taken_at, threat_level FROM (
+			SELECT taken_at, threat_level FROM threat_snapshots WHERE org_id = ? AND taken_at < ? ORDER BY taken_at DESC LIMIT 1)
+		UNION ALL
+		SELECT taken_at, threat_level FROM threat_snapshots WHERE org_id = ? AND taken_at >= ? AND taken_at < ?
+		ORDER BY taken_at`, orgID, summary.Start, orgID, summary.Start, summary.End)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	type snapshot struct {
+		takenAt time.Time
+		level   string
+	}
+	var snapshots []snapshot
+	for rows.Next() {
+		var s snapshot
+		if err := rows.Scan(&s.takenAt, &s.level); err != nil {
+			return summary, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+
+	covered := time.Duration(0)
+	for i, s := range snapshots {
+		from, until := s.takenAt, s.takenAt.Add(threatSnapshotInterval)
+		if i+1 < len(snapshots) && snapshots[i+1].takenAt.Before(until) {
+			until = snapshots[i+1].takenAt
+		}
+		if from.Before(summary.Start) {
+			from = summary.Start
+		} else {
+			summary.Snapshots++
+		}
+		if until.After(summary.End) {
+			until = summary.End
+		}
+		if until.After(from) {
+			summary.Hours[s.level] += until.Sub(from).Hours()
+			covered += until.Sub(from)
+		}
+	}
+	for level, hours := range summary.Hours {
+		summary.Hours[level] = roundHours(hours)
+	}
+	summary.UncoveredHours = roundHours((summary.End.Sub(summary.Start) - covered).Hours())
+	return summary, nil
+}
+
+func roundHours(hours float64) float64 {
+	return math.Round(hours*100) / 100
+}
+
+// GetThreatLevelDays returns the UTC days between start and end on which a
+// snapshot of an organization's threat level recorded level, in order.
+func GetThreatLevelDays(orgID int64, level string, start, end time.Time) ([]time.Time, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT taken_at FROM threat_snapshots WHERE org_id = ? AND threat_level = ? AND taken_at >= ? AND taken_at <= ? ORDER BY taken_at",
+		orgID, level, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []time.Time
+	for rows.Next() {
+		var takenAt time.Time
+		if err := rows.Scan(&takenAt); err != nil {
+			return nil, err
+		}
+		day := takenAt.UTC().Truncate(24 * time.Hour)
+		if len(days) == 0 || !days[len(days)-1].Equal(day) {
+			days = append(days, day)
+		}
+	}
+	return days, rows.Err()
+}
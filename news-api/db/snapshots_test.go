@@ -0,0 +1,90 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreatSnapshotsAndSummary(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM threat_snapshots")
+	require.NoError(t, err)
+
+	base := time.Now().UTC().Truncate(time.Hour).Add(-24 * time.Hour)
+	insertSnapshot := func(at time.Time, level string) {
+		_, err := db.Exec("INSERT INTO threat_snapshots(org_id, taken_at, threat_level, score, article_ids) VALUES(0, ?, ?, '{}', '[]')", at, level)
+		require.NoError(t, err)
+	}
+	insertSnapshot(base.Add(-30*time.Minute), "Attention") // in effect at the start
+	insertSnapshot(base.Add(30*time.Minute), "Code Red")
+	insertSnapshot(base.Add(90*time.Minute), "Code Red")
+	// The service was down for two hours.
+	insertSnapshot(base.Add(270*time.Minute), "Business as Usual")
+	insertSnapshot(base.Add(6*time.Hour), "Attention") // after the end
+
+	summary, err := GetThreatSummary(0, base, base.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Snapshots)
+	assert.Equal(t, 0.5, summary.Hours["Attention"])
+	assert.Equal(t, 2.0, summary.Hours["Code Red"])
+	assert.Equal(t, 0.5, summary.Hours["Business as Usual"])
+	assert.Equal(t, 0.0, summary.Hours["No Threats Reported"])
+	assert.Equal(t, 2.0, summary.UncoveredHours)
+
+	other, err := GetThreatSummary(1, base, base.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 5.0, other.UncoveredHours)
+}
+
+func TestTakeThreatSnapshot(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM threat_snapshots")
+	require.NoError(t, err)
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "zero-day", URL: "u1", Rank: 10, PublishedAt: time.Now()}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "old", URL: "u2", Rank: 10, PublishedAt: time.Now().Add(-72 * time.Hour)}))
+
+	require.NoError(t, TakeThreatSnapshot(0, time.Now()))
+	var level, score, ids string
+	require.NoError(t, db.QueryRow("SELECT threat_level, score, article_ids FROM threat_snapshots WHERE org_id = 0").Scan(&level, &score, &ids))
+	assert.Equal(t, "Code Red", level)
+	var stored ThreatScore
+	require.NoError(t, json.Unmarshal([]byte(score), &stored))
+	assert.Equal(t, 1, stored.HighRankCount)
+	var articleIDs []int64
+	require.NoError(t, json.Unmarshal([]byte(ids), &articleIDs))
+	assert.Len(t, articleIDs, 1)
+}
+
+func TestGetThreatLevelDays(t *testing.T) {
+	setupTestDB(t)
+	_, err := db.Exec("DELETE FROM threat_snapshots")
+	require.NoError(t, err)
+	day := time.Date(2024, 4, 9, 0, 0, 0, 0, time.UTC)
+	for _, s := range []struct {
+		at    time.Time
+		level string
+	}{
+		{day.Add(2 * time.Hour), "Code Red"},
+		{day.Add(3 * time.Hour), "Code Red"},
+		{day.Add(26 * time.Hour), "Attention"},
+		{day.Add(50 * time.Hour), "Code Red"},
+	} {
+		_, err := db.Exec("INSERT INTO threat_snapshots(org_id, taken_at, threat_level, score, article_ids) VALUES(0, ?, ?, '{}', '[]')", s.at, s.level)
+		require.NoError(t, err)
+	}
+
+	days, err := GetThreatLevelDays(0, "Code Red", day, day.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{day, day.AddDate(0, 0, 2)}, days)
+	days, err = GetThreatLevelDays(1, "Code Red", day, day.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	assert.Empty(t, days)
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"news-api/models"
+)
+
+// SourceFilter rejects the low-value items of a source, such as the deals and
+// sponsored posts of high-volume tech feeds, before they are stored.
+type SourceFilter struct {
+	// ExcludeTitles drops items whose title matches one of the patterns.
+	ExcludeTitles []*regexp.Regexp
+	// MinDescriptionLength drops items whose description is shorter, in
+	// characters.
+	MinDescriptionLength int
+	// ExcludeKeywords drops items mentioning one of the keywords in their
+	// title or description. Keywords are lower case and match
+	// case-insensitively, like watchlist terms.
+	ExcludeKeywords []string
+	// IncludeKeywords, if not empty, drops items mentioning none of the
+	// keywords.
+	IncludeKeywords []string
+}
+
+// SourceFilters holds the filters applied at ingest, keyed by feed URL; "*"
+// applies to every source, in addition to the source's own filter.
+var SourceFilters map[string]SourceFilter
+
+// SetIngestKeywords adds deployment-wide include and exclude keywords to the
+// "*" filter of SourceFilters.
+func SetIngestKeywords(include, exclude []string) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return
+	}
+	if SourceFilters == nil {
+		SourceFilters = map[string]SourceFilter{}
+	}
+	filter := SourceFilters["*"]
+	filter.IncludeKeywords = append(filter.IncludeKeywords, lowerKeywords(include)...)
+	filter.ExcludeKeywords = append(filter.ExcludeKeywords, lowerKeywords(exclude)...)
+	SourceFilters["*"] = filter
+}
+
+func lowerKeywords(keywords []string) []string {
+	var lower []string
+	for _, keyword := range keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			lower = append(lower, keyword)
+		}
+	}
+	return lower
+}
+
+// LoadSourceFilters reads per-source filters from a JSON file mapping feed
+// URLs (or "*" for all) to objects with excludeTitles, an array of regular
+// expressions, minDescriptionLength, and excludeKeywords and includeKeywords,
+// arrays of keywords.
+func LoadSourceFilters(path string) (map[string]SourceFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source filters file: %v", err)
+	}
+	var raw map[string]struct {
+		ExcludeTitles        []string `json:"excludeTitles"`
+		MinDescriptionLength int      `json:"minDescriptionLength"`
+		ExcludeKeywords      []string `json:"excludeKeywords"`
+		IncludeKeywords      []string `json:"includeKeywords"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse source filters file: %v", err)
+	}
+	filters := make(map[string]SourceFilter, len(raw))
+	for source, spec := range raw {
+		if spec.MinDescriptionLength < 0 {
+			return nil, fmt.Errorf("invalid minDescriptionLength for %s: must not be negative", source)
+		}
+		filter := SourceFilter{
+			MinDescriptionLength: spec.MinDescriptionLength,
+			ExcludeKeywords:      lowerKeywords(spec.ExcludeKeywords),
+			IncludeKeywords:      lowerKeywords(spec.IncludeKeywords),
+		}
+		for _, expr := range spec.ExcludeTitles {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid title pattern for %s: %v", source, err)
+			}
+			filter.ExcludeTitles = append(filter.ExcludeTitles, re)
+		}
+		filters[source] = filter
+	}
+	return filters, nil
+}
+
+// filteredOut returns why an article is rejected by the filters of its
+// source, or "" if it is kept.
+func filteredOut(article models.NewsArticle) string {
+	text := strings.ToLower(article.Title + " " + article.Description + " " + article.Content)
+	for _, key := range []string{"*", article.SourceURL} {
+		filter, ok := SourceFilters[key]
+		if !ok {
+			continue
+		}
+		for _, re := range filter.ExcludeTitles {
+			if re.MatchString(article.Title) {
+				return fmt.Sprintf("title matches %q", re.String())
+			}
+		}
+		// Full text moved to Content by truncation still counts.
+		length := utf8.RuneCountInString(article.Description)
+		if article.Content != "" {
+			length = utf8.RuneCountInString(article.Content)
+		}
+		if length < filter.MinDescriptionLength {
+			return fmt.Sprintf("description shorter than %d characters", filter.MinDescriptionLength)
+		}
+		for _, keyword := range filter.ExcludeKeywords {
+			if strings.Contains(text, keyword) {
+				return fmt.Sprintf("mentions %q", keyword)
+			}
+		}
+		if len(filter.IncludeKeywords) > 0 && !slices.ContainsFunc(filter.IncludeKeywords, func(keyword string) bool {
+			return strings.Contains(text, keyword)
+		}) {
+			return "mentions none of the included keywords"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+func TestLoadSourceFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filters.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"https://example.com/feed": {"excludeTitles": ["(?i)^deals?:"], "minDescriptionLength": 40}, "*": {"excludeTitles": ["(?i)\\bsponsored\\b"]}}`), 0o644))
+	filters, err := LoadSourceFilters(path)
+	require.NoError(t, err)
+	assert.Equal(t, 40, filters["https://example.com/feed"].MinDescriptionLength)
+	assert.Len(t, filters["*"].ExcludeTitles, 1)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"*": {"excludeTitles": ["(unclosed"]}}`), 0o644))
+	_, err = LoadSourceFilters(path)
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(`{"*": {"minDescriptionLength": -1}}`), 0o644))
+	_, err = LoadSourceFilters(path)
+	assert.Error(t, err)
+}
+
+func TestSourceFiltersAtIngest(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		date := time.Now().UTC().Format(time.RFC1123Z)
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Gadgets</title>
+<item><title>Deals: 40%% off noise-cancelling headphones</title><link>https://example.com/deal</link><description>The best prices of the week on headphones and more.</description><pubDate>%[1]s</pubDate></item>
+<item><title>Sponsored: Why your SOC needs AI</title><link>https://example.com/ad</link><description>A word from our partners about the future of the SOC.</description><pubDate>%[1]s</pubDate></item>
+<item><title>Chip shortage eases</title><link>https://example.com/brief</link><description>Briefly.</description><pubDate>%[1]s</pubDate></item>
+<item><title>Router maker patches actively exploited flaw</title><link>https://example.com/patch</link><description>The vendor fixed a command injection flaw exploited by a botnet.</description><pubDate>%[1]s</pubDate></item>
+</channel></rss>`, date)
+	}))
+	defer server.Close()
+
+	defer func(filters map[string]SourceFilter) { SourceFilters = filters }(SourceFilters)
+	path := filepath.Join(t.TempDir(), "filters.json")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`{%q: {"excludeTitles": ["(?i)^deals?:"], "minDescriptionLength": 20}, "*": {"excludeTitles": ["(?i)\\bsponsored\\b"]}}`, server.URL)), 0o644))
+	filters, err := LoadSourceFilters(path)
+	require.NoError(t, err)
+	SourceFilters = filters
+
+	fetchAndCacheNews([]string{server.URL})
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Router maker patches actively exploited flaw", articles[0].Title)
+}
+
+func TestIngestKeywords(t *testing.T) {
+	defer func(filters map[string]SourceFilter) { SourceFilters = filters }(SourceFilters)
+	SourceFilters = nil
+	SetIngestKeywords([]string{"Ransomware", " breach "}, []string{"GIVEAWAY"})
+	assert.Equal(t, SourceFilter{IncludeKeywords: []string{"ransomware", "breach"}, ExcludeKeywords: []string{"giveaway"}}, SourceFilters["*"])
+
+	kept := models.NewsArticle{Title: "Hospital ransomware attack disrupts care", SourceURL: "https://example.com/feed"}
+	assert.Empty(t, filteredOut(kept))
+	assert.Equal(t, `mentions "giveaway"`, filteredOut(models.NewsArticle{Title: "Ransomware book giveaway", SourceURL: "https://example.com/feed"}))
+	assert.Equal(t, "mentions none of the included keywords", filteredOut(models.NewsArticle{Title: "New phone announced", SourceURL: "https://example.com/feed"}))
+	assert.Empty(t, filteredOut(models.NewsArticle{Title: "Retailer confirms data theft", Description: "The breach exposed card numbers.", SourceURL: "https://example.com/feed"}))
+}
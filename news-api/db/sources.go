@@ -0,0 +1,230 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/net/html"
+
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// sourceInfoTTL is how long a resolved source title and icon are kept before
+// they are looked up again.
+const sourceInfoTTL = 7 * 24 * time.Hour
+
+func createSourceTables() error {
+	createSourcesSQL := `
+	CREATE TABLE IF NOT EXISTS sources (
+		url TEXT PRIMARY KEY,
+		title TEXT NOT NULL DEFAULT '',
+		site_url TEXT NOT NULL DEFAULT '',
+		icon_url TEXT NOT NULL DEFAULT '',
+		checked_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createSourcesSQL); err != nil {
+		return fmt.Errorf("failed to create sources table: %v", err)
+	}
+	return nil
+}
+
+// GetSources lists the sources an organization has articles from, with their
+// resolved titles and icons, ordered by URL.
+func GetSources(orgID int64) ([]models.Source, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT a.sourceUrl, MAX(a.category), COUNT(*), COALESCE(s.title, ''), COALESCE(s.site_url, ''), COALESCE(s.icon_url, '')
+		FROM articles a LEFT JOIN sources s ON s.url = a.sourceUrl
+		WHERE a.org_id = ? AND a.deleted_at IS NULL
+		GROUP BY a.sourceUrl
+		ORDER BY a.sourceUrl`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []models.Source{}
+	for rows.Next() {
+		var s models.Source
+		if err := rows.Scan(&s.URL, &s.Category, &s.ArticleCount, &s.Title, &s.SiteURL, &s.IconURL); err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// GetSourceStats summarizes the articles an organization has from each
+// source, with the source's ingestion health, ordered by URL.
+func GetSourceStats(orgID int64) ([]models.SourceStats, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT a.sourceUrl, COALESCE(MAX(s.title), ''), MAX(a.category), COUNT(*), SUM(a.severity >= ?), AVG(a.rank),
+			MIN(a.publishedAt), MAX(a.publishedAt), MAX(h.last_success_at), COALESCE(MAX(h.last_error), '')
+		FROM articles a LEFT JOIN sources s ON s.url = a.sourceUrl LEFT JOIN source_health h ON h.url = a.sourceUrl
+		WHERE a.org_id = ? AND a.deleted_at IS NULL
+		GROUP BY a.sourceUrl
+		ORDER BY a.sourceUrl`, SeverityHigh, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := []models.SourceStats{}
+	for rows.Next() {
+		var s models.SourceStats
+		var first, last string
+		var lastSuccess sql.NullString
+		if err := rows.Scan(&s.URL, &s.Title, &s.Category, &s.Articles, &s.HighSeverityArticles, &s.AverageRank,
+			&first, &last, &lastSuccess, &s.LastError); err != nil {
+			return nil, err
+		}
+		if s.FirstPublishedAt, err = parseStoredTime(first); err != nil {
+			return nil, err
+		}
+		if s.LastPublishedAt, err = parseStoredTime(last); err != nil {
+			return nil, err
+		}
+		if lastSuccess.Valid {
+			t, err := parseStoredTime(lastSuccess.String)
+			if err != nil {
+				return nil, err
+			}
+			s.LastSuccessAt = &t
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// refreshSourceInfo records a feed's title and resolves its icon unless they
+// were looked up within sourceInfoTTL.
+func refreshSourceInfo(source string, feed *gofeed.Feed) {
+	var checkedAt time.Time
+	err := db.QueryRow("SELECT checked_at FROM sources WHERE url = ?", source).Scan(&checkedAt)
+	if err == nil && time.Since(checkedAt) < sourceInfoTTL {
+		return
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error reading source info for %s: %v", source, err)
+		return
+	}
+
+	siteURL := feed.Link
+	if u, err := url.Parse(siteURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		siteURL = ""
+		if u, err := url.Parse(source); err == nil && u.Host != "" {
+			siteURL = u.Scheme + "://" + u.Host + "/"
+		}
+	}
+
+	icon := ""
+	if feed.Image != nil {
+		if base, err := url.Parse(source); err == nil {
+			icon = absoluteHTTPURL(base, feed.Image.URL)
+		}
+	}
+	if icon == "" && siteURL != "" {
+		icon = resolveSiteIcon(siteURL)
+	}
+
+	_, err = db.Exec(`INSERT INTO sources(url, title, site_url, icon_url, checked_at) VALUES(?, ?, ?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET title = excluded.title, site_url = excluded.site_url, icon_url = excluded.icon_url, checked_at = excluded.checked_at`,
+		source, strings.TrimSpace(feed.Title), siteURL, icon, time.Now().UTC())
+	if err != nil {
+		log.Printf("Error saving source info for %s: %v", source, err)
+	}
+}
+
+// resolveSiteIcon finds a site's icon from the <link rel="icon"> tags of its
+// home page, falling back to /favicon.ico if the site serves one.
+func resolveSiteIcon(siteURL string) string {
+	pageClient := fetcher.PoliteClient(previewImageTimeout)
+	resp, err := pageClient.Get(siteURL)
+	if err == nil {
+		defer resp.Body.Close()
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if resp.StatusCode == http.StatusOK && mediaType == "text/html" {
+			if icon := parseSiteIcon(io.LimitReader(resp.Body, previewImageMaxBytes), resp.Request.URL); icon != "" {
+				return icon
+			}
+		}
+	}
+
+	base, err := url.Parse(siteURL)
+	if err != nil {
+		return ""
+	}
+	favicon := base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	resp, err = pageClient.Get(favicon)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); resp.StatusCode != http.StatusOK || !strings.HasPrefix(mediaType, "image/") {
+		return ""
+	}
+	return favicon
+}
+
+// parseSiteIcon returns the icon declared in an HTML head, preferring
+// rel="icon" over rel="apple-touch-icon", resolved against base.
+func parseSiteIcon(r io.Reader, base *url.URL) string {
+	var icon, touchIcon string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return absoluteHTTPURL(base, icon, touchIcon)
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return absoluteHTTPURL(base, icon, touchIcon)
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" {
+				return absoluteHTTPURL(base, icon, touchIcon)
+			}
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+			var rel, href string
+			for {
+				attr, value, more := z.TagAttr()
+				switch strings.ToLower(string(attr)) {
+				case "rel":
+					rel = strings.ToLower(string(value))
+				case "href":
+					href = strings.TrimSpace(string(value))
+				}
+				if !more {
+					break
+				}
+			}
+			for _, r := range strings.Fields(rel) {
+				switch r {
+				case "icon":
+					if icon == "" {
+						icon = href
+					}
+				case "apple-touch-icon", "apple-touch-icon-precomposed":
+					if touchIcon == "" {
+						touchIcon = href
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSiteIcon(t *testing.T) {
+	base, _ := url.Parse("https://news.example/")
+	assert.Equal(t, "https://news.example/icon-32.png", parseSiteIcon(strings.NewReader(
+		`<head><link rel="apple-touch-icon" href="/touch.png"><link rel="shortcut icon" href="/icon-32.png"></head>`), base))
+	assert.Equal(t, "https://news.example/touch.png", parseSiteIcon(strings.NewReader(
+		`<head><link rel="apple-touch-icon" href="touch.png"><link rel="stylesheet" href="/site.css"></head>`), base))
+	assert.Equal(t, "", parseSiteIcon(strings.NewReader(`<head><title>No icon</title></head>`), base))
+}
+
+func TestRefreshSourceInfo(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	var homepageHits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			homepageHits++
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><head><link rel="icon" href="/static/icon.png"></head></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := server.URL + "/feed.xml"
+	feed := &gofeed.Feed{Title: " Example News ", Link: server.URL + "/"}
+	refreshSourceInfo(source, feed)
+	refreshSourceInfo(source, feed)
+	assert.Equal(t, 1, homepageHits, "source info is cached")
+
+	// Feeds with a logo do not need the home page.
+	logoSource := "https://logo.example/rss"
+	refreshSourceInfo(logoSource, &gofeed.Feed{Title: "Logo", Image: &gofeed.Image{URL: "/logo.png"}})
+
+	now := time.Now()
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "A", URL: "u1", SourceURL: source, Category: "Cybersecurity", PublishedAt: now}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "B", URL: "u2", SourceURL: logoSource, Category: "Tech", PublishedAt: now}))
+
+	sources, err := GetSources(0)
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, models.Source{URL: logoSource, Title: "Logo", SiteURL: "https://logo.example/", IconURL: "https://logo.example/logo.png", Category: "Tech", ArticleCount: 1}, sources[1])
+	assert.Equal(t, "Example News", sources[0].Title)
+	assert.Equal(t, server.URL+"/static/icon.png", sources[0].IconURL)
+
+	articles, err := QueryArticles(ArticleFilter{Source: source})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, server.URL+"/static/icon.png", articles[0].SourceIcon)
+}
@@ -0,0 +1,240 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// Tag kinds. Tags of every kind but TagKindManual are assigned automatically
+// when an article is stored.
+const (
+	TagKindIOC        = "ioc"
+	TagKindAttack     = "attack"
+	TagKindActor      = "actor"
+	TagKindWatchlist  = "watchlist"
+	TagKindRansomware = "ransomware"
+	TagKindVictim     = "victim"
+	TagKindSector     = "sector"
+	TagKindPackage    = "package"
+	TagKindManual     = "manual"
+)
+
+// TagWatchlist holds the deployment-wide watchlist terms. Articles mentioning
+// one are tagged with it, as are articles mentioning a term of their
+// organization's watchlist.
+var TagWatchlist []string
+
+// tagSeparator joins tag names in the article query; it cannot occur in a name.
+const tagSeparator = "\x1f"
+
+func createTagTables() error {
+	createTagsSQL := `
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		kind TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS article_tags (
+		article_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		manual INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (article_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_article_tags_tag ON article_tags (tag_id, article_id);
+	`
+	if _, err := db.Exec(createTagsSQL); err != nil {
+		return fmt.Errorf("failed to create tag tables: %v", err)
+	}
+	return nil
+}
+
+// NormalizeTagName trims a tag name and reports whether it is usable: 1-64
+// characters without control characters.
+func NormalizeTagName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return "", false
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// autoTags returns the tags the enrichment pipeline assigns to an article:
+// the IOC types, ATT&CK techniques, threat actors, ransomware groups and
+// ransomware victims it mentions, the sectors it concerns, the watchlist terms
+// it matches, and the packages a GitHub security advisory affects.
+func autoTags(article models.NewsArticle) []models.Tag {
+	text := article.Title + " " + article.Description
+	var tags []models.Tag
+	seenIOCTypes := map[string]bool{}
+	for _, ioc := range extract.IOCs(text) {
+		if !seenIOCTypes[ioc.Type] {
+			seenIOCTypes[ioc.Type] = true
+			tags = append(tags, models.Tag{Name: ioc.Type, Kind: TagKindIOC})
+		}
+	}
+	for _, id := range extract.AttackIDs(text) {
+		tags = append(tags, models.Tag{Name: id, Kind: TagKindAttack})
+	}
+	for _, actor := range extract.Actors(text) {
+		tags = append(tags, models.Tag{Name: actor, Kind: TagKindActor})
+	}
+	for _, group := range RansomwareGroups.Match(text) {
+		tags = append(tags, models.Tag{Name: group, Kind: TagKindRansomware})
+	}
+	for _, victim := range RansomwareGroups.Victims(text) {
+		if name, ok := NormalizeTagName(victim); ok {
+			tags = append(tags, models.Tag{Name: name, Kind: TagKindVictim})
+		}
+	}
+	for _, sector := range Sectors.Match(text) {
+		tags = append(tags, models.Tag{Name: sector, Kind: TagKindSector})
+	}
+
+	terms := append([]string{}, TagWatchlist...)
+	if orgTerms, err := getOrgWatchlist(article.OrgID); err == nil {
+		terms = append(terms, orgTerms...)
+	}
+	lower := strings.ToLower(text)
+	for _, term := range terms {
+		if name, ok := NormalizeTagName(term); ok && strings.Contains(lower, strings.ToLower(name)) {
+			tags = append(tags, models.Tag{Name: strings.ToLower(name), Kind: TagKindWatchlist})
+		}
+	}
+	return append(tags, ghsaPackageTags(article)...)
+}
+
+func getOrgWatchlist(orgID int64) ([]string, error) {
+	rows, err := db.Query("SELECT term FROM org_watchlist WHERE org_id = ?", orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var terms []string
+	for rows.Next() {
+		var term string
+		if err := rows.Scan(&term); err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	return terms, rows.Err()
+}
+
+// autoTagArticle assigns the enrichment tags to a newly stored article.
+func autoTagArticle(articleID int64, article models.NewsArticle) error {
+	for _, tag := range autoTags(article) {
+		if err := tagArticle(articleID, tag, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TagArticle adds a manual tag to an article, returning sql.ErrNoRows if the
+// article does not exist. Tags are created on first use.
+func TagArticle(articleID int64, name string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if _, err := GetArticleByID(articleID); err != nil {
+		return err
+	}
+	return tagArticle(articleID, models.Tag{Name: name, Kind: TagKindManual}, true)
+}
+
+func tagArticle(articleID int64, tag models.Tag, manual bool) error {
+	if _, err := db.Exec("INSERT OR IGNORE INTO tags(name, kind) VALUES(?, ?)", tag.Name, tag.Kind); err != nil {
+		return err
+	}
+	_, err := db.Exec(`INSERT OR IGNORE INTO article_tags(article_id, tag_id, manual, created_at)
+		SELECT ?, id, ?, ? FROM tags WHERE name = ?`, articleID, manual, time.Now().UTC(), tag.Name)
+	return err
+}
+
+// UntagArticle removes a tag from an article, returning sql.ErrNoRows if the
+// article did not have it.
+func UntagArticle(articleID int64, name string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("DELETE FROM article_tags WHERE article_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)", articleID, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTags lists the tags used on an organization's articles with their article
+// counts, most used first. An empty kind lists tags of every kind.
+func GetTags(orgID int64, kind string) ([]models.Tag, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	query := `SELECT t.id, t.name, t.kind, COUNT(*)
+		FROM tags t
+		JOIN article_tags at ON at.tag_id = t.id
+		JOIN articles a ON a.id = at.article_id
+		WHERE a.org_id = ? AND a.deleted_at IS NULL`
+	args := []interface{}{orgID}
+	if kind != "" {
+		query += " AND t.kind = ?"
+		args = append(args, kind)
+	}
+	query += " GROUP BY t.id ORDER BY COUNT(*) DESC, t.name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Kind, &tag.ArticleCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// articleTagsColumn selects an article's tag names joined by tagSeparator.
+func articleTagsColumn(alias string) string {
+	return "COALESCE((SELECT GROUP_CONCAT(t.name, char(31)) FROM article_tags at JOIN tags t ON t.id = at.tag_id WHERE at.article_id = " + alias + ".id), '')"
+}
+
+// tagList scans the value selected by articleTagsColumn into a slice of names.
+type tagList []string
+
+func (t *tagList) Scan(src interface{}) error {
+	var joined string
+	switch v := src.(type) {
+	case string:
+		joined = v
+	case []byte:
+		joined = string(v)
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into tags", src)
+	}
+	*t = nil
+	if joined != "" {
+		*t = strings.Split(joined, tagSeparator)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoTagging(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	TagWatchlist = []string{"Ivanti"}
+	defer func() { TagWatchlist = nil }()
+
+	now := time.Now()
+	_, err := insertArticle(models.NewsArticle{
+		Title:       "APT29 exploits Ivanti flaw CVE-2024-21887",
+		Description: "Initial access via T1190 from 203.0.113.5.",
+		URL:         "https://news.example/apt29", SourceURL: "src", PublishedAt: now,
+	})
+	require.NoError(t, err)
+	_, err = insertArticle(models.NewsArticle{Title: "Another CVE-2024-0001 patch", URL: "https://news.example/patch", SourceURL: "src", PublishedAt: now})
+	require.NoError(t, err)
+
+	articles, err := QueryArticles(ArticleFilter{Tag: "apt29"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.ElementsMatch(t, []string{"cve", "ipv4", "T1190", "APT29", "ivanti"}, articles[0].Tags)
+
+	articles, err = QueryArticles(ArticleFilter{Tag: "CVE"})
+	require.NoError(t, err)
+	assert.Len(t, articles, 2)
+
+	tags, err := GetTags(0, TagKindIOC)
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+	assert.Equal(t, "cve", tags[0].Name)
+	assert.Equal(t, 2, tags[0].ArticleCount)
+
+	tags, err = GetTags(1, "")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestManualTagging(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := insertArticle(models.NewsArticle{Title: "Quiet news", URL: "https://news.example/q", SourceURL: "src", PublishedAt: time.Now()})
+	require.NoError(t, err)
+	articles, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	id := articles[0].ID
+	assert.Nil(t, articles[0].Tags)
+
+	require.NoError(t, TagArticle(id, "Supply Chain"))
+	require.NoError(t, TagArticle(id, "supply chain"))
+	article, err := GetArticleByID(id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Supply Chain"}, article.Tags)
+
+	tags, err := GetTags(0, TagKindManual)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, 1, tags[0].ArticleCount)
+
+	assert.ErrorIs(t, TagArticle(id+100, "x"), sql.ErrNoRows)
+	require.NoError(t, UntagArticle(id, "SUPPLY CHAIN"))
+	assert.ErrorIs(t, UntagArticle(id, "supply chain"), sql.ErrNoRows)
+
+	_, ok := NormalizeTagName("  ")
+	assert.False(t, ok)
+	name, ok := NormalizeTagName(" apt ")
+	assert.True(t, ok)
+	assert.Equal(t, "apt", name)
+}
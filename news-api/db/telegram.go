@@ -0,0 +1,620 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+
+	"news-api/models"
+)
+
+// TelegramCategory is the category of the posts of Telegram channels, where
+// leaks, breach claims and hacktivist campaigns are often announced first.
+const TelegramCategory = "Social"
+
+// TelegramBotToken authenticates the Bot API calls reading the channels. The
+// bot only receives the posts of channels it was added to as an
+// administrator.
+var TelegramBotToken string
+
+// TelegramMediaURL is the base URL post images are served from, by the
+// /telegram/media/ handler, which downloads them with the bot token so that
+// the token never appears in article URLs.
+var TelegramMediaURL = "/telegram/media/"
+
+// telegramAPIURL is the Bot API, replaced by tests.
+var telegramAPIURL = "https://api.telegram.org"
+
+const (
+	// telegramLinkBase links to a channel or, followed by a post ID, to a post.
+	telegramLinkBase = "https://t.me/"
+	// telegramPrivateLinkBase links to a post of a channel without a
+	// username, for its members.
+	telegramPrivateLinkBase = "https://t.me/c/"
+	// telegramUpdateBatch is the most updates getUpdates returns at once.
+	telegramUpdateBatch = 100
+	// telegramPollInterval is how long the updates polled for one channel
+	// serve the others fetched in the same cycle.
+	telegramPollInterval = 30 * time.Second
+	// maxTelegramResponseBytes bounds the Bot API responses read.
+	maxTelegramResponseBytes = 8 << 20
+	// MaxTelegramFileBytes bounds the media downloaded; the Bot API serves
+	// files of up to 20 MB.
+	MaxTelegramFileBytes = 10 << 20
+	// telegramTitleLength is the length posts, which have no title, are
+	// given one from their first line.
+	telegramTitleLength = 120
+	// telegramPhotoWidth is the widest photo size used as a post's image.
+	telegramPhotoWidth = 1280
+)
+
+// telegramChannelPattern matches public channel usernames.
+var telegramChannelPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// telegramChannelIDPattern matches the IDs of channels, which identify
+// channels without a username.
+var telegramChannelIDPattern = regexp.MustCompile(`^-100[0-9]{5,}$`)
+
+// telegramSources maps the source URL of each ingested channel to its
+// lowercased username or its ID.
+var telegramSources = map[string]string{}
+
+var (
+	// telegramPollMutex serializes polls, which all channels share.
+	telegramPollMutex sync.Mutex
+	telegramPolledAt  time.Time
+)
+
+// SetTelegramChannels selects the Telegram channels to ingest, given as
+// usernames ("vxunderground" or "@vxunderground"), links
+// ("https://t.me/vxunderground") or, for channels without a username, IDs
+// ("-1001234567890"). It must be called before the first caching cycle.
+func SetTelegramChannels(channels []string) error {
+	sources := map[string]string{}
+	for _, channel := range channels {
+		name := strings.TrimSpace(channel)
+		if telegramChannelIDPattern.MatchString(name) {
+			sources[telegramPrivateLinkBase+strings.TrimPrefix(name, "-100")] = name
+			continue
+		}
+		for _, prefix := range []string{"https://", "http://", "t.me/s/", "t.me/", "@"} {
+			name = strings.TrimPrefix(name, prefix)
+		}
+		name = strings.TrimSuffix(name, "/")
+		if !telegramChannelPattern.MatchString(name) {
+			return fmt.Errorf("invalid Telegram channel %q", channel)
+		}
+		sources[telegramLinkBase+name] = strings.ToLower(name)
+	}
+	telegramSources = sources
+	return nil
+}
+
+// TelegramSources returns the source URLs of the selected channels, to be
+// fetched with the feeds.
+func TelegramSources() []string {
+	sources := make([]string, 0, len(telegramSources))
+	for source := range telegramSources {
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+func isTelegramSource(source string) bool {
+	_, ok := telegramSources[source]
+	return ok
+}
+
+func createTelegramTables() error {
+	createTelegramSQL := `
+	CREATE TABLE IF NOT EXISTS telegram_posts (
+		update_id INTEGER PRIMARY KEY,
+		channel TEXT NOT NULL,
+		message TEXT NOT NULL,
+		processed INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX IF NOT EXISTS idx_telegram_posts_channel ON telegram_posts (channel, processed);
+	CREATE TABLE IF NOT EXISTS telegram_files (
+		file_unique_id TEXT PRIMARY KEY,
+		file_id TEXT NOT NULL
+	);
+	`
+	if _, err := db.Exec(createTelegramSQL); err != nil {
+		return fmt.Errorf("failed to create telegram tables: %v", err)
+	}
+	return nil
+}
+
+// telegramResponse is the envelope of Bot API responses.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+type telegramUpdate struct {
+	UpdateID    int64            `json:"update_id"`
+	ChannelPost *telegramMessage `json:"channel_post"`
+}
+
+type telegramChat struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Username    string `json:"username"`
+	Description string `json:"description"`
+}
+
+type telegramMessage struct {
+	MessageID       int64               `json:"message_id"`
+	Date            int64               `json:"date"`
+	Chat            telegramChat        `json:"chat"`
+	AuthorSignature string              `json:"author_signature"`
+	MediaGroupID    string              `json:"media_group_id"`
+	ForwardOrigin   *telegramOrigin     `json:"forward_origin"`
+	Text            string              `json:"text"`
+	Entities        []telegramEntity    `json:"entities"`
+	Caption         string              `json:"caption"`
+	CaptionEntities []telegramEntity    `json:"caption_entities"`
+	Photo           []telegramPhotoSize `json:"photo"`
+	Video           *telegramMedia      `json:"video"`
+	Animation       *telegramMedia      `json:"animation"`
+	Document        *telegramMedia      `json:"document"`
+	Audio           *telegramMedia      `json:"audio"`
+	Voice           *telegramMedia      `json:"voice"`
+}
+
+// telegramOrigin is where a forwarded post came from.
+type telegramOrigin struct {
+	Type       string        `json:"type"`
+	Chat       *telegramChat `json:"chat"`
+	SenderChat *telegramChat `json:"sender_chat"`
+	SenderUser *struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	} `json:"sender_user"`
+	SenderUserName string `json:"sender_user_name"`
+}
+
+type telegramEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url"`
+}
+
+type telegramPhotoSize struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+// telegramMedia is a video, animation, document, audio file or voice note.
+type telegramMedia struct {
+	Duration  int                `json:"duration"`
+	FileName  string             `json:"file_name"`
+	Title     string             `json:"title"`
+	Performer string             `json:"performer"`
+	Thumbnail *telegramPhotoSize `json:"thumbnail"`
+}
+
+// telegramCall calls a Bot API method and decodes its result into out.
+// Transport errors carry the request URL, so the token is redacted from them.
+func telegramCall(client *http.Client, method string, params url.Values, out interface{}) error {
+	if TelegramBotToken == "" {
+		return fmt.Errorf("TELEGRAM_BOT_TOKEN is not set")
+	}
+	resp, err := client.Get(telegramAPIURL + "/bot" + TelegramBotToken + "/" + method + "?" + params.Encode())
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = telegramAPIURL + "/bot<token>/" + method
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTelegramResponseBytes))
+	if err != nil {
+		return err
+	}
+	var response telegramResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("unexpected %s response (status %s): %v", method, resp.Status, err)
+	}
+	if !response.OK {
+		return fmt.Errorf("%s failed: %s", method, response.Description)
+	}
+	return json.Unmarshal(response.Result, out)
+}
+
+// pollTelegramUpdates stores the channel posts received since the last poll
+// for the next fetch of their channel. Posts of channels that are not
+// ingested are dropped. The offset passed to getUpdates confirms the updates
+// stored before, so polls resume after the newest stored update, also after
+// a restart. Polls within telegramPollInterval of the last are skipped: one
+// poll returns the posts of every channel.
+func pollTelegramUpdates(client *http.Client) error {
+	telegramPollMutex.Lock()
+	defer telegramPollMutex.Unlock()
+	if time.Since(telegramPolledAt) < telegramPollInterval {
+		return nil
+	}
+
+	for {
+		var offset int64
+		if err := db.QueryRow("SELECT COALESCE(MAX(update_id), -1) + 1 FROM telegram_posts").Scan(&offset); err != nil {
+			return err
+		}
+		params := url.Values{
+			"offset":          {strconv.FormatInt(offset, 10)},
+			"limit":           {strconv.Itoa(telegramUpdateBatch)},
+			"allowed_updates": {`["channel_post"]`},
+		}
+		var updates []telegramUpdate
+		if err := telegramCall(client, "getUpdates", params, &updates); err != nil {
+			return err
+		}
+		for _, update := range updates {
+			channel, message := "", []byte("{}")
+			if post := update.ChannelPost; post != nil {
+				if channel = telegramChatKey(post.Chat); channel != "" {
+					message, _ = json.Marshal(post)
+				}
+			}
+			// Dropped updates are stored as processed to advance the offset.
+			_, err := db.Exec("INSERT OR IGNORE INTO telegram_posts(update_id, channel, message, processed) VALUES(?, ?, ?, ?)",
+				update.UpdateID, channel, string(message), channel == "")
+			if err != nil {
+				return err
+			}
+		}
+		if len(updates) < telegramUpdateBatch {
+			break
+		}
+	}
+	telegramPolledAt = time.Now()
+	return nil
+}
+
+// telegramChatKey returns the key of an ingested channel in telegramSources,
+// or "" for other chats.
+func telegramChatKey(chat telegramChat) string {
+	id := strconv.FormatInt(chat.ID, 10)
+	for _, channel := range telegramSources {
+		if channel == id || (chat.Username != "" && channel == strings.ToLower(chat.Username)) {
+			return channel
+		}
+	}
+	return ""
+}
+
+// fetchTelegramFeed polls the Bot API, then turns the posts of a channel
+// stored since its last fetch into a feed. The channel's title and
+// description come from getChat, which also fails for channels the bot was
+// not added to.
+func fetchTelegramFeed(client *http.Client, source string) (*gofeed.Feed, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	channel := telegramSources[source]
+	chatID := channel
+	if !telegramChannelIDPattern.MatchString(channel) {
+		chatID = "@" + channel
+	}
+	var chat telegramChat
+	if err := telegramCall(client, "getChat", url.Values{"chat_id": {chatID}}, &chat); err != nil {
+		return nil, err
+	}
+	if err := pollTelegramUpdates(client); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT update_id, message FROM telegram_posts WHERE channel = ? AND processed = 0 ORDER BY update_id", channel)
+	if err != nil {
+		return nil, err
+	}
+	var messages []telegramMessage
+	var lastID int64
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&lastID, &raw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var message telegramMessage
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			log.Printf("Error decoding Telegram update %d: %v", lastID, err)
+			continue
+		}
+		messages = append(messages, message)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{Title: chat.Title, Description: chat.Description, Link: source}
+	for _, message := range mergeTelegramAlbums(messages) {
+		item, err := telegramItem(message)
+		if err != nil {
+			return nil, err
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	if lastID > 0 {
+		if err := markTelegramPostsProcessed(channel, lastID); err != nil {
+			return nil, err
+		}
+	}
+	return feed, nil
+}
+
+// markTelegramPostsProcessed flags the posts of a channel up to lastID as
+// ingested and deletes the processed posts of all channels but the newest
+// update, which keeps the offset of the next poll.
+func markTelegramPostsProcessed(channel string, lastID int64) error {
+	if _, err := db.Exec("UPDATE telegram_posts SET processed = 1 WHERE channel = ? AND update_id <= ?", channel, lastID); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM telegram_posts WHERE processed = 1 AND update_id < (SELECT MAX(update_id) FROM telegram_posts)")
+	return err
+}
+
+// mergeTelegramAlbums folds the posts of an album, which Telegram delivers
+// as one message per photo or video with the caption on one of them, into
+// its first post.
+func mergeTelegramAlbums(messages []telegramMessage) []telegramMessage {
+	var merged []telegramMessage
+	albums := map[string]int{}
+	for _, message := range messages {
+		if message.MediaGroupID == "" {
+			merged = append(merged, message)
+			continue
+		}
+		i, ok := albums[message.MediaGroupID]
+		if !ok {
+			albums[message.MediaGroupID] = len(merged)
+			merged = append(merged, message)
+			continue
+		}
+		if merged[i].Caption == "" && message.Caption != "" {
+			merged[i].Caption, merged[i].CaptionEntities = message.Caption, message.CaptionEntities
+		}
+	}
+	return merged
+}
+
+// telegramItem converts a channel post. It links to the post's t.me page and
+// is titled by its first line. Its photo, or the thumbnail of its video,
+// animation or document, becomes the item image, served through
+// TelegramMediaURL. Videos carry their duration in a tg:duration extension.
+// Forwarded posts name their origin and attached files are named at the end
+// of the description.
+func telegramItem(message telegramMessage) (*gofeed.Item, error) {
+	channelTitle := message.Chat.Title
+	var link string
+	if message.Chat.Username != "" {
+		link = telegramLinkBase + message.Chat.Username + "/" + strconv.FormatInt(message.MessageID, 10)
+	} else {
+		link = telegramPrivateLinkBase + strings.TrimPrefix(strconv.FormatInt(message.Chat.ID, 10), "-100") + "/" + strconv.FormatInt(message.MessageID, 10)
+	}
+	published := time.Unix(message.Date, 0).UTC()
+	author := message.AuthorSignature
+	if author == "" {
+		author = channelTitle
+	}
+	item := &gofeed.Item{
+		GUID:            link,
+		Link:            link,
+		Published:       published.Format(time.RFC3339),
+		PublishedParsed: &published,
+		Authors:         []*gofeed.Person{{Name: author}},
+	}
+
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+	var description strings.Builder
+	if origin := telegramOriginName(message.ForwardOrigin); origin != "" {
+		description.WriteString("<p>Forwarded from " + html.EscapeString(origin) + "</p>")
+	}
+	if text != "" {
+		description.WriteString("<p>" + telegramHTML(text, entities) + "</p>")
+	}
+
+	kind := "Post"
+	var image *telegramPhotoSize
+	switch {
+	case len(message.Photo) > 0:
+		kind, image = "Photo", telegramPhoto(message.Photo)
+	case message.Video != nil:
+		kind, image = "Video", message.Video.Thumbnail
+		item.Extensions = ext.Extensions{"tg": {"duration": {{Name: "duration", Value: strconv.Itoa(message.Video.Duration)}}}}
+	case message.Animation != nil:
+		kind, image = "Animation", message.Animation.Thumbnail
+	case message.Document != nil:
+		kind, image = "File "+message.Document.FileName, message.Document.Thumbnail
+		description.WriteString("<p>Attached file: " + html.EscapeString(message.Document.FileName) + "</p>")
+	case message.Audio != nil:
+		name := message.Audio.Title
+		if message.Audio.Performer != "" && name != "" {
+			name = message.Audio.Performer + " - " + name
+		}
+		if name == "" {
+			name = message.Audio.FileName
+		}
+		kind = "Audio"
+		description.WriteString("<p>Attached audio: " + html.EscapeString(name) + "</p>")
+	case message.Voice != nil:
+		kind = "Voice message"
+	}
+	if image != nil {
+		if err := recordTelegramFile(*image); err != nil {
+			return nil, err
+		}
+		item.Image = &gofeed.Image{URL: TelegramMediaURL + image.FileUniqueID}
+	}
+
+	item.Description = description.String()
+	item.Title = telegramTitle(text)
+	if item.Title == "" {
+		item.Title = kind + " from " + channelTitle
+	}
+	return item, nil
+}
+
+// telegramPhoto picks the largest size of a photo that is at most
+// telegramPhotoWidth wide. Sizes come smallest first.
+func telegramPhoto(sizes []telegramPhotoSize) *telegramPhotoSize {
+	best := &sizes[0]
+	for i := range sizes {
+		if sizes[i].Width <= telegramPhotoWidth && sizes[i].Width > best.Width {
+			best = &sizes[i]
+		}
+	}
+	return best
+}
+
+// telegramOriginName names where a forwarded post came from.
+func telegramOriginName(origin *telegramOrigin) string {
+	switch {
+	case origin == nil:
+		return ""
+	case origin.Chat != nil:
+		return origin.Chat.Title
+	case origin.SenderChat != nil:
+		return origin.SenderChat.Title
+	case origin.SenderUser != nil:
+		return strings.TrimSpace(origin.SenderUser.FirstName + " " + origin.SenderUser.LastName)
+	}
+	return origin.SenderUserName
+}
+
+// telegramHTML renders the text of a post as HTML, with its links and line
+// breaks. Entity offsets count UTF-16 code units.
+func telegramHTML(text string, entities []telegramEntity) string {
+	units := utf16.Encode([]rune(text))
+	escape := func(from, to int) string {
+		return strings.ReplaceAll(html.EscapeString(string(utf16.Decode(units[from:to]))), "\n", "<br>")
+	}
+	var b strings.Builder
+	pos := 0
+	for _, entity := range entities {
+		end := entity.Offset + entity.Length
+		if entity.Offset < pos || end > len(units) {
+			continue
+		}
+		href := entity.URL
+		switch entity.Type {
+		case "url":
+			href = string(utf16.Decode(units[entity.Offset:end]))
+			if !strings.Contains(href, "://") {
+				href = "https://" + href
+			}
+		case "text_link":
+		default:
+			continue
+		}
+		if u, err := url.Parse(href); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		b.WriteString(escape(pos, entity.Offset))
+		b.WriteString(`<a href="` + html.EscapeString(href) + `">` + escape(entity.Offset, end) + "</a>")
+		pos = end
+	}
+	b.WriteString(escape(pos, len(units)))
+	return b.String()
+}
+
+// telegramTitle titles a post with its first non-empty line.
+func telegramTitle(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			return truncateText(line, telegramTitleLength)
+		}
+	}
+	return ""
+}
+
+// applyTelegramMedia marks the articles of video posts as videos.
+func applyTelegramMedia(article *models.NewsArticle, item *gofeed.Item) {
+	if duration := firstExtension(item.Extensions, "tg", "duration"); duration != nil {
+		article.MediaType = MediaTypeVideo
+		article.Duration, _ = strconv.Atoi(duration.Value)
+	}
+}
+
+// recordTelegramFile remembers the file ID of a post image, which the
+// /telegram/media/ handler downloads it by. Only recorded files are served.
+func recordTelegramFile(photo telegramPhotoSize) error {
+	_, err := db.Exec("INSERT INTO telegram_files(file_unique_id, file_id) VALUES(?, ?) ON CONFLICT(file_unique_id) DO UPDATE SET file_id = excluded.file_id",
+		photo.FileUniqueID, photo.FileID)
+	return err
+}
+
+// ErrTelegramFileNotFound is returned for media that no post refers to.
+var ErrTelegramFileNotFound = errors.New("telegram file not found")
+
+// FetchTelegramFile downloads the image of a post by the unique ID its
+// TelegramMediaURL ends with, returning its bytes. Files larger than
+// MaxTelegramFileBytes are refused.
+func FetchTelegramFile(client *http.Client, uniqueID string) ([]byte, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	var fileID string
+	err := db.QueryRow("SELECT file_id FROM telegram_files WHERE file_unique_id = ?", uniqueID).Scan(&fileID)
+	if err == sql.ErrNoRows {
+		return nil, ErrTelegramFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		FilePath string `json:"file_path"`
+		FileSize int    `json:"file_size"`
+	}
+	if err := telegramCall(client, "getFile", url.Values{"file_id": {fileID}}, &file); err != nil {
+		return nil, err
+	}
+	if file.FileSize > MaxTelegramFileBytes {
+		return nil, fmt.Errorf("file of %d bytes is too large", file.FileSize)
+	}
+	resp, err := client.Get(telegramAPIURL + "/file/bot" + TelegramBotToken + "/" + file.FilePath)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = telegramAPIURL + "/file/bot<token>/" + file.FilePath
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxTelegramFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxTelegramFileBytes {
+		return nil, fmt.Errorf("file is larger than %d bytes", MaxTelegramFileBytes)
+	}
+	return data, nil
+}
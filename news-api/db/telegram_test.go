@@ -0,0 +1,178 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTelegramToken = "123456:secret-token"
+
+// testTelegramUpdates are getUpdates results. %d is the date of the posts.
+const testTelegramUpdates = `[
+ {"update_id": 500, "channel_post": {"message_id": 101, "date": %[1]d, "chat": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "type": "channel"},
+  "text": "🚨 Acme Corp database leaked\n1.2M customer records posted for sale, see proof",
+  "entities": [{"type": "bold", "offset": 3, "length": 25}, {"type": "text_link", "offset": 72, "length": 5, "url": "https://example.com/acme"}]}},
+ {"update_id": 501, "channel_post": {"message_id": 7, "date": %[1]d, "chat": {"id": -1002222222222, "title": "Unrelated", "username": "unrelated", "type": "channel"}, "text": "Not ingested"}},
+ {"update_id": 502, "channel_post": {"message_id": 102, "date": %[1]d, "chat": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "type": "channel"},
+  "media_group_id": "album1", "photo": [{"file_id": "small-1", "file_unique_id": "u-small-1", "width": 90, "height": 60}, {"file_id": "large-1", "file_unique_id": "u-large-1", "width": 1280, "height": 853}, {"file_id": "huge-1", "file_unique_id": "u-huge-1", "width": 2560, "height": 1706}]}},
+ {"update_id": 503, "channel_post": {"message_id": 103, "date": %[1]d, "chat": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "type": "channel"},
+  "media_group_id": "album1", "caption": "Screenshots of the Acme admin panel", "photo": [{"file_id": "small-2", "file_unique_id": "u-small-2", "width": 90, "height": 60}]}},
+ {"update_id": 504, "channel_post": {"message_id": 104, "date": %[1]d, "chat": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "type": "channel"},
+  "author_signature": "admin", "video": {"duration": 65, "thumbnail": {"file_id": "thumb-1", "file_unique_id": "u-thumb-1", "width": 320, "height": 180}}}},
+ {"update_id": 505, "channel_post": {"message_id": 105, "date": %[1]d, "chat": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "type": "channel"},
+  "forward_origin": {"type": "channel", "chat": {"id": -1003333333333, "title": "Other Group"}}, "document": {"file_name": "acme_dump.zip"}}}
+]`
+
+// fakeTelegramAPI serves getChat, getUpdates from offset on, getFile and
+// file downloads, recording the offsets polled.
+func fakeTelegramAPI(t *testing.T, posted time.Time, offsets *[]int64) *httptest.Server {
+	var updates []json.RawMessage
+	require.NoError(t, json.Unmarshal([]byte(fmt.Sprintf(testTelegramUpdates, posted.Unix())), &updates))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := "/bot" + testTelegramToken + "/"
+		switch {
+		case r.URL.Path == prefix+"getChat":
+			if r.URL.Query().Get("chat_id") != "@leakwatch" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"ok": false, "error_code": 400, "description": "Bad Request: chat not found"}`))
+				return
+			}
+			w.Write([]byte(`{"ok": true, "result": {"id": -1001111111111, "title": "Leak Watch", "username": "LeakWatch", "description": "Breach claims, as they happen."}}`))
+		case r.URL.Path == prefix+"getUpdates":
+			assert.Equal(t, `["channel_post"]`, r.URL.Query().Get("allowed_updates"))
+			offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+			*offsets = append(*offsets, offset)
+			var result []json.RawMessage
+			for _, update := range updates {
+				var u telegramUpdate
+				require.NoError(t, json.Unmarshal(update, &u))
+				if u.UpdateID >= offset {
+					result = append(result, update)
+				}
+			}
+			body, _ := json.Marshal(map[string]interface{}{"ok": true, "result": result})
+			w.Write(body)
+		case r.URL.Path == prefix+"getFile":
+			fmt.Fprintf(w, `{"ok": true, "result": {"file_id": %q, "file_size": 8, "file_path": "photos/%s.png"}}`, r.URL.Query().Get("file_id"), r.URL.Query().Get("file_id"))
+		case strings.HasPrefix(r.URL.Path, "/file/bot"+testTelegramToken+"/photos/"):
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"ok": false, "description": "Not Found"}`))
+		}
+	}))
+}
+
+func setupTelegram(t *testing.T, apiURL string) {
+	sources, token, api, media := telegramSources, TelegramBotToken, telegramAPIURL, TelegramMediaURL
+	t.Cleanup(func() {
+		telegramSources, TelegramBotToken, telegramAPIURL, TelegramMediaURL = sources, token, api, media
+		telegramPolledAt = time.Time{}
+	})
+	TelegramBotToken, telegramAPIURL, TelegramMediaURL = testTelegramToken, apiURL, "https://threatfeed.example.org/telegram/media/"
+	telegramPolledAt = time.Time{}
+	require.NoError(t, SetTelegramChannels([]string{"leakwatch", "missingchannel"}))
+}
+
+func TestSetTelegramChannels(t *testing.T) {
+	defer func(sources map[string]string) { telegramSources = sources }(telegramSources)
+	require.NoError(t, SetTelegramChannels([]string{"@leakwatch", "https://t.me/vxunderground/", " t.me/s/Some_Channel ", "-1001234567890"}))
+	assert.ElementsMatch(t, []string{"https://t.me/leakwatch", "https://t.me/vxunderground", "https://t.me/Some_Channel", "https://t.me/c/1234567890"}, TelegramSources())
+	assert.True(t, isTelegramSource("https://t.me/leakwatch"))
+	assert.Equal(t, "some_channel", telegramChatKey(telegramChat{ID: -1009, Username: "Some_Channel"}))
+	assert.Equal(t, "-1001234567890", telegramChatKey(telegramChat{ID: -1001234567890}))
+	assert.Empty(t, telegramChatKey(telegramChat{ID: -1009, Username: "other"}))
+	assert.Contains(t, CategoryNames(), TelegramCategory)
+	assert.Error(t, SetTelegramChannels([]string{"https://t.me/+invitehash"}))
+	assert.Error(t, SetTelegramChannels([]string{"abc"}))
+}
+
+func TestIngestTelegram(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	defer func(fetch bool) { FetchPreviewImages = fetch }(FetchPreviewImages)
+	FetchPreviewImages = false
+
+	posted := time.Now().UTC().Truncate(time.Second)
+	var offsets []int64
+	server := fakeTelegramAPI(t, posted, &offsets)
+	defer server.Close()
+	setupTelegram(t, server.URL)
+
+	_, err := fetchTelegramFeed(server.Client(), "https://t.me/missingchannel")
+	assert.ErrorContains(t, err, "chat not found")
+
+	fetchAndCacheNews([]string{"https://t.me/leakwatch"})
+	articles, err := QueryArticles(ArticleFilter{SortBy: "idAsc"})
+	require.NoError(t, err)
+	require.Len(t, articles, 4, "albums are one article and other channels are dropped")
+
+	text, album, video, file := articles[0], articles[1], articles[2], articles[3]
+	assert.Equal(t, "🚨 Acme Corp database leaked", text.Title)
+	assert.Equal(t, "https://t.me/LeakWatch/101", text.URL)
+	assert.Equal(t, "https://t.me/leakwatch", text.SourceURL)
+	assert.Equal(t, TelegramCategory, text.Category)
+	assert.Equal(t, "Leak Watch", text.Author)
+	assert.Contains(t, text.Description, "1.2M customer records posted for sale")
+	assert.True(t, posted.Equal(text.PublishedAt))
+	assert.Greater(t, text.Severity, SeverityHigh)
+
+	assert.Equal(t, "Screenshots of the Acme admin panel", album.Title)
+	assert.Equal(t, "https://threatfeed.example.org/telegram/media/u-large-1", album.ImageURL)
+
+	assert.Equal(t, "Video from Leak Watch", video.Title)
+	assert.Equal(t, "admin", video.Author)
+	assert.Equal(t, MediaTypeVideo, video.MediaType)
+	assert.Equal(t, 65, video.Duration)
+	assert.Equal(t, "https://threatfeed.example.org/telegram/media/u-thumb-1", video.ImageURL)
+
+	assert.Equal(t, "File acme_dump.zip from Leak Watch", file.Title)
+	assert.Contains(t, file.Description, "Forwarded from Other Group")
+	assert.Contains(t, file.Description, "Attached file: acme_dump.zip")
+	assert.Empty(t, file.MediaType)
+
+	// The next poll confirms the stored updates and nothing is ingested twice.
+	telegramPolledAt = time.Time{}
+	feed, err := fetchTelegramFeed(server.Client(), "https://t.me/leakwatch")
+	require.NoError(t, err)
+	assert.Empty(t, feed.Items)
+	assert.Equal(t, "Leak Watch", feed.Title)
+	assert.Equal(t, []int64{0, 506}, offsets)
+
+	data, err := FetchTelegramFile(server.Client(), "u-large-1")
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNG\r\n\x1a\n", string(data))
+	_, err = FetchTelegramFile(server.Client(), "u-huge-1")
+	assert.ErrorIs(t, err, ErrTelegramFileNotFound, "only the images of posts are served")
+}
+
+func TestTelegramCallRedactsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+	setupTelegram(t, server.URL)
+
+	err := telegramCall(http.DefaultClient, "getUpdates", nil, nil)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), testTelegramToken)
+	assert.Contains(t, err.Error(), "/bot<token>/getUpdates")
+}
+
+func TestTelegramHTML(t *testing.T) {
+	// "🔥" is two UTF-16 code units, which entity offsets count.
+	html := telegramHTML("🔥 <b> leak\nsee example.com/x or here", []telegramEntity{
+		{Type: "text_link", Offset: 3, Length: 3, URL: "javascript:alert(1)"},
+		{Type: "url", Offset: 16, Length: 13},
+		{Type: "text_link", Offset: 33, Length: 4, URL: "https://example.org/"},
+	})
+	assert.Equal(t, `🔥 &lt;b&gt; leak<br>see <a href="https://example.com/x">example.com/x</a> or <a href="https://example.org/">here</a>`, html)
+}
@@ -0,0 +1,144 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ErrMessageTemplateExists is returned when a message template's name is already taken.
+var ErrMessageTemplateExists = errors.New("message template already exists")
+
+// ErrMessageTemplateInUse is returned when deleting a message template that a
+// report job or saved search still uses.
+var ErrMessageTemplateInUse = errors.New("message template is in use")
+
+func createMessageTemplateTables() error {
+	createTemplatesSQL := `
+	CREATE TABLE IF NOT EXISTS message_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		kind TEXT NOT NULL,
+		subject TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createTemplatesSQL); err != nil {
+		return fmt.Errorf("failed to create message_templates table: %v", err)
+	}
+	return nil
+}
+
+const messageTemplateColumns = "id, name, kind, subject, body, created_at, updated_at"
+
+func scanMessageTemplate(row rowScanner) (models.MessageTemplate, error) {
+	var t models.MessageTemplate
+	err := row.Scan(&t.ID, &t.Name, &t.Kind, &t.Subject, &t.Body, &t.CreatedAt, &t.UpdatedAt)
+	return t, err
+}
+
+// CreateMessageTemplate stores a message template.
+func CreateMessageTemplate(t models.MessageTemplate) (models.MessageTemplate, error) {
+	if db == nil {
+		return t, fmt.Errorf("database connection is nil")
+	}
+	t.CreatedAt = time.Now().UTC()
+	t.UpdatedAt = t.CreatedAt
+	res, err := db.Exec("INSERT INTO message_templates(name, kind, subject, body, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+		t.Name, t.Kind, t.Subject, t.Body, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return t, ErrMessageTemplateExists
+		}
+		return t, err
+	}
+	t.ID, err = res.LastInsertId()
+	return t, err
+}
+
+// UpdateMessageTemplate replaces a message template. It returns
+// sql.ErrNoRows if the template does not exist.
+func UpdateMessageTemplate(t models.MessageTemplate) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	res, err := db.Exec("UPDATE message_templates SET name = ?, kind = ?, subject = ?, body = ?, updated_at = ? WHERE id = ?",
+		t.Name, t.Kind, t.Subject, t.Body, time.Now().UTC(), t.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrMessageTemplateExists
+		}
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetMessageTemplate returns a message template, or sql.ErrNoRows.
+func GetMessageTemplate(id int64) (models.MessageTemplate, error) {
+	if db == nil {
+		return models.MessageTemplate{}, fmt.Errorf("database connection is nil")
+	}
+	return scanMessageTemplate(db.QueryRow("SELECT "+messageTemplateColumns+" FROM message_templates WHERE id = ?", id))
+}
+
+// GetMessageTemplates lists the message templates by name.
+func GetMessageTemplates() ([]models.MessageTemplate, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query("SELECT " + messageTemplateColumns + " FROM message_templates ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	templates := []models.MessageTemplate{}
+	for rows.Next() {
+		t, err := scanMessageTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// DeleteMessageTemplate deletes a message template. It returns
+// ErrMessageTemplateInUse if a report job or saved search uses it, and
+// sql.ErrNoRows if it does not exist.
+func DeleteMessageTemplate(id int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var inUse bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM report_jobs WHERE template_id = ?) OR EXISTS(SELECT 1 FROM saved_searches WHERE template_id = ?)",
+		id, id).Scan(&inUse); err != nil {
+		return fmt.Errorf("failed to check message template use: %v", err)
+	}
+	if inUse {
+		return ErrMessageTemplateInUse
+	}
+	res, err := tx.Exec("DELETE FROM message_templates WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	setupTestDB(t)
+	tmpl, err := CreateMessageTemplate(models.MessageTemplate{Name: "Slack digest", Kind: "slack", Body: `{"text": {{json .Name}}}`})
+	require.NoError(t, err)
+	_, err = CreateMessageTemplate(models.MessageTemplate{Name: "slack DIGEST", Kind: "webhook", Body: "{}"})
+	assert.ErrorIs(t, err, ErrMessageTemplateExists)
+
+	tmpl.Body = `{"text": {{json .Name}}, "mrkdwn": true}`
+	require.NoError(t, UpdateMessageTemplate(tmpl))
+	stored, err := GetMessageTemplate(tmpl.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.Body, stored.Body)
+	assert.False(t, stored.UpdatedAt.Before(stored.CreatedAt))
+
+	user, err := CreateUser("templated", "correct horse battery")
+	require.NoError(t, err)
+	search, err := CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ivanti", Search: "ivanti", NotifyURL: "https://hooks.example.com", TemplateID: tmpl.ID})
+	require.NoError(t, err)
+	search, err = GetSavedSearch(user.ID, search.ID)
+	require.NoError(t, err)
+	assert.Equal(t, tmpl.ID, search.TemplateID)
+	assert.ErrorIs(t, DeleteMessageTemplate(tmpl.ID), ErrMessageTemplateInUse)
+
+	require.NoError(t, DeleteSavedSearch(user.ID, search.ID))
+	require.NoError(t, DeleteMessageTemplate(tmpl.ID))
+	assert.ErrorIs(t, DeleteMessageTemplate(tmpl.ID), sql.ErrNoRows)
+	templates, err := GetMessageTemplates()
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"sort"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// termTitleWeight is how much more a mention in a title counts than one in a
+// description.
+const termTitleWeight = 2
+
+// GetTermFrequencies counts the terms used in the articles matching the filter
+// and returns the limit heaviest ones. Inflections are grouped by stem and
+// reported by their most frequent form. Title mentions weigh termTitleWeight.
+func GetTermFrequencies(f ArticleFilter, limit int) ([]models.TermFrequency, error) {
+	articles, err := QueryArticles(f)
+	if err != nil {
+		return nil, err
+	}
+
+	type stemStats struct {
+		weight   int
+		articles int
+		forms    map[string]int
+	}
+	stats := map[string]*stemStats{}
+	count := func(text string, weight int, seen map[string]bool) {
+		for _, term := range extract.Terms(text) {
+			stem := extract.Stem(term)
+			s, ok := stats[stem]
+			if !ok {
+				s = &stemStats{forms: map[string]int{}}
+				stats[stem] = s
+			}
+			s.weight += weight
+			s.forms[term]++
+			if !seen[stem] {
+				seen[stem] = true
+				s.articles++
+			}
+		}
+	}
+	for _, article := range articles {
+		seen := map[string]bool{}
+		count(article.Title, termTitleWeight, seen)
+		count(article.Description, 1, seen)
+	}
+
+	terms := make([]models.TermFrequency, 0, len(stats))
+	for _, s := range stats {
+		form := ""
+		for candidate, n := range s.forms {
+			if n > s.forms[form] || (n == s.forms[form] && candidate < form) {
+				form = candidate
+			}
+		}
+		terms = append(terms, models.TermFrequency{Term: form, Weight: s.weight, Articles: s.articles})
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Weight != terms[j].Weight {
+			return terms[i].Weight > terms[j].Weight
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if limit > 0 && len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms, nil
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTermFrequencies(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	for _, article := range []models.NewsArticle{
+		{Title: "Ransomware attacks hospitals", Description: "The attack hit two hospitals.", URL: "u1", Category: "Cybersecurity", PublishedAt: now},
+		{Title: "Hospital attacked", Description: "Ransomware again.", URL: "u2", Category: "Cybersecurity", PublishedAt: now},
+		{Title: "New phone review", URL: "u3", Category: "Tech", PublishedAt: now},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	terms, err := GetTermFrequencies(ArticleFilter{Category: "Cybersecurity"}, 3)
+	require.NoError(t, err)
+	require.Len(t, terms, 3)
+
+	// attacks(2) + attack(1) + attacked(2); hospitals(2) + hospitals(1) + hospital(2)
+	// Ties are ordered by term.
+	assert.Equal(t, models.TermFrequency{Term: "attack", Weight: 5, Articles: 2}, terms[0])
+	assert.Equal(t, models.TermFrequency{Term: "hospitals", Weight: 5, Articles: 2}, terms[1])
+	assert.Equal(t, models.TermFrequency{Term: "ransomware", Weight: 3, Articles: 2}, terms[2])
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// createIntegrationTables creates the bookkeeping tables used by outbound integrations.
+func createIntegrationTables() error {
+	createTicketsSQL := `
+	CREATE TABLE IF NOT EXISTS integration_tickets (
+		integration TEXT NOT NULL,
+		story_key TEXT NOT NULL,
+		article_url TEXT NOT NULL,
+		ticket_key TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (integration, story_key)
+	);
+	CREATE TABLE IF NOT EXISTS integration_cursors (
+		integration TEXT PRIMARY KEY,
+		last_article_id INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS integration_failures (
+		integration TEXT NOT NULL,
+		article_id INTEGER NOT NULL,
+		attempts INTEGER NOT NULL,
+		last_error TEXT NOT NULL,
+		PRIMARY KEY (integration, article_id)
+	);
+	`
+	if _, err := db.Exec(createTicketsSQL); err != nil {
+		return fmt.Errorf("failed to create integration tables: %v", err)
+	}
+	return nil
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// StoryKey normalizes an article title so that the same story syndicated by
+// several sources (or re-published with a new URL) maps to one key.
+func StoryKey(title string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(title), " "), " ")
+}
+
+// GetTicketKey returns the ticket already opened by an integration for a story,
+// or an empty string if there is none.
+func GetTicketKey(integration, storyKey string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("database connection is nil")
+	}
+	var key string
+	err := db.QueryRow("SELECT ticket_key FROM integration_tickets WHERE integration = ? AND story_key = ?", integration, storyKey).Scan(&key)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return key, err
+}
+
+// SaveTicketKey records the ticket an integration opened for a story.
+func SaveTicketKey(integration, storyKey, articleURL, ticketKey string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec("INSERT OR IGNORE INTO integration_tickets(integration, story_key, article_url, ticket_key) VALUES(?, ?, ?, ?)", integration, storyKey, articleURL, ticketKey)
+	return err
+}
+
+// GetIntegrationCursor returns the ID of the last article an integration
+// synced, and false if it never synced any.
+func GetIntegrationCursor(integration string) (int64, bool, error) {
+	if db == nil {
+		return 0, false, fmt.Errorf("database connection is nil")
+	}
+	var lastID int64
+	err := db.QueryRow("SELECT last_article_id FROM integration_cursors WHERE integration = ?", integration).Scan(&lastID)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return lastID, err == nil, err
+}
+
+// SaveIntegrationCursor records the ID of the last article an integration synced.
+func SaveIntegrationCursor(integration string, lastID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec(`INSERT INTO integration_cursors(integration, last_article_id) VALUES(?, ?)
+		ON CONFLICT(integration) DO UPDATE SET last_article_id = excluded.last_article_id`, integration, lastID)
+	return err
+}
+
+// RecordIntegrationFailure counts a failed attempt of an integration to sync
+// an article and returns the number of attempts so far.
+func RecordIntegrationFailure(integration string, articleID int64, cause error) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	var attempts int
+	err := db.QueryRow(`INSERT INTO integration_failures(integration, article_id, attempts, last_error) VALUES(?, ?, 1, ?)
+		ON CONFLICT(integration, article_id) DO UPDATE SET attempts = attempts + 1, last_error = excluded.last_error
+		RETURNING attempts`, integration, articleID, cause.Error()).Scan(&attempts)
+	return attempts, err
+}
+
+// ClearIntegrationFailures forgets the failed attempts to sync an article.
+func ClearIntegrationFailures(integration string, articleID int64) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec("DELETE FROM integration_failures WHERE integration = ? AND article_id = ?", integration, articleID)
+	return err
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"sort"
+
+	"news-api/models"
+)
+
+// timelineDateFormat is the layout of TimelineDay.Date.
+const timelineDateFormat = "2006-01-02"
+
+// GetTimeline returns the articles matching the filter grouped by the UTC day
+// they were published, oldest day first. Days without coverage are omitted.
+func GetTimeline(f ArticleFilter) ([]models.TimelineDay, error) {
+	articles, err := QueryArticles(f)
+	if err != nil {
+		return nil, err
+	}
+
+	byDate := map[string]*models.TimelineDay{}
+	for _, article := range articles {
+		date := article.PublishedAt.UTC().Format(timelineDateFormat)
+		day, ok := byDate[date]
+		if !ok {
+			day = &models.TimelineDay{Date: date, Articles: []models.NewsArticle{}}
+			byDate[date] = day
+		}
+		day.Count++
+		day.RankSum += article.Rank
+		day.Articles = append(day.Articles, article)
+	}
+
+	days := make([]models.TimelineDay, 0, len(byDate))
+	for _, day := range byDate {
+		sort.Slice(day.Articles, func(i, j int) bool {
+			return day.Articles[i].PublishedAt.Before(day.Articles[j].PublishedAt)
+		})
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
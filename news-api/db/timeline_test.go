@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTimeline(t *testing.T) {
+	setupTestDB(t)
+	day1 := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(48 * time.Hour)
+	for _, article := range []models.NewsArticle{
+		{Title: "CVE-2024-1234 disclosed", URL: "u1", Rank: 3, PublishedAt: day1},
+		{Title: "Exploit for CVE-2024-1234", URL: "u2", Rank: 5, PublishedAt: day2.Add(time.Hour)},
+		{Title: "Patch for cve-2024-1234 released", URL: "u3", Rank: 1, PublishedAt: day2},
+		{Title: "Unrelated story", URL: "u4", Rank: 9, PublishedAt: day2},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	days, err := GetTimeline(ArticleFilter{Search: "CVE-2024-1234"})
+	require.NoError(t, err)
+	require.Len(t, days, 2)
+
+	assert.Equal(t, "2024-03-01", days[0].Date)
+	assert.Equal(t, 1, days[0].Count)
+	assert.Equal(t, 3, days[0].RankSum)
+
+	assert.Equal(t, "2024-03-03", days[1].Date)
+	assert.Equal(t, 2, days[1].Count)
+	assert.Equal(t, 6, days[1].RankSum)
+	require.Len(t, days[1].Articles, 2)
+	assert.Equal(t, "u3", days[1].Articles[0].URL)
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// notDeletedCondition matches articles that are not in the trash. Articles
+// are moved to the trash by DeleteArticle and PruneDeadArticles, and only
+// removed from the database by PurgeArticle and PurgeTrash. Trashed articles
+// stay in the articles table, so that ingestion does not store them again.
+const notDeletedCondition = "deleted_at IS NULL"
+
+// articleTables are the tables holding data about an article, keyed by
+// article_id. Foreign keys are not enforced, so purging deletes their rows.
+var articleTables = []string{"article_tags", "article_categories", "article_enrichment", "reprocess_queue", "article_cves", "breaches", "bookmarks", "article_reads", "article_feedback", "article_engagement", "integration_failures"}
+
+// TrashFilter describes which articles of the trash to return.
+type TrashFilter struct {
+	// Search restricts results to articles whose title contains it.
+	Search string
+	Limit  int
+	Offset int
+}
+
+// DeleteArticle moves an article to the trash, hiding it from every query
+// until it is restored. It returns sql.ErrNoRows if the article does not exist
+// or is already in the trash.
+func DeleteArticle(id int64) error {
+	return updateArticle("UPDATE articles SET deleted_at = ? WHERE id = ? AND "+notDeletedCondition, time.Now().UTC(), id)
+}
+
+// RestoreArticle takes an article out of the trash. A dead link is checked
+// again, so that PruneDeadArticles does not move the article straight back.
+// It returns sql.ErrNoRows if the article is not in the trash.
+func RestoreArticle(id int64) error {
+	return updateArticle(`UPDATE articles SET deleted_at = NULL,
+		link_status = CASE WHEN `+deadLinkCondition+` THEN 0 ELSE link_status END,
+		link_checked_at = CASE WHEN `+deadLinkCondition+` THEN NULL ELSE link_checked_at END
+		WHERE id = ? AND NOT `+notDeletedCondition, id)
+}
+
+func updateArticle(query string, args ...interface{}) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	res, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetTrash returns the articles in the trash of every organization, most
+// recently deleted first.
+func GetTrash(f TrashFilter) ([]models.TrashedArticle, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	query := "SELECT " + articleColumns + ", deleted_at FROM articles WHERE NOT " + notDeletedCondition
+	var args []interface{}
+	if f.Search != "" {
+		query += " AND LOWER(title) LIKE ?"
+		args = append(args, "%"+strings.ToLower(f.Search)+"%")
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	query += " ORDER BY deleted_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, f.Offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trash := []models.TrashedArticle{}
+	for rows.Next() {
+		var a models.TrashedArticle
+		if err := rows.Scan(append(articleScanTargets(&a.NewsArticle), &a.DeletedAt)...); err != nil {
+			return nil, err
+		}
+		trash = append(trash, a)
+	}
+	return trash, rows.Err()
+}
+
+// PurgeArticle deletes an article in the trash from the database, with its
+// tags, user state and archived body. It returns sql.ErrNoRows if the article
+// is not in the trash.
+func PurgeArticle(id int64) error {
+	n, err := purgeArticles("id = ?", id)
+	if err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// PurgeTrash deletes the articles moved to the trash before the cutoff from
+// the database, and returns how many were deleted.
+func PurgeTrash(cutoff time.Time) (int, error) {
+	return purgeArticles("deleted_at < ?", cutoff.UTC())
+}
+
+// purgeArticles deletes the articles in the trash matching condition, with the
+// rows of articleTables and the archived bodies no other article links to.
+func purgeArticles(condition string, args ...interface{}) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	where := "NOT " + notDeletedCondition + " AND " + condition
+	for _, table := range articleTables {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE article_id IN (SELECT id FROM articles WHERE "+where+")", args...); err != nil {
+			return 0, fmt.Errorf("failed to purge %s: %v", table, err)
+		}
+	}
+	res, err := tx.Exec("DELETE FROM articles WHERE "+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge articles: %v", err)
+	}
+	purged, _ := res.RowsAffected()
+	if _, err := tx.Exec("DELETE FROM article_bodies WHERE url NOT IN (SELECT url FROM articles)"); err != nil {
+		return 0, fmt.Errorf("failed to purge article_bodies: %v", err)
+	}
+	return int(purged), tx.Commit()
+}
+
+// StartTrashPurge schedules the "trash-purge" job, which deletes the articles
+// that have been in the trash for longer than retention every night. Purged
+// articles that are still in their feed are ingested again.
+func StartTrashPurge(retention time.Duration) {
+	err := RegisterJob(Job{Name: "trash-purge", Schedule: "0 3 * * *", Run: func() error {
+		purged, err := PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			return fmt.Errorf("failed to purge the trash: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("Purged %d articles from the trash.", purged)
+		}
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling the trash purge: %v", err)
+	}
+}
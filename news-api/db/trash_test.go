@@ -0,0 +1,65 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	now := time.Now()
+	for _, title := range []string{"Kept", "Mistake", "Spam"} {
+		require.NoError(t, InsertArticle(models.NewsArticle{Title: title, URL: "https://example.com/" + title, SourceURL: "src", PublishedAt: now}))
+	}
+	articles, err := QueryArticles(ArticleFilter{SortBy: "idAsc"})
+	require.NoError(t, err)
+	require.Len(t, articles, 3)
+	mistake, spam := articles[1], articles[2]
+
+	require.NoError(t, DeleteArticle(mistake.ID))
+	require.NoError(t, DeleteArticle(spam.ID))
+	assert.ErrorIs(t, DeleteArticle(spam.ID), sql.ErrNoRows, "already in the trash")
+	articles, err = QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Kept", articles[0].Title)
+	_, err = GetOrgArticle(0, spam.ID, false)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Deleted articles are not ingested again.
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "Spam", URL: spam.URL, SourceURL: "src", PublishedAt: now}))
+	count, err := GetArticleCount()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	trash, err := GetTrash(TrashFilter{Search: "spa"})
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, spam.ID, trash[0].ID)
+	assert.WithinDuration(t, time.Now(), trash[0].DeletedAt, time.Minute)
+
+	require.NoError(t, RestoreArticle(mistake.ID))
+	assert.ErrorIs(t, RestoreArticle(mistake.ID), sql.ErrNoRows, "not in the trash")
+	_, err = GetOrgArticle(0, mistake.ID, false)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, PurgeArticle(mistake.ID), sql.ErrNoRows, "only articles in the trash are purged")
+	purged, err := PurgeTrash(now.Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = PurgeTrash(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = GetArticleByID(spam.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	trash, err = GetTrash(TrashFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// normalizeArticleURL reduces the variants under which feeds publish the same
+// article to one form: the scheme, a "www." prefix, default ports, trailing
+// slashes, fragments and utm_* tracking parameters are ignored, and the other
+// query parameters are sorted. URLs that do not parse are only trimmed.
+func normalizeArticleURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	var params []string
+	if u.RawQuery != "" {
+		for _, param := range strings.Split(u.RawQuery, "&") {
+			if param != "" && !strings.HasPrefix(strings.ToLower(param), "utm_") {
+				params = append(params, param)
+			}
+		}
+		sort.Strings(params)
+	}
+
+	normalized := host + strings.TrimRight(u.EscapedPath(), "/")
+	if len(params) > 0 {
+		normalized += "?" + strings.Join(params, "&")
+	}
+	return normalized
+}
+
+// urlHash identifies an article URL regardless of its variant.
+func urlHash(raw string) string {
+	sum := sha256.Sum256([]byte(normalizeArticleURL(raw)))
+	return hex.EncodeToString(sum[:])
+}
+
+// migrateURLHashes computes the URL hash of articles stored before it was
+// recorded, and again for URLs with several query parameters, which were
+// hashed in their original order. When several stored articles of an
+// organization are variants of the same URL, only the oldest gets the hash and
+// the others are moved to the trash.
+func migrateURLHashes() error {
+	if _, err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_org_url_hash ON articles (org_id, url_hash) WHERE url_hash != ''"); err != nil {
+		return fmt.Errorf("failed to create URL hash index: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT id, url, url_hash FROM articles
+		WHERE (url_hash = '' AND ` + notDeletedCondition + `) OR url LIKE '%?%&%' ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to read article URLs: %v", err)
+	}
+	type pending struct {
+		id   int64
+		hash string
+	}
+	var articles []pending
+	for rows.Next() {
+		var id int64
+		var u, stored string
+		if err := rows.Scan(&id, &u, &stored); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read article URLs: %v", err)
+		}
+		if hash := urlHash(u); hash != stored {
+			articles = append(articles, pending{id: id, hash: hash})
+		}
+	}
+	rows.Close()
+	if len(articles) == 0 {
+		return nil
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	now := time.Now().UTC()
+	trashed := 0
+	for _, p := range articles {
+		res, err := tx.Exec("UPDATE OR IGNORE articles SET url_hash = ? WHERE id = ?", p.hash, p.id)
+		if err != nil {
+			return fmt.Errorf("failed to store URL hash of article %d: %v", p.id, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		res, err = tx.Exec("UPDATE articles SET deleted_at = ? WHERE id = ? AND "+notDeletedCondition, now, p.id)
+		if err != nil {
+			return fmt.Errorf("failed to move duplicate article %d to the trash: %v", p.id, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			trashed++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if trashed > 0 {
+		log.Printf("Moved %d articles duplicating another's URL to the trash.", trashed)
+	}
+	return nil
+}
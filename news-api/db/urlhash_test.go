@@ -0,0 +1,91 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLHashVariants(t *testing.T) {
+	base := urlHash("https://www.example.com/news/story")
+	for _, variant := range []string{
+		"http://www.example.com/news/story",
+		"https://example.com/news/story/",
+		"HTTPS://Example.com:443/news/story#comments",
+		"https://example.com/news/story?utm_source=rss&utm_medium=feed",
+		" https://example.com/news/story ",
+	} {
+		assert.Equal(t, base, urlHash(variant), variant)
+	}
+	for _, other := range []string{
+		"https://example.com/news/other-story",
+		"https://example.com/news/story?id=2",
+		"https://example.com:8443/news/story",
+		"https://blog.example.com/news/story",
+	} {
+		assert.NotEqual(t, base, urlHash(other), other)
+	}
+	assert.Equal(t, "example.com/a?id=1&page=2", normalizeArticleURL("https://example.com/a/?id=1&utm_campaign=x&page=2"))
+	assert.Equal(t, urlHash("https://example.com/a?id=1&page=2"), urlHash("https://example.com/a?page=2&id=1"), "parameters are sorted")
+}
+
+func TestInsertArticleIgnoresURLVariants(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	inserted, err := insertArticle(models.NewsArticle{Title: "Original", URL: "https://www.example.com/story/", SourceURL: "src", PublishedAt: now})
+	require.NoError(t, err)
+	assert.True(t, inserted)
+	inserted, err = insertArticle(models.NewsArticle{Title: "Variant", URL: "http://example.com/story?utm_source=rss", SourceURL: "other", PublishedAt: now})
+	require.NoError(t, err)
+	assert.False(t, inserted)
+
+	// Another organization keeps its own copy.
+	inserted, err = insertArticle(models.NewsArticle{Title: "Variant", URL: "http://example.com/story", SourceURL: "src", PublishedAt: now, OrgID: 1})
+	require.NoError(t, err)
+	assert.True(t, inserted)
+}
+
+func TestMigrateURLHashes(t *testing.T) {
+	setupTestDB(t)
+	for _, u := range []string{"https://example.com/a", "http://www.example.com/a/", "https://example.com/b", "https://example.com/c?page=2&id=1"} {
+		_, err := db.Exec("INSERT INTO articles(title, description, imageUrl, url, sourceUrl) VALUES('t', '', '', ?, 'src')", u)
+		require.NoError(t, err)
+	}
+	// Hashed before parameters were sorted.
+	_, err := db.Exec("UPDATE articles SET url_hash = 'unsorted' WHERE url LIKE '%&%'")
+	require.NoError(t, err)
+	require.NoError(t, migrateURLHashes())
+
+	type stored struct {
+		hash    string
+		trashed bool
+	}
+	read := func() map[string]stored {
+		rows, err := db.Query("SELECT url, url_hash, deleted_at IS NOT NULL FROM articles ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		articles := map[string]stored{}
+		for rows.Next() {
+			var u string
+			var s stored
+			require.NoError(t, rows.Scan(&u, &s.hash, &s.trashed))
+			articles[u] = s
+		}
+		return articles
+	}
+	articles := read()
+	assert.Equal(t, stored{hash: urlHash("https://example.com/a")}, articles["https://example.com/a"])
+	assert.Equal(t, stored{trashed: true}, articles["http://www.example.com/a/"], "later variants are moved to the trash")
+	assert.Equal(t, stored{hash: urlHash("https://example.com/b")}, articles["https://example.com/b"])
+	assert.Equal(t, stored{hash: urlHash("https://example.com/c?id=1&page=2")}, articles["https://example.com/c?page=2&id=1"])
+
+	require.NoError(t, migrateURLHashes())
+	assert.Equal(t, articles, read())
+	news, err := QueryArticles(ArticleFilter{})
+	require.NoError(t, err)
+	assert.Len(t, news, 3)
+}
@@ -0,0 +1,256 @@
+package db
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/models"
+)
+
+// ErrUserExists is returned when registering a username that is already taken.
+var ErrUserExists = errors.New("username already exists")
+
+// ErrInvalidCredentials is returned when a username/password pair or session token is not valid.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// passwordIterations is the PBKDF2-SHA256 work factor for new password hashes.
+var passwordIterations = 600000
+
+func createUserTables() error {
+	createUsersSQL := `
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		org_id INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS sessions (
+		token_hash TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS bookmarks (
+		user_id INTEGER NOT NULL,
+		article_id INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, article_id)
+	);
+	CREATE TABLE IF NOT EXISTS article_reads (
+		user_id INTEGER NOT NULL,
+		article_id INTEGER NOT NULL,
+		read_at DATETIME NOT NULL,
+		PRIMARY KEY (user_id, article_id)
+	);
+	`
+	if _, err := db.Exec(createUsersSQL); err != nil {
+		return fmt.Errorf("failed to create user tables: %v", err)
+	}
+	return addColumnIfMissing("users", "org_id", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// hashPassword derives a salted PBKDF2-SHA256 hash encoded as iterations$salt$hash.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, hex.EncodeToString(salt), hex.EncodeToString(key)), nil
+}
+
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateUser registers a new account.
+func CreateUser(username, password string) (models.User, error) {
+	if db == nil {
+		return models.User{}, fmt.Errorf("database connection is nil")
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	now := time.Now().UTC()
+	res, err := db.Exec("INSERT INTO users(username, password_hash, created_at) VALUES(?, ?, ?)", username, hash, now)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return models.User{}, ErrUserExists
+		}
+		return models.User{}, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.User{}, err
+	}
+	return models.User{ID: id, Username: username, CreatedAt: now}, nil
+}
+
+// AuthenticateUser checks a username and password.
+func AuthenticateUser(username, password string) (models.User, error) {
+	if db == nil {
+		return models.User{}, fmt.Errorf("database connection is nil")
+	}
+	var user models.User
+	var hash string
+	err := db.QueryRow("SELECT id, username, password_hash, created_at, org_id FROM users WHERE username = ?", username).
+		Scan(&user.ID, &user.Username, &hash, &user.CreatedAt, &user.OrgID)
+	if err == sql.ErrNoRows {
+		// Spend the same time as a real check so usernames can't be probed by timing.
+		checkPassword("pbkdf2-sha256$"+strconv.Itoa(passwordIterations)+"$00$00", password)
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if !checkPassword(hash, password) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// CreateSession issues a new bearer token for a user. Only a hash of the token is stored.
+func CreateSession(userID int64, ttl time.Duration) (string, time.Time, error) {
+	if db == nil {
+		return "", time.Time{}, fmt.Errorf("database connection is nil")
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().UTC().Add(ttl)
+	_, err := db.Exec("INSERT INTO sessions(token_hash, user_id, expires_at) VALUES(?, ?, ?)", hashToken(token), userID, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// GetUserBySession resolves a bearer token to its user.
+func GetUserBySession(token string) (models.User, error) {
+	if db == nil {
+		return models.User{}, fmt.Errorf("database connection is nil")
+	}
+	var user models.User
+	var expiresAt time.Time
+	err := db.QueryRow(`SELECT u.id, u.username, u.created_at, u.org_id, s.expires_at
+		FROM sessions s JOIN users u ON u.id = s.user_id WHERE s.token_hash = ?`, hashToken(token)).
+		Scan(&user.ID, &user.Username, &user.CreatedAt, &user.OrgID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if time.Now().After(expiresAt) {
+		db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
+		return models.User{}, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// DeleteSession revokes a bearer token.
+func DeleteSession(token string) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	_, err := db.Exec("DELETE FROM sessions WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// SetBookmark adds or removes an article from a user's bookmarks.
+func SetBookmark(userID, articleID int64, bookmarked bool) error {
+	return setUserArticleFlag("bookmarks", "created_at", userID, articleID, bookmarked)
+}
+
+// SetRead marks an article as read or unread for a user.
+func SetRead(userID, articleID int64, read bool) error {
+	return setUserArticleFlag("article_reads", "read_at", userID, articleID, read)
+}
+
+func setUserArticleFlag(table, timeColumn string, userID, articleID int64, set bool) error {
+	if db == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+	if !set {
+		_, err := db.Exec("DELETE FROM "+table+" WHERE user_id = ? AND article_id = ?", userID, articleID)
+		return err
+	}
+	// Users can only flag articles of their own organization.
+	var visible int
+	err := db.QueryRow("SELECT 1 FROM articles a JOIN users u ON u.id = ? WHERE a.id = ? AND a.org_id = u.org_id AND a.deleted_at IS NULL", userID, articleID).Scan(&visible)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("INSERT OR IGNORE INTO "+table+"(user_id, article_id, "+timeColumn+") VALUES(?, ?, ?)", userID, articleID, time.Now().UTC())
+	return err
+}
+
+// GetBookmarks returns a user's bookmarked articles, most recently bookmarked first.
+func GetBookmarks(userID int64) ([]models.BookmarkedArticle, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	rows, err := db.Query(`SELECT `+qualifiedArticleColumns("a")+`, b.created_at, r.article_id IS NOT NULL
+		FROM bookmarks b
+		JOIN users u ON u.id = b.user_id
+		JOIN articles a ON a.id = b.article_id AND a.org_id = u.org_id AND a.deleted_at IS NULL
+		LEFT JOIN article_reads r ON r.user_id = b.user_id AND r.article_id = b.article_id
+		WHERE b.user_id = ?
+		ORDER BY b.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []models.BookmarkedArticle{}
+	for rows.Next() {
+		var b models.BookmarkedArticle
+		targets := append(articleScanTargets(&b.NewsArticle), &b.BookmarkedAt, &b.Read)
+		if err := rows.Scan(targets...); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
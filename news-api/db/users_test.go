@@ -0,0 +1,100 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserAuthentication(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	passwordIterations = 1000
+
+	user, err := CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+	assert.NotZero(t, user.ID)
+
+	_, err = CreateUser("Analyst", "another password")
+	assert.ErrorIs(t, err, ErrUserExists, "usernames are case-insensitive")
+
+	got, err := AuthenticateUser("analyst", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, got.ID)
+
+	_, err = AuthenticateUser("analyst", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = AuthenticateUser("nobody", "correct horse")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestSessions(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	passwordIterations = 1000
+
+	user, err := CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+
+	token, _, err := CreateSession(user.ID, time.Hour)
+	require.NoError(t, err)
+	got, err := GetUserBySession(token)
+	require.NoError(t, err)
+	assert.Equal(t, "analyst", got.Username)
+
+	require.NoError(t, DeleteSession(token))
+	_, err = GetUserBySession(token)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	expired, _, err := CreateSession(user.ID, -time.Minute)
+	require.NoError(t, err)
+	_, err = GetUserBySession(expired)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestBookmarksAndReadState(t *testing.T) {
+	setupTestDB(t)
+	defer teardownTestDB()
+	passwordIterations = 1000
+
+	user, err := CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+	other, err := CreateUser("other", "correct horse")
+	require.NoError(t, err)
+
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "a1", URL: "u1", PublishedAt: time.Now()}))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "a2", URL: "u2", PublishedAt: time.Now()}))
+	articles, err := GetArticlesFromDB("", "", "", 10, time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+	first, second := articles[0].ID, articles[1].ID
+
+	require.NoError(t, SetBookmark(user.ID, first, true))
+	require.NoError(t, SetBookmark(user.ID, second, true))
+	require.NoError(t, SetBookmark(user.ID, second, true), "bookmarking twice is a no-op")
+	require.NoError(t, SetRead(user.ID, first, true))
+	assert.Error(t, SetBookmark(user.ID, 9999, true), "unknown articles cannot be bookmarked")
+
+	bookmarks, err := GetBookmarks(user.ID)
+	require.NoError(t, err)
+	require.Len(t, bookmarks, 2)
+	readByID := map[int64]bool{}
+	for _, b := range bookmarks {
+		readByID[b.ID] = b.Read
+	}
+	assert.True(t, readByID[first])
+	assert.False(t, readByID[second])
+
+	otherBookmarks, err := GetBookmarks(other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, otherBookmarks, "bookmarks are personal")
+
+	require.NoError(t, SetBookmark(user.ID, first, false))
+	bookmarks, err = GetBookmarks(user.ID)
+	require.NoError(t, err)
+	assert.Len(t, bookmarks, 1)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// Vendors is the dictionary of vendor and product names counted by
+// GetVendorMentions.
+var Vendors = extract.DefaultVendors
+
+// LoadVendors reads a vendor dictionary from a JSON file mapping vendor names
+// to arrays of product names.
+func LoadVendors(path string) (extract.VendorDictionary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vendors file: %v", err)
+	}
+	var vendors extract.VendorDictionary
+	if err := json.Unmarshal(data, &vendors); err != nil {
+		return nil, fmt.Errorf("failed to parse vendors file: %v", err)
+	}
+	for vendor, products := range vendors {
+		if strings.TrimSpace(vendor) == "" {
+			return nil, fmt.Errorf("vendors file contains an empty vendor name")
+		}
+		for _, product := range products {
+			if strings.TrimSpace(product) == "" {
+				return nil, fmt.Errorf("vendor %q has an empty product name", vendor)
+			}
+		}
+	}
+	return vendors, nil
+}
+
+// GetVendorMentions counts the articles matching the filter that mention each
+// vendor of the Vendors dictionary in their title or description, and returns
+// the limit most mentioned vendors with the number of articles naming each
+// product.
+func GetVendorMentions(f ArticleFilter, limit int) ([]models.VendorMentions, error) {
+	articles, err := QueryArticles(f)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]*models.VendorMentions{}
+	for _, article := range articles {
+		for _, mention := range Vendors.Match(article.Title + " " + article.Description) {
+			s, ok := stats[mention.Vendor]
+			if !ok {
+				s = &models.VendorMentions{Vendor: mention.Vendor, Products: map[string]int{}}
+				stats[mention.Vendor] = s
+			}
+			s.Articles++
+			for _, product := range mention.Products {
+				s.Products[product]++
+			}
+		}
+	}
+
+	vendors := make([]models.VendorMentions, 0, len(stats))
+	for _, s := range stats {
+		vendors = append(vendors, *s)
+	}
+	sort.Slice(vendors, func(i, j int) bool {
+		if vendors[i].Articles != vendors[j].Articles {
+			return vendors[i].Articles > vendors[j].Articles
+		}
+		return vendors[i].Vendor < vendors[j].Vendor
+	})
+	if limit > 0 && len(vendors) > limit {
+		vendors = vendors[:limit]
+	}
+	return vendors, nil
+}
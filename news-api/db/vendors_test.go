@@ -0,0 +1,45 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetVendorMentions(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now()
+	for _, article := range []models.NewsArticle{
+		{Title: "Ivanti Connect Secure zero-day", Description: "Ivanti urges patching.", URL: "u1", PublishedAt: now},
+		{Title: "Attackers chain Connect Secure and ESXi bugs", URL: "u2", PublishedAt: now},
+		{Title: "VMware fixes vCenter flaw", URL: "u3", PublishedAt: now},
+		{Title: "Microsoft Patch Tuesday", URL: "u4", PublishedAt: now.Add(-30 * 24 * time.Hour)},
+	} {
+		require.NoError(t, InsertArticle(article))
+	}
+
+	vendors, err := GetVendorMentions(ArticleFilter{StartDate: now.Add(-24 * time.Hour)}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.VendorMentions{
+		{Vendor: "Ivanti", Articles: 2, Products: map[string]int{"Connect Secure": 2}},
+		{Vendor: "VMware", Articles: 2, Products: map[string]int{"ESXi": 1, "vCenter": 1}},
+	}, vendors)
+}
+
+func TestLoadVendors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vendors.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Acme": ["RoadRunner"]}`), 0o600))
+	vendors, err := LoadVendors(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"RoadRunner"}, vendors["Acme"])
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"Acme": [""]}`), 0o600))
+	_, err = LoadVendors(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WaybackURL is the base URL of the Internet Archive's Wayback Machine.
+var WaybackURL = "https://web.archive.org"
+
+// waybackTimestamp is the layout of Wayback Machine capture times.
+const waybackTimestamp = "20060102150405"
+
+// waybackSnapshot is a capture of a URL by the Wayback Machine.
+type waybackSnapshot struct {
+	Timestamp string
+	Original  string
+}
+
+// PageURL returns the address of the capture for readers, in the Wayback
+// Machine's page.
+func (s waybackSnapshot) PageURL() string {
+	return WaybackURL + "/web/" + s.Timestamp + "/" + s.Original
+}
+
+// RawURL returns the address of the capture as it was archived, without the
+// Wayback Machine's toolbar and rewritten links.
+func (s waybackSnapshot) RawURL() string {
+	return WaybackURL + "/web/" + s.Timestamp + "id_/" + s.Original
+}
+
+// waybackSnapshots lists the successful captures of a URL since a time,
+// oldest first, skipping those identical to the previous capture.
+func waybackSnapshots(client *http.Client, target string, since time.Time) ([]waybackSnapshot, error) {
+	query := url.Values{"collapse": {"digest"}}
+	if !since.IsZero() {
+		query.Set("from", since.UTC().Format(waybackTimestamp))
+	}
+	return queryWayback(client, target, query)
+}
+
+// latestWaybackSnapshot returns the newest successful capture of a URL, and
+// false if it was never captured.
+func latestWaybackSnapshot(client *http.Client, target string) (waybackSnapshot, bool, error) {
+	snapshots, err := queryWayback(client, target, url.Values{"limit": {"-1"}, "fastLatest": {"true"}})
+	if err != nil || len(snapshots) == 0 {
+		return waybackSnapshot{}, false, err
+	}
+	return snapshots[len(snapshots)-1], true, nil
+}
+
+// queryWayback lists the successful captures of a URL with the Wayback
+// Machine's CDX API.
+func queryWayback(client *http.Client, target string, query url.Values) ([]waybackSnapshot, error) {
+	query.Set("url", target)
+	query.Set("output", "json")
+	query.Set("fl", "timestamp,original")
+	query.Set("filter", "statuscode:200")
+	resp, err := client.Get(WaybackURL + "/cdx/search/cdx?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wayback machine returned %s", resp.Status)
+	}
+	var rows [][]string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&rows); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid wayback machine response: %v", err)
+	}
+	var snapshots []waybackSnapshot
+	// The first row names the fields.
+	for i, row := range rows {
+		if i == 0 || len(row) < 2 {
+			continue
+		}
+		snapshots = append(snapshots, waybackSnapshot{Timestamp: row[0], Original: row[1]})
+	}
+	return snapshots, nil
+}
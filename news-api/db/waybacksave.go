@@ -0,0 +1,150 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"news-api/fetcher"
+)
+
+// WaybackSaveConfig controls the submission of new articles to the Wayback
+// Machine's Save Page Now API, so that critical reporting is preserved.
+type WaybackSaveConfig struct {
+	// MinSeverity is the severity an article needs to be submitted. The
+	// default, SeverityHigh, submits the articles counted towards Code Red.
+	MinSeverity int
+	// Batch is the number of URLs submitted after each caching cycle.
+	Batch int
+	// MaxAge limits the submissions to articles ingested this recently, so
+	// that enabling them does not submit the whole history.
+	MaxAge time.Duration
+	// ExcludedSources lists feeds whose articles are never submitted.
+	ExcludedSources []string
+	// AccessKey and SecretKey are the archive.org S3 keys of an account,
+	// which raise the rate limits. Submissions are anonymous without them.
+	AccessKey string
+	SecretKey string
+}
+
+var waybackSaveClient = fetcher.Client(time.Minute)
+
+func createWaybackSaveTables() error {
+	createSavesSQL := `
+	CREATE TABLE IF NOT EXISTS wayback_saves (
+		url TEXT PRIMARY KEY,
+		status INTEGER NOT NULL,
+		job_id TEXT NOT NULL DEFAULT '',
+		submitted_at DATETIME NOT NULL
+	);
+	`
+	if _, err := db.Exec(createSavesSQL); err != nil {
+		return fmt.Errorf("failed to create wayback_saves table: %v", err)
+	}
+	return nil
+}
+
+// StartWaybackSaves submits newly qualifying articles to the Wayback Machine
+// after each caching cycle.
+func StartWaybackSaves(cfg WaybackSaveConfig) {
+	RegisterCycleHook(func() {
+		if submitted, err := SubmitToWayback(cfg); err != nil {
+			log.Printf("Error submitting articles to the Wayback Machine: %v", err)
+		} else if submitted > 0 {
+			log.Printf("Submitted %d articles to the Wayback Machine.", submitted)
+		}
+	})
+}
+
+// SubmitToWayback asks the Wayback Machine to capture up to cfg.Batch article
+// URLs at or above cfg.MinSeverity ingested within cfg.MaxAge, highest
+// severity first. A URL is submitted once: the response is recorded, except
+// for rate limiting, server errors and network errors, after which the
+// remaining URLs are left for the next cycle. It returns the number of URLs
+// accepted.
+func SubmitToWayback(cfg WaybackSaveConfig) (int, error) {
+	if db == nil {
+		return 0, fmt.Errorf("database connection is nil")
+	}
+	query := `SELECT url FROM articles WHERE severity >= ? AND ingested_at >= ? AND ` + notDeletedCondition + ` AND NOT ` + deadLinkCondition + `
+		AND url NOT IN (SELECT url FROM wayback_saves)`
+	args := []interface{}{cfg.MinSeverity, time.Now().Add(-cfg.MaxAge).UTC()}
+	if len(cfg.ExcludedSources) > 0 {
+		query += " AND sourceUrl NOT IN (?" + strings.Repeat(", ?", len(cfg.ExcludedSources)-1) + ")"
+		for _, source := range cfg.ExcludedSources {
+			args = append(args, source)
+		}
+	}
+	query += " GROUP BY url ORDER BY MAX(severity) DESC, MAX(publishedAt) DESC LIMIT ?"
+	args = append(args, cfg.Batch)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, u)
+	}
+	rows.Close()
+
+	submitted := 0
+	for _, link := range urls {
+		if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		status, jobID, err := savePageNow(link, cfg)
+		if err != nil {
+			return submitted, fmt.Errorf("failed to submit %s: %v", link, err)
+		}
+		if status == http.StatusTooManyRequests || status >= 500 {
+			return submitted, fmt.Errorf("wayback machine answered %d, retrying next cycle", status)
+		}
+		if _, err := db.Exec("INSERT OR REPLACE INTO wayback_saves(url, status, job_id, submitted_at) VALUES(?, ?, ?, ?)",
+			link, status, jobID, time.Now().UTC()); err != nil {
+			return submitted, fmt.Errorf("failed to record the submission of %s: %v", link, err)
+		}
+		if status < 300 {
+			submitted++
+		}
+	}
+	return submitted, nil
+}
+
+// savePageNow submits a URL to the Save Page Now API and returns the HTTP
+// status and, for authenticated submissions, the capture job's ID.
+func savePageNow(link string, cfg WaybackSaveConfig) (int, string, error) {
+	form := url.Values{"url": {link}}
+	req, err := http.NewRequest(http.MethodPost, WaybackURL+"/save", strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if cfg.AccessKey != "" {
+		req.Header.Set("Authorization", "LOW "+cfg.AccessKey+":"+cfg.SecretKey)
+	}
+	resp, err := waybackSaveClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	var job struct {
+		JobID string `json:"job_id"`
+	}
+	if resp.StatusCode < 300 {
+		// Anonymous submissions are answered with an HTML page.
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&job)
+	}
+	return resp.StatusCode, job.JobID, nil
+}
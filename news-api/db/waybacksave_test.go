@@ -0,0 +1,85 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubmitToWayback(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+	_, err := db.Exec("DELETE FROM wayback_saves")
+	require.NoError(t, err)
+
+	var submitted []string
+	limited := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/save", r.URL.Path)
+		assert.Equal(t, "LOW key:secret", r.Header.Get("Authorization"))
+		link := r.FormValue("url")
+		if limited {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		submitted = append(submitted, link)
+		if strings.HasSuffix(link, "/blocked") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"url": "` + link + `", "job_id": "spn2-1"}`))
+	}))
+	defer server.Close()
+	defer func(base string) { WaybackURL = base }(WaybackURL)
+	WaybackURL = server.URL
+
+	now := time.Now()
+	articles := []models.NewsArticle{
+		{Title: "critical", URL: "https://news.example/critical", Severity: 100},
+		{Title: "blocked", URL: "https://news.example/blocked", Severity: 60},
+		{Title: "minor", URL: "https://news.example/minor", Severity: 5},
+		{Title: "pending", URL: "https://news.example/pending", Severity: 30},
+	}
+	for _, a := range articles {
+		a.SourceURL = "src"
+		a.PublishedAt = now
+		require.NoError(t, InsertArticle(a))
+	}
+	cfg := WaybackSaveConfig{MinSeverity: SeverityHigh, Batch: 2, MaxAge: time.Hour, AccessKey: "key", SecretKey: "secret"}
+
+	n, err := SubmitToWayback(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"https://news.example/critical", "https://news.example/blocked"}, submitted)
+	var jobID string
+	require.NoError(t, db.QueryRow("SELECT job_id FROM wayback_saves WHERE url = ?", "https://news.example/critical").Scan(&jobID))
+	assert.Equal(t, "spn2-1", jobID)
+
+	// Rate limited URLs wait for the next cycle.
+	limited = true
+	_, err = SubmitToWayback(cfg)
+	assert.Error(t, err)
+	limited = false
+	submitted = nil
+	n, err = SubmitToWayback(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"https://news.example/pending"}, submitted, "URLs are submitted once")
+
+	// Articles ingested before MaxAge are left alone.
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "old", URL: "https://news.example/old", SourceURL: "src", Severity: 100, PublishedAt: now}))
+	_, err = db.Exec("UPDATE articles SET ingested_at = ? WHERE title = 'old'", now.Add(-2*time.Hour).UTC())
+	require.NoError(t, err)
+	submitted = nil
+	n, err = SubmitToWayback(cfg)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, submitted)
+}
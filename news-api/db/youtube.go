@@ -0,0 +1,187 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// MediaTypeVideo is the media type of the articles of YouTube sources.
+const MediaTypeVideo = "video"
+
+// MediaTypes are the values of ArticleFilter.MediaType. "text" selects the
+// articles without a media type.
+var MediaTypes = []string{"text", MediaTypeVideo, MediaTypePodcast}
+
+// mediaTypeValue returns the stored media type selected by a filter value.
+func mediaTypeValue(mediaType string) string {
+	if mediaType == "text" {
+		return ""
+	}
+	return mediaType
+}
+
+// YouTubeAPIKey is a YouTube Data API key used to look up the duration of
+// new videos, which the channel and playlist feeds do not carry. Without it,
+// videos are stored without a duration.
+var YouTubeAPIKey string
+
+// youtubeVideosEndpoint is the YouTube Data API videos.list method, replaced
+// by tests.
+var youtubeVideosEndpoint = "https://www.googleapis.com/youtube/v3/videos"
+
+// youtubeFeedURL is the feed of a YouTube channel or playlist.
+const youtubeFeedURL = "https://www.youtube.com/feeds/videos.xml"
+
+// maxYouTubeIDs is the most videos videos.list accepts per call.
+const maxYouTubeIDs = 50
+
+// isoDurationPattern matches the ISO 8601 durations of the YouTube Data API,
+// such as "PT1H2M3S" or "P1DT2H".
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// YouTubeFeedURL returns the feed of a YouTube channel, given its "UC..." ID,
+// or of a playlist, given its "PL..." (or other) ID.
+func YouTubeFeedURL(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	switch {
+	case id == "":
+		return "", fmt.Errorf("empty YouTube channel or playlist ID")
+	case strings.HasPrefix(id, "UC") && len(id) == 24:
+		return youtubeFeedURL + "?channel_id=" + url.QueryEscape(id), nil
+	default:
+		return youtubeFeedURL + "?playlist_id=" + url.QueryEscape(id), nil
+	}
+}
+
+// isYouTubeSource reports whether a source is a YouTube channel or playlist
+// feed.
+func isYouTubeSource(source string) bool {
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	return host == "youtube.com" && u.Path == "/feeds/videos.xml"
+}
+
+// annotateYouTubeItems moves the description and thumbnail of each video,
+// which YouTube feeds carry in a media:group, to the item, and adds the video
+// durations as yt:duration extensions when YouTubeAPIKey is set.
+func annotateYouTubeItems(client *http.Client, items []*gofeed.Item) {
+	var ids []string
+	for _, item := range items {
+		if group := firstExtension(item.Extensions, "media", "group"); group != nil {
+			if item.Description == "" {
+				if description := group.Children["description"]; len(description) > 0 {
+					item.Description = description[0].Value
+				}
+			}
+			if item.Image == nil {
+				if thumbnail := group.Children["thumbnail"]; len(thumbnail) > 0 && thumbnail[0].Attrs["url"] != "" {
+					item.Image = &gofeed.Image{URL: thumbnail[0].Attrs["url"]}
+				}
+			}
+		}
+		if video := firstExtension(item.Extensions, "yt", "videoId"); video != nil && video.Value != "" {
+			ids = append(ids, video.Value)
+		}
+	}
+	if YouTubeAPIKey == "" || len(ids) == 0 {
+		return
+	}
+	durations := map[string]int{}
+	for start := 0; start < len(ids); start += maxYouTubeIDs {
+		batch, err := fetchYouTubeDurations(client, ids[start:min(start+maxYouTubeIDs, len(ids))])
+		if err != nil {
+			log.Printf("Error fetching YouTube video durations: %v", err)
+			return
+		}
+		for id, seconds := range batch {
+			durations[id] = seconds
+		}
+	}
+	for _, item := range items {
+		video := firstExtension(item.Extensions, "yt", "videoId")
+		if video == nil {
+			continue
+		}
+		if seconds, ok := durations[video.Value]; ok {
+			item.Extensions["yt"]["duration"] = []ext.Extension{{Name: "duration", Value: strconv.Itoa(seconds)}}
+		}
+	}
+}
+
+// fetchYouTubeDurations returns the durations, in seconds, of videos by ID.
+func fetchYouTubeDurations(client *http.Client, ids []string) (map[string]int, error) {
+	query := url.Values{"part": {"contentDetails"}, "id": {strings.Join(ids, ",")}, "key": {YouTubeAPIKey}}
+	resp, err := client.Get(youtubeVideosEndpoint + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	var videos struct {
+		Items []struct {
+			ID             string `json:"id"`
+			ContentDetails struct {
+				Duration string `json:"duration"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&videos); err != nil {
+		return nil, fmt.Errorf("failed to parse YouTube videos: %v", err)
+	}
+	durations := make(map[string]int, len(videos.Items))
+	for _, video := range videos.Items {
+		if seconds, ok := parseISODuration(video.ContentDetails.Duration); ok {
+			durations[video.ID] = seconds
+		}
+	}
+	return durations, nil
+}
+
+// parseISODuration converts an ISO 8601 duration without years or months to
+// seconds.
+func parseISODuration(s string) (int, bool) {
+	match := isoDurationPattern.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, false
+	}
+	seconds := 0
+	for i, unit := range []int{86400, 3600, 60, 1} {
+		if match[i+1] != "" {
+			n, _ := strconv.Atoi(match[i+1])
+			seconds += n * unit
+		}
+	}
+	return seconds, true
+}
+
+// youtubeDuration returns the duration of a video item annotated by
+// annotateYouTubeItems, 0 if it is unknown.
+func youtubeDuration(item *gofeed.Item) int {
+	if duration := firstExtension(item.Extensions, "yt", "duration"); duration != nil {
+		if seconds, err := strconv.Atoi(duration.Value); err == nil {
+			return seconds
+		}
+	}
+	return 0
+}
+
+func firstExtension(extensions ext.Extensions, namespace, name string) *ext.Extension {
+	if matches := extensions[namespace][name]; len(matches) > 0 {
+		return &matches[0]
+	}
+	return nil
+}
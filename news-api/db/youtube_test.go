@@ -0,0 +1,98 @@
+package db
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+const testYouTubeFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <title>DEFCONConference</title>
+ <entry>
+  <id>yt:video:abc123XYZ_0</id>
+  <yt:videoId>abc123XYZ_0</yt:videoId>
+  <title>DEF CON 32 - Breaking Secure Boot</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=abc123XYZ_0"/>
+  <published>2024-10-01T16:00:00+00:00</published>
+  <media:group>
+   <media:title>DEF CON 32 - Breaking Secure Boot</media:title>
+   <media:thumbnail url="https://i1.ytimg.com/vi/abc123XYZ_0/hqdefault.jpg" width="480" height="360"/>
+   <media:description>How a leaked platform key broke Secure Boot on hundreds of devices.</media:description>
+  </media:group>
+ </entry>
+</feed>`
+
+func TestYouTubeFeedURL(t *testing.T) {
+	feedURL, err := YouTubeFeedURL("UC6Om9kAkl32dWlDSNlDS9Iw")
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com/feeds/videos.xml?channel_id=UC6Om9kAkl32dWlDSNlDS9Iw", feedURL)
+	assert.True(t, isYouTubeSource(feedURL))
+	feedURL, err = YouTubeFeedURL("PL9fPq3eQfaaBk9DFnyJRpxPYJ2Oq9DXn3")
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.youtube.com/feeds/videos.xml?playlist_id=PL9fPq3eQfaaBk9DFnyJRpxPYJ2Oq9DXn3", feedURL)
+	_, err = YouTubeFeedURL(" ")
+	assert.Error(t, err)
+	assert.False(t, isYouTubeSource("https://www.youtube.com/@DEFCONConference"))
+}
+
+func TestParseISODuration(t *testing.T) {
+	for input, want := range map[string]int{"PT45S": 45, "PT1H2M3S": 3723, "PT20M": 1200, "P1DT2H": 93600, "P0D": 0} {
+		seconds, ok := parseISODuration(input)
+		assert.True(t, ok, input)
+		assert.Equal(t, want, seconds, input)
+	}
+	for _, input := range []string{"", "P", "PT", "1H", "P1Y"} {
+		_, ok := parseISODuration(input)
+		assert.False(t, ok, input)
+	}
+}
+
+func TestYouTubeVideoArticles(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, ClearAllArticlesForTest())
+
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.RawQuery
+		w.Write([]byte(`{"items": [{"id": "abc123XYZ_0", "contentDetails": {"duration": "PT41M7S"}}]}`))
+	}))
+	defer server.Close()
+	defer func(endpoint, key string) { youtubeVideosEndpoint, YouTubeAPIKey = endpoint, key }(youtubeVideosEndpoint, YouTubeAPIKey)
+	youtubeVideosEndpoint, YouTubeAPIKey = server.URL, "test-key"
+
+	feed, err := gofeed.NewParser().Parse(strings.NewReader(testYouTubeFeed))
+	require.NoError(t, err)
+	annotateYouTubeItems(server.Client(), feed.Items)
+	assert.Contains(t, requested, "id=abc123XYZ_0")
+	assert.Contains(t, requested, "key=test-key")
+
+	source := "https://www.youtube.com/feeds/videos.xml?channel_id=UC6Om9kAkl32dWlDSNlDS9Iw"
+	article := articleFromItem(source, feed.Items[0])
+	assert.Equal(t, MediaTypeVideo, article.MediaType)
+	assert.Equal(t, 41*60+7, article.Duration)
+	assert.Equal(t, "How a leaked platform key broke Secure Boot on hundreds of devices.", article.Description)
+	assert.Equal(t, "https://i1.ytimg.com/vi/abc123XYZ_0/hqdefault.jpg", article.ImageURL)
+	assert.Equal(t, "https://www.youtube.com/watch?v=abc123XYZ_0", article.URL)
+
+	require.NoError(t, InsertArticle(article))
+	require.NoError(t, InsertArticle(models.NewsArticle{Title: "A text article", URL: "https://example.com/text", SourceURL: "https://example.com/feed", PublishedAt: time.Now()}))
+	videos, err := QueryArticles(ArticleFilter{MediaType: MediaTypeVideo})
+	require.NoError(t, err)
+	require.Len(t, videos, 1)
+	assert.Equal(t, MediaTypeVideo, videos[0].MediaType)
+	assert.Equal(t, 2467, videos[0].Duration)
+	assert.True(t, ArticleFilter{MediaType: MediaTypeVideo}.Matches(videos[0]))
+	texts, err := QueryArticles(ArticleFilter{MediaType: "text"})
+	require.NoError(t, err)
+	require.Len(t, texts, 1)
+	assert.Equal(t, "A text article", texts[0].Title)
+}
@@ -0,0 +1,136 @@
+// Package demo serves bundled sample feeds from a local server, so that the
+// whole pipeline can run without network access and on the same data every
+// time.
+package demo
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html"
+	"io/fs"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed feeds/*.xml
+var feedFiles embed.FS
+
+const icon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect width="16" height="16" rx="3" fill="#b91c1c"/></svg>`
+
+// Server serves the sample feeds, the article pages they link to and an icon.
+// Publication dates are relative to when the server started, so that the
+// sample articles are always recent.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:41234.
+	URL string
+
+	started time.Time
+	feeds   map[string]*template.Template
+	server  *http.Server
+}
+
+// NewServer parses the sample feeds for a server at baseURL without starting
+// it; use Handler to serve them.
+func NewServer(baseURL string, started time.Time) (*Server, error) {
+	s := &Server{URL: strings.TrimRight(baseURL, "/"), started: started, feeds: map[string]*template.Template{}}
+	names, err := fs.Glob(feedFiles, "feeds/*.xml")
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{
+		// ago formats the time a duration before the server started as an
+		// RSS date.
+		"ago": func(d string) (string, error) {
+			offset, err := time.ParseDuration(d)
+			if err != nil {
+				return "", err
+			}
+			return s.started.Add(-offset).UTC().Format(time.RFC1123Z), nil
+		},
+	}
+	for _, name := range names {
+		data, err := feedFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse demo feed %s: %v", name, err)
+		}
+		s.feeds[strings.TrimSuffix(path.Base(name), ".xml")] = tmpl
+	}
+	return s, nil
+}
+
+// Start serves the sample feeds on a free port of the loopback interface.
+func Start() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the demo feeds: %v", err)
+	}
+	s, err := NewServer("http://"+listener.Addr().String(), time.Now())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	s.server = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Close stops a server started with Start.
+func (s *Server) Close() error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Close()
+}
+
+// Sources returns the URLs of the sample feeds.
+func (s *Server) Sources() []string {
+	sources := make([]string, 0, len(s.feeds))
+	for name := range s.feeds {
+		sources = append(sources, s.URL+"/feeds/"+name+".xml")
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Handler serves /feeds/{name}.xml, the article pages under /articles/ and
+// /icon.svg.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /feeds/{file}", func(w http.ResponseWriter, r *http.Request) {
+		tmpl, ok := s.feeds[strings.TrimSuffix(r.PathValue("file"), ".xml")]
+		if !ok || !strings.HasSuffix(r.PathValue("file"), ".xml") {
+			http.NotFound(w, r)
+			return
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, map[string]string{"Base": s.URL}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+	mux.HandleFunc("GET /articles/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		title := strings.ReplaceAll(r.PathValue("slug"), "-", " ")
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!DOCTYPE html><html><head><title>%[1]s</title></head><body><h1>%[1]s</h1><p>This is a sample article of the Threatfeed demo. The events it describes are fictional.</p></body></html>", html.EscapeString(title))
+	})
+	mux.HandleFunc("GET /icon.svg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(icon))
+	})
+	mux.HandleFunc("GET /robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nAllow: /\n"))
+	})
+	return mux
+}
@@ -0,0 +1,44 @@
+package demo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	s, err := Start()
+	require.NoError(t, err)
+	defer s.Close()
+
+	sources := s.Sources()
+	assert.Equal(t, []string{s.URL + "/feeds/defense.xml", s.URL + "/feeds/security.xml", s.URL + "/feeds/tech.xml"}, sources)
+	items := 0
+	for _, source := range sources {
+		feed, err := gofeed.NewParser().ParseURL(source)
+		require.NoError(t, err, source)
+		require.NotEmpty(t, feed.Items)
+		for _, item := range feed.Items {
+			require.NotNil(t, item.PublishedParsed, item.Title)
+			assert.False(t, item.PublishedParsed.After(s.started), item.Title)
+			assert.True(t, item.PublishedParsed.After(s.started.Add(-48*time.Hour)), item.Title)
+			assert.Contains(t, item.Link, s.URL+"/articles/")
+		}
+		assert.Equal(t, s.URL+"/icon.svg", feed.Image.URL)
+		items += len(feed.Items)
+	}
+	assert.Equal(t, 14, items)
+
+	resp, err := http.Get(s.URL + "/articles/acme-vpn-zero-day")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, err = http.Get(s.URL + "/feeds/missing.xml")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envDefault reads an environment variable, falling back to def when it is unset.
+func envDefault(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// envList reads a comma-separated environment variable, dropping empty entries.
+func envList(name string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// envInt reads an integer environment variable, falling back to def when it is
+// unset or invalid.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		log.Printf("Warning: invalid value %q for %s, using %d", raw, name, def)
+		return def
+	}
+	return v
+}
+
+// envDuration reads a duration environment variable such as "90s" or "2h",
+// falling back to def when it is unset or invalid.
+func envDuration(name string, def time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v < 0 {
+		log.Printf("Warning: invalid value %q for %s, using %s", raw, name, def)
+		return def
+	}
+	return v
+}
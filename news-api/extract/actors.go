@@ -0,0 +1,78 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	attackPattern = regexp.MustCompile(`\bT1\d{3}(?:\.\d{3})?\b`)
+	// actorIDPattern matches numbered threat actor designations (APT29, FIN7,
+	// UNC3886, TA505, DEV-0537, Storm-0558).
+	actorIDPattern = regexp.MustCompile(`(?i)\b(?:APT|FIN|UNC|TA)\d{1,4}\b|\b(?:DEV|Storm)-\d{4}\b`)
+)
+
+// knownActors are widely reported threat actor names, in their usual spelling.
+// Ransomware groups are in DefaultRansomwareGroups.
+var knownActors = []string{
+	"Lazarus", "Sandworm", "Fancy Bear", "Cozy Bear", "Midnight Blizzard", "Kimsuky", "Turla",
+	"Volt Typhoon", "Salt Typhoon", "Flax Typhoon", "Scattered Spider", "Lapsus$", "Charming Kitten",
+	"MuddyWater", "Equation Group", "Evil Corp", "Gamaredon", "OilRig",
+}
+
+// AttackIDs returns the unique MITRE ATT&CK technique IDs (e.g. T1566.001)
+// mentioned in text, in order of first appearance.
+func AttackIDs(text string) []string {
+	return unique(attackPattern.FindAllString(text, -1), strings.ToUpper)
+}
+
+// Actors returns the threat actors mentioned in text: numbered designations,
+// upper-cased, and known group names in their usual spelling.
+func Actors(text string) []string {
+	actors := unique(actorIDPattern.FindAllString(text, -1), func(s string) string {
+		if strings.HasPrefix(strings.ToLower(s), "storm-") {
+			return "Storm-" + s[len("storm-"):]
+		}
+		return strings.ToUpper(s)
+	})
+	lower := strings.ToLower(text)
+	for _, name := range knownActors {
+		if containsWord(lower, strings.ToLower(name)) {
+			actors = append(actors, name)
+		}
+	}
+	return actors
+}
+
+// containsWord reports whether phrase occurs in text delimited by non-letters.
+func containsWord(text, phrase string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		if (i == 0 || !isWordByte(text[i-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '_'
+}
+
+func unique(values []string, normalize func(string) string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		v = normalize(v)
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,19 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttackIDs(t *testing.T) {
+	text := "Phishing (T1566.001) led to T1059 execution; T1566.001 again. Not T15660 or XT1059."
+	assert.Equal(t, []string{"T1566.001", "T1059"}, AttackIDs(text))
+	assert.Nil(t, AttackIDs("no techniques here"))
+}
+
+func TestActors(t *testing.T) {
+	text := "apt29 and Storm-0558 overlap with the Lazarus group, unlike FIN7. Lazarusian is not an actor."
+	assert.Equal(t, []string{"APT29", "Storm-0558", "FIN7", "Lazarus"}, Actors(text))
+	assert.Nil(t, Actors("Patch Tuesday fixes 60 bugs"))
+}
@@ -0,0 +1,64 @@
+package extract
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Breach is a data breach announced in a text.
+type Breach struct {
+	// Organization is the breached organization, empty if it is not named.
+	Organization string
+	// Records estimates how many records, accounts or people were exposed,
+	// 0 if no figure is given.
+	Records int64
+}
+
+var (
+	// breachScalePattern matches figures such as "2.5 million records" or
+	// "120,000 customers".
+	breachScalePattern   = regexp.MustCompile(`(?i)\b(\d{1,3}(?:,\d{3})+|\d+(?:\.\d+)?)\s*(thousand|million|billion|[kmb])?\+?\s+(?:(?:customer|user|patient|student|employee|member|voter)s?\s+)?(?:records|customers|users|accounts|patients|people|individuals|students|employees|members|voters|rows|credentials|passwords)\b`)
+	breachKeywordPattern = regexp.MustCompile(`(?i)\b(?:data\s+)?(?:breach(?:ed|es)?|leak(?:ed|s)?|exposed|stolen|exfiltrated|compromised)\b`)
+	breachOrgPatterns    = []*regexp.Regexp{
+		regexp.MustCompile(`(?i:breach|leak|incident|hack)\s+(?:at|of|on)\s+` + victimName),
+		regexp.MustCompile(victimName + `\s+(?i:confirms|confirmed|discloses|disclosed|reports|reported|suffers|suffered|notifies|notified|admits|admitted)\s+(?:a\s+)?(?:major\s+|massive\s+)?(?i:data\s+)?(?i:breach|leak|security\s+incident)`),
+		regexp.MustCompile(victimName + `\s+(?i:data\s+breach|breach|leak)\b`),
+		regexp.MustCompile(victimName + `\s+(?:was\s+|is\s+)?(?i:breached|hacked)\b`),
+	}
+	breachMultipliers = map[string]float64{
+		"thousand": 1e3, "k": 1e3, "million": 1e6, "m": 1e6, "billion": 1e9, "b": 1e9,
+	}
+)
+
+// DataBreach returns the data breach text announces, if any: a breach keyword
+// with either a named organization or a scale figure. The largest figure
+// given is the scale estimate.
+func DataBreach(text string) (Breach, bool) {
+	if !breachKeywordPattern.MatchString(text) {
+		return Breach{}, false
+	}
+	var breach Breach
+	for _, pattern := range breachOrgPatterns {
+		if m := pattern.FindStringSubmatch(text); m != nil {
+			if org := trimVictim(m[1]); org != "" && !breachKeywordPattern.MatchString(org) {
+				breach.Organization = org
+				break
+			}
+		}
+	}
+	for _, m := range breachScalePattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.ParseFloat(strings.ReplaceAll(m[1], ",", ""), 64)
+		if err != nil {
+			continue
+		}
+		if multiplier, ok := breachMultipliers[strings.ToLower(m[2])]; ok {
+			n *= multiplier
+		}
+		if records := int64(math.Min(n, math.MaxInt64)); records > breach.Records {
+			breach.Records = records
+		}
+	}
+	return breach, breach.Organization != "" || breach.Records > 0
+}
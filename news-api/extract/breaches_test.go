@@ -0,0 +1,28 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataBreach(t *testing.T) {
+	for _, tc := range []struct {
+		text   string
+		breach Breach
+	}{
+		{"Ticketmaster confirms data breach affecting 560 million customers", Breach{Organization: "Ticketmaster", Records: 560_000_000}},
+		{"Hackers leak 1.2M user records", Breach{Records: 1_200_000}},
+		{"Officials confirmed breach at Acme Health Systems; 120,000 patients notified", Breach{Organization: "Acme Health Systems", Records: 120_000}},
+		{"The Dell data breach: what we know", Breach{Organization: "Dell"}},
+	} {
+		breach, ok := DataBreach(tc.text)
+		assert.True(t, ok, tc.text)
+		assert.Equal(t, tc.breach, breach, tc.text)
+	}
+
+	_, ok := DataBreach("Chrome update adds 3 million lines of code")
+	assert.False(t, ok)
+	_, ok = DataBreach("Researchers discuss breach notification laws")
+	assert.False(t, ok)
+}
@@ -0,0 +1,90 @@
+// Package extract pulls structured indicators out of article text.
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IOC is an indicator of compromise found in article text.
+type IOC struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// IOC types reported by IOCs.
+const (
+	TypeCVE    = "cve"
+	TypeIPv4   = "ipv4"
+	TypeMD5    = "md5"
+	TypeSHA1   = "sha1"
+	TypeSHA256 = "sha256"
+	TypeDomain = "domain"
+	TypeURL    = "url"
+)
+
+var (
+	cvePattern    = regexp.MustCompile(`(?i)\bCVE-\d{4}-\d{4,7}\b`)
+	ipv4Pattern   = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)(?:\.|\[\.\]|\(\.\))){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+	hashPattern   = regexp.MustCompile(`\b[a-fA-F0-9]{32}\b|\b[a-fA-F0-9]{40}\b|\b[a-fA-F0-9]{64}\b`)
+	defangedURL   = regexp.MustCompile(`(?i)\bhxxps?://[^\s"'<>]+`)
+	defangedHost  = regexp.MustCompile(`(?i)\b[a-z0-9-]+(?:(?:\[\.\]|\(\.\))[a-z0-9-]+)*(?:\[\.\]|\(\.\))[a-z]{2,}\b`)
+	defangedPunct = strings.NewReplacer("[.]", ".", "(.)", ".", "hxxp", "http", "HXXP", "http")
+)
+
+// CVEs returns the unique CVE identifiers mentioned in text, upper-cased and in
+// order of first appearance.
+func CVEs(text string) []string {
+	var cves []string
+	seen := map[string]bool{}
+	for _, m := range cvePattern.FindAllString(text, -1) {
+		m = strings.ToUpper(m)
+		if !seen[m] {
+			seen[m] = true
+			cves = append(cves, m)
+		}
+	}
+	return cves
+}
+
+// IOCs returns the unique indicators found in text. Domains and URLs are only
+// reported when defanged (e.g. evil[.]com, hxxps://...), since plain hostnames
+// in news copy are almost always references rather than indicators.
+func IOCs(text string) []IOC {
+	var iocs []IOC
+	seen := map[IOC]bool{}
+	add := func(t, v string) {
+		ioc := IOC{Type: t, Value: v}
+		if !seen[ioc] {
+			seen[ioc] = true
+			iocs = append(iocs, ioc)
+		}
+	}
+
+	for _, cve := range CVEs(text) {
+		add(TypeCVE, cve)
+	}
+	for _, m := range ipv4Pattern.FindAllString(text, -1) {
+		add(TypeIPv4, defangedPunct.Replace(m))
+	}
+	for _, m := range hashPattern.FindAllString(text, -1) {
+		m = strings.ToLower(m)
+		switch len(m) {
+		case 32:
+			add(TypeMD5, m)
+		case 40:
+			add(TypeSHA1, m)
+		case 64:
+			add(TypeSHA256, m)
+		}
+	}
+	withoutURLs := text
+	for _, m := range defangedURL.FindAllString(text, -1) {
+		add(TypeURL, defangedPunct.Replace(m))
+		withoutURLs = strings.Replace(withoutURLs, m, " ", 1)
+	}
+	for _, m := range defangedHost.FindAllString(withoutURLs, -1) {
+		add(TypeDomain, strings.ToLower(defangedPunct.Replace(m)))
+	}
+	return iocs
+}
@@ -0,0 +1,27 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCVEs(t *testing.T) {
+	text := "Patch CVE-2024-3400 now; cve-2024-3400 and CVE-2023-46805 are chained. CVE-99-1 is not valid."
+	assert.Equal(t, []string{"CVE-2024-3400", "CVE-2023-46805"}, CVEs(text))
+	assert.Empty(t, CVEs("No identifiers here"))
+}
+
+func TestIOCs(t *testing.T) {
+	text := `Beacon to 185.220.101[.]4 and 10.0.0.1, payload d41d8cd98f00b204e9800998ecf8427e,
+	staged at hxxps://evil[.]example/payload and bad-domain[.]net. Read more at example.com.`
+
+	iocs := IOCs(text)
+	assert.Contains(t, iocs, IOC{Type: TypeIPv4, Value: "185.220.101.4"})
+	assert.Contains(t, iocs, IOC{Type: TypeIPv4, Value: "10.0.0.1"})
+	assert.Contains(t, iocs, IOC{Type: TypeMD5, Value: "d41d8cd98f00b204e9800998ecf8427e"})
+	assert.Contains(t, iocs, IOC{Type: TypeURL, Value: "https://evil.example/payload"})
+	assert.Contains(t, iocs, IOC{Type: TypeDomain, Value: "bad-domain.net"})
+	assert.NotContains(t, iocs, IOC{Type: TypeDomain, Value: "example.com"}, "plain hostnames are not indicators")
+	assert.NotContains(t, iocs, IOC{Type: TypeDomain, Value: "evil.example"}, "URL hosts are reported as URLs")
+}
@@ -0,0 +1,127 @@
+package extract
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RansomwareDictionary maps ransomware group names, in their usual spelling,
+// to the aliases they are also reported under.
+type RansomwareDictionary map[string][]string
+
+// DefaultRansomwareGroups are the most active ransomware groups. Groups named
+// after common words are spelled with "ransomware" to avoid false matches.
+var DefaultRansomwareGroups = RansomwareDictionary{
+	"LockBit":               {"LockBit 3.0", "LockBit Black", "LockBitSupp"},
+	"BlackCat":              {"ALPHV", "Noberus"},
+	"Cl0p":                  {"Clop"},
+	"Conti":                 {},
+	"REvil":                 {"Sodinokibi"},
+	"Black Basta":           {},
+	"Akira":                 {},
+	"Rhysida":               {},
+	"Play ransomware":       {"PlayCrypt"},
+	"BlackSuit":             {"Royal ransomware"},
+	"BianLian":              {},
+	"8Base":                 {},
+	"RansomHub":             {},
+	"Qilin":                 {"Agenda ransomware"},
+	"Hunters International": {},
+	"INC Ransom":            {"INC ransomware"},
+	"NoEscape":              {},
+	"Vice Society":          {},
+	"Ragnar Locker":         {"RagnarLocker"},
+}
+
+// Match returns the groups mentioned in text by name or alias, ordered by
+// name. Names are matched case-insensitively as whole words.
+func (d RansomwareDictionary) Match(text string) []string {
+	lower := strings.ToLower(text)
+	var groups []string
+	for group, aliases := range d {
+		for _, name := range append([]string{group}, aliases...) {
+			if containsWord(lower, strings.ToLower(name)) {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// Canonical returns the name of the group known by name or one of its
+// aliases, compared case-insensitively.
+func (d RansomwareDictionary) Canonical(name string) (string, bool) {
+	for group, aliases := range d {
+		for _, candidate := range append([]string{group}, aliases...) {
+			if strings.EqualFold(candidate, name) {
+				return group, true
+			}
+		}
+	}
+	return "", false
+}
+
+// victimName matches a capitalized name of up to five words, such as
+// "Bank of America" or "Johnson Controls".
+const victimName = `([A-Z][\w&'.-]*(?:\s+(?:(?:of|and|&|de)\s+)?[A-Z][\w&'.-]*){0,4})`
+
+// victimPatterns match the phrasings reports use to name ransomware victims.
+var victimPatterns = []*regexp.Regexp{
+	regexp.MustCompile(victimName + `\s+(?:was\s+|is\s+)?(?:hit|struck|crippled|disrupted|targeted|attacked|breached|extorted)\s+by\b`),
+	regexp.MustCompile(`(?i:attack|breach|intrusion)\s+(?:on|at|against)\s+` + victimName),
+	regexp.MustCompile(`(?i:claims?|lists?|adds?)\s+(?:responsibility\s+for\s+)?(?:the\s+)?(?:(?i:attack|hack)\s+on\s+)?` + victimName),
+	regexp.MustCompile(victimName + `\s+(?:confirms|discloses|reports|suffers|says)\s+(?:a\s+)?(?i:ransomware|cyberattack)`),
+}
+
+// victimStopWords are capitalized words that start sentences or name the
+// attack rather than its victim.
+var victimStopWords = toSet(`a an the this that it its new major massive ransomware cyberattack attack hackers
+	gang group report reports data`)
+
+// Victims returns the organizations text names as victims of a
+// ransomware attack, in order of first appearance. Only texts mentioning
+// ransomware or a group of the dictionary are considered.
+func (d RansomwareDictionary) Victims(text string) []string {
+	groups := d.Match(text)
+	if len(groups) == 0 && !strings.Contains(strings.ToLower(text), "ransomware") {
+		return nil
+	}
+	excluded := map[string]bool{}
+	for _, group := range groups {
+		excluded[strings.ToLower(group)] = true
+		for _, alias := range d[group] {
+			excluded[strings.ToLower(alias)] = true
+		}
+	}
+
+	var victims []string
+	seen := map[string]bool{}
+	for _, pattern := range victimPatterns {
+		for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+			victim := trimVictim(match[1])
+			key := strings.ToLower(victim)
+			if victim == "" || excluded[key] || seen[key] {
+				continue
+			}
+			seen[key] = true
+			victims = append(victims, victim)
+		}
+	}
+	return victims
+}
+
+// trimVictim drops leading stop words and trailing punctuation from a
+// matched victim name.
+func trimVictim(name string) string {
+	words := strings.Fields(name)
+	for len(words) > 0 && victimStopWords[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+	for len(words) > 0 && victimStopWords[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	return strings.TrimRight(strings.Join(words, " "), ".,;:'")
+}
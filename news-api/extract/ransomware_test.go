@@ -0,0 +1,23 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRansomwareDictionaryMatch(t *testing.T) {
+	text := "ALPHV affiliates and LockBit 3.0 operators share infrastructure. Clopper is not a group."
+	assert.Equal(t, []string{"BlackCat", "LockBit"}, DefaultRansomwareGroups.Match(text))
+	assert.Nil(t, DefaultRansomwareGroups.Match("Patch Tuesday fixes 60 bugs"))
+}
+
+func TestVictims(t *testing.T) {
+	assert.Equal(t, []string{"Johnson Controls"},
+		DefaultRansomwareGroups.Victims("Johnson Controls hit by Dark Angels ransomware"))
+	assert.Equal(t, []string{"Bank of America"},
+		DefaultRansomwareGroups.Victims("LockBit claims attack on Bank of America."))
+	assert.Equal(t, []string{"Change Healthcare"},
+		DefaultRansomwareGroups.Victims("The BlackCat attack on Change Healthcare disrupted pharmacies"))
+	assert.Nil(t, DefaultRansomwareGroups.Victims("Acme hit by outage"))
+}
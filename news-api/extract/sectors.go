@@ -0,0 +1,50 @@
+package extract
+
+import (
+	"sort"
+	"strings"
+)
+
+// SectorDictionary maps sector names to keywords that place an article in
+// the sector.
+type SectorDictionary map[string][]string
+
+// DefaultSectors are the industries most often singled out by attacks.
+var DefaultSectors = SectorDictionary{
+	"healthcare":     {"healthcare", "health care", "hospital", "hospitals", "patient", "patients", "clinic", "medical", "pharmacy", "pharmaceutical", "NHS", "HIPAA"},
+	"finance":        {"bank", "banks", "banking", "financial", "fintech", "insurance", "insurer", "credit union", "payment", "payments", "brokerage", "SWIFT", "cryptocurrency exchange"},
+	"energy":         {"energy", "utility", "utilities", "power grid", "electric", "pipeline", "oil and gas", "nuclear", "water utility", "ICS", "SCADA"},
+	"government":     {"government", "federal", "ministry", "municipal", "city council", "state agency", "agency", "military", "defense contractor", "embassy", "election", "elections"},
+	"education":      {"university", "universities", "college", "school", "schools", "school district", "student", "students", "education", "campus"},
+	"manufacturing":  {"manufacturing", "manufacturer", "factory", "factories", "industrial", "automaker", "semiconductor"},
+	"retail":         {"retail", "retailer", "e-commerce", "ecommerce", "online store", "point-of-sale", "supermarket"},
+	"telecom":        {"telecom", "telecommunications", "mobile operator", "ISP", "internet service provider"},
+	"transportation": {"airline", "airport", "aviation", "shipping", "logistics", "railway", "port operator", "transportation"},
+}
+
+// Match returns the sectors whose keywords occur in text, ordered by name.
+// Keywords are matched case-insensitively as whole words.
+func (d SectorDictionary) Match(text string) []string {
+	lower := strings.ToLower(text)
+	var sectors []string
+	for sector, keywords := range d {
+		for _, keyword := range keywords {
+			if containsWord(lower, strings.ToLower(keyword)) {
+				sectors = append(sectors, sector)
+				break
+			}
+		}
+	}
+	sort.Strings(sectors)
+	return sectors
+}
+
+// Names returns the sector names, sorted.
+func (d SectorDictionary) Names() []string {
+	names := make([]string, 0, len(d))
+	for sector := range d {
+		names = append(names, sector)
+	}
+	sort.Strings(names)
+	return names
+}
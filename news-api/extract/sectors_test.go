@@ -0,0 +1,14 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSectorDictionaryMatch(t *testing.T) {
+	text := "Ransomware disrupts hospitals and a regional bank; students unaffected. Banksy is not a bank."
+	assert.Equal(t, []string{"education", "finance", "healthcare"}, DefaultSectors.Match(text))
+	assert.Nil(t, DefaultSectors.Match("Chrome update fixes a bug"))
+	assert.Equal(t, []string{"a", "b"}, SectorDictionary{"b": nil, "a": nil}.Names())
+}
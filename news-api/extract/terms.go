@@ -0,0 +1,74 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var wordPattern = regexp.MustCompile(`\p{L}[\p{L}\p{N}'-]*[\p{L}\p{N}]|\p{L}`)
+
+// stopWords are common English words (plus newsroom filler) that carry no topic.
+var stopWords = toSet(`a about above after again against all also am an and any are as at be because been
+before being below between both but by can could did do does doing down during each few for from further
+had has have having he her here hers herself him himself his how i if in into is it its itself just me
+more most my myself new news no nor not now of off on once only or other our ours ourselves out over own
+said same says she should so some such than that the their theirs them themselves then there these they
+this those through to too under until up very was we were what when where which while who whom why will
+with would you your yours yourself yourselves one two three may might must us via get gets got make
+makes made like week weeks year years day days today yesterday according report reports reported read
+more continue post posted article`)
+
+func toSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// Terms splits text into lower-cased words, dropping stop words and words
+// shorter than three characters. CVE identifiers and hyphenated words such as
+// "zero-day" are kept whole.
+func Terms(text string) []string {
+	var terms []string
+	for _, w := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		w = strings.TrimSuffix(strings.TrimSuffix(w, "'s"), "’s")
+		if len([]rune(w)) < 3 || stopWords[w] {
+			continue
+		}
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// Stem reduces an English word to a crude stem by stripping common plural and
+// verb endings, so that "attacks", "attacked" and "attacking" count together.
+// It is deliberately conservative; stems are used as grouping keys, not shown.
+func Stem(word string) string {
+	if strings.ContainsAny(word, "0123456789") {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return undouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return undouble(word[:len(word)-2])
+	case strings.HasSuffix(word, "s") && len(word) > 3 && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// undouble removes a doubled final consonant left by stripping a suffix, as in
+// "patched" -> "patch" but "dropped" -> "drop".
+func undouble(stem string) string {
+	n := len(stem)
+	if n >= 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("aeiouls", rune(stem[n-1])) {
+		return stem[:n-1]
+	}
+	return stem
+}
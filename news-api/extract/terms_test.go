@@ -0,0 +1,27 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerms(t *testing.T) {
+	terms := Terms("The zero-day in Ivanti's VPN is exploited; CVE-2024-21887 was patched by a vendor.")
+	assert.Equal(t, []string{"zero-day", "ivanti", "vpn", "exploited", "cve-2024-21887", "patched", "vendor"}, terms)
+}
+
+func TestStem(t *testing.T) {
+	for word, stem := range map[string]string{
+		"attacks":         "attack",
+		"attacked":        "attack",
+		"attacking":       "attack",
+		"vulnerabilities": "vulnerability",
+		"dropped":         "drop",
+		"access":          "access",
+		"virus":           "virus",
+		"cve-2024-1234s":  "cve-2024-1234s",
+	} {
+		assert.Equal(t, stem, Stem(word), word)
+	}
+}
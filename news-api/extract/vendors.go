@@ -0,0 +1,62 @@
+package extract
+
+import (
+	"sort"
+	"strings"
+)
+
+// VendorDictionary maps vendor names to the names of their products. A vendor
+// is mentioned when its own name or one of its products occurs in a text.
+type VendorDictionary map[string][]string
+
+// DefaultVendors are the vendors most often named in security reporting.
+var DefaultVendors = VendorDictionary{
+	"Microsoft":          {"Windows", "Exchange Server", "SharePoint", "Outlook", "Azure", "Entra ID", "Active Directory", "Microsoft 365", "Office 365", "Edge"},
+	"Cisco":              {"IOS XE", "ASA", "Firepower", "Webex", "Meraki"},
+	"Ivanti":             {"Connect Secure", "Pulse Secure", "Policy Secure", "EPMM", "MobileIron"},
+	"VMware":             {"ESXi", "vCenter", "vSphere", "Workspace ONE", "Aria"},
+	"Fortinet":           {"FortiGate", "FortiOS", "FortiManager", "FortiWeb", "FortiClient"},
+	"Palo Alto Networks": {"PAN-OS", "GlobalProtect", "Cortex XDR", "Prisma"},
+	"Citrix":             {"NetScaler", "Citrix ADC", "Citrix Gateway", "XenServer"},
+	"Apple":              {"iOS", "iPadOS", "macOS", "Safari", "WebKit"},
+	"Google":             {"Chrome", "Android", "Chromium"},
+	"Oracle":             {"WebLogic", "E-Business Suite", "MySQL"},
+	"SAP":                {"NetWeaver", "SAP S/4HANA"},
+	"Atlassian":          {"Confluence", "Jira", "Bitbucket"},
+	"Juniper":            {"Junos", "SRX"},
+	"SonicWall":          {"SonicOS", "SMA 100"},
+	"Progress":           {"MOVEit", "WS_FTP", "Telerik"},
+	"Adobe":              {"Acrobat", "ColdFusion", "Magento"},
+	"Apache":             {"Log4j", "Struts", "Tomcat", "ActiveMQ"},
+	"Linux":              {"Linux kernel"},
+	"Zyxel":              {},
+	"F5":                 {"BIG-IP"},
+	"Check Point":        {"Quantum Security Gateway"},
+	"CrowdStrike":        {"Falcon"},
+}
+
+// VendorMention is a vendor mentioned in a text with the products named.
+type VendorMention struct {
+	Vendor   string
+	Products []string
+}
+
+// Match returns the vendors mentioned in text, ordered by name. Names are
+// matched case-insensitively as whole words.
+func (d VendorDictionary) Match(text string) []VendorMention {
+	lower := strings.ToLower(text)
+	var mentions []VendorMention
+	for vendor, products := range d {
+		mention := VendorMention{Vendor: vendor}
+		for _, product := range products {
+			if containsWord(lower, strings.ToLower(product)) {
+				mention.Products = append(mention.Products, product)
+			}
+		}
+		if len(mention.Products) > 0 || containsWord(lower, strings.ToLower(vendor)) {
+			mentions = append(mentions, mention)
+		}
+	}
+	sort.Slice(mentions, func(i, j int) bool { return mentions[i].Vendor < mentions[j].Vendor })
+	return mentions
+}
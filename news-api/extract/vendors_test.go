@@ -0,0 +1,20 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVendorDictionaryMatch(t *testing.T) {
+	text := "Ivanti Connect Secure and FortiGate flaws exploited; Windows update pending. Zyxeloid is not a vendor."
+	assert.Equal(t, []VendorMention{
+		{Vendor: "Fortinet", Products: []string{"FortiGate"}},
+		{Vendor: "Ivanti", Products: []string{"Connect Secure"}},
+		{Vendor: "Microsoft", Products: []string{"Windows"}},
+	}, DefaultVendors.Match(text))
+
+	custom := VendorDictionary{"Acme": {"RoadRunner"}}
+	assert.Equal(t, []VendorMention{{Vendor: "Acme"}}, custom.Match("ACME patches a bug"))
+	assert.Empty(t, custom.Match("Nothing here"))
+}
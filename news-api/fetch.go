@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"news-api/db"
+)
+
+// runFetch implements the fetch command: `news-api fetch --once` runs one
+// caching cycle and exits, and `--dry-run` fetches, filters and scores the
+// sources without writing anything, reporting what would be inserted.
+// `--replay <fixture>` processes a recorded feed response instead of fetching
+// the sources. It returns the exit status: 2 for usage errors, 1 if a source
+// failed.
+func runFetch(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	once := flags.Bool("once", false, "run one caching cycle and exit")
+	dryRun := flags.Bool("dry-run", false, "fetch and process the sources without writing to the database")
+	sources := flags.String("sources", "", "comma-separated feed URLs to fetch instead of the configured sources (dry runs only)")
+	replay := flags.String("replay", "", "name of a recorded fixture in FEED_FIXTURE_DIR to process instead of fetching the sources")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if !*once {
+		fmt.Fprintln(stderr, "fetch requires --once; the server runs the caching job continuously")
+		return 2
+	}
+	if *sources != "" && !*dryRun {
+		// A partial cycle would forget the health of the other sources.
+		fmt.Fprintln(stderr, "--sources requires --dry-run")
+		return 2
+	}
+	if *sources != "" && *replay != "" {
+		fmt.Fprintln(stderr, "--sources and --replay cannot be combined")
+		return 2
+	}
+
+	if err := openFetchDB(envDefault("DB_PATH", "./news.db"), *dryRun); err != nil {
+		fmt.Fprintf(stderr, "Failed to open database: %v\n", err)
+		return 1
+	}
+	configureIngestion()
+	feeds := RssSources
+	if *sources != "" {
+		feeds = nil
+		for _, source := range strings.Split(*sources, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				feeds = append(feeds, source)
+			}
+		}
+	}
+
+	var report db.IngestReport
+	if *replay != "" {
+		var err error
+		if report, err = db.ReplayFeedFixture(*replay, *dryRun); err != nil {
+			fmt.Fprintf(stderr, "Failed to replay %s: %v\n", *replay, err)
+			return 1
+		}
+	} else {
+		report = db.FetchOnce(feeds, *dryRun)
+	}
+	printIngestReport(stdout, report, *dryRun)
+	for _, source := range report.Sources {
+		if source.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// openFetchDB opens the database for the fetch command. Dry runs open it
+// read-only, so that nothing can be written, or use an empty in-memory
+// database if there is none yet, where every article is new.
+func openFetchDB(path string, dryRun bool) error {
+	if !dryRun {
+		return db.InitDB(path)
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return db.InitDB(":memory:")
+	}
+	return db.InitReadOnlyDB(path)
+}
+
+// printIngestReport writes the per-source counts and the rank distribution
+// of the new articles as text.
+func printIngestReport(w io.Writer, report db.IngestReport, dryRun bool) {
+	newLabel := "NEW"
+	if dryRun {
+		newLabel = "WOULD INSERT"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SOURCE\tITEMS\tSEEN\tNON-ENGLISH\tFILTERED\t%s\tDUPLICATES\n", newLabel)
+	for _, s := range report.Sources {
+		switch {
+		case s.Skipped:
+			fmt.Fprintf(tw, "%s\tskipped\n", s.URL)
+		case s.Error != "":
+			fmt.Fprintf(tw, "%s\terror: %s\n", s.URL, s.Error)
+		default:
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n", s.URL, s.Items, s.Seen, s.NonEnglish, s.Filtered, s.New, s.Duplicates)
+		}
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\n%d new, %d duplicate articles.\n", report.New, report.Duplicates)
+	if report.New == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Severity: %d high, %d medium, %d low.\n", report.Severities["high"], report.Severities["medium"], report.Severities["low"])
+	fmt.Fprintln(w, "Rank distribution:")
+	for _, rank := range report.RankBuckets() {
+		fmt.Fprintf(w, "  %4d  %d\n", rank, report.Ranks[rank])
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/db"
+)
+
+func TestRunFetchDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		date := time.Now().UTC().Format(time.RFC1123Z)
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Advisories</title>
+<item><title>Ransomware gang exploits zero-day in VPN appliances</title><link>https://example.com/vpn</link><description>Attackers exploit a critical vulnerability to deploy ransomware on corporate networks.</description><pubDate>%[1]s</pubDate></item>
+<item><title>Ransomware gang exploits zero-day in VPN appliances</title><link>https://example.com/vpn</link><description>Attackers exploit a critical vulnerability to deploy ransomware on corporate networks.</description><pubDate>%[1]s</pubDate></item>
+<item><title>Company announces quarterly earnings results today</title><link>https://example.com/earnings</link><description>The company reported revenue growth in its quarterly earnings call with analysts.</description><pubDate>%[1]s</pubDate></item>
+</channel></rss>`, date)
+	}))
+	defer server.Close()
+	t.Setenv("DB_PATH", filepath.Join(t.TempDir(), "missing.db"))
+	t.Setenv("PREVIEW_IMAGE_FALLBACK", "false")
+	t.Setenv("INGEST_EXCLUDE_KEYWORDS", "earnings")
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, runFetch([]string{"--dry-run"}, &stdout, &stderr), "--once is required")
+	assert.Equal(t, 2, runFetch([]string{"--once", "--sources", server.URL}, &stdout, &stderr), "--sources needs --dry-run")
+
+	stdout.Reset()
+	require.Equal(t, 0, runFetch([]string{"--once", "--dry-run", "--sources", server.URL}, &stdout, &stderr), stderr.String())
+	out := stdout.String()
+	assert.Contains(t, out, "WOULD INSERT")
+	assert.Contains(t, out, "1 new, 1 duplicate articles.")
+	assert.Contains(t, out, "Rank distribution:")
+	count, err := db.GetArticleCount()
+	require.NoError(t, err)
+	assert.Zero(t, count, "nothing is written")
+}
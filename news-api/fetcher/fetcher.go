@@ -0,0 +1,487 @@
+// Package fetcher is the outbound HTTP client shared by feed fetching and
+// article enrichment. It reuses connections, revalidates cached responses,
+// limits concurrent requests per host, records per-host metrics and sets the
+// deployment's user agent. Clients for article pages and publisher sites also
+// honor robots.txt and per-host delays.
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the release reported in the default user agent.
+const Version = "1.0"
+
+// DefaultUserAgent identifies the service honestly and is sent when
+// Config.UserAgent is empty.
+const DefaultUserAgent = "Threatfeed/" + Version + " (+https://github.com/code-grey/Threatfeed)"
+
+// Config controls a Fetcher.
+type Config struct {
+	UserAgent string
+	// HostUserAgents overrides UserAgent for publishers that reject it, by
+	// host. A leading dot also matches subdomains (".example.com").
+	HostUserAgents map[string]string
+	// MaxPerHost limits the concurrent requests to one host. Zero means no limit.
+	MaxPerHost int
+	// CacheBytes bounds the size of cached response bodies. Zero disables caching.
+	CacheBytes int64
+
+	// The settings below only apply to polite clients.
+
+	// RespectRobots skips URLs that the site's robots.txt disallows for us.
+	RespectRobots bool
+	// HostDelay is the minimum time between two requests to the same host.
+	HostDelay time.Duration
+	// MaxCrawlDelay caps the Crawl-delay a robots.txt may ask for. A longer
+	// crawl delay is honored up to this value.
+	MaxCrawlDelay time.Duration
+}
+
+// DefaultConfig is the configuration of the shared fetcher until Configure is called.
+var DefaultConfig = Config{
+	UserAgent:     DefaultUserAgent,
+	MaxPerHost:    4,
+	CacheBytes:    32 << 20,
+	RespectRobots: true,
+	HostDelay:     time.Second,
+	MaxCrawlDelay: 30 * time.Second,
+}
+
+// cacheMaxBody is the largest response body that is cached.
+const cacheMaxBody = 2 << 20
+
+// ErrDisallowedByRobots is returned for requests that robots.txt disallows.
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// ErrHostBusy is returned when a request would have to wait for its host past its deadline.
+var ErrHostBusy = errors.New("host rate limit exceeds request deadline")
+
+// Fetcher sends outbound requests. Its clients share one connection pool,
+// cache and set of per-host limits.
+type Fetcher struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	config Config
+	// slots holds the concurrency semaphore of each host.
+	slots map[string]chan struct{}
+	// next is the earliest time of the next polite request to each host.
+	next   map[string]time.Time
+	robots map[string]*robotsEntry
+	cache  map[string]*cacheEntry
+	cached int64
+	stats  map[string]*HostStats
+}
+
+// New returns a Fetcher sending requests through base, or a new pooled
+// transport if base is nil.
+func New(cfg Config, base http.RoundTripper) *Fetcher {
+	if base == nil {
+		base = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		}
+	}
+	return &Fetcher{
+		base:   base,
+		config: cfg,
+		slots:  map[string]chan struct{}{},
+		next:   map[string]time.Time{},
+		robots: map[string]*robotsEntry{},
+		cache:  map[string]*cacheEntry{},
+		stats:  map[string]*HostStats{},
+	}
+}
+
+// Configure replaces the configuration. Requests already waiting keep the
+// limits they started with.
+func (f *Fetcher) Configure(cfg Config) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = cfg
+	f.slots = map[string]chan struct{}{}
+	if cfg.CacheBytes <= 0 {
+		f.cache, f.cached = map[string]*cacheEntry{}, 0
+	}
+}
+
+func (f *Fetcher) currentConfig() Config {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.config
+}
+
+// Client returns a client for feeds and APIs.
+func (f *Fetcher) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: &transport{fetcher: f}, Timeout: timeout}
+}
+
+// PoliteClient returns a client for article pages and publisher sites, which
+// also honors robots.txt and per-host delays, including on redirects. The
+// timeout includes any time spent waiting for the host's delay.
+func (f *Fetcher) PoliteClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: &transport{fetcher: f, polite: true}, Timeout: timeout}
+}
+
+type transport struct {
+	fetcher *Fetcher
+	polite  bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.fetcher.roundTrip(req, t.polite)
+}
+
+func (f *Fetcher) roundTrip(req *http.Request, polite bool) (*http.Response, error) {
+	cfg := f.currentConfig()
+	host := req.URL.Host
+	start := time.Now()
+
+	if polite {
+		delay := cfg.HostDelay
+		if cfg.RespectRobots {
+			rules, err := f.robotsRules(req.Context(), req.URL, cfg.userAgent(req.URL.Hostname()))
+			if err != nil {
+				return nil, err
+			}
+			if !rules.allowed(req.URL) {
+				f.record(host, func(s *HostStats) { s.RobotsBlocked++ })
+				return nil, ErrDisallowedByRobots
+			}
+			delay = max(delay, min(rules.crawlDelay, cfg.MaxCrawlDelay))
+		}
+		if err := f.wait(req.Context(), host, delay); err != nil {
+			return nil, err
+		}
+	}
+
+	release, err := f.acquire(req.Context(), host, cfg.MaxPerHost)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", cfg.userAgent(req.URL.Hostname()))
+	entry := f.revalidate(req, cfg)
+
+	resp, err := f.base.RoundTrip(req)
+	elapsed := time.Since(start)
+	if err != nil {
+		release()
+		f.record(host, func(s *HostStats) { s.Requests++; s.Errors++; s.totalTime += elapsed })
+		return nil, err
+	}
+
+	cacheHit := entry != nil && resp.StatusCode == http.StatusNotModified
+	f.record(host, func(s *HostStats) {
+		s.Requests++
+		s.totalTime += elapsed
+		if cacheHit {
+			s.CacheHits++
+		}
+		if resp.StatusCode >= 500 {
+			s.Errors++
+		}
+		class := string(rune('0'+resp.StatusCode/100)) + "xx"
+		if s.Statuses == nil {
+			s.Statuses = map[string]int{}
+		}
+		s.Statuses[class]++
+	})
+
+	if cacheHit {
+		resp.Body.Close()
+		release()
+		return entry.response(req), nil
+	}
+	if req.Method == http.MethodGet && resp.StatusCode == http.StatusOK && cfg.CacheBytes > 0 && cacheable(resp) {
+		resp.Body = f.store(req.URL.String(), resp, cfg.CacheBytes)
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, release: release, count: func(n int) {
+		f.record(host, func(s *HostStats) { s.Bytes += int64(n) })
+	}}
+	return resp, nil
+}
+
+// userAgent returns the user agent to send to a host. The most specific
+// matching override wins.
+func (cfg Config) userAgent(host string) string {
+	host = strings.ToLower(host)
+	override, longest := "", 0
+	for pattern, ua := range cfg.HostUserAgents {
+		pattern = strings.ToLower(pattern)
+		if len(pattern) > longest && (host == strings.TrimPrefix(pattern, ".") || (strings.HasPrefix(pattern, ".") && strings.HasSuffix(host, pattern))) {
+			override, longest = ua, len(pattern)
+		}
+	}
+	if override != "" {
+		return override
+	}
+	if cfg.UserAgent != "" {
+		return cfg.UserAgent
+	}
+	return DefaultUserAgent
+}
+
+// acquire takes one of a host's concurrency slots and returns its release function.
+func (f *Fetcher) acquire(ctx context.Context, host string, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	f.mu.Lock()
+	slots := f.slots[host]
+	if slots == nil {
+		slots = make(chan struct{}, limit)
+		f.slots[host] = slots
+	}
+	f.mu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-slots }) }, nil
+}
+
+// wait reserves the next request slot for a host and sleeps until it. A
+// request that cannot get a slot before its deadline fails without taking one.
+func (f *Fetcher) wait(ctx context.Context, host string, delay time.Duration) error {
+	f.mu.Lock()
+	now := time.Now()
+	slot := f.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	if deadline, ok := ctx.Deadline(); ok && slot.After(deadline) {
+		f.mu.Unlock()
+		return ErrHostBusy
+	}
+	f.next[host] = slot.Add(delay)
+	f.mu.Unlock()
+
+	if d := time.Until(slot); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// countingBody counts the bytes read from a response and releases the host's
+// concurrency slot when closed.
+type countingBody struct {
+	io.ReadCloser
+	release func()
+	count   func(int)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.count(n)
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// cacheEntry is a response kept for revalidation with its validators.
+type cacheEntry struct {
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// cacheable reports whether a response carries validators and may be stored.
+func cacheable(resp *http.Response) bool {
+	if strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") {
+		return false
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// revalidate adds the validators of a cached response to a GET request that
+// has none, and returns the cache entry used.
+func (f *Fetcher) revalidate(req *http.Request, cfg Config) *cacheEntry {
+	if req.Method != http.MethodGet || cfg.CacheBytes <= 0 || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return nil
+	}
+	f.mu.Lock()
+	entry := f.cache[req.URL.String()]
+	f.mu.Unlock()
+	if entry == nil {
+		return nil
+	}
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+	return entry
+}
+
+// response rebuilds the cached response for a request that was answered 304.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// store reads up to cacheMaxBody of a response into the cache and returns a
+// body that replays what was read followed by the rest. Larger responses are
+// not cached.
+func (f *Fetcher) store(key string, resp *http.Response, limit int64) io.ReadCloser {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, cacheMaxBody+1))
+	rest := io.MultiReader(bytes.NewReader(body), resp.Body)
+	if err != nil || len(body) > cacheMaxBody {
+		return readCloser{rest, resp.Body}
+	}
+
+	entry := &cacheEntry{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		header:       resp.Header.Clone(),
+		body:         body,
+	}
+	f.mu.Lock()
+	if old := f.cache[key]; old != nil {
+		f.cached -= int64(len(old.body))
+		delete(f.cache, key)
+	}
+	for k, e := range f.cache {
+		if f.cached+int64(len(body)) <= limit {
+			break
+		}
+		f.cached -= int64(len(e.body))
+		delete(f.cache, k)
+	}
+	if f.cached+int64(len(body)) <= limit {
+		f.cache[key] = entry
+		f.cached += int64(len(body))
+	}
+	f.mu.Unlock()
+	return readCloser{rest, resp.Body}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// HostStats are the request metrics of one host.
+type HostStats struct {
+	Host     string `json:"host"`
+	Requests int    `json:"requests"`
+	// Errors counts failed requests and 5xx responses.
+	Errors int `json:"errors"`
+	// CacheHits counts requests answered from the cache after a 304.
+	CacheHits     int            `json:"cacheHits"`
+	RobotsBlocked int            `json:"robotsBlocked"`
+	Bytes         int64          `json:"bytes"`
+	Statuses      map[string]int `json:"statuses,omitempty"`
+	AvgMillis     int64          `json:"avgMillis"`
+
+	totalTime time.Duration
+}
+
+func (f *Fetcher) record(host string, update func(*HostStats)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.stats[host]
+	if s == nil {
+		s = &HostStats{Host: host}
+		f.stats[host] = s
+	}
+	update(s)
+}
+
+// Stats returns the metrics of every host contacted, busiest first.
+func (f *Fetcher) Stats() []HostStats {
+	f.mu.Lock()
+	stats := make([]HostStats, 0, len(f.stats))
+	for _, s := range f.stats {
+		copied := *s
+		copied.Statuses = map[string]int{}
+		for k, v := range s.Statuses {
+			copied.Statuses[k] = v
+		}
+		if s.Requests > 0 {
+			copied.AvgMillis = (s.totalTime / time.Duration(s.Requests)).Milliseconds()
+		}
+		stats = append(stats, copied)
+	}
+	f.mu.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Requests != stats[j].Requests {
+			return stats[i].Requests > stats[j].Requests
+		}
+		return stats[i].Host < stats[j].Host
+	})
+	return stats
+}
+
+// Default is the fetcher shared by the whole service.
+var Default = New(DefaultConfig, nil)
+
+// Configure replaces the configuration of the shared fetcher.
+func Configure(cfg Config) { Default.Configure(cfg) }
+
+// Client returns a client of the shared fetcher for feeds and APIs.
+func Client(timeout time.Duration) *http.Client { return Default.Client(timeout) }
+
+// PoliteClient returns a client of the shared fetcher for article pages and publisher sites.
+func PoliteClient(timeout time.Duration) *http.Client { return Default.PoliteClient(timeout) }
+
+// UserAgent returns the user agent the shared fetcher sends to a host, for
+// requests that cannot go through its clients.
+func UserAgent(host string) string { return Default.currentConfig().userAgent(host) }
+
+// Stats returns the per-host metrics of the shared fetcher.
+func Stats() []HostStats { return Default.Stats() }
+
+// CacheSize returns the number and total size of cached responses.
+func (f *Fetcher) CacheSize() (entries int, bytes int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.cache), f.cached
+}
+
+// CacheSize returns the number and total size of the responses cached by the default fetcher.
+func CacheSize() (int, int64) { return Default.CacheSize() }
@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		switch r.URL.Path {
+		case "/feed":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte("<rss>v1</rss>"))
+		case "/no-store":
+			w.Header().Set("ETag", `"x"`)
+			w.Header().Set("Cache-Control", "no-store")
+			w.Write([]byte("fresh"))
+		}
+	}))
+	defer server.Close()
+
+	f := New(Config{UserAgent: "TestAgent/1.0", CacheBytes: 1 << 20}, nil)
+	client := f.Client(time.Second)
+	for i := 0; i < 2; i++ {
+		status, body := get(t, client, server.URL+"/feed")
+		assert.Equal(t, http.StatusOK, status, "a 304 is answered from the cache")
+		assert.Equal(t, "<rss>v1</rss>", body)
+	}
+	get(t, client, server.URL+"/no-store")
+	get(t, client, server.URL+"/no-store")
+	assert.Equal(t, []string{"TestAgent/1.0", "TestAgent/1.0", "TestAgent/1.0", "TestAgent/1.0"}, userAgents)
+
+	stats := f.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, strings.TrimPrefix(server.URL, "http://"), stats[0].Host)
+	assert.Equal(t, 4, stats[0].Requests)
+	assert.Equal(t, 1, stats[0].CacheHits)
+	assert.Equal(t, map[string]int{"2xx": 3, "3xx": 1}, stats[0].Statuses)
+	assert.Equal(t, int64(len("<rss>v1</rss>")+len("fresh")*2), stats[0].Bytes, "cache hits transfer no body")
+
+	// Requests with their own validators get the server's answer.
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/feed", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestMaxPerHost(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}))
+	defer server.Close()
+
+	client := New(Config{MaxPerHost: 2}, nil).Client(5 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, server.URL)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+}
+
+func TestUserAgent(t *testing.T) {
+	cfg := Config{HostUserAgents: map[string]string{
+		".example.com":      "Mozilla/5.0 (compatible; Threatfeed)",
+		"news.example.com":  "NewsBot/2.0",
+		"feeds.example.org": "FeedReader/1.0",
+	}}
+	assert.Equal(t, DefaultUserAgent, cfg.userAgent("other.net"))
+	assert.Equal(t, "Mozilla/5.0 (compatible; Threatfeed)", cfg.userAgent("www.EXAMPLE.com"))
+	assert.Equal(t, "Mozilla/5.0 (compatible; Threatfeed)", cfg.userAgent("example.com"))
+	assert.Equal(t, "NewsBot/2.0", cfg.userAgent("news.example.com"), "the most specific override wins")
+	assert.Equal(t, DefaultUserAgent, cfg.userAgent("example.org"))
+
+	cfg.UserAgent = "Acme-Threatfeed/1.0 (security@acme.example)"
+	assert.Equal(t, cfg.UserAgent, cfg.userAgent("other.net"))
+	assert.Equal(t, "FeedReader/1.0", cfg.userAgent("feeds.example.org"))
+}
@@ -0,0 +1,222 @@
+package fetcher
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsAgent is the product token matched against robots.txt user-agent
+// lines. It stays the same when the user agent is overridden.
+const robotsAgent = "threatfeed"
+
+const (
+	// robotsTTL is how long a fetched robots.txt is used.
+	robotsTTL = 24 * time.Hour
+	// robotsErrorTTL is how long a site whose robots.txt could not be fetched
+	// is considered to disallow everything.
+	robotsErrorTTL = time.Hour
+	robotsTimeout  = 10 * time.Second
+	// robotsMaxBytes is the robots.txt size crawlers must at least parse (RFC 9309).
+	robotsMaxBytes = 500 << 10
+)
+
+type robotsEntry struct {
+	ready   chan struct{}
+	rules   robotsRules
+	expires time.Time
+}
+
+// robotsRules returns the robots.txt rules of a URL's site, fetching them if
+// they are not cached. Concurrent requests to the same site share one fetch.
+func (f *Fetcher) robotsRules(ctx context.Context, u *url.URL, ua string) (robotsRules, error) {
+	site := u.Scheme + "://" + u.Host
+	f.mu.Lock()
+	entry := f.robots[site]
+	if entry == nil || (isClosed(entry.ready) && time.Now().After(entry.expires)) {
+		entry = &robotsEntry{ready: make(chan struct{})}
+		f.robots[site] = entry
+		f.mu.Unlock()
+		entry.rules, entry.expires = f.fetchRobots(site, ua)
+		close(entry.ready)
+		return entry.rules, nil
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.rules, nil
+	case <-ctx.Done():
+		return robotsRules{}, ctx.Err()
+	}
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// fetchRobots fetches and parses a site's robots.txt following RFC 9309: a
+// missing file (4xx) allows everything, an unreachable one (5xx, network
+// errors) disallows everything until it can be fetched again.
+func (f *Fetcher) fetchRobots(site, ua string) (robotsRules, time.Time) {
+	client := &http.Client{Transport: f.base, Timeout: robotsTimeout}
+	req, err := http.NewRequest(http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return robotsRules{disallowAll: true}, time.Now().Add(robotsErrorTTL)
+	}
+	req.Header.Set("User-Agent", ua)
+	resp, err := client.Do(req)
+	if err != nil {
+		return robotsRules{disallowAll: true}, time.Now().Add(robotsErrorTTL)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500:
+		return robotsRules{disallowAll: true}, time.Now().Add(robotsErrorTTL)
+	case resp.StatusCode >= 400:
+		return robotsRules{}, time.Now().Add(robotsTTL)
+	case resp.StatusCode != http.StatusOK:
+		return robotsRules{disallowAll: true}, time.Now().Add(robotsErrorTTL)
+	}
+	return parseRobots(io.LimitReader(resp.Body, robotsMaxBytes), robotsAgent), time.Now().Add(robotsTTL)
+}
+
+// robotsRules are the robots.txt rules that apply to us on one site.
+type robotsRules struct {
+	rules       []robotsRule
+	crawlDelay  time.Duration
+	disallowAll bool
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// allowed reports whether a URL may be fetched: the longest matching rule
+// decides, with allow winning ties. /robots.txt is always allowed.
+func (r robotsRules) allowed(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if r.disallowAll {
+		return false
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if rule.pattern.MatchString(path) && (rule.length > longest || (rule.length == longest && rule.allow)) {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// parseRobots reads the groups of a robots.txt that apply to agent, falling
+// back to the "*" groups if none names it.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+	var groups []*group
+	var current *group
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &group{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value == "" {
+				continue
+			}
+			if pattern, err := robotsPattern(value); err == nil {
+				current.rules = append(current.rules, robotsRule{allow: key == "allow", length: len(value), pattern: pattern})
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	var rules robotsRules
+	for _, name := range []string{agent, "*"} {
+		matched := false
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if a == name {
+					matched = true
+					rules.rules = append(rules.rules, g.rules...)
+					rules.crawlDelay = max(rules.crawlDelay, g.crawlDelay)
+					break
+				}
+			}
+		}
+		if matched {
+			break
+		}
+	}
+	return rules
+}
+
+// robotsPattern compiles a robots.txt path pattern, where * matches any
+// characters and a trailing $ anchors the end of the path.
+func robotsPattern(value string) (*regexp.Regexp, error) {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+	parts := strings.Split(value, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.Compile(expr)
+}
@@ -0,0 +1,117 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := `# comment
+User-agent: *
+Disallow: /
+
+User-agent: Threatfeed
+User-agent: otherbot
+Disallow: /private/
+Allow: /private/press/
+Disallow: /*.pdf$
+Disallow: /search?
+Crawl-delay: 5
+`
+	rules := parseRobots(strings.NewReader(robots), robotsAgent)
+	assert.Equal(t, 5*time.Second, rules.crawlDelay)
+	for path, want := range map[string]bool{
+		"/news/story":             true,
+		"/private/notes":          false,
+		"/private/press/release":  true,
+		"/files/report.pdf":       false,
+		"/files/report.pdf?x=1":   true,
+		"/search?q=ransomware":    false,
+		"/robots.txt":             true,
+		"/news/story?utm=feed#id": true,
+	} {
+		u, _ := url.Parse("https://example.com" + path)
+		assert.Equal(t, want, rules.allowed(u), path)
+	}
+
+	// Other agents fall back to the wildcard group.
+	rules = parseRobots(strings.NewReader(robots), "somebot")
+	u, _ := url.Parse("https://example.com/news/story")
+	assert.False(t, rules.allowed(u))
+
+	// A group naming us without rules allows everything.
+	rules = parseRobots(strings.NewReader("User-agent: *\nDisallow: /\n\nUser-agent: threatfeed\nDisallow:\n"), robotsAgent)
+	assert.True(t, rules.allowed(u))
+}
+
+func TestPoliteClient(t *testing.T) {
+	f := New(Config{RespectRobots: true, MaxCrawlDelay: 150 * time.Millisecond}, nil)
+
+	var robotsFetches, pageFetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			atomic.AddInt32(&robotsFetches, 1)
+			w.Write([]byte("User-agent: *\nDisallow: /private\nCrawl-delay: 10\n"))
+			return
+		}
+		atomic.AddInt32(&pageFetches, 1)
+		if r.URL.Path == "/moved" {
+			http.Redirect(w, r, "/private/page", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	client := f.PoliteClient(5 * time.Second)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL + "/page")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond, "the crawl delay is honored up to MaxCrawlDelay")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&robotsFetches), "robots.txt is cached")
+
+	_, err := client.Get(server.URL + "/private/page")
+	assert.True(t, errors.Is(err, ErrDisallowedByRobots))
+	_, err = client.Get(server.URL + "/moved")
+	assert.True(t, errors.Is(err, ErrDisallowedByRobots), "redirects are checked too")
+	assert.Equal(t, int32(4), atomic.LoadInt32(&pageFetches))
+
+	// A request that cannot get a slot before its deadline fails right away.
+	impatient := New(Config{RespectRobots: true, MaxCrawlDelay: time.Minute}, nil).PoliteClient(100 * time.Millisecond)
+	resp, err := impatient.Get(server.URL + "/page")
+	require.NoError(t, err)
+	resp.Body.Close()
+	_, err = impatient.Get(server.URL + "/page")
+	assert.True(t, errors.Is(err, ErrHostBusy))
+}
+
+func TestRobotsUnavailable(t *testing.T) {
+	cfg := Config{RespectRobots: true}
+
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(status)
+		}
+	}))
+	defer server.Close()
+
+	_, err := New(cfg, nil).PoliteClient(time.Second).Get(server.URL + "/page")
+	assert.True(t, errors.Is(err, ErrDisallowedByRobots), "an unreachable robots.txt disallows everything")
+
+	status = http.StatusNotFound
+	resp, err := New(cfg, nil).PoliteClient(time.Second).Get(server.URL + "/page")
+	require.NoError(t, err, "a missing robots.txt allows everything")
+	resp.Body.Close()
+}
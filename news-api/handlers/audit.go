@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// maxAuditLimit caps how many audit entries a single request can return.
+const maxAuditLimit = 1000
+
+// auditActor names who performed an admin request. The admin token is shared,
+// so operators can identify themselves with an X-Admin-User header.
+func auditActor(r *http.Request) string {
+	if user := strings.TrimSpace(r.Header.Get("X-Admin-User")); user != "" {
+		return "admin:" + user
+	}
+	return "admin"
+}
+
+// recordAudit stores an administrative change. Failures are logged but do not
+// fail the request, which has already been applied.
+func recordAudit(r *http.Request, action, target string, before, after interface{}) {
+	entry := models.AuditEntry{
+		Actor:      auditActor(r),
+		Action:     action,
+		Target:     target,
+		Before:     auditValue(before),
+		After:      auditValue(after),
+		RemoteAddr: r.RemoteAddr,
+	}
+	if _, err := db.RecordAudit(entry); err != nil {
+		log.Printf("Error recording audit entry %s %s: %v", action, target, err)
+	}
+}
+
+func auditValue(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error encoding audit value: %v", err)
+		return nil
+	}
+	return data
+}
+
+// GetAuditLog lists administrative changes, newest first. It accepts action,
+// target and actor filters, a limit (default 100) and a before entry ID for paging.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	limit := params.limit(100, maxAuditLimit)
+	beforeID := int64(params.intRange("before", 0, 1, math.MaxInt))
+	if !params.valid(w) {
+		return
+	}
+
+	entries, err := db.GetAuditLog(db.AuditFilter{
+		Action:   query.Get("action"),
+		Target:   query.Get("target"),
+		Actor:    query.Get("actor"),
+		BeforeID: beforeID,
+		Limit:    limit,
+	})
+	if err != nil {
+		log.Printf("Error fetching audit log: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
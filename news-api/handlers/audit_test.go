@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminChangesAreAudited(t *testing.T) {
+	setupTestDB(t)
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = "" }()
+
+	rr := httptest.NewRecorder()
+	RequireAdmin(CreateOrganization)(rr, adminRequest("POST", "/admin/orgs", orgRequest{Name: "Audited"}, ""))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var org models.Organization
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&org))
+	id := strconv.FormatInt(org.ID, 10)
+
+	req := adminRequest("PUT", "/admin/orgs/"+id+"/watchlist", []string{"Fortinet"}, id)
+	req.Header.Set("X-Admin-User", "alice")
+	rr = httptest.NewRecorder()
+	RequireAdmin(SetOrgWatchlist)(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(GetAuditLog)(rr, adminRequest("GET", "/admin/audit?target=org:"+id, nil, ""))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var entries []models.AuditEntry
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&entries))
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "watchlist.set", entries[0].Action)
+	assert.Equal(t, "admin:alice", entries[0].Actor)
+	assert.JSONEq(t, `[]`, string(entries[0].Before))
+	assert.JSONEq(t, `["fortinet"]`, string(entries[0].After))
+
+	assert.Equal(t, "org.create", entries[1].Action)
+	assert.Equal(t, "admin", entries[1].Actor)
+	assert.Empty(t, entries[1].Before)
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"news-api/db"
+)
+
+// GetArticleBody returns the archived readable body of the article {id}.
+func GetArticleBody(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	body, err := db.GetArticleBody(OrgFromContext(r.Context()), articleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "No archived body for this article")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching archived body: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+)
+
+// GetBreaches lists the data breaches announced by articles, most recently
+// reported first. It accepts organization (a substring of the breached
+// organization), minRecords, the start, end and window filters of /news, and
+// limit and offset.
+func GetBreaches(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	startDate, endDate := params.dateRange()
+	filter := db.BreachFilter{
+		OrgID:        OrgFromContext(r.Context()),
+		Organization: strings.TrimSpace(r.URL.Query().Get("organization")),
+		MinRecords:   int64(params.intRange("minRecords", 0, 0, math.MaxInt)),
+		StartDate:    startDate,
+		EndDate:      endDate,
+		Limit:        params.limit(DefaultPageSize, MaxPageSize),
+		Offset:       params.offset(),
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	breaches, err := db.GetBreaches(filter)
+	if err != nil {
+		log.Printf("Error fetching breaches: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	setNextPageLink(w, r, filter.Offset, filter.Limit, len(breaches))
+	writeJSON(w, http.StatusOK, breaches)
+}
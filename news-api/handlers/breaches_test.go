@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBreaches(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Acme Bank breach exposes 20,000 accounts", URL: "u1", PublishedAt: time.Now().Add(-time.Hour)}))
+
+	rr := httptest.NewRecorder()
+	GetBreaches(rr, httptest.NewRequest("GET", "/breaches?organization=acme&window=7d", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var breaches []models.Breach
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&breaches))
+	require.Len(t, breaches, 1)
+	assert.Equal(t, int64(20_000), breaches[0].Records)
+
+	rr = httptest.NewRecorder()
+	GetBreaches(rr, httptest.NewRequest("GET", "/breaches?minRecords=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
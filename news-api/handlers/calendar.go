@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"news-api/db"
+)
+
+const (
+	// calendarPast and calendarFuture bound the calendar when no start or end
+	// is given.
+	calendarPast   = 90 * 24 * time.Hour
+	calendarFuture = 365 * 24 * time.Hour
+	// calendarMaxSpan is the longest period a calendar can cover.
+	calendarMaxSpan = 3 * 365 * 24 * time.Hour
+	// kevListedCVEs is the number of CVEs named in the summary of a KEV
+	// deadline before they are only counted.
+	kevListedCVEs = 3
+)
+
+// calendarEventKinds are the kinds of events /calendar.ics can include.
+var calendarEventKinds = []string{"patch-tuesday", "kev", "code-red"}
+
+// calendarEvent is an all-day event.
+type calendarEvent struct {
+	uid         string
+	day         time.Time
+	summary     string
+	description string
+	url         string
+	categories  string
+}
+
+// GetCalendar serves an iCalendar feed of Patch Tuesdays, CISA KEV remediation
+// deadlines and the days the caller's threat level reached Code Red, by
+// default from 90 days ago to a year ahead. The events parameter selects a
+// comma-separated subset of patch-tuesday, kev and code-red.
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	start, end := params.dateRange()
+	kinds := map[string]bool{}
+	if raw := r.URL.Query().Get("events"); raw != "" {
+		for _, kind := range strings.Split(raw, ",") {
+			kind = strings.TrimSpace(kind)
+			if !slices.Contains(calendarEventKinds, kind) {
+				params.invalid("events must be a comma-separated list of %s", strings.Join(calendarEventKinds, ", "))
+				break
+			}
+			kinds[kind] = true
+		}
+	} else {
+		for _, kind := range calendarEventKinds {
+			kinds[kind] = true
+		}
+	}
+	if !params.valid(w) {
+		return
+	}
+	now := time.Now().UTC()
+	if start.IsZero() {
+		start = now.Add(-calendarPast)
+	}
+	if end.IsZero() {
+		end = now.Add(calendarFuture)
+	}
+	if end.Sub(start) > calendarMaxSpan {
+		WriteError(w, http.StatusBadRequest, "The calendar cannot span more than 3 years")
+		return
+	}
+
+	var events []calendarEvent
+	if kinds["patch-tuesday"] {
+		for _, day := range patchTuesdays(start, end) {
+			events = append(events, calendarEvent{
+				uid:         "patch-tuesday-" + day.Format("2006-01") + "@threatfeed",
+				day:         day,
+				summary:     "Microsoft Patch Tuesday",
+				description: "Monthly Microsoft security updates.",
+				url:         "https://msrc.microsoft.com/update-guide/releaseNote/" + day.Format("2006-Jan"),
+				categories:  "Patch Tuesday",
+			})
+		}
+	}
+	if kinds["kev"] {
+		kevEvents, err := kevDeadlineEvents(start, end)
+		if err != nil {
+			log.Printf("Error reading KEV deadlines: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		events = append(events, kevEvents...)
+	}
+	if kinds["code-red"] {
+		orgID := OrgFromContext(r.Context())
+		days, err := db.GetThreatLevelDays(orgID, "Code Red", start, end)
+		if err != nil {
+			log.Printf("Error reading threat level history: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		for _, day := range days {
+			events = append(events, calendarEvent{
+				uid:         fmt.Sprintf("code-red-%s-%d@threatfeed", day.Format("2006-01-02"), orgID),
+				day:         day,
+				summary:     "Threat level Code Red",
+				description: "The Threatfeed threat level reached Code Red.",
+				categories:  "Code Red",
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="threatfeed.ics"`)
+	writeCalendar(w, events, now)
+}
+
+// patchTuesdays returns the second Tuesday of every month between start and
+// end, as UTC days.
+func patchTuesdays(start, end time.Time) []time.Time {
+	var days []time.Time
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for !month.After(end) {
+		offset := (int(time.Tuesday) - int(month.Weekday()) + 7) % 7
+		day := month.AddDate(0, 0, offset+7)
+		if !day.Before(start.UTC().Truncate(24*time.Hour)) && !day.After(end) {
+			days = append(days, day)
+		}
+		month = month.AddDate(0, 1, 0)
+	}
+	return days
+}
+
+// kevDeadlineEvents returns one event per KEV due date, listing the
+// vulnerabilities due that day.
+func kevDeadlineEvents(start, end time.Time) ([]calendarEvent, error) {
+	entries, err := db.GetKEVDeadlines(start, end)
+	if err != nil {
+		return nil, err
+	}
+	var events []calendarEvent
+	for i := 0; i < len(entries); {
+		j := i
+		var cves, lines []string
+		for ; j < len(entries) && entries[j].DueDate.Equal(entries[i].DueDate); j++ {
+			e := entries[j]
+			cves = append(cves, e.CVE)
+			line := fmt.Sprintf("%s: %s %s, %s (added %s)", e.CVE, e.Vendor, e.Product, e.Name, e.DateAdded.Format("2006-01-02"))
+			if e.Ransomware {
+				line += ", used by ransomware"
+			}
+			lines = append(lines, line)
+		}
+		summary := "CISA KEV due: " + strings.Join(cves, ", ")
+		if len(cves) > kevListedCVEs {
+			summary = fmt.Sprintf("CISA KEV due: %d vulnerabilities", len(cves))
+		}
+		event := calendarEvent{
+			uid:         "kev-" + entries[i].DueDate.Format("2006-01-02") + "@threatfeed",
+			day:         entries[i].DueDate,
+			summary:     summary,
+			description: "Remediation deadline of the Known Exploited Vulnerabilities catalog:\n" + strings.Join(lines, "\n"),
+			url:         "https://www.cisa.gov/known-exploited-vulnerabilities-catalog",
+			categories:  "KEV",
+		}
+		events = append(events, event)
+		i = j
+	}
+	return events, nil
+}
+
+// writeCalendar writes events as an iCalendar (RFC 5545) document.
+func writeCalendar(w http.ResponseWriter, events []calendarEvent, stamp time.Time) {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(foldICSLine(name + ":" + value))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Threatfeed//Threat Calendar//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("X-WR-CALNAME", "Threatfeed")
+	for _, e := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.uid)
+		line("DTSTAMP", stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE", e.day.Format("20060102"))
+		line("DTEND;VALUE=DATE", e.day.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY", escapeICSText(e.summary))
+		if e.description != "" {
+			line("DESCRIPTION", escapeICSText(e.description))
+		}
+		if e.url != "" {
+			line("URL", e.url)
+		}
+		if e.categories != "" {
+			line("CATEGORIES", escapeICSText(e.categories))
+		}
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	w.Write([]byte(b.String()))
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// foldICSLine splits a content line into lines of at most 75 octets, each
+// continuation starting with a space, without splitting UTF-8 characters.
+func foldICSLine(s string) string {
+	const limit = 75
+	var b strings.Builder
+	for first := true; len(s) > 0; first = false {
+		n := limit
+		if !first {
+			n--
+			b.WriteString("\r\n ")
+		}
+		if n >= len(s) {
+			b.WriteString(s)
+			break
+		}
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		b.WriteString(s[:n])
+		s = s[n:]
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchTuesdays(t *testing.T) {
+	days := patchTuesdays(time.Date(2024, 4, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC))
+	require.Len(t, days, 9, "April 9 is before the start")
+	assert.Equal(t, time.Date(2024, 5, 14, 0, 0, 0, 0, time.UTC), days[0])
+	assert.Equal(t, time.Date(2024, 10, 8, 0, 0, 0, 0, time.UTC), days[5])
+	assert.Equal(t, time.Date(2025, 1, 14, 0, 0, 0, 0, time.UTC), days[8])
+}
+
+func TestFoldICSLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 60)
+	folded := foldICSLine(line)
+	for _, part := range strings.Split(folded, "\r\n") {
+		assert.LessOrEqual(t, len(part), 75)
+	}
+	assert.Equal(t, line, strings.ReplaceAll(folded, "\r\n ", ""))
+	assert.Equal(t, "SUMMARY:short", foldICSLine("SUMMARY:short"))
+}
+
+func TestGetCalendar(t *testing.T) {
+	setupTestDB(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"vulnerabilities": [
+			{"cveID": "CVE-2024-3400", "vendorProject": "Palo Alto Networks", "product": "PAN-OS", "vulnerabilityName": "Command Injection", "dateAdded": "2024-04-12", "dueDate": "2024-04-19"},
+			{"cveID": "CVE-2024-3273", "vendorProject": "D-Link", "product": "NAS", "vulnerabilityName": "Command Injection, Hardcoded Credential", "dateAdded": "2024-04-11", "dueDate": "2024-04-19", "knownRansomwareCampaignUse": "Known"}
+		]}`))
+	}))
+	defer server.Close()
+	_, err := db.SyncKEV(server.URL)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	GetCalendar(rr, httptest.NewRequest("GET", "/calendar.ics?start=2024-04-01&end=2024-04-30", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+	body := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT"))
+	assert.Contains(t, body, "UID:patch-tuesday-2024-04@threatfeed\r\nDTSTAMP:")
+	assert.Contains(t, body, "DTSTART;VALUE=DATE:20240409\r\nDTEND;VALUE=DATE:20240410")
+	assert.Contains(t, body, "SUMMARY:CISA KEV due: CVE-2024-3273\\, CVE-2024-3400")
+	assert.Contains(t, body, `Command Injection\, Hardcoded Credential (added 2024-04-11)\, used by ransomware`)
+
+	rr = httptest.NewRecorder()
+	GetCalendar(rr, httptest.NewRequest("GET", "/calendar.ics?start=2024-04-01&end=2024-04-30&events=kev", nil))
+	assert.Equal(t, 1, strings.Count(rr.Body.String(), "BEGIN:VEVENT"))
+
+	for _, query := range []string{"events=holidays", "start=2020-01-01&end=2024-01-01"} {
+		rr = httptest.NewRecorder()
+		GetCalendar(rr, httptest.NewRequest("GET", "/calendar.ics?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+const maxTopCVEsLimit = 500
+
+type topCVEsResponse struct {
+	Since time.Time           `json:"since"`
+	CVEs  []models.CVEMention `json:"cves"`
+}
+
+// GetTopCVEs returns the CVEs mentioned by the most articles published in the
+// window (default 7d), with the first and last date any article mentioned them.
+// It accepts a limit (default 20) on the number of CVEs.
+func GetTopCVEs(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	window := 7 * 24 * time.Hour
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		var err error
+		if window, err = parseWindow(raw); err != nil {
+			params.invalid("window must be a positive duration such as 24h or 7d")
+		}
+	}
+	limit := params.limit(20, maxTopCVEsLimit)
+	if !params.valid(w) {
+		return
+	}
+
+	since := time.Now().UTC().Add(-window)
+	cves, err := db.GetTopCVEs(OrgFromContext(r.Context()), since, limit)
+	if err != nil {
+		log.Printf("Error fetching top CVEs: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, topCVEsResponse{Since: since, CVEs: cves})
+}
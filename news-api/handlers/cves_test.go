@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTopCVEs(t *testing.T) {
+	setupTestDB(t)
+	now := time.Now().UTC()
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "CVE-2024-1111 exploited", URL: "u1", PublishedAt: now.Add(-time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "CVE-2024-2222 patched", URL: "u2", PublishedAt: now.Add(-3 * 24 * time.Hour)}))
+
+	rr := httptest.NewRecorder()
+	GetTopCVEs(rr, httptest.NewRequest("GET", "/cves/top?window=bogus", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	GetTopCVEs(rr, httptest.NewRequest("GET", "/cves/top", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp topCVEsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Len(t, resp.CVEs, 2)
+
+	rr = httptest.NewRecorder()
+	GetTopCVEs(rr, httptest.NewRequest("GET", "/cves/top?window=24h", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.CVEs, 1)
+	assert.Equal(t, "CVE-2024-1111", resp.CVEs[0].CVE)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+const (
+	defaultDeliveryLimit = 100
+	maxDeliveryLimit     = 1000
+)
+
+// deliveryFilter parses the sink and status filters of the delivery
+// endpoints, writing a 400 response if they are invalid.
+func deliveryFilter(w http.ResponseWriter, r *http.Request, paged bool) (db.DeliveryFilter, bool) {
+	params := newQueryParams(r)
+	f := db.DeliveryFilter{
+		Sink:   r.URL.Query().Get("sink"),
+		Status: params.oneOf("status", db.DeliveryPending, db.DeliveryDead),
+	}
+	if paged {
+		f.Limit = params.limit(defaultDeliveryLimit, maxDeliveryLimit)
+		f.BeforeID = int64(params.intRange("before", 0, 1, math.MaxInt))
+	}
+	return f, params.valid(w)
+}
+
+// GetDeliveries lists the outbound delivery queue, newest first. It accepts
+// sink and status filters, a limit (default 100) and a before delivery ID for
+// paging.
+func GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	f, ok := deliveryFilter(w, r, true)
+	if !ok {
+		return
+	}
+	deliveries, err := db.GetDeliveries(f)
+	if err != nil {
+		log.Printf("Error fetching deliveries: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// GetDelivery returns the delivery in the {id} path segment.
+func GetDelivery(w http.ResponseWriter, r *http.Request) {
+	if d, ok := deliveryFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, d)
+	}
+}
+
+// ReplayDelivery makes the delivery in the {id} path segment due now with its
+// attempts reset, and returns it.
+func ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	d, ok := deliveryFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.ReplayDelivery(d.ID); errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Delivery not found")
+		return
+	} else if err != nil {
+		log.Printf("Error replaying delivery: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "delivery.replay", deliveryTarget(d.ID), d, nil)
+	if d, ok = deliveryFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, d)
+	}
+}
+
+// ReplayDeliveries replays every dead delivery, or those of the sink query
+// parameter, and returns {"replayed": n}.
+func ReplayDeliveries(w http.ResponseWriter, r *http.Request) {
+	f, ok := deliveryFilter(w, r, false)
+	if !ok {
+		return
+	}
+	f.Status = db.DeliveryDead
+	n, err := db.ReplayDeliveries(f)
+	if err != nil {
+		log.Printf("Error replaying deliveries: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "delivery.replay", "deliveries", nil, map[string]interface{}{"sink": f.Sink, "replayed": n})
+	writeJSON(w, http.StatusOK, map[string]int64{"replayed": n})
+}
+
+// DeleteDelivery removes the delivery in the {id} path segment from the queue.
+func DeleteDelivery(w http.ResponseWriter, r *http.Request) {
+	d, ok := deliveryFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DeleteDelivery(d.ID); errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Delivery not found")
+		return
+	} else if err != nil {
+		log.Printf("Error deleting delivery: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "delivery.delete", deliveryTarget(d.ID), d, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteDeliveries removes every dead delivery, or those of the sink query
+// parameter, and returns {"deleted": n}. Pending deliveries are only removed
+// with status=pending.
+func DeleteDeliveries(w http.ResponseWriter, r *http.Request) {
+	f, ok := deliveryFilter(w, r, false)
+	if !ok {
+		return
+	}
+	if f.Status == "" {
+		f.Status = db.DeliveryDead
+	}
+	n, err := db.DeleteDeliveries(f)
+	if err != nil {
+		log.Printf("Error deleting deliveries: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "delivery.delete", "deliveries", map[string]interface{}{"sink": f.Sink, "status": f.Status, "deleted": n}, nil)
+	writeJSON(w, http.StatusOK, map[string]int64{"deleted": n})
+}
+
+func deliveryFromPath(w http.ResponseWriter, r *http.Request) (models.Delivery, bool) {
+	id, ok := idFromPath(w, r, "id", "delivery")
+	if !ok {
+		return models.Delivery{}, false
+	}
+	d, err := db.GetDelivery(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Delivery not found")
+		return models.Delivery{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching delivery: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.Delivery{}, false
+	}
+	return d, true
+}
+
+func deliveryTarget(id int64) string {
+	return "delivery:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveries(t *testing.T) {
+	setupTestDB(t)
+	call := func(handler http.HandlerFunc, method, target, id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler(rr, adminRequest(method, target, nil, id))
+		return rr
+	}
+	var deliveries []models.Delivery
+	for _, sink := range []string{"webhook", "splunk", "splunk"} {
+		d, err := db.EnqueueDelivery(models.Delivery{Sink: sink, ContentType: "application/json", Payload: `{}`})
+		require.NoError(t, err)
+		deliveries = append(deliveries, d)
+	}
+	for _, d := range deliveries[1:] {
+		d.Status, d.Attempts, d.LastError = db.DeliveryDead, 8, "connection refused"
+		require.NoError(t, db.RescheduleDeliveries([]models.Delivery{d}))
+	}
+
+	rr := call(GetDeliveries, "GET", "/admin/deliveries?status=dead&sink=splunk&limit=1", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var page []models.Delivery
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page, 1)
+	assert.Equal(t, deliveries[2].ID, page[0].ID)
+	assert.Equal(t, "connection refused", page[0].LastError)
+	assert.Equal(t, http.StatusBadRequest, call(GetDeliveries, "GET", "/admin/deliveries?status=delivered", "").Code)
+
+	id := strconv.FormatInt(deliveries[1].ID, 10)
+	rr = call(ReplayDelivery, "POST", "/admin/deliveries/"+id+"/replay", id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var replayed models.Delivery
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &replayed))
+	assert.Equal(t, db.DeliveryPending, replayed.Status)
+	assert.Zero(t, replayed.Attempts)
+
+	rr = call(ReplayDeliveries, "POST", "/admin/deliveries/replay?sink=splunk", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"replayed": 1}`, rr.Body.String())
+
+	rr = call(DeleteDeliveries, "DELETE", "/admin/deliveries", "")
+	assert.JSONEq(t, `{"deleted": 0}`, rr.Body.String(), "only dead deliveries are deleted by default")
+	rr = call(DeleteDeliveries, "DELETE", "/admin/deliveries?status=pending&sink=splunk", "")
+	assert.JSONEq(t, `{"deleted": 2}`, rr.Body.String())
+
+	id = strconv.FormatInt(deliveries[0].ID, 10)
+	assert.Equal(t, http.StatusNoContent, call(DeleteDelivery, "DELETE", "/admin/deliveries/"+id, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(GetDelivery, "GET", "/admin/deliveries/"+id, id).Code)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// EmbedAncestors are the origins allowed to frame /embed, as CSP
+// frame-ancestors sources. When empty, any site may embed it.
+var EmbedAncestors []string
+
+const (
+	defaultEmbedArticles = 5
+	maxEmbedArticles     = 10
+	// embedRefreshSeconds is how often an embedded widget reloads itself, and
+	// how long oEmbed consumers may cache the embed code.
+	embedRefreshSeconds = 300
+	embedWidth          = 320
+	// embedHeight and embedRowHeight size the frame: a fixed part for the
+	// threat level and credit, plus a row per headline.
+	embedHeight    = 96
+	embedRowHeight = 44
+)
+
+var embedPage = template.Must(template.New("embed").Funcs(template.FuncMap{"publisher": publisher}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>Threat level: {{.ThreatLevel}}</title>
+<link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="Threatfeed">
+<style>
+body { font: 14px/1.4 sans-serif; margin: 0; padding: 8px; color: #222; background: #fff; }
+body.dark { color: #eee; background: #1e1e1e; }
+a { color: inherit; }
+.level { padding: 6px 8px; border-radius: 4px; background: #ddd; color: #222; }
+.level.code-red { background: #c62828; color: #fff; }
+.level.attention { background: #f9a825; }
+.level.business-as-usual { background: #2e7d32; color: #fff; }
+ul { list-style: none; margin: 8px 0; padding: 0; }
+li { margin: 0 0 6px; white-space: nowrap; overflow: hidden; text-overflow: ellipsis; }
+.meta, .credit { color: #888; font-size: 12px; }
+.credit { margin: 0; text-align: right; }
+</style>
+</head>
+<body class="{{.Theme}}">
+<div class="level {{.LevelClass}}">Threat level: <strong>{{.ThreatLevel}}</strong></div>
+<ul>
+{{- range .Articles}}
+<li><a href="{{$.Base}}/r/{{.ID}}" target="_blank" rel="noopener">{{.Title}}</a><br><span class="meta">{{publisher .}} &middot; severity {{.Severity}}</span></li>
+{{- end}}
+</ul>
+<p class="credit"><a href="{{.Base}}" target="_blank" rel="noopener">Threatfeed</a></p>
+</body>
+</html>
+`))
+
+type embedPageData struct {
+	Base        string
+	OEmbedURL   string
+	Theme       string
+	ThreatLevel string
+	LevelClass  string
+	Articles    []models.NewsArticle
+	Refresh     int
+}
+
+// embedScript replaces its own script tag with a frame showing /embed. The
+// tag's data-limit, data-theme and data-width attributes configure it.
+var embedScript = texttemplate.Must(texttemplate.New("embed.js").Parse(`(function () {
+  var script = document.currentScript;
+  if (!script) return;
+  var params = new URLSearchParams();
+  var limit = parseInt(script.dataset.limit || "{{.Limit}}", 10);
+  params.set("limit", limit);
+  if (script.dataset.theme) params.set("theme", script.dataset.theme);
+  var frame = document.createElement("iframe");
+  frame.src = {{.Base}} + "/embed?" + params.toString();
+  frame.title = "Threatfeed threat level";
+  frame.loading = "lazy";
+  frame.width = script.dataset.width || "{{.Width}}";
+  frame.height = String({{.Height}} + {{.RowHeight}} * limit);
+  frame.style.border = "0";
+  script.parentNode.insertBefore(frame, script);
+})();
+`))
+
+// oembedResponse is an oEmbed rich response, as specified at https://oembed.com.
+type oembedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int    `json:"cache_age"`
+}
+
+// embedOptions reads the limit and theme of an embedded widget.
+func embedOptions(params *queryParams) (int, string) {
+	limit := params.intRange("limit", defaultEmbedArticles, 1, maxEmbedArticles)
+	theme := params.oneOf("theme", "light", "dark")
+	if theme == "" {
+		theme = "light"
+	}
+	return limit, theme
+}
+
+// GetEmbed renders the current threat level and the latest headlines of the
+// shared feed as a small page meant to be framed by other sites, which is
+// what /embed.js and /oembed embed. It reloads itself every five minutes.
+func GetEmbed(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	limit, theme := embedOptions(params)
+	if !params.valid(w) {
+		return
+	}
+	// Frames cannot send an API key, so the embed always shows the shared feed.
+	score, err := db.GetOrgThreatScore(0)
+	if err != nil {
+		log.Printf("Error getting threat score for embed: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	articles, err := db.QueryArticles(db.ArticleFilter{Limit: limit})
+	if err != nil {
+		log.Printf("Error fetching articles for embed: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	base := baseURL(r)
+	embedURL := base + "/embed?" + r.URL.RawQuery
+	data := embedPageData{
+		Base:        base,
+		OEmbedURL:   base + "/oembed?url=" + url.QueryEscape(strings.TrimSuffix(embedURL, "?")),
+		Theme:       theme,
+		ThreatLevel: score.ThreatLevel,
+		LevelClass:  strings.ReplaceAll(strings.ToLower(score.ThreatLevel), " ", "-"),
+		Articles:    articles,
+		Refresh:     embedRefreshSeconds,
+	}
+	ancestors := "*"
+	if len(EmbedAncestors) > 0 {
+		ancestors = strings.Join(EmbedAncestors, " ")
+	}
+	// This page is the one meant to be framed, so it lifts the DENY set for
+	// every other response.
+	w.Header().Del("X-Frame-Options")
+	w.Header().Set("Content-Security-Policy", strings.TrimSpace(w.Header().Get("Content-Security-Policy")+" frame-ancestors "+ancestors+";"))
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := embedPage.Execute(w, data); err != nil {
+		log.Printf("Error rendering embed: %v", err)
+	}
+}
+
+// GetEmbedScript serves the script that embeds the widget with one tag:
+//
+//	<script src="https://threatfeed.example.org/embed.js" data-limit="5" async></script>
+func GetEmbedScript(w http.ResponseWriter, r *http.Request) {
+	base, err := json.Marshal(baseURL(r))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	err = embedScript.Execute(w, map[string]interface{}{
+		"Base":      string(base),
+		"Limit":     defaultEmbedArticles,
+		"Width":     embedWidth,
+		"Height":    embedHeight,
+		"RowHeight": embedRowHeight,
+	})
+	if err != nil {
+		log.Printf("Error rendering embed script: %v", err)
+	}
+}
+
+// GetOEmbed is the oEmbed endpoint for /embed URLs, which lets blog and wiki
+// platforms turn a pasted link into the embedded widget. Only the JSON format
+// is supported.
+func GetOEmbed(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	format := params.oneOf("format", "json", "xml")
+	maxWidth := params.intRange("maxwidth", 0, 1, math.MaxInt)
+	maxHeight := params.intRange("maxheight", 0, 1, math.MaxInt)
+	if !params.valid(w) {
+		return
+	}
+	if format == "xml" {
+		WriteError(w, http.StatusNotImplemented, "Only the json format is supported")
+		return
+	}
+
+	base := baseURL(r)
+	rest, ok := strings.CutPrefix(r.URL.Query().Get("url"), base+"/embed")
+	query, hasQuery := strings.CutPrefix(rest, "?")
+	if !ok || (rest != "" && !hasQuery) {
+		WriteError(w, http.StatusNotFound, "url must be an /embed URL of this service")
+		return
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid url query")
+		return
+	}
+	embedParams := &queryParams{values: values}
+	limit, theme := embedOptions(embedParams)
+	if !embedParams.valid(w) {
+		return
+	}
+
+	width, height := embedWidth, embedHeight+embedRowHeight*limit
+	if maxWidth > 0 {
+		width = min(width, maxWidth)
+	}
+	if maxHeight > 0 {
+		height = min(height, maxHeight)
+	}
+	src := base + "/embed?" + url.Values{"limit": {strconv.Itoa(limit)}, "theme": {theme}}.Encode()
+	html := `<iframe src="` + template.HTMLEscapeString(src) + `" width="` + strconv.Itoa(width) + `" height="` + strconv.Itoa(height) +
+		`" title="Threatfeed threat level" loading="lazy" style="border:0"></iframe>`
+	writeJSON(w, http.StatusOK, oembedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        "Threatfeed threat level",
+		ProviderName: "Threatfeed",
+		ProviderURL:  base,
+		HTML:         html,
+		Width:        width,
+		Height:       height,
+		CacheAge:     embedRefreshSeconds,
+	})
+}
+
+// publisher returns the host name of an article's URL without "www.", or its
+// source URL if the article URL has none.
+func publisher(article models.NewsArticle) string {
+	if u, err := url.Parse(article.URL); err == nil && u.Hostname() != "" {
+		return strings.TrimPrefix(u.Hostname(), "www.")
+	}
+	return article.SourceURL
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEmbed(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Worm <spreads>", URL: "https://www.example.com/worm", SourceURL: "s1",
+		Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour),
+	}))
+
+	req := httptest.NewRequest("GET", "/embed?limit=3&theme=dark", nil)
+	rr := httptest.NewRecorder()
+	rr.Header().Set("X-Frame-Options", "DENY")
+	rr.Header().Set("Content-Security-Policy", "default-src 'self';")
+	GetEmbed(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "default-src 'self'; frame-ancestors *;", rr.Header().Get("Content-Security-Policy"))
+	page := rr.Body.String()
+	assert.Contains(t, page, `<body class="dark">`)
+	assert.Contains(t, page, "Worm &lt;spreads&gt;")
+	assert.Contains(t, page, "example.com &middot;")
+	assert.Contains(t, page, `href="http://example.com/r/`)
+	assert.Contains(t, page, `href="http://example.com/oembed?url=http%3A%2F%2Fexample.com%2Fembed%3Flimit%3D3%26theme%3Ddark"`)
+
+	EmbedAncestors = []string{"https://wiki.example.org"}
+	defer func() { EmbedAncestors = nil }()
+	rr = httptest.NewRecorder()
+	GetEmbed(rr, httptest.NewRequest("GET", "/embed", nil))
+	assert.Equal(t, "frame-ancestors https://wiki.example.org;", rr.Header().Get("Content-Security-Policy"))
+
+	rr = httptest.NewRecorder()
+	GetEmbed(rr, httptest.NewRequest("GET", "/embed?limit=50", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetEmbedScript(t *testing.T) {
+	PublicURL = "https://threatfeed.example.org"
+	defer func() { PublicURL = "" }()
+	rr := httptest.NewRecorder()
+	GetEmbedScript(rr, httptest.NewRequest("GET", "/embed.js", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/javascript; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `frame.src = "https://threatfeed.example.org" + "/embed?"`)
+}
+
+func TestGetOEmbed(t *testing.T) {
+	oembed := func(query url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		GetOEmbed(rr, httptest.NewRequest("GET", "/oembed?"+query.Encode(), nil))
+		return rr
+	}
+
+	rr := oembed(url.Values{"url": {"http://example.com/embed?limit=2"}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp oembedResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "1.0", resp.Version)
+	assert.Equal(t, "rich", resp.Type)
+	assert.Equal(t, "http://example.com", resp.ProviderURL)
+	assert.Equal(t, embedWidth, resp.Width)
+	assert.Equal(t, embedHeight+2*embedRowHeight, resp.Height)
+	assert.Contains(t, resp.HTML, `<iframe src="http://example.com/embed?limit=2&amp;theme=light"`)
+
+	rr = oembed(url.Values{"url": {"http://example.com/embed"}, "maxwidth": {"200"}, "maxheight": {"100"}})
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, 200, resp.Width)
+	assert.Equal(t, 100, resp.Height)
+
+	assert.Equal(t, http.StatusNotFound, oembed(url.Values{"url": {"http://other.example/embed"}}).Code)
+	assert.Equal(t, http.StatusNotFound, oembed(url.Values{"url": {"http://example.com/embedded"}}).Code)
+	assert.Equal(t, http.StatusBadRequest, oembed(url.Values{"url": {"http://example.com/embed?theme=neon"}}).Code)
+	assert.Equal(t, http.StatusNotImplemented, oembed(url.Values{"url": {"http://example.com/embed"}, "format": {"xml"}}).Code)
+}
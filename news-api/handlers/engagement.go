@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// TrackEngagement enables counting the clicks redirected by RedirectToArticle.
+// Read-only instances disable it, since they cannot store the counts.
+var TrackEngagement = true
+
+// RedirectToArticle counts a click on the article {id} and redirects to its URL.
+// HEAD requests are not counted.
+func RedirectToArticle(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	article, err := db.GetOrgArticle(OrgFromContext(r.Context()), articleID, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if u, err := url.Parse(article.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		WriteError(w, http.StatusNotFound, "Article has no web URL")
+		return
+	}
+	if TrackEngagement && r.Method == http.MethodGet {
+		// A lost count must not keep the reader from the article.
+		if err := db.RecordClick(articleID); err != nil {
+			log.Printf("Error recording click on article %d: %v", articleID, err)
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, article.URL, http.StatusFound)
+}
+
+type impressionsRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+type impressionsResponse struct {
+	Counted int `json:"counted"`
+}
+
+// RecordImpressions counts an impression of each article in {"ids": [...]},
+// which clients send for the articles they display. Articles the caller's
+// organization cannot see are ignored.
+func RecordImpressions(w http.ResponseWriter, r *http.Request) {
+	var req impressionsRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if len(req.IDs) > db.MaxImpressionBatch {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ids can be reported at once", db.MaxImpressionBatch))
+		return
+	}
+	counted, err := db.RecordImpressions(OrgFromContext(r.Context()), req.IDs)
+	if err != nil {
+		log.Printf("Error recording impressions: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, impressionsResponse{Counted: counted})
+}
+
+type engagementStatsResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	models.EngagementStats
+}
+
+// GetEngagementStats sums the impressions and clicks counted on the days of
+// the window, in total and per source and category. It accepts the window
+// parameters and the category and source filters of /stats/terms.
+func GetEngagementStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	days := params.intRange("days", 7, 1, maxTermStatsDays)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	if endDate.IsZero() {
+		endDate = time.Now()
+	}
+	if startDate.IsZero() {
+		startDate = endDate.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	stats, err := db.GetEngagementStats(db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      query.Get("source"),
+		Category:    query.Get("category"),
+		IncludeDead: true,
+	}, startDate, endDate)
+	if err != nil {
+		log.Printf("Error aggregating engagement: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, engagementStatsResponse{Start: startDate, End: endDate, EngagementStats: stats})
+}
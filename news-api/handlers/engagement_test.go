@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngagementTracking(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "SCADA exploit", URL: "https://example.com/scada", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Feed entry", URL: "javascript:alert(1)", SourceURL: "s1", PublishedAt: time.Now().Add(-2 * time.Hour)}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	idByURL := map[string]string{}
+	for _, a := range articles {
+		idByURL[a.URL] = strconv.FormatInt(a.ID, 10)
+	}
+
+	redirect := func(method, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/r/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		RedirectToArticle(rr, req)
+		return rr
+	}
+	rr := redirect("GET", idByURL["https://example.com/scada"])
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://example.com/scada", rr.Header().Get("Location"))
+	assert.Equal(t, http.StatusFound, redirect("HEAD", idByURL["https://example.com/scada"]).Code)
+	assert.Equal(t, http.StatusNotFound, redirect("GET", "9999").Code)
+	assert.Equal(t, http.StatusNotFound, redirect("GET", idByURL["javascript:alert(1)"]).Code)
+
+	impressions := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		RecordImpressions(rr, httptest.NewRequest("POST", "/impressions", bytes.NewBufferString(body)))
+		return rr
+	}
+	rr = impressions(`{"ids": [` + idByURL["https://example.com/scada"] + `, 9999]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"counted": 1}`, rr.Body.String())
+	assert.Equal(t, http.StatusBadRequest, impressions(`{"ids": "1"}`).Code)
+	tooMany, _ := json.Marshal(impressionsRequest{IDs: make([]int64, db.MaxImpressionBatch+1)})
+	assert.Equal(t, http.StatusBadRequest, impressions(string(tooMany)).Code)
+
+	rr = httptest.NewRecorder()
+	GetEngagementStats(rr, httptest.NewRequest("GET", "/stats/engagement?days=1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stats engagementStatsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	assert.Equal(t, 1, stats.Impressions)
+	assert.Equal(t, 1, stats.Clicks, "HEAD requests are not counted")
+	assert.Equal(t, []models.EngagementCount{{Key: "s1", Impressions: 1, Clicks: 1, ClickThroughRate: 1}}, stats.BySource)
+
+	rr = httptest.NewRecorder()
+	GetEngagementStats(rr, httptest.NewRequest("GET", "/stats/engagement?days=0", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
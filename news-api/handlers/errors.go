@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+)
+
+// errorCodes are the machine-readable codes of error responses by status.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+// ErrorBody is the body of every error response:
+// {"error": {"code": "not_found", "message": "Article not found"}}.
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error. Code is stable for clients to match on;
+// Message is meant for humans.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorCode returns the error code of a status.
+func ErrorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// WriteError replies with a JSON error envelope. It replaces http.Error,
+// whose plain text bodies clients cannot parse reliably.
+func WriteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeJSON(w, status, ErrorBody{Error: ErrorDetail{Code: ErrorCode(status), Message: message}})
+}
+
+// JSONErrors converts the plain text errors written with http.Error by the
+// standard library, such as the 404 and 405 responses of http.ServeMux and
+// http.FileServer, to JSON error envelopes.
+func JSONErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &plainErrorWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.flush()
+	})
+}
+
+// plainErrorWriter holds back plain text error responses so that they can be
+// rewritten as JSON.
+type plainErrorWriter struct {
+	http.ResponseWriter
+	status int
+	// held is the body of a held back error response.
+	held *bytes.Buffer
+}
+
+func (w *plainErrorWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if status >= 400 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		w.held = &bytes.Buffer{}
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *plainErrorWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.held != nil {
+		return w.held.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers flush successful responses.
+func (w *plainErrorWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && w.held == nil {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (w *plainErrorWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func (w *plainErrorWriter) flush() {
+	if w.held != nil {
+		WriteError(w.ResponseWriter, w.status, strings.TrimSpace(w.held.String()))
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) ErrorDetail {
+	t.Helper()
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var body ErrorBody
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	return body.Error
+}
+
+func TestWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WriteError(rr, http.StatusTooManyRequests, "Too Many Requests")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, ErrorDetail{Code: "rate_limited", Message: "Too Many Requests"}, decodeError(t, rr))
+
+	assert.Equal(t, "unprocessable_entity", ErrorCode(http.StatusUnprocessableEntity))
+}
+
+func TestHandlerErrorsAreJSON(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /news", GetNews)
+	mux.HandleFunc("GET /news/{id}", GetArticle)
+	handler := JSONErrors(mux)
+
+	tests := []struct {
+		target string
+		method string
+		status int
+		code   string
+	}{
+		{"/news?start=yesterday", http.MethodGet, http.StatusBadRequest, "bad_request"},
+		{"/news/12345", http.MethodGet, http.StatusNotFound, "not_found"},
+		{"/nowhere", http.MethodGet, http.StatusNotFound, "not_found"},
+		{"/news", http.MethodPost, http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.target, nil))
+		assert.Equal(t, tt.status, rr.Code, tt.target)
+		detail := decodeError(t, rr)
+		assert.Equal(t, tt.code, detail.Code, tt.target)
+		assert.NotEmpty(t, detail.Message, tt.target)
+	}
+
+	// Successful responses pass through unchanged.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/news", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+}
@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// ExportThreatHistory downloads the caller's recorded threat scores as CSV,
+// oldest first, optionally between the start and end dates or within window.
+func ExportThreatHistory(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	start, end := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	snapshots, err := db.GetThreatSnapshots(OrgFromContext(r.Context()), start, end)
+	if err != nil {
+		log.Printf("Error fetching threat snapshots for export: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	records := make([][]string, len(snapshots))
+	for i, s := range snapshots {
+		records[i] = []string{
+			s.TakenAt.UTC().Format(time.RFC3339),
+			s.ThreatLevel,
+			s.ComputedLevel,
+			strconv.Itoa(s.HighRankCount),
+			strconv.Itoa(s.MediumRankCount),
+			strconv.Itoa(s.LowRankCount),
+			strconv.Itoa(s.TotalArticles),
+		}
+	}
+	writeCSVExport(w, "threat-history", []string{"TakenAt", "ThreatLevel", "ComputedLevel", "HighRankCount", "MediumRankCount", "LowRankCount", "TotalArticles"}, records)
+}
+
+// ExportIngestCycles downloads the summaries of the last caching cycles as
+// CSV, oldest first.
+func ExportIngestCycles(w http.ResponseWriter, r *http.Request) {
+	cycles, err := db.GetIngestCycles()
+	if err != nil {
+		log.Printf("Error fetching caching cycles for export: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	records := make([][]string, len(cycles))
+	for i, c := range cycles {
+		records[i] = []string{
+			c.StartedAt.UTC().Format(time.RFC3339),
+			c.FinishedAt.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(c.FinishedAt.Sub(c.StartedAt).Seconds(), 'f', 1, 64),
+			strconv.Itoa(c.Sources),
+			strconv.Itoa(c.FailedSources),
+			strconv.Itoa(c.Articles),
+		}
+	}
+	writeCSVExport(w, "ingest-cycles", []string{"StartedAt", "FinishedAt", "DurationSeconds", "Sources", "FailedSources", "Articles"}, records)
+}
+
+// ExportSourceStats downloads per-source article statistics and ingestion
+// health for the caller's sources as CSV.
+func ExportSourceStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := db.GetSourceStats(OrgFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Error fetching source stats for export: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	records := make([][]string, len(stats))
+	for i, s := range stats {
+		lastSuccess := ""
+		if s.LastSuccessAt != nil {
+			lastSuccess = s.LastSuccessAt.UTC().Format(time.RFC3339)
+		}
+		records[i] = []string{
+			s.URL,
+			s.Title,
+			s.Category,
+			strconv.Itoa(s.Articles),
+			strconv.Itoa(s.HighSeverityArticles),
+			strconv.FormatFloat(s.AverageRank, 'f', 2, 64),
+			s.FirstPublishedAt.Format(time.RFC3339),
+			s.LastPublishedAt.Format(time.RFC3339),
+			lastSuccess,
+			s.LastError,
+		}
+	}
+	writeCSVExport(w, "sources", []string{"SourceURL", "Title", "Category", "Articles", "HighSeverityArticles", "AverageRank", "FirstPublishedAt", "LastPublishedAt", "LastSuccessAt", "LastError"}, records)
+}
+
+// writeCSVExport sends a CSV download named after the dataset and the time.
+func writeCSVExport(w http.ResponseWriter, dataset string, header []string, records [][]string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+dataset+"_exported-"+time.Now().UTC().Format("20060102T150405Z")+`.csv"`)
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(header); err != nil {
+		log.Printf("Error writing CSV header: %v", err)
+		return
+	}
+	if err := csvWriter.WriteAll(records); err != nil {
+		log.Printf("Error writing %s export: %v", dataset, err)
+	}
+}
+
+// ExportWriteTimeout is how long each batch of exportFlushRows articles of an
+// article export may take to write, 0 for no limit. Exports are exempt from
+// the server's write timeout, so that large ones are not cut off, but a
+// client that stops reading still is.
+var ExportWriteTimeout time.Duration
+
+// exportFlushRows is the number of articles written between flushes of an
+// article export. It is fixed so that the same articles are always encoded
+// the same way, which byte ranges rely on.
+const exportFlushRows = 500
+
+var (
+	errRangeNotSatisfiable = errors.New("range not satisfiable")
+	errRangeWritten        = errors.New("range written")
+)
+
+// writeArticlesCSV writes the rows of db.StreamArticles to dst as CSV,
+// compressed with gzip if compress is set, calling flush every
+// exportFlushRows articles. It returns the number of bytes written.
+func writeArticlesCSV(dst io.Writer, rows *sql.Rows, compress bool, flush func()) (int64, error) {
+	counter := &countingWriter{w: dst}
+	var out io.Writer = counter
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(counter)
+		out = gz
+	}
+	csvWriter := csv.NewWriter(out)
+	flushAll := func() error {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		if gz != nil {
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+		}
+		if flush != nil {
+			flush()
+		}
+		return nil
+	}
+
+	if err := csvWriter.Write([]string{"Title", "Description", "ImageURL", "URL", "SourceURL", "PublishedAt", "Rank", "Category"}); err != nil {
+		return counter.n, err
+	}
+	written := 0
+	for rows.Next() {
+		var article models.NewsArticle
+		if err := rows.Scan(&article.Title, &article.Description, &article.ImageURL, &article.URL, &article.SourceURL, &article.PublishedAt, &article.Rank, &article.Category); err != nil {
+			log.Printf("Error scanning article row for CSV export: %v", err)
+			continue // Skip bad rows
+		}
+		record := []string{
+			article.Title,
+			article.Description,
+			article.ImageURL,
+			article.URL,
+			article.SourceURL,
+			article.PublishedAt.Format(time.RFC3339), // Use a standard format
+			strconv.Itoa(article.Rank),
+			article.Category,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return counter.n, err
+		}
+		if written++; written%exportFlushRows == 0 {
+			if err := flushAll(); err != nil {
+				return counter.n, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		// The export is left unterminated, so that it cannot pass for a
+		// complete one.
+		return counter.n, err
+	}
+	if err := flushAll(); err != nil {
+		return counter.n, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return counter.n, err
+		}
+		if flush != nil {
+			flush()
+		}
+	}
+	return counter.n, nil
+}
+
+// acceptsGzip reports whether the client accepts gzip-encoded responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, coding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// exportETag identifies an article export by the newest article it contains
+// and a hash of its query, articles and encoding, e.g. "1234.9f86d081884c7d65".
+func exportETag(r *http.Request, state db.ExportState, compress bool) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\n%d\n%s\n%t", r.URL.RawQuery, state.Count, state.Revision, compress)
+	return fmt.Sprintf(`"%d.%016x"`, state.LastID, h.Sum64())
+}
+
+// exportETagID returns the newest article ID of an export ETag.
+func exportETagID(etag string) (int64, bool) {
+	id, _, ok := strings.Cut(strings.Trim(etag, `"`), ".")
+	if !ok {
+		return 0, false
+	}
+	lastID, err := strconv.ParseInt(id, 10, 64)
+	return lastID, err == nil && lastID > 0
+}
+
+// parseByteRange parses a Range header of a single byte range ("bytes=0-499",
+// "bytes=500-" or "bytes=-500") of a size byte representation, and returns its
+// first byte and length. It returns errRangeNotSatisfiable if the range lies
+// past the end, and another error if the header is invalid or lists several
+// ranges.
+func parseByteRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, errors.New("unsupported range")
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, errors.New("invalid range")
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("invalid range")
+		}
+		if n == 0 {
+			return 0, 0, errRangeNotSatisfiable
+		}
+		n = min(n, size)
+		return size - n, n, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("invalid range")
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return 0, 0, errors.New("invalid range")
+		}
+		end = min(end, size-1)
+	}
+	if start >= size {
+		return 0, 0, errRangeNotSatisfiable
+	}
+	return start, end - start + 1, nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// rangeWriter writes the remaining bytes to w after skipping skip bytes, and
+// fails with errRangeWritten once they are written.
+type rangeWriter struct {
+	w         io.Writer
+	skip      int64
+	remaining int64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if rw.skip >= int64(n) {
+		rw.skip -= int64(n)
+		return n, nil
+	}
+	p = p[rw.skip:]
+	rw.skip = 0
+	if int64(len(p)) > rw.remaining {
+		p = p[:rw.remaining]
+	}
+	if _, err := rw.w.Write(p); err != nil {
+		return 0, err
+	}
+	rw.remaining -= int64(len(p))
+	if rw.remaining == 0 {
+		return n, errRangeWritten
+	}
+	return n, nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCSVExport(t *testing.T, handler http.HandlerFunc, target string) [][]string {
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("GET", target, nil))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	require.NoError(t, err)
+	return records
+}
+
+func TestExportThreatHistory(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	now := time.Now().UTC()
+	require.NoError(t, db.TakeThreatSnapshot(0, now.Add(-72*time.Hour)))
+	require.NoError(t, db.TakeThreatSnapshot(0, now.Add(-time.Hour)))
+	require.NoError(t, db.TakeThreatSnapshot(5, now.Add(-time.Hour)))
+
+	records := readCSVExport(t, ExportThreatHistory, "/export/threat-history")
+	require.Len(t, records, 3, "the header and the shared feed's snapshots")
+	assert.Equal(t, []string{"TakenAt", "ThreatLevel", "ComputedLevel", "HighRankCount", "MediumRankCount", "LowRankCount", "TotalArticles"}, records[0])
+	assert.Equal(t, now.Add(-72*time.Hour).Format(time.RFC3339), records[1][0])
+	assert.NotEmpty(t, records[1][1])
+
+	records = readCSVExport(t, ExportThreatHistory, "/export/threat-history?window=24h")
+	assert.Len(t, records, 2)
+}
+
+func TestExportIngestCyclesAndSources(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, db.RecordIngestCycleForTest(started, started.Add(90*time.Second), map[string]error{"src1": nil, "src2": errors.New("timeout")}))
+
+	records := readCSVExport(t, ExportIngestCycles, "/export/ingest-cycles")
+	require.Len(t, records, 2)
+	assert.Equal(t, []string{"2024-05-01T12:00:00Z", "2024-05-01T12:01:30Z", "90.0", "2", "1", "0"}, records[1])
+
+	records = readCSVExport(t, ExportSourceStats, "/export/sources")
+	require.Len(t, records, 3)
+	assert.Equal(t, "SourceURL", records[0][0])
+	assert.Equal(t, []string{"src1", "", "Cybersecurity", "2", "2", "9.00"}, records[1][:6])
+	assert.Equal(t, "2024-05-01T12:01:30Z", records[1][8])
+	assert.Equal(t, "timeout", records[2][9])
+}
+
+func TestExportCSVResume(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rr := httptest.NewRecorder()
+		ExportCSV(rr, req)
+		return rr
+	}
+
+	full := get("/export/csv", nil)
+	require.Equal(t, http.StatusOK, full.Code)
+	assert.Equal(t, "bytes", full.Header().Get("Accept-Ranges"))
+	etag := full.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	// Compressed exports hold the same CSV.
+	compressed := get("/export/csv", http.Header{"Accept-Encoding": {"deflate, gzip"}})
+	require.Equal(t, http.StatusOK, compressed.Code)
+	assert.Equal(t, "gzip", compressed.Header().Get("Content-Encoding"))
+	assert.NotEqual(t, etag, compressed.Header().Get("ETag"))
+	gz, err := gzip.NewReader(compressed.Body)
+	require.NoError(t, err)
+	plain, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, full.Body.String(), string(plain))
+
+	// A new article does not change the rest of an earlier export.
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Fresh Article", URL: "u-fresh", SourceURL: "src1", Category: "Cybersecurity", PublishedAt: time.Now()}))
+	size := full.Body.Len()
+	rest := get("/export/csv", http.Header{"Range": {"bytes=10-"}, "If-Range": {etag}})
+	require.Equal(t, http.StatusPartialContent, rest.Code)
+	assert.Equal(t, fmt.Sprintf("bytes 10-%d/%d", size-1, size), rest.Header().Get("Content-Range"))
+	assert.Equal(t, full.Body.String()[10:], rest.Body.String())
+	assert.Equal(t, etag, rest.Header().Get("ETag"))
+
+	// Changed exports are sent whole.
+	changed := get("/export/csv", http.Header{"Range": {"bytes=10-"}, "If-Range": {`"1.0000000000000000"`}})
+	require.Equal(t, http.StatusOK, changed.Code)
+	assert.Contains(t, changed.Body.String(), "Fresh Article")
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+
+	tail := get("/export/csv", http.Header{"Range": {"bytes=-5"}})
+	require.Equal(t, http.StatusPartialContent, tail.Code)
+	assert.Equal(t, changed.Body.String()[changed.Body.Len()-5:], tail.Body.String())
+
+	unsatisfiable := get("/export/csv", http.Header{"Range": {"bytes=100000-"}})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, unsatisfiable.Code)
+	assert.Equal(t, fmt.Sprintf("bytes */%d", changed.Body.Len()), unsatisfiable.Header().Get("Content-Range"))
+
+	// since sends the articles published from then on, oldest first.
+	records, err := csv.NewReader(strings.NewReader(changed.Body.String())).ReadAll()
+	require.NoError(t, err)
+	since := records[3][5]
+	records = readCSVExport(t, ExportCSV, "/export/csv?since="+url.QueryEscape(since))
+	require.Len(t, records, 4)
+	assert.Equal(t, []string{"Tech Article 1", "Cyber Article 1", "Fresh Article"}, []string{records[1][0], records[2][0], records[3][0]})
+
+	rr := httptest.NewRecorder()
+	ExportCSV(rr, httptest.NewRequest("GET", "/export/csv?since=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestWriteArticlesCSVFailsOnCancel(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err := db.StreamArticles(ctx, db.ArticleFilter{IncludeDead: true})
+	require.NoError(t, err)
+	defer rows.Close()
+	cancel()
+	// The rows are closed when the cancellation is noticed.
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = writeArticlesCSV(io.Discard, rows, true, nil)
+	assert.ErrorIs(t, err, context.Canceled, "a cut off export must fail rather than end like a complete one")
+}
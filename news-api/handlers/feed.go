@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+const (
+	// feedArchivePageSize is the number of article IDs covered by each archive
+	// page. Page N holds the articles with IDs from (N-1)*size+1 to N*size, so
+	// a page never changes once it is full.
+	feedArchivePageSize = 100
+	// feedEntries is the number of articles in the subscription feed.
+	feedEntries = 50
+	// feedArchiveMaxAge and feedSubscriptionMaxAge are how long, in seconds,
+	// archive pages and the subscription feed may be cached.
+	feedArchiveMaxAge      = "86400"
+	feedSubscriptionMaxAge = "300"
+
+	atomNamespace        = "http://www.w3.org/2005/Atom"
+	feedHistoryNamespace = "http://purl.org/syndication/history/1.0"
+)
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Namespace string      `xml:"xmlns,attr"`
+	History   string      `xml:"xmlns:fh,attr,omitempty"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Archive   *struct{}   `xml:"fh:archive"`
+	Links     []atomLink  `xml:"link"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Link      atomLink      `xml:"link"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Author    *atomAuthor   `xml:"author"`
+	Category  *atomCategory `xml:"category"`
+	Summary   string        `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// GetFeed serves the caller's articles as an Atom feed, paged as an archived
+// feed (RFC 5005). /feed.xml is the subscription document with the latest
+// articles, and /feed.xml?page=N the archive pages holding the complete
+// history, oldest first. Only full pages are published, so an archive page
+// never changes and crawlers fetch each of them once.
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	page := params.intRange("page", 0, 1, math.MaxInt)
+	if !params.valid(w) {
+		return
+	}
+	lastID, err := db.LastArticleID()
+	if err != nil {
+		log.Printf("Error fetching last article ID: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	archived := int(lastID / feedArchivePageSize)
+	if page > archived {
+		WriteError(w, http.StatusNotFound, "Archive page not found")
+		return
+	}
+
+	filter := db.ArticleFilter{OrgID: OrgFromContext(r.Context()), IncludeDead: true, SortBy: "id"}
+	if page == 0 {
+		filter.Limit = feedEntries
+	} else {
+		filter.AfterID = int64(page-1) * feedArchivePageSize
+		filter.BeforeID = int64(page)*feedArchivePageSize + 1
+	}
+	articles, err := db.QueryArticles(filter)
+	if err != nil {
+		log.Printf("Error fetching articles for feed: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	base := baseURL(r)
+	feed := atomFeed{
+		Namespace: atomNamespace,
+		History:   feedHistoryNamespace,
+		ID:        base + "/feed.xml",
+		Title:     "Threatfeed",
+		Author:    atomAuthor{Name: "Threatfeed"},
+		Links:     []atomLink{{Rel: "current", Href: base + "/feed.xml"}},
+		Entries:   make([]atomEntry, 0, len(articles)),
+	}
+	var updated time.Time
+	for _, article := range articles {
+		feed.Entries = append(feed.Entries, newAtomEntry(base, article))
+		if article.IngestedAt.After(updated) {
+			updated = article.IngestedAt
+		}
+	}
+	if updated.IsZero() {
+		// An empty page still needs a date, and must not change over time.
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	maxAge := feedSubscriptionMaxAge
+	if page == 0 {
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: base + "/feed.xml"})
+		if archived > 0 {
+			feed.Links = append(feed.Links, atomLink{Rel: "prev-archive", Href: feedPageURL(base, archived)})
+		}
+	} else {
+		maxAge = feedArchiveMaxAge
+		feed.Archive = &struct{}{}
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: feedPageURL(base, page)})
+		if page > 1 {
+			feed.Links = append(feed.Links, atomLink{Rel: "prev-archive", Href: feedPageURL(base, page-1)})
+		}
+		if page < archived {
+			feed.Links = append(feed.Links, atomLink{Rel: "next-archive", Href: feedPageURL(base, page+1)})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age="+maxAge)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("Error encoding feed: %v", err)
+	}
+}
+
+func newAtomEntry(base string, article models.NewsArticle) atomEntry {
+	entry := atomEntry{
+		ID:        base + "/news/" + strconv.FormatInt(article.ID, 10),
+		Title:     article.Title,
+		Link:      atomLink{Rel: "alternate", Href: article.URL},
+		Published: article.PublishedAt.UTC().Format(time.RFC3339),
+		Updated:   article.PublishedAt.UTC().Format(time.RFC3339),
+		Summary:   article.Description,
+	}
+	if article.Author != "" {
+		entry.Author = &atomAuthor{Name: article.Author}
+	}
+	if article.Category != "" {
+		entry.Category = &atomCategory{Term: article.Category}
+	}
+	return entry
+}
+
+func feedPageURL(base string, page int) string {
+	return base + "/feed.xml?page=" + strconv.Itoa(page)
+}
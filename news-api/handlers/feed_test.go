@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFeed(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	// Article IDs are never reused, so the pages already taken by articles of
+	// other tests stay archived and empty.
+	lastID, err := db.LastArticleID()
+	require.NoError(t, err)
+	archived := int(lastID/feedArchivePageSize) + 2
+	for id := lastID + 1; id <= int64(archived*feedArchivePageSize+1); id++ {
+		require.NoError(t, db.InsertArticle(models.NewsArticle{
+			Title: fmt.Sprintf("Story %d", id), URL: fmt.Sprintf("https://example.com/%d", id), SourceURL: "s1",
+			Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour),
+		}))
+	}
+
+	get := func(query string) (atomFeed, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		GetFeed(rr, httptest.NewRequest("GET", "/feed.xml"+query, nil))
+		var feed atomFeed
+		if rr.Code == http.StatusOK {
+			require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &feed))
+		}
+		return feed, rr
+	}
+	links := func(feed atomFeed) map[string]string {
+		rels := map[string]string{}
+		for _, link := range feed.Links {
+			rels[link.Rel] = link.Href
+		}
+		return rels
+	}
+	pageURL := func(page int) string {
+		return "http://example.com/feed.xml?page=" + strconv.Itoa(page)
+	}
+
+	feed, rr := get("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.NotContains(t, rr.Body.String(), "fh:archive")
+	assert.Len(t, feed.Entries, feedEntries)
+	assert.Equal(t, fmt.Sprintf("Story %d", archived*feedArchivePageSize+1), feed.Entries[0].Title)
+	assert.Equal(t, map[string]string{
+		"current":      "http://example.com/feed.xml",
+		"self":         "http://example.com/feed.xml",
+		"prev-archive": pageURL(archived),
+	}, links(feed))
+
+	feed, rr = get("?page=" + strconv.Itoa(archived))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `xmlns:fh="http://purl.org/syndication/history/1.0"`)
+	assert.Contains(t, rr.Body.String(), "<fh:archive></fh:archive>")
+	assert.Equal(t, "public, max-age="+feedArchiveMaxAge, rr.Header().Get("Cache-Control"))
+	require.Len(t, feed.Entries, feedArchivePageSize)
+	assert.Equal(t, fmt.Sprintf("Story %d", archived*feedArchivePageSize), feed.Entries[0].Title)
+	assert.Equal(t, fmt.Sprintf("http://example.com/news/%d", archived*feedArchivePageSize), feed.Entries[0].ID)
+	assert.Equal(t, map[string]string{
+		"current":      "http://example.com/feed.xml",
+		"self":         pageURL(archived),
+		"prev-archive": pageURL(archived - 1),
+	}, links(feed))
+
+	feed, _ = get("?page=" + strconv.Itoa(archived-1))
+	assert.Equal(t, pageURL(archived), links(feed)["next-archive"])
+
+	_, rr = get("?page=" + strconv.Itoa(archived+1))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the page being filled is not published")
+	_, rr = get("?page=0")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// feedbackVotes maps the votes accepted by SetArticleFeedback to db votes.
+var feedbackVotes = map[string]int{"up": db.VoteUp, "down": db.VoteDown, "none": 0}
+
+type feedbackRequest struct {
+	Vote string `json:"vote"`
+}
+
+// SetArticleFeedback records the authenticated user's relevance vote on the
+// article {id}: {"vote": "up"}, {"vote": "down"}, or {"vote": "none"} to
+// retract it.
+func SetArticleFeedback(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req feedbackRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	vote, ok := feedbackVotes[req.Vote]
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "vote must be one of up, down, none")
+		return
+	}
+	err := db.SetFeedback(user.ID, articleID, vote)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording feedback: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type feedbackStatsResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	models.FeedbackStats
+}
+
+// GetFeedbackStats aggregates the relevance votes on the articles published
+// in the window, in total and per source and category. It accepts the window
+// parameters and the category and source filters of /stats/terms.
+func GetFeedbackStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	days := params.intRange("days", 7, 1, maxTermStatsDays)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	if endDate.IsZero() {
+		endDate = time.Now()
+	}
+	if startDate.IsZero() {
+		startDate = endDate.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	stats, err := db.GetFeedbackStats(db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      query.Get("source"),
+		Category:    query.Get("category"),
+		StartDate:   startDate,
+		EndDate:     endDate,
+		IncludeDead: true,
+	})
+	if err != nil {
+		log.Printf("Error aggregating feedback: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, feedbackStatsResponse{Start: startDate, End: endDate, FeedbackStats: stats})
+}
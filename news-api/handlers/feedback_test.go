@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleFeedback(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "SCADA exploit", URL: "u1", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour)}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	vote := func(user models.User, id, body string) int {
+		req := httptest.NewRequest("POST", "/news/"+id+"/feedback", bytes.NewBufferString(body))
+		req.SetPathValue("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, user))
+		rr := httptest.NewRecorder()
+		SetArticleFeedback(rr, req)
+		return rr.Code
+	}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := db.CreateUser(name, "correct horse battery")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, vote(user, id, `{"vote": "up"}`))
+		assert.Equal(t, http.StatusBadRequest, vote(user, id, `{"vote": "meh"}`))
+		assert.Equal(t, http.StatusNotFound, vote(user, "9999", `{"vote": "down"}`))
+	}
+
+	rr := httptest.NewRecorder()
+	GetFeedbackStats(rr, httptest.NewRequest("GET", "/stats/feedback?days=1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stats feedbackStatsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stats))
+	assert.Equal(t, 3, stats.Up)
+	assert.Equal(t, []models.FeedbackCount{{Key: "s1", Up: 3}}, stats.BySource)
+
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Vendor funding news", URL: "u2", Rank: 10, PublishedAt: time.Now().Add(-2 * time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Another SCADA flaw", URL: "u3", Rank: 5, PublishedAt: time.Now().Add(-3 * time.Hour)}))
+	FeedbackRanking = true
+	defer func() { FeedbackRanking = false }()
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest("GET", "/news?sortBy=rank&limit=2", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+	require.Len(t, articles, 2)
+	assert.Greater(t, articles[0].Rank, articles[1].Rank)
+	assert.Contains(t, articles[0].Title, "SCADA")
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+type recordFeedRequest struct {
+	SourceURL string `json:"sourceUrl"`
+	// Fetches is the number of fetches to record; it defaults to 1.
+	Fetches int `json:"fetches"`
+}
+
+type feedFixturesResponse struct {
+	Recordings []models.FeedRecording `json:"recordings"`
+	Fixtures   []models.FeedFixture   `json:"fixtures"`
+}
+
+// GetFeedFixtures lists the pending feed recordings and the recorded fixtures.
+func GetFeedFixtures(w http.ResponseWriter, r *http.Request) {
+	recordings, err := db.GetFeedRecordings()
+	if err != nil {
+		log.Printf("Error listing feed recordings: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	fixtures, err := db.GetFeedFixtures()
+	if err != nil {
+		log.Printf("Error listing feed fixtures: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, feedFixturesResponse{Recordings: recordings, Fixtures: fixtures})
+}
+
+// RecordFeed saves the raw responses of the next fetches of a source as
+// fixtures, for replaying them later.
+func RecordFeed(w http.ResponseWriter, r *http.Request) {
+	var req recordFeedRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	req.SourceURL = strings.TrimSpace(req.SourceURL)
+	if u, err := url.Parse(req.SourceURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		WriteError(w, http.StatusBadRequest, "sourceUrl must be an http or https URL")
+		return
+	}
+	if req.Fetches == 0 {
+		req.Fetches = 1
+	}
+	if req.Fetches < 1 || req.Fetches > 100 {
+		WriteError(w, http.StatusBadRequest, "fetches must be between 1 and 100")
+		return
+	}
+	err := db.RecordFeed(req.SourceURL, req.Fetches)
+	if errors.Is(err, db.ErrUnrecordableSource) {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error requesting a feed recording: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "fixtures.record", "source:"+req.SourceURL, nil, req)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ReplayFeedFixture runs the recorded fixture {name} through the caching
+// pipeline as a dry run and reports what would be stored. With ?store=true
+// the new articles are stored.
+func ReplayFeedFixture(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	store := params.boolean("store")
+	if !params.valid(w) {
+		return
+	}
+	name := r.PathValue("name")
+	report, err := db.ReplayFeedFixture(name, !store)
+	if errors.Is(err, os.ErrNotExist) {
+		WriteError(w, http.StatusNotFound, "Fixture not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error replaying fixture %s: %v", name, err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if store {
+		recordAudit(r, "fixtures.replay", "fixture:"+name, nil, map[string]int{"new": report.New, "duplicates": report.Duplicates})
+	}
+	writeJSON(w, http.StatusOK, report)
+}
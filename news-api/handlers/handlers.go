@@ -1,69 +1,127 @@
 package handlers
 
 import (
-	"encoding/csv"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"news-api/db"
-	"news-api/models"
 )
 
-func GetNews(w http.ResponseWriter, r *http.Request) {
-	// Get query parameters
-	sourceFilter := r.URL.Query().Get("source")
-	categoryFilter := r.URL.Query().Get("category") // New parameter
-	searchFilter := r.URL.Query().Get("search")
-	limitStr := r.URL.Query().Get("limit")
-	limit, _ := strconv.Atoi(limitStr)
-	if limit == 0 {
-		limit = 20 // Default limit
-	}
-	startDateStr := r.URL.Query().Get("start")
-	endDateStr := r.URL.Query().Get("end")
-	sortBy := r.URL.Query().Get("sortBy")
-
-	var startDate, endDate time.Time
-	var err error
-
-	if startDateStr != "" {
-		startDate, err = time.Parse("2006-01-02", startDateStr)
-		if err != nil {
-			http.Error(w, "Invalid start date format", http.StatusBadRequest)
-			return
-		}
-	}
+var (
+	// DefaultPageSize is the number of articles a list endpoint returns when
+	// no limit is given.
+	DefaultPageSize = 20
+	// MaxPageSize is the most articles a list endpoint returns at once. Larger
+	// result sets are paged with offset.
+	MaxPageSize = 1000
+)
 
-	if endDateStr != "" {
-		endDate, err = time.Parse("2006-01-02", endDateStr)
-		if err != nil {
-			http.Error(w, "Invalid end date format", http.StatusBadRequest)
-			return
-		}
-		// Add 23 hours, 59 minutes, and 59 seconds to the end date to include the entire day.
-		endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+// GetNews lists articles matching the query parameters, newest first unless
+// sortBy says otherwise. Invalid parameters are rejected with 400. The ranks
+// are adjusted by rerank unless personalize=false.
+func GetNews(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	limit := params.limit(DefaultPageSize, MaxPageSize)
+	offset := params.offset()
+	sortBy := params.oneOf("sortBy", db.ArticleSortKeys...)
+	minRank := params.integer("minRank", 0)
+	minSeverity := params.intRange("minSeverity", 0, 0, 100)
+	includeDead := params.boolean("includeDead")
+	startDate, endDate := params.dateRange()
+	dateField := params.oneOf("dateField", "publishedAt", "ingestedAt")
+	sector := params.oneOf("sector", db.Sectors.Names()...)
+	mediaType := params.oneOf("mediaType", db.MediaTypes...)
+	paywalled := params.optionalBoolean("paywalled")
+	personalize := params.oneOf("personalize", "true", "false") != "false"
+	if !params.valid(w) {
+		return
 	}
 
-	articles, err := db.GetArticlesFromDB(sourceFilter, categoryFilter, searchFilter, limit, startDate, endDate, sortBy) // Pass categoryFilter
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      r.URL.Query().Get("source"),
+		Category:    r.URL.Query().Get("category"),
+		Search:      r.URL.Query().Get("search"),
+		Author:      r.URL.Query().Get("author"),
+		Tag:         r.URL.Query().Get("tag"),
+		Sector:      sector,
+		MediaType:   mediaType,
+		Paywalled:   paywalled,
+		MinRank:     minRank,
+		MinSeverity: minSeverity,
+		IncludeDead: includeDead,
+		Limit:       limit,
+		Offset:      offset,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		ByIngestion: dateField == "ingestedAt",
+		SortBy:      sortBy,
+	})
 	if err != nil {
 		log.Printf("Error fetching articles from DB: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
+	if personalize {
+		if err := rerank(r, articles); err != nil {
+			log.Printf("Error re-ranking articles: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if sortBy == "rank" {
+			db.SortByRank(articles)
+		}
+	}
+	setNextPageLink(w, r, offset, limit, len(articles))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(articles)
 }
 
+// GetArticle returns the article {id}. With ?expand=content the response
+// includes the article's full text, which long descriptions are truncated from.
+func GetArticle(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	withContent := false
+	if expand := r.URL.Query().Get("expand"); expand != "" {
+		for _, field := range strings.Split(expand, ",") {
+			if strings.TrimSpace(field) != "content" {
+				WriteError(w, http.StatusBadRequest, "Unsupported expand field: "+field)
+				return
+			}
+			withContent = true
+		}
+	}
+	article, err := db.GetOrgArticle(OrgFromContext(r.Context()), articleID, withContent)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, article)
+}
 
 func GetTodayThreat(w http.ResponseWriter, r *http.Request) {
-	threatScore, err := db.GetTodayThreatScore()
+	threatScore, err := db.GetOrgThreatScore(OrgFromContext(r.Context()))
 	if err != nil {
 		log.Printf("Error getting today's threat score: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
@@ -71,57 +129,208 @@ func GetTodayThreat(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(threatScore)
 }
 
+// GetTodayThreatExplanation returns today's threat score with the articles in
+// each bucket and the keyword hits behind their ranks.
+func GetTodayThreatExplanation(w http.ResponseWriter, r *http.Request) {
+	explanation, err := db.GetOrgThreatExplanation(OrgFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Error explaining today's threat score: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, explanation)
+}
+
+// ExportCSV downloads the caller's articles as CSV. It accepts the source,
+// category, tag, minSeverity and date filters of /news, and names the file
+// after them and the export time. The export is streamed, flushed every few
+// hundred articles, and compressed if the client accepts gzip. An interrupted
+// download resumes with a Range and an If-Range of its ETag, which leaves out
+// the articles stored since, or with since.
 func ExportCSV(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	filter := db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      r.URL.Query().Get("source"),
+		Category:    r.URL.Query().Get("category"),
+		Tag:         r.URL.Query().Get("tag"),
+		MinSeverity: params.intRange("minSeverity", 0, 0, 100),
+		IncludeDead: true,
+	}
+	filter.StartDate, filter.EndDate = params.dateRange()
+	// since resumes an export from the PublishedAt of the last article
+	// received: articles are sent oldest first, from that time on. The
+	// articles published at that time are sent again, for the client to skip.
+	if value := r.URL.Query().Get("since"); value != "" {
+		if since, _, err := parseDateParam(value); err != nil {
+			params.invalid("since must be an RFC3339 timestamp or a YYYY-MM-DD date")
+		} else {
+			if since.After(filter.StartDate) {
+				filter.StartDate = since
+			}
+			filter.SortBy = "publishedAtAsc"
+		}
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	compress := acceptsGzip(r)
+	state, err := db.GetExportState(filter)
+	if err != nil {
+		log.Printf("Error getting export state from DB: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	etag := exportETag(r, state, compress)
+	byteRange := r.Header.Get("Range")
+	if ifRange := r.Header.Get("If-Range"); byteRange != "" && ifRange != "" && ifRange != etag {
+		// The client resumes an earlier export. Articles stored since are
+		// left out, so the rest of it is sent if its articles did not change.
+		byteRange = ""
+		if lastID, ok := exportETagID(ifRange); ok && lastID < state.LastID {
+			earlier := filter
+			earlier.BeforeID = lastID + 1
+			if earlierState, err := db.GetExportState(earlier); err == nil && exportETag(r, earlierState, compress) == ifRange {
+				state, etag, byteRange = earlierState, ifRange, r.Header.Get("Range")
+			}
+		}
+	}
+	if state.LastID > 0 {
+		filter.BeforeID = state.LastID + 1
+	}
+
 	// Set headers to prompt for file download.
 	w.Header().Set("Content-Type", "text/csv")
-	w.Header().Set("Content-Disposition", `attachment; filename="articles.csv"`)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exportFilename(r, time.Now())+`"`)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Accept-Encoding")
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+
+	var size int64
+	if byteRange != "" {
+		// The length of the export is only known once it is written, so it
+		// is written twice.
+		rows, err := db.StreamArticles(r.Context(), filter)
+		if err != nil {
+			log.Printf("Error getting articles stream from DB: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		size, err = writeArticlesCSV(io.Discard, rows, compress, nil)
+		rows.Close()
+		if err != nil {
+			log.Printf("Error measuring CSV export: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
 
-	rows, err := db.GetAllArticlesStream()
+	rows, err := db.StreamArticles(r.Context(), filter)
 	if err != nil {
 		log.Printf("Error getting articles stream from DB: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 	defer rows.Close()
 
-	csvWriter := csv.NewWriter(w)
-	defer csvWriter.Flush()
+	var out io.Writer = w
+	if byteRange != "" {
+		start, length, err := parseByteRange(byteRange, size)
+		switch {
+		case errors.Is(err, errRangeNotSatisfiable):
+			w.Header().Del("Content-Encoding")
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			WriteError(w, http.StatusRequestedRangeNotSatisfiable, "Requested range not satisfiable")
+			return
+		case err == nil:
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, size))
+			w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+			w.WriteHeader(http.StatusPartialContent)
+			out = &rangeWriter{w: w, skip: start, remaining: length}
+		}
+		// Other Range headers are ignored and the whole export is sent.
+	}
 
-	// Write CSV header
-	headers := []string{"Title", "Description", "ImageURL", "URL", "SourceURL", "PublishedAt", "Rank", "Category"}
-	if err := csvWriter.Write(headers); err != nil {
-		log.Printf("Error writing CSV header: %v", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	controller := http.NewResponseController(w)
+	extendDeadline := func() {
+		if ExportWriteTimeout > 0 {
+			controller.SetWriteDeadline(time.Now().Add(ExportWriteTimeout))
+		}
+	}
+	flush := func() {
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		extendDeadline()
+	}
+	extendDeadline()
+	written, err := writeArticlesCSV(out, rows, compress, flush)
+	if err == nil || errors.Is(err, errRangeWritten) {
 		return
 	}
-
-	// Write rows
-	for rows.Next() {
-		var article models.NewsArticle
-		if err := rows.Scan(&article.Title, &article.Description, &article.ImageURL, &article.URL, &article.SourceURL, &article.PublishedAt, &article.Rank, &article.Category); err != nil {
-			log.Printf("Error scanning article row for CSV export: %v", err)
-			continue // Skip bad rows
+	log.Printf("Error writing CSV export: %v", err)
+	if written == 0 && out == io.Writer(w) {
+		// Nothing was sent yet.
+		for _, name := range []string{"Content-Disposition", "Content-Encoding", "Accept-Ranges", "ETag"} {
+			w.Header().Del(name)
 		}
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	// Ending the response would pass the export off as complete, so the
+	// connection is aborted instead.
+	panic(http.ErrAbortHandler)
+}
 
-		record := []string{
-			article.Title,
-			article.Description,
-			article.ImageURL,
-			article.URL,
-			article.SourceURL,
-			article.PublishedAt.Format(time.RFC3339), // Use a standard format
-			strconv.Itoa(article.Rank),
-			article.Category,
+// exportFilename names a CSV export after its filters and time, e.g.
+// articles_cybersecurity_from-20240501_exported-20240502T093000Z.csv.
+func exportFilename(r *http.Request, now time.Time) string {
+	query := r.URL.Query()
+	parts := []string{"articles"}
+	if category := query.Get("category"); category != "" && category != "all" {
+		parts = append(parts, filenamePart(category))
+	}
+	if source := query.Get("source"); source != "" && source != "all" {
+		if u, err := url.Parse(source); err == nil && u.Host != "" {
+			source = u.Host
 		}
-		if err := csvWriter.Write(record); err != nil {
-			log.Printf("Error writing CSV record: %v", err)
-			// The connection might be broken, so we can't send another HTTP error.
-			// We just log and stop.
-			return
+		parts = append(parts, filenamePart(source))
+	}
+	if tag := query.Get("tag"); tag != "" {
+		parts = append(parts, "tag-"+filenamePart(tag))
+	}
+	if minSeverity := query.Get("minSeverity"); minSeverity != "" {
+		parts = append(parts, "severity-"+filenamePart(minSeverity))
+	}
+	if window := query.Get("window"); window != "" {
+		parts = append(parts, "last-"+filenamePart(window))
+	}
+	for _, bound := range []string{"start", "end"} {
+		if value := query.Get(bound); value != "" {
+			t, _, _ := parseDateParam(value)
+			label := map[string]string{"start": "from-", "end": "to-"}[bound]
+			parts = append(parts, label+t.Format("20060102"))
 		}
 	}
+	parts = append(parts, "exported-"+now.UTC().Format("20060102T150405Z"))
+	return strings.Join(parts, "_") + ".csv"
+}
 
-	if err := rows.Err(); err != nil {
-		log.Printf("Error iterating article rows for CSV export: %v", err)
+// filenamePart reduces a filter value to lowercase letters, digits, dots and
+// dashes, so that it is safe in a Content-Disposition filename.
+func filenamePart(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "-"):
+			b.WriteByte('-')
+		}
 	}
+	return strings.Trim(b.String(), "-.")
 }
@@ -168,6 +168,28 @@ func TestGetNewsInvalidDate(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rr.Code)
 }
 
+func TestGetNewsDateOffsets(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	// Published at 23:30 in New York on March 1st, which is March 2nd in UTC.
+	newYork := time.FixedZone("EST", -5*60*60)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Evening", URL: "u1", SourceURL: "src", PublishedAt: time.Date(2024, 3, 1, 23, 30, 0, 0, newYork)}))
+
+	count := func(query string) int {
+		rr := httptest.NewRecorder()
+		GetNews(rr, httptest.NewRequest("GET", "/news?"+query, nil))
+		require.Equal(t, http.StatusOK, rr.Code, query)
+		var articles []models.NewsArticle
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+		return len(articles)
+	}
+
+	assert.Equal(t, 1, count("start=2024-03-01T23:00:00-05:00&end=2024-03-01T23:59:59-05:00"))
+	assert.Equal(t, 0, count("start=2024-03-01T22:00:00-05:00&end=2024-03-01T23:00:00-05:00"))
+	assert.Equal(t, 1, count("start=2024-03-02&end=2024-03-02"))
+	assert.Equal(t, 0, count("start=2024-03-01&end=2024-03-01"))
+}
+
 func TestGetTodayThreat(t *testing.T) {
 	setupTestDB(t)
 	seedArticles(t) // Seeds articles with various ranks and timestamps
@@ -186,13 +208,14 @@ func TestGetTodayThreat(t *testing.T) {
 	require.NoError(t, err)
 
 	// Based on seedArticles (only recent articles count towards today's threat):
-	// Recent: Cyber Article 1 (rank 10), Tech Article 1 (rank 5), Cyber Article 2 (rank 8)
-	// High (rank >= 5): 3
-	// Medium (2 <= rank < 5): 0
-	// Low (rank < 2): 0
+	// Recent: Cyber Article 1 (rank 10, severity 50), Tech Article 1 (rank 5, severity 13),
+	// Cyber Article 2 (rank 8, severity 40)
+	// High (severity >= 25): 2
+	// Medium (10 <= severity < 25): 1
+	// Low (severity < 10): 0
 	// Total: 3
-	assert.Equal(t, 3, threatScore.HighRankCount)
-	assert.Equal(t, 0, threatScore.MediumRankCount)
+	assert.Equal(t, 2, threatScore.HighRankCount)
+	assert.Equal(t, 1, threatScore.MediumRankCount)
 	assert.Equal(t, 0, threatScore.LowRankCount)
 	assert.Equal(t, 3, threatScore.TotalArticles)
 	assert.Equal(t, "Code Red", threatScore.ThreatLevel)
@@ -212,7 +235,7 @@ func TestExportCSV(t *testing.T) {
 	// Check status and headers
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
-	assert.Equal(t, `attachment; filename="articles.csv"`, rr.Header().Get("Content-Disposition"))
+	assert.Regexp(t, `^attachment; filename="articles_exported-\d{8}T\d{6}Z\.csv"$`, rr.Header().Get("Content-Disposition"))
 
 	// Check CSV content
 	body := rr.Body.String()
@@ -220,3 +243,107 @@ func TestExportCSV(t *testing.T) {
 	assert.Contains(t, body, "Cyber Article 1,", "CSV should contain data from seeded articles")
 	assert.Contains(t, body, "Tech Article 1,", "CSV should contain data from seeded articles")
 }
+
+func TestExportCSVFilters(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+
+	rr := httptest.NewRecorder()
+	ExportCSV(rr, httptest.NewRequest("GET", "/export/csv?category=Tech&source=src2&start=2020-01-01&end=2099-12-31", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Regexp(t, `^attachment; filename="articles_tech_src2_from-20200101_to-20991231_exported-\d{8}T\d{6}Z\.csv"$`, rr.Header().Get("Content-Disposition"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "Tech Article 1,")
+	assert.NotContains(t, body, "Cyber Article 1,")
+
+	now := time.Date(2024, 5, 2, 9, 30, 0, 0, time.UTC)
+	req := httptest.NewRequest("GET", "/export/csv?source=https://www.bleepingcomputer.com/feed/&tag=CVE-2024-1&window=7d", nil)
+	assert.Equal(t, "articles_www.bleepingcomputer.com_tag-cve-2024-1_last-7d_exported-20240502T093000Z.csv", exportFilename(req, now))
+
+	rr = httptest.NewRecorder()
+	ExportCSV(rr, httptest.NewRequest("GET", "/export/csv?start=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetTodayThreatExplanation(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+
+	rr := httptest.NewRecorder()
+	GetTodayThreatExplanation(rr, httptest.NewRequest("GET", "/today-threat/explain", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var explanation db.ThreatExplanation
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&explanation))
+	assert.Equal(t, "Code Red", explanation.ThreatLevel)
+	require.Len(t, explanation.High, 2)
+	assert.Equal(t, "Cyber Article 1", explanation.High[0].Title)
+	require.Len(t, explanation.Medium, 1)
+	assert.Equal(t, "Tech Article 1", explanation.Medium[0].Title)
+	assert.Empty(t, explanation.Low)
+}
+
+func TestGetArticle(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Long", Description: "Attackers…", Content: "Attackers exploited the flaw.", URL: "u1", SourceURL: "src", PublishedAt: time.Now()}))
+	articles, err := db.QueryArticles(db.ArticleFilter{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	get := func(id, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/news/"+id+query, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		GetArticle(rr, req)
+		return rr
+	}
+
+	rr := get(id, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var article models.NewsArticle
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&article))
+	assert.Equal(t, "Attackers…", article.Description)
+	assert.Empty(t, article.Content)
+
+	rr = get(id, "?expand=content")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&article))
+	assert.Equal(t, "Attackers exploited the flaw.", article.Content)
+
+	assert.Equal(t, http.StatusBadRequest, get(id, "?expand=body").Code)
+	assert.Equal(t, http.StatusNotFound, get("999999", "").Code)
+}
+
+func TestGetNewsWindow(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+
+	count := func(query string) int {
+		rr := httptest.NewRecorder()
+		GetNews(rr, httptest.NewRequest("GET", "/news?"+query, nil))
+		require.Equal(t, http.StatusOK, rr.Code, query)
+		var articles []models.NewsArticle
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+		return len(articles)
+	}
+	assert.Equal(t, 1, count("window=90m"))
+	assert.Equal(t, 3, count("window=24h"))
+	assert.Equal(t, 4, count("window=7d"))
+	assert.Equal(t, 4, count("window=1w"))
+
+	for _, query := range []string{"window=0h", "window=-1d", "window=xd", "window=soon", "window=1d&start=2024-01-01"} {
+		rr := httptest.NewRecorder()
+		GetNews(rr, httptest.NewRequest("GET", "/news?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestGetNewsInvalidDateField(t *testing.T) {
+	setupTestDB(t)
+
+	rr := httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest("GET", "/news?dateField=updatedAt", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+var (
+	// MaxIngestAge is how long ago the last caching cycle may have finished
+	// for /readyz to report ready. 0 disables the check.
+	MaxIngestAge = time.Hour
+	// SourceStaleAfter is how long a source may go without a successful fetch
+	// before /readyz counts it as stale.
+	SourceStaleAfter = 6 * time.Hour
+)
+
+type readinessResponse struct {
+	Status       string              `json:"status"`
+	Database     string              `json:"database"`
+	LastCycle    *models.IngestCycle `json:"lastCycle,omitempty"`
+	Sources      int                 `json:"sources"`
+	StaleSources []string            `json:"staleSources"`
+}
+
+// GetReadiness reports whether the service is ready to serve fresh news: the
+// database is reachable and a caching cycle finished within MaxIngestAge. It
+// answers 503 otherwise. Sources without a successful fetch within
+// SourceStaleAfter are listed but do not fail the check.
+func GetReadiness(w http.ResponseWriter, r *http.Request) {
+	resp := readinessResponse{Status: "ok", Database: "ok", StaleSources: []string{}}
+	status := http.StatusOK
+	if err := db.Ping(); err != nil {
+		log.Printf("Readiness check: database unreachable: %v", err)
+		resp.Status, resp.Database = "unavailable", "unreachable"
+		writeJSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+
+	cycle, err := db.GetLastIngestCycle()
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if MaxIngestAge > 0 {
+			resp.Status, status = "no ingestion yet", http.StatusServiceUnavailable
+		}
+	case err != nil:
+		log.Printf("Error getting the last caching cycle: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	default:
+		resp.LastCycle = &cycle
+		if MaxIngestAge > 0 && time.Since(cycle.FinishedAt) > MaxIngestAge {
+			resp.Status, status = "ingestion stale", http.StatusServiceUnavailable
+		}
+	}
+
+	health, err := db.GetSourceHealth()
+	if err != nil {
+		log.Printf("Error getting source health: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	resp.Sources = len(health)
+	for _, h := range health {
+		if h.LastSuccessAt == nil || time.Since(*h.LastSuccessAt) > SourceStaleAfter {
+			resp.StaleSources = append(resp.StaleSources, h.URL)
+		}
+	}
+	writeJSON(w, status, resp)
+}
+
+// Metrics exposes the ingestion freshness in the Prometheus text format, so
+// that alerts fire when feeds silently stop being fetched.
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	health, err := db.GetSourceHealth()
+	if err != nil {
+		log.Printf("Error getting source health: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	cycle, err := db.GetLastIngestCycle()
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting the last caching cycle: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	now := time.Now()
+
+	var b strings.Builder
+	metric := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name, source string, value float64) {
+		if source != "" {
+			fmt.Fprintf(&b, "%s{source=%q} %s\n", name, source, formatMetric(value))
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", name, formatMetric(value))
+		}
+	}
+
+	metric("threatfeed_source_last_success_timestamp_seconds", "Unix time of the last successful fetch of the source.")
+	for _, h := range health {
+		if h.LastSuccessAt != nil {
+			sample("threatfeed_source_last_success_timestamp_seconds", h.URL, float64(h.LastSuccessAt.Unix()))
+		}
+	}
+	metric("threatfeed_source_seconds_since_last_success", "Seconds since the last successful fetch of the source, +Inf if it never succeeded.")
+	for _, h := range health {
+		age := math.Inf(1)
+		if h.LastSuccessAt != nil {
+			age = now.Sub(*h.LastSuccessAt).Seconds()
+		}
+		sample("threatfeed_source_seconds_since_last_success", h.URL, age)
+	}
+	metric("threatfeed_source_up", "Whether the last fetch of the source succeeded.")
+	for _, h := range health {
+		up := 1.0
+		if h.LastError != "" {
+			up = 0
+		}
+		sample("threatfeed_source_up", h.URL, up)
+	}
+	metric("threatfeed_source_last_cycle_articles", "Articles stored from the source in its last successful fetch.")
+	for _, h := range health {
+		sample("threatfeed_source_last_cycle_articles", h.URL, float64(h.NewArticles))
+	}
+	if err == nil {
+		metric("threatfeed_ingest_last_cycle_timestamp_seconds", "Unix time the last caching cycle finished.")
+		sample("threatfeed_ingest_last_cycle_timestamp_seconds", "", float64(cycle.FinishedAt.Unix()))
+		metric("threatfeed_ingest_last_cycle_articles", "Articles ingested in the last caching cycle.")
+		sample("threatfeed_ingest_last_cycle_articles", "", float64(cycle.Articles))
+		metric("threatfeed_ingest_last_cycle_failed_sources", "Sources that could not be fetched in the last caching cycle.")
+		sample("threatfeed_ingest_last_cycle_failed_sources", "", float64(cycle.FailedSources))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
+
+// formatMetric formats a sample value the way Prometheus expects.
+func formatMetric(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/db"
+)
+
+func TestReadinessAndMetrics(t *testing.T) {
+	setupTestDB(t)
+
+	rr := httptest.NewRecorder()
+	GetReadiness(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "not ready before the first caching cycle")
+
+	finished := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, db.RecordIngestCycleForTest(finished.Add(-time.Minute), finished, map[string]error{
+		"https://example.com/feed": nil,
+		"https://down.example/rss": assert.AnError,
+	}))
+
+	defer func(age time.Duration) { MaxIngestAge = age }(MaxIngestAge)
+	MaxIngestAge = time.Hour
+	rr = httptest.NewRecorder()
+	GetReadiness(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code, "the last cycle is too old")
+
+	MaxIngestAge = 3 * time.Hour
+	rr = httptest.NewRecorder()
+	GetReadiness(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp readinessResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Sources)
+	assert.Equal(t, []string{"https://down.example/rss"}, resp.StaleSources)
+	require.NotNil(t, resp.LastCycle)
+	assert.Equal(t, 1, resp.LastCycle.FailedSources)
+
+	rr = httptest.NewRecorder()
+	Metrics(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, `threatfeed_source_up{source="https://example.com/feed"} 1`)
+	assert.Contains(t, body, `threatfeed_source_up{source="https://down.example/rss"} 0`)
+	assert.Contains(t, body, `threatfeed_source_seconds_since_last_success{source="https://down.example/rss"} +Inf`)
+	assert.Contains(t, body, "threatfeed_ingest_last_cycle_failed_sources 1")
+	assert.Contains(t, body, "# TYPE threatfeed_ingest_last_cycle_articles gauge")
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/db"
+	"news-api/fetcher"
+	"news-api/models"
+	"news-api/webhook"
+)
+
+// ImageProxyHosts lists the hosts /img may fetch from. A leading dot allows
+// subdomains (".example.com"). When empty, only images referenced by stored
+// articles can be proxied.
+var ImageProxyHosts []string
+
+// thumbnailWidths are the widths /img can resize to; the first is the default.
+var thumbnailWidths = []int{320, 160, 640}
+
+const (
+	maxProxiedImageBytes  = 10 << 20
+	maxProxiedImagePixels = 40_000_000
+	imageCacheBytes       = 64 << 20
+	imageCacheMaxAge      = 7 * 24 * time.Hour
+)
+
+var errImageTooLarge = errors.New("image too large")
+
+// imageProxyClient fetches source images. It refuses to connect to loopback,
+// private and link-local addresses so /img cannot be used to reach internal services.
+var imageProxyClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: webhook.RefuseNonPublic,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("too many redirects")
+		}
+		if len(ImageProxyHosts) > 0 && !imageHostAllowed(req.URL.Hostname()) {
+			return fmt.Errorf("redirect to disallowed host %s", req.URL.Hostname())
+		}
+		return nil
+	},
+}
+
+// thumbnail is a cached, ready-to-serve image.
+type thumbnail struct {
+	key         string
+	contentType string
+	data        []byte
+	etag        string
+}
+
+// imageCache is a byte-bounded LRU of thumbnails.
+var imageCache = newThumbnailCache(imageCacheBytes)
+
+type thumbnailCache struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List
+	entries  map[string]*list.Element
+	hits     int64
+	misses   int64
+}
+
+func newThumbnailCache(maxBytes int) *thumbnailCache {
+	return &thumbnailCache{maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func (c *thumbnailCache) get(key string) (*thumbnail, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(el)
+	return el.Value.(*thumbnail), true
+}
+
+func (c *thumbnailCache) add(t *thumbnail) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(t.data) > c.maxBytes {
+		return
+	}
+	if el, ok := c.entries[t.key]; ok {
+		c.bytes -= len(el.Value.(*thumbnail).data)
+		c.order.Remove(el)
+	}
+	c.entries[t.key] = c.order.PushFront(t)
+	c.bytes += len(t.data)
+	for c.bytes > c.maxBytes {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		evicted := oldest.Value.(*thumbnail)
+		delete(c.entries, evicted.key)
+		c.bytes -= len(evicted.data)
+	}
+}
+
+func (c *thumbnailCache) stats() models.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return models.NewCacheStats(len(c.entries), int64(c.bytes), c.hits, c.misses)
+}
+
+// imageHostAllowed reports whether host matches ImageProxyHosts.
+func imageHostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range ImageProxyHosts {
+		allowed = strings.ToLower(allowed)
+		if host == strings.TrimPrefix(allowed, ".") || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyImage serves a publisher image from our own origin, resized to one of the
+// thumbnail widths (w=160, 320 or 640), so pages do not hotlink third-party hosts.
+func ProxyImage(w http.ResponseWriter, r *http.Request) {
+	src := r.URL.Query().Get("src")
+	u, err := url.Parse(src)
+	if src == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		WriteError(w, http.StatusBadRequest, "src must be an http or https image URL")
+		return
+	}
+	width := thumbnailWidths[0]
+	if raw := r.URL.Query().Get("w"); raw != "" {
+		width, _ = strconv.Atoi(raw)
+		if !validThumbnailWidth(width) {
+			WriteError(w, http.StatusBadRequest, "w must be one of 160, 320 or 640")
+			return
+		}
+	}
+
+	if len(ImageProxyHosts) > 0 {
+		if !imageHostAllowed(u.Hostname()) {
+			WriteError(w, http.StatusForbidden, "Image host is not allowed")
+			return
+		}
+	} else {
+		known, err := db.IsArticleImage(src)
+		if err != nil {
+			log.Printf("Error checking proxied image %s: %v", src, err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		if !known {
+			WriteError(w, http.StatusForbidden, "Image is not used by any article")
+			return
+		}
+	}
+
+	key := strconv.Itoa(width) + " " + src
+	thumb, ok := imageCache.get(key)
+	if !ok {
+		thumb, err = fetchThumbnail(r.Context(), src, width)
+		if err != nil {
+			log.Printf("Error proxying image %s: %v", src, err)
+			WriteError(w, http.StatusBadGateway, "Bad Gateway")
+			return
+		}
+		thumb.key = key
+		imageCache.add(thumb)
+	}
+
+	serveThumbnail(w, r, thumb)
+}
+
+// serveThumbnail writes a cached image with caching headers.
+func serveThumbnail(w http.ResponseWriter, r *http.Request, thumb *thumbnail) {
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(imageCacheMaxAge.Seconds())))
+	w.Header().Set("ETag", thumb.etag)
+	if r.Header.Get("If-None-Match") == thumb.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", thumb.contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb.data)))
+	w.Write(thumb.data)
+}
+
+func validThumbnailWidth(width int) bool {
+	for _, allowed := range thumbnailWidths {
+		if width == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchThumbnail downloads an image and scales it down to width. Images that are
+// already narrow enough, or in formats that cannot be decoded (e.g. WebP), are
+// served as they are.
+func fetchThumbnail(ctx context.Context, src string, width int) (*thumbnail, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/*")
+	req.Header.Set("User-Agent", fetcher.UserAgent(req.URL.Hostname()))
+	resp, err := imageProxyClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/svg+xml" {
+		return nil, fmt.Errorf("unexpected content type %q", contentType)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProxiedImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxProxiedImageBytes {
+		return nil, errImageTooLarge
+	}
+
+	original := &thumbnail{contentType: contentType, data: data}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= width {
+		return withETag(original), nil
+	}
+	if config.Width*config.Height > maxProxiedImagePixels {
+		return nil, errImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return withETag(original), nil
+	}
+
+	height := config.Height * width / config.Width
+	if height < 1 {
+		height = 1
+	}
+	resized := resizeImage(img, width, height)
+
+	var buf bytes.Buffer
+	thumb := &thumbnail{}
+	if format == "png" || format == "gif" {
+		thumb.contentType = "image/png"
+		err = png.Encode(&buf, resized)
+	} else {
+		thumb.contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 80})
+	}
+	if err != nil {
+		return nil, err
+	}
+	thumb.data = buf.Bytes()
+	return withETag(thumb), nil
+}
+
+func withETag(t *thumbnail) *thumbnail {
+	sum := sha256.Sum256(t.data)
+	t.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	return t
+}
+
+// resizeImage scales src down to width x height by averaging the source pixels
+// covered by each destination pixel.
+func resizeImage(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || bounds.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	}
+	srcW, srcH := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcH/height, (y+1)*srcH/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcW/width, (x+1)*srcW/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += int(p[0])
+					g += int(p[1])
+					b += int(p[2])
+					a += int(p[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyImage(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	src.Set(0, 0, color.Black)
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, src))
+
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(encoded.Bytes())
+	}))
+	defer server.Close()
+	originalClient := imageProxyClient
+	imageProxyClient = server.Client()
+	defer func() { imageProxyClient = originalClient }()
+
+	imageURL := server.URL + "/cover.png"
+	proxy := func(query string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/img?"+query, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		ProxyImage(rr, req)
+		return rr
+	}
+
+	// Without an allowlist only images of stored articles are proxied.
+	rr := proxy("src="+url.QueryEscape(imageURL), nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Story", URL: "u1", ImageURL: imageURL, PublishedAt: time.Now()}))
+
+	rr = proxy("src="+url.QueryEscape(imageURL)+"&w=160", nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "max-age=")
+	thumb, err := png.Decode(rr.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 160, 80), thumb.Bounds())
+
+	// Repeated requests are served from the cache and support revalidation.
+	rr = proxy("src="+url.QueryEscape(imageURL)+"&w=160", http.Header{"If-None-Match": {rr.Header().Get("ETag")}})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+
+	rr = proxy("src="+url.QueryEscape(imageURL)+"&w=123", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	ImageProxyHosts = []string{".example.com"}
+	defer func() { ImageProxyHosts = nil }()
+	rr = proxy("src="+url.QueryEscape(imageURL), nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.True(t, imageHostAllowed("cdn.example.com"))
+	assert.True(t, imageHostAllowed("example.com"))
+	assert.False(t, imageHostAllowed("badexample.com"))
+}
+
+func TestImageProxyClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, err := imageProxyClient.Get(server.URL)
+	assert.ErrorContains(t, err, "non-public address")
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"news-api/db"
+)
+
+// GetJobs lists the background jobs with their schedule, state and last run.
+func GetJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := db.GetJobs()
+	if err != nil {
+		log.Printf("Error listing jobs: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// PauseJob stops the job {name} from running until it is resumed.
+func PauseJob(w http.ResponseWriter, r *http.Request) {
+	setJobPaused(w, r, true)
+}
+
+// ResumeJob lets the paused job {name} run on its schedule again.
+func ResumeJob(w http.ResponseWriter, r *http.Request) {
+	setJobPaused(w, r, false)
+}
+
+func setJobPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	name := r.PathValue("name")
+	err := db.SetJobPaused(name, paused)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating job %s: %v", name, err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	action := "job.resume"
+	if paused {
+		action = "job.pause"
+	}
+	recordAudit(r, action, "job:"+name, nil, map[string]bool{"paused": paused})
+	w.WriteHeader(http.StatusNoContent)
+}
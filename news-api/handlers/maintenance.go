@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"news-api/db"
+)
+
+// GetDBStats reports the size of the database file and its WAL, the rows of
+// every table, the size of every index, the share of free pages and the last
+// maintenance run.
+func GetDBStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := db.GetDBStats()
+	if err != nil {
+		log.Printf("Error fetching database stats: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// MaintainDB starts the maintenance operations listed in ?operations=, e.g.
+// "analyze,vacuum", or all of them, in the background. The run shows up in
+// /admin/db/stats once finished.
+func MaintainDB(w http.ResponseWriter, r *http.Request) {
+	var operations []string
+	if raw := r.URL.Query().Get("operations"); raw != "" {
+		for _, op := range strings.Split(raw, ",") {
+			op = strings.TrimSpace(op)
+			if !slices.Contains(db.MaintenanceOperations, op) {
+				WriteError(w, http.StatusBadRequest, "operations must be a comma-separated list of "+strings.Join(db.MaintenanceOperations, ", "))
+				return
+			}
+			operations = append(operations, op)
+		}
+	}
+	operations, err := db.StartMaintenance(operations)
+	if errors.Is(err, db.ErrMaintenanceRunning) {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting database maintenance: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "db.maintain", "database", nil, map[string][]string{"operations": operations})
+	writeJSON(w, http.StatusAccepted, map[string][]string{"operations": operations})
+}
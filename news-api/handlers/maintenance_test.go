@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"news-api/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBMaintenanceEndpoints(t *testing.T) {
+	// Maintenance runs on its own connection, which needs a database file.
+	require.NoError(t, db.InitDB(filepath.Join(t.TempDir(), "news.db")))
+	defer setupTestDB(t)
+	seedArticles(t)
+
+	rr := httptest.NewRecorder()
+	MaintainDB(rr, httptest.NewRequest("POST", "/admin/db/maintain?operations=analyze,shrink", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	MaintainDB(rr, httptest.NewRequest("POST", "/admin/db/maintain?operations=analyze", nil))
+	require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"operations": ["analyze"]}`, rr.Body.String())
+
+	var stats db.DBStats
+	require.Eventually(t, func() bool {
+		rr := httptest.NewRecorder()
+		GetDBStats(rr, httptest.NewRequest("GET", "/admin/db/stats", nil))
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+		return stats.LastRun != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"analyze"}, stats.LastRun.Operations)
+	assert.Empty(t, stats.LastRun.Error)
+	assert.NotEmpty(t, stats.Tables)
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+)
+
+// NewsletterToken authorizes the inbound email webhook, given as the token
+// query parameter or the X-Newsletter-Token header. The webhook answers 404
+// when it is empty.
+var NewsletterToken string
+
+// maxNewsletterMemory is the part of a multipart webhook request kept in
+// memory rather than in temporary files.
+const maxNewsletterMemory = 8 << 20
+
+// ReceiveNewsletter accepts a newsletter forwarded by an inbound email
+// service, either as the raw message (message/rfc822, or the "email" field of
+// SendGrid and the "body-mime" field of Mailgun) or as parsed from, subject,
+// html and text fields. Its links become articles at the next caching cycle.
+func ReceiveNewsletter(w http.ResponseWriter, r *http.Request) {
+	if NewsletterToken == "" {
+		WriteError(w, http.StatusNotFound, "Newsletter ingestion is not configured")
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = r.Header.Get("X-Newsletter-Token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(NewsletterToken)) != 1 {
+		WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	newsletter, err := readNewsletter(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(newsletter.HTML) == "" && strings.TrimSpace(newsletter.Text) == "" {
+		WriteError(w, http.StatusBadRequest, "Newsletter has no body")
+		return
+	}
+	if err := db.StoreNewsletter(newsletter); err != nil {
+		log.Printf("Error storing newsletter: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func readNewsletter(r *http.Request) (db.Newsletter, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "message/rfc822" {
+		raw, err := io.ReadAll(r.Body)
+		if err != nil {
+			return db.Newsletter{}, errBadNewsletter
+		}
+		return db.ParseNewsletter(raw)
+	}
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxNewsletterMemory); err != nil {
+			return db.Newsletter{}, errBadNewsletter
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return db.Newsletter{}, errBadNewsletter
+	}
+	for _, field := range []string{"email", "body-mime"} {
+		if raw := r.PostFormValue(field); raw != "" {
+			return db.ParseNewsletter([]byte(raw))
+		}
+	}
+	return db.Newsletter{
+		MessageID: firstFormValue(r, "Message-Id", "message-id"),
+		From:      firstFormValue(r, "from", "sender"),
+		Subject:   r.PostFormValue("subject"),
+		HTML:      firstFormValue(r, "html", "body-html"),
+		Text:      firstFormValue(r, "text", "body-plain"),
+	}, nil
+}
+
+var errBadNewsletter = errors.New("Request body must be an email message or an inbound email form")
+
+func firstFormValue(r *http.Request, fields ...string) string {
+	for _, field := range fields {
+		if value := r.PostFormValue(field); value != "" {
+			return value
+		}
+	}
+	return ""
+}
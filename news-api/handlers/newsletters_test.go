@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiveNewsletter(t *testing.T) {
+	setupTestDB(t)
+	defer func(token string) { NewsletterToken = token }(NewsletterToken)
+
+	post := func(target, contentType, body string) int {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		ReceiveNewsletter(rr, req)
+		return rr.Code
+	}
+	raw := "From: Risky Business <news@risky.biz>\r\nSubject: Risky Bulletin\r\nMessage-ID: <rb-1@risky.biz>\r\nContent-Type: text/html\r\n\r\n" +
+		`<p><a href="https://example.com/okta">Okta's support system was breached</a></p>`
+
+	NewsletterToken = ""
+	assert.Equal(t, http.StatusNotFound, post("/inbound/newsletter?token=x", "message/rfc822", raw), "disabled without a token")
+
+	NewsletterToken = "inbound-secret"
+	assert.Equal(t, http.StatusUnauthorized, post("/inbound/newsletter?token=wrong", "message/rfc822", raw))
+	assert.Equal(t, http.StatusAccepted, post("/inbound/newsletter?token=inbound-secret", "message/rfc822", raw))
+
+	form := url.Values{"email": {raw}}.Encode()
+	assert.Equal(t, http.StatusAccepted, post("/inbound/newsletter?token=inbound-secret", "application/x-www-form-urlencoded", form), "SendGrid raw message")
+
+	form = url.Values{"sender": {"tl;dr sec <clint@tldrsec.com>"}, "subject": {"#250"}, "body-plain": {"Cloud IR: https://example.com/aws-ir"}}.Encode()
+	assert.Equal(t, http.StatusAccepted, post("/inbound/newsletter?token=inbound-secret", "application/x-www-form-urlencoded", form), "Mailgun parsed fields")
+
+	form = url.Values{"subject": {"No body"}}.Encode()
+	assert.Equal(t, http.StatusBadRequest, post("/inbound/newsletter?token=inbound-secret", "application/x-www-form-urlencoded", form))
+	assert.Equal(t, http.StatusBadRequest, post("/inbound/newsletter?token=inbound-secret", "message/rfc822", "not a message"))
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// AdminToken authorizes the /admin endpoints. The admin API is disabled when it is empty.
+var AdminToken string
+
+const (
+	orgContextKey       contextKey = "org"
+	principalContextKey contextKey = "principal"
+)
+
+// ScopeOrg resolves the organization a request acts for and makes it available
+// through OrgFromContext. Requests carrying an X-API-Key header act for the key's
+// organization and are rejected if the key is unknown. Otherwise a bearer token
+// other than AdminToken must be a valid session, which scopes the request to the
+// user's organization. Everything else sees the shared feed. The key or user is
+// available through PrincipalFromContext.
+func ScopeOrg(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var orgID int64
+		var principal string
+		if secret := r.Header.Get("X-API-Key"); secret != "" {
+			key, err := db.GetAPIKey(secret)
+			if err != nil {
+				if !errors.Is(err, db.ErrInvalidCredentials) {
+					log.Printf("Error resolving API key: %v", err)
+				}
+				WriteError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			orgID, principal = key.OrgID, db.APIKeyPrincipal(key.ID)
+		} else if token := bearerToken(r); token != "" && !isAdminToken(token) {
+			user, err := db.GetUserBySession(token)
+			if err != nil {
+				if !errors.Is(err, db.ErrInvalidCredentials) {
+					log.Printf("Error resolving session: %v", err)
+				}
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				WriteError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			orgID, principal = user.OrgID, db.UserPrincipal(user.ID)
+		}
+		ctx := context.WithValue(r.Context(), orgContextKey, orgID)
+		ctx = context.WithValue(ctx, principalContextKey, principal)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// OrgFromContext returns the organization set by ScopeOrg, 0 being the shared feed.
+func OrgFromContext(ctx context.Context) int64 {
+	orgID, _ := ctx.Value(orgContextKey).(int64)
+	return orgID
+}
+
+// PrincipalFromContext returns the API key or user ScopeOrg authenticated,
+// as named by db.APIKeyPrincipal or db.UserPrincipal, or "" for anonymous
+// requests.
+func PrincipalFromContext(ctx context.Context) string {
+	principal, _ := ctx.Value(principalContextKey).(string)
+	return principal
+}
+
+// RequireAdmin rejects requests that do not carry AdminToken as bearer token.
+func RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if AdminToken == "" {
+			WriteError(w, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+		if !isAdminToken(bearerToken(r)) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isAdminToken reports whether token is AdminToken, in constant time.
+func isAdminToken(token string) bool {
+	return AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(AdminToken)) == 1
+}
+
+type orgRequest struct {
+	Name string `json:"name"`
+}
+
+type orgResponse struct {
+	models.Organization
+	models.OrgSettings
+	APIKeys []models.APIKey `json:"apiKeys"`
+}
+
+type apiKeyResponse struct {
+	Key string `json:"key"`
+	models.APIKey
+}
+
+// CreateOrganization registers a new tenant.
+func CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	var req orgRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		WriteError(w, http.StatusBadRequest, "Name must be 1-100 characters")
+		return
+	}
+	org, err := db.CreateOrganization(req.Name)
+	if errors.Is(err, db.ErrOrgExists) {
+		WriteError(w, http.StatusConflict, "Organization already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating organization: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "org.create", orgTarget(org.ID), nil, org)
+	writeJSON(w, http.StatusCreated, org)
+}
+
+// GetOrganizations lists all tenants.
+func GetOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := db.GetOrganizations()
+	if err != nil {
+		log.Printf("Error fetching organizations: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, orgs)
+}
+
+// GetOrganization returns the organization in the {id} path segment with its
+// sources, scoring rules and API keys.
+func GetOrganization(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	settings, err := db.GetOrgSettings(org.ID)
+	if err != nil {
+		log.Printf("Error fetching organization settings: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	keys, err := db.GetAPIKeys(org.ID)
+	if err != nil {
+		log.Printf("Error fetching API keys: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, orgResponse{Organization: org, OrgSettings: settings, APIKeys: keys})
+}
+
+// CreateAPIKey issues an API key for the organization in the {id} path segment.
+// The key is only shown in this response.
+func CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req orgRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	secret, key, err := db.CreateAPIKey(org.ID, strings.TrimSpace(req.Name))
+	if err != nil {
+		log.Printf("Error creating API key: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "apikey.create", orgTarget(org.ID), nil, key)
+	writeJSON(w, http.StatusCreated, apiKeyResponse{Key: secret, APIKey: key})
+}
+
+// DeleteAPIKey revokes the API key {keyId} of the organization {id}.
+func DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	keyID, ok := idFromPath(w, r, "keyId", "API key")
+	if !ok {
+		return
+	}
+	var before *models.APIKey
+	if keys, err := db.GetAPIKeys(org.ID); err == nil {
+		for i := range keys {
+			if keys[i].ID == keyID {
+				before = &keys[i]
+			}
+		}
+	}
+	err := db.DeleteAPIKey(org.ID, keyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting API key: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "apikey.delete", orgTarget(org.ID), before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddOrgSource adds {"url": "..."} to the organization's feed sources.
+func AddOrgSource(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		WriteError(w, http.StatusBadRequest, "url must be an http or https feed URL")
+		return
+	}
+	before := orgSettingsForAudit(org.ID)
+	if err := db.AddOrgSource(org.ID, req.URL); err != nil {
+		log.Printf("Error adding organization source: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "source.add", orgTarget(org.ID), before.Sources, orgSettingsForAudit(org.ID).Sources)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveOrgSource removes the feed given in the url query parameter from the
+// organization's sources. Articles already fetched from it are kept.
+func RemoveOrgSource(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	before := orgSettingsForAudit(org.ID)
+	err := db.RemoveOrgSource(org.ID, r.URL.Query().Get("url"))
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Source not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error removing organization source: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "source.remove", orgTarget(org.ID), before.Sources, orgSettingsForAudit(org.ID).Sources)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetOrgKeywords replaces the organization's keyword rules with a
+// {"keyword": weight} object. Weights are added to the rank of new articles.
+func SetOrgKeywords(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	var keywords map[string]int
+	if err := decodeJSON(w, r, &keywords); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	before := orgSettingsForAudit(org.ID)
+	if err := db.SetOrgKeywords(org.ID, keywords); err != nil {
+		log.Printf("Error saving organization keywords: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "keywords.set", orgTarget(org.ID), before.Keywords, orgSettingsForAudit(org.ID).Keywords)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetOrgWatchlist replaces the organization's watchlist with a JSON array of terms.
+func SetOrgWatchlist(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	var terms []string
+	if err := decodeJSON(w, r, &terms); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	before := orgSettingsForAudit(org.ID)
+	if err := db.SetOrgWatchlist(org.ID, terms); err != nil {
+		log.Printf("Error saving organization watchlist: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "watchlist.set", orgTarget(org.ID), before.Watchlist, orgSettingsForAudit(org.ID).Watchlist)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AddOrgMember moves the user {"username": "..."} into the organization.
+func AddOrgMember(w http.ResponseWriter, r *http.Request) {
+	org, ok := orgFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	previousOrg, err := db.GetUserOrg(req.Username)
+	if err == nil {
+		err = db.SetUserOrg(req.Username, org.ID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error adding organization member: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "member.add", "user:"+req.Username, map[string]int64{"orgId": previousOrg}, map[string]int64{"orgId": org.ID})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// orgFromPath loads the organization in the {id} path segment, writing a 400 or
+// 404 response if it is invalid or unknown.
+func orgFromPath(w http.ResponseWriter, r *http.Request) (models.Organization, bool) {
+	id, ok := idFromPath(w, r, "id", "organization")
+	if !ok {
+		return models.Organization{}, false
+	}
+	org, err := db.GetOrganization(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Organization not found")
+		return models.Organization{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching organization: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.Organization{}, false
+	}
+	return org, true
+}
+
+func orgTarget(id int64) string {
+	return "org:" + strconv.FormatInt(id, 10)
+}
+
+// orgSettingsForAudit loads an organization's settings to record around a
+// change, logging rather than failing if they cannot be read.
+func orgSettingsForAudit(orgID int64) models.OrgSettings {
+	settings, err := db.GetOrgSettings(orgID)
+	if err != nil {
+		log.Printf("Error loading organization settings for audit: %v", err)
+	}
+	return settings
+}
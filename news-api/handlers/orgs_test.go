@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// adminRequest builds a request authorized with the admin token and an optional {id} path value.
+func adminRequest(method, target string, body interface{}, id string) *http.Request {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, target, &buf)
+	req.Header.Set("Authorization", "Bearer "+AdminToken)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	return req
+}
+
+func TestRequireAdmin(t *testing.T) {
+	setupTestDB(t)
+	AdminToken = ""
+	rr := httptest.NewRecorder()
+	RequireAdmin(GetOrganizations)(rr, adminRequest("GET", "/admin/orgs", nil, ""))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = "" }()
+	rr = httptest.NewRecorder()
+	RequireAdmin(GetOrganizations)(rr, authedRequest("GET", "/admin/orgs", "wrong", ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(GetOrganizations)(rr, adminRequest("GET", "/admin/orgs", nil, ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestOrganizationAdminAndScoping(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = "" }()
+
+	rr := httptest.NewRecorder()
+	RequireAdmin(CreateOrganization)(rr, adminRequest("POST", "/admin/orgs", orgRequest{Name: "Acme"}, ""))
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var org models.Organization
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&org))
+	id := strconv.FormatInt(org.ID, 10)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(AddOrgSource)(rr, adminRequest("POST", "/admin/orgs/"+id+"/sources", map[string]string{"url": "https://acme.example/feed"}, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(AddOrgSource)(rr, adminRequest("POST", "/admin/orgs/"+id+"/sources", map[string]string{"url": "ftp://acme.example"}, id))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(SetOrgKeywords)(rr, adminRequest("PUT", "/admin/orgs/"+id+"/keywords", map[string]int{"acme": 3}, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(CreateAPIKey)(rr, adminRequest("POST", "/admin/orgs/"+id+"/api-keys", orgRequest{Name: "dashboard"}, id))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var key apiKeyResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&key))
+	require.NotEmpty(t, key.Key)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(GetOrganization)(rr, adminRequest("GET", "/admin/orgs/"+id, nil, id))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var details orgResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&details))
+	assert.Equal(t, []string{"https://acme.example/feed"}, details.Sources)
+	assert.Equal(t, map[string]int{"acme": 3}, details.Keywords)
+	require.Len(t, details.APIKeys, 1)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(GetOrganization)(rr, adminRequest("GET", "/admin/orgs/999", nil, "999"))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Shared story", URL: "u1", PublishedAt: time.Now()}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Acme story", URL: "u2", OrgID: org.ID, PublishedAt: time.Now()}))
+
+	news := ScopeOrg(http.HandlerFunc(GetNews))
+	fetch := func(apiKey string) (int, []models.NewsArticle) {
+		req := httptest.NewRequest("GET", "/news", nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		news.ServeHTTP(rr, req)
+		var articles []models.NewsArticle
+		json.NewDecoder(rr.Body).Decode(&articles)
+		return rr.Code, articles
+	}
+
+	code, articles := fetch("")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Shared story", articles[0].Title)
+
+	code, articles = fetch(key.Key)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Acme story", articles[0].Title)
+
+	code, _ = fetch("tf_invalid")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// Members see their organization's articles through their session.
+	token := registerUser(t, "analyst")
+	rr = httptest.NewRecorder()
+	RequireAdmin(AddOrgMember)(rr, adminRequest("POST", "/admin/orgs/"+id+"/members", map[string]string{"username": "analyst"}, id))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	news.ServeHTTP(rr, authedRequest("GET", "/news", token, ""))
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Acme story", articles[0].Title)
+
+	// An unknown or revoked session is rejected rather than seeing the shared feed.
+	rr = httptest.NewRecorder()
+	news.ServeHTTP(rr, authedRequest("GET", "/news", "not-a-session", ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "Bearer", rr.Header().Get("WWW-Authenticate"))
+	require.NoError(t, db.DeleteSession(token))
+	rr = httptest.NewRecorder()
+	news.ServeHTTP(rr, authedRequest("GET", "/news", token, ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// The admin token is not a session and sees the shared feed.
+	rr = httptest.NewRecorder()
+	news.ServeHTTP(rr, authedRequest("GET", "/news", AdminToken, ""))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Shared story", articles[0].Title)
+}
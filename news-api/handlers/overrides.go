@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// maxOverrideDuration bounds how long a threat level can be pinned, so a
+// forgotten override cannot hide the computed level indefinitely.
+const maxOverrideDuration = 30 * 24 * time.Hour
+
+type overrideRequest struct {
+	OrgID     int64     `json:"orgId"`
+	Level     string    `json:"level"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Duration is an alternative to ExpiresAt, e.g. "4h".
+	Duration string `json:"duration"`
+}
+
+// SetThreatOverride pins the threat level reported by /today-threat for an
+// organization (0 for the shared feed) until the given expiry.
+func SetThreatOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if !validThreatLevel(req.Level) {
+		WriteError(w, http.StatusBadRequest, "level must be one of "+strings.Join(db.ThreatLevels, ", "))
+		return
+	}
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			WriteError(w, http.StatusBadRequest, "duration must be a positive duration such as 4h")
+			return
+		}
+		req.ExpiresAt = time.Now().Add(d)
+	}
+	if !req.ExpiresAt.After(time.Now()) || time.Until(req.ExpiresAt) > maxOverrideDuration {
+		WriteError(w, http.StatusBadRequest, "expiresAt or duration must be in the next 30 days")
+		return
+	}
+	if req.OrgID != 0 {
+		if _, err := db.GetOrganization(req.OrgID); errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return
+		} else if err != nil {
+			log.Printf("Error fetching organization: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+	}
+
+	before := activeOverride(req.OrgID)
+	override, err := db.SetThreatOverride(models.ThreatOverride{
+		OrgID:     req.OrgID,
+		Level:     req.Level,
+		Reason:    strings.TrimSpace(req.Reason),
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: auditActor(r),
+	})
+	if err != nil {
+		log.Printf("Error saving threat override: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "threatlevel.override", orgTarget(req.OrgID), before, override)
+	writeJSON(w, http.StatusOK, override)
+}
+
+// ClearThreatOverride removes the override of the organization in the orgId
+// query parameter (default 0, the shared feed).
+func ClearThreatOverride(w http.ResponseWriter, r *http.Request) {
+	var orgID int64
+	if raw := r.URL.Query().Get("orgId"); raw != "" {
+		var err error
+		if orgID, err = strconv.ParseInt(raw, 10, 64); err != nil || orgID < 0 {
+			WriteError(w, http.StatusBadRequest, "Invalid orgId")
+			return
+		}
+	}
+	before := activeOverride(orgID)
+	err := db.ClearThreatOverride(orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "No active override")
+		return
+	}
+	if err != nil {
+		log.Printf("Error clearing threat override: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "threatlevel.clear", orgTarget(orgID), before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func validThreatLevel(level string) bool {
+	for _, l := range db.ThreatLevels {
+		if level == l {
+			return true
+		}
+	}
+	return false
+}
+
+// activeOverride loads the override to record around a change, nil if there is none.
+func activeOverride(orgID int64) *models.ThreatOverride {
+	o, err := db.GetThreatOverride(orgID)
+	if err != nil {
+		return nil
+	}
+	return &o
+}
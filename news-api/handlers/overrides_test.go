@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"news-api/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThreatOverrideEndpoints(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = "" }()
+
+	for _, body := range []map[string]interface{}{
+		{"level": "Purple", "duration": "1h"},
+		{"level": "Attention"},
+		{"level": "Attention", "duration": "-1h"},
+		{"level": "Attention", "duration": "1000h"},
+	} {
+		rr := httptest.NewRecorder()
+		RequireAdmin(SetThreatOverride)(rr, adminRequest("POST", "/admin/threat-level/override", body, ""))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, "%v", body)
+	}
+
+	rr := httptest.NewRecorder()
+	RequireAdmin(SetThreatOverride)(rr, adminRequest("POST", "/admin/threat-level/override",
+		map[string]interface{}{"level": "Attention", "duration": "2h", "reason": "holding during triage"}, ""))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	GetTodayThreat(rr, httptest.NewRequest("GET", "/today-threat", nil))
+	var score db.ThreatScore
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&score))
+	assert.Equal(t, "Attention", score.ThreatLevel)
+	assert.Equal(t, "Code Red", score.ComputedLevel)
+	require.NotNil(t, score.Override)
+	assert.Equal(t, "holding during triage", score.Override.Reason)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(ClearThreatOverride)(rr, adminRequest("DELETE", "/admin/threat-level/override", nil, ""))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = httptest.NewRecorder()
+	RequireAdmin(ClearThreatOverride)(rr, adminRequest("DELETE", "/admin/threat-level/override", nil, ""))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	entries, err := db.GetAuditLog(db.AuditFilter{Target: "org:0"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "threatlevel.clear", entries[0].Action)
+	assert.Equal(t, "threatlevel.override", entries[1].Action)
+}
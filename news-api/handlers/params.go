@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryParams validates the query parameters of a request. Each accessor
+// returns the parameter's value, or its default when it is absent, and records
+// a description of invalid values. valid then answers 400 listing all of them,
+// so that bad input is never silently replaced by a default.
+type queryParams struct {
+	values url.Values
+	errs   []string
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{values: r.URL.Query()}
+}
+
+func (p *queryParams) invalid(format string, args ...interface{}) {
+	p.errs = append(p.errs, fmt.Sprintf(format, args...))
+}
+
+// valid reports whether every parameter read so far was valid, and otherwise
+// writes a 400 response describing the invalid ones.
+func (p *queryParams) valid(w http.ResponseWriter) bool {
+	if len(p.errs) == 0 {
+		return true
+	}
+	WriteError(w, http.StatusBadRequest, strings.Join(p.errs, "; "))
+	return false
+}
+
+// intRange returns the integer parameter name, which must be between min and max.
+func (p *queryParams) intRange(name string, def, min, max int) int {
+	raw := p.values.Get(name)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || n > max {
+		switch {
+		case min == math.MinInt:
+			p.invalid("%s must be an integer", name)
+		case max == math.MaxInt:
+			p.invalid("%s must be an integer of at least %d", name, min)
+		default:
+			p.invalid("%s must be an integer between %d and %d", name, min, max)
+		}
+		return def
+	}
+	return n
+}
+
+// integer returns the integer parameter name.
+func (p *queryParams) integer(name string, def int) int {
+	return p.intRange(name, def, math.MinInt, math.MaxInt)
+}
+
+// limit returns the limit parameter, which must be between 1 and max.
+func (p *queryParams) limit(def, max int) int {
+	return p.intRange("limit", def, 1, max)
+}
+
+// offset returns the offset parameter, the number of results to skip.
+func (p *queryParams) offset() int {
+	return p.intRange("offset", 0, 0, math.MaxInt)
+}
+
+// oneOf returns the parameter name, which must be one of allowed, or "" when
+// it is absent.
+func (p *queryParams) oneOf(name string, allowed ...string) string {
+	raw := p.values.Get(name)
+	if raw == "" {
+		return ""
+	}
+	for _, a := range allowed {
+		if raw == a {
+			return raw
+		}
+	}
+	p.invalid("%s must be one of %s", name, strings.Join(allowed, ", "))
+	return ""
+}
+
+// boolean returns the parameter name, which must be true or false.
+func (p *queryParams) boolean(name string) bool {
+	switch p.values.Get(name) {
+	case "", "false":
+		return false
+	case "true":
+		return true
+	}
+	p.invalid("%s must be true or false", name)
+	return false
+}
+
+// optionalBoolean returns the parameter name, which must be true or false,
+// or nil if it is not given.
+func (p *queryParams) optionalBoolean(name string) *bool {
+	if p.values.Get(name) == "" {
+		return nil
+	}
+	value := p.boolean(name)
+	return &value
+}
+
+// dateRange reads the optional start and end parameters, either RFC3339
+// timestamps with an offset or YYYY-MM-DD dates, which are UTC days. An end
+// date includes the entire day. Alternatively, window (e.g. 6h, 24h or 7d)
+// selects a period ending now. The returned times are in UTC.
+func (p *queryParams) dateRange() (time.Time, time.Time) {
+	if windowStr := p.values.Get("window"); windowStr != "" {
+		if p.values.Get("start") != "" || p.values.Get("end") != "" {
+			p.invalid("window cannot be combined with start or end")
+			return time.Time{}, time.Time{}
+		}
+		window, err := parseWindow(windowStr)
+		if err != nil {
+			p.invalid("window must be a positive duration such as 6h, 24h or 7d")
+			return time.Time{}, time.Time{}
+		}
+		return time.Now().UTC().Add(-window), time.Time{}
+	}
+
+	var startDate, endDate time.Time
+	if startDateStr := p.values.Get("start"); startDateStr != "" {
+		var err error
+		if startDate, _, err = parseDateParam(startDateStr); err != nil {
+			p.invalid("start must be an RFC 3339 timestamp (2024-05-01T00:00:00Z) or a date (2024-05-01)")
+		}
+	}
+	if endDateStr := p.values.Get("end"); endDateStr != "" {
+		var dateOnly bool
+		var err error
+		if endDate, dateOnly, err = parseDateParam(endDateStr); err != nil {
+			p.invalid("end must be an RFC 3339 timestamp (2024-05-01T00:00:00Z) or a date (2024-05-01)")
+		} else if dateOnly {
+			// Add 23 hours, 59 minutes, and 59 seconds to the end date to include the entire day.
+			endDate = endDate.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+		}
+	}
+	if !startDate.IsZero() && !endDate.IsZero() && startDate.After(endDate) {
+		p.invalid("start must not be after end")
+	}
+	return startDate, endDate
+}
+
+// setNextPageLink adds a Link header pointing to the next page of a list
+// response when the page is full, i.e. more results may follow.
+func setNextPageLink(w http.ResponseWriter, r *http.Request, offset, limit, count int) {
+	if count < limit {
+		return
+	}
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset+limit))
+	query.Set("limit", strconv.Itoa(limit))
+	next := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	w.Header().Add("Link", "<"+next.String()+`>; rel="next"`)
+}
+
+// parseDateRange validates the date range parameters of a request that has
+// no others, writing a 400 response if they are invalid.
+func parseDateRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	params := newQueryParams(r)
+	startDate, endDate := params.dateRange()
+	return startDate, endDate, params.valid(w)
+}
+
+// parseWindow parses a positive duration, accepting d (days) and w (weeks) in
+// addition to the units of time.ParseDuration.
+func parseWindow(value string) (time.Duration, error) {
+	unit := map[byte]time.Duration{'d': 24 * time.Hour, 'w': 7 * 24 * time.Hour}[value[len(value)-1]]
+	var d time.Duration
+	if unit > 0 {
+		n, err := strconv.Atoi(value[:len(value)-1])
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(n) * unit
+	} else {
+		var err error
+		if d, err = time.ParseDuration(value); err != nil {
+			return 0, err
+		}
+	}
+	if d <= 0 {
+		return 0, errors.New("window must be positive")
+	}
+	return d, nil
+}
+
+// parseDateParam parses an RFC3339 timestamp or a YYYY-MM-DD date, reporting
+// whether it was a date. Dates are taken as UTC.
+func parseDateParam(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), false, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	return t, true, err
+}
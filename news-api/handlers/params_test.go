@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/models"
+)
+
+func TestGetNewsRejectsInvalidParams(t *testing.T) {
+	setupTestDB(t)
+
+	tests := []struct {
+		query   string
+		message string
+	}{
+		{"limit=-5", "limit must be an integer between 1 and 1000"},
+		{"limit=0", "limit must be an integer between 1 and 1000"},
+		{"limit=1000001", "limit must be an integer between 1 and 1000"},
+		{"limit=ten", "limit must be an integer between 1 and 1000"},
+		{"sortBy=popularity", "sortBy must be one of publishedAt, rank, severity, ingestedAt"},
+		{"minRank=high", "minRank must be an integer"},
+		{"minSeverity=101", "minSeverity must be an integer between 0 and 100"},
+		{"includeDead=yes", "includeDead must be true or false"},
+		{"paywalled=1", "paywalled must be true or false"},
+		{"start=26/10/2023", "start must be an RFC 3339 timestamp (2024-05-01T00:00:00Z) or a date (2024-05-01)"},
+		{"start=2024-05-02&end=2024-05-01", "start must not be after end"},
+		{"limit=-1&sortBy=x", "limit must be an integer between 1 and 1000; sortBy must be one of publishedAt, rank, severity, ingestedAt"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?"+tt.query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.query)
+		assert.Equal(t, ErrorDetail{Code: "bad_request", Message: tt.message}, decodeError(t, rr), tt.query)
+	}
+
+	rr := httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?limit=1000&sortBy=publishedAt&minRank=-2&includeDead=false&paywalled=false&start=2024-05-01&end=2024-05-01", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestGetNewsPaging(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	defer func(def, max int) { DefaultPageSize, MaxPageSize = def, max }(DefaultPageSize, MaxPageSize)
+	DefaultPageSize, MaxPageSize = 2, 3
+
+	rr := httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?category=all", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var page []models.NewsArticle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page, 2, "the default page size applies")
+	assert.Equal(t, []string{"Cyber Article 1", "Tech Article 1"}, []string{page[0].Title, page[1].Title})
+	assert.Equal(t, `</news?category=all&limit=2&offset=2>; rel="next"`, rr.Header().Get("Link"))
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?category=all&limit=2&offset=2", nil))
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page, 2)
+	assert.Equal(t, "Cyber Article 2 about ransomware", page[0].Title)
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?limit=3&offset=3", nil))
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page, 1)
+	assert.Empty(t, rr.Header().Get("Link"), "the last page has no next link")
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest(http.MethodGet, "/news?limit=4", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "limit must be an integer between 1 and 3", decodeError(t, rr).Message)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"news-api/db"
+	"news-api/integrations"
+)
+
+// Pocket saves articles to users' Pocket accounts. It is nil, and the Pocket
+// endpoints answer 404, unless a consumer key is configured.
+var Pocket *integrations.Pocket
+
+// pocketAuthorizationTTL is how long a user has to approve access in Pocket.
+const pocketAuthorizationTTL = 15 * time.Minute
+
+func requirePocket(w http.ResponseWriter) bool {
+	if Pocket == nil {
+		WriteError(w, http.StatusNotFound, "Pocket integration is not configured")
+		return false
+	}
+	return true
+}
+
+type pocketConnectResponse struct {
+	AuthorizeURL string `json:"authorizeUrl"`
+}
+
+// ConnectPocket starts connecting the user's Pocket account. The user must
+// open the returned authorizeUrl and approve access; Pocket then redirects to
+// /pocket/callback, which completes the connection.
+func ConnectPocket(w http.ResponseWriter, r *http.Request) {
+	if !requirePocket(w) {
+		return
+	}
+	user, _ := UserFromContext(r.Context())
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		log.Printf("Error generating Pocket state: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	state := hex.EncodeToString(raw)
+	redirectURI := baseURL(r) + "/pocket/callback?" + url.Values{"state": {state}}.Encode()
+	requestToken, err := Pocket.RequestToken(r.Context(), redirectURI)
+	if err != nil {
+		log.Printf("Error requesting Pocket token: %v", err)
+		WriteError(w, http.StatusBadGateway, "Pocket is unavailable")
+		return
+	}
+	if err := db.StartPocketAuthorization(state, user.ID, requestToken, pocketAuthorizationTTL); err != nil {
+		log.Printf("Error storing Pocket authorization: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, pocketConnectResponse{AuthorizeURL: Pocket.AuthorizeURL(requestToken, redirectURI)})
+}
+
+// PocketCallback completes a connection started by ConnectPocket when Pocket
+// redirects the user back. It needs no session: the state identifies the user.
+func PocketCallback(w http.ResponseWriter, r *http.Request) {
+	if !requirePocket(w) {
+		return
+	}
+	userID, requestToken, err := db.FinishPocketAuthorization(r.URL.Query().Get("state"))
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusBadRequest, "Unknown or expired Pocket authorization")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading Pocket authorization: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	accessToken, username, err := Pocket.Authorize(r.Context(), requestToken)
+	if err != nil {
+		// Pocket also refuses tokens the user did not approve.
+		log.Printf("Error authorizing Pocket token: %v", err)
+		WriteError(w, http.StatusBadGateway, "Pocket did not authorize access")
+		return
+	}
+	if err := db.SavePocketAccount(userID, accessToken, username); err != nil {
+		log.Printf("Error storing Pocket account: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	account, err := db.GetPocketAccount(userID)
+	if err != nil {
+		log.Printf("Error reading Pocket account: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// GetPocketAccount returns the user's connected Pocket account.
+func GetPocketAccount(w http.ResponseWriter, r *http.Request) {
+	if !requirePocket(w) {
+		return
+	}
+	user, _ := UserFromContext(r.Context())
+	account, err := db.GetPocketAccount(user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "No Pocket account connected")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading Pocket account: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
+
+// DisconnectPocket forgets the user's Pocket access token.
+func DisconnectPocket(w http.ResponseWriter, r *http.Request) {
+	if !requirePocket(w) {
+		return
+	}
+	user, _ := UserFromContext(r.Context())
+	err := db.DeletePocketAccount(user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "No Pocket account connected")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting Pocket account: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SaveToPocket adds the article {id} to the user's Pocket reading queue.
+func SaveToPocket(w http.ResponseWriter, r *http.Request) {
+	if !requirePocket(w) {
+		return
+	}
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	user, _ := UserFromContext(r.Context())
+	account, err := db.GetPocketAccount(user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusConflict, "No Pocket account connected")
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading Pocket account: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	article, err := db.GetOrgArticle(OrgFromContext(r.Context()), articleID, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if err := Pocket.Add(r.Context(), account.AccessToken, article); err != nil {
+		log.Printf("Error saving article %d to Pocket: %v", articleID, err)
+		WriteError(w, http.StatusBadGateway, "Pocket did not accept the article")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/integrations"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveToPocket(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Long read on APT tooling", URL: "https://example.com/apt", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour)}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	id := strconv.FormatInt(articles[0].ID, 10)
+	user, err := db.CreateUser("pocketeer", "correct horse battery")
+	require.NoError(t, err)
+
+	var added map[string]string
+	pocket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("X-Accept"))
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "consumer", body["consumer_key"])
+		switch r.URL.Path {
+		case "/v3/oauth/request":
+			json.NewEncoder(w).Encode(map[string]string{"code": "request-token"})
+		case "/v3/oauth/authorize":
+			assert.Equal(t, "request-token", body["code"])
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "access-token", "username": "pocketeer@example.com"})
+		case "/v3/add":
+			added = body
+			w.Write([]byte(`{"status": 1}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer pocket.Close()
+
+	call := func(handler http.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.SetPathValue("id", id)
+		req = req.WithContext(context.WithValue(req.Context(), userContextKey, user))
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+	assert.Equal(t, http.StatusNotFound, call(SaveToPocket, "POST", "/news/"+id+"/save-to-pocket").Code, "Pocket is not configured")
+
+	Pocket = &integrations.Pocket{ConsumerKey: "consumer", APIURL: pocket.URL}
+	defer func() { Pocket = nil }()
+	assert.Equal(t, http.StatusConflict, call(SaveToPocket, "POST", "/news/"+id+"/save-to-pocket").Code)
+
+	rr := call(ConnectPocket, "POST", "/me/pocket/connect")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var connect pocketConnectResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&connect))
+	authorize, err := url.Parse(connect.AuthorizeURL)
+	require.NoError(t, err)
+	assert.Equal(t, "request-token", authorize.Query().Get("request_token"))
+	redirect, err := url.Parse(authorize.Query().Get("redirect_uri"))
+	require.NoError(t, err)
+	assert.Equal(t, "/pocket/callback", redirect.Path)
+
+	rr = httptest.NewRecorder()
+	PocketCallback(rr, httptest.NewRequest("GET", "/pocket/callback?state=forged", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = httptest.NewRecorder()
+	PocketCallback(rr, httptest.NewRequest("GET", redirect.RequestURI(), nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "pocketeer@example.com")
+	assert.NotContains(t, rr.Body.String(), "access-token")
+
+	assert.Equal(t, http.StatusNoContent, call(SaveToPocket, "POST", "/news/"+id+"/save-to-pocket").Code)
+	assert.Equal(t, "access-token", added["access_token"])
+	assert.Equal(t, "https://example.com/apt", added["url"])
+	assert.Equal(t, "threatfeed,cybersecurity", added["tags"])
+	req := httptest.NewRequest("POST", "/news/9999/save-to-pocket", nil)
+	req.SetPathValue("id", "9999")
+	req = req.WithContext(context.WithValue(req.Context(), userContextKey, user))
+	rr = httptest.NewRecorder()
+	SaveToPocket(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	assert.Equal(t, http.StatusOK, call(GetPocketAccount, "GET", "/me/pocket").Code)
+	assert.Equal(t, http.StatusNoContent, call(DisconnectPocket, "DELETE", "/me/pocket").Code)
+	assert.Equal(t, http.StatusNotFound, call(GetPocketAccount, "GET", "/me/pocket").Code)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// maxProfileWeight bounds the weight of a ranking profile term, in either direction.
+const maxProfileWeight = 100
+
+// FeedbackRanking enables the re-ranking of /news by the model learned from
+// the relevance votes of the caller's organization.
+var FeedbackRanking bool
+
+// rerank adjusts the ranks of articles by the feedback model of the caller's
+// organization, when FeedbackRanking is set, and by the caller's ranking profile.
+func rerank(r *http.Request, articles []models.NewsArticle) error {
+	if FeedbackRanking {
+		model, err := db.GetFeedbackModel(OrgFromContext(r.Context()))
+		if err != nil {
+			return err
+		}
+		model.Apply(articles)
+	}
+	if principal := PrincipalFromContext(r.Context()); principal != "" {
+		weights, err := db.GetRankingProfile(principal)
+		if err != nil {
+			return err
+		}
+		db.ApplyRankingProfile(articles, weights)
+	}
+	return nil
+}
+
+// requirePrincipal returns the caller's principal, answering 401 for
+// anonymous requests.
+func requirePrincipal(w http.ResponseWriter, r *http.Request) (string, bool) {
+	principal := PrincipalFromContext(r.Context())
+	if principal == "" {
+		WriteError(w, http.StatusUnauthorized, "A session token or API key is required")
+		return "", false
+	}
+	return principal, true
+}
+
+// GetRankingProfile returns the calling user's or API key's ranking profile
+// as a {"term": weight} object.
+func GetRankingProfile(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	weights, err := db.GetRankingProfile(principal)
+	if err != nil {
+		log.Printf("Error fetching ranking profile: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, weights)
+}
+
+// SetRankingProfile replaces the calling user's or API key's ranking profile
+// with a {"term": weight} object. Positive weights boost articles mentioning
+// the term on /news, negative ones demote them; {} clears the profile.
+func SetRankingProfile(w http.ResponseWriter, r *http.Request) {
+	principal, ok := requirePrincipal(w, r)
+	if !ok {
+		return
+	}
+	var weights map[string]int
+	if err := decodeJSON(w, r, &weights); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if len(weights) > db.MaxProfileTerms {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("A profile can weigh at most %d terms", db.MaxProfileTerms))
+		return
+	}
+	for term, weight := range weights {
+		if term = strings.TrimSpace(term); term == "" || len(term) > 100 {
+			WriteError(w, http.StatusBadRequest, "Terms must be 1-100 characters")
+			return
+		}
+		if weight < -maxProfileWeight || weight > maxProfileWeight {
+			WriteError(w, http.StatusBadRequest, fmt.Sprintf("Weights must be between -%d and %d", maxProfileWeight, maxProfileWeight))
+			return
+		}
+	}
+	if err := db.SetRankingProfile(principal, weights); err != nil {
+		log.Printf("Error saving ranking profile: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	GetRankingProfile(w, r)
+}
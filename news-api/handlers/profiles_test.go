@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankingProfile(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	user, err := db.CreateUser("analyst", "correct horse battery")
+	require.NoError(t, err)
+	token, _, err := db.CreateSession(user.ID, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Startup closes funding round", URL: "u1", Rank: 8, PublishedAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Exploit hits OT/ICS networks", URL: "u2", Rank: 3, PublishedAt: time.Now().Add(-2 * time.Hour)}))
+
+	serve := func(method, target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /news", GetNews)
+		mux.HandleFunc("GET /me/ranking-profile", GetRankingProfile)
+		mux.HandleFunc("PUT /me/ranking-profile", SetRankingProfile)
+		rr := httptest.NewRecorder()
+		ScopeOrg(mux).ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("PUT", "/me/ranking-profile", `{"ot/ics": 10}`, "").Code)
+	assert.Equal(t, http.StatusBadRequest, serve("PUT", "/me/ranking-profile", `{"ot/ics": 1000}`, token).Code)
+
+	rr := serve("PUT", "/me/ranking-profile", `{"OT/ICS": 10, "funding": -5}`, token)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var weights map[string]int
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&weights))
+	assert.Equal(t, map[string]int{"ot/ics": 10, "funding": -5}, weights)
+
+	urls := func(rr *httptest.ResponseRecorder) []string {
+		var articles []models.NewsArticle
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+		var urls []string
+		for _, a := range articles {
+			urls = append(urls, a.URL)
+		}
+		return urls
+	}
+	assert.Equal(t, []string{"u2", "u1"}, urls(serve("GET", "/news?sortBy=rank", "", token)))
+	assert.Equal(t, []string{"u1", "u2"}, urls(serve("GET", "/news?sortBy=rank&personalize=false", "", token)))
+	assert.Equal(t, []string{"u1", "u2"}, urls(serve("GET", "/news?sortBy=rank", "", "")))
+}
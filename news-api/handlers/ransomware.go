@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+)
+
+// GetRansomwareLog returns the mentions of ransomware groups, newest first,
+// with the victims each article names. It accepts a group (a name or alias),
+// the source, category and date filters of /news, and limit and offset.
+func GetRansomwareLog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	group := strings.TrimSpace(query.Get("group"))
+	if group != "" {
+		canonical, ok := db.RansomwareGroups.Canonical(group)
+		if !ok {
+			params.invalid("group %q is not a known ransomware group", group)
+		}
+		group = canonical
+	}
+	startDate, endDate := params.dateRange()
+	filter := db.ArticleFilter{
+		OrgID:     OrgFromContext(r.Context()),
+		Source:    query.Get("source"),
+		Category:  query.Get("category"),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Limit:     params.limit(DefaultPageSize, MaxPageSize),
+		Offset:    params.offset(),
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	mentions, err := db.GetRansomwareLog(filter, group)
+	if err != nil {
+		log.Printf("Error fetching ransomware log: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	setNextPageLink(w, r, filter.Offset, filter.Limit, len(mentions))
+	writeJSON(w, http.StatusOK, mentions)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRansomwareLog(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Noberus hits Change Healthcare", URL: "u1", PublishedAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Akira ransomware evolves", URL: "u2", PublishedAt: time.Now().Add(-2 * time.Hour)}))
+
+	rr := httptest.NewRecorder()
+	GetRansomwareLog(rr, httptest.NewRequest("GET", "/ransomware?group=alphv", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var mentions []models.RansomwareMention
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&mentions))
+	require.Len(t, mentions, 1)
+	assert.Equal(t, "BlackCat", mentions[0].Group)
+	assert.Equal(t, "u1", mentions[0].Article.URL)
+
+	rr = httptest.NewRecorder()
+	GetRansomwareLog(rr, httptest.NewRequest("GET", "/ransomware?group=nobody", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
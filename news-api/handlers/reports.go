@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/integrations"
+	"news-api/models"
+)
+
+// Reports runs the scheduled report jobs. It is nil, and the report endpoints
+// answer 404, on instances that do not deliver reports.
+var Reports *integrations.ReportScheduler
+
+const (
+	defaultReportArticles = 50
+	maxReportArticles     = 500
+	// defaultReportRuns and maxReportRuns bound the run history returned at once.
+	defaultReportRuns = 20
+	maxReportRuns     = 100
+)
+
+type reportJobRequest struct {
+	Name        string `json:"name"`
+	OrgID       int64  `json:"orgId"`
+	Source      string `json:"source"`
+	Category    string `json:"category"`
+	Tag         string `json:"tag"`
+	Search      string `json:"search"`
+	MinSeverity int    `json:"minSeverity"`
+	MaxArticles int    `json:"maxArticles"`
+	Format      string `json:"format"`
+	Schedule    string `json:"schedule"`
+	Channel     string `json:"channel"`
+	Target      string `json:"target"`
+	TemplateID  int64  `json:"templateId"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+func requireReports(w http.ResponseWriter) bool {
+	if Reports == nil {
+		WriteError(w, http.StatusNotFound, "Scheduled reports are not enabled")
+		return false
+	}
+	return true
+}
+
+// reportJobFromRequest validates a report job definition, writing a 400
+// response if it is invalid.
+func reportJobFromRequest(w http.ResponseWriter, r *http.Request) (models.ReportJob, bool) {
+	var req reportJobRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return models.ReportJob{}, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		WriteError(w, http.StatusBadRequest, "Name must be 1-100 characters")
+		return models.ReportJob{}, false
+	}
+	if req.MinSeverity < 0 || req.MinSeverity > 100 {
+		WriteError(w, http.StatusBadRequest, "minSeverity must be between 0 and 100")
+		return models.ReportJob{}, false
+	}
+	if req.MaxArticles == 0 {
+		req.MaxArticles = defaultReportArticles
+	}
+	if req.MaxArticles < 1 || req.MaxArticles > maxReportArticles {
+		WriteError(w, http.StatusBadRequest, "maxArticles must be between 1 and "+strconv.Itoa(maxReportArticles))
+		return models.ReportJob{}, false
+	}
+	if req.Format == "" {
+		req.Format = "markdown"
+	}
+	if !slices.Contains(integrations.ReportFormats, req.Format) {
+		WriteError(w, http.StatusBadRequest, "format must be one of "+strings.Join(integrations.ReportFormats, ", "))
+		return models.ReportJob{}, false
+	}
+	next, err := integrations.NextRun(req.Schedule, time.Now())
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid schedule: "+err.Error())
+		return models.ReportJob{}, false
+	}
+	if err := Reports.ValidateTarget(req.Channel, req.Target); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid delivery: "+err.Error())
+		return models.ReportJob{}, false
+	}
+	if req.TemplateID != 0 {
+		if req.Channel == "s3" {
+			WriteError(w, http.StatusBadRequest, "s3 reports cannot use a message template")
+			return models.ReportJob{}, false
+		}
+		if !checkMessageTemplate(w, req.TemplateID, req.Channel) {
+			return models.ReportJob{}, false
+		}
+	}
+	if req.OrgID != 0 {
+		if _, err := db.GetOrganization(req.OrgID); errors.Is(err, sql.ErrNoRows) {
+			WriteError(w, http.StatusNotFound, "Organization not found")
+			return models.ReportJob{}, false
+		} else if err != nil {
+			log.Printf("Error fetching organization: %v", err)
+			WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return models.ReportJob{}, false
+		}
+	}
+	return models.ReportJob{
+		OrgID:       req.OrgID,
+		Name:        req.Name,
+		Source:      req.Source,
+		Category:    req.Category,
+		Tag:         req.Tag,
+		Search:      req.Search,
+		MinSeverity: req.MinSeverity,
+		MaxArticles: req.MaxArticles,
+		Format:      req.Format,
+		Schedule:    strings.TrimSpace(req.Schedule),
+		Channel:     req.Channel,
+		Target:      req.Target,
+		TemplateID:  req.TemplateID,
+		Enabled:     req.Enabled == nil || *req.Enabled,
+		NextRunAt:   next,
+	}, true
+}
+
+// CreateReportJob defines a scheduled report.
+func CreateReportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	job, ok := reportJobFromRequest(w, r)
+	if !ok {
+		return
+	}
+	job, err := db.CreateReportJob(job)
+	if errors.Is(err, db.ErrReportJobExists) {
+		WriteError(w, http.StatusConflict, "A report with this name already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating report job: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "report.create", reportTarget(job.ID), nil, job)
+	writeJSON(w, http.StatusCreated, job)
+}
+
+// GetReportJobs lists the scheduled reports.
+func GetReportJobs(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	jobs, err := db.GetReportJobs()
+	if err != nil {
+		log.Printf("Error fetching report jobs: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// GetReportJob returns the scheduled report in the {id} path segment.
+func GetReportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	if job, ok := reportJobFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// UpdateReportJob replaces the definition of the scheduled report in the {id}
+// path segment. Its next run is computed again from the schedule.
+func UpdateReportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	before, ok := reportJobFromPath(w, r)
+	if !ok {
+		return
+	}
+	job, ok := reportJobFromRequest(w, r)
+	if !ok {
+		return
+	}
+	job.ID = before.ID
+	err := db.UpdateReportJob(job)
+	if errors.Is(err, db.ErrReportJobExists) {
+		WriteError(w, http.StatusConflict, "A report with this name already exists")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating report job: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	job, err = db.GetReportJob(job.ID)
+	if err != nil {
+		log.Printf("Error fetching report job: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "report.update", reportTarget(job.ID), before, job)
+	writeJSON(w, http.StatusOK, job)
+}
+
+// DeleteReportJob deletes the scheduled report in the {id} path segment and
+// its run history.
+func DeleteReportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	before, ok := reportJobFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := db.DeleteReportJob(before.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting report job: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "report.delete", reportTarget(before.ID), before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetReportRuns returns the most recent runs of the scheduled report in the
+// {id} path segment, newest first.
+func GetReportRuns(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	params := newQueryParams(r)
+	limit := params.limit(defaultReportRuns, maxReportRuns)
+	if !params.valid(w) {
+		return
+	}
+	job, ok := reportJobFromPath(w, r)
+	if !ok {
+		return
+	}
+	runs, err := db.GetReportRuns(job.ID, limit)
+	if err != nil {
+		log.Printf("Error fetching report runs: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+// RunReportJob runs the scheduled report in the {id} path segment now and
+// returns the run. Its next scheduled run is computed from now.
+func RunReportJob(w http.ResponseWriter, r *http.Request) {
+	if !requireReports(w) {
+		return
+	}
+	job, ok := reportJobFromPath(w, r)
+	if !ok {
+		return
+	}
+	recordAudit(r, "report.run", reportTarget(job.ID), nil, nil)
+	writeJSON(w, http.StatusOK, Reports.Run(job))
+}
+
+func reportJobFromPath(w http.ResponseWriter, r *http.Request) (models.ReportJob, bool) {
+	id, ok := idFromPath(w, r, "id", "report")
+	if !ok {
+		return models.ReportJob{}, false
+	}
+	job, err := db.GetReportJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Report not found")
+		return models.ReportJob{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching report job: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.ReportJob{}, false
+	}
+	return job, true
+}
+
+func reportTarget(id int64) string {
+	return "report:" + strconv.FormatInt(id, 10)
+}
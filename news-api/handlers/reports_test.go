@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"news-api/integrations"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportJobs(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	defer func() { Reports = nil }()
+	// The test webhook listens on loopback.
+	integrations.AllowPrivateWebhooks = true
+	defer func() { integrations.AllowPrivateWebhooks = false }()
+
+	var delivered string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		delivered = string(data)
+	}))
+	defer hook.Close()
+
+	call := func(handler http.HandlerFunc, method, target string, body interface{}, id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler(rr, adminRequest(method, target, body, id))
+		return rr
+	}
+	definition := map[string]interface{}{"name": "Morning brief", "schedule": "0 7 * * 1-5", "channel": "webhook", "target": hook.URL, "format": "html"}
+	assert.Equal(t, http.StatusNotFound, call(CreateReportJob, "POST", "/admin/reports", definition, "").Code, "reports are not enabled")
+
+	Reports = &integrations.ReportScheduler{}
+	for _, bad := range []map[string]interface{}{
+		{"name": "", "schedule": "@daily", "channel": "webhook", "target": hook.URL},
+		{"name": "x", "schedule": "61 * * * *", "channel": "webhook", "target": hook.URL},
+		{"name": "x", "schedule": "@daily", "channel": "fax", "target": hook.URL},
+		{"name": "x", "schedule": "@daily", "channel": "email", "target": "soc@example.com"},
+		{"name": "x", "schedule": "@daily", "channel": "webhook", "target": hook.URL, "format": "pdf"},
+		{"name": "x", "schedule": "@daily", "channel": "webhook", "target": hook.URL, "maxArticles": 1000},
+	} {
+		assert.Equal(t, http.StatusBadRequest, call(CreateReportJob, "POST", "/admin/reports", bad, "").Code, bad)
+	}
+
+	rr := call(CreateReportJob, "POST", "/admin/reports", definition, "")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var job models.ReportJob
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.Equal(t, defaultReportArticles, job.MaxArticles)
+	assert.True(t, job.Enabled)
+	assert.False(t, job.NextRunAt.IsZero())
+	id := strconv.FormatInt(job.ID, 10)
+	assert.Equal(t, http.StatusConflict, call(CreateReportJob, "POST", "/admin/reports", definition, "").Code)
+
+	definition["enabled"] = false
+	definition["category"] = "Cybersecurity"
+	rr = call(UpdateReportJob, "PUT", "/admin/reports/"+id, definition, id)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+	assert.False(t, job.Enabled)
+	assert.Equal(t, "Cybersecurity", job.Category)
+
+	rr = call(RunReportJob, "POST", "/admin/reports/"+id+"/run", nil, id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var run models.ReportRun
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &run))
+	assert.Equal(t, "succeeded", run.Status)
+	assert.Contains(t, delivered, "<h1>Morning brief</h1>")
+
+	rr = call(GetReportRuns, "GET", "/admin/reports/"+id+"/runs", nil, id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var runs []models.ReportRun
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &runs))
+	assert.Len(t, runs, 1)
+	assert.Equal(t, http.StatusBadRequest, call(GetReportRuns, "GET", "/admin/reports/"+id+"/runs?limit=0", nil, id).Code)
+
+	rr = call(GetReportJobs, "GET", "/admin/reports", nil, "")
+	var jobs []models.ReportJob
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &jobs))
+	require.Len(t, jobs, 1)
+	assert.NotNil(t, jobs[0].LastSuccessAt)
+
+	assert.Equal(t, http.StatusNoContent, call(DeleteReportJob, "DELETE", "/admin/reports/"+id, nil, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(GetReportJob, "GET", "/admin/reports/"+id, nil, id).Code)
+}
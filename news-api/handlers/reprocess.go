@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"news-api/db"
+)
+
+// GetRawItem returns the archived feed item the article {id} was built from.
+func GetRawItem(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	item, err := db.GetRawItem(articleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "No archived item for this article")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching archived item: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// ReprocessArticles re-runs enrichment over all articles from their archived
+// feed items and reports how many were updated.
+func ReprocessArticles(w http.ResponseWriter, r *http.Request) {
+	updated, err := db.ReprocessArticles()
+	if err != nil {
+		log.Printf("Error reprocessing articles after %d updates: %v", updated, err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "articles.reprocess", "articles", nil, map[string]int{"reprocessed": updated})
+	writeJSON(w, http.StatusOK, map[string]int{"reprocessed": updated})
+}
+
+// GetEnrichmentStatus reports the enrichment stages, how many articles each is
+// current and stale for, and how many articles are queued for reprocessing.
+func GetEnrichmentStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := db.GetEnrichmentStatus()
+	if err != nil {
+		log.Printf("Error fetching enrichment status: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// EnqueueReprocess queues the articles with stale enrichment, or all of them
+// with ?all=true, for the background reprocessing worker.
+func EnqueueReprocess(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	all := params.boolean("all")
+	if !params.valid(w) {
+		return
+	}
+	queued, err := db.EnqueueReprocess(all)
+	if err != nil {
+		log.Printf("Error queuing articles for reprocessing: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "articles.queue_reprocess", "articles", nil, map[string]interface{}{"all": all, "queued": queued})
+	writeJSON(w, http.StatusAccepted, map[string]int{"queued": queued})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"news-api/db"
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+// startedAt is when the process started, for the uptime in /admin/runtime.
+var startedAt = time.Now()
+
+type heapStats struct {
+	AllocBytes    uint64 `json:"allocBytes"`
+	InuseBytes    uint64 `json:"inuseBytes"`
+	IdleBytes     uint64 `json:"idleBytes"`
+	ReleasedBytes uint64 `json:"releasedBytes"`
+	SysBytes      uint64 `json:"sysBytes"`
+	Objects       uint64 `json:"objects"`
+	TotalAlloc    uint64 `json:"totalAllocBytes"`
+	NumGC         uint32 `json:"numGC"`
+	// LastGC is when the last garbage collection finished, if any ran.
+	LastGC         *time.Time `json:"lastGC,omitempty"`
+	GCPauseTotalMs float64    `json:"gcPauseTotalMs"`
+}
+
+type dbPoolStats struct {
+	OpenConnections   int     `json:"openConnections"`
+	InUse             int     `json:"inUse"`
+	Idle              int     `json:"idle"`
+	MaxOpen           int     `json:"maxOpen"`
+	WaitCount         int64   `json:"waitCount"`
+	WaitMs            float64 `json:"waitMs"`
+	MaxIdleClosed     int64   `json:"maxIdleClosed"`
+	MaxLifetimeClosed int64   `json:"maxLifetimeClosed"`
+}
+
+type runtimeResponse struct {
+	GoVersion     string                       `json:"goVersion"`
+	UptimeSeconds int64                        `json:"uptimeSeconds"`
+	Goroutines    int                          `json:"goroutines"`
+	CPUs          int                          `json:"cpus"`
+	Heap          heapStats                    `json:"heap"`
+	DBPool        dbPoolStats                  `json:"dbPool"`
+	Caches        map[string]models.CacheStats `json:"caches"`
+}
+
+// GetRuntime reports the process's goroutines, heap, database pool and cache
+// hit rates, to diagnose memory growth in the long-running jobs. Profiles are
+// available under /debug/pprof/.
+func GetRuntime(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	heap := heapStats{
+		AllocBytes:     mem.HeapAlloc,
+		InuseBytes:     mem.HeapInuse,
+		IdleBytes:      mem.HeapIdle,
+		ReleasedBytes:  mem.HeapReleased,
+		SysBytes:       mem.Sys,
+		Objects:        mem.HeapObjects,
+		TotalAlloc:     mem.TotalAlloc,
+		NumGC:          mem.NumGC,
+		GCPauseTotalMs: float64(mem.PauseTotalNs) / 1e6,
+	}
+	if mem.LastGC > 0 {
+		lastGC := time.Unix(0, int64(mem.LastGC)).UTC()
+		heap.LastGC = &lastGC
+	}
+
+	pool := db.PoolStats()
+
+	// The fetcher cache is revalidated rather than looked up, so a hit is a
+	// request answered with 304 from the cache.
+	var requests, cacheHits int64
+	for _, s := range fetcher.Stats() {
+		requests += int64(s.Requests)
+		cacheHits += int64(s.CacheHits)
+	}
+	entries, bytes := fetcher.CacheSize()
+
+	writeJSON(w, http.StatusOK, runtimeResponse{
+		GoVersion:     runtime.Version(),
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		CPUs:          runtime.NumCPU(),
+		Heap:          heap,
+		DBPool: dbPoolStats{
+			OpenConnections:   pool.OpenConnections,
+			InUse:             pool.InUse,
+			Idle:              pool.Idle,
+			MaxOpen:           pool.MaxOpenConnections,
+			WaitCount:         pool.WaitCount,
+			WaitMs:            float64(pool.WaitDuration) / float64(time.Millisecond),
+			MaxIdleClosed:     pool.MaxIdleClosed,
+			MaxLifetimeClosed: pool.MaxLifetimeClosed,
+		},
+		Caches: map[string]models.CacheStats{
+			"fetcher":       models.NewCacheStats(entries, bytes, cacheHits, requests-cacheHits),
+			"thumbnails":    imageCache.stats(),
+			"previewImages": db.PreviewImageCacheStats(),
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRuntime(t *testing.T) {
+	setupTestDB(t)
+	cache := newThumbnailCache(1 << 20)
+	defer func(c *thumbnailCache) { imageCache = c }(imageCache)
+	imageCache = cache
+	cache.add(&thumbnail{key: "a", data: []byte("img")})
+	cache.get("a")
+	cache.get("a")
+	cache.get("b")
+
+	rr := httptest.NewRecorder()
+	GetRuntime(rr, httptest.NewRequest(http.MethodGet, "/admin/runtime", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp runtimeResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Greater(t, resp.Goroutines, 0)
+	assert.Greater(t, resp.Heap.SysBytes, uint64(0))
+	assert.Greater(t, resp.DBPool.MaxOpen+resp.DBPool.OpenConnections, 0)
+	thumbs := resp.Caches["thumbnails"]
+	assert.Equal(t, 1, thumbs.Entries)
+	assert.Equal(t, int64(2), thumbs.Hits)
+	assert.Equal(t, int64(1), thumbs.Misses)
+	assert.InDelta(t, 2.0/3, thumbs.HitRate, 0.001)
+	assert.Contains(t, resp.Caches, "fetcher")
+	assert.Contains(t, resp.Caches, "previewImages")
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"news-api/db"
+	"news-api/integrations"
+	"news-api/models"
+	"news-api/webhook"
+)
+
+type savedSearchRequest struct {
+	Name        string `json:"name"`
+	Source      string `json:"source"`
+	Category    string `json:"category"`
+	Search      string `json:"search"`
+	MinRank     int    `json:"minRank"`
+	MinSeverity int    `json:"minSeverity"`
+	NotifyURL   string `json:"notifyUrl"`
+	TemplateID  int64  `json:"templateId"`
+}
+
+// CreateSavedSearch stores a named filter combination for the authenticated user.
+func CreateSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	var req savedSearchRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		WriteError(w, http.StatusBadRequest, "Name must be 1-100 characters")
+		return
+	}
+	if req.MinRank < 0 {
+		WriteError(w, http.StatusBadRequest, "minRank must not be negative")
+		return
+	}
+	if req.MinSeverity < 0 || req.MinSeverity > 100 {
+		WriteError(w, http.StatusBadRequest, "minSeverity must be between 0 and 100")
+		return
+	}
+	if req.NotifyURL != "" {
+		u, err := url.Parse(req.NotifyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			WriteError(w, http.StatusBadRequest, "notifyUrl must be an http or https URL")
+			return
+		}
+		// Host names resolving to internal addresses are refused when the
+		// webhook is sent; this catches the obvious cases up front.
+		if !integrations.AllowPrivateWebhooks && !publicHost(u.Hostname()) {
+			WriteError(w, http.StatusBadRequest, "notifyUrl must not point to a loopback or private address")
+			return
+		}
+	}
+	if req.TemplateID != 0 {
+		if req.NotifyURL == "" {
+			WriteError(w, http.StatusBadRequest, "templateId requires a notifyUrl")
+			return
+		}
+		if !checkMessageTemplate(w, req.TemplateID, "webhook", "slack") {
+			return
+		}
+	}
+
+	search, err := db.CreateSavedSearch(models.SavedSearch{
+		UserID:      user.ID,
+		Name:        req.Name,
+		Source:      req.Source,
+		Category:    req.Category,
+		Search:      req.Search,
+		MinRank:     req.MinRank,
+		MinSeverity: req.MinSeverity,
+		NotifyURL:   req.NotifyURL,
+		TemplateID:  req.TemplateID,
+	})
+	if errors.Is(err, db.ErrSavedSearchExists) {
+		WriteError(w, http.StatusConflict, "A saved search with this name already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating saved search: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusCreated, search)
+}
+
+// GetSavedSearches lists the authenticated user's saved searches.
+func GetSavedSearches(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	searches, err := db.GetSavedSearches(user.ID)
+	if err != nil {
+		log.Printf("Error fetching saved searches: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, searches)
+}
+
+// RunSavedSearch returns the articles matching the saved search in the {id}
+// path segment. It accepts the same limit, offset and sortBy parameters as /news.
+func RunSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	id, ok := savedSearchIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	search, err := db.GetSavedSearch(user.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Saved search not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching saved search: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	params := newQueryParams(r)
+	filter := db.SavedSearchFilter(search)
+	filter.Limit = params.limit(DefaultPageSize, MaxPageSize)
+	filter.Offset = params.offset()
+	filter.SortBy = params.oneOf("sortBy", db.ArticleSortKeys...)
+	if !params.valid(w) {
+		return
+	}
+
+	articles, err := db.QueryArticles(filter)
+	if err != nil {
+		log.Printf("Error running saved search: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if articles == nil {
+		articles = []models.NewsArticle{}
+	}
+	setNextPageLink(w, r, filter.Offset, filter.Limit, len(articles))
+	writeJSON(w, http.StatusOK, articles)
+}
+
+// DeleteSavedSearch removes the saved search in the {id} path segment.
+func DeleteSavedSearch(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	id, ok := savedSearchIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := db.DeleteSavedSearch(user.ID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Saved search not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting saved search: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func savedSearchIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	return idFromPath(w, r, "id", "saved search")
+}
+
+// publicHost reports whether a URL host may be public: it is not localhost or
+// a non-public IP address.
+func publicHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return webhook.IsPublicIP(ip)
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createSavedSearch(t *testing.T, token string, req savedSearchRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest("POST", "/saved-searches", bytes.NewReader(body))
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	RequireUser(CreateSavedSearch)(rr, httpReq)
+	return rr
+}
+
+func TestSavedSearchEndpoints(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	token := registerUser(t, "analyst")
+
+	rr := createSavedSearch(t, token, savedSearchRequest{Name: "Cyber", Category: "Cybersecurity", MinRank: 9})
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var search models.SavedSearch
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&search))
+	id := strconv.FormatInt(search.ID, 10)
+
+	rr = createSavedSearch(t, token, savedSearchRequest{Name: "Cyber"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = createSavedSearch(t, token, savedSearchRequest{Name: "Hook", NotifyURL: "file:///etc/passwd"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	for _, target := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/", "http://localhost/hook"} {
+		rr = createSavedSearch(t, token, savedSearchRequest{Name: "Hook", NotifyURL: target})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, target)
+	}
+
+	rr = httptest.NewRecorder()
+	RequireUser(GetSavedSearches)(rr, authedRequest("GET", "/saved-searches", token, ""))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var searches []models.SavedSearch
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&searches))
+	require.Len(t, searches, 1)
+	assert.Equal(t, "Cyber", searches[0].Name)
+
+	rr = httptest.NewRecorder()
+	RequireUser(RunSavedSearch)(rr, authedRequest("GET", "/saved-searches/"+id+"/articles", token, id))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var articles []models.NewsArticle
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+	require.Len(t, articles, 1)
+	assert.Equal(t, "Cyber Article 1", articles[0].Title)
+
+	// Other users cannot see or delete the search.
+	other := registerUser(t, "intruder")
+	rr = httptest.NewRecorder()
+	RequireUser(RunSavedSearch)(rr, authedRequest("GET", "/saved-searches/"+id+"/articles", other, id))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = httptest.NewRecorder()
+	RequireUser(DeleteSavedSearch)(rr, authedRequest("DELETE", "/saved-searches/"+id, other, id))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(DeleteSavedSearch)(rr, authedRequest("DELETE", "/saved-searches/"+id, token, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// PublicURL is the external base URL of the service, used to build short
+// links. When empty, it is derived from the request.
+var PublicURL string
+
+// landingRedirectSeconds is how long the short link landing page is shown
+// before the browser moves on to the publisher.
+const landingRedirectSeconds = 5
+
+var landingPage = template.Must(template.New("landing").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="{{.Delay}}; url={{.Redirect}}">
+<title>{{.Article.Title}}</title>
+<meta property="og:type" content="article">
+<meta property="og:title" content="{{.Article.Title}}">
+<meta property="og:description" content="{{.Summary}}">
+<meta property="og:url" content="{{.Article.URL}}">
+{{- if .Article.ImageURL}}
+<meta property="og:image" content="{{.Article.ImageURL}}">
+{{- end}}
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; color: #222; }
+.meta { color: #666; font-size: 0.9em; }
+.tag { display: inline-block; background: #eee; border-radius: 3px; padding: 0 0.4em; margin: 0 0.2em 0.2em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Article.Title}}</h1>
+<p class="meta">{{.Publisher}} &middot; {{.Article.PublishedAt.Format "2 Jan 2006 15:04 MST"}} &middot; {{.Article.Category}}</p>
+<p>{{.Summary}}</p>
+<p><strong>Severity {{.Article.Severity}}/100</strong>{{if .ThreatLevel}} &middot; current threat level: <strong>{{.ThreatLevel}}</strong>{{end}}</p>
+{{- if .Article.Tags}}
+<p>{{range .Article.Tags}}<span class="tag">{{.}}</span>{{end}}</p>
+{{- end}}
+<p><a href="{{.Redirect}}">Continue to {{.Publisher}}</a> (redirecting in {{.Delay}} seconds)</p>
+</body>
+</html>
+`))
+
+type landingPageData struct {
+	Article     models.NewsArticle
+	Summary     string
+	Publisher   string
+	ThreatLevel string
+	Redirect    string
+	Delay       int
+}
+
+type shareResponse struct {
+	ID      int64  `json:"id"`
+	ShortID string `json:"shortId"`
+	URL     string `json:"url"`
+}
+
+// ShareArticle returns the short link of the article {id}.
+func ShareArticle(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	_, err := db.GetOrgArticle(OrgFromContext(r.Context()), articleID, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	shortID := models.ShortID(articleID)
+	writeJSON(w, http.StatusOK, shareResponse{ID: articleID, ShortID: shortID, URL: baseURL(r) + "/a/" + shortID})
+}
+
+// ShowShortLink renders the landing page of the short link /a/{shortid}: the
+// article's title, summary, severity and tags with the current threat level,
+// before redirecting to the publisher through /r/{id} so the click is counted.
+func ShowShortLink(w http.ResponseWriter, r *http.Request) {
+	articleID, ok := models.ParseShortID(r.PathValue("shortid"))
+	if !ok {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	orgID := OrgFromContext(r.Context())
+	article, err := db.GetOrgArticle(orgID, articleID, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	data := landingPageData{
+		Article:   article,
+		Summary:   article.Description,
+		Publisher: publisher(article),
+		Redirect:  "/r/" + strconv.FormatInt(articleID, 10),
+		Delay:     landingRedirectSeconds,
+	}
+	// The page is still useful without the threat level.
+	if score, err := db.GetOrgThreatScore(orgID); err != nil {
+		log.Printf("Error getting threat score for short link: %v", err)
+	} else {
+		data.ThreatLevel = score.ThreatLevel
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := landingPage.Execute(w, data); err != nil {
+		log.Printf("Error rendering short link page: %v", err)
+	}
+}
+
+// baseURL returns PublicURL, or the scheme and host the request was made to.
+func baseURL(r *http.Request) string {
+	if PublicURL != "" {
+		return strings.TrimRight(PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShortLinks(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Zero-day <exploited>", Description: "Attackers exploit CVE-2024-1234 in the wild.",
+		URL: "https://www.example.com/zero-day", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour),
+	}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	req := httptest.NewRequest("GET", "/news/"+id+"/share", nil)
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	ShareArticle(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var share shareResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&share))
+	assert.Equal(t, models.ShortID(articles[0].ID), share.ShortID)
+	assert.Equal(t, "http://example.com/a/"+share.ShortID, share.URL)
+
+	PublicURL = "https://threatfeed.example.org/"
+	defer func() { PublicURL = "" }()
+	rr = httptest.NewRecorder()
+	ShareArticle(rr, req)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&share))
+	assert.Equal(t, "https://threatfeed.example.org/a/"+share.ShortID, share.URL)
+
+	show := func(shortID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/a/"+shortID, nil)
+		req.SetPathValue("shortid", shortID)
+		rr := httptest.NewRecorder()
+		ShowShortLink(rr, req)
+		return rr
+	}
+	rr = show(share.ShortID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	page := rr.Body.String()
+	assert.Contains(t, page, "Zero-day &lt;exploited&gt;")
+	assert.Contains(t, page, "CVE-2024-1234")
+	assert.Contains(t, page, `url=/r/`+id)
+	assert.Contains(t, page, "Continue to example.com")
+	assert.Equal(t, http.StatusNotFound, show("zzzzzz").Code)
+	assert.Equal(t, http.StatusNotFound, show("not-an-id").Code)
+}
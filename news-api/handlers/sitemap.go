@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// Sitemaps enables /sitemap.xml and /news-sitemap.xml. Private deployments
+// leave it off so that search engines are not pointed at their articles.
+var Sitemaps bool
+
+const (
+	// sitemapPageSize is the number of article IDs covered by each sitemap
+	// page, well below the 50,000 URLs a sitemap may list.
+	sitemapPageSize = 10000
+	// newsSitemapMaxAge and newsSitemapMaxURLs are the limits Google News puts
+	// on the articles of a news sitemap.
+	newsSitemapMaxAge  = 48 * time.Hour
+	newsSitemapMaxURLs = 1000
+	sitemapMaxAge      = "3600"
+
+	sitemapNamespace     = "http://www.sitemaps.org/schemas/sitemap/0.9"
+	newsSitemapNamespace = "http://www.google.com/schemas/sitemap-news/0.9"
+)
+
+type sitemapIndex struct {
+	XMLName   xml.Name       `xml:"sitemapindex"`
+	Namespace string         `xml:"xmlns,attr"`
+	Sitemaps  []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName   xml.Name     `xml:"urlset"`
+	Namespace string       `xml:"xmlns,attr"`
+	News      string       `xml:"xmlns:news,attr,omitempty"`
+	URLs      []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string       `xml:"loc"`
+	LastMod string       `xml:"lastmod,omitempty"`
+	News    *sitemapNews `xml:"news:news"`
+}
+
+type sitemapNews struct {
+	Publication struct {
+		Name     string `xml:"news:name"`
+		Language string `xml:"news:language"`
+	} `xml:"news:publication"`
+	PublicationDate string `xml:"news:publication_date"`
+	Title           string `xml:"news:title"`
+}
+
+func requireSitemaps(w http.ResponseWriter) bool {
+	if !Sitemaps {
+		WriteError(w, http.StatusNotFound, "Sitemaps are not enabled")
+		return false
+	}
+	return true
+}
+
+// GetSitemap serves the sitemap of the short link landing pages of the shared
+// feed. /sitemap.xml is an index of the pages, /sitemap.xml?page=N, and of the
+// news sitemap. Page N lists the articles with IDs from (N-1)*10000+1 to
+// N*10000.
+func GetSitemap(w http.ResponseWriter, r *http.Request) {
+	if !requireSitemaps(w) {
+		return
+	}
+	params := newQueryParams(r)
+	page := params.intRange("page", 0, 1, math.MaxInt)
+	if !params.valid(w) {
+		return
+	}
+	lastID, err := db.LastArticleID()
+	if err != nil {
+		log.Printf("Error fetching last article ID: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	pages := int((lastID + sitemapPageSize - 1) / sitemapPageSize)
+	base := baseURL(r)
+
+	if page == 0 {
+		index := sitemapIndex{Namespace: sitemapNamespace, Sitemaps: []sitemapEntry{{Loc: base + "/news-sitemap.xml"}}}
+		for p := 1; p <= pages; p++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapEntry{Loc: base + "/sitemap.xml?page=" + strconv.Itoa(p)})
+		}
+		writeSitemap(w, index)
+		return
+	}
+	if page > pages {
+		WriteError(w, http.StatusNotFound, "Sitemap page not found")
+		return
+	}
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		AfterID:  int64(page-1) * sitemapPageSize,
+		BeforeID: int64(page)*sitemapPageSize + 1,
+		SortBy:   "idAsc",
+	})
+	if err != nil {
+		log.Printf("Error fetching articles for sitemap: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	urls := sitemapURLSet{Namespace: sitemapNamespace, URLs: make([]sitemapURL, 0, len(articles))}
+	for _, article := range articles {
+		urls.URLs = append(urls.URLs, sitemapURL{
+			Loc:     landingPageURL(base, article),
+			LastMod: article.PublishedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	writeSitemap(w, urls)
+}
+
+// GetNewsSitemap serves a Google News sitemap of the landing pages of the
+// articles published in the last 48 hours.
+func GetNewsSitemap(w http.ResponseWriter, r *http.Request) {
+	if !requireSitemaps(w) {
+		return
+	}
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		StartDate: time.Now().Add(-newsSitemapMaxAge),
+		Limit:     newsSitemapMaxURLs,
+	})
+	if err != nil {
+		log.Printf("Error fetching articles for news sitemap: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	base := baseURL(r)
+	urls := sitemapURLSet{Namespace: sitemapNamespace, News: newsSitemapNamespace, URLs: make([]sitemapURL, 0, len(articles))}
+	for _, article := range articles {
+		news := &sitemapNews{PublicationDate: article.PublishedAt.UTC().Format(time.RFC3339), Title: article.Title}
+		news.Publication.Name = "Threatfeed"
+		news.Publication.Language = "en"
+		urls.URLs = append(urls.URLs, sitemapURL{Loc: landingPageURL(base, article), News: news})
+	}
+	writeSitemap(w, urls)
+}
+
+func writeSitemap(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age="+sitemapMaxAge)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Error encoding sitemap: %v", err)
+	}
+}
+
+// landingPageURL returns the short link of an article, whose landing page is
+// what sitemaps list.
+func landingPageURL(base string, article models.NewsArticle) string {
+	return base + "/a/" + models.ShortID(article.ID)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSitemaps(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Fresh & new", URL: "https://example.com/fresh", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour),
+	}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Last week", URL: "https://example.com/old", SourceURL: "s1", Category: "Cybersecurity", PublishedAt: time.Now().Add(-7 * 24 * time.Hour),
+	}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 2)
+	fresh, old := articles[0], articles[1]
+
+	get := func(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusNotFound, get(GetSitemap, "/sitemap.xml").Code, "sitemaps are off by default")
+	assert.Equal(t, http.StatusNotFound, get(GetNewsSitemap, "/news-sitemap.xml").Code)
+	Sitemaps = true
+	defer func() { Sitemaps = false }()
+
+	rr := get(GetSitemap, "/sitemap.xml")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var index sitemapIndex
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &index))
+	page := int((old.ID-1)/sitemapPageSize) + 1
+	require.NotEmpty(t, index.Sitemaps)
+	assert.Equal(t, "http://example.com/news-sitemap.xml", index.Sitemaps[0].Loc)
+	assert.Equal(t, "http://example.com/sitemap.xml?page="+strconv.Itoa(page), index.Sitemaps[len(index.Sitemaps)-1].Loc)
+
+	rr = get(GetSitemap, "/sitemap.xml?page="+strconv.Itoa(page))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", rr.Header().Get("Content-Type"))
+	var urls sitemapURLSet
+	require.NoError(t, xml.Unmarshal(rr.Body.Bytes(), &urls))
+	locs := []string{}
+	for _, u := range urls.URLs {
+		locs = append(locs, u.Loc)
+	}
+	assert.Contains(t, locs, "http://example.com/a/"+models.ShortID(fresh.ID))
+	assert.Contains(t, locs, "http://example.com/a/"+models.ShortID(old.ID))
+	assert.Equal(t, http.StatusNotFound, get(GetSitemap, "/sitemap.xml?page="+strconv.Itoa(page+1)).Code)
+
+	rr = get(GetNewsSitemap, "/news-sitemap.xml")
+	require.Equal(t, http.StatusOK, rr.Code)
+	body := rr.Body.String()
+	assert.Contains(t, body, `xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"`)
+	assert.Contains(t, body, "<news:title>Fresh &amp; new</news:title>")
+	assert.Contains(t, body, "http://example.com/a/"+models.ShortID(fresh.ID))
+	assert.NotContains(t, body, "Last week", "news sitemaps only list the last two days")
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"news-api/db"
+)
+
+// GetSources lists the feeds the caller's articles come from, with each
+// publisher's title, site and icon.
+func GetSources(w http.ResponseWriter, r *http.Request) {
+	sources, err := db.GetSources(OrgFromContext(r.Context()))
+	if err != nil {
+		log.Printf("Error fetching sources: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, sources)
+}
+
+// GetCategories lists the article categories: the built-in ones followed by
+// those defined in the categories file.
+func GetCategories(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, db.CategoryNames())
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// staticAssetMaxAge is how long browsers may cache fingerprinted assets.
+const staticAssetMaxAge = "31536000"
+
+// StaticFiles serves the files of a directory. Each file is also served under
+// a fingerprinted name containing a hash of its content (app.3f2a9c1b2d.js for
+// app.js), which browsers may cache forever since a changed file gets a new
+// name. Plain names must be revalidated on every use. The fingerprinted URLs
+// are listed in manifest.json.
+type StaticFiles struct {
+	dir string
+	// fingerprinted maps fingerprinted names to file names, and hashes file
+	// names to the hashes of their content.
+	fingerprinted map[string]string
+	hashes        map[string]string
+}
+
+// NewStaticFiles hashes the files in dir. Files added or changed later are
+// only picked up on restart.
+func NewStaticFiles(dir string) (*StaticFiles, error) {
+	s := &StaticFiles{dir: dir, fingerprinted: map[string]string{}, hashes: map[string]string{}}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		hash := hex.EncodeToString(h.Sum(nil))[:10]
+		s.hashes[name] = hash
+		s.fingerprinted[fingerprint(name, hash)] = name
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fingerprint inserts hash before the extension of name.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// URL returns the fingerprinted path of a file relative to prefix, or "" if
+// there is no such file.
+func (s *StaticFiles) URL(prefix, name string) string {
+	hash, ok := s.hashes[name]
+	if !ok {
+		return ""
+	}
+	return prefix + fingerprint(name, hash)
+}
+
+// ServeHTTP serves the file named by the request path, which must already
+// have the mount prefix stripped.
+func (s *StaticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" {
+		name = "index.html"
+	}
+	if name == "manifest.json" {
+		if _, ok := s.hashes[name]; !ok {
+			s.serveManifest(w, r)
+			return
+		}
+	}
+
+	if original, ok := s.fingerprinted[name]; ok {
+		w.Header().Set("Cache-Control", "public, max-age="+staticAssetMaxAge+", immutable")
+		s.serveFile(w, r, original)
+		return
+	}
+	if _, ok := s.hashes[name]; !ok {
+		WriteError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	s.serveFile(w, r, name)
+}
+
+// serveManifest lists the fingerprinted URL of each file, relative to the
+// manifest, by file name.
+func (s *StaticFiles) serveManifest(w http.ResponseWriter, r *http.Request) {
+	manifest := make(map[string]string, len(s.hashes))
+	for name := range s.hashes {
+		manifest[name] = s.URL("", name)
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, manifest)
+}
+
+// serveFile serves a file with its content hash as ETag, so that conditional
+// requests are answered with 304.
+func (s *StaticFiles) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := os.Open(filepath.Join(s.dir, filepath.FromSlash(name)))
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		WriteError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	w.Header().Set("ETag", `"`+s.hashes[name]+`"`)
+	http.ServeContent(w, r, name, info.ModTime(), f)
+}
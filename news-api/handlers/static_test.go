@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "css"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "css", "site.css"), []byte("body{}"), 0o644))
+
+	static, err := NewStaticFiles(dir)
+	require.NoError(t, err)
+	handler := http.StripPrefix("/static/", static)
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("/static/manifest.json", nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var manifest map[string]string
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &manifest))
+	require.Len(t, manifest, 2)
+	assert.Regexp(t, `^app\.[0-9a-f]{10}\.js$`, manifest["app.js"])
+	assert.Regexp(t, `^css/site\.[0-9a-f]{10}\.css$`, manifest["css/site.css"])
+	assert.Equal(t, "/static/"+manifest["app.js"], static.URL("/static/", "app.js"))
+
+	rr = get("/static/"+manifest["app.js"], nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "console.log(1)", rr.Body.String())
+	assert.Equal(t, "public, max-age=31536000, immutable", rr.Header().Get("Cache-Control"))
+
+	rr = get("/static/app.js", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-cache", rr.Header().Get("Cache-Control"))
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rr = get("/static/app.js", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	assert.Equal(t, http.StatusNotFound, get("/static/missing.js", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get("/static/app.0000000000.js", nil).Code)
+	assert.Equal(t, http.StatusNotFound, get("/static/../go.mod", nil).Code)
+}
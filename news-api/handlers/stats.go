@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"news-api/db"
+	"news-api/fetcher"
+	"news-api/models"
+)
+
+const (
+	// termStatsArticleLimit caps how many of the newest articles are analyzed.
+	termStatsArticleLimit = 5000
+	maxTermStatsDays      = 90
+	maxTermStatsLimit     = 500
+)
+
+type termStatsResponse struct {
+	Start time.Time              `json:"start"`
+	End   time.Time              `json:"end"`
+	Terms []models.TermFrequency `json:"terms"`
+}
+
+// GetTermStats returns weighted term frequencies for a word cloud. The window is
+// the last days (default 7, at most 90) days, or start/end dates. It accepts the
+// category and source filters of /news and a limit on the number of terms
+// (default 100).
+func GetTermStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	days := params.intRange("days", 7, 1, maxTermStatsDays)
+	limit := params.limit(100, maxTermStatsLimit)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	if endDate.IsZero() {
+		endDate = time.Now()
+	}
+	if startDate.IsZero() {
+		startDate = endDate.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	terms, err := db.GetTermFrequencies(db.ArticleFilter{
+		OrgID:     OrgFromContext(r.Context()),
+		Source:    query.Get("source"),
+		Category:  query.Get("category"),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Limit:     termStatsArticleLimit,
+	}, limit)
+	if err != nil {
+		log.Printf("Error computing term frequencies: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, termStatsResponse{Start: startDate, End: endDate, Terms: terms})
+}
+
+type vendorStatsResponse struct {
+	Start   time.Time               `json:"start"`
+	End     time.Time               `json:"end"`
+	Vendors []models.VendorMentions `json:"vendors"`
+}
+
+// GetVendorStats returns the vendors mentioned by the most articles, with the
+// number of articles naming each of their products. It accepts the window
+// parameters and the category and source filters of /stats/terms and a limit
+// on the number of vendors (default 20).
+func GetVendorStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	days := params.intRange("days", 7, 1, maxTermStatsDays)
+	limit := params.limit(20, maxTermStatsLimit)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	if endDate.IsZero() {
+		endDate = time.Now()
+	}
+	if startDate.IsZero() {
+		startDate = endDate.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	vendors, err := db.GetVendorMentions(db.ArticleFilter{
+		OrgID:     OrgFromContext(r.Context()),
+		Source:    query.Get("source"),
+		Category:  query.Get("category"),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Limit:     termStatsArticleLimit,
+	}, limit)
+	if err != nil {
+		log.Printf("Error counting vendor mentions: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, vendorStatsResponse{Start: startDate, End: endDate, Vendors: vendors})
+}
+
+type sectorStatsResponse struct {
+	Start   time.Time            `json:"start"`
+	End     time.Time            `json:"end"`
+	Sectors []models.SectorCount `json:"sectors"`
+}
+
+// GetSectorStats returns how many articles concern each sector, and how many
+// of those are of high severity. It accepts the window parameters and the
+// category and source filters of /stats/terms.
+func GetSectorStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := newQueryParams(r)
+	days := params.intRange("days", 7, 1, maxTermStatsDays)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+	if endDate.IsZero() {
+		endDate = time.Now()
+	}
+	if startDate.IsZero() {
+		startDate = endDate.Add(-time.Duration(days) * 24 * time.Hour)
+	}
+
+	sectors, err := db.GetSectorCounts(db.ArticleFilter{
+		OrgID:     OrgFromContext(r.Context()),
+		Source:    query.Get("source"),
+		Category:  query.Get("category"),
+		StartDate: startDate,
+		EndDate:   endDate,
+	})
+	if err != nil {
+		log.Printf("Error counting sector articles: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, sectorStatsResponse{Start: startDate, End: endDate, Sectors: sectors})
+}
+
+// GetFetchStats reports the outbound request metrics of each host contacted
+// since startup: requests, errors, cache hits, robots.txt blocks and bytes read.
+func GetFetchStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, fetcher.Stats())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTermStats(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+
+	rr := httptest.NewRecorder()
+	GetTermStats(rr, httptest.NewRequest("GET", "/stats/terms?days=1&category=Cybersecurity", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp termStatsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotEmpty(t, resp.Terms)
+	assert.Equal(t, "cyber", resp.Terms[0].Term)
+	assert.Equal(t, 4, resp.Terms[0].Weight)
+
+	rr = httptest.NewRecorder()
+	GetTermStats(rr, httptest.NewRequest("GET", "/stats/terms?days=365", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestGetVendorStats(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Fortinet patches FortiOS", URL: "u1", PublishedAt: time.Now().Add(-time.Hour)}))
+
+	rr := httptest.NewRecorder()
+	GetVendorStats(rr, httptest.NewRequest("GET", "/stats/vendors?window=7d", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp vendorStatsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Vendors, 1)
+	assert.Equal(t, "Fortinet", resp.Vendors[0].Vendor)
+	assert.Equal(t, 1, resp.Vendors[0].Products["FortiOS"])
+
+	rr = httptest.NewRecorder()
+	GetVendorStats(rr, httptest.NewRequest("GET", "/stats/vendors?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestSectorFilters(t *testing.T) {
+	setupTestDB(t)
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "School district hit by ransomware", URL: "u1", PublishedAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "New phone review", URL: "u2", PublishedAt: time.Now().Add(-time.Hour)}))
+
+	rr := httptest.NewRecorder()
+	GetSectorStats(rr, httptest.NewRequest("GET", "/stats/sectors?days=1", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp sectorStatsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Sectors, 1)
+	assert.Equal(t, "education", resp.Sectors[0].Sector)
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest("GET", "/news?sector=education", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var articles []models.NewsArticle
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&articles))
+	require.Len(t, articles, 1)
+	assert.Equal(t, "u1", articles[0].URL)
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest("GET", "/news?sector=space", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"news-api/db"
+)
+
+// GetTags lists the tags on the caller's articles with their article counts.
+// The optional kind parameter restricts the list to ioc, attack, actor,
+// watchlist, package or manual tags.
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "", db.TagKindIOC, db.TagKindAttack, db.TagKindActor, db.TagKindWatchlist, db.TagKindPackage, db.TagKindManual:
+	default:
+		WriteError(w, http.StatusBadRequest, "kind must be one of ioc, attack, actor, watchlist, package or manual")
+		return
+	}
+	tags, err := db.GetTags(OrgFromContext(r.Context()), kind)
+	if err != nil {
+		log.Printf("Error fetching tags: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, tags)
+}
+
+// AddArticleTag tags the article {id} with {tag}.
+func AddArticleTag(w http.ResponseWriter, r *http.Request) {
+	setArticleTag(w, r, true)
+}
+
+// RemoveArticleTag removes {tag} from the article {id}.
+func RemoveArticleTag(w http.ResponseWriter, r *http.Request) {
+	setArticleTag(w, r, false)
+}
+
+func setArticleTag(w http.ResponseWriter, r *http.Request, add bool) {
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	tag, ok := db.NormalizeTagName(r.PathValue("tag"))
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "Invalid tag")
+		return
+	}
+
+	action, update := "tag.add", db.TagArticle
+	if !add {
+		action, update = "tag.remove", db.UntagArticle
+	}
+	err := update(articleID, tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		if add {
+			WriteError(w, http.StatusNotFound, "Article not found")
+		} else {
+			WriteError(w, http.StatusNotFound, "Tag not found on article")
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating article tags: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, action, "article:"+strconv.FormatInt(articleID, 10), nil, tag)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArticleTagging(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	AdminToken = "admin-secret"
+	defer func() { AdminToken = "" }()
+
+	articles, err := db.QueryArticles(db.ArticleFilter{Search: "ransomware"})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	req := adminRequest("PUT", "/admin/articles/"+id+"/tags/Incident", nil, id)
+	req.SetPathValue("tag", "Incident")
+	rr := httptest.NewRecorder()
+	RequireAdmin(AddArticleTag)(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	req = adminRequest("PUT", "/admin/articles/999/tags/x", nil, "999")
+	req.SetPathValue("tag", "x")
+	rr = httptest.NewRecorder()
+	RequireAdmin(AddArticleTag)(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	GetNews(rr, httptest.NewRequest("GET", "/news?tag=incident", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var tagged []models.NewsArticle
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tagged))
+	require.Len(t, tagged, 1)
+	assert.Equal(t, "Cyber Article 2 about ransomware", tagged[0].Title)
+	assert.Equal(t, []string{"Incident"}, tagged[0].Tags)
+
+	rr = httptest.NewRecorder()
+	GetTags(rr, httptest.NewRequest("GET", "/tags?kind=manual", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var tags []models.Tag
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tags))
+	require.Len(t, tags, 1)
+	assert.Equal(t, "Incident", tags[0].Name)
+
+	rr = httptest.NewRecorder()
+	GetTags(rr, httptest.NewRequest("GET", "/tags?kind=bogus", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req = adminRequest("DELETE", "/admin/articles/"+id+"/tags/Incident", nil, id)
+	req.SetPathValue("tag", "Incident")
+	rr = httptest.NewRecorder()
+	RequireAdmin(RemoveArticleTag)(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireAdmin(RemoveArticleTag)(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	entries, err := db.GetAuditLog(db.AuditFilter{Target: "article:" + id})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "tag.remove", entries[0].Action)
+	assert.Equal(t, "tag.add", entries[1].Action)
+}
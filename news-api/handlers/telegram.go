@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"news-api/db"
+)
+
+// telegramMediaClient downloads post images from the Bot API.
+var telegramMediaClient = &http.Client{Timeout: 20 * time.Second}
+
+// TelegramMedia serves the image of a Telegram post, downloaded through the
+// Bot API so that the bot token stays on the server. Only the images of
+// ingested posts are served.
+func TelegramMedia(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	key := "telegram " + id
+	thumb, ok := imageCache.get(key)
+	if !ok {
+		data, err := db.FetchTelegramFile(telegramMediaClient, id)
+		if errors.Is(err, db.ErrTelegramFileNotFound) {
+			WriteError(w, http.StatusNotFound, "Media not found")
+			return
+		}
+		if err != nil {
+			log.Printf("Error fetching Telegram media %s: %v", id, err)
+			WriteError(w, http.StatusBadGateway, "Bad Gateway")
+			return
+		}
+		contentType := http.DetectContentType(data)
+		if !strings.HasPrefix(contentType, "image/") {
+			log.Printf("Error fetching Telegram media %s: unexpected content type %q", id, contentType)
+			WriteError(w, http.StatusBadGateway, "Bad Gateway")
+			return
+		}
+		thumb = withETag(&thumbnail{key: key, contentType: contentType, data: data})
+		imageCache.add(thumb)
+	}
+	serveThumbnail(w, r, thumb)
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelegramMediaOnlyServesPostImages(t *testing.T) {
+	setupTestDB(t)
+
+	req := httptest.NewRequest("GET", "/telegram/media/unknown", nil)
+	req.SetPathValue("id", "unknown")
+	rr := httptest.NewRecorder()
+	TelegramMedia(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/integrations"
+	"news-api/models"
+)
+
+// previewArticles is the number of recent articles a template preview is
+// rendered with.
+const previewArticles = 5
+
+type messageTemplateRequest struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// messageTemplateFromRequest validates a message template definition, writing
+// a 400 response if it is invalid.
+func messageTemplateFromRequest(w http.ResponseWriter, r *http.Request) (models.MessageTemplate, bool) {
+	var req messageTemplateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return models.MessageTemplate{}, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		WriteError(w, http.StatusBadRequest, "Name must be 1-100 characters")
+		return models.MessageTemplate{}, false
+	}
+	t := models.MessageTemplate{Name: req.Name, Kind: req.Kind, Subject: req.Subject, Body: req.Body}
+	if err := integrations.ValidateMessageTemplate(t); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return models.MessageTemplate{}, false
+	}
+	return t, true
+}
+
+// CreateMessageTemplate stores a message template.
+func CreateMessageTemplate(w http.ResponseWriter, r *http.Request) {
+	t, ok := messageTemplateFromRequest(w, r)
+	if !ok {
+		return
+	}
+	t, err := db.CreateMessageTemplate(t)
+	if errors.Is(err, db.ErrMessageTemplateExists) {
+		WriteError(w, http.StatusConflict, "A template with this name already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "template.create", templateTarget(t.ID), nil, t)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// GetMessageTemplates lists the message templates.
+func GetMessageTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := db.GetMessageTemplates()
+	if err != nil {
+		log.Printf("Error fetching message templates: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// GetMessageTemplate returns the message template in the {id} path segment.
+func GetMessageTemplate(w http.ResponseWriter, r *http.Request) {
+	if t, ok := messageTemplateFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, t)
+	}
+}
+
+// UpdateMessageTemplate replaces the message template in the {id} path
+// segment. Its kind cannot change while report jobs or saved searches use it.
+func UpdateMessageTemplate(w http.ResponseWriter, r *http.Request) {
+	before, ok := messageTemplateFromPath(w, r)
+	if !ok {
+		return
+	}
+	t, ok := messageTemplateFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if t.Kind != before.Kind {
+		WriteError(w, http.StatusBadRequest, "The kind of a template cannot change")
+		return
+	}
+	t.ID = before.ID
+	err := db.UpdateMessageTemplate(t)
+	if errors.Is(err, db.ErrMessageTemplateExists) {
+		WriteError(w, http.StatusConflict, "A template with this name already exists")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Template not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	t, err = db.GetMessageTemplate(t.ID)
+	if err != nil {
+		log.Printf("Error fetching message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "template.update", templateTarget(t.ID), before, t)
+	writeJSON(w, http.StatusOK, t)
+}
+
+// DeleteMessageTemplate deletes the message template in the {id} path
+// segment, unless a report job or saved search uses it.
+func DeleteMessageTemplate(w http.ResponseWriter, r *http.Request) {
+	before, ok := messageTemplateFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := db.DeleteMessageTemplate(before.ID)
+	if errors.Is(err, db.ErrMessageTemplateInUse) {
+		WriteError(w, http.StatusConflict, "The template is used by a report or saved search")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Template not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error deleting message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "template.delete", templateTarget(before.ID), before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type templatePreviewRequest struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+	// Type is "digest" (default) or "alert".
+	Type string `json:"type"`
+}
+
+// PreviewMessageTemplate renders an unsaved message template with the
+// highest-severity articles ingested in the last 24 hours, or made-up ones
+// when there are none. A digest has up to five articles, an alert one.
+func PreviewMessageTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templatePreviewRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Type == "" {
+		req.Type = "digest"
+	}
+	if req.Type != "digest" && req.Type != "alert" {
+		WriteError(w, http.StatusBadRequest, "type must be one of digest, alert")
+		return
+	}
+	t := models.MessageTemplate{Name: "preview", Kind: req.Kind, Subject: req.Subject, Body: req.Body}
+	if !slices.Contains(integrations.TemplateKinds, t.Kind) {
+		WriteError(w, http.StatusBadRequest, "kind must be one of "+strings.Join(integrations.TemplateKinds, ", "))
+		return
+	}
+
+	data := integrations.SampleMessageData()
+	data.Name = "Preview"
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		StartDate: data.Since, EndDate: data.Until.Add(time.Minute), ByIngestion: true, SortBy: "severity", Limit: previewArticles,
+	})
+	if err != nil {
+		log.Printf("Error fetching preview articles: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	if len(articles) > 0 {
+		data.Articles = articles
+	}
+	if req.Type == "alert" {
+		data.Type = "alert"
+		data.Since = data.Until
+		data.Articles = data.Articles[:1]
+	}
+	msg, err := integrations.RenderMessage(t, data)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid template: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, msg)
+}
+
+// checkMessageTemplate verifies that the message template id exists and is of
+// one of kinds, writing an error response if not.
+func checkMessageTemplate(w http.ResponseWriter, id int64, kinds ...string) bool {
+	t, err := db.GetMessageTemplate(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusBadRequest, "templateId does not exist")
+		return false
+	}
+	if err != nil {
+		log.Printf("Error fetching message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return false
+	}
+	if !slices.Contains(kinds, t.Kind) {
+		WriteError(w, http.StatusBadRequest, "templateId must be a "+strings.Join(kinds, " or ")+" template")
+		return false
+	}
+	return true
+}
+
+func messageTemplateFromPath(w http.ResponseWriter, r *http.Request) (models.MessageTemplate, bool) {
+	id, ok := idFromPath(w, r, "id", "template")
+	if !ok {
+		return models.MessageTemplate{}, false
+	}
+	t, err := db.GetMessageTemplate(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Template not found")
+		return models.MessageTemplate{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching message template: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.MessageTemplate{}, false
+	}
+	return t, true
+}
+
+func templateTarget(id int64) string {
+	return "template:" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/integrations"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	call := func(handler http.HandlerFunc, method, target string, body interface{}, id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler(rr, adminRequest(method, target, body, id))
+		return rr
+	}
+
+	body := `{"text": {{printf "%d new articles" (len .Articles) | json}}}`
+	assert.Equal(t, http.StatusBadRequest, call(CreateMessageTemplate, "POST", "/admin/templates", map[string]string{"name": "Bad", "kind": "slack", "body": `{"text": "{{.Name}}`}, "").Code)
+	rr := call(CreateMessageTemplate, "POST", "/admin/templates", map[string]string{"name": "Slack count", "kind": "slack", "body": body}, "")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tmpl models.MessageTemplate
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tmpl))
+	id := strconv.FormatInt(tmpl.ID, 10)
+	assert.Equal(t, http.StatusConflict, call(CreateMessageTemplate, "POST", "/admin/templates", map[string]string{"name": "slack count", "kind": "slack", "body": body}, "").Code)
+	assert.Equal(t, http.StatusBadRequest, call(UpdateMessageTemplate, "PUT", "/admin/templates/"+id, map[string]string{"name": "Slack count", "kind": "webhook", "body": body}, id).Code)
+
+	// Previews use the recent articles when there are any.
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Fresh zero-day", URL: "https://example.com/fresh", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour)}))
+	rr = call(PreviewMessageTemplate, "POST", "/admin/templates/preview", map[string]string{"kind": "slack", "body": body}, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var msg integrations.Message
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &msg))
+	assert.JSONEq(t, `{"text": "1 new articles"}`, msg.Body)
+	rr = call(PreviewMessageTemplate, "POST", "/admin/templates/preview", map[string]string{"kind": "email", "subject": "{{.Type}} {{.Name}}", "body": "<p>{{(index .Articles 0).Title}}</p>", "type": "alert"}, "")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &msg))
+	assert.Equal(t, integrations.Message{Subject: "alert Preview", Body: "<p>Fresh zero-day</p>", ContentType: "text/html; charset=utf-8"}, msg)
+	rr = call(PreviewMessageTemplate, "POST", "/admin/templates/preview", map[string]string{"kind": "webhook", "body": "{{.Nope}}"}, "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Nope")
+
+	// Report jobs need a template of their channel's kind.
+	Reports = &integrations.ReportScheduler{}
+	defer func() { Reports = nil }()
+	job := map[string]interface{}{"name": "Templated", "schedule": "@daily", "channel": "webhook", "target": "https://example.com/hook", "templateId": tmpl.ID}
+	assert.Equal(t, http.StatusBadRequest, call(CreateReportJob, "POST", "/admin/reports", job, "").Code)
+	job["channel"] = "slack"
+	rr = call(CreateReportJob, "POST", "/admin/reports", job, "")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created models.ReportJob
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Equal(t, tmpl.ID, created.TemplateID)
+
+	assert.Equal(t, http.StatusConflict, call(DeleteMessageTemplate, "DELETE", "/admin/templates/"+id, nil, id).Code)
+	jobID := strconv.FormatInt(created.ID, 10)
+	require.Equal(t, http.StatusNoContent, call(DeleteReportJob, "DELETE", "/admin/reports/"+jobID, nil, jobID).Code)
+	assert.Equal(t, http.StatusNoContent, call(DeleteMessageTemplate, "DELETE", "/admin/templates/"+id, nil, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(GetMessageTemplate, "GET", "/admin/templates/"+id, nil, id).Code)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"news-api/db"
+)
+
+// defaultSummaryPeriod is the period summarized when no start date is given.
+const defaultSummaryPeriod = 30 * 24 * time.Hour
+
+// GetThreatSummary reports how many hours the caller's threat level spent at
+// each level between the optional start and end dates, by default the last
+// 30 days.
+func GetThreatSummary(w http.ResponseWriter, r *http.Request) {
+	start, end, ok := parseDateRange(w, r)
+	if !ok {
+		return
+	}
+	if end.IsZero() {
+		end = time.Now()
+	}
+	if start.IsZero() {
+		start = end.Add(-defaultSummaryPeriod)
+	}
+	if !start.Before(end) {
+		WriteError(w, http.StatusBadRequest, "start must be before end")
+		return
+	}
+
+	summary, err := db.GetThreatSummary(OrgFromContext(r.Context()), start, end)
+	if err != nil {
+		log.Printf("Error summarizing threat history: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetThreatSummary(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+	require.NoError(t, db.TakeThreatSnapshot(0, time.Now().Add(-30*time.Minute)))
+
+	rr := httptest.NewRecorder()
+	GetThreatSummary(rr, httptest.NewRequest("GET", "/threat-history/summary", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var summary models.ThreatSummary
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&summary))
+	assert.Equal(t, 1, summary.Snapshots)
+	assert.InDelta(t, 0.5, summary.Hours["Code Red"], 0.01)
+	assert.InDelta(t, 30*24-0.5, summary.UncoveredHours, 0.01)
+
+	rr = httptest.NewRecorder()
+	GetThreatSummary(rr, httptest.NewRequest("GET", "/threat-history/summary?start=2024-02-01&end=2024-01-01", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
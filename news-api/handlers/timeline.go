@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// timelineArticleLimit caps how many matching articles a timeline covers.
+const timelineArticleLimit = 1000
+
+type timelineResponse struct {
+	Query string               `json:"query"`
+	Total int                  `json:"total"`
+	Days  []models.TimelineDay `json:"days"`
+}
+
+// GetTimeline returns the articles mentioning the q parameter (a keyword or CVE
+// ID) bucketed by day with per-day counts and rank sums. It accepts the same
+// source, category, minRank, start and end filters as /news.
+func GetTimeline(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		WriteError(w, http.StatusBadRequest, "Missing q parameter")
+		return
+	}
+	params := newQueryParams(r)
+	minRank := params.integer("minRank", 0)
+	minSeverity := params.intRange("minSeverity", 0, 0, 100)
+	startDate, endDate := params.dateRange()
+	if !params.valid(w) {
+		return
+	}
+
+	days, err := db.GetTimeline(db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      r.URL.Query().Get("source"),
+		Category:    r.URL.Query().Get("category"),
+		Search:      q,
+		MinRank:     minRank,
+		MinSeverity: minSeverity,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Limit:       timelineArticleLimit,
+	})
+	if err != nil {
+		log.Printf("Error building timeline for %q: %v", q, err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	total := 0
+	for _, day := range days {
+		total += day.Count
+	}
+	writeJSON(w, http.StatusOK, timelineResponse{Query: q, Total: total, Days: days})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTimeline(t *testing.T) {
+	setupTestDB(t)
+	seedArticles(t)
+
+	rr := httptest.NewRecorder()
+	GetTimeline(rr, httptest.NewRequest("GET", "/timeline", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	GetTimeline(rr, httptest.NewRequest("GET", "/timeline?q=cyber", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp timelineResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "cyber", resp.Query)
+	assert.Equal(t, 2, resp.Total)
+	rankSum := 0
+	for _, day := range resp.Days {
+		rankSum += day.RankSum
+	}
+	assert.Equal(t, 18, rankSum)
+}
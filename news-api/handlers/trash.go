@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+const (
+	defaultTrashLimit = 100
+	maxTrashLimit     = 1000
+)
+
+// DeleteArticle moves the article in the {id} path segment to the trash,
+// hiding it until it is restored or purged.
+func DeleteArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := storedArticleFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.DeleteArticle(article.ID); errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	} else if err != nil {
+		log.Printf("Error deleting article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "article.delete", articleTarget(article.ID), article, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTrash lists the deleted articles of every organization, most recently
+// deleted first. It accepts a search of titles, a limit (default 100) and an
+// offset for paging.
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	f := db.TrashFilter{
+		Search: r.URL.Query().Get("search"),
+		Limit:  params.limit(defaultTrashLimit, maxTrashLimit),
+		Offset: params.offset(),
+	}
+	if !params.valid(w) {
+		return
+	}
+	trash, err := db.GetTrash(f)
+	if err != nil {
+		log.Printf("Error fetching trash: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, trash)
+}
+
+// RestoreArticle takes the article in the {id} path segment out of the trash
+// and returns it.
+func RestoreArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := storedArticleFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.RestoreArticle(article.ID); errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not in trash")
+		return
+	} else if err != nil {
+		log.Printf("Error restoring article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "article.restore", articleTarget(article.ID), nil, article)
+	if article, ok = storedArticleFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, article)
+	}
+}
+
+// PurgeArticle permanently deletes the article in the {id} path segment,
+// which must be in the trash.
+func PurgeArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := storedArticleFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := db.PurgeArticle(article.ID); errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not in trash")
+		return
+	} else if err != nil {
+		log.Printf("Error purging article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "article.purge", articleTarget(article.ID), article, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EmptyTrash permanently deletes every article in the trash and returns
+// {"purged": n}.
+func EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	n, err := db.PurgeTrash(time.Now())
+	if err != nil {
+		log.Printf("Error emptying trash: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	recordAudit(r, "article.purge", "trash", nil, map[string]int{"purged": n})
+	writeJSON(w, http.StatusOK, map[string]int{"purged": n})
+}
+
+// storedArticleFromPath loads the article in the {id} path segment, whether
+// or not it is in the trash, writing a 400 or 404 response if there is none.
+func storedArticleFromPath(w http.ResponseWriter, r *http.Request) (models.NewsArticle, bool) {
+	id, ok := articleIDFromPath(w, r)
+	if !ok {
+		return models.NewsArticle{}, false
+	}
+	article, err := db.GetArticleByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return models.NewsArticle{}, false
+	}
+	if err != nil {
+		log.Printf("Error fetching article: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return models.NewsArticle{}, false
+	}
+	return article, true
+}
+
+func articleTarget(id int64) string {
+	return "article:" + strconv.FormatInt(id, 10)
+}
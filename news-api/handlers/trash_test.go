@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashEndpoints(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	call := func(handler http.HandlerFunc, method, target, id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler(rr, adminRequest(method, target, nil, id))
+		return rr
+	}
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Removed by mistake", URL: "https://example.com/a", SourceURL: "src", PublishedAt: time.Now()}))
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	require.Len(t, articles, 1)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	assert.Equal(t, http.StatusNoContent, call(DeleteArticle, "DELETE", "/admin/articles/"+id, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(DeleteArticle, "DELETE", "/admin/articles/"+id, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(DeleteArticle, "DELETE", "/admin/articles/999", "999").Code)
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/news/"+id, nil)
+	req.SetPathValue("id", id)
+	GetArticle(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "deleted articles are hidden")
+
+	rr = call(GetTrash, "GET", "/admin/trash?limit=10", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var trash []models.TrashedArticle
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &trash))
+	require.Len(t, trash, 1)
+	assert.Equal(t, "Removed by mistake", trash[0].Title)
+	assert.False(t, trash[0].DeletedAt.IsZero())
+	assert.Equal(t, http.StatusBadRequest, call(GetTrash, "GET", "/admin/trash?limit=0", "").Code)
+
+	rr = call(RestoreArticle, "POST", "/admin/trash/"+id+"/restore", id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Removed by mistake")
+	assert.Equal(t, http.StatusNotFound, call(RestoreArticle, "POST", "/admin/trash/"+id+"/restore", id).Code)
+	assert.Equal(t, http.StatusNotFound, call(PurgeArticle, "DELETE", "/admin/trash/"+id, id).Code, "only articles in the trash are purged")
+
+	assert.Equal(t, http.StatusNoContent, call(DeleteArticle, "DELETE", "/admin/articles/"+id, id).Code)
+	assert.Equal(t, http.StatusNoContent, call(PurgeArticle, "DELETE", "/admin/trash/"+id, id).Code)
+	assert.Equal(t, http.StatusNotFound, call(RestoreArticle, "POST", "/admin/trash/"+id+"/restore", id).Code)
+	rr = call(EmptyTrash, "DELETE", "/admin/trash", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"purged": 0}`, rr.Body.String())
+
+	entries, err := db.GetAuditLog(db.AuditFilter{Target: "article:" + id})
+	require.NoError(t, err)
+	actions := []string{}
+	for _, e := range entries {
+		actions = append(actions, e.Action)
+	}
+	assert.Equal(t, []string{"article.purge", "article.delete", "article.restore", "article.delete"}, actions)
+}
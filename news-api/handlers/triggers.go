@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+const (
+	defaultTriggerItems = 50
+	maxTriggerItems     = 100
+)
+
+// triggerArticle is an article in the flat shape no-code polling triggers
+// such as Zapier expect: a stable id, ISO 8601 dates and no nested values.
+type triggerArticle struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	ImageURL    string `json:"imageUrl"`
+	Source      string `json:"source"`
+	Category    string `json:"category"`
+	Author      string `json:"author"`
+	Rank        int    `json:"rank"`
+	Severity    int    `json:"severity"`
+	// Tags is the article's tags separated by commas.
+	Tags        string `json:"tags"`
+	PublishedAt string `json:"publishedAt"`
+	IngestedAt  string `json:"ingestedAt"`
+}
+
+func newTriggerArticle(article models.NewsArticle) triggerArticle {
+	return triggerArticle{
+		ID:          article.ID,
+		Title:       article.Title,
+		Description: article.Description,
+		URL:         article.URL,
+		ImageURL:    article.ImageURL,
+		Source:      article.SourceURL,
+		Category:    article.Category,
+		Author:      article.Author,
+		Rank:        article.Rank,
+		Severity:    article.Severity,
+		Tags:        strings.Join(article.Tags, ","),
+		PublishedAt: article.PublishedAt.UTC().Format(time.RFC3339),
+		IngestedAt:  article.IngestedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// GetNewArticlesTrigger is a polling trigger for Zapier, IFTTT and similar
+// services. Without ?cursor it returns the latest articles, which is what
+// services that deduplicate on id poll. With it, it returns the oldest articles
+// stored after that article ID, so that a client advancing its cursor to the
+// X-Next-Cursor header never skips any. Either way items are listed most
+// recently stored first.
+func GetNewArticlesTrigger(w http.ResponseWriter, r *http.Request) {
+	params := newQueryParams(r)
+	limit := params.limit(defaultTriggerItems, maxTriggerItems)
+	cursor := params.intRange("cursor", 0, 0, math.MaxInt)
+	minSeverity := params.intRange("minSeverity", 0, 0, 100)
+	if !params.valid(w) {
+		return
+	}
+	sortBy := "id"
+	if cursor > 0 {
+		sortBy = "idAsc"
+	}
+
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		OrgID:       OrgFromContext(r.Context()),
+		Source:      r.URL.Query().Get("source"),
+		Category:    r.URL.Query().Get("category"),
+		Search:      r.URL.Query().Get("search"),
+		Tag:         r.URL.Query().Get("tag"),
+		MinSeverity: minSeverity,
+		AfterID:     int64(cursor),
+		SortBy:      sortBy,
+		Limit:       limit,
+	})
+	if err != nil {
+		log.Printf("Error fetching articles for trigger: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	next := int64(cursor)
+	items := make([]triggerArticle, 0, len(articles))
+	for _, article := range articles {
+		items = append(items, newTriggerArticle(article))
+		next = max(next, article.ID)
+	}
+	if sortBy == "idAsc" {
+		slices.Reverse(items)
+	}
+	w.Header().Set("X-Next-Cursor", strconv.FormatInt(next, 10))
+	writeJSON(w, http.StatusOK, items)
+}
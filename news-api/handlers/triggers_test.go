@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetNewArticlesTrigger(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	published := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, db.InsertArticle(models.NewsArticle{
+			Title: fmt.Sprintf("Story %d", i), URL: fmt.Sprintf("https://example.com/%d", i), SourceURL: "s1",
+			Category: "Cybersecurity", PublishedAt: published.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+
+	poll := func(query string) ([]triggerArticle, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		GetNewArticlesTrigger(rr, httptest.NewRequest("GET", "/triggers/new-articles"+query, nil))
+		var items []triggerArticle
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&items))
+		}
+		return items, rr
+	}
+
+	articles, err := db.QueryArticles(db.ArticleFilter{})
+	require.NoError(t, err)
+	for _, article := range articles {
+		require.NoError(t, db.TagArticle(article.ID, "ransomware"))
+		require.NoError(t, db.TagArticle(article.ID, "lockbit"))
+	}
+
+	items, rr := poll("")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, items, 3)
+	assert.Equal(t, "Story 3", items[0].Title)
+	assert.Equal(t, "Story 1", items[2].Title)
+	assert.Equal(t, "2024-05-01T11:00:00Z", items[2].PublishedAt)
+	assert.ElementsMatch(t, []string{"lockbit", "ransomware"}, strings.Split(items[0].Tags, ","))
+	assert.Equal(t, "s1", items[0].Source)
+	assert.Equal(t, strconv.FormatInt(items[0].ID, 10), rr.Header().Get("X-Next-Cursor"))
+
+	first := strconv.FormatInt(items[2].ID, 10)
+	items, rr = poll("?limit=1&cursor=" + first)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Story 2", items[0].Title, "a cursor returns the oldest new articles first so none are skipped")
+	assert.Equal(t, strconv.FormatInt(items[0].ID, 10), rr.Header().Get("X-Next-Cursor"))
+
+	latest := rr.Header().Get("X-Next-Cursor")
+	items, rr = poll("?cursor=" + latest)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Story 3", items[0].Title)
+	items, rr = poll("?cursor=" + rr.Header().Get("X-Next-Cursor"))
+	assert.NotNil(t, items, "no new articles is an empty array, not null")
+	assert.Empty(t, items)
+
+	_, rr = poll("?cursor=abc")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
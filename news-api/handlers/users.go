@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// SessionTTL is how long a login token stays valid.
+var SessionTTL = 30 * 24 * time.Hour
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type sessionResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expiresAt"`
+	User      models.User `json:"user"`
+}
+
+// decodeJSON reads a JSON request body of at most 1 MiB into v.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// RequireUser rejects requests without a valid session token and makes the
+// authenticated user available through UserFromContext.
+func RequireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		user, err := db.GetUserBySession(token)
+		if err != nil {
+			if !errors.Is(err, db.ErrInvalidCredentials) {
+				log.Printf("Error resolving session: %v", err)
+			}
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			WriteError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey, user)))
+	}
+}
+
+// UserFromContext returns the user set by RequireUser.
+func UserFromContext(ctx context.Context) (models.User, bool) {
+	user, ok := ctx.Value(userContextKey).(models.User)
+	return user, ok
+}
+
+// Register creates an account and returns a session token for it.
+func Register(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := decodeJSON(w, r, &creds); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if !usernamePattern.MatchString(creds.Username) {
+		WriteError(w, http.StatusBadRequest, "Username must be 3-32 letters, digits, '.', '_' or '-'")
+		return
+	}
+	if len(creds.Password) < 8 {
+		WriteError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+		return
+	}
+
+	user, err := db.CreateUser(creds.Username, creds.Password)
+	if errors.Is(err, db.ErrUserExists) {
+		WriteError(w, http.StatusConflict, "Username already exists")
+		return
+	}
+	if err != nil {
+		log.Printf("Error creating user: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	issueSession(w, user, http.StatusCreated)
+}
+
+// Login exchanges a username and password for a session token.
+func Login(w http.ResponseWriter, r *http.Request) {
+	var creds credentials
+	if err := decodeJSON(w, r, &creds); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	user, err := db.AuthenticateUser(creds.Username, creds.Password)
+	if errors.Is(err, db.ErrInvalidCredentials) {
+		WriteError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+	if err != nil {
+		log.Printf("Error authenticating user: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	issueSession(w, user, http.StatusOK)
+}
+
+func issueSession(w http.ResponseWriter, user models.User, status int) {
+	token, expiresAt, err := db.CreateSession(user.ID, SessionTTL)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, status, sessionResponse{Token: token, ExpiresAt: expiresAt, User: user})
+}
+
+// Logout revokes the session token used for the request.
+func Logout(w http.ResponseWriter, r *http.Request) {
+	if err := db.DeleteSession(bearerToken(r)); err != nil {
+		log.Printf("Error deleting session: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMe returns the authenticated user.
+func GetMe(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	writeJSON(w, http.StatusOK, user)
+}
+
+// GetBookmarks lists the authenticated user's bookmarks with their read state.
+func GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	bookmarks, err := db.GetBookmarks(user.ID)
+	if err != nil {
+		log.Printf("Error fetching bookmarks: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	writeJSON(w, http.StatusOK, bookmarks)
+}
+
+// AddBookmark bookmarks the article in the {id} path segment.
+func AddBookmark(w http.ResponseWriter, r *http.Request) {
+	setUserArticleState(w, r, db.SetBookmark, true)
+}
+
+// RemoveBookmark removes the article in the {id} path segment from the bookmarks.
+func RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	setUserArticleState(w, r, db.SetBookmark, false)
+}
+
+// MarkRead marks the article in the {id} path segment as read.
+func MarkRead(w http.ResponseWriter, r *http.Request) {
+	setUserArticleState(w, r, db.SetRead, true)
+}
+
+// MarkUnread marks the article in the {id} path segment as unread.
+func MarkUnread(w http.ResponseWriter, r *http.Request) {
+	setUserArticleState(w, r, db.SetRead, false)
+}
+
+func setUserArticleState(w http.ResponseWriter, r *http.Request, set func(userID, articleID int64, value bool) error, value bool) {
+	user, _ := UserFromContext(r.Context())
+	articleID, ok := articleIDFromPath(w, r)
+	if !ok {
+		return
+	}
+	err := set(user.ID, articleID, value)
+	if errors.Is(err, sql.ErrNoRows) {
+		WriteError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating article state: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// articleIDFromPath parses the {id} path value, writing a 400 response if it is invalid.
+func articleIDFromPath(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	return idFromPath(w, r, "id", "article")
+}
+
+// idFromPath parses a positive integer path value, writing a 400 response naming
+// the kind of resource if it is invalid.
+func idFromPath(w http.ResponseWriter, r *http.Request, name, kind string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid "+kind+" id")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerUser creates an account through the handler and returns its session token.
+func registerUser(t *testing.T, username string) string {
+	body, _ := json.Marshal(credentials{Username: username, Password: "correct horse"})
+	rr := httptest.NewRecorder()
+	Register(rr, httptest.NewRequest("POST", "/auth/register", bytes.NewReader(body)))
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var session sessionResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&session))
+	require.NotEmpty(t, session.Token)
+	return session.Token
+}
+
+// authedRequest builds a request carrying a bearer token and an optional {id} path value.
+func authedRequest(method, target, token, id string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if id != "" {
+		req.SetPathValue("id", id)
+	}
+	return req
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	setupTestDB(t)
+	registerUser(t, "analyst")
+
+	// Duplicate usernames are rejected.
+	body, _ := json.Marshal(credentials{Username: "analyst", Password: "correct horse"})
+	rr := httptest.NewRecorder()
+	Register(rr, httptest.NewRequest("POST", "/auth/register", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	// Weak passwords are rejected.
+	body, _ = json.Marshal(credentials{Username: "newuser", Password: "short"})
+	rr = httptest.NewRecorder()
+	Register(rr, httptest.NewRequest("POST", "/auth/register", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	body, _ = json.Marshal(credentials{Username: "analyst", Password: "correct horse"})
+	rr = httptest.NewRecorder()
+	Login(rr, httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	body, _ = json.Marshal(credentials{Username: "analyst", Password: "wrong password"})
+	rr = httptest.NewRecorder()
+	Login(rr, httptest.NewRequest("POST", "/auth/login", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRequireUser(t *testing.T) {
+	setupTestDB(t)
+	token := registerUser(t, "analyst")
+
+	rr := httptest.NewRecorder()
+	RequireUser(GetMe)(rr, httptest.NewRequest("GET", "/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(GetMe)(rr, authedRequest("GET", "/me", "not-a-token", ""))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(GetMe)(rr, authedRequest("GET", "/me", token, ""))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var user models.User
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&user))
+	assert.Equal(t, "analyst", user.Username)
+}
+
+func TestBookmarkEndpoints(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	token := registerUser(t, "analyst")
+
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Saved", URL: "saved-url", PublishedAt: time.Now()}))
+	articles, err := db.GetArticlesFromDB("", "", "", 1, time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+	id := strconv.FormatInt(articles[0].ID, 10)
+
+	rr := httptest.NewRecorder()
+	RequireUser(AddBookmark)(rr, authedRequest("PUT", "/me/bookmarks/"+id, token, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(MarkRead)(rr, authedRequest("PUT", "/me/read/"+id, token, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(AddBookmark)(rr, authedRequest("PUT", "/me/bookmarks/424242", token, "424242"))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(AddBookmark)(rr, authedRequest("PUT", "/me/bookmarks/abc", token, "abc"))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(GetBookmarks)(rr, authedRequest("GET", "/me/bookmarks", token, ""))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var bookmarks []models.BookmarkedArticle
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&bookmarks))
+	require.Len(t, bookmarks, 1)
+	assert.Equal(t, "Saved", bookmarks[0].Title)
+	assert.True(t, bookmarks[0].Read)
+
+	rr = httptest.NewRecorder()
+	RequireUser(RemoveBookmark)(rr, authedRequest("DELETE", "/me/bookmarks/"+id, token, id))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = httptest.NewRecorder()
+	RequireUser(GetBookmarks)(rr, authedRequest("GET", "/me/bookmarks", token, ""))
+	assert.JSONEq(t, "[]", rr.Body.String())
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"news-api/db"
+)
+
+// WidgetOrigins are the origins allowed to fetch /widget from a browser, such
+// as "chrome-extension://<id>" or "moz-extension://<uuid>". "*" allows any.
+var WidgetOrigins []string
+
+const (
+	widgetArticles = 5
+	// widgetMaxAge is both how long a widget is reused by the server and how
+	// long clients may cache it.
+	widgetMaxAge = time.Minute
+	// widgetPreflightMaxAge is how long browsers may cache a CORS preflight.
+	widgetPreflightMaxAge = 10 * time.Minute
+)
+
+// widget is the compact summary shown on a browser's new tab page.
+type widget struct {
+	ThreatLevel   string          `json:"threatLevel"`
+	TotalArticles int             `json:"totalArticles"`
+	Articles      []widgetArticle `json:"articles"`
+	GeneratedAt   time.Time       `json:"generatedAt"`
+}
+
+type widgetArticle struct {
+	ID          int64     `json:"id"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url"`
+	Source      string    `json:"source"`
+	Severity    int       `json:"severity"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
+
+// cachedWidget is an encoded widget and its entity tag.
+type cachedWidget struct {
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// widgetCache holds each organization's widget for widgetMaxAge, so that new
+// tabs opened in quick succession are answered without querying the database.
+var widgetCache = struct {
+	sync.Mutex
+	entries map[int64]cachedWidget
+}{entries: map[int64]cachedWidget{}}
+
+// GetWidget returns the threat level and the most severe articles of the last
+// 24 hours, for browser new tab extensions. Responses are cached for a minute
+// and carry an ETag for conditional requests.
+func GetWidget(w http.ResponseWriter, r *http.Request) {
+	setWidgetCORS(w, r)
+	orgID := OrgFromContext(r.Context())
+	entry, err := loadWidget(orgID, time.Now())
+	if err != nil {
+		log.Printf("Error building widget: %v", err)
+		WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	visibility := "public"
+	if orgID != 0 {
+		visibility = "private"
+	}
+	w.Header().Set("Cache-Control", visibility+", max-age="+strconv.Itoa(int(widgetMaxAge.Seconds())))
+	w.Header().Add("Vary", "X-API-Key")
+	w.Header().Set("ETag", entry.etag)
+	if r.Header.Get("If-None-Match") == entry.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(entry.body)))
+	w.Write(entry.body)
+}
+
+// WidgetPreflight answers CORS preflight requests for /widget, which browsers
+// send before requests carrying an X-API-Key header.
+func WidgetPreflight(w http.ResponseWriter, r *http.Request) {
+	if setWidgetCORS(w, r) {
+		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.Header().Set("Access-Control-Allow-Headers", "X-API-Key, If-None-Match")
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(widgetPreflightMaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setWidgetCORS allows the request's origin to read the response if it is one
+// of WidgetOrigins, and reports whether it is.
+func setWidgetCORS(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" || !(slices.Contains(WidgetOrigins, origin) || slices.Contains(WidgetOrigins, "*")) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	return true
+}
+
+// loadWidget returns the organization's cached widget, building it if it is
+// missing or expired.
+func loadWidget(orgID int64, now time.Time) (cachedWidget, error) {
+	widgetCache.Lock()
+	entry, ok := widgetCache.entries[orgID]
+	widgetCache.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry, nil
+	}
+
+	score, err := db.GetOrgThreatScore(orgID)
+	if err != nil {
+		return cachedWidget{}, err
+	}
+	articles, err := db.QueryArticles(db.ArticleFilter{
+		OrgID:     orgID,
+		StartDate: now.Add(-24 * time.Hour),
+		SortBy:    "severity",
+		Limit:     widgetArticles,
+	})
+	if err != nil {
+		return cachedWidget{}, err
+	}
+	data := widget{
+		ThreatLevel:   score.ThreatLevel,
+		TotalArticles: score.TotalArticles,
+		Articles:      make([]widgetArticle, 0, len(articles)),
+		GeneratedAt:   now.UTC().Truncate(time.Second),
+	}
+	for _, article := range articles {
+		data.Articles = append(data.Articles, widgetArticle{
+			ID:          article.ID,
+			Title:       article.Title,
+			URL:         article.URL,
+			Source:      article.SourceURL,
+			Severity:    article.Severity,
+			PublishedAt: article.PublishedAt.UTC(),
+		})
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return cachedWidget{}, err
+	}
+	// The entity tag ignores generatedAt, so an unchanged widget still matches
+	// the client's copy after it is rebuilt.
+	data.GeneratedAt = time.Time{}
+	unstamped, err := json.Marshal(data)
+	if err != nil {
+		return cachedWidget{}, err
+	}
+	sum := sha256.Sum256(unstamped)
+	entry = cachedWidget{
+		body:    append(body, '\n'),
+		etag:    `"` + hex.EncodeToString(sum[:8]) + `"`,
+		expires: now.Add(widgetMaxAge),
+	}
+
+	widgetCache.Lock()
+	widgetCache.entries[orgID] = entry
+	widgetCache.Unlock()
+	return entry, nil
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetWidgetCache() {
+	widgetCache.Lock()
+	widgetCache.entries = map[int64]cachedWidget{}
+	widgetCache.Unlock()
+}
+
+func TestGetWidget(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	resetWidgetCache()
+	defer resetWidgetCache()
+	for i := 1; i <= 7; i++ {
+		require.NoError(t, db.InsertArticle(models.NewsArticle{
+			Title: fmt.Sprintf("Story %d", i), URL: fmt.Sprintf("https://example.com/%d", i), SourceURL: "s1",
+			Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Duration(i) * time.Hour), Severity: i * 10,
+		}))
+	}
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Old story", URL: "https://example.com/old", SourceURL: "s1",
+		Category: "Cybersecurity", PublishedAt: time.Now().Add(-48 * time.Hour), Severity: 100,
+	}))
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/widget", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		GetWidget(rr, req)
+		return rr
+	}
+
+	rr := get(nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "public, max-age=60", rr.Header().Get("Cache-Control"))
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	var body widget
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	require.Len(t, body.Articles, widgetArticles)
+	assert.Equal(t, "Story 7", body.Articles[0].Title, "the most severe articles of the last day come first")
+	assert.Equal(t, "Story 3", body.Articles[4].Title)
+	assert.NotEmpty(t, body.ThreatLevel)
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	require.NoError(t, db.InsertArticle(models.NewsArticle{
+		Title: "Breaking", URL: "https://example.com/breaking", SourceURL: "s1",
+		Category: "Cybersecurity", PublishedAt: time.Now(), Severity: 95,
+	}))
+	rr = get(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, rr.Code, "the widget is served from the cache")
+
+	resetWidgetCache()
+	rr = get(http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+	assert.Equal(t, "Breaking", body.Articles[0].Title)
+}
+
+func TestWidgetCORS(t *testing.T) {
+	setupTestDB(t)
+	clearDB(t)
+	resetWidgetCache()
+	defer resetWidgetCache()
+	WidgetOrigins = []string{"chrome-extension://abcdef"}
+	defer func() { WidgetOrigins = nil }()
+
+	request := func(method, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/widget", nil)
+		req.Header.Set("Origin", origin)
+		rr := httptest.NewRecorder()
+		if method == http.MethodOptions {
+			WidgetPreflight(rr, req)
+		} else {
+			GetWidget(rr, req)
+		}
+		return rr
+	}
+
+	rr := request("GET", "chrome-extension://abcdef")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "chrome-extension://abcdef", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Origin")
+
+	rr = request("OPTIONS", "chrome-extension://abcdef")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "chrome-extension://abcdef", rr.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, rr.Header().Get("Access-Control-Allow-Headers"), "X-API-Key")
+
+	rr = request("GET", "https://evil.example")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Origin"))
+	rr = request("OPTIONS", "https://evil.example")
+	assert.Empty(t, rr.Header().Get("Access-Control-Allow-Methods"))
+
+	WidgetOrigins = []string{"*"}
+	rr = request("GET", "moz-extension://1234")
+	assert.Equal(t, "moz-extension://1234", rr.Header().Get("Access-Control-Allow-Origin"))
+}
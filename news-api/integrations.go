@@ -0,0 +1,311 @@
+package main
+
+import (
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/handlers"
+	"news-api/integrations"
+)
+
+// deliveryQueue persists and retries outbound webhooks, escalations, tickets
+// and SIEM and stream events until they are delivered.
+var deliveryQueue = integrations.NewDeliveryQueue()
+
+// eventBatchSize is the number of queued article events sent to a SIEM or
+// stream sink at once.
+const eventBatchSize = 50
+
+// threatWatcher re-evaluates the threat level after every caching cycle and
+// fans level changes out to escalation services and forwarders. Escalations
+// go through the delivery queue.
+var threatWatcher = &integrations.ThreatLevelWatcher{Queue: deliveryQueue}
+
+// setupSentry reports recovered handler panics to Sentry when SENTRY_DSN is set.
+func setupSentry() {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return
+	}
+	sentry, err := integrations.NewSentry(dsn)
+	if err != nil {
+		log.Printf("%v, panic reporting disabled.", err)
+		return
+	}
+	sentry.Environment = os.Getenv("SENTRY_ENVIRONMENT")
+	sentry.Release = os.Getenv("SENTRY_RELEASE")
+	registerPanicReporter(sentry.ReportPanic)
+	log.Println("Sentry panic reporting enabled.")
+}
+
+// setupThreatEscalation registers PagerDuty and/or Opsgenie escalation when their
+// keys are configured.
+func setupThreatEscalation() {
+	if key := os.Getenv("PAGERDUTY_ROUTING_KEY"); key != "" {
+		threatWatcher.AddEscalator(&integrations.PagerDuty{RoutingKey: key, EventsURL: os.Getenv("PAGERDUTY_EVENTS_URL")})
+		log.Println("PagerDuty escalation enabled.")
+	}
+	if key := os.Getenv("OPSGENIE_API_KEY"); key != "" {
+		threatWatcher.AddEscalator(&integrations.Opsgenie{APIKey: key, APIURL: os.Getenv("OPSGENIE_API_URL")})
+		log.Println("Opsgenie escalation enabled.")
+	}
+}
+
+// startThreatWatcher hooks the watcher into the caching job if any escalation
+// or forwarder subscribed to threat level changes.
+func startThreatWatcher() {
+	if !threatWatcher.Active() {
+		return
+	}
+	db.RegisterCycleHook(func() {
+		score, err := db.GetTodayThreatScore()
+		if err != nil {
+			log.Printf("Error computing threat score for level change notifications: %v", err)
+			return
+		}
+		threatWatcher.Observe(score)
+	})
+}
+
+// setupTicketing registers the Jira integration when it is configured. Tickets
+// are opened for articles matching WATCHLIST terms or reaching JIRA_MIN_RANK.
+func setupTicketing() {
+	baseURL := os.Getenv("JIRA_URL")
+	if baseURL == "" {
+		return
+	}
+
+	jira := &integrations.Jira{
+		BaseURL:   baseURL,
+		Email:     os.Getenv("JIRA_EMAIL"),
+		APIToken:  os.Getenv("JIRA_API_TOKEN"),
+		Project:   os.Getenv("JIRA_PROJECT"),
+		IssueType: os.Getenv("JIRA_ISSUE_TYPE"),
+		Labels:    envList("JIRA_LABELS"),
+		Rule: integrations.TicketRule{
+			Watchlist: envList("WATCHLIST"),
+			MinRank:   envInt("JIRA_MIN_RANK", 0),
+		},
+		Queue: deliveryQueue,
+	}
+	if jira.Project == "" {
+		log.Println("JIRA_URL is set but JIRA_PROJECT is missing, Jira ticketing disabled.")
+		return
+	}
+	if len(jira.Rule.Watchlist) == 0 && jira.Rule.MinRank == 0 {
+		log.Println("Jira ticketing needs WATCHLIST or JIRA_MIN_RANK, Jira ticketing disabled.")
+		return
+	}
+
+	jira.Start()
+	db.RegisterArticleHook(jira.Enqueue)
+	log.Printf("Jira ticketing enabled for project %s.", jira.Project)
+}
+
+// setupStreamSinks forwards every newly cached article to the configured SIEM
+// platforms (Splunk HEC, Elasticsearch), message streams (Kafka, NATS) and
+// event webhook through the delivery queue, and threat level changes to the
+// sinks that take them.
+func setupStreamSinks() {
+	if os.Getenv("SIEM_DEAD_LETTER_DIR") != "" {
+		log.Println("Warning: SIEM_DEAD_LETTER_DIR is no longer used, failed deliveries are kept in the database under /admin/deliveries")
+	}
+
+	if url := os.Getenv("SPLUNK_HEC_URL"); url != "" {
+		hec := &integrations.SplunkHEC{
+			URL:        url,
+			Token:      os.Getenv("SPLUNK_HEC_TOKEN"),
+			Index:      os.Getenv("SPLUNK_HEC_INDEX"),
+			SourceType: os.Getenv("SPLUNK_HEC_SOURCETYPE"),
+		}
+		deliveryQueue.AddSink(integrations.ArticleSink("splunk", hec.Send), eventBatchSize)
+	}
+	if url := os.Getenv("ELASTICSEARCH_URL"); url != "" {
+		index := os.Getenv("ELASTICSEARCH_INDEX")
+		if index == "" {
+			index = "threatfeed-articles"
+		}
+		es := &integrations.Elasticsearch{
+			URL:      url,
+			Index:    index,
+			APIKey:   os.Getenv("ELASTICSEARCH_API_KEY"),
+			Username: os.Getenv("ELASTICSEARCH_USERNAME"),
+			Password: os.Getenv("ELASTICSEARCH_PASSWORD"),
+		}
+		deliveryQueue.AddSink(integrations.ArticleSink("elasticsearch", es.Send), eventBatchSize)
+	}
+
+	if proxyURL := os.Getenv("KAFKA_REST_URL"); proxyURL != "" {
+		kafka := &integrations.KafkaRESTProducer{
+			ProxyURL: proxyURL,
+			Topic:    envDefault("KAFKA_TOPIC", "threatfeed.articles"),
+			Username: os.Getenv("KAFKA_REST_USERNAME"),
+			Password: os.Getenv("KAFKA_REST_PASSWORD"),
+		}
+		deliveryQueue.AddSink(integrations.ArticleSink("kafka", kafka.Send), eventBatchSize)
+	}
+	if natsURL := os.Getenv("NATS_URL"); natsURL != "" {
+		nats := &integrations.NATSPublisher{URL: natsURL, Subject: envDefault("NATS_SUBJECT", "threatfeed.articles")}
+		deliveryQueue.AddSink(integrations.ArticleSink("nats", nats.Send), eventBatchSize)
+	}
+	if url := os.Getenv("EVENT_WEBHOOK_URL"); url != "" {
+		deliveryQueue.AddSink(&integrations.WebhookEventSink{URL: url}, eventBatchSize)
+	}
+
+	for _, sink := range deliveryQueue.Sinks() {
+		log.Printf("Forwarding articles to %s.", sink)
+	}
+}
+
+// publishToSinks queues every newly cached article and threat level change
+// for the sinks added above.
+func publishToSinks() {
+	if len(deliveryQueue.Sinks()) == 0 {
+		return
+	}
+	db.RegisterArticleHook(deliveryQueue.PublishArticle)
+	threatWatcher.AddListener(deliveryQueue.PublishLevelChange)
+}
+
+// setupSyslog forwards high-rank articles and threat level changes to a
+// syslog collector as CEF or LEEF messages, through the delivery queue.
+func setupSyslog() {
+	address := os.Getenv("SYSLOG_ADDRESS")
+	if address == "" {
+		return
+	}
+
+	forwarder := &integrations.SyslogForwarder{
+		Network:  envDefault("SYSLOG_NETWORK", "udp"),
+		Address:  address,
+		Facility: envInt("SYSLOG_FACILITY", 16),
+		Format:   strings.ToLower(envDefault("SYSLOG_FORMAT", integrations.FormatCEF)),
+		MinRank:  envInt("SYSLOG_MIN_RANK", 5),
+	}
+	if spec := os.Getenv("SYSLOG_FIELD_MAP"); spec != "" {
+		fields, err := integrations.ParseSyslogFieldMap(spec)
+		if err != nil {
+			log.Printf("Warning: %v, using the default syslog field mapping", err)
+		} else {
+			forwarder.FieldMap = fields
+		}
+	}
+
+	deliveryQueue.AddSink(forwarder, eventBatchSize)
+	log.Printf("Forwarding %s events to syslog at %s://%s.", strings.ToUpper(forwarder.Format), forwarder.Network, address)
+}
+
+// setupOpenCTI pushes high-rank articles and their extracted indicators into an
+// OpenCTI instance on a schedule.
+func setupOpenCTI() {
+	url := os.Getenv("OPENCTI_URL")
+	if url == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(envDefault("OPENCTI_SYNC_INTERVAL", "1h"))
+	if err != nil || interval <= 0 {
+		log.Printf("Warning: invalid OPENCTI_SYNC_INTERVAL, using 1h")
+		interval = time.Hour
+	}
+
+	connector := &integrations.OpenCTI{
+		URL:     url,
+		Token:   os.Getenv("OPENCTI_TOKEN"),
+		MinRank: envInt("OPENCTI_MIN_RANK", 5),
+	}
+	connector.Start(interval)
+	log.Printf("OpenCTI connector enabled, syncing every %s.", interval)
+}
+
+// setupPocket enables saving articles to Pocket when POCKET_CONSUMER_KEY is set.
+func setupPocket() {
+	key := os.Getenv("POCKET_CONSUMER_KEY")
+	if key == "" {
+		return
+	}
+	handlers.Pocket = &integrations.Pocket{ConsumerKey: key, APIURL: os.Getenv("POCKET_API_URL")}
+	log.Println("Pocket integration enabled.")
+}
+
+// setupReports runs the scheduled report jobs. Email delivery needs SMTP_ADDR
+// and SMTP_FROM, S3 delivery AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func setupReports() {
+	scheduler := &integrations.ReportScheduler{AlertURL: os.Getenv("REPORT_ALERT_URL"), Queue: deliveryQueue}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		if from, err := mail.ParseAddress(os.Getenv("SMTP_FROM")); err != nil {
+			log.Printf("Warning: invalid SMTP_FROM, email delivery disabled")
+		} else {
+			scheduler.Mailer = &integrations.Mailer{
+				Addr:     addr,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     *from,
+			}
+		}
+	}
+	scheduler.S3 = newS3Uploader()
+	scheduler.Start()
+	handlers.Reports = scheduler
+}
+
+// newS3Uploader returns the S3 client configured by AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION and S3_ENDPOINT, nil
+// without AWS_ACCESS_KEY_ID.
+func newS3Uploader() *integrations.S3Uploader {
+	key := os.Getenv("AWS_ACCESS_KEY_ID")
+	if key == "" {
+		return nil
+	}
+	return &integrations.S3Uploader{
+		Region:          envDefault("AWS_REGION", "us-east-1"),
+		AccessKeyID:     key,
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:        os.Getenv("S3_ENDPOINT"),
+	}
+}
+
+// startDeliveryQueue delivers the queued messages of the integrations above,
+// with the retry policy set by DELIVERY_MAX_ATTEMPTS and DELIVERY_RETRY_BACKOFF.
+func startDeliveryQueue() {
+	deliveryQueue.MaxAttempts = envInt("DELIVERY_MAX_ATTEMPTS", 8)
+	deliveryQueue.RetryBackoff = envDuration("DELIVERY_RETRY_BACKOFF", 30*time.Second)
+	deliveryQueue.Start()
+}
+
+// setupWebhookSigning signs outbound webhook deliveries with
+// WEBHOOK_SIGNING_SECRET so receivers can verify them.
+func setupWebhookSigning() {
+	secret := os.Getenv("WEBHOOK_SIGNING_SECRET")
+	if secret == "" {
+		return
+	}
+	if len(secret) < 32 {
+		log.Printf("Warning: WEBHOOK_SIGNING_SECRET is shorter than 32 characters")
+	}
+	integrations.WebhookSecret = []byte(secret)
+	log.Println("Webhook signing enabled.")
+}
+
+// setupWebhookAddresses lets user-configured webhooks reach internal
+// addresses when WEBHOOK_ALLOW_PRIVATE is set.
+func setupWebhookAddresses() {
+	if envDefault("WEBHOOK_ALLOW_PRIVATE", "false") != "true" {
+		return
+	}
+	integrations.AllowPrivateWebhooks = true
+	log.Println("Warning: user-configured webhooks may reach internal addresses.")
+}
+
+// setupSavedSearchNotifications delivers newly cached articles to the notify
+// URLs of matching saved searches.
+func setupSavedSearchNotifications() {
+	notifier := &integrations.SavedSearchNotifier{Queue: deliveryQueue}
+	notifier.Start()
+	db.RegisterArticleHook(notifier.Enqueue)
+}
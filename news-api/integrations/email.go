@@ -0,0 +1,130 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Mailer sends email through an SMTP server, upgrading the connection with
+// STARTTLS when the server offers it.
+type Mailer struct {
+	// Addr is the server's host:port.
+	Addr     string
+	Username string
+	Password string
+	From     mail.Address
+}
+
+// Email is a message with a plain text or HTML body and an optional attachment.
+type Email struct {
+	To         []*mail.Address
+	Subject    string
+	Text       string
+	HTML       string
+	Attachment *Attachment
+}
+
+// Attachment is a file attached to an Email.
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Send delivers a message to its recipients.
+func (m *Mailer) Send(msg Email) error {
+	data, err := m.compose(msg, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP address: %v", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	to := make([]string, len(msg.To))
+	for i, addr := range msg.To {
+		to[i] = addr.Address
+	}
+	if err := smtp.SendMail(m.Addr, auth, m.From.Address, to, data); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
+
+// compose encodes a message in MIME format.
+func (m *Mailer) compose(msg Email, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	to := make([]string, len(msg.To))
+	for i, addr := range msg.To {
+		to[i] = addr.String()
+	}
+	header := func(name, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", name, value) }
+	header("From", m.From.String())
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	bodyType, body := "text/plain; charset=utf-8", msg.Text
+	if msg.HTML != "" {
+		bodyType, body = "text/html; charset=utf-8", msg.HTML
+	}
+	if msg.Attachment == nil {
+		header("Content-Type", bodyType)
+		header("Content-Transfer-Encoding", "base64")
+		buf.WriteString("\r\n")
+		writeBase64Lines(&buf, []byte(body))
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	buf.WriteString("\r\n")
+	parts := []struct {
+		header textproto.MIMEHeader
+		data   []byte
+	}{
+		{textproto.MIMEHeader{"Content-Type": {bodyType}, "Content-Transfer-Encoding": {"base64"}}, []byte(body)},
+		{textproto.MIMEHeader{
+			"Content-Type":              {msg.Attachment.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": msg.Attachment.Name})},
+		}, msg.Attachment.Data},
+	}
+	for _, part := range parts {
+		w, err := mw.CreatePart(part.header)
+		if err != nil {
+			return nil, err
+		}
+		var encoded bytes.Buffer
+		writeBase64Lines(&encoded, part.data)
+		w.Write(encoded.Bytes())
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBase64Lines writes data in base64 with the 76-character lines MIME requires.
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+}
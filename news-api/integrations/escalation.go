@@ -0,0 +1,160 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// CodeRed is the threat level that pages on-call analysts.
+const CodeRed = "Code Red"
+
+// Escalator is an incident management service that can be paged when the
+// threat level reaches Code Red and resolved when it drops again.
+type Escalator interface {
+	Name() string
+	Trigger(ctx context.Context, score db.ThreatScore) error
+	Resolve(ctx context.Context, score db.ThreatScore) error
+}
+
+// LevelChangeListener is called whenever the observed threat level changes.
+// previous is empty for the first observation after startup.
+type LevelChangeListener func(previous string, score db.ThreatScore)
+
+// escalationSinkPrefix names the delivery queue sink of an escalator, e.g.
+// "escalation.pagerduty".
+const escalationSinkPrefix = "escalation."
+
+// escalation is a page or resolution waiting in the delivery queue.
+type escalation struct {
+	Trigger bool           `json:"trigger"`
+	Score   db.ThreatScore `json:"score"`
+}
+
+// ThreatLevelWatcher tracks the computed threat level between caching cycles,
+// notifies listeners of every change and escalates when the level transitions
+// into or out of Code Red.
+type ThreatLevelWatcher struct {
+	// Queue delivers the escalations, retrying them until the service accepts
+	// them. They are sent right away when it is nil. It must be set before
+	// escalators are added.
+	Queue *DeliveryQueue
+
+	mu         sync.Mutex
+	lastLevel  string
+	escalators []Escalator
+	listeners  []LevelChangeListener
+}
+
+// NewThreatLevelWatcher creates a watcher that notifies the given escalators.
+func NewThreatLevelWatcher(escalators ...Escalator) *ThreatLevelWatcher {
+	return &ThreatLevelWatcher{escalators: escalators}
+}
+
+// AddEscalator registers another incident management service.
+func (w *ThreatLevelWatcher) AddEscalator(e Escalator) {
+	w.mu.Lock()
+	w.escalators = append(w.escalators, e)
+	w.mu.Unlock()
+	if w.Queue != nil {
+		w.Queue.Register(escalationSink(e), 1, func(ctx context.Context, deliveries []models.Delivery) error {
+			var queued escalation
+			if err := json.Unmarshal([]byte(deliveries[0].Payload), &queued); err != nil {
+				return fmt.Errorf("failed to decode queued escalation %d: %v", deliveries[0].ID, err)
+			}
+			// An escalation retried after the level moved on is dropped, so
+			// that a late page does not reopen a resolved incident.
+			if level := w.level(); level != "" && (level == CodeRed) != queued.Trigger {
+				return nil
+			}
+			return escalate(ctx, e, queued.Trigger, queued.Score)
+		})
+	}
+}
+
+// escalationSink returns the name of the delivery queue sink of an escalator.
+func escalationSink(e Escalator) string {
+	return escalationSinkPrefix + strings.ToLower(e.Name())
+}
+
+// level returns the last level observed, empty before the first observation.
+func (w *ThreatLevelWatcher) level() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastLevel
+}
+
+// AddListener registers a callback for threat level changes.
+func (w *ThreatLevelWatcher) AddListener(l LevelChangeListener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, l)
+}
+
+// Active reports whether anything is subscribed to the watcher.
+func (w *ThreatLevelWatcher) Active() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.escalators) > 0 || len(w.listeners) > 0
+}
+
+// Observe records the latest threat score, notifies listeners if the level
+// changed, and pages or resolves on a Code Red transition. The first
+// observation after startup counts as a transition into Code Red so an
+// incident is opened even if the process restarted mid-wave.
+func (w *ThreatLevelWatcher) Observe(score db.ThreatScore) {
+	w.mu.Lock()
+	previous := w.lastLevel
+	w.lastLevel = score.ThreatLevel
+	escalators := w.escalators
+	listeners := w.listeners
+	w.mu.Unlock()
+
+	if previous == score.ThreatLevel {
+		return
+	}
+	for _, l := range listeners {
+		l(previous, score)
+	}
+
+	wasCodeRed := previous == CodeRed
+	isCodeRed := score.ThreatLevel == CodeRed
+	if wasCodeRed == isCodeRed {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, e := range escalators {
+		if isCodeRed {
+			log.Printf("Threat level changed to %s, triggering %s alert", score.ThreatLevel, e.Name())
+		} else {
+			log.Printf("Threat level dropped to %s, resolving %s alert", score.ThreatLevel, e.Name())
+		}
+		var err error
+		if w.Queue != nil {
+			err = w.Queue.EnqueueJSON(escalationSink(e), "", escalation{Trigger: isCodeRed, Score: score})
+		} else {
+			err = escalate(ctx, e, isCodeRed, score)
+		}
+		if err != nil {
+			log.Printf("Error escalating threat level change to %s: %v", e.Name(), err)
+		}
+	}
+}
+
+// escalate pages through an escalator, or resolves its alert.
+func escalate(ctx context.Context, e Escalator, trigger bool, score db.ThreatScore) error {
+	if trigger {
+		return e.Trigger(ctx, score)
+	}
+	return e.Resolve(ctx, score)
+}
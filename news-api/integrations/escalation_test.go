@@ -0,0 +1,171 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingEscalator struct {
+	triggered int
+	resolved  int
+}
+
+func (r *recordingEscalator) Name() string { return "recorder" }
+
+func (r *recordingEscalator) Trigger(ctx context.Context, score db.ThreatScore) error {
+	r.triggered++
+	return nil
+}
+
+func (r *recordingEscalator) Resolve(ctx context.Context, score db.ThreatScore) error {
+	r.resolved++
+	return nil
+}
+
+func TestThreatLevelWatcherTransitions(t *testing.T) {
+	rec := &recordingEscalator{}
+	w := NewThreatLevelWatcher(rec)
+
+	w.Observe(db.ThreatScore{ThreatLevel: "Attention"})
+	assert.Equal(t, 0, rec.triggered, "non Code Red startup level should not page")
+	assert.Equal(t, 0, rec.resolved, "nothing to resolve on startup")
+
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	assert.Equal(t, 1, rec.triggered, "staying at Code Red should page only once")
+
+	w.Observe(db.ThreatScore{ThreatLevel: "Business as Usual"})
+	assert.Equal(t, 1, rec.resolved, "dropping from Code Red should resolve")
+}
+
+func TestThreatLevelWatcherStartsInCodeRed(t *testing.T) {
+	rec := &recordingEscalator{}
+	w := NewThreatLevelWatcher(rec)
+
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	assert.Equal(t, 1, rec.triggered)
+}
+
+func TestPagerDutyEvents(t *testing.T) {
+	var events []pagerDutyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event pagerDutyEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	pd := &PagerDuty{RoutingKey: "rk", EventsURL: server.URL}
+	score := db.ThreatScore{ThreatLevel: CodeRed, HighRankCount: 3}
+
+	require.NoError(t, pd.Trigger(context.Background(), score))
+	require.NoError(t, pd.Resolve(context.Background(), score))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, "rk", events[0].RoutingKey)
+	assert.Equal(t, codeRedDedupKey, events[0].DedupKey)
+	assert.Equal(t, "critical", events[0].Payload.Severity)
+	assert.Equal(t, "resolve", events[1].EventAction)
+	assert.Equal(t, codeRedDedupKey, events[1].DedupKey)
+}
+
+func TestOpsgenieAlerts(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GenieKey key", r.Header.Get("Authorization"))
+		paths = append(paths, r.URL.RequestURI())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	og := &Opsgenie{APIKey: "key", APIURL: server.URL}
+	score := db.ThreatScore{ThreatLevel: CodeRed}
+
+	require.NoError(t, og.Trigger(context.Background(), score))
+	require.NoError(t, og.Resolve(context.Background(), score))
+	assert.Equal(t, []string{"/v2/alerts", "/v2/alerts/threatfeed-code-red/close?identifierType=alias"}, paths)
+}
+
+func TestPostJSONErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid routing key", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	pd := &PagerDuty{RoutingKey: "bad", EventsURL: server.URL}
+	err := pd.Trigger(context.Background(), db.ThreatScore{ThreatLevel: CodeRed})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid routing key")
+}
+
+func TestThreatLevelWatcherListeners(t *testing.T) {
+	w := NewThreatLevelWatcher()
+	assert.False(t, w.Active())
+
+	var changes []string
+	w.AddListener(func(previous string, score db.ThreatScore) {
+		changes = append(changes, previous+"->"+score.ThreatLevel)
+	})
+	assert.True(t, w.Active())
+
+	w.Observe(db.ThreatScore{ThreatLevel: "Attention"})
+	w.Observe(db.ThreatScore{ThreatLevel: "Attention"})
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	assert.Equal(t, []string{"->Attention", "Attention->Code Red"}, changes)
+}
+
+type failingEscalator struct {
+	recordingEscalator
+	failures int
+}
+
+func (f *failingEscalator) Trigger(ctx context.Context, score db.ThreatScore) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("service unavailable")
+	}
+	return f.recordingEscalator.Trigger(ctx, score)
+}
+
+func TestThreatLevelWatcherQueuesEscalations(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	rec := &failingEscalator{failures: 1}
+	w := &ThreatLevelWatcher{Queue: NewDeliveryQueue()}
+	w.Queue.RetryBackoff = time.Minute
+	w.AddEscalator(rec)
+
+	now := time.Now().Add(time.Second)
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	w.Queue.Process(now)
+	assert.Equal(t, 0, rec.triggered, "the first attempt failed")
+	w.Queue.Process(now.Add(time.Minute))
+	assert.Equal(t, 1, rec.triggered, "failed pages are retried")
+
+	// A page still waiting when the level drops is not sent after the
+	// resolution.
+	w.Observe(db.ThreatScore{ThreatLevel: "Attention"})
+	w.Queue.Process(now.Add(2 * time.Minute))
+	assert.Equal(t, 1, rec.resolved)
+	rec.failures = 1
+	w.Observe(db.ThreatScore{ThreatLevel: CodeRed})
+	w.Queue.Process(now.Add(3 * time.Minute))
+	w.Observe(db.ThreatScore{ThreatLevel: "Attention"})
+	w.Queue.Process(now.Add(5 * time.Minute))
+	assert.Equal(t, 1, rec.triggered)
+	assert.Equal(t, 2, rec.resolved)
+	pending, err := db.GetDeliveries(db.DeliveryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
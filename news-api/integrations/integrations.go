@@ -0,0 +1,144 @@
+// Package integrations forwards Threatfeed events to external services such as
+// incident management and ticketing systems.
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"news-api/webhook"
+)
+
+// httpClient is shared by all integrations. Outbound calls should never hold up
+// the caching job for long.
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// AllowPrivateWebhooks lets user-configured webhooks reach loopback, private
+// and link-local addresses, for deployments whose receivers are all internal.
+var AllowPrivateWebhooks bool
+
+// webhookClient delivers to user-configured webhook URLs. Any user can save a
+// search with a notify URL, so like the /img proxy it refuses to connect to
+// loopback, private and link-local addresses unless AllowPrivateWebhooks is
+// set.
+var webhookClient = &http.Client{
+	Timeout: 15 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				if AllowPrivateWebhooks {
+					return nil
+				}
+				return webhook.RefuseNonPublic(network, address, c)
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// WebhookSecret signs the deliveries to user-configured webhook URLs: saved
+// search notifications and scheduled reports and their alerts. Deliveries are
+// unsigned when it is empty.
+var WebhookSecret []byte
+
+// sendWebhook POSTs body to a user-configured webhook URL through
+// webhookClient, signed with WebhookSecret when one is set.
+func sendWebhook(ctx context.Context, url, contentType string, body []byte) error {
+	return sendSigned(ctx, webhookClient, url, contentType, body)
+}
+
+// postWebhookJSON sends payload as a JSON body with sendWebhook.
+func postWebhookJSON(ctx context.Context, url string, payload interface{}) error {
+	return postSignedJSON(ctx, webhookClient, url, payload)
+}
+
+// sendSigned POSTs body signed with WebhookSecret, when one is set.
+func sendSigned(ctx context.Context, client *http.Client, url, contentType string, body []byte) error {
+	var headers map[string]string
+	if len(WebhookSecret) > 0 {
+		headers = webhook.Sign(WebhookSecret, body, time.Now())
+	}
+	_, err := sendWith(ctx, client, http.MethodPost, url, contentType, headers, body)
+	return err
+}
+
+// postSignedJSON sends payload as a JSON body with sendSigned.
+func postSignedJSON(ctx context.Context, client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return sendSigned(ctx, client, url, "application/json", body)
+}
+
+// postJSON sends payload as a JSON body and returns an error for non-2xx responses.
+// The response body is decoded into out when out is not nil.
+func postJSON(ctx context.Context, url string, headers map[string]string, payload interface{}, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+
+	respBody, err := send(ctx, http.MethodPost, url, "application/json", headers, body)
+	if err != nil {
+		return err
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+	}
+	return nil
+}
+
+// basicAuth returns an Authorization header value for HTTP basic authentication.
+func basicAuth(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// send performs an HTTP request and returns the response body, or an error for
+// transport failures and non-2xx responses.
+func send(ctx context.Context, method, url, contentType string, headers map[string]string, body []byte) ([]byte, error) {
+	return sendWith(ctx, httpClient, method, url, contentType, headers, body)
+}
+
+// sendWith performs the request of send with the given client.
+func sendWith(ctx context.Context, client *http.Client, method, url, contentType string, headers map[string]string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %v", url, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > 1024 {
+			respBody = respBody[:1024]
+		}
+		return nil, fmt.Errorf("request to %s returned %s: %s", url, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
@@ -0,0 +1,154 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// TicketRule decides which articles are worth a ticket: any article mentioning a
+// watchlist term, or any article at or above MinRank. A zero MinRank disables
+// the rank threshold.
+type TicketRule struct {
+	Watchlist []string
+	MinRank   int
+}
+
+// Match reports whether an article should open a ticket and why.
+func (r TicketRule) Match(article models.NewsArticle) (bool, string) {
+	content := strings.ToLower(article.Title + " " + article.Description)
+	for _, term := range r.Watchlist {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term != "" && strings.Contains(content, term) {
+			return true, fmt.Sprintf("matched watchlist term %q", term)
+		}
+	}
+	if r.MinRank > 0 && article.Rank >= r.MinRank {
+		return true, fmt.Sprintf("rank %d exceeds threshold %d", article.Rank, r.MinRank)
+	}
+	return false, ""
+}
+
+// JiraSink is the delivery queue sink opening the tickets of queued articles.
+const JiraSink = "jira"
+
+// Jira opens Jira issues through the REST API v2.
+type Jira struct {
+	BaseURL   string
+	Email     string
+	APIToken  string
+	Project   string
+	IssueType string
+	Labels    []string
+	Rule      TicketRule
+	// Queue holds the matching articles until their ticket is opened, so
+	// that they survive restarts and Jira outages. They are handled right
+	// away when it is nil.
+	Queue *DeliveryQueue
+}
+
+type jiraIssueResponse struct {
+	Key string `json:"key"`
+}
+
+// Start registers the sink opening the tickets with Queue. Jira calls are
+// slow compared to inserts, so articles are queued rather than handled inline.
+func (j *Jira) Start() {
+	if j.Queue != nil {
+		j.Queue.RegisterArticleSink(JiraSink, j.HandleArticle)
+	}
+}
+
+// Enqueue queues an article matching the rule for a ticket. It is meant to be
+// registered as a db.ArticleHook.
+func (j *Jira) Enqueue(article models.NewsArticle) {
+	if ok, _ := j.Rule.Match(article); !ok {
+		return
+	}
+	if j.Queue == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := j.HandleArticle(ctx, article); err != nil {
+			log.Printf("Error creating Jira ticket for %s: %v", article.URL, err)
+		}
+		return
+	}
+	if err := j.Queue.EnqueueArticle(JiraSink, article); err != nil {
+		log.Printf("Error queueing %s for a Jira ticket: %v", article.URL, err)
+	}
+}
+
+// HandleArticle opens a ticket for a matching article unless one already
+// exists for the same story.
+func (j *Jira) HandleArticle(ctx context.Context, article models.NewsArticle) error {
+	ok, reason := j.Rule.Match(article)
+	if !ok {
+		return nil
+	}
+
+	storyKey := db.StoryKey(article.Title)
+	existing, err := db.GetTicketKey("jira", storyKey)
+	if err != nil {
+		return fmt.Errorf("failed to look up existing ticket: %v", err)
+	}
+	if existing != "" {
+		return nil
+	}
+
+	key, err := j.createIssue(ctx, article, reason)
+	if err != nil {
+		return err
+	}
+	log.Printf("Created Jira ticket %s for %s", key, article.URL)
+	return db.SaveTicketKey("jira", storyKey, article.URL, key)
+}
+
+func (j *Jira) createIssue(ctx context.Context, article models.NewsArticle, reason string) (string, error) {
+	issueType := j.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	labels := j.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+
+	description := fmt.Sprintf("%s\n\nSource: %s\nCategory: %s\nRank: %d\nPublished: %s\nReason: %s\n\n%s",
+		article.URL, article.SourceURL, article.Category, article.Rank,
+		article.PublishedAt.Format(time.RFC3339), reason, article.Description)
+
+	issue := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.Project},
+			"summary":     truncate(article.Title, 250),
+			"description": description,
+			"issuetype":   map[string]string{"name": issueType},
+			"labels":      labels,
+		},
+	}
+
+	headers := map[string]string{"Authorization": basicAuth(j.Email, j.APIToken)}
+
+	var resp jiraIssueResponse
+	if err := postJSON(ctx, strings.TrimRight(j.BaseURL, "/")+"/rest/api/2/issue", headers, issue, &resp); err != nil {
+		return "", err
+	}
+	if resp.Key == "" {
+		return "", fmt.Errorf("Jira response did not include an issue key")
+	}
+	return resp.Key, nil
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
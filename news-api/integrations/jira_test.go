@@ -0,0 +1,112 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTicketRuleMatch(t *testing.T) {
+	rule := TicketRule{Watchlist: []string{"Ivanti", " citrix "}, MinRank: 10}
+
+	ok, reason := rule.Match(models.NewsArticle{Title: "Ivanti patches VPN flaw"})
+	assert.True(t, ok)
+	assert.Contains(t, reason, "ivanti")
+
+	ok, _ = rule.Match(models.NewsArticle{Title: "Bug", Description: "Citrix Bleed returns"})
+	assert.True(t, ok)
+
+	ok, reason = rule.Match(models.NewsArticle{Title: "Big breach", Rank: 12})
+	assert.True(t, ok)
+	assert.Contains(t, reason, "rank 12")
+
+	ok, _ = rule.Match(models.NewsArticle{Title: "Gadget review", Rank: 3})
+	assert.False(t, ok)
+}
+
+func TestJiraCreatesOneTicketPerStory(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+
+	var created int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/issue", r.URL.Path)
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "analyst@example.com", user)
+		assert.Equal(t, "token", pass)
+
+		var issue map[string]map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&issue))
+		assert.Equal(t, map[string]interface{}{"key": "SEC"}, issue["fields"]["project"])
+		assert.Equal(t, []interface{}{"threatfeed"}, issue["fields"]["labels"])
+
+		created++
+		json.NewEncoder(w).Encode(map[string]string{"key": "SEC-1"})
+	}))
+	defer server.Close()
+
+	j := &Jira{
+		BaseURL:  server.URL,
+		Email:    "analyst@example.com",
+		APIToken: "token",
+		Project:  "SEC",
+		Labels:   []string{"threatfeed"},
+		Rule:     TicketRule{Watchlist: []string{"ivanti"}},
+	}
+
+	first := models.NewsArticle{Title: "Ivanti zero-day exploited", URL: "https://a.example.com/1"}
+	syndicated := models.NewsArticle{Title: "Ivanti Zero-Day Exploited!", URL: "https://b.example.com/2"}
+	unrelated := models.NewsArticle{Title: "New phone released", URL: "https://c.example.com/3"}
+
+	require.NoError(t, j.HandleArticle(context.Background(), first))
+	require.NoError(t, j.HandleArticle(context.Background(), syndicated))
+	require.NoError(t, j.HandleArticle(context.Background(), unrelated))
+	assert.Equal(t, 1, created, "the same story should only create one ticket")
+
+	key, err := db.GetTicketKey("jira", db.StoryKey(first.Title))
+	require.NoError(t, err)
+	assert.Equal(t, "SEC-1", key)
+}
+
+func TestJiraQueuesMatchingArticles(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	available := false
+	var created int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		created++
+		json.NewEncoder(w).Encode(map[string]string{"key": "SEC-2"})
+	}))
+	defer server.Close()
+
+	j := &Jira{BaseURL: server.URL, Project: "SEC", Rule: TicketRule{Watchlist: []string{"ivanti"}}, Queue: NewDeliveryQueue()}
+	j.Queue.RetryBackoff = time.Minute
+	j.Start()
+	j.Enqueue(models.NewsArticle{Title: "Ivanti zero-day exploited", URL: "https://a.example.com/1"})
+	j.Enqueue(models.NewsArticle{Title: "New phone released", URL: "https://c.example.com/3"})
+	queued, err := db.GetDeliveries(db.DeliveryFilter{Sink: JiraSink})
+	require.NoError(t, err)
+	require.Len(t, queued, 1, "only matching articles are queued")
+
+	// Tickets wait out a Jira outage.
+	now := time.Now().Add(time.Second)
+	j.Queue.Process(now)
+	available = true
+	j.Queue.Process(now.Add(time.Minute))
+	assert.Equal(t, 1, created)
+	key, err := db.GetTicketKey("jira", db.StoryKey("Ivanti zero-day exploited"))
+	require.NoError(t, err)
+	assert.Equal(t, "SEC-2", key)
+}
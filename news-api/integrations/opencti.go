@@ -0,0 +1,286 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"news-api/db"
+	"news-api/extract"
+	"news-api/models"
+)
+
+// OpenCTI pushes articles into an OpenCTI instance as reports, together with the
+// vulnerabilities and indicators extracted from them.
+type OpenCTI struct {
+	URL     string
+	Token   string
+	MinRank int
+	// MaxAttempts is the number of runs an article may fail in before it is
+	// skipped, 5 by default.
+	MaxAttempts int
+}
+
+// openCTIIntegration names the OpenCTI sync in the integration bookkeeping
+// tables.
+const openCTIIntegration = "opencti"
+
+// openCTIPageSize is how many articles a sync loads at a time.
+var openCTIPageSize = 200
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+func (o *OpenCTI) graphql(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	var resp graphQLResponse
+	headers := map[string]string{"Authorization": "Bearer " + o.Token}
+	payload := map[string]interface{}{"query": query, "variables": variables}
+	if err := postJSON(ctx, strings.TrimRight(o.URL, "/")+"/graphql", headers, payload, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("OpenCTI GraphQL error: %s", resp.Errors[0].Message)
+	}
+	if out != nil {
+		return json.Unmarshal(resp.Data, out)
+	}
+	return nil
+}
+
+// Start schedules the "opencti" job, which syncs articles now and on the given
+// interval. The first run covers the articles ingested in the last 24 hours,
+// later runs those stored since, however old their publication date or long
+// the service was down.
+func (o *OpenCTI) Start(interval time.Duration) {
+	err := db.RegisterJob(db.Job{Name: "opencti", Schedule: db.Every(interval), RunAtStart: true, Run: func() error {
+		o.syncOnce()
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling the OpenCTI sync: %v", err)
+	}
+}
+
+// syncOnce pushes the articles stored after the last one synced, in pages by
+// article ID until none are left. The cursor is stored in the database, so a
+// restart resumes where the last run stopped. A failed page is retried on the
+// next run.
+func (o *OpenCTI) syncOnce() {
+	if !db.IsLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	lastID, ok, err := db.GetIntegrationCursor(openCTIIntegration)
+	if err != nil {
+		log.Printf("Error loading the OpenCTI sync cursor: %v", err)
+		return
+	}
+	var since time.Time
+	if !ok {
+		since = time.Now().Add(-24 * time.Hour)
+	}
+	total := 0
+	for {
+		articles, err := db.QueryArticles(db.ArticleFilter{
+			MinRank:     o.MinRank,
+			StartDate:   since,
+			ByIngestion: true,
+			AfterID:     lastID,
+			SortBy:      "idAsc",
+			Limit:       openCTIPageSize,
+		})
+		if err != nil {
+			log.Printf("Error loading articles for OpenCTI sync: %v", err)
+			return
+		}
+		if len(articles) == 0 {
+			break
+		}
+		pushed, err := o.Sync(ctx, articles)
+		total += pushed
+		if err != nil {
+			log.Printf("OpenCTI sync stopped early after %d reports: %v", total, err)
+			return
+		}
+		lastID = articles[len(articles)-1].ID
+		if err := db.SaveIntegrationCursor(openCTIIntegration, lastID); err != nil {
+			log.Printf("Error saving the OpenCTI sync cursor: %v", err)
+			return
+		}
+	}
+	log.Printf("OpenCTI sync completed, %d reports created.", total)
+}
+
+// Sync pushes each eligible article that is not yet in OpenCTI and returns how
+// many reports were created. Articles are de-duplicated by their URL, which is
+// stored as the report's external reference. Sync stops at the first article
+// that fails, unless it failed in MaxAttempts runs: it is then skipped, so
+// that an article OpenCTI rejects does not hold up the ones after it.
+func (o *OpenCTI) Sync(ctx context.Context, articles []models.NewsArticle) (int, error) {
+	maxAttempts := o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 5
+	}
+	pushed := 0
+	for _, article := range articles {
+		if article.Rank < o.MinRank {
+			continue
+		}
+		created, err := o.pushArticle(ctx, article)
+		if err != nil {
+			err = fmt.Errorf("failed to push %s: %v", article.URL, err)
+			attempts, recordErr := db.RecordIntegrationFailure(openCTIIntegration, article.ID, err)
+			if recordErr != nil || attempts < maxAttempts {
+				return pushed, err
+			}
+			log.Printf("Giving up on OpenCTI sync of %s after %d attempts: %v", article.URL, attempts, err)
+			continue
+		}
+		if err := db.ClearIntegrationFailures(openCTIIntegration, article.ID); err != nil {
+			log.Printf("Error clearing OpenCTI sync failures of %s: %v", article.URL, err)
+		}
+		if created {
+			pushed++
+		}
+	}
+	return pushed, nil
+}
+
+func (o *OpenCTI) pushArticle(ctx context.Context, article models.NewsArticle) (bool, error) {
+	if key, err := db.GetTicketKey("opencti", article.URL); err != nil {
+		return false, err
+	} else if key != "" {
+		return false, nil
+	}
+
+	existing, err := o.findExternalReference(ctx, article.URL)
+	if err != nil {
+		return false, err
+	}
+	if existing != "" {
+		return false, db.SaveTicketKey("opencti", article.URL, article.URL, existing)
+	}
+
+	refID, err := o.createID(ctx, "externalReferenceAdd", "ExternalReferenceAddInput", map[string]interface{}{
+		"source_name": "Threatfeed",
+		"url":         article.URL,
+		"description": article.SourceURL,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	text := article.Title + "\n" + article.Description
+	var objectIDs []string
+	for _, ioc := range extract.IOCs(text) {
+		var id string
+		if ioc.Type == extract.TypeCVE {
+			id, err = o.createID(ctx, "vulnerabilityAdd", "VulnerabilityAddInput", map[string]interface{}{
+				"name":               ioc.Value,
+				"externalReferences": []string{refID},
+			})
+		} else {
+			pattern, observableType := stixPattern(ioc)
+			id, err = o.createID(ctx, "indicatorAdd", "IndicatorAddInput", map[string]interface{}{
+				"name":                           ioc.Value,
+				"pattern":                        pattern,
+				"pattern_type":                   "stix",
+				"x_opencti_main_observable_type": observableType,
+				"valid_from":                     article.PublishedAt.UTC().Format(time.RFC3339),
+				"externalReferences":             []string{refID},
+			})
+		}
+		if err != nil {
+			return false, err
+		}
+		objectIDs = append(objectIDs, id)
+	}
+
+	reportID, err := o.createID(ctx, "reportAdd", "ReportAddInput", map[string]interface{}{
+		"name":               article.Title,
+		"description":        article.Description,
+		"published":          article.PublishedAt.UTC().Format(time.RFC3339),
+		"report_types":       []string{"threat-report"},
+		"externalReferences": []string{refID},
+		"objects":            objectIDs,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, db.SaveTicketKey("opencti", article.URL, article.URL, reportID)
+}
+
+// findExternalReference returns the ID of an existing external reference with the given URL.
+func (o *OpenCTI) findExternalReference(ctx context.Context, url string) (string, error) {
+	query := `query ExternalReferences($filters: FilterGroup) {
+		externalReferences(first: 1, filters: $filters) { edges { node { id } } }
+	}`
+	filters := map[string]interface{}{
+		"mode":         "and",
+		"filters":      []map[string]interface{}{{"key": "url", "values": []string{url}}},
+		"filterGroups": []interface{}{},
+	}
+	var data struct {
+		ExternalReferences struct {
+			Edges []struct {
+				Node struct {
+					ID string `json:"id"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"externalReferences"`
+	}
+	if err := o.graphql(ctx, query, map[string]interface{}{"filters": filters}, &data); err != nil {
+		return "", err
+	}
+	if len(data.ExternalReferences.Edges) == 0 {
+		return "", nil
+	}
+	return data.ExternalReferences.Edges[0].Node.ID, nil
+}
+
+// createID runs an xxxAdd mutation and returns the new object's ID. OpenCTI
+// upserts vulnerabilities and indicators, so repeated CVEs resolve to one object.
+func (o *OpenCTI) createID(ctx context.Context, mutation, inputType string, input map[string]interface{}) (string, error) {
+	query := fmt.Sprintf("mutation Create($input: %s!) { %s(input: $input) { id } }", inputType, mutation)
+	var data map[string]struct {
+		ID string `json:"id"`
+	}
+	if err := o.graphql(ctx, query, map[string]interface{}{"input": input}, &data); err != nil {
+		return "", err
+	}
+	id := data[mutation].ID
+	if id == "" {
+		return "", fmt.Errorf("%s returned no id", mutation)
+	}
+	return id, nil
+}
+
+// stixPattern converts an IOC into a STIX 2.1 pattern and OpenCTI observable type.
+func stixPattern(ioc extract.IOC) (string, string) {
+	value := strings.ReplaceAll(ioc.Value, "'", `\'`)
+	switch ioc.Type {
+	case extract.TypeIPv4:
+		return fmt.Sprintf("[ipv4-addr:value = '%s']", value), "IPv4-Addr"
+	case extract.TypeDomain:
+		return fmt.Sprintf("[domain-name:value = '%s']", value), "Domain-Name"
+	case extract.TypeURL:
+		return fmt.Sprintf("[url:value = '%s']", value), "Url"
+	case extract.TypeMD5:
+		return fmt.Sprintf("[file:hashes.MD5 = '%s']", value), "StixFile"
+	case extract.TypeSHA1:
+		return fmt.Sprintf("[file:hashes.'SHA-1' = '%s']", value), "StixFile"
+	default:
+		return fmt.Sprintf("[file:hashes.'SHA-256' = '%s']", value), "StixFile"
+	}
+}
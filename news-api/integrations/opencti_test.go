@@ -0,0 +1,189 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/extract"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenCTISync(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+
+	var mutations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/graphql", r.URL.Path)
+		assert.Equal(t, "Bearer cti-token", r.Header.Get("Authorization"))
+
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		if strings.Contains(req.Query, "externalReferences(") {
+			filters := req.Variables["filters"].(map[string]interface{})
+			url := filters["filters"].([]interface{})[0].(map[string]interface{})["values"].([]interface{})[0]
+			if url == "https://example.com/already-in-opencti" {
+				w.Write([]byte(`{"data":{"externalReferences":{"edges":[{"node":{"id":"ref-existing"}}]}}}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"externalReferences":{"edges":[]}}}`))
+			return
+		}
+
+		for _, m := range []string{"externalReferenceAdd", "vulnerabilityAdd", "indicatorAdd", "reportAdd"} {
+			if strings.Contains(req.Query, m+"(") {
+				mutations = append(mutations, m)
+				fmt.Fprintf(w, `{"data":{"%s":{"id":"%s-%d"}}}`, m, m, len(mutations))
+				return
+			}
+		}
+		t.Fatalf("unexpected query: %s", req.Query)
+	}))
+	defer server.Close()
+
+	o := &OpenCTI{URL: server.URL, Token: "cti-token", MinRank: 3}
+	articles := []models.NewsArticle{
+		{Title: "PAN-OS CVE-2024-3400 exploited", Description: "C2 at 203.0.113[.]7", URL: "https://example.com/a", Rank: 10, PublishedAt: time.Now()},
+		{Title: "Gadget review", URL: "https://example.com/b", Rank: 1},
+		{Title: "Already synced elsewhere", URL: "https://example.com/already-in-opencti", Rank: 8},
+	}
+
+	pushed, err := o.Sync(context.Background(), articles)
+	require.NoError(t, err)
+	assert.Equal(t, 1, pushed)
+	assert.Equal(t, []string{"externalReferenceAdd", "vulnerabilityAdd", "indicatorAdd", "reportAdd"}, mutations)
+
+	// A second sync must not create anything new.
+	pushed, err = o.Sync(context.Background(), articles)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pushed)
+	assert.Len(t, mutations, 4)
+}
+
+func TestOpenCTISyncPages(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	require.NoError(t, db.ClearAllArticlesForTest())
+	defer func(size int) { openCTIPageSize = size }(openCTIPageSize)
+	openCTIPageSize = 2
+
+	var reports []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "externalReferences("):
+			w.Write([]byte(`{"data":{"externalReferences":{"edges":[]}}}`))
+		case strings.Contains(req.Query, "reportAdd("):
+			input := req.Variables["input"].(map[string]interface{})
+			reports = append(reports, input["name"].(string))
+			w.Write([]byte(`{"data":{"reportAdd":{"id":"report"}}}`))
+		default:
+			w.Write([]byte(`{"data":{"externalReferenceAdd":{"id":"ref"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	// An article ingested late with an old publication date is still synced.
+	for _, article := range []models.NewsArticle{
+		{Title: "First breach", URL: "https://example.com/1", Rank: 5, PublishedAt: time.Now()},
+		{Title: "Backfilled breach", URL: "https://example.com/2", Rank: 5, PublishedAt: time.Now().AddDate(0, -3, 0)},
+		{Title: "Third breach", URL: "https://example.com/3", Rank: 5, PublishedAt: time.Now()},
+	} {
+		require.NoError(t, db.InsertArticle(article))
+	}
+
+	o := &OpenCTI{URL: server.URL, Token: "cti-token", MinRank: 3}
+	o.syncOnce()
+	assert.Equal(t, []string{"First breach", "Backfilled breach", "Third breach"}, reports)
+
+	// The cursor is stored, so a restarted connector carries on from it.
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Fourth breach", URL: "https://example.com/4", Rank: 5, PublishedAt: time.Now()}))
+	o = &OpenCTI{URL: server.URL, Token: "cti-token", MinRank: 3}
+	o.syncOnce()
+	assert.Equal(t, []string{"First breach", "Backfilled breach", "Third breach", "Fourth breach"}, reports)
+	articles, err := db.QueryArticles(db.ArticleFilter{SortBy: "idAsc"})
+	require.NoError(t, err)
+	lastID, ok, err := db.GetIntegrationCursor(openCTIIntegration)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, articles[len(articles)-1].ID, lastID)
+}
+
+func TestOpenCTISyncSkipsRejectedArticles(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	require.NoError(t, db.ClearAllArticlesForTest())
+
+	var reports []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch {
+		case strings.Contains(req.Query, "externalReferences("):
+			w.Write([]byte(`{"data":{"externalReferences":{"edges":[]}}}`))
+		case strings.Contains(req.Query, "reportAdd("):
+			input := req.Variables["input"].(map[string]interface{})
+			if input["name"] == "Rejected breach" {
+				w.Write([]byte(`{"errors":[{"message":"Invalid report"}]}`))
+				return
+			}
+			reports = append(reports, input["name"].(string))
+			w.Write([]byte(`{"data":{"reportAdd":{"id":"report"}}}`))
+		default:
+			w.Write([]byte(`{"data":{"externalReferenceAdd":{"id":"ref"}}}`))
+		}
+	}))
+	defer server.Close()
+
+	for _, title := range []string{"Rejected breach", "Next breach"} {
+		require.NoError(t, db.InsertArticle(models.NewsArticle{Title: title, URL: "https://example.com/" + title, Rank: 5, PublishedAt: time.Now()}))
+	}
+
+	o := &OpenCTI{URL: server.URL, Token: "cti-token", MinRank: 3, MaxAttempts: 2}
+	o.syncOnce()
+	assert.Empty(t, reports, "the sync stops at a failing article")
+	o.syncOnce()
+	assert.Equal(t, []string{"Next breach"}, reports, "the article is skipped after MaxAttempts runs")
+	o.syncOnce()
+	assert.Equal(t, []string{"Next breach"}, reports)
+}
+
+func TestOpenCTIGraphQLErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"You are not authenticated"}]}`))
+	}))
+	defer server.Close()
+
+	o := &OpenCTI{URL: server.URL}
+	_, err := o.findExternalReference(context.Background(), "https://example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not authenticated")
+}
+
+func TestStixPattern(t *testing.T) {
+	pattern, typ := stixPattern(extract.IOC{Type: extract.TypeSHA256, Value: "abc"})
+	assert.Equal(t, "[file:hashes.'SHA-256' = 'abc']", pattern)
+	assert.Equal(t, "StixFile", typ)
+
+	pattern, typ = stixPattern(extract.IOC{Type: extract.TypeDomain, Value: "evil.example"})
+	assert.Equal(t, "[domain-name:value = 'evil.example']", pattern)
+	assert.Equal(t, "Domain-Name", typ)
+}
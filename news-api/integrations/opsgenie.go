@@ -0,0 +1,60 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"news-api/db"
+)
+
+// DefaultOpsgenieAPIURL is the Opsgenie API base URL. EU accounts use https://api.eu.opsgenie.com.
+const DefaultOpsgenieAPIURL = "https://api.opsgenie.com"
+
+// Opsgenie raises alerts through the Opsgenie Alert API.
+type Opsgenie struct {
+	APIKey string
+	APIURL string
+}
+
+func (o *Opsgenie) apiURL() string {
+	if o.APIURL != "" {
+		return strings.TrimRight(o.APIURL, "/")
+	}
+	return DefaultOpsgenieAPIURL
+}
+
+func (o *Opsgenie) headers() map[string]string {
+	return map[string]string{"Authorization": "GenieKey " + o.APIKey}
+}
+
+// Name identifies the escalation target in logs.
+func (o *Opsgenie) Name() string { return "Opsgenie" }
+
+// Trigger creates the Code Red alert. Opsgenie de-duplicates open alerts by alias.
+func (o *Opsgenie) Trigger(ctx context.Context, score db.ThreatScore) error {
+	alert := map[string]interface{}{
+		"message":  fmt.Sprintf("Threatfeed threat level is %s", score.ThreatLevel),
+		"alias":    codeRedDedupKey,
+		"priority": "P1",
+		"source":   "threatfeed",
+		"details": map[string]string{
+			"highRankCount":   fmt.Sprint(score.HighRankCount),
+			"mediumRankCount": fmt.Sprint(score.MediumRankCount),
+			"lowRankCount":    fmt.Sprint(score.LowRankCount),
+			"totalArticles":   fmt.Sprint(score.TotalArticles),
+		},
+	}
+	return postJSON(ctx, o.apiURL()+"/v2/alerts", o.headers(), alert, nil)
+}
+
+// Resolve closes the Code Red alert.
+func (o *Opsgenie) Resolve(ctx context.Context, score db.ThreatScore) error {
+	endpoint := o.apiURL() + "/v2/alerts/" + url.PathEscape(codeRedDedupKey) + "/close?identifierType=alias"
+	body := map[string]string{
+		"source": "threatfeed",
+		"note":   fmt.Sprintf("Threat level dropped to %s", score.ThreatLevel),
+	}
+	return postJSON(ctx, endpoint, o.headers(), body, nil)
+}
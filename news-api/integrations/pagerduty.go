@@ -0,0 +1,72 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+
+	"news-api/db"
+)
+
+// DefaultPagerDutyEventsURL is the PagerDuty Events API v2 enqueue endpoint.
+const DefaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// codeRedDedupKey ties trigger and resolve events to the same PagerDuty incident.
+const codeRedDedupKey = "threatfeed-code-red"
+
+// PagerDuty pages on-call responders through the Events API v2.
+type PagerDuty struct {
+	RoutingKey string
+	EventsURL  string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	CustomDetails db.ThreatScore `json:"custom_details"`
+}
+
+func (p *PagerDuty) eventsURL() string {
+	if p.EventsURL != "" {
+		return p.EventsURL
+	}
+	return DefaultPagerDutyEventsURL
+}
+
+// Name identifies the escalation target in logs.
+func (p *PagerDuty) Name() string { return "PagerDuty" }
+
+// Trigger opens (or re-triggers) the Code Red incident.
+func (p *PagerDuty) Trigger(ctx context.Context, score db.ThreatScore) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    codeRedDedupKey,
+		Payload: &pagerDutyPayload{
+			Summary:       fmt.Sprintf("Threatfeed threat level is %s (%d high-rank articles in the last 24h)", score.ThreatLevel, score.HighRankCount),
+			Source:        "threatfeed",
+			Severity:      "critical",
+			Component:     "threat-level",
+			CustomDetails: score,
+		},
+	}
+	return postJSON(ctx, p.eventsURL(), nil, event, nil)
+}
+
+// Resolve closes the Code Red incident.
+func (p *PagerDuty) Resolve(ctx context.Context, score db.ThreatScore) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    codeRedDedupKey,
+	}
+	return postJSON(ctx, p.eventsURL(), nil, event, nil)
+}
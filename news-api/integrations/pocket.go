@@ -0,0 +1,81 @@
+package integrations
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"news-api/models"
+)
+
+// DefaultPocketURL is the Pocket API base URL.
+const DefaultPocketURL = "https://getpocket.com"
+
+// Pocket saves articles to users' Pocket reading queues. Users connect their
+// account through Pocket's OAuth flow: a request token is obtained for the
+// redirect URI, the user approves it at AuthorizeURL, and it is exchanged for
+// an access token once Pocket redirects back.
+type Pocket struct {
+	ConsumerKey string
+	APIURL      string
+}
+
+func (p *Pocket) apiURL() string {
+	if p.APIURL != "" {
+		return strings.TrimRight(p.APIURL, "/")
+	}
+	return DefaultPocketURL
+}
+
+// headers asks Pocket for JSON responses, which it otherwise form-encodes.
+func (p *Pocket) headers() map[string]string {
+	return map[string]string{"X-Accept": "application/json"}
+}
+
+// RequestToken starts an authorization that returns to redirectURI.
+func (p *Pocket) RequestToken(ctx context.Context, redirectURI string) (string, error) {
+	var resp struct {
+		Code string `json:"code"`
+	}
+	body := map[string]string{"consumer_key": p.ConsumerKey, "redirect_uri": redirectURI}
+	if err := postJSON(ctx, p.apiURL()+"/v3/oauth/request", p.headers(), body, &resp); err != nil {
+		return "", err
+	}
+	return resp.Code, nil
+}
+
+// AuthorizeURL is where the user approves the request token.
+func (p *Pocket) AuthorizeURL(requestToken, redirectURI string) string {
+	return p.apiURL() + "/auth/authorize?" + url.Values{"request_token": {requestToken}, "redirect_uri": {redirectURI}}.Encode()
+}
+
+// Authorize exchanges an approved request token for an access token and the
+// username of the account.
+func (p *Pocket) Authorize(ctx context.Context, requestToken string) (string, string, error) {
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		Username    string `json:"username"`
+	}
+	body := map[string]string{"consumer_key": p.ConsumerKey, "code": requestToken}
+	if err := postJSON(ctx, p.apiURL()+"/v3/oauth/authorize", p.headers(), body, &resp); err != nil {
+		return "", "", err
+	}
+	return resp.AccessToken, resp.Username, nil
+}
+
+// Add saves an article to the reading queue of the account of accessToken,
+// tagged "threatfeed" and with its category.
+func (p *Pocket) Add(ctx context.Context, accessToken string, article models.NewsArticle) error {
+	tags := []string{"threatfeed"}
+	if article.Category != "" {
+		tags = append(tags, strings.ToLower(article.Category))
+	}
+	body := map[string]string{
+		"consumer_key": p.ConsumerKey,
+		"access_token": accessToken,
+		"url":          article.URL,
+		"title":        article.Title,
+		"tags":         strings.Join(tags, ","),
+	}
+	return postJSON(ctx, p.apiURL()+"/v3/add", p.headers(), body, nil)
+}
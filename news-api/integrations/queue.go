@@ -0,0 +1,238 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// WebhookSink is the delivery queue sink POSTing payloads to their target URL.
+const WebhookSink = "webhook"
+
+// maxRetryBackoff caps the delay between two attempts of a delivery.
+const maxRetryBackoff = time.Hour
+
+// DeliveryQueue delivers outbound messages through a queue stored in the
+// database, so they survive restarts. Failed deliveries are retried with
+// exponential backoff and kept as dead after MaxAttempts, to be inspected
+// and replayed. Only the leader instance delivers; any instance may enqueue.
+type DeliveryQueue struct {
+	// MaxAttempts is the number of attempts before a delivery is given up on,
+	// 8 by default.
+	MaxAttempts int
+	// RetryBackoff is the delay after the first failed attempt, 30 seconds by
+	// default. It doubles after every further failure, up to an hour.
+	RetryBackoff time.Duration
+	// Interval is how often due deliveries are looked for, 2 seconds by default.
+	Interval time.Duration
+
+	mu    sync.Mutex
+	sinks map[string]queueSink
+	// outputs are the Sinks that published articles and level changes are
+	// dispatched to.
+	outputs []sinkOutput
+}
+
+type queueSink struct {
+	batchSize int
+	send      func(ctx context.Context, deliveries []models.Delivery) error
+}
+
+// NewDeliveryQueue returns a queue with the webhook sink registered.
+func NewDeliveryQueue() *DeliveryQueue {
+	q := &DeliveryQueue{sinks: map[string]queueSink{}}
+	q.Register(WebhookSink, 1, func(ctx context.Context, deliveries []models.Delivery) error {
+		d := deliveries[0]
+		return sendWebhook(ctx, d.Target, d.ContentType, []byte(d.Payload))
+	})
+	return q
+}
+
+// Register adds a sink delivering up to batchSize queued messages at once.
+// The whole batch is retried if send fails. Messages queued for a sink that
+// is not registered stay pending.
+func (q *DeliveryQueue) Register(sink string, batchSize int, send func(ctx context.Context, deliveries []models.Delivery) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.sinks[sink] = queueSink{batchSize: batchSize, send: send}
+}
+
+// RegisterEventSink adds a sink delivering batches of ArticleEvents, such as
+// the SIEM and stream sinks.
+func (q *DeliveryQueue) RegisterEventSink(sink string, batchSize int, send func(ctx context.Context, events []interface{}) error) {
+	q.Register(sink, batchSize, func(ctx context.Context, deliveries []models.Delivery) error {
+		events := make([]interface{}, 0, len(deliveries))
+		for _, d := range deliveries {
+			var event ArticleEvent
+			if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+				return fmt.Errorf("failed to decode queued event %d: %v", d.ID, err)
+			}
+			events = append(events, event)
+		}
+		return send(ctx, events)
+	})
+}
+
+// RegisterArticleSink adds a sink handling the articles queued for it with
+// EnqueueArticle one at a time, such as ticketing and saved search
+// notifications.
+func (q *DeliveryQueue) RegisterArticleSink(sink string, handle func(ctx context.Context, article models.NewsArticle) error) {
+	q.Register(sink, 1, func(ctx context.Context, deliveries []models.Delivery) error {
+		var queued queuedArticle
+		if err := json.Unmarshal([]byte(deliveries[0].Payload), &queued); err != nil {
+			return fmt.Errorf("failed to decode queued article %d: %v", deliveries[0].ID, err)
+		}
+		article := queued.NewsArticle
+		article.OrgID = queued.OrgID
+		return handle(ctx, article)
+	})
+}
+
+// queuedArticle is the payload of EnqueueArticle. The JSON of an article
+// leaves out its organization, which saved searches are matched by.
+type queuedArticle struct {
+	models.NewsArticle
+	OrgID int64 `json:"orgId"`
+}
+
+// EnqueueArticle stores an article for a sink added with RegisterArticleSink.
+func (q *DeliveryQueue) EnqueueArticle(sink string, article models.NewsArticle) error {
+	return q.EnqueueJSON(sink, "", queuedArticle{NewsArticle: article, OrgID: article.OrgID})
+}
+
+// Enqueue stores a message for delivery by a sink.
+func (q *DeliveryQueue) Enqueue(sink, target, contentType string, payload []byte) error {
+	_, err := db.EnqueueDelivery(models.Delivery{Sink: sink, Target: target, ContentType: contentType, Payload: string(payload)})
+	return err
+}
+
+// EnqueueJSON stores a JSON-encoded message for delivery by a sink.
+func (q *DeliveryQueue) EnqueueJSON(sink, target string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return q.Enqueue(sink, target, "application/json", payload)
+}
+
+// Start delivers due messages every Interval while this instance is the leader.
+func (q *DeliveryQueue) Start() {
+	interval := q.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	go func() {
+		for range time.NewTicker(interval).C {
+			if db.IsLeader() {
+				q.Process(time.Now())
+			}
+		}
+	}()
+}
+
+// Process delivers the messages due at now, sink by sink. A sink whose batch
+// fails is not tried again before the next call.
+func (q *DeliveryQueue) Process(now time.Time) {
+	q.mu.Lock()
+	names := make([]string, 0, len(q.sinks))
+	sinks := make(map[string]queueSink, len(q.sinks))
+	for name, sink := range q.sinks {
+		names = append(names, name)
+		sinks[name] = sink
+	}
+	q.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		sink := sinks[name]
+		for {
+			due, err := db.GetDueDeliveries(name, now, sink.batchSize)
+			if err != nil {
+				log.Printf("Error loading due deliveries for %s: %v", name, err)
+				break
+			}
+			if len(due) == 0 || !q.deliver(name, sink, due, now) || len(due) < sink.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliver sends a batch and records the outcome, reporting whether it was delivered.
+func (q *DeliveryQueue) deliver(name string, sink queueSink, batch []models.Delivery, now time.Time) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	err := sink.send(ctx, batch)
+	cancel()
+	if err == nil {
+		ids := make([]int64, len(batch))
+		for i, d := range batch {
+			ids[i] = d.ID
+		}
+		if err := db.CompleteDeliveries(ids); err != nil {
+			log.Printf("Error removing %d delivered messages for %s: %v", len(batch), name, err)
+		}
+		return true
+	}
+
+	maxAttempts := q.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	dead := 0
+	for i := range batch {
+		d := &batch[i]
+		d.Attempts++
+		d.LastError = err.Error()
+		if d.Attempts >= maxAttempts {
+			d.Status = db.DeliveryDead
+			dead++
+		} else {
+			d.NextAttemptAt = now.Add(q.backoff(d.Attempts))
+		}
+	}
+	log.Printf("Error delivering %d messages to %s (attempt %d): %v", len(batch), name, batch[0].Attempts, err)
+	if dead > 0 {
+		log.Printf("Gave up on %d messages for %s after %d attempts", dead, name, maxAttempts)
+	}
+	if err := db.RescheduleDeliveries(batch); err != nil {
+		log.Printf("Error rescheduling deliveries for %s: %v", name, err)
+	}
+	return false
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (q *DeliveryQueue) backoff(attempts int) time.Duration {
+	backoff := q.RetryBackoff
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// deliverWebhook queues a webhook delivery, or sends it right away when there
+// is no queue.
+func deliverWebhook(ctx context.Context, q *DeliveryQueue, url, contentType string, body []byte) error {
+	if q != nil {
+		return q.Enqueue(WebhookSink, url, contentType, body)
+	}
+	return sendWebhook(ctx, url, contentType, body)
+}
+
+// deliverWebhookJSON queues or sends payload as a JSON body like deliverWebhook.
+func deliverWebhookJSON(ctx context.Context, q *DeliveryQueue, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %v", err)
+	}
+	return deliverWebhook(ctx, q, url, "application/json", body)
+}
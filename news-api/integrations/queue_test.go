@@ -0,0 +1,120 @@
+package integrations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryQueue(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	// The test servers listen on loopback.
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = append(received, string(data))
+	}))
+	defer server.Close()
+
+	var batches [][]interface{}
+	failing := true
+	q := NewDeliveryQueue()
+	q.MaxAttempts = 3
+	q.RetryBackoff = time.Minute
+	q.RegisterEventSink("siem", 2, func(ctx context.Context, events []interface{}) error {
+		if failing {
+			return errors.New("sink unavailable")
+		}
+		batches = append(batches, events)
+		return nil
+	})
+
+	require.NoError(t, q.Enqueue(WebhookSink, server.URL, "application/json", []byte(`{"alert":1}`)))
+	for _, url := range []string{"u1", "u2", "u3"} {
+		require.NoError(t, q.EnqueueJSON("siem", "", NewArticleEvent(models.NewsArticle{Title: url, URL: url})))
+	}
+	require.NoError(t, q.Enqueue("unregistered", "", "application/json", []byte(`{}`)))
+
+	now := time.Now().Add(time.Second)
+	q.Process(now)
+	assert.Equal(t, []string{`{"alert":1}`}, received)
+	pending, err := db.GetDeliveries(db.DeliveryFilter{Status: db.DeliveryPending})
+	require.NoError(t, err)
+	assert.Len(t, pending, 4, "the siem deliveries failed and the unregistered one is left alone")
+	for _, d := range pending {
+		if d.Sink == "siem" && d.Attempts > 0 {
+			assert.Equal(t, "sink unavailable", d.LastError)
+			assert.WithinDuration(t, now.Add(time.Minute), d.NextAttemptAt, time.Second)
+		}
+	}
+
+	// The first batch fails twice more and is given up on; the third event was
+	// never attempted, since the sink was failing.
+	q.Process(now.Add(time.Minute))
+	q.Process(now.Add(3 * time.Minute))
+	dead, err := db.GetDeliveries(db.DeliveryFilter{Status: db.DeliveryDead})
+	require.NoError(t, err)
+	assert.Len(t, dead, 2)
+
+	failing = false
+	_, err = db.ReplayDeliveries(db.DeliveryFilter{Status: db.DeliveryDead})
+	require.NoError(t, err)
+	q.Process(now.Add(4 * time.Minute))
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	assert.Equal(t, "u1", batches[0][0].(ArticleEvent).URL)
+	remaining, err := db.GetDeliveries(db.DeliveryFilter{})
+	require.NoError(t, err)
+	require.Len(t, remaining, 1)
+	assert.Equal(t, "unregistered", remaining[0].Sink)
+}
+
+func TestDeliveryQueueBackoff(t *testing.T) {
+	q := &DeliveryQueue{RetryBackoff: 30 * time.Second}
+	assert.Equal(t, 30*time.Second, q.backoff(1))
+	assert.Equal(t, time.Minute, q.backoff(2))
+	assert.Equal(t, 4*time.Minute, q.backoff(4))
+	assert.Equal(t, time.Hour, q.backoff(20))
+}
+
+func TestSavedSearchNotificationsAreQueued(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	user, err := db.CreateUser("queued", "correct horse")
+	require.NoError(t, err)
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ivanti", Search: "ivanti", NotifyURL: "http://127.0.0.1:1/unreachable"})
+	require.NoError(t, err)
+	org, err := db.CreateOrganization("Acme")
+	require.NoError(t, err)
+	member, err := db.CreateUser("acme", "correct horse")
+	require.NoError(t, err)
+	require.NoError(t, db.SetUserOrg(member.Username, org.ID))
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: member.ID, Name: "Acme Ivanti", Search: "ivanti", NotifyURL: "http://127.0.0.1:1/acme"})
+	require.NoError(t, err)
+
+	// Articles wait in the queue to be matched, with their organization.
+	n := &SavedSearchNotifier{Queue: NewDeliveryQueue()}
+	n.Start()
+	n.Enqueue(models.NewsArticle{Title: "Ivanti VPN exploited", URL: "u1", OrgID: org.ID})
+	queued, err := db.GetDeliveries(db.DeliveryFilter{Sink: SavedSearchSink})
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+
+	n.Queue.Process(time.Now().Add(time.Second))
+	queued, err = db.GetDeliveries(db.DeliveryFilter{Sink: WebhookSink})
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, "http://127.0.0.1:1/acme", queued[0].Target)
+	assert.Contains(t, queued[0].Payload, `"title":"Ivanti VPN exploited"`)
+}
@@ -0,0 +1,349 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/cron"
+	"news-api/db"
+	"news-api/models"
+)
+
+// ReportFormats are the formats a report can be rendered in.
+var ReportFormats = []string{"markdown", "html", "csv", "json"}
+
+// ReportChannels are the channels a report can be delivered through.
+var ReportChannels = []string{"webhook", "slack", "email", "s3"}
+
+// reportFirstPeriod is the period covered by the first run of a report job,
+// which has no previous successful run to continue from.
+const reportFirstPeriod = 24 * time.Hour
+
+// Report is the content of one run of a report job.
+type Report struct {
+	Job      models.ReportJob     `json:"-"`
+	Name     string               `json:"name"`
+	Since    time.Time            `json:"since"`
+	Until    time.Time            `json:"until"`
+	Articles []models.NewsArticle `json:"articles"`
+}
+
+// ReportScheduler runs the report jobs stored in the database when their cron
+// schedule, evaluated in UTC, comes due. Each run covers the articles
+// ingested since the job's last successful run, so articles of failed runs
+// are delivered by the next successful one.
+type ReportScheduler struct {
+	// Mailer and S3 deliver the email and s3 channels; jobs using a channel
+	// that is not configured fail.
+	Mailer *Mailer
+	S3     *S3Uploader
+	// AlertURL receives a JSON alert, also understood by Slack incoming
+	// webhooks, when a job starts failing and when it recovers. Alerts go
+	// through Queue when it is set.
+	AlertURL string
+	Queue    *DeliveryQueue
+	// Interval is how often due jobs are looked for, one minute by default.
+	Interval time.Duration
+
+	mu sync.Mutex
+}
+
+// NextRun returns when a cron schedule next fires after t, or an error if the
+// schedule is invalid or never fires.
+func NextRun(schedule string, t time.Time) (time.Time, error) {
+	s, err := cron.Parse(schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := s.Next(t.UTC())
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule never fires")
+	}
+	return next, nil
+}
+
+// ValidateTarget checks that a delivery target suits its channel and that
+// the channel is configured.
+func (s *ReportScheduler) ValidateTarget(channel, target string) error {
+	switch channel {
+	case "webhook", "slack":
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("target must be an http or https URL")
+		}
+	case "email":
+		if s.Mailer == nil {
+			return fmt.Errorf("email delivery is not configured")
+		}
+		if _, err := mail.ParseAddressList(target); err != nil {
+			return fmt.Errorf("target must be a comma-separated list of email addresses")
+		}
+	case "s3":
+		if s.S3 == nil {
+			return fmt.Errorf("S3 delivery is not configured")
+		}
+		if _, _, err := parseS3Target(target); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("channel must be one of %s", strings.Join(ReportChannels, ", "))
+	}
+	return nil
+}
+
+// Start schedules the "reports" job, which looks for due report jobs every
+// Interval while this instance is the leader.
+func (s *ReportScheduler) Start() {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	err := db.RegisterJob(db.Job{Name: "reports", Schedule: db.Every(interval), Run: func() error {
+		s.RunDue(time.Now())
+		return nil
+	}})
+	if err != nil {
+		log.Printf("Error scheduling reports: %v", err)
+	}
+}
+
+// RunDue runs every job due at now.
+func (s *ReportScheduler) RunDue(now time.Time) {
+	jobs, err := db.GetDueReportJobs(now)
+	if err != nil {
+		log.Printf("Error loading due report jobs: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		s.Run(job)
+	}
+}
+
+// Run builds and delivers a report now, records the run in the job's history
+// and schedules the job's next run.
+func (s *ReportScheduler) Run(job models.ReportJob) models.ReportRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	run := models.ReportRun{JobID: job.ID, StartedAt: time.Now().UTC(), Status: db.ReportRunSucceeded}
+	articles, err := s.runReport(ctx, job, run.StartedAt)
+	run.FinishedAt = time.Now().UTC()
+	run.Articles = articles
+	if err != nil {
+		run.Status = db.ReportRunFailed
+		run.Error = err.Error()
+		log.Printf("Error running report %q: %v", job.Name, err)
+	}
+
+	next, err := NextRun(job.Schedule, run.StartedAt)
+	if err != nil {
+		// The schedule was validated when the job was saved.
+		next = run.StartedAt.Add(24 * time.Hour)
+	}
+	updated, err := db.RecordReportRun(run, next)
+	if err != nil {
+		log.Printf("Error recording run of report %q: %v", job.Name, err)
+		return run
+	}
+	s.alert(ctx, job, updated, run)
+	return run
+}
+
+func (s *ReportScheduler) runReport(ctx context.Context, job models.ReportJob, now time.Time) (int, error) {
+	since := now.Add(-reportFirstPeriod)
+	if job.LastSuccessAt != nil {
+		since = *job.LastSuccessAt
+	}
+	articles, err := db.QueryArticles(db.ReportFilter(job, since, now))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query articles: %v", err)
+	}
+	report := Report{Job: job, Name: job.Name, Since: since, Until: now, Articles: articles}
+	return len(articles), s.deliver(ctx, report)
+}
+
+func (s *ReportScheduler) deliver(ctx context.Context, report Report) error {
+	job := report.Job
+	if job.TemplateID != 0 {
+		return s.deliverTemplate(ctx, report)
+	}
+	if job.Channel == "slack" {
+		return postWebhookJSON(ctx, job.Target, map[string]string{"text": SlackReportText(report)})
+	}
+	body, contentType, ext, err := RenderReport(report, job.Format)
+	if err != nil {
+		return err
+	}
+	switch job.Channel {
+	case "webhook":
+		return sendWebhook(ctx, job.Target, contentType, body)
+	case "email":
+		if s.Mailer == nil {
+			return fmt.Errorf("email delivery is not configured")
+		}
+		to, err := mail.ParseAddressList(job.Target)
+		if err != nil {
+			return fmt.Errorf("invalid recipients: %v", err)
+		}
+		msg := Email{To: to, Subject: fmt.Sprintf("%s: %d articles", job.Name, len(report.Articles))}
+		switch job.Format {
+		case "html":
+			msg.HTML = string(body)
+		case "markdown":
+			msg.Text = string(body)
+		default:
+			msg.Text = fmt.Sprintf("%d articles ingested between %s and %s are attached.",
+				len(report.Articles), report.Since.Format(time.RFC1123), report.Until.Format(time.RFC1123))
+			msg.Attachment = &Attachment{Name: reportFilename(report) + ext, ContentType: contentType, Data: body}
+		}
+		return s.Mailer.Send(msg)
+	case "s3":
+		if s.S3 == nil {
+			return fmt.Errorf("S3 delivery is not configured")
+		}
+		bucket, prefix, err := parseS3Target(job.Target)
+		if err != nil {
+			return err
+		}
+		return s.S3.Put(ctx, bucket, prefix+reportFilename(report)+ext, contentType, body)
+	}
+	return fmt.Errorf("unknown channel %q", job.Channel)
+}
+
+// deliverTemplate delivers a report rendered with the job's message template,
+// which is of the kind of the job's channel.
+func (s *ReportScheduler) deliverTemplate(ctx context.Context, report Report) error {
+	job := report.Job
+	tmpl, err := db.GetMessageTemplate(job.TemplateID)
+	if err != nil {
+		return fmt.Errorf("failed to load message template %d: %v", job.TemplateID, err)
+	}
+	if tmpl.Kind != job.Channel {
+		return fmt.Errorf("message template %q is for %s, not %s", tmpl.Name, tmpl.Kind, job.Channel)
+	}
+	msg, err := RenderMessage(tmpl, MessageData{Type: "digest", Name: report.Name, Since: report.Since, Until: report.Until, Articles: report.Articles})
+	if err != nil {
+		return fmt.Errorf("failed to render message template %q: %v", tmpl.Name, err)
+	}
+	if job.Channel == "email" {
+		if s.Mailer == nil {
+			return fmt.Errorf("email delivery is not configured")
+		}
+		to, err := mail.ParseAddressList(job.Target)
+		if err != nil {
+			return fmt.Errorf("invalid recipients: %v", err)
+		}
+		return s.Mailer.Send(Email{To: to, Subject: msg.Subject, HTML: msg.Body})
+	}
+	return sendWebhook(ctx, job.Target, msg.ContentType, []byte(msg.Body))
+}
+
+// alert notifies AlertURL when a job fails after succeeding, and when it
+// succeeds again.
+func (s *ReportScheduler) alert(ctx context.Context, before, after models.ReportJob, run models.ReportRun) {
+	if s.AlertURL == "" {
+		return
+	}
+	var text string
+	switch {
+	case run.Status == db.ReportRunFailed && after.ConsecutiveFailures == 1:
+		text = fmt.Sprintf("Report %q failed: %s", after.Name, run.Error)
+	case run.Status == db.ReportRunSucceeded && before.ConsecutiveFailures > 0:
+		text = fmt.Sprintf("Report %q was delivered again after %d failed runs", after.Name, before.ConsecutiveFailures)
+	default:
+		return
+	}
+	payload := map[string]interface{}{"text": text, "job": after, "run": run}
+	if err := deliverWebhookJSON(ctx, s.Queue, s.AlertURL, payload); err != nil {
+		log.Printf("Error sending report alert: %v", err)
+	}
+}
+
+// reportFilename names a report file after its job and the end of its period.
+func reportFilename(report Report) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(report.Name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else if b.Len() > 0 && !strings.HasSuffix(b.String(), "-") {
+			b.WriteByte('-')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-") + "-" + report.Until.UTC().Format("20060102T150405Z")
+}
+
+var reportHTML = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{.Name}}</title></head>
+<body style="font-family: sans-serif;">
+<h1>{{.Name}}</h1>
+<p>{{len .Articles}} articles ingested between {{.Since.Format "2 Jan 2006 15:04 MST"}} and {{.Until.Format "2 Jan 2006 15:04 MST"}}.</p>
+<ul>
+{{- range .Articles}}
+<li><a href="{{.URL}}">{{.Title}}</a> &middot; severity {{.Severity}} &middot; {{.Category}}{{if .Description}}<br>{{.Description}}{{end}}</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// RenderReport renders a report in a format and returns it with its content
+// type and file extension.
+func RenderReport(report Report, format string) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "json":
+		if err := json.NewEncoder(&buf).Encode(report); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "application/json", ".json", nil
+	case "csv":
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"Title", "URL", "SourceURL", "PublishedAt", "Severity", "Category", "Tags"})
+		for _, a := range report.Articles {
+			w.Write([]string{a.Title, a.URL, a.SourceURL, a.PublishedAt.Format(time.RFC3339), strconv.Itoa(a.Severity), a.Category, strings.Join(a.Tags, ";")})
+		}
+		w.Flush()
+		return buf.Bytes(), "text/csv", ".csv", w.Error()
+	case "markdown":
+		fmt.Fprintf(&buf, "# %s\n\n%d articles ingested between %s and %s.\n\n", report.Name, len(report.Articles),
+			report.Since.Format(time.RFC1123), report.Until.Format(time.RFC1123))
+		for _, a := range report.Articles {
+			fmt.Fprintf(&buf, "- [%s](%s) · severity %d · %s\n", markdownEscaper.Replace(a.Title), a.URL, a.Severity, a.Category)
+		}
+		return buf.Bytes(), "text/markdown; charset=utf-8", ".md", nil
+	case "html":
+		if err := reportHTML.Execute(&buf, report); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), "text/html; charset=utf-8", ".html", nil
+	}
+	return nil, "", "", fmt.Errorf("unknown format %q", format)
+}
+
+var markdownEscaper = strings.NewReplacer("[", `\[`, "]", `\]`)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackReportText renders a report as a Slack message.
+func SlackReportText(report Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*: %d new articles", slackEscaper.Replace(report.Name), len(report.Articles))
+	for _, a := range report.Articles {
+		fmt.Fprintf(&b, "\n• <%s|%s> (severity %d)", a.URL, slackEscaper.Replace(strings.ReplaceAll(a.Title, "|", "-")), a.Severity)
+	}
+	return b.String()
+}
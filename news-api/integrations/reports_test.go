@@ -0,0 +1,231 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReport() Report {
+	until := time.Date(2024, 4, 9, 8, 0, 0, 0, time.UTC)
+	return Report{
+		Name:  "Daily digest",
+		Since: until.Add(-24 * time.Hour),
+		Until: until,
+		Articles: []models.NewsArticle{
+			{Title: "Zero-day [in the wild]", URL: "https://example.com/a", Severity: 90, Category: "Cybersecurity", Tags: []string{"CVE-2024-1234"}},
+			{Title: "Patch <released>", URL: "https://example.com/b", Severity: 40, Category: "Cybersecurity"},
+		},
+	}
+}
+
+func TestRenderReport(t *testing.T) {
+	report := testReport()
+	body, contentType, ext, err := RenderReport(report, "markdown")
+	require.NoError(t, err)
+	assert.Equal(t, ".md", ext)
+	assert.Equal(t, "text/markdown; charset=utf-8", contentType)
+	assert.Contains(t, string(body), `- [Zero-day \[in the wild\]](https://example.com/a) · severity 90`)
+
+	body, _, _, err = RenderReport(report, "html")
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Patch &lt;released&gt;")
+
+	body, _, ext, err = RenderReport(report, "csv")
+	require.NoError(t, err)
+	assert.Equal(t, ".csv", ext)
+	assert.Contains(t, string(body), "Zero-day [in the wild],https://example.com/a,,0001-01-01T00:00:00Z,90,Cybersecurity,CVE-2024-1234\n")
+
+	body, _, _, err = RenderReport(report, "json")
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Len(t, decoded.Articles, 2)
+
+	_, _, _, err = RenderReport(report, "pdf")
+	assert.Error(t, err)
+
+	assert.Equal(t, "*Daily digest*: 2 new articles\n• <https://example.com/a|Zero-day [in the wild]> (severity 90)\n• <https://example.com/b|Patch &lt;released&gt;> (severity 40)", SlackReportText(report))
+	assert.Equal(t, "daily-digest-20240409T080000Z", reportFilename(report))
+}
+
+func TestComposeEmail(t *testing.T) {
+	m := &Mailer{From: mail.Address{Name: "Threatfeed", Address: "reports@example.com"}}
+	to, err := mail.ParseAddressList("soc@example.com, Analyst <analyst@example.com>")
+	require.NoError(t, err)
+	data, err := m.compose(Email{
+		To: to, Subject: "Daily digest: 2 articles", Text: "Attached.",
+		Attachment: &Attachment{Name: "digest.csv", ContentType: "text/csv", Data: []byte("Title,URL\n")},
+	}, time.Now())
+	require.NoError(t, err)
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	require.NoError(t, err)
+	assert.Equal(t, `"Threatfeed" <reports@example.com>`, msg.Header.Get("From"))
+	assert.Equal(t, "Daily digest: 2 articles", msg.Header.Get("Subject"))
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/mixed", mediaType)
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		parts = append(parts, part.Header.Get("Content-Type")+" "+part.FileName())
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8 ", "text/csv digest.csv"}, parts)
+}
+
+func TestS3Put(t *testing.T) {
+	var got *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	u := &S3Uploader{Region: "eu-west-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", Endpoint: server.URL}
+	bucket, prefix, err := parseS3Target("s3://reports/threatfeed/daily/")
+	require.NoError(t, err)
+	require.NoError(t, u.Put(context.Background(), bucket, prefix+"digest 1.md", "text/markdown", []byte("# Digest")))
+	assert.Equal(t, "PUT", got.Method)
+	assert.Equal(t, "/reports/threatfeed/daily/digest%201.md", got.URL.EscapedPath())
+	assert.Equal(t, "# Digest", body)
+	auth := got.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), auth)
+	assert.Contains(t, auth, "/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature=")
+	assert.Equal(t, sha256Hex([]byte("# Digest")), got.Header.Get("X-Amz-Content-Sha256"))
+
+	for _, target := range []string{"https://bucket", "s3://", "s3:///prefix"} {
+		_, _, err := parseS3Target(target)
+		assert.Error(t, err, target)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		assert.Contains(t, r.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=")
+		switch {
+		case r.Method == "GET" && r.URL.Query().Get("list-type") == "2":
+			if r.URL.Query().Get("continuation-token") == "" {
+				w.Write([]byte(`<ListBucketResult><Contents><Key>db/generations/a/wal/1</Key></Contents><IsTruncated>true</IsTruncated><NextContinuationToken>next</NextContinuationToken></ListBucketResult>`))
+				return
+			}
+			w.Write([]byte(`<ListBucketResult><Contents><Key>db/generations/a/wal/2</Key></Contents><IsTruncated>false</IsTruncated></ListBucketResult>`))
+		case r.Method == "GET":
+			w.Write([]byte("segment"))
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(&S3Uploader{Region: "eu-west-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", Endpoint: server.URL}, "s3://backups/db")
+	require.NoError(t, err)
+	keys, err := store.List(context.Background(), "generations/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"generations/a/wal/1", "generations/a/wal/2"}, keys)
+	body, err := store.Get(context.Background(), keys[0])
+	require.NoError(t, err)
+	assert.Equal(t, "segment", string(body))
+	require.NoError(t, store.Delete(context.Background(), keys[1]))
+	assert.Equal(t, []string{
+		"GET /backups?list-type=2&prefix=db%2Fgenerations%2F",
+		"GET /backups?continuation-token=next&list-type=2&prefix=db%2Fgenerations%2F",
+		"GET /backups/db/generations/a/wal/1",
+		"DELETE /backups/db/generations/a/wal/2",
+	}, requests)
+}
+
+func TestReportSchedulerRun(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	// The test servers listen on loopback.
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+	require.NoError(t, db.ClearAllArticlesForTest())
+	require.NoError(t, db.InsertArticle(models.NewsArticle{Title: "Ransomware hits hospital", URL: "https://example.com/r", Category: "Cybersecurity", PublishedAt: time.Now().Add(-time.Hour)}))
+	// Report windows are compared at second precision.
+	time.Sleep(time.Second)
+
+	var mu sync.Mutex
+	var delivered []string
+	failing := true
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		delivered = append(delivered, string(data))
+	}))
+	defer target.Close()
+	var alerts []string
+	alertServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&alert)
+		alerts = append(alerts, alert.Text)
+	}))
+	defer alertServer.Close()
+
+	s := &ReportScheduler{AlertURL: alertServer.URL}
+	job, err := db.CreateReportJob(models.ReportJob{
+		Name: "Hourly", MaxArticles: 10, Format: "markdown", Schedule: "@hourly", Channel: "webhook", Target: target.URL, Enabled: true, NextRunAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		s.RunDue(time.Now())
+		job, err = db.GetReportJob(job.ID)
+		require.NoError(t, err)
+		job.NextRunAt = time.Now().Add(-time.Minute)
+		require.NoError(t, db.UpdateReportJob(job))
+	}
+	assert.Equal(t, 2, job.ConsecutiveFailures)
+	require.Len(t, alerts, 1, "only the first failure alerts")
+	assert.Contains(t, alerts[0], `Report "Hourly" failed`)
+
+	failing = false
+	run := s.Run(job)
+	assert.Equal(t, db.ReportRunSucceeded, run.Status)
+	assert.Equal(t, 1, run.Articles)
+	require.Len(t, delivered, 1)
+	assert.Contains(t, delivered[0], "Ransomware hits hospital")
+	require.Len(t, alerts, 2)
+	assert.Contains(t, alerts[1], "after 2 failed runs")
+
+	job, err = db.GetReportJob(job.ID)
+	require.NoError(t, err)
+	assert.True(t, job.NextRunAt.After(time.Now()))
+	run = s.Run(job)
+	assert.Zero(t, run.Articles, "articles are only reported once")
+
+	assert.Error(t, s.ValidateTarget("email", "soc@example.com"), "email is not configured")
+	assert.Error(t, s.ValidateTarget("webhook", "ftp://example.com"))
+	assert.NoError(t, s.ValidateTarget("slack", "https://hooks.slack.com/services/T/B/X"))
+}
@@ -0,0 +1,284 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Uploader stores objects in Amazon S3 or a compatible service (MinIO,
+// Ceph, R2) with AWS Signature Version 4.
+type S3Uploader struct {
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+	// Endpoint overrides https://s3.<region>.amazonaws.com. Buckets are
+	// addressed in the path, which every compatible service supports.
+	Endpoint string
+}
+
+func (u *S3Uploader) endpoint() string {
+	if u.Endpoint != "" {
+		return strings.TrimRight(u.Endpoint, "/")
+	}
+	return "https://s3." + u.Region + ".amazonaws.com"
+}
+
+// S3Store keeps objects under an s3://bucket/prefix target, with keys
+// relative to the prefix. It is the S3 replica.Store of database replication.
+type S3Store struct {
+	uploader *S3Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3Store returns the store of an s3://bucket/prefix target.
+func NewS3Store(u *S3Uploader, target string) (*S3Store, error) {
+	bucket, prefix, err := parseS3Target(target)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{uploader: u, bucket: bucket, prefix: prefix}, nil
+}
+
+// Put stores an object.
+func (s *S3Store) Put(ctx context.Context, key string, body []byte) error {
+	return s.uploader.Put(ctx, s.bucket, s.prefix+key, "application/octet-stream", body)
+}
+
+// Get returns an object.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.uploader.Get(ctx, s.bucket, s.prefix+key)
+}
+
+// Delete removes an object.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	return s.uploader.Delete(ctx, s.bucket, s.prefix+key)
+}
+
+// List returns the keys starting with prefix, in lexical order.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	keys, err := s.uploader.List(ctx, s.bucket, s.prefix+prefix)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, s.prefix)
+	}
+	return keys, err
+}
+
+// parseS3Target splits an s3://bucket/prefix target into the bucket and the
+// key prefix, which ends with a slash unless empty.
+func parseS3Target(target string) (string, string, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("target must be an s3://bucket/prefix URL")
+	}
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+// s3Client has a longer timeout than httpClient, for the database snapshots
+// of replication.
+var s3Client = &http.Client{Timeout: 10 * time.Minute}
+
+// Put uploads an object.
+func (u *S3Uploader) Put(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	_, err := u.do(ctx, "PUT", bucket, key, nil, contentType, body)
+	return err
+}
+
+// Get downloads an object.
+func (u *S3Uploader) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	return u.do(ctx, "GET", bucket, key, nil, "", nil)
+}
+
+// Delete removes an object. Removing a missing object succeeds.
+func (u *S3Uploader) Delete(ctx context.Context, bucket, key string) error {
+	_, err := u.do(ctx, "DELETE", bucket, key, nil, "", nil)
+	return err
+}
+
+// List returns the keys of the objects starting with prefix, in lexical order.
+func (u *S3Uploader) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := u.do(ctx, "GET", bucket, "", query, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("invalid S3 listing: %v", err)
+		}
+		for _, object := range result.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// do sends a signed request for an object, or for the bucket if key is empty,
+// and returns the response body. Responses other than 2xx are errors.
+func (u *S3Uploader) do(ctx context.Context, method, bucket, key string, query url.Values, contentType string, body []byte) ([]byte, error) {
+	base, err := url.Parse(u.endpoint())
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %v", err)
+	}
+	path := strings.TrimRight(base.Path, "/") + "/" + awsURIEscape(bucket)
+	if key != "" {
+		path += "/" + awsURIEscape(key)
+	}
+	rawQuery := canonicalQuery(query)
+	headers := u.sign(method, base.Host, path, rawQuery, contentType, body, time.Now().UTC())
+	target := base.Scheme + "://" + base.Host + path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := s3Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %v", target, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %v", target, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > 1024 {
+			respBody = respBody[:1024]
+		}
+		return nil, fmt.Errorf("request to %s returned %s: %s", target, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return respBody, nil
+}
+
+// canonicalQuery encodes a query string as Signature Version 4 requires:
+// sorted by name, with every byte but the unreserved characters escaped.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, awsQueryEscape(name)+"="+awsQueryEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// sign returns the headers authenticating a request with Signature Version 4.
+func (u *S3Uploader) sign(method, host, path, rawQuery, contentType string, body []byte, now time.Time) map[string]string {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	signed := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType != "" {
+		signed["content-type"] = contentType
+	}
+	if u.SessionToken != "" {
+		signed["x-amz-security-token"] = u.SessionToken
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(signed[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{method, path, rawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+
+	scope := day + "/" + u.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	key := []byte("AWS4" + u.SecretAccessKey)
+	for _, part := range []string{day, u.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	headers := map[string]string{
+		"Authorization": fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", u.AccessKeyID, scope, signedHeaders, signature),
+	}
+	for name, value := range signed {
+		if name != "host" && name != "content-type" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// awsURIEscape percent-encodes every byte of an object key but the unreserved
+// characters and slashes, as Signature Version 4 requires.
+func awsURIEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsQueryEscape percent-encodes a query parameter like awsURIEscape, slashes
+// included.
+func awsQueryEscape(s string) string {
+	return strings.ReplaceAll(awsURIEscape(s), "/", "%2F")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,98 @@
+package integrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// SavedSearchSink is the delivery queue sink matching queued articles
+// against the saved searches.
+const SavedSearchSink = "saved-searches"
+
+// SavedSearchNotifier POSTs newly cached articles to the notify URL of every
+// saved search they match.
+type SavedSearchNotifier struct {
+	// Queue holds the articles until they are matched and delivers the
+	// notifications. Both happen right away when it is nil.
+	Queue *DeliveryQueue
+}
+
+// SavedSearchNotification is the JSON body sent to a saved search's notify
+// URL, unless the search has a message template.
+type SavedSearchNotification struct {
+	SavedSearch models.SavedSearch `json:"savedSearch"`
+	Article     models.NewsArticle `json:"article"`
+}
+
+// Start registers the sink matching the queued articles with Queue.
+func (n *SavedSearchNotifier) Start() {
+	if n.Queue != nil {
+		n.Queue.RegisterArticleSink(SavedSearchSink, n.HandleArticle)
+	}
+}
+
+// Enqueue queues an article to be matched against the saved searches. It is
+// meant to be registered as a db.ArticleHook.
+func (n *SavedSearchNotifier) Enqueue(article models.NewsArticle) {
+	if n.Queue == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := n.HandleArticle(ctx, article); err != nil {
+			log.Printf("Error notifying saved searches for %s: %v", article.URL, err)
+		}
+		return
+	}
+	if err := n.Queue.EnqueueArticle(SavedSearchSink, article); err != nil {
+		log.Printf("Error queueing %s for saved search notifications: %v", article.URL, err)
+	}
+}
+
+// HandleArticle notifies every saved search the article matches. A failing
+// endpoint is logged and does not stop delivery to the others.
+func (n *SavedSearchNotifier) HandleArticle(ctx context.Context, article models.NewsArticle) error {
+	searches, err := db.GetNotifyingSavedSearches()
+	if err != nil {
+		return fmt.Errorf("failed to load saved searches: %v", err)
+	}
+	templates := map[int64]models.MessageTemplate{}
+	for _, search := range searches {
+		if !db.SavedSearchFilter(search).Matches(article) {
+			continue
+		}
+		var err error
+		if search.TemplateID != 0 {
+			err = n.notifyTemplate(ctx, search, article, templates)
+		} else {
+			payload := SavedSearchNotification{SavedSearch: search, Article: article}
+			err = deliverWebhookJSON(ctx, n.Queue, search.NotifyURL, payload)
+		}
+		if err != nil {
+			log.Printf("Error notifying saved search %d: %v", search.ID, err)
+		}
+	}
+	return nil
+}
+
+// notifyTemplate POSTs the alert rendered with the saved search's message
+// template. templates caches the templates loaded for one article.
+func (n *SavedSearchNotifier) notifyTemplate(ctx context.Context, search models.SavedSearch, article models.NewsArticle, templates map[int64]models.MessageTemplate) error {
+	tmpl, ok := templates[search.TemplateID]
+	if !ok {
+		var err error
+		if tmpl, err = db.GetMessageTemplate(search.TemplateID); err != nil {
+			return fmt.Errorf("failed to load message template %d: %v", search.TemplateID, err)
+		}
+		templates[search.TemplateID] = tmpl
+	}
+	now := time.Now().UTC()
+	msg, err := RenderMessage(tmpl, MessageData{Type: "alert", Name: search.Name, Since: now, Until: now, Articles: []models.NewsArticle{article}})
+	if err != nil {
+		return fmt.Errorf("failed to render message template %q: %v", tmpl.Name, err)
+	}
+	return deliverWebhook(ctx, n.Queue, search.NotifyURL, msg.ContentType, []byte(msg.Body))
+}
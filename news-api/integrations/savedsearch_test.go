@@ -0,0 +1,86 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"news-api/db"
+	"news-api/models"
+	"news-api/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavedSearchNotifier(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	// The test servers listen on loopback.
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+
+	var received []SavedSearchNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n SavedSearchNotification
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&n))
+		received = append(received, n)
+	}))
+	defer server.Close()
+
+	user, err := db.CreateUser("analyst", "correct horse")
+	require.NoError(t, err)
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ivanti", Search: "ivanti", NotifyURL: server.URL})
+	require.NoError(t, err)
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Silent", Search: "ivanti"})
+	require.NoError(t, err)
+
+	n := &SavedSearchNotifier{}
+	require.NoError(t, n.HandleArticle(context.Background(), models.NewsArticle{Title: "Ivanti VPN exploited", URL: "u1"}))
+	require.NoError(t, n.HandleArticle(context.Background(), models.NewsArticle{Title: "Unrelated", URL: "u2"}))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, "Ivanti", received[0].SavedSearch.Name)
+	assert.Equal(t, "u1", received[0].Article.URL)
+}
+
+func TestSavedSearchNotificationsAreSigned(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	// The test servers listen on loopback.
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+	WebhookSecret = []byte("0123456789abcdef0123456789abcdef")
+	defer func() { WebhookSecret = nil }()
+
+	verifier := webhook.NewVerifier(string(WebhookSecret))
+	var verified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.VerifyRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		verified++
+	}))
+	defer server.Close()
+
+	user, err := db.CreateUser("signed", "correct horse")
+	require.NoError(t, err)
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ivanti", Search: "ivanti", NotifyURL: server.URL})
+	require.NoError(t, err)
+	n := &SavedSearchNotifier{}
+	require.NoError(t, n.HandleArticle(context.Background(), models.NewsArticle{Title: "Ivanti VPN exploited", URL: "u1"}))
+	assert.Equal(t, 1, verified)
+}
+
+func TestSavedSearchWebhooksRefusePrivateAddresses(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	err := sendWebhook(context.Background(), server.URL, "application/json", []byte("{}"))
+	assert.ErrorContains(t, err, "non-public address")
+	assert.Zero(t, received)
+}
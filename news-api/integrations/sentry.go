@@ -0,0 +1,121 @@
+package integrations
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Sentry reports recovered panics to Sentry, or a compatible service such as
+// GlitchTip, through the store API.
+type Sentry struct {
+	Environment string
+	Release     string
+
+	storeURL string
+	key      string
+}
+
+type sentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Exception   sentryExceptions       `json:"exception"`
+	Request     sentryRequest          `json:"request"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sentryRequest deliberately leaves out headers and the query string, which
+// may carry credentials.
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// NewSentry returns a reporter for the project of a Sentry DSN, e.g.
+// https://<key>@o123.ingest.sentry.io/456.
+func NewSentry(dsn string) (*Sentry, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN: %v", err)
+	}
+	if u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing key or host")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if slash < 0 || project == "" {
+		return nil, fmt.Errorf("invalid Sentry DSN: missing project ID")
+	}
+	return &Sentry{
+		storeURL: fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:slash], project),
+		key:      u.User.Username(),
+	}, nil
+}
+
+// ReportPanic sends a panic recovered while serving r in the background.
+func (s *Sentry) ReportPanic(r *http.Request, recovered interface{}, stack []byte) {
+	event := s.panicEvent(r, recovered, stack)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s.send(ctx, event); err != nil {
+			log.Printf("Error reporting panic to Sentry: %v", err)
+		}
+	}()
+}
+
+func (s *Sentry) panicEvent(r *http.Request, recovered interface{}, stack []byte) sentryEvent {
+	id := make([]byte, 16)
+	rand.Read(id)
+	host, _ := os.Hostname()
+
+	message := fmt.Sprint(recovered)
+	if err, ok := recovered.(error); ok {
+		message = err.Error()
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return sentryEvent{
+		EventID:     hex.EncodeToString(id),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+		Level:       "fatal",
+		Platform:    "go",
+		Logger:      "http",
+		ServerName:  host,
+		Environment: s.Environment,
+		Release:     s.Release,
+		Exception:   sentryExceptions{Values: []sentryException{{Type: "panic", Value: message}}},
+		Request:     sentryRequest{Method: r.Method, URL: scheme + "://" + r.Host + r.URL.Path},
+		Extra:       map[string]interface{}{"stack": string(stack)},
+	}
+}
+
+func (s *Sentry) send(ctx context.Context, event sentryEvent) error {
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=threatfeed/1.0, sentry_key=%s", s.key)
+	return postJSON(ctx, s.storeURL, map[string]string{"X-Sentry-Auth": auth}, event, nil)
+}
@@ -0,0 +1,61 @@
+package integrations
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSentry(t *testing.T) {
+	s, err := NewSentry("https://abc123@o1.ingest.sentry.io/456")
+	require.NoError(t, err)
+	assert.Equal(t, "https://o1.ingest.sentry.io/api/456/store/", s.storeURL)
+	assert.Equal(t, "abc123", s.key)
+
+	s, err = NewSentry("http://key@glitchtip.internal/sentry/7/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://glitchtip.internal/sentry/api/7/store/", s.storeURL)
+
+	for _, dsn := range []string{"https://o1.ingest.sentry.io/456", "https://key@o1.ingest.sentry.io/", "::"} {
+		_, err := NewSentry(dsn)
+		assert.Error(t, err, dsn)
+	}
+}
+
+func TestSentryReportPanic(t *testing.T) {
+	events := make(chan sentryEvent, 1)
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/42/store/", r.URL.Path)
+		auth = r.Header.Get("X-Sentry-Auth")
+		var event sentryEvent
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		events <- event
+	}))
+	defer server.Close()
+
+	s, err := NewSentry("http://secret@" + server.Listener.Addr().String() + "/42")
+	require.NoError(t, err)
+	s.Environment = "production"
+
+	req := httptest.NewRequest(http.MethodGet, "http://api.example.com/news?api_key=hidden", nil)
+	s.ReportPanic(req, errors.New("nil map"), []byte("goroutine 1 [running]:"))
+
+	select {
+	case event := <-events:
+		assert.Contains(t, auth, "sentry_key=secret")
+		assert.Len(t, event.EventID, 32)
+		assert.Equal(t, "production", event.Environment)
+		assert.Equal(t, []sentryException{{Type: "panic", Value: "nil map"}}, event.Exception.Values)
+		assert.Equal(t, "http://api.example.com/news", event.Request.URL, "the query string is not sent")
+		assert.Equal(t, "goroutine 1 [running]:", event.Extra["stack"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panic was not reported")
+	}
+}
@@ -0,0 +1,159 @@
+package integrations
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"news-api/extract"
+	"news-api/models"
+)
+
+// ArticleEvent is the document forwarded to SIEM sinks for each ingested article.
+type ArticleEvent struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	ImageURL    string        `json:"imageUrl,omitempty"`
+	URL         string        `json:"url"`
+	SourceURL   string        `json:"sourceUrl"`
+	Category    string        `json:"category"`
+	Rank        int           `json:"rank"`
+	PublishedAt time.Time     `json:"publishedAt"`
+	IOCs        []extract.IOC `json:"iocs"`
+}
+
+// NewArticleEvent builds the SIEM document for an article, including the
+// indicators extracted from its title and description.
+func NewArticleEvent(article models.NewsArticle) ArticleEvent {
+	iocs := extract.IOCs(article.Title + "\n" + article.Description)
+	if iocs == nil {
+		iocs = []extract.IOC{}
+	}
+	return ArticleEvent{
+		Title:       article.Title,
+		Description: article.Description,
+		ImageURL:    article.ImageURL,
+		URL:         article.URL,
+		SourceURL:   article.SourceURL,
+		Category:    article.Category,
+		Rank:        article.Rank,
+		PublishedAt: article.PublishedAt,
+		IOCs:        iocs,
+	}
+}
+
+// SplunkHEC sends events to a Splunk HTTP Event Collector.
+type SplunkHEC struct {
+	URL        string
+	Token      string
+	Index      string
+	SourceType string
+}
+
+type splunkEvent struct {
+	Time       float64     `json:"time"`
+	Source     string      `json:"source"`
+	SourceType string      `json:"sourcetype"`
+	Index      string      `json:"index,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+// Send delivers a batch as concatenated HEC event objects.
+func (s *SplunkHEC) Send(ctx context.Context, events []interface{}) error {
+	sourceType := s.SourceType
+	if sourceType == "" {
+		sourceType = "threatfeed:article"
+	}
+
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		ts := float64(time.Now().Unix())
+		if ae, ok := event.(ArticleEvent); ok && !ae.PublishedAt.IsZero() {
+			ts = float64(ae.PublishedAt.Unix())
+		}
+		if err := enc.Encode(splunkEvent{Time: ts, Source: "threatfeed", SourceType: sourceType, Index: s.Index, Event: event}); err != nil {
+			return fmt.Errorf("failed to encode HEC event: %v", err)
+		}
+	}
+
+	endpoint := strings.TrimRight(s.URL, "/")
+	if !strings.HasSuffix(endpoint, "/services/collector/event") {
+		endpoint += "/services/collector/event"
+	}
+	headers := map[string]string{"Authorization": "Splunk " + s.Token}
+	_, err := send(ctx, "POST", endpoint, "application/json", headers, body.Bytes())
+	return err
+}
+
+// Elasticsearch indexes events through the bulk API. Article events use a hash
+// of the URL as document ID so retried batches do not create duplicates.
+type Elasticsearch struct {
+	URL      string
+	Index    string
+	APIKey   string
+	Username string
+	Password string
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// Send delivers a batch as an NDJSON bulk request.
+func (e *Elasticsearch) Send(ctx context.Context, events []interface{}) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		action := map[string]string{"_index": e.Index}
+		if ae, ok := event.(ArticleEvent); ok {
+			sum := sha256.Sum256([]byte(ae.URL))
+			action["_id"] = hex.EncodeToString(sum[:])
+		}
+		if err := enc.Encode(map[string]interface{}{"index": action}); err != nil {
+			return fmt.Errorf("failed to encode bulk action: %v", err)
+		}
+		if err := enc.Encode(event); err != nil {
+			return fmt.Errorf("failed to encode bulk document: %v", err)
+		}
+	}
+
+	headers := map[string]string{}
+	if e.APIKey != "" {
+		headers["Authorization"] = "ApiKey " + e.APIKey
+	} else if e.Username != "" {
+		headers["Authorization"] = basicAuth(e.Username, e.Password)
+	}
+
+	respBody, err := send(ctx, "POST", strings.TrimRight(e.URL, "/")+"/_bulk", "application/x-ndjson", headers, body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %v", err)
+	}
+	if resp.Errors {
+		for _, item := range resp.Items {
+			for _, result := range item {
+				if result.Status >= 300 {
+					return fmt.Errorf("bulk indexing failed: %s", result.Error.Reason)
+				}
+			}
+		}
+		return fmt.Errorf("bulk indexing reported errors")
+	}
+	return nil
+}
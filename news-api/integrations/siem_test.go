@@ -0,0 +1,82 @@
+package integrations
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewArticleEventIncludesIOCs(t *testing.T) {
+	event := NewArticleEvent(models.NewsArticle{
+		Title:       "Attackers exploit CVE-2024-3400",
+		Description: "C2 at 203.0.113[.]7",
+		URL:         "https://example.com/a",
+	})
+	assert.Len(t, event.IOCs, 2)
+	assert.Equal(t, "cve", event.IOCs[0].Type)
+	assert.Equal(t, "203.0.113.7", event.IOCs[1].Value)
+}
+
+func TestSplunkHECSend(t *testing.T) {
+	var lines []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/services/collector/event", r.URL.Path)
+		assert.Equal(t, "Splunk hec-token", r.Header.Get("Authorization"))
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		w.Write([]byte(`{"text":"Success","code":0}`))
+	}))
+	defer server.Close()
+
+	hec := &SplunkHEC{URL: server.URL, Token: "hec-token", Index: "threat"}
+	events := []interface{}{
+		NewArticleEvent(models.NewsArticle{Title: "a", URL: "u1", PublishedAt: time.Unix(1700000000, 0)}),
+		NewArticleEvent(models.NewsArticle{Title: "b", URL: "u2"}),
+	}
+	require.NoError(t, hec.Send(context.Background(), events))
+	require.Len(t, lines, 2)
+
+	var first splunkEvent
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, float64(1700000000), first.Time)
+	assert.Equal(t, "threat", first.Index)
+	assert.Equal(t, "threatfeed:article", first.SourceType)
+}
+
+func TestElasticsearchSend(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		assert.Equal(t, "ApiKey es-key", r.Header.Get("Authorization"))
+		raw, _ := bufio.NewReader(r.Body).ReadString(0)
+		body = raw
+		if strings.Contains(body, `"title":"bad"`) {
+			w.Write([]byte(`{"errors":true,"items":[{"index":{"status":400,"error":{"reason":"mapper_parsing_exception"}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer server.Close()
+
+	es := &Elasticsearch{URL: server.URL, Index: "threatfeed", APIKey: "es-key"}
+	require.NoError(t, es.Send(context.Background(), []interface{}{NewArticleEvent(models.NewsArticle{Title: "ok", URL: "u1"})}))
+	assert.Contains(t, body, `"_index":"threatfeed"`)
+	assert.Contains(t, body, `"_id":"`)
+
+	err := es.Send(context.Background(), []interface{}{NewArticleEvent(models.NewsArticle{Title: "bad", URL: "u2"})})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "mapper_parsing_exception")
+}
@@ -0,0 +1,180 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+)
+
+// levelSinkSuffix names the delivery queue sink carrying the threat level
+// changes of a Sink, next to the one carrying its articles.
+const levelSinkSuffix = ".level"
+
+// Sink is an output that receives ingested articles and threat level changes,
+// such as a SIEM, a message stream or a chat webhook. Sinks added to the
+// delivery queue share its batching, retries and dead letters.
+type Sink interface {
+	// Name identifies the sink in the delivery queue; it must be unique.
+	Name() string
+	// OnArticle receives the events of newly ingested articles, up to the
+	// sink's batch size at once. On error the whole batch is retried.
+	OnArticle(ctx context.Context, events []ArticleEvent) error
+	// OnThreatLevelChange receives threat level changes, oldest first.
+	OnThreatLevelChange(ctx context.Context, changes []LevelChangeEvent) error
+}
+
+// ArticleMatcher is implemented by sinks taking only some articles, such as
+// the high-rank ones. The other articles are not queued for them.
+type ArticleMatcher interface {
+	MatchArticle(article models.NewsArticle) bool
+}
+
+// LevelChangeEvent is the document delivered to sinks when the threat level
+// changes.
+type LevelChangeEvent struct {
+	Previous   string         `json:"previous"`
+	Level      string         `json:"level"`
+	Score      db.ThreatScore `json:"score"`
+	ObservedAt time.Time      `json:"observedAt"`
+}
+
+// AddSink registers a sink with the queue and dispatches every published
+// article and level change to it, in batches of up to batchSize.
+func (q *DeliveryQueue) AddSink(sink Sink, batchSize int) {
+	name := sink.Name()
+	q.Register(name, batchSize, func(ctx context.Context, deliveries []models.Delivery) error {
+		events := make([]ArticleEvent, 0, len(deliveries))
+		for _, d := range deliveries {
+			var event ArticleEvent
+			if err := json.Unmarshal([]byte(d.Payload), &event); err != nil {
+				return fmt.Errorf("failed to decode queued event %d: %v", d.ID, err)
+			}
+			events = append(events, event)
+		}
+		return sink.OnArticle(ctx, events)
+	})
+	q.Register(name+levelSinkSuffix, batchSize, func(ctx context.Context, deliveries []models.Delivery) error {
+		changes := make([]LevelChangeEvent, 0, len(deliveries))
+		for _, d := range deliveries {
+			var change LevelChangeEvent
+			if err := json.Unmarshal([]byte(d.Payload), &change); err != nil {
+				return fmt.Errorf("failed to decode queued level change %d: %v", d.ID, err)
+			}
+			changes = append(changes, change)
+		}
+		return sink.OnThreatLevelChange(ctx, changes)
+	})
+
+	_, articlesOnly := sink.(eventSink)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.outputs = append(q.outputs, sinkOutput{name: name, sink: sink, levelChanges: !articlesOnly})
+}
+
+type sinkOutput struct {
+	name string
+	sink Sink
+	// levelChanges is false for sinks ignoring threat level changes, which
+	// are then not queued for them.
+	levelChanges bool
+}
+
+// Sinks returns the names of the sinks added with AddSink.
+func (q *DeliveryQueue) Sinks() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	names := make([]string, len(q.outputs))
+	for i, output := range q.outputs {
+		names[i] = output.name
+	}
+	return names
+}
+
+// PublishArticle queues an article for every sink. It is meant to be
+// registered as an article hook.
+func (q *DeliveryQueue) PublishArticle(article models.NewsArticle) {
+	event := NewArticleEvent(article)
+	q.mu.Lock()
+	outputs := q.outputs
+	q.mu.Unlock()
+	for _, output := range outputs {
+		if matcher, ok := output.sink.(ArticleMatcher); ok && !matcher.MatchArticle(article) {
+			continue
+		}
+		if err := q.EnqueueJSON(output.name, "", event); err != nil {
+			log.Printf("Error queueing %s for %s: %v", article.URL, output.name, err)
+		}
+	}
+}
+
+// PublishLevelChange queues a threat level change for every sink. It is meant
+// to be registered as a listener of the threat level watcher. The first level
+// observed after a restart or a change of leader is not a change, so it is
+// not published.
+func (q *DeliveryQueue) PublishLevelChange(previous string, score db.ThreatScore) {
+	if previous == "" {
+		return
+	}
+	change := LevelChangeEvent{Previous: previous, Level: score.ThreatLevel, Score: score, ObservedAt: time.Now().UTC()}
+	q.mu.Lock()
+	outputs := q.outputs
+	q.mu.Unlock()
+	for _, output := range outputs {
+		if !output.levelChanges {
+			continue
+		}
+		if err := q.EnqueueJSON(output.name+levelSinkSuffix, "", change); err != nil {
+			log.Printf("Error queueing the %s threat level for %s: %v", score.ThreatLevel, output.name, err)
+		}
+	}
+}
+
+// eventSink adapts the Send method of the SIEM and stream forwarders, which
+// only forward articles, to a Sink.
+type eventSink struct {
+	name string
+	send func(ctx context.Context, events []interface{}) error
+}
+
+// ArticleSink returns a Sink forwarding article events with send and
+// ignoring threat level changes.
+func ArticleSink(name string, send func(ctx context.Context, events []interface{}) error) Sink {
+	return eventSink{name: name, send: send}
+}
+
+func (s eventSink) Name() string { return s.name }
+
+func (s eventSink) OnArticle(ctx context.Context, events []ArticleEvent) error {
+	batch := make([]interface{}, len(events))
+	for i, event := range events {
+		batch[i] = event
+	}
+	return s.send(ctx, batch)
+}
+
+func (s eventSink) OnThreatLevelChange(ctx context.Context, changes []LevelChangeEvent) error {
+	return nil
+}
+
+// WebhookEventSink POSTs batches of events to a URL as JSON, signed like the
+// other webhooks: {"articles": [...]} for articles and {"levelChanges": [...]}
+// for threat level changes. The URL is set by the operator, so unlike
+// user-configured webhooks it may point to an internal service.
+type WebhookEventSink struct {
+	URL string
+}
+
+func (s *WebhookEventSink) Name() string { return "events-webhook" }
+
+func (s *WebhookEventSink) OnArticle(ctx context.Context, events []ArticleEvent) error {
+	return postSignedJSON(ctx, httpClient, s.URL, map[string]interface{}{"articles": events})
+}
+
+func (s *WebhookEventSink) OnThreatLevelChange(ctx context.Context, changes []LevelChangeEvent) error {
+	return postSignedJSON(ctx, httpClient, s.URL, map[string]interface{}{"levelChanges": changes})
+}
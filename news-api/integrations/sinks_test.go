@@ -0,0 +1,63 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSinkDispatch(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	var bodies []map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(data, &body))
+		bodies = append(bodies, body)
+	}))
+	defer server.Close()
+
+	var forwarded []interface{}
+	q := NewDeliveryQueue()
+	q.AddSink(&WebhookEventSink{URL: server.URL}, 10)
+	q.AddSink(ArticleSink("siem", func(ctx context.Context, events []interface{}) error {
+		forwarded = append(forwarded, events...)
+		return nil
+	}), 10)
+	assert.Equal(t, []string{"events-webhook", "siem"}, q.Sinks())
+
+	q.PublishArticle(models.NewsArticle{Title: "Exploit for 203.0.113.7", URL: "https://example.com/a"})
+	q.PublishArticle(models.NewsArticle{Title: "Second", URL: "https://example.com/b"})
+	q.PublishLevelChange("Code Orange", db.ThreatScore{ThreatLevel: CodeRed, HighRankCount: 12})
+	pending, err := db.GetDeliveries(db.DeliveryFilter{Status: db.DeliveryPending})
+	require.NoError(t, err)
+	assert.Len(t, pending, 5, "level changes are not queued for sinks ignoring them")
+
+	q.Process(time.Now().Add(time.Second))
+	require.Len(t, forwarded, 2)
+	assert.Equal(t, "https://example.com/a", forwarded[0].(ArticleEvent).URL)
+	require.Len(t, bodies, 2)
+	var articles []ArticleEvent
+	require.NoError(t, json.Unmarshal(bodies[0]["articles"], &articles))
+	assert.Len(t, articles, 2, "articles are delivered in one batch")
+	var changes []LevelChangeEvent
+	require.NoError(t, json.Unmarshal(bodies[1]["levelChanges"], &changes))
+	require.Len(t, changes, 1)
+	assert.Equal(t, "Code Orange", changes[0].Previous)
+	assert.Equal(t, CodeRed, changes[0].Level)
+	assert.Equal(t, 12, changes[0].Score.HighRankCount)
+
+	pending, err = db.GetDeliveries(db.DeliveryFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
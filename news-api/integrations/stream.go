@@ -0,0 +1,204 @@
+package integrations
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KafkaRESTProducer publishes events to a Kafka topic through a Kafka REST
+// Proxy (v2 API), which avoids linking a native Kafka client into the binary.
+type KafkaRESTProducer struct {
+	ProxyURL string
+	Topic    string
+	Username string
+	Password string
+}
+
+type kafkaRecord struct {
+	Key   string      `json:"key,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Send publishes a batch of events as JSON records. Article events are keyed
+// by URL so consumers can compact or partition by article.
+func (k *KafkaRESTProducer) Send(ctx context.Context, events []interface{}) error {
+	records := make([]kafkaRecord, 0, len(events))
+	for _, event := range events {
+		record := kafkaRecord{Value: event}
+		if ae, ok := event.(ArticleEvent); ok {
+			record.Key = ae.URL
+		}
+		records = append(records, record)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to encode Kafka records: %v", err)
+	}
+
+	headers := map[string]string{"Accept": "application/vnd.kafka.v2+json"}
+	if k.Username != "" {
+		headers["Authorization"] = basicAuth(k.Username, k.Password)
+	}
+	endpoint := strings.TrimRight(k.ProxyURL, "/") + "/topics/" + url.PathEscape(k.Topic)
+	_, err = send(ctx, "POST", endpoint, "application/vnd.kafka.json.v2+json", headers, body)
+	return err
+}
+
+// NATSPublisher publishes events to a NATS subject using the core NATS text
+// protocol. The connection is opened lazily and re-established after errors.
+// URLs use the nats:// scheme (tls:// for TLS) and may carry user:pass or a
+// token as userinfo.
+type NATSPublisher struct {
+	URL     string
+	Subject string
+
+	mu   sync.Mutex
+	conn net.Conn
+	w    *bufio.Writer
+}
+
+type natsConnectOptions struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// Send publishes each event as a separate JSON message and flushes the batch.
+func (n *NATSPublisher) Send(ctx context.Context, events []interface{}) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.conn == nil {
+		if err := n.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		n.conn.SetWriteDeadline(deadline)
+	}
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode NATS message: %v", err)
+		}
+		fmt.Fprintf(n.w, "PUB %s %d\r\n", n.Subject, len(payload))
+		n.w.Write(payload)
+		n.w.WriteString("\r\n")
+	}
+	if err := n.w.Flush(); err != nil {
+		n.closeLocked()
+		return fmt.Errorf("failed to publish to NATS: %v", err)
+	}
+	return nil
+}
+
+// Close closes the NATS connection.
+func (n *NATSPublisher) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closeLocked()
+}
+
+func (n *NATSPublisher) closeLocked() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+		n.w = nil
+	}
+}
+
+func (n *NATSPublisher) connect(ctx context.Context) error {
+	u, err := url.Parse(n.URL)
+	if err != nil {
+		return fmt.Errorf("invalid NATS URL: %v", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if u.Scheme == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS at %s: %v", host, err)
+	}
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	info, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(info, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected NATS greeting %q: %v", strings.TrimSpace(info), err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	opts := natsConnectOptions{Name: "threatfeed", Lang: "go", Version: "1.0"}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			opts.User, opts.Pass = u.User.Username(), pass
+		} else {
+			opts.Token = u.User.Username()
+		}
+	}
+	connectJSON, _ := json.Marshal(opts)
+
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "CONNECT %s\r\n", connectJSON)
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to send NATS CONNECT: %v", err)
+	}
+
+	n.conn, n.w = conn, w
+	go n.readLoop(conn, r)
+	return nil
+}
+
+// readLoop answers server PINGs and logs protocol errors until the connection closes.
+func (n *NATSPublisher) readLoop(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			n.mu.Lock()
+			if n.conn == conn {
+				n.closeLocked()
+			}
+			n.mu.Unlock()
+			return
+		}
+		line = bytes.TrimSpace(line)
+		switch {
+		case bytes.Equal(line, []byte("PING")):
+			n.mu.Lock()
+			if n.conn == conn {
+				n.w.WriteString("PONG\r\n")
+				n.w.Flush()
+			}
+			n.mu.Unlock()
+		case bytes.HasPrefix(line, []byte("-ERR")):
+			log.Printf("NATS server error: %s", line)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package integrations
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKafkaRESTProducerSend(t *testing.T) {
+	var payload struct {
+		Records []struct {
+			Key   string       `json:"key"`
+			Value ArticleEvent `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/threatfeed.articles", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.json.v2+json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+	}))
+	defer server.Close()
+
+	k := &KafkaRESTProducer{ProxyURL: server.URL, Topic: "threatfeed.articles"}
+	event := NewArticleEvent(models.NewsArticle{Title: "t", URL: "https://example.com/a"})
+	require.NoError(t, k.Send(context.Background(), []interface{}{event}))
+
+	require.Len(t, payload.Records, 1)
+	assert.Equal(t, "https://example.com/a", payload.Records[0].Key)
+	assert.Equal(t, "t", payload.Records[0].Value.Title)
+}
+
+// fakeNATSServer accepts one connection and returns the protocol lines it receives.
+func fakeNATSServer(t *testing.T) (string, <-chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	received := make(chan []string, 1)
+
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+
+		var lines []string
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimSpace(line)
+			lines = append(lines, line)
+			if strings.HasPrefix(line, "PUB ") {
+				var subject string
+				var size int
+				fmt.Sscanf(line, "PUB %s %d", &subject, &size)
+				payload := make([]byte, size+2)
+				io.ReadFull(r, payload)
+				lines = append(lines, string(payload[:size]))
+			}
+		}
+		received <- lines
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestNATSPublisherSend(t *testing.T) {
+	addr, received := fakeNATSServer(t)
+
+	n := &NATSPublisher{URL: "nats://user:secret@" + addr, Subject: "threatfeed.articles"}
+	event := NewArticleEvent(models.NewsArticle{Title: "t", URL: "u"})
+	require.NoError(t, n.Send(context.Background(), []interface{}{event}))
+	n.Close()
+
+	lines := <-received
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"user":"user"`)
+	assert.Contains(t, lines[0], `"pass":"secret"`)
+	assert.True(t, strings.HasPrefix(lines[1], "PUB threatfeed.articles "))
+	assert.Contains(t, lines[2], `"url":"u"`)
+}
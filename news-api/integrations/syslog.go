@@ -0,0 +1,314 @@
+package integrations
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"news-api/models"
+)
+
+// Syslog message formats.
+const (
+	FormatCEF  = "cef"
+	FormatLEEF = "leef"
+)
+
+// DefaultSyslogFieldMap maps CEF/LEEF extension keys to article fields.
+var DefaultSyslogFieldMap = map[string]string{
+	"msg":     "title",
+	"request": "url",
+	"cs1":     "category",
+	"cs2":     "sourceUrl",
+	"cn1":     "rank",
+}
+
+// syslogFieldLabels labels the custom CEF fields so SIEM parsers can name them.
+var syslogFieldLabels = map[string]string{
+	"cs1": "cs1Label", "cs2": "cs2Label", "cs3": "cs3Label",
+	"cn1": "cn1Label", "cn2": "cn2Label", "cn3": "cn3Label",
+}
+
+// SyslogForwarder emits high-rank articles and threat level changes as CEF or
+// LEEF messages over UDP, TCP or TLS syslog (RFC 5424 headers). TLS uses
+// octet-counting framing (RFC 5425); TCP uses newline-terminated frames. It
+// is a Sink, so its messages wait in the delivery queue while the collector
+// is unreachable.
+type SyslogForwarder struct {
+	Network  string // udp, tcp or tls
+	Address  string
+	Facility int // syslog facility code, e.g. 16 for local0
+	Format   string
+	MinRank  int
+	FieldMap map[string]string
+
+	mu       sync.Mutex
+	conn     net.Conn
+	hostname string
+}
+
+// ParseSyslogFieldMap parses "key=field,key=field" into a field map.
+func ParseSyslogFieldMap(spec string) (map[string]string, error) {
+	fields := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, field, ok := strings.Cut(pair, "=")
+		if !ok || key == "" || field == "" {
+			return nil, fmt.Errorf("invalid syslog field mapping %q", pair)
+		}
+		if _, known := articleField(models.NewsArticle{}, field); !known {
+			return nil, fmt.Errorf("unknown article field %q in syslog mapping", field)
+		}
+		fields[key] = field
+	}
+	return fields, nil
+}
+
+func articleField(article models.NewsArticle, field string) (string, bool) {
+	switch field {
+	case "title":
+		return article.Title, true
+	case "description":
+		return article.Description, true
+	case "url":
+		return article.URL, true
+	case "sourceUrl":
+		return article.SourceURL, true
+	case "category":
+		return article.Category, true
+	case "rank":
+		return strconv.Itoa(article.Rank), true
+	case "publishedAt":
+		return article.PublishedAt.UTC().Format(time.RFC3339), true
+	case "imageUrl":
+		return article.ImageURL, true
+	}
+	return "", false
+}
+
+// Name identifies the sink in the delivery queue.
+func (s *SyslogForwarder) Name() string { return "syslog" }
+
+// MatchArticle reports whether an article meets the rank threshold; only
+// those are queued for the forwarder.
+func (s *SyslogForwarder) MatchArticle(article models.NewsArticle) bool {
+	return article.Rank >= s.MinRank
+}
+
+// OnArticle forwards the events of high-rank articles.
+func (s *SyslogForwarder) OnArticle(ctx context.Context, events []ArticleEvent) error {
+	fieldMap := s.FieldMap
+	if fieldMap == nil {
+		fieldMap = DefaultSyslogFieldMap
+	}
+	for _, event := range events {
+		article := models.NewsArticle{
+			Title:       event.Title,
+			Description: event.Description,
+			ImageURL:    event.ImageURL,
+			URL:         event.URL,
+			SourceURL:   event.SourceURL,
+			PublishedAt: event.PublishedAt,
+			Rank:        event.Rank,
+			Category:    event.Category,
+		}
+		var ext []extension
+		for _, key := range sortedKeys(fieldMap) {
+			value, _ := articleField(article, fieldMap[key])
+			ext = append(ext, extension{key, value})
+			if label, ok := syslogFieldLabels[key]; ok {
+				ext = append(ext, extension{label, fieldMap[key]})
+			}
+		}
+		if err := s.emit("article", "High-rank article", rankSeverity(article.Rank), ext, syslogSeverity(article.Rank)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OnThreatLevelChange forwards threat level transitions.
+func (s *SyslogForwarder) OnThreatLevelChange(ctx context.Context, changes []LevelChangeEvent) error {
+	for _, change := range changes {
+		severity := 3
+		syslogSev := 5 // notice
+		if change.Level == CodeRed {
+			severity, syslogSev = 10, 2 // critical
+		} else if change.Level == "Attention" {
+			severity, syslogSev = 6, 4 // warning
+		}
+		ext := []extension{
+			{"msg", fmt.Sprintf("Threat level changed from %s to %s", orNone(change.Previous), change.Level)},
+			{"cs1", change.Level}, {"cs1Label", "threatLevel"},
+			{"cs2", change.Previous}, {"cs2Label", "previousThreatLevel"},
+			{"cn1", strconv.Itoa(change.Score.HighRankCount)}, {"cn1Label", "highRankCount"},
+			{"cn2", strconv.Itoa(change.Score.MediumRankCount)}, {"cn2Label", "mediumRankCount"},
+			{"cn3", strconv.Itoa(change.Score.TotalArticles)}, {"cn3Label", "totalArticles"},
+		}
+		if err := s.emit("threat-level-change", "Threat level changed", severity, ext, syslogSev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type extension struct {
+	key, value string
+}
+
+// formatSecurityEvent renders a CEF or LEEF payload.
+func formatSecurityEvent(format, eventID, name string, severity int, ext []extension) string {
+	if format == FormatLEEF {
+		fields := []string{"sev=" + strconv.Itoa(severity)}
+		for _, e := range ext {
+			fields = append(fields, e.key+"="+leefValueEscape(e.value))
+		}
+		return "LEEF:1.0|Threatfeed|Threatfeed|1.0|" + leefHeaderEscape(eventID) + "|" + strings.Join(fields, "\t")
+	}
+
+	var fields []string
+	for _, e := range ext {
+		fields = append(fields, e.key+"="+cefValueEscape(e.value))
+	}
+	return fmt.Sprintf("CEF:0|Threatfeed|Threatfeed|1.0|%s|%s|%d|%s",
+		cefHeaderEscape(eventID), cefHeaderEscape(name), severity, strings.Join(fields, " "))
+}
+
+// emit writes an event as a syslog message.
+func (s *SyslogForwarder) emit(eventID, name string, severity int, ext []extension, syslogSev int) error {
+	if err := s.write(syslogSev, formatSecurityEvent(s.Format, eventID, name, severity, ext)); err != nil {
+		return fmt.Errorf("failed to forward %s event to syslog: %v", eventID, err)
+	}
+	return nil
+}
+
+// write sends one syslog message, reconnecting once if the connection dropped.
+func (s *SyslogForwarder) write(severity int, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+		if s.hostname == "" {
+			s.hostname = "-"
+		}
+	}
+	pri := s.Facility*8 + severity
+	line := fmt.Sprintf("<%d>1 %s %s threatfeed - - - %s", pri, time.Now().UTC().Format(time.RFC3339), s.hostname, msg)
+
+	var frame string
+	switch s.Network {
+	case "tls":
+		frame = fmt.Sprintf("%d %s", len(line), line)
+	case "tcp":
+		frame = line + "\n"
+	default:
+		frame = line
+	}
+
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if s.conn, err = s.dial(); err != nil {
+				return err
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err = s.conn.Write([]byte(frame)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+func (s *SyslogForwarder) dial() (net.Conn, error) {
+	switch s.Network {
+	case "tls":
+		host, _, _ := net.SplitHostPort(s.Address)
+		return tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", s.Address, &tls.Config{ServerName: host})
+	case "tcp":
+		return net.DialTimeout("tcp", s.Address, 10*time.Second)
+	default:
+		return net.DialTimeout("udp", s.Address, 10*time.Second)
+	}
+}
+
+// Close closes the syslog connection.
+func (s *SyslogForwarder) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// rankSeverity maps an article rank to the 0-10 CEF severity scale.
+func rankSeverity(rank int) int {
+	switch {
+	case rank >= 15:
+		return 10
+	case rank >= 5:
+		return 8
+	case rank >= 2:
+		return 5
+	default:
+		return 3
+	}
+}
+
+// syslogSeverity maps an article rank to an RFC 5424 severity.
+func syslogSeverity(rank int) int {
+	switch {
+	case rank >= 15:
+		return 2 // critical
+	case rank >= 5:
+		return 4 // warning
+	default:
+		return 5 // notice
+	}
+}
+
+func orNone(level string) string {
+	if level == "" {
+		return "none"
+	}
+	return level
+}
+
+func cefHeaderEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+func cefValueEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "=", `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+func leefHeaderEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func leefValueEscape(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
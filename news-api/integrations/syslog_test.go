@@ -0,0 +1,106 @@
+package integrations
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listenUDP(t *testing.T) (*net.UDPConn, func() string) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn, func() string {
+		buf := make([]byte, 4096)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := conn.ReadFromUDP(buf)
+		require.NoError(t, err)
+		return string(buf[:n])
+	}
+}
+
+func TestSyslogForwarderCEFArticle(t *testing.T) {
+	conn, read := listenUDP(t)
+	s := &SyslogForwarder{Network: "udp", Address: conn.LocalAddr().String(), Facility: 16, Format: FormatCEF, MinRank: 5}
+	defer s.Close()
+
+	article := models.NewsArticle{Title: "Zero-day a=b|c", URL: "https://example.com/x", Category: "Cybersecurity", Rank: 8}
+	assert.False(t, s.MatchArticle(models.NewsArticle{Title: "Low", Rank: 1}))
+	assert.True(t, s.MatchArticle(article))
+	require.NoError(t, s.OnArticle(context.Background(), []ArticleEvent{NewArticleEvent(article)}))
+
+	msg := read()
+	assert.True(t, strings.HasPrefix(msg, "<132>1 "), "local0 (16*8) + warning (4): %s", msg)
+	assert.Contains(t, msg, "CEF:0|Threatfeed|Threatfeed|1.0|article|High-rank article|8|")
+	assert.Contains(t, msg, `msg=Zero-day a\=b|c`)
+	assert.Contains(t, msg, "request=https://example.com/x")
+	assert.Contains(t, msg, "cs1=Cybersecurity cs1Label=category")
+	assert.Contains(t, msg, "cn1=8 cn1Label=rank")
+}
+
+func TestSyslogForwarderLEEFLevelChange(t *testing.T) {
+	conn, read := listenUDP(t)
+	s := &SyslogForwarder{Network: "udp", Address: conn.LocalAddr().String(), Facility: 1, Format: FormatLEEF}
+	defer s.Close()
+
+	require.NoError(t, s.OnThreatLevelChange(context.Background(), []LevelChangeEvent{
+		{Previous: "Attention", Level: CodeRed, Score: db.ThreatScore{ThreatLevel: CodeRed, HighRankCount: 4, TotalArticles: 9}},
+	}))
+
+	msg := read()
+	assert.True(t, strings.HasPrefix(msg, "<10>1 "), "user (1*8) + critical (2): %s", msg)
+	assert.Contains(t, msg, "LEEF:1.0|Threatfeed|Threatfeed|1.0|threat-level-change|sev=10\t")
+	assert.Contains(t, msg, "msg=Threat level changed from Attention to Code Red")
+	assert.Contains(t, msg, "cn1=4\tcn1Label=highRankCount")
+}
+
+func TestSyslogForwarderIsQueued(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	conn, read := listenUDP(t)
+	s := &SyslogForwarder{Network: "udp", Address: "collector.invalid", Facility: 16, Format: FormatCEF, MinRank: 5}
+	defer s.Close()
+	q := NewDeliveryQueue()
+	q.RetryBackoff = time.Minute
+	q.AddSink(s, 10)
+
+	// Only high-rank articles are queued, and the first level observed is
+	// not a change.
+	q.PublishArticle(models.NewsArticle{Title: "Low", Rank: 1})
+	q.PublishArticle(models.NewsArticle{Title: "Queued zero-day", Rank: 8})
+	q.PublishLevelChange("", db.ThreatScore{ThreatLevel: CodeRed})
+	pending, err := db.GetDeliveries(db.DeliveryFilter{})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	// Events wait in the queue while the collector is unreachable.
+	now := time.Now().Add(time.Second)
+	q.Process(now)
+	pending, err = db.GetDeliveries(db.DeliveryFilter{Status: db.DeliveryPending})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Contains(t, pending[0].LastError, "syslog")
+
+	s.Address = conn.LocalAddr().String()
+	q.Process(now.Add(time.Minute))
+	assert.Contains(t, read(), "msg=Queued zero-day")
+}
+
+func TestParseSyslogFieldMap(t *testing.T) {
+	fields, err := ParseSyslogFieldMap("msg=title, request=url ,cs3=publishedAt")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"msg": "title", "request": "url", "cs3": "publishedAt"}, fields)
+
+	_, err = ParseSyslogFieldMap("msg=body")
+	assert.Error(t, err)
+	_, err = ParseSyslogFieldMap("msg")
+	assert.Error(t, err)
+}
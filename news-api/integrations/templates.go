@@ -0,0 +1,184 @@
+package integrations
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"news-api/models"
+)
+
+// TemplateKinds are the kinds of message templates. A report job's template
+// must be of the kind of its channel.
+var TemplateKinds = []string{"slack", "email", "webhook"}
+
+const (
+	// maxTemplateSize bounds the source of a template and maxMessageSize what
+	// it renders to.
+	maxTemplateSize = 64 << 10
+	maxMessageSize  = 256 << 10
+)
+
+var errMessageTooLarge = errors.New("rendered message is larger than 256 KiB")
+
+// MessageData is what message templates are executed with: the articles of a
+// report run for digests, or the one article matching a saved search for
+// alerts.
+type MessageData struct {
+	// Type is "digest" or "alert".
+	Type string `json:"type"`
+	// Name is the report job's or saved search's name.
+	Name     string               `json:"name"`
+	Since    time.Time            `json:"since"`
+	Until    time.Time            `json:"until"`
+	Articles []models.NewsArticle `json:"articles"`
+}
+
+// Message is a rendered message template.
+type Message struct {
+	Subject     string `json:"subject,omitempty"`
+	Body        string `json:"body"`
+	ContentType string `json:"contentType"`
+}
+
+// templateFuncs are the functions available to templates. None of them has
+// side effects or reaches outside the data the template is executed with.
+var templateFuncs = map[string]interface{}{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"slack": slackEscaper.Replace,
+	"truncate": func(n int, s string) string {
+		if n < 0 || utf8.RuneCountInString(s) <= n {
+			return s
+		}
+		return strings.TrimSpace(string([]rune(s)[:n])) + "…"
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+	"date": func(layout string, t time.Time) string {
+		return t.UTC().Format(layout)
+	},
+}
+
+// sizeLimitedBuffer fails writes that would grow it beyond maxMessageSize, so
+// a runaway template stops early.
+type sizeLimitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *sizeLimitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxMessageSize {
+		return 0, errMessageTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// RenderMessage executes a message template. Slack and webhook templates must
+// render valid JSON, which the json function helps with; email bodies are
+// HTML-escaped as they are rendered.
+func RenderMessage(t models.MessageTemplate, data MessageData) (Message, error) {
+	var buf sizeLimitedBuffer
+	switch t.Kind {
+	case "slack", "webhook":
+		tmpl, err := texttemplate.New(t.Name).Funcs(templateFuncs).Parse(t.Body)
+		if err != nil {
+			return Message{}, err
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return Message{}, err
+		}
+		var payload interface{}
+		if err := json.Unmarshal(buf.Bytes(), &payload); err != nil {
+			return Message{}, fmt.Errorf("template does not render valid JSON: %v", err)
+		}
+		if _, ok := payload.(map[string]interface{}); t.Kind == "slack" && !ok {
+			return Message{}, fmt.Errorf("slack templates must render a JSON object such as {\"blocks\": [...]}")
+		}
+		return Message{Body: buf.String(), ContentType: "application/json"}, nil
+	case "email":
+		tmpl, err := htmltemplate.New(t.Name).Funcs(templateFuncs).Parse(t.Body)
+		if err != nil {
+			return Message{}, err
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return Message{}, err
+		}
+		subject := fmt.Sprintf("%s: %d articles", data.Name, len(data.Articles))
+		if t.Subject != "" {
+			subjectTmpl, err := texttemplate.New(t.Name + " subject").Funcs(templateFuncs).Parse(t.Subject)
+			if err != nil {
+				return Message{}, err
+			}
+			var s sizeLimitedBuffer
+			if err := subjectTmpl.Execute(&s, data); err != nil {
+				return Message{}, err
+			}
+			subject = strings.Join(strings.Fields(s.String()), " ")
+		}
+		return Message{Subject: subject, Body: buf.String(), ContentType: "text/html; charset=utf-8"}, nil
+	}
+	return Message{}, fmt.Errorf("kind must be one of %s", strings.Join(TemplateKinds, ", "))
+}
+
+// ValidateMessageTemplate checks that a template parses and renders a valid
+// message for both a sample digest and a sample alert.
+func ValidateMessageTemplate(t models.MessageTemplate) error {
+	if !slices.Contains(TemplateKinds, t.Kind) {
+		return fmt.Errorf("kind must be one of %s", strings.Join(TemplateKinds, ", "))
+	}
+	if strings.TrimSpace(t.Body) == "" || len(t.Body) > maxTemplateSize || len(t.Subject) > maxTemplateSize {
+		return fmt.Errorf("body must be 1 byte to 64 KiB")
+	}
+	if t.Subject != "" && t.Kind != "email" {
+		return fmt.Errorf("only email templates have a subject")
+	}
+	digest := SampleMessageData()
+	alert := digest
+	alert.Type = "alert"
+	alert.Articles = digest.Articles[:1]
+	alert.Since = alert.Until
+	for _, data := range []MessageData{digest, alert} {
+		if _, err := RenderMessage(t, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SampleMessageData is a digest of made-up articles, used to validate and
+// preview templates.
+func SampleMessageData() MessageData {
+	until := time.Now().UTC().Truncate(time.Minute)
+	return MessageData{
+		Type:  "digest",
+		Name:  "Sample digest",
+		Since: until.Add(-24 * time.Hour),
+		Until: until,
+		Articles: []models.NewsArticle{
+			{
+				ID: 1, Title: "Critical RCE in VPN appliance exploited in the wild", URL: "https://example.com/vpn-rce",
+				Description: "Attackers are exploiting an unauthenticated remote code execution flaw. Patch now.",
+				SourceURL:   "https://example.com/feed", PublishedAt: until.Add(-2 * time.Hour), Rank: 12, Severity: 92,
+				Category: "Cybersecurity", Tags: []string{"CVE-2024-0001", "exploited"},
+			},
+			{
+				ID: 2, Title: "Ransomware group claims attack on logistics firm", URL: "https://example.com/ransomware",
+				Description: "The group listed the company on its leak site.",
+				SourceURL:   "https://example.com/feed", PublishedAt: until.Add(-5 * time.Hour), Rank: 7, Severity: 61,
+				Category: "Cybersecurity", Tags: []string{"ransomware"},
+			},
+		},
+	}
+}
@@ -0,0 +1,117 @@
+package integrations
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"news-api/db"
+	"news-api/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const slackBlocksTemplate = `{"blocks": [
+  {"type": "header", "text": {"type": "plain_text", "text": {{json .Name}}}}
+  {{- range .Articles}},
+  {"type": "section", "text": {"type": "mrkdwn", "text": {{printf "<%s|%s> (%d)" .URL (slack .Title) .Severity | json}}}}
+  {{- end}}
+]}`
+
+func TestRenderMessage(t *testing.T) {
+	data := SampleMessageData()
+	data.Articles[0].Title = `Exploit "in the wild" <b>now</b>`
+
+	msg, err := RenderMessage(models.MessageTemplate{Kind: "slack", Body: slackBlocksTemplate}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", msg.ContentType)
+	var payload struct {
+		Blocks []struct {
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(msg.Body), &payload))
+	require.Len(t, payload.Blocks, 3)
+	assert.Equal(t, "Sample digest", payload.Blocks[0].Text.Text)
+	assert.Equal(t, `<https://example.com/vpn-rce|Exploit "in the wild" &lt;b&gt;now&lt;/b&gt;> (92)`, payload.Blocks[1].Text.Text)
+
+	msg, err = RenderMessage(models.MessageTemplate{
+		Kind:    "email",
+		Subject: "{{.Name}}:\n{{len .Articles}} articles",
+		Body:    `<ul>{{range .Articles}}<li><a href="{{.URL}}">{{truncate 25 .Title}}</a> {{join ", " .Tags | upper}}</li>{{end}}</ul>`,
+	}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Sample digest: 2 articles", msg.Subject)
+	assert.Contains(t, msg.Body, `<a href="https://example.com/vpn-rce">Exploit &#34;in the wild&#34; &lt;b&gt;…</a> CVE-2024-0001, EXPLOITED`)
+
+	_, err = RenderMessage(models.MessageTemplate{Kind: "webhook", Body: `{"title": "{{(index .Articles 0).Title}}"}`}, data)
+	assert.ErrorContains(t, err, "valid JSON", "unescaped quotes break the payload")
+	_, err = RenderMessage(models.MessageTemplate{Kind: "slack", Body: `["not", "an", "object"]`}, data)
+	assert.Error(t, err)
+	data.Articles[1].Description = strings.Repeat("x", maxMessageSize)
+	_, err = RenderMessage(models.MessageTemplate{Kind: "webhook", Body: `[{{range .Articles}}{{json .Description}},{{end}}0]`}, data)
+	assert.ErrorIs(t, err, errMessageTooLarge)
+}
+
+func TestValidateMessageTemplate(t *testing.T) {
+	assert.NoError(t, ValidateMessageTemplate(models.MessageTemplate{Kind: "slack", Body: slackBlocksTemplate}))
+	assert.NoError(t, ValidateMessageTemplate(models.MessageTemplate{Kind: "webhook", Body: `{"article": {{json (index .Articles 0)}}}`}))
+	for _, invalid := range []models.MessageTemplate{
+		{Kind: "sms", Body: "{}"},
+		{Kind: "webhook", Body: " "},
+		{Kind: "webhook", Subject: "x", Body: "{}"},
+		{Kind: "webhook", Body: `{{.Missing}}`},
+		{Kind: "webhook", Body: `{{env "HOME"}}`},
+		{Kind: "webhook", Body: `{"second": {{json (index .Articles 1)}}}`},
+	} {
+		assert.Error(t, ValidateMessageTemplate(invalid), invalid.Body)
+	}
+}
+
+func TestTemplatedDelivery(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	// The test servers listen on loopback.
+	AllowPrivateWebhooks = true
+	defer func() { AllowPrivateWebhooks = false }()
+	require.NoError(t, db.ClearAllArticlesForTest())
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+	}))
+	defer server.Close()
+	tmpl, err := db.CreateMessageTemplate(models.MessageTemplate{Name: "Compact", Kind: "webhook",
+		Body: `{"type": {{json .Type}}, "name": {{json .Name}}, "urls": [{{range $i, $a := .Articles}}{{if $i}}, {{end}}{{json $a.URL}}{{end}}]}`})
+	require.NoError(t, err)
+
+	user, err := db.CreateUser("alerts", "correct horse battery")
+	require.NoError(t, err)
+	_, err = db.CreateSavedSearch(models.SavedSearch{UserID: user.ID, Name: "Ivanti", Search: "ivanti", NotifyURL: server.URL, TemplateID: tmpl.ID})
+	require.NoError(t, err)
+	n := &SavedSearchNotifier{}
+	require.NoError(t, n.HandleArticle(context.Background(), models.NewsArticle{Title: "Ivanti VPN exploited", URL: "https://example.com/ivanti"}))
+	require.Len(t, bodies, 1)
+	assert.JSONEq(t, `{"type": "alert", "name": "Ivanti", "urls": ["https://example.com/ivanti"]}`, bodies[0])
+
+	job, err := db.CreateReportJob(models.ReportJob{Name: "Compact digest", MaxArticles: 10, Format: "markdown", Schedule: "@daily",
+		Channel: "webhook", Target: server.URL, TemplateID: tmpl.ID, Enabled: true, NextRunAt: time.Now()})
+	require.NoError(t, err)
+	run := (&ReportScheduler{}).Run(job)
+	assert.Equal(t, db.ReportRunSucceeded, run.Status, run.Error)
+	require.Len(t, bodies, 2)
+	assert.JSONEq(t, `{"type": "digest", "name": "Compact digest", "urls": []}`, bodies[1])
+
+	job.Channel = "slack"
+	run = (&ReportScheduler{}).Run(job)
+	assert.Equal(t, db.ReportRunFailed, run.Status)
+	assert.True(t, strings.Contains(run.Error, "is for webhook, not slack"), run.Error)
+}
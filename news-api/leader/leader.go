@@ -0,0 +1,132 @@
+// Package leader elects the instance that runs the background jobs when
+// several instances of the service run side by side, so that feeds are
+// fetched once while every instance serves reads. The leader holds a lease in
+// a store shared by all instances and renews it well before it expires; when
+// it stops, another instance takes the lease over.
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Store keeps leases shared by all instances.
+type Store interface {
+	// Acquire takes or renews the named lease for holder until ttl from now,
+	// reporting false if another holder has it.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the named lease if holder has it.
+	Release(ctx context.Context, name, holder string) error
+}
+
+// Elector competes for a lease on behalf of this instance.
+type Elector struct {
+	store  Store
+	name   string
+	holder string
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	validUntil time.Time
+	leading    bool
+}
+
+// NewElector returns an elector competing for the named lease as holder, which
+// must be unique to this instance (see DefaultHolder). The lease expires ttl
+// after its last renewal, 30 seconds if ttl is not positive.
+func NewElector(store Store, name, holder string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &Elector{store: store, name: name, holder: holder, ttl: ttl}
+}
+
+// DefaultHolder identifies this instance by host name and process.
+func DefaultHolder() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// IsLeader reports whether this instance holds the lease. Leadership lapses
+// when the lease could not be renewed before it expired, even if the store
+// was unreachable, since another instance may have taken over by then.
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return time.Now().Before(e.validUntil)
+}
+
+// Start tries to take the lease now, so that IsLeader is settled when it
+// returns, and then keeps competing for it in the background until ctx is
+// done. The returned channel is closed once the lease has been released.
+func (e *Elector) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	e.campaign(ctx)
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				e.release()
+				return
+			case <-ticker.C:
+				e.campaign(ctx)
+			}
+		}
+	}()
+	return done
+}
+
+// campaign takes or renews the lease once.
+func (e *Elector) campaign(ctx context.Context) {
+	started := time.Now()
+	attemptCtx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+	acquired, err := e.store.Acquire(attemptCtx, e.name, e.holder, e.ttl)
+	if err != nil {
+		log.Printf("Error renewing %s lease: %v", e.name, err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if acquired {
+		// The lease runs from before the request, in case it was slow.
+		e.validUntil = started.Add(e.ttl)
+	}
+	leading := time.Now().Before(e.validUntil)
+	if leading != e.leading {
+		if leading {
+			log.Printf("This instance (%s) is now the %s leader.", e.holder, e.name)
+		} else {
+			log.Printf("This instance (%s) is no longer the %s leader.", e.holder, e.name)
+		}
+		e.leading = leading
+	}
+}
+
+func (e *Elector) release() {
+	e.mu.Lock()
+	leading := e.leading
+	e.validUntil, e.leading = time.Time{}, false
+	e.mu.Unlock()
+	if !leading {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := e.store.Release(ctx, e.name, e.holder); err != nil {
+		log.Printf("Error releasing %s lease: %v", e.name, err)
+		return
+	}
+	log.Printf("Released the %s lease.", e.name)
+}
@@ -0,0 +1,96 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"news-api/db"
+)
+
+// testStore checks the lease semantics shared by all stores.
+func testStore(t *testing.T, store Store, expire func()) {
+	ctx := context.Background()
+	ok, err := store.Acquire(ctx, "jobs", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "a free lease is acquired")
+	ok, err = store.Acquire(ctx, "jobs", "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "a held lease is not")
+	ok, err = store.Acquire(ctx, "jobs", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "the holder renews its lease")
+
+	require.NoError(t, store.Release(ctx, "jobs", "b"))
+	ok, err = store.Acquire(ctx, "jobs", "b", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "only the holder releases a lease")
+
+	require.NoError(t, store.Release(ctx, "jobs", "a"))
+	ok, err = store.Acquire(ctx, "jobs", "b", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "a released lease is acquired")
+
+	expire()
+	ok, err = store.Acquire(ctx, "jobs", "a", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok, "an expired lease is acquired")
+}
+
+func TestDBStore(t *testing.T) {
+	require.NoError(t, db.InitDB(":memory:"))
+	testStore(t, DBStore{}, func() {
+		// Take a lease that is already expired.
+		require.NoError(t, db.ReleaseLease("jobs", "b"))
+		ok, err := db.AcquireLease("jobs", "b", -time.Second)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+}
+
+func TestRedisStore(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	testStore(t, RedisStore{Client: client, Prefix: "test:"}, func() {
+		server.FastForward(2 * time.Minute)
+	})
+}
+
+func TestElectorFailover(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	store := RedisStore{Client: client}
+
+	firstCtx, stopFirst := context.WithCancel(context.Background())
+	first := NewElector(store, "jobs", "first", 300*time.Millisecond)
+	firstDone := first.Start(firstCtx)
+	assert.True(t, first.IsLeader())
+
+	secondCtx, stopSecond := context.WithCancel(context.Background())
+	defer stopSecond()
+	second := NewElector(store, "jobs", "second", 300*time.Millisecond)
+	second.Start(secondCtx)
+	assert.False(t, second.IsLeader())
+
+	// Leadership is kept while it is renewed...
+	time.Sleep(500 * time.Millisecond)
+	assert.True(t, first.IsLeader())
+	assert.False(t, second.IsLeader())
+
+	// ...and handed over when the leader stops.
+	stopFirst()
+	<-firstDone
+	assert.False(t, first.IsLeader())
+	assert.Eventually(t, second.IsLeader, time.Second, 10*time.Millisecond)
+
+	// A leader that cannot renew its lease steps down once it expires.
+	server.Close()
+	assert.Eventually(t, func() bool { return !second.IsLeader() }, time.Second, 10*time.Millisecond)
+}
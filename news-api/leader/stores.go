@@ -0,0 +1,63 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"news-api/db"
+)
+
+// DBStore keeps leases in the service's database. It suits instances sharing
+// one database file, e.g. on a shared volume.
+type DBStore struct{}
+
+// Acquire implements Store.
+func (DBStore) Acquire(_ context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	return db.AcquireLease(name, holder, ttl)
+}
+
+// Release implements Store.
+func (DBStore) Release(_ context.Context, name, holder string) error {
+	return db.ReleaseLease(name, holder)
+}
+
+// RedisStore keeps leases in Redis, as keys holding the holder's name that
+// expire with the lease.
+type RedisStore struct {
+	Client redis.Scripter
+	// Prefix is prepended to lease names to form their keys.
+	Prefix string
+}
+
+var acquireLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+var releaseLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Acquire implements Store.
+func (s RedisStore) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLease.Run(ctx, s.Client, []string{s.Prefix + name}, holder, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// Release implements Store.
+func (s RedisStore) Release(ctx context.Context, name, holder string) error {
+	return releaseLease.Run(ctx, s.Client, []string{s.Prefix + name}, holder).Err()
+}
@@ -1,15 +1,29 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"log"
 	"net/http"
+	"net/http/pprof"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/time/rate"
 
 	"news-api/db"
+	"news-api/demo"
+	"news-api/fetcher"
 	"news-api/handlers"
+	"news-api/leader"
+	"news-api/ratelimit"
 )
 
 var RssSources = []string{
@@ -45,11 +59,219 @@ var RssSources = []string{
 	"https://www.defensenews.com/arc/outboundfeeds/home-rss/",
 }
 
-// Create a more generous rate limiter that allows 2 requests per second with a burst size of 10.
-var limiter = rate.NewLimiter(2, 10)
+// appendMissing appends the values not already in list.
+func appendMissing(list, values []string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// configureIngestion applies the environment to fetching, enrichment and the
+// list of sources, for the server and the fetch command alike.
+func configureIngestion() {
+	// Publishers that reject the default user agent can be given another one.
+	var hostUserAgents map[string]string
+	if raw := os.Getenv("USER_AGENT_OVERRIDES"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &hostUserAgents); err != nil {
+			log.Fatalf("Invalid USER_AGENT_OVERRIDES: %v", err)
+		}
+	}
+
+	// Feeds and enrichment share one outbound client. Article pages and
+	// publisher sites are also fetched politely.
+	fetcher.Configure(fetcher.Config{
+		UserAgent:      envDefault("USER_AGENT", fetcher.DefaultUserAgent),
+		HostUserAgents: hostUserAgents,
+		MaxPerHost:     envInt("FETCH_MAX_PER_HOST", 4),
+		CacheBytes:     int64(envInt("FETCH_CACHE_MB", 32)) << 20,
+		RespectRobots:  envDefault("RESPECT_ROBOTS_TXT", "true") != "false",
+		HostDelay:      envDuration("FETCH_HOST_DELAY", time.Second),
+		MaxCrawlDelay:  envDuration("MAX_CRAWL_DELAY", 30*time.Second),
+	})
+
+	// Scrape og:image/twitter:image from article pages when feeds carry no image.
+	db.FetchPreviewImages = envDefault("PREVIEW_IMAGE_FALLBACK", "true") != "false"
+	switch db.SanitizePolicy = envDefault("SANITIZE_POLICY", db.SanitizeStrict); db.SanitizePolicy {
+	case db.SanitizeStrict, db.SanitizeUGC:
+	default:
+		log.Fatalf("SANITIZE_POLICY must be %q or %q", db.SanitizeStrict, db.SanitizeUGC)
+	}
+	db.DescriptionMaxLength = envInt("DESCRIPTION_MAX_LENGTH", 1000)
+	if path := os.Getenv("BOILERPLATE_FILE"); path != "" {
+		patterns, err := db.LoadBoilerplate(path)
+		if err != nil {
+			log.Fatalf("Failed to load boilerplate patterns: %v", err)
+		}
+		db.Boilerplate = patterns
+	}
+	if path := os.Getenv("SOURCE_FILTERS_FILE"); path != "" {
+		filters, err := db.LoadSourceFilters(path)
+		if err != nil {
+			log.Fatalf("Failed to load source filters: %v", err)
+		}
+		db.SourceFilters = filters
+	}
+	db.SetIngestKeywords(envList("INGEST_INCLUDE_KEYWORDS"), envList("INGEST_EXCLUDE_KEYWORDS"))
+	if path := os.Getenv("VENDORS_FILE"); path != "" {
+		vendors, err := db.LoadVendors(path)
+		if err != nil {
+			log.Fatalf("Failed to load vendors: %v", err)
+		}
+		db.Vendors = vendors
+	}
+	if path := os.Getenv("RANSOMWARE_GROUPS_FILE"); path != "" {
+		groups, err := db.LoadRansomwareGroups(path)
+		if err != nil {
+			log.Fatalf("Failed to load ransomware groups: %v", err)
+		}
+		db.RansomwareGroups = groups
+	}
+	if path := os.Getenv("SECTORS_FILE"); path != "" {
+		sectors, err := db.LoadSectors(path)
+		if err != nil {
+			log.Fatalf("Failed to load sectors: %v", err)
+		}
+		db.Sectors = sectors
+	}
+	db.TagWatchlist = envList("WATCHLIST")
+	if languages := envList("DETECTION_LANGUAGES"); len(languages) > 0 {
+		if err := db.SetDetectionLanguages(languages); err != nil {
+			log.Fatalf("Invalid DETECTION_LANGUAGES: %v", err)
+		}
+	}
+	db.LanguageWorkers = envInt("LANGUAGE_WORKERS", runtime.NumCPU())
+	db.ArchiveRawItems = envDefault("ARCHIVE_RAW_ITEMS", "true") != "false"
+	db.FeedFixtureDir = envDefault("FEED_FIXTURE_DIR", "./fixtures")
+	db.InferCategories = envDefault("INFER_CATEGORIES", "true") != "false"
+
+	// Operator-defined categories add their sources to the fetched feeds.
+	if path := os.Getenv("CATEGORIES_FILE"); path != "" {
+		categories, err := db.LoadCategories(path)
+		if err != nil {
+			log.Fatalf("Failed to load categories: %v", err)
+		}
+		db.CustomCategories = categories
+		RssSources = appendMissing(RssSources, db.CategorySources())
+	}
+
+	// Articles of paywalled feeds and sites are flagged, so that readers can hide them.
+	db.PaywalledSources = envList("PAYWALLED_SOURCES")
+
+	// Paste-site monitors feed the Exposure category.
+	db.PasteSources = envList("PASTE_SOURCES")
+	RssSources = appendMissing(RssSources, db.PasteSources)
+
+	// Telegram channels, where leaks and breach claims are often announced
+	// first, feed the Social category through a bot added to them.
+	if channels := envList("TELEGRAM_CHANNELS"); len(channels) > 0 {
+		if err := db.SetTelegramChannels(channels); err != nil {
+			log.Fatalf("Invalid TELEGRAM_CHANNELS: %v", err)
+		}
+		db.TelegramBotToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+		if db.TelegramBotToken == "" {
+			log.Fatalf("TELEGRAM_CHANNELS requires TELEGRAM_BOT_TOKEN")
+		}
+		if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+			db.TelegramMediaURL = strings.TrimRight(publicURL, "/") + "/telegram/media/"
+		}
+		RssSources = appendMissing(RssSources, db.TelegramSources())
+	}
+
+	// YouTube channels and playlists, such as conference talk uploads, are
+	// fetched as video articles.
+	for _, id := range envList("YOUTUBE_CHANNELS") {
+		feedURL, err := db.YouTubeFeedURL(id)
+		if err != nil {
+			log.Fatalf("Invalid YOUTUBE_CHANNELS: %v", err)
+		}
+		RssSources = appendMissing(RssSources, []string{feedURL})
+	}
+	db.YouTubeAPIKey = os.Getenv("YOUTUBE_API_KEY")
+
+	// Vendor advisories (CSAF, ROLIE or CVRF documents) feed Cybersecurity.
+	db.AdvisorySources = envList("ADVISORY_SOURCES")
+	RssSources = appendMissing(RssSources, db.AdvisorySources)
+
+	// The GitHub Advisory Database feeds Tech for the selected ecosystems.
+	if ecosystems := envList("GHSA_ECOSYSTEMS"); len(ecosystems) > 0 {
+		if err := db.SetGHSAEcosystems(ecosystems); err != nil {
+			log.Fatalf("Invalid GHSA_ECOSYSTEMS: %v", err)
+		}
+		db.GitHubToken = os.Getenv("GITHUB_TOKEN")
+		if db.GitHubToken == "" {
+			log.Fatalf("GHSA_ECOSYSTEMS requires GITHUB_TOKEN")
+		}
+		RssSources = appendMissing(RssSources, db.GHSASources())
+	}
+
+	// Newsletters, polled from a mailbox or forwarded to /inbound/newsletter,
+	// contribute the articles they link to.
+	db.NewsletterMailbox = os.Getenv("NEWSLETTER_IMAP_URL")
+	handlers.NewsletterToken = os.Getenv("NEWSLETTER_TOKEN")
+	if db.NewsletterMailbox != "" || handlers.NewsletterToken != "" {
+		RssSources = appendMissing(RssSources, []string{db.NewsletterSource})
+	}
+
+	// The demo serves bundled sample feeds locally and ingests only those, so
+	// that it needs no network access.
+	if envDefault("DEMO_MODE", "false") == "true" {
+		server, err := demo.Start()
+		if err != nil {
+			log.Fatalf("Failed to start the demo feeds: %v", err)
+		}
+		RssSources = server.Sources()
+		db.FetchPreviewImages = false
+		log.Printf("Demo mode: ingesting the sample feeds served at %s.", server.URL)
+	}
+}
+
+// Create a more generous rate limiter that allows each client 2 requests per second with a burst size of 10.
+var limiter ratelimit.Store = ratelimit.NewMemory(2, 10)
 
 func main() {
-	if err := db.InitDB("./news.db"); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "fetch" {
+		os.Exit(runFetch(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		os.Exit(runBackfill(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		os.Exit(runRestore(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// A read-only instance serves queries from an existing database, e.g. a
+	// restored snapshot for a public mirror, and never writes to it.
+	readOnly := envDefault("READ_ONLY", "false") == "true"
+	dbPath := envDefault("DB_PATH", "./news.db")
+	// The demo starts from an empty database every time, unless given one.
+	demoMode := envDefault("DEMO_MODE", "false") == "true"
+	if demoMode && os.Getenv("DB_PATH") == "" {
+		dbPath = filepath.Join(os.TempDir(), "threatfeed-demo.db")
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(dbPath + suffix)
+		}
+	}
+	// After a disk wipe, the database is restored from its replica.
+	if !demoMode {
+		restoreMissingDB(dbPath)
+	}
+	if readOnly {
+		if err := db.InitReadOnlyDB(dbPath); err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		// Background jobs only run on the leader, which is never this instance.
+		db.IsLeader = func() bool { return false }
+	} else if err := db.InitDB(dbPath); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
@@ -57,7 +279,7 @@ func main() {
 	count, err := db.GetArticleCount()
 	if err != nil {
 		log.Printf("Warning: Failed to get article count: %v", err)
-	} else if count == 0 {
+	} else if count == 0 && !readOnly && !demoMode {
 		// Database is empty, try to load from CSV backup
 		csvPath := "./articles.csv"
 		if _, err := os.Stat(csvPath); err == nil {
@@ -70,34 +292,349 @@ func main() {
 		}
 	}
 
+	// Background jobs run on their default schedules unless overridden, e.g.
+	// {"caching": "*/5 * * * *"}.
+	if raw := os.Getenv("JOB_SCHEDULES"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &db.JobScheduleOverrides); err != nil {
+			log.Fatalf("Invalid JOB_SCHEDULES: %v", err)
+		}
+		for name, spec := range db.JobScheduleOverrides {
+			if _, err := db.ParseJobSchedule(spec); err != nil {
+				log.Fatalf("Invalid JOB_SCHEDULES schedule for %s: %v", name, err)
+			}
+		}
+	}
+
+	// Report handler panics to an error tracker.
+	setupSentry()
+
+	// Page on-call analysts when the threat level reaches Code Red.
+	setupThreatEscalation()
+
+	// Open tickets for watchlist matches and high-rank articles.
+	setupTicketing()
+
+	// Forward ingested articles to SIEM platforms and message streams.
+	setupStreamSinks()
+
+	// Emit CEF/LEEF events for legacy syslog-only SIEMs.
+	setupSyslog()
+	publishToSinks()
+
+	// Push reports and indicators into OpenCTI.
+	setupOpenCTI()
+
+	// Sign the webhooks of saved searches and scheduled reports, and keep
+	// them off internal addresses unless allowed.
+	setupWebhookSigning()
+	setupWebhookAddresses()
+	setupSavedSearchNotifications()
+
+	// Let analysts save articles to their Pocket reading queue.
+	setupPocket()
+
+	// Deliver the scheduled reports defined under /admin/reports.
+	setupReports()
+
+	// Re-evaluate the threat level after each caching cycle for the integrations above.
+	startThreatWatcher()
+
+	// Deliver the webhooks and events queued by the integrations above.
+	startDeliveryQueue()
+
+	configureIngestion()
+
+	// Keep an offline copy of high-severity articles, searchable and served by /news/{id}/body.
+	if envDefault("ARCHIVE_ARTICLE_BODIES", "false") == "true" {
+		db.StartBodyArchive(db.BodyArchiveConfig{
+			MinSeverity:     envInt("BODY_ARCHIVE_MIN_SEVERITY", db.SeverityHigh),
+			Batch:           envInt("BODY_ARCHIVE_BATCH", 20),
+			ExcludedSources: envList("BODY_ARCHIVE_EXCLUDE_SOURCES"),
+		})
+	}
+
+	// Have the Wayback Machine capture the articles counted towards Code Red.
+	if envDefault("WAYBACK_SAVE", "false") == "true" && !demoMode {
+		db.StartWaybackSaves(db.WaybackSaveConfig{
+			MinSeverity:     envInt("WAYBACK_SAVE_MIN_SEVERITY", db.SeverityHigh),
+			Batch:           envInt("WAYBACK_SAVE_BATCH", 10),
+			MaxAge:          envDuration("WAYBACK_SAVE_MAX_AGE", 48*time.Hour),
+			ExcludedSources: envList("WAYBACK_SAVE_EXCLUDE_SOURCES"),
+			AccessKey:       os.Getenv("WAYBACK_ACCESS_KEY"),
+			SecretKey:       os.Getenv("WAYBACK_SECRET_KEY"),
+		})
+	}
+
+	// When several instances run side by side, only the elected leader runs the background jobs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	redisClient := newRedisClient()
+	var electionDone <-chan struct{}
+	if !readOnly {
+		electionDone = startLeaderElection(ctx, redisClient)
+	}
+
+	// Copy every transaction to object storage for point-in-time restores.
+	var replicationDone <-chan struct{}
+	if !readOnly && !demoMode {
+		replicationDone = startReplication(ctx, dbPath)
+	}
+
 	// Start the background caching job
 	db.StartCachingJob(RssSources)
 
+	// Record the threat level hourly for /threat-history/summary.
+	db.StartThreatSnapshots()
+
+	// Check, analyze and vacuum the database weekly, so that the file does not grow unbounded.
+	maintenance := envList("DB_MAINTENANCE_OPERATIONS")
+	for _, op := range maintenance {
+		if !slices.Contains(db.MaintenanceOperations, op) {
+			log.Fatalf("Unknown DB_MAINTENANCE_OPERATIONS operation %q, expected %s", op, strings.Join(db.MaintenanceOperations, ", "))
+		}
+	}
+	db.StartMaintenanceJob(maintenance)
+
+	// Keep CISA's Known Exploited Vulnerabilities catalog for the KEV deadlines in /calendar.ics.
+	if interval := envDuration("KEV_SYNC_INTERVAL", 24*time.Hour); interval > 0 && !demoMode {
+		db.StartKEVSync(envDefault("KEV_CATALOG_URL", db.DefaultKEVCatalogURL), interval)
+	}
+
+	// Flag articles whose links went dead so /news can hide them, and link
+	// them to an archived copy instead.
+	if sample := envInt("LINK_CHECK_SAMPLE", 50); sample > 0 && !demoMode {
+		db.StartLinkChecker(db.LinkCheckConfig{
+			Sample:          sample,
+			Interval:        envDuration("LINK_CHECK_INTERVAL", time.Hour),
+			PruneAfter:      envDuration("DEAD_LINK_PRUNE_AFTER", 0),
+			ArchiveFallback: envDefault("ARCHIVE_FALLBACK", "true") != "false",
+		})
+	}
+
+	// Keep deleted articles restorable for a while before purging them.
+	if retention := envDuration("TRASH_RETENTION", 30*24*time.Hour); retention > 0 {
+		db.StartTrashPurge(retention)
+	}
+
+	// Backfill new or changed enrichment stages over stored articles a batch
+	// per caching cycle.
+	if batch := envInt("REPROCESS_BATCH", 100); batch > 0 {
+		db.StartReprocessQueue(batch)
+	}
+
 	// Start the self-ping mechanism to keep the service alive on free tiers.
 	go startSelfPing()
 
 	// The main handler is now wrapped in our security middlewares.
 	mux := http.NewServeMux()
-	fs := http.FileServer(http.Dir("./test"))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
-	mux.HandleFunc("/news", handlers.GetNews)
-	mux.HandleFunc("/today-threat", handlers.GetTodayThreat)
-	mux.HandleFunc("/export/csv", handlers.ExportCSV)
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+	static, err := handlers.NewStaticFiles("./test")
+	if err != nil {
+		log.Fatalf("Failed to read static files: %v", err)
+	}
+	mux.Handle("GET /static/", http.StripPrefix("/static/", static))
+	mux.HandleFunc("GET /news", handlers.GetNews)
+	mux.HandleFunc("GET /today-threat", handlers.GetTodayThreat)
+	mux.HandleFunc("GET /news/{id}", handlers.GetArticle)
+	mux.HandleFunc("GET /news/{id}/body", handlers.GetArticleBody)
+	mux.HandleFunc("GET /news/{id}/share", handlers.ShareArticle)
+	mux.HandleFunc("GET /a/{shortid}", handlers.ShowShortLink)
+	mux.HandleFunc("GET /today-threat/explain", handlers.GetTodayThreatExplanation)
+	mux.HandleFunc("GET /threat-history/summary", handlers.GetThreatSummary)
+	mux.HandleFunc("GET /calendar.ics", handlers.GetCalendar)
+	mux.HandleFunc("GET /triggers/new-articles", handlers.GetNewArticlesTrigger)
+	handlers.WidgetOrigins = envList("WIDGET_ORIGINS")
+	mux.HandleFunc("GET /widget", handlers.GetWidget)
+	mux.HandleFunc("OPTIONS /widget", handlers.WidgetPreflight)
+	handlers.EmbedAncestors = envList("EMBED_ANCESTORS")
+	mux.HandleFunc("GET /embed", handlers.GetEmbed)
+	mux.HandleFunc("GET /embed.js", handlers.GetEmbedScript)
+	mux.HandleFunc("GET /oembed", handlers.GetOEmbed)
+	mux.HandleFunc("GET /feed.xml", handlers.GetFeed)
+	handlers.Sitemaps = envDefault("SITEMAPS", "false") == "true"
+	mux.HandleFunc("GET /sitemap.xml", handlers.GetSitemap)
+	mux.HandleFunc("GET /news-sitemap.xml", handlers.GetNewsSitemap)
+	mux.HandleFunc("GET /export/csv", handlers.ExportCSV)
+	mux.HandleFunc("GET /export/threat-history", handlers.ExportThreatHistory)
+	mux.HandleFunc("GET /export/ingest-cycles", handlers.ExportIngestCycles)
+	mux.HandleFunc("GET /export/sources", handlers.ExportSourceStats)
+	mux.HandleFunc("GET /sources", handlers.GetSources)
+	mux.HandleFunc("GET /tags", handlers.GetTags)
+	mux.HandleFunc("GET /categories", handlers.GetCategories)
+	mux.HandleFunc("GET /timeline", handlers.GetTimeline)
+	mux.HandleFunc("GET /cves/top", handlers.GetTopCVEs)
+	mux.HandleFunc("GET /ransomware", handlers.GetRansomwareLog)
+	mux.HandleFunc("GET /breaches", handlers.GetBreaches)
+	mux.HandleFunc("GET /stats/terms", handlers.GetTermStats)
+	mux.HandleFunc("GET /stats/vendors", handlers.GetVendorStats)
+	mux.HandleFunc("GET /stats/sectors", handlers.GetSectorStats)
+	mux.HandleFunc("GET /stats/feedback", handlers.GetFeedbackStats)
+	mux.HandleFunc("GET /stats/engagement", handlers.GetEngagementStats)
+	mux.HandleFunc("GET /r/{id}", handlers.RedirectToArticle)
+	mux.HandleFunc("POST /impressions", handlers.RecordImpressions)
+	mux.HandleFunc("POST /inbound/newsletter", handlers.ReceiveNewsletter)
+	handlers.ImageProxyHosts = envList("IMAGE_PROXY_HOSTS")
+	mux.HandleFunc("GET /img", handlers.ProxyImage)
+	mux.HandleFunc("GET /telegram/media/{id}", handlers.TelegramMedia)
+
+	// Accounts and personal article state.
+	mux.HandleFunc("POST /auth/register", handlers.Register)
+	mux.HandleFunc("POST /auth/login", handlers.Login)
+	mux.HandleFunc("POST /auth/logout", handlers.RequireUser(handlers.Logout))
+	mux.HandleFunc("GET /me", handlers.RequireUser(handlers.GetMe))
+	mux.HandleFunc("GET /me/bookmarks", handlers.RequireUser(handlers.GetBookmarks))
+	mux.HandleFunc("PUT /me/bookmarks/{id}", handlers.RequireUser(handlers.AddBookmark))
+	mux.HandleFunc("DELETE /me/bookmarks/{id}", handlers.RequireUser(handlers.RemoveBookmark))
+	mux.HandleFunc("PUT /me/read/{id}", handlers.RequireUser(handlers.MarkRead))
+	mux.HandleFunc("DELETE /me/read/{id}", handlers.RequireUser(handlers.MarkUnread))
+	mux.HandleFunc("POST /news/{id}/feedback", handlers.RequireUser(handlers.SetArticleFeedback))
+	mux.HandleFunc("POST /news/{id}/save-to-pocket", handlers.RequireUser(handlers.SaveToPocket))
+	mux.HandleFunc("GET /me/pocket", handlers.RequireUser(handlers.GetPocketAccount))
+	mux.HandleFunc("POST /me/pocket/connect", handlers.RequireUser(handlers.ConnectPocket))
+	mux.HandleFunc("DELETE /me/pocket", handlers.RequireUser(handlers.DisconnectPocket))
+	mux.HandleFunc("GET /pocket/callback", handlers.PocketCallback)
+	// Ranking profiles belong to the calling user or API key, so they do not require a session.
+	mux.HandleFunc("GET /me/ranking-profile", handlers.GetRankingProfile)
+	mux.HandleFunc("PUT /me/ranking-profile", handlers.SetRankingProfile)
+	mux.HandleFunc("POST /saved-searches", handlers.RequireUser(handlers.CreateSavedSearch))
+	mux.HandleFunc("GET /saved-searches", handlers.RequireUser(handlers.GetSavedSearches))
+	mux.HandleFunc("GET /saved-searches/{id}/articles", handlers.RequireUser(handlers.RunSavedSearch))
+	mux.HandleFunc("DELETE /saved-searches/{id}", handlers.RequireUser(handlers.DeleteSavedSearch))
+
+	// Tenant administration.
+	handlers.AdminToken = os.Getenv("ADMIN_TOKEN")
+	mux.HandleFunc("POST /admin/orgs", handlers.RequireAdmin(handlers.CreateOrganization))
+	mux.HandleFunc("GET /admin/orgs", handlers.RequireAdmin(handlers.GetOrganizations))
+	mux.HandleFunc("GET /admin/orgs/{id}", handlers.RequireAdmin(handlers.GetOrganization))
+	mux.HandleFunc("POST /admin/orgs/{id}/api-keys", handlers.RequireAdmin(handlers.CreateAPIKey))
+	mux.HandleFunc("DELETE /admin/orgs/{id}/api-keys/{keyId}", handlers.RequireAdmin(handlers.DeleteAPIKey))
+	mux.HandleFunc("POST /admin/orgs/{id}/sources", handlers.RequireAdmin(handlers.AddOrgSource))
+	mux.HandleFunc("DELETE /admin/orgs/{id}/sources", handlers.RequireAdmin(handlers.RemoveOrgSource))
+	mux.HandleFunc("PUT /admin/orgs/{id}/keywords", handlers.RequireAdmin(handlers.SetOrgKeywords))
+	mux.HandleFunc("PUT /admin/orgs/{id}/watchlist", handlers.RequireAdmin(handlers.SetOrgWatchlist))
+	mux.HandleFunc("POST /admin/orgs/{id}/members", handlers.RequireAdmin(handlers.AddOrgMember))
+	mux.HandleFunc("GET /admin/articles/{id}/raw", handlers.RequireAdmin(handlers.GetRawItem))
+	mux.HandleFunc("POST /admin/reprocess", handlers.RequireAdmin(handlers.ReprocessArticles))
+	mux.HandleFunc("GET /admin/enrichment", handlers.RequireAdmin(handlers.GetEnrichmentStatus))
+	mux.HandleFunc("POST /admin/enrichment/queue", handlers.RequireAdmin(handlers.EnqueueReprocess))
+	mux.HandleFunc("GET /admin/jobs", handlers.RequireAdmin(handlers.GetJobs))
+	mux.HandleFunc("POST /admin/jobs/{name}/pause", handlers.RequireAdmin(handlers.PauseJob))
+	mux.HandleFunc("POST /admin/jobs/{name}/resume", handlers.RequireAdmin(handlers.ResumeJob))
+	mux.HandleFunc("GET /admin/fixtures", handlers.RequireAdmin(handlers.GetFeedFixtures))
+	mux.HandleFunc("POST /admin/fixtures/record", handlers.RequireAdmin(handlers.RecordFeed))
+	mux.HandleFunc("POST /admin/fixtures/{name}/replay", handlers.RequireAdmin(handlers.ReplayFeedFixture))
+	mux.HandleFunc("PUT /admin/articles/{id}/tags/{tag}", handlers.RequireAdmin(handlers.AddArticleTag))
+	mux.HandleFunc("DELETE /admin/articles/{id}/tags/{tag}", handlers.RequireAdmin(handlers.RemoveArticleTag))
+	mux.HandleFunc("DELETE /admin/articles/{id}", handlers.RequireAdmin(handlers.DeleteArticle))
+	mux.HandleFunc("GET /admin/trash", handlers.RequireAdmin(handlers.GetTrash))
+	mux.HandleFunc("DELETE /admin/trash", handlers.RequireAdmin(handlers.EmptyTrash))
+	mux.HandleFunc("POST /admin/trash/{id}/restore", handlers.RequireAdmin(handlers.RestoreArticle))
+	mux.HandleFunc("DELETE /admin/trash/{id}", handlers.RequireAdmin(handlers.PurgeArticle))
+	mux.HandleFunc("POST /admin/threat-level/override", handlers.RequireAdmin(handlers.SetThreatOverride))
+	mux.HandleFunc("DELETE /admin/threat-level/override", handlers.RequireAdmin(handlers.ClearThreatOverride))
+	mux.HandleFunc("POST /admin/reports", handlers.RequireAdmin(handlers.CreateReportJob))
+	mux.HandleFunc("GET /admin/reports", handlers.RequireAdmin(handlers.GetReportJobs))
+	mux.HandleFunc("GET /admin/reports/{id}", handlers.RequireAdmin(handlers.GetReportJob))
+	mux.HandleFunc("PUT /admin/reports/{id}", handlers.RequireAdmin(handlers.UpdateReportJob))
+	mux.HandleFunc("DELETE /admin/reports/{id}", handlers.RequireAdmin(handlers.DeleteReportJob))
+	mux.HandleFunc("GET /admin/reports/{id}/runs", handlers.RequireAdmin(handlers.GetReportRuns))
+	mux.HandleFunc("POST /admin/reports/{id}/run", handlers.RequireAdmin(handlers.RunReportJob))
+	mux.HandleFunc("POST /admin/templates", handlers.RequireAdmin(handlers.CreateMessageTemplate))
+	mux.HandleFunc("GET /admin/templates", handlers.RequireAdmin(handlers.GetMessageTemplates))
+	mux.HandleFunc("POST /admin/templates/preview", handlers.RequireAdmin(handlers.PreviewMessageTemplate))
+	mux.HandleFunc("GET /admin/templates/{id}", handlers.RequireAdmin(handlers.GetMessageTemplate))
+	mux.HandleFunc("PUT /admin/templates/{id}", handlers.RequireAdmin(handlers.UpdateMessageTemplate))
+	mux.HandleFunc("DELETE /admin/templates/{id}", handlers.RequireAdmin(handlers.DeleteMessageTemplate))
+	mux.HandleFunc("GET /admin/deliveries", handlers.RequireAdmin(handlers.GetDeliveries))
+	mux.HandleFunc("DELETE /admin/deliveries", handlers.RequireAdmin(handlers.DeleteDeliveries))
+	mux.HandleFunc("POST /admin/deliveries/replay", handlers.RequireAdmin(handlers.ReplayDeliveries))
+	mux.HandleFunc("GET /admin/deliveries/{id}", handlers.RequireAdmin(handlers.GetDelivery))
+	mux.HandleFunc("DELETE /admin/deliveries/{id}", handlers.RequireAdmin(handlers.DeleteDelivery))
+	mux.HandleFunc("POST /admin/deliveries/{id}/replay", handlers.RequireAdmin(handlers.ReplayDelivery))
+	mux.HandleFunc("GET /admin/audit", handlers.RequireAdmin(handlers.GetAuditLog))
+	mux.HandleFunc("GET /admin/fetch-stats", handlers.RequireAdmin(handlers.GetFetchStats))
+	mux.HandleFunc("GET /admin/runtime", handlers.RequireAdmin(handlers.GetRuntime))
+	mux.HandleFunc("GET /admin/db/stats", handlers.RequireAdmin(handlers.GetDBStats))
+	mux.HandleFunc("POST /admin/db/maintain", handlers.RequireAdmin(handlers.MaintainDB))
+	// The profiler is registered on our mux rather than http.DefaultServeMux so
+	// that it is only reachable with the admin token.
+	mux.HandleFunc("/debug/pprof/", handlers.RequireAdmin(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", handlers.RequireAdmin(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", handlers.RequireAdmin(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", handlers.RequireAdmin(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", handlers.RequireAdmin(pprof.Trace))
+	mux.HandleFunc("GET /metrics", handlers.RequireAdmin(handlers.Metrics))
+	mux.HandleFunc("GET /readyz", handlers.GetReadiness)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 
-	// Chain the middlewares. The request will flow from logging to security headers to the rate limiter.
-	handler := loggingMiddleware(securityHeadersMiddleware(rateLimitMiddleware(mux)))
+	// Chain the middlewares. The request will flow from client address resolution to logging to
+	// panic recovery to security headers to the rate limiter and the request limits, and is then scoped to the
+	// caller's organization.
+	limiter = newRateLimitStore(redisClient)
+	trustedProxies, err := parseTrustedProxies(envList("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	requestTimeout := envDuration("REQUEST_TIMEOUT", 30*time.Second)
+	// Leave handlers that hit the request timeout time to write their error.
+	writeTimeout := envDuration("WRITE_TIMEOUT", requestTimeout+10*time.Second)
+	limits := requestLimitsMiddleware(requestTimeout, writeTimeout, int64(envInt("MAX_BODY_BYTES", 1<<20)))
+	handlers.ExportWriteTimeout = writeTimeout
+	handlers.MaxPageSize = envInt("MAX_PAGE_SIZE", handlers.MaxPageSize)
+	handlers.DefaultPageSize = envInt("PAGE_SIZE", handlers.DefaultPageSize)
+	if handlers.MaxPageSize < 1 || handlers.DefaultPageSize < 1 || handlers.DefaultPageSize > handlers.MaxPageSize {
+		log.Fatalf("PAGE_SIZE must be between 1 and MAX_PAGE_SIZE")
+	}
+	handlers.MaxIngestAge = envDuration("READY_MAX_INGEST_AGE", time.Hour)
+	handlers.SourceStaleAfter = envDuration("SOURCE_STALE_AFTER", 6*time.Hour)
+	handlers.FeedbackRanking = envDefault("FEEDBACK_RANKING", "false") == "true"
+	handlers.PublicURL = os.Getenv("PUBLIC_URL")
+	db.FeedbackModelTTL = envDuration("FEEDBACK_MODEL_TTL", db.FeedbackModelTTL)
+	var routes http.Handler = handlers.JSONErrors(mux)
+	if readOnly {
+		// Nothing is ingested here, so the data can be as old as the snapshot.
+		handlers.MaxIngestAge = 0
+		handlers.TrackEngagement = false
+		routes = readOnlyMiddleware(routes)
+	}
+	handler := realIPMiddleware(trustedProxies)(loggingMiddleware(recoveryMiddleware(securityHeadersMiddleware(rateLimitMiddleware(limits(handlers.ScopeOrg(routes)))))))
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadHeaderTimeout: envDuration("READ_HEADER_TIMEOUT", 10*time.Second),
+		ReadTimeout:       envDuration("READ_TIMEOUT", 30*time.Second),
+		// The write timeout is set per request by requestLimitsMiddleware,
+		// which leaves it out for streamed responses.
+		IdleTimeout: envDuration("IDLE_TIMEOUT", 2*time.Minute),
+	}
+	// On SIGTERM, finish the requests in flight and hand leadership over.
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
 	log.Println("Server starting on port " + port + "...")
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	if electionDone != nil {
+		<-electionDone
+	}
+	if replicationDone != nil {
+		<-replicationDone
+	}
+	log.Println("Server stopped.")
 }
 
 // Middleware for logging requests
@@ -120,6 +657,64 @@ func securityHeadersMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// streamingRoutes are the paths whose responses take as long as they need:
+// streamed exports, and CPU profiles and traces, which last as long as the
+// caller asks. The request timeout and the write timeout do not apply to them.
+var streamingRoutes = map[string]bool{
+	"/export/csv":          true,
+	"/debug/pprof/profile": true,
+	"/debug/pprof/trace":   true,
+}
+
+// requestLimitsMiddleware bounds the work done for a request: its context is
+// cancelled after timeout, which stops handlers waiting on the database or
+// on other servers, its response must be written within writeTimeout and
+// request bodies are limited to maxBody bytes. A zero timeout, writeTimeout or
+// maxBody disables that limit. The timeouts are not applied to streamingRoutes.
+func requestLimitsMiddleware(timeout, writeTimeout time.Duration, maxBody int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxBody > 0 {
+				if r.ContentLength > maxBody {
+					handlers.WriteError(w, http.StatusRequestEntityTooLarge, "Request Entity Too Large")
+					return
+				}
+				r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+			}
+			if streamingRoutes[r.URL.Path] {
+				// Clear the deadline of an earlier request on the connection.
+				http.NewResponseController(w).SetWriteDeadline(time.Time{})
+				next.ServeHTTP(w, r)
+				return
+			}
+			if writeTimeout > 0 {
+				// Writers that do not support deadlines, as in tests, are left
+				// without one.
+				http.NewResponseController(w).SetWriteDeadline(time.Now().Add(writeTimeout))
+			}
+			if timeout > 0 {
+				ctx, cancel := context.WithTimeout(r.Context(), timeout)
+				defer cancel()
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// readOnlyMiddleware rejects requests that could change state, for instances
+// serving a read-only database.
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			w.Header().Set("Allow", "GET, HEAD")
+			handlers.WriteError(w, http.StatusMethodNotAllowed, "This instance is read-only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // startSelfPing periodically pings the /healthz endpoint to keep the service alive on free hosting tiers.
 func startSelfPing() {
 	appURL := os.Getenv("APP_URL")
@@ -149,7 +744,69 @@ func startSelfPing() {
 	}
 }
 
-// Middleware for rate limiting, which excludes the /healthz endpoint.
+// newRedisClient connects to the Redis server at REDIS_URL, if it is set.
+func newRedisClient() *redis.Client {
+	raw := os.Getenv("REDIS_URL")
+	if raw == "" {
+		return nil
+	}
+	opts, err := redis.ParseURL(raw)
+	if err != nil {
+		log.Fatalf("Invalid REDIS_URL: %v", err)
+	}
+	return redis.NewClient(opts)
+}
+
+// startLeaderElection competes for leadership of the background jobs through
+// the store selected by LEADER_ELECTION: "db" for instances sharing the
+// database file, "redis" for instances sharing the Redis server at REDIS_URL.
+// Without it, this instance always runs the jobs. The returned channel is
+// closed once the lease has been released after ctx is done.
+func startLeaderElection(ctx context.Context, redisClient *redis.Client) <-chan struct{} {
+	var store leader.Store
+	switch mode := os.Getenv("LEADER_ELECTION"); mode {
+	case "":
+		done := make(chan struct{})
+		close(done)
+		return done
+	case "db":
+		store = leader.DBStore{}
+	case "redis":
+		if redisClient == nil {
+			log.Fatalf("LEADER_ELECTION=redis requires REDIS_URL")
+		}
+		store = leader.RedisStore{Client: redisClient, Prefix: "threatfeed:lease:"}
+	default:
+		log.Fatalf("Unknown LEADER_ELECTION %q, expected db or redis", mode)
+	}
+	elector := leader.NewElector(store, "ingestion", envDefault("INSTANCE_ID", leader.DefaultHolder()), envDuration("LEADER_LEASE_TTL", 30*time.Second))
+	done := elector.Start(ctx)
+	db.IsLeader = elector.IsLeader
+	return done
+}
+
+// newRateLimitStore returns the rate limit store selected by RATE_LIMIT_STORE:
+// "memory" (the default) limits each instance separately, "redis" shares the
+// limits of all instances using the same Redis server.
+func newRateLimitStore(redisClient *redis.Client) ratelimit.Store {
+	limit := rate.Limit(envInt("RATE_LIMIT", 2))
+	burst := envInt("RATE_LIMIT_BURST", 10)
+	switch store := envDefault("RATE_LIMIT_STORE", "memory"); store {
+	case "memory":
+		return ratelimit.NewMemory(limit, burst)
+	case "redis":
+		if redisClient == nil {
+			log.Fatalf("RATE_LIMIT_STORE=redis requires REDIS_URL")
+		}
+		log.Println("Rate limits are shared through Redis.")
+		return ratelimit.NewRedis(redisClient, limit, burst, "threatfeed:ratelimit:")
+	default:
+		log.Fatalf("Unknown RATE_LIMIT_STORE %q, expected memory or redis", store)
+		return nil
+	}
+}
+
+// Middleware for rate limiting per client, which excludes the /healthz endpoint.
 func rateLimitMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Exclude the /healthz endpoint from rate limiting.
@@ -157,8 +814,12 @@ func rateLimitMiddleware(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		if !limiter.Allow() {
-			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		allowed, err := limiter.Allow(r.Context(), ratelimit.ClientKey(r.RemoteAddr))
+		if err != nil {
+			// Fail open: an unavailable store must not take the API down.
+			log.Printf("Error checking rate limit: %v", err)
+		} else if !allowed {
+			handlers.WriteError(w, http.StatusTooManyRequests, "Too Many Requests")
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
-
-	"golang.org/x/time/rate"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+
+	"news-api/ratelimit"
 )
 
 func TestSecurityHeadersMiddleware(t *testing.T) {
@@ -35,7 +38,7 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 
 func TestRateLimitMiddleware(t *testing.T) {
 	// Reset the global limiter for this test to ensure isolation.
-	limiter = rate.NewLimiter(2, 10)
+	limiter = ratelimit.NewMemory(2, 10)
 
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -81,3 +84,79 @@ func TestLoggingMiddleware(t *testing.T) {
 	assert.True(t, called, "next handler was not called")
 	assert.Equal(t, http.StatusOK, rr.Code)
 }
+
+func TestRequestLimitsMiddleware(t *testing.T) {
+	var readErr error
+	var deadline bool
+	handlerToTest := requestLimitsMiddleware(time.Minute, time.Minute, 8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = r.Context().Deadline()
+		_, readErr = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handlerToTest.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("small")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, readErr)
+	assert.True(t, deadline, "request context should have a deadline")
+
+	handlerToTest.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/debug/pprof/profile?seconds=60", nil))
+	assert.False(t, deadline, "streamed responses should not time out")
+
+	rr = httptest.NewRecorder()
+	handlerToTest.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("far too large")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+
+	// Bodies of unknown length are cut off while they are read.
+	req := httptest.NewRequest("POST", "/", io.MultiReader(strings.NewReader("far too large")))
+	req.ContentLength = -1
+	handlerToTest.ServeHTTP(httptest.NewRecorder(), req)
+	var maxBytesErr *http.MaxBytesError
+	assert.ErrorAs(t, readErr, &maxBytesErr)
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	handlerToTest := readOnlyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for method, want := range map[string]int{"GET": http.StatusOK, "HEAD": http.StatusOK, "POST": http.StatusMethodNotAllowed, "PUT": http.StatusMethodNotAllowed, "DELETE": http.StatusMethodNotAllowed} {
+		rr := httptest.NewRecorder()
+		handlerToTest.ServeHTTP(rr, httptest.NewRequest(method, "/me/bookmarks/1", nil))
+		assert.Equal(t, want, rr.Code, method)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	defer func(reporters []PanicReporter) { panicReporters = reporters }(panicReporters)
+	panicReporters = nil
+	var reported interface{}
+	var stack []byte
+	registerPanicReporter(func(r *http.Request, recovered interface{}, s []byte) {
+		reported, stack = recovered, s
+	})
+
+	handler := recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error": {"code": "internal_error", "message": "Internal Server Error"}}`, rr.Body.String())
+	assert.Equal(t, "boom", reported)
+	assert.Contains(t, string(stack), "TestRecoveryMiddleware")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "ok", rr.Body.String())
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		recoveryMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
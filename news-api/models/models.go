@@ -1,15 +1,502 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // NewsArticle defines the structure for a news article.
 type NewsArticle struct {
+	ID          int64  `json:"id"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
-	ImageURL    string `json:"imageUrl"`
-	URL         string `json:"url"`
-	SourceURL   string `json:"sourceUrl"`
+	// DescriptionHTML is the description's sanitized markup, kept when the
+	// deployment's sanitization policy allows basic formatting.
+	DescriptionHTML string `json:"descriptionHtml,omitempty"`
+	// Content is the full text of a description that was truncated at ingest.
+	// It is only loaded for /news/{id}?expand=content.
+	Content     string    `json:"content,omitempty"`
+	ImageURL    string    `json:"imageUrl"`
+	URL         string    `json:"url"`
+	SourceURL   string    `json:"sourceUrl"`
 	PublishedAt time.Time `json:"publishedAt"`
-	Rank        int    `json:"rank"`
-	Category    string `json:"category"`
+	// IngestedAt is when the article was first stored, which for feeds that
+	// backfill old posts can be long after PublishedAt.
+	IngestedAt time.Time `json:"ingestedAt"`
+	// Rank is the raw keyword score. Severity is the same score calibrated per
+	// category onto 0-100 and is what comparisons across categories should use.
+	Rank     int    `json:"rank"`
+	Severity int    `json:"severity"`
+	Category string `json:"category"`
+	// Categories are the primary Category followed by the secondary categories
+	// the article also concerns, e.g. Defense for a breach at a defense
+	// contractor. The threat score only counts the primary one.
+	Categories []string `json:"categories,omitempty"`
+	Author     string   `json:"author,omitempty"`
+	// GUID is the feed item's identifier, used to recognize items whose URL changed.
+	GUID string   `json:"guid,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	// MediaType is "video" for videos, "podcast" for podcast episodes and
+	// empty for text articles.
+	MediaType string `json:"mediaType,omitempty"`
+	// Duration is the length of a video or episode in seconds, 0 if unknown.
+	Duration int `json:"duration,omitempty"`
+	// AudioURL is the audio file of a podcast episode.
+	AudioURL string `json:"audioUrl,omitempty"`
+	// Paywalled is set for articles of paywalled sources and for articles
+	// whose page turned out to be behind a paywall.
+	Paywalled bool `json:"paywalled,omitempty"`
+	// LinkDead is set when the article URL last responded 404 or 410.
+	LinkDead bool `json:"linkDead,omitempty"`
+	// ArchiveURL is a Wayback Machine capture of the article, looked up once
+	// its link is dead.
+	ArchiveURL string `json:"archiveUrl,omitempty"`
+	// SourceIcon is the publisher's favicon or logo, if it could be resolved.
+	SourceIcon string `json:"sourceIcon,omitempty"`
+	// OrgID is the organization whose sources the article came from, 0 for the shared feed.
+	OrgID int64 `json:"-"`
+}
+
+// User is a registered account that can keep personal state such as bookmarks.
+type User struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	OrgID     int64     `json:"orgId,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// BookmarkedArticle is an article in a user's bookmark list along with its read state.
+type BookmarkedArticle struct {
+	NewsArticle
+	Read         bool      `json:"read"`
+	BookmarkedAt time.Time `json:"bookmarkedAt"`
+}
+
+// TrashedArticle is an article in the trash along with when it was deleted.
+type TrashedArticle struct {
+	NewsArticle
+	DeletedAt time.Time `json:"deletedAt"`
+}
+
+// PocketAccount is the Pocket account a user connected to save articles to.
+// The access token is kept server-side and never returned.
+type PocketAccount struct {
+	Username    string    `json:"username"`
+	ConnectedAt time.Time `json:"connectedAt"`
+	AccessToken string    `json:"-"`
+}
+
+// SavedSearch is a named filter combination a user can re-run, optionally
+// notifying a webhook when newly ingested articles match. TemplateID selects
+// the message template of notifications, 0 for the default JSON payload.
+type SavedSearch struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+	OrgID       int64     `json:"-"`
+	Name        string    `json:"name"`
+	Source      string    `json:"source,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	Search      string    `json:"search,omitempty"`
+	MinRank     int       `json:"minRank,omitempty"`
+	MinSeverity int       `json:"minSeverity,omitempty"`
+	NotifyURL   string    `json:"notifyUrl,omitempty"`
+	TemplateID  int64     `json:"templateId,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// ReportJob is a report delivered on a cron schedule: the articles matching
+// its filter that were ingested since its last successful run, rendered in
+// Format and sent through Channel to Target.
+type ReportJob struct {
+	ID          int64  `json:"id"`
+	OrgID       int64  `json:"orgId"`
+	Name        string `json:"name"`
+	Source      string `json:"source,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	Search      string `json:"search,omitempty"`
+	MinSeverity int    `json:"minSeverity,omitempty"`
+	// MaxArticles caps the articles in one report, highest severity first.
+	MaxArticles int    `json:"maxArticles"`
+	Format      string `json:"format"`
+	Schedule    string `json:"schedule"`
+	Channel     string `json:"channel"`
+	// Target is where the channel delivers: a URL for webhook and slack,
+	// comma-separated addresses for email, s3://bucket/prefix for s3.
+	// TemplateID selects a message template replacing Format for the slack,
+	// email and webhook channels, 0 for none.
+	Target        string     `json:"target"`
+	TemplateID    int64      `json:"templateId,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	NextRunAt     time.Time  `json:"nextRunAt"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	// ConsecutiveFailures counts the failed runs since the last successful one.
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	CreatedAt           time.Time `json:"createdAt"`
+}
+
+// ReportRun records one run of a report job.
+type ReportRun struct {
+	ID         int64     `json:"id"`
+	JobID      int64     `json:"jobId"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Status is "succeeded" or "failed".
+	Status   string `json:"status"`
+	Articles int    `json:"articles"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MessageTemplate is a Go template customizing a notification or digest
+// message. Slack and webhook templates render a JSON payload, email templates
+// an HTML body and a plain-text Subject.
+type MessageTemplate struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Kind is "slack", "email" or "webhook".
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Delivery is a message waiting in the outbound delivery queue, or given up
+// on after its last attempt failed.
+type Delivery struct {
+	ID int64 `json:"id"`
+	// Sink names the integration delivering it, e.g. "webhook" or "splunk".
+	Sink string `json:"sink"`
+	// Target is the URL of webhook deliveries.
+	Target      string `json:"target,omitempty"`
+	ContentType string `json:"contentType"`
+	Payload     string `json:"payload"`
+	// Status is "pending" or "dead".
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Organization is a tenant with its own sources, scoring rules and API keys.
+type Organization struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrgSettings holds an organization's feed sources and scoring rules.
+type OrgSettings struct {
+	Sources []string `json:"sources"`
+	// Keywords adds the given weight to the rank of articles mentioning the keyword.
+	Keywords map[string]int `json:"keywords"`
+	// Watchlist terms (vendors, products, actors) are scored as high-impact keywords.
+	Watchlist []string `json:"watchlist"`
+}
+
+// APIKey identifies an organization to the API. The secret is only returned on creation.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	OrgID      int64      `json:"orgId"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// AuditEntry records an administrative change. Before and After hold the JSON
+// state of the target around the change and are omitted when there is none.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Target     string          `json:"target"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RemoteAddr string          `json:"remoteAddr,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// TimelineDay summarizes the coverage of a topic on one day.
+type TimelineDay struct {
+	Date     string        `json:"date"`
+	Count    int           `json:"count"`
+	RankSum  int           `json:"rankSum"`
+	Articles []NewsArticle `json:"articles"`
+}
+
+// TermFrequency is a word-cloud entry: a term with its weighted number of
+// mentions and the number of articles using it.
+type TermFrequency struct {
+	Term     string `json:"term"`
+	Weight   int    `json:"weight"`
+	Articles int    `json:"articles"`
+}
+
+// Breach is a data breach announced by an article.
+type Breach struct {
+	ID int64 `json:"id"`
+	// Organization is the breached organization, empty if the article does
+	// not name it.
+	Organization string `json:"organization"`
+	// Records estimates how many records, accounts or people were exposed,
+	// 0 if the article gives no figure.
+	Records    int64     `json:"records"`
+	ReportedAt time.Time `json:"reportedAt"`
+	ArticleID  int64     `json:"articleId"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	SourceURL  string    `json:"sourceUrl"`
+}
+
+// RansomwareMention is an article mentioning a ransomware group, with the
+// victims it names.
+type RansomwareMention struct {
+	Group   string      `json:"group"`
+	Victims []string    `json:"victims,omitempty"`
+	Article NewsArticle `json:"article"`
+}
+
+// EngagementCount is the number of impressions and clicks of the articles of
+// a source or category.
+type EngagementCount struct {
+	Key         string `json:"key"`
+	Impressions int    `json:"impressions"`
+	Clicks      int    `json:"clicks"`
+	// ClickThroughRate is Clicks divided by Impressions, 0 without impressions.
+	ClickThroughRate float64 `json:"clickThroughRate"`
+}
+
+// EngagementStats aggregates how often articles were shown and opened.
+type EngagementStats struct {
+	Impressions      int               `json:"impressions"`
+	Clicks           int               `json:"clicks"`
+	ClickThroughRate float64           `json:"clickThroughRate"`
+	BySource         []EngagementCount `json:"bySource"`
+	ByCategory       []EngagementCount `json:"byCategory"`
+}
+
+// ClickThroughRate returns clicks divided by impressions, or 0 without impressions.
+func ClickThroughRate(impressions, clicks int) float64 {
+	if impressions == 0 {
+		return 0
+	}
+	return float64(clicks) / float64(impressions)
+}
+
+// FeedbackCount is the number of up and down votes on the articles of a
+// source or category.
+type FeedbackCount struct {
+	Key  string `json:"key"`
+	Up   int    `json:"up"`
+	Down int    `json:"down"`
+}
+
+// FeedbackStats aggregates relevance votes on articles.
+type FeedbackStats struct {
+	Up         int             `json:"up"`
+	Down       int             `json:"down"`
+	BySource   []FeedbackCount `json:"bySource"`
+	ByCategory []FeedbackCount `json:"byCategory"`
+}
+
+// SectorCount is the number of articles concerning a sector, with how many
+// of them are of high severity.
+type SectorCount struct {
+	Sector       string `json:"sector"`
+	Articles     int    `json:"articles"`
+	HighSeverity int    `json:"highSeverity"`
+}
+
+// VendorMentions is the number of articles mentioning a vendor, with the
+// number naming each of its products.
+type VendorMentions struct {
+	Vendor   string         `json:"vendor"`
+	Articles int            `json:"articles"`
+	Products map[string]int `json:"products"`
+}
+
+// Source is a feed that articles are ingested from.
+type Source struct {
+	URL          string `json:"url"`
+	Title        string `json:"title,omitempty"`
+	SiteURL      string `json:"siteUrl,omitempty"`
+	IconURL      string `json:"iconUrl,omitempty"`
+	Category     string `json:"category"`
+	ArticleCount int    `json:"articleCount"`
+}
+
+// SourceStats summarizes the articles of a source and its ingestion health.
+type SourceStats struct {
+	URL                  string    `json:"url"`
+	Title                string    `json:"title,omitempty"`
+	Category             string    `json:"category"`
+	Articles             int       `json:"articles"`
+	HighSeverityArticles int       `json:"highSeverityArticles"`
+	AverageRank          float64   `json:"averageRank"`
+	FirstPublishedAt     time.Time `json:"firstPublishedAt"`
+	LastPublishedAt      time.Time `json:"lastPublishedAt"`
+	// LastSuccessAt and LastError are the source's last fetch, if it is
+	// still fetched.
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+}
+
+// Tag labels articles, either automatically (IOC types, ATT&CK techniques,
+// threat actors, watchlist terms) or manually by an administrator.
+type Tag struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	ArticleCount int    `json:"articleCount"`
+}
+
+// Category is an operator-defined article category: the sources whose
+// articles belong to it and the keyword weights used to rank them.
+type Category struct {
+	Name     string         `json:"name"`
+	Sources  []string       `json:"sources"`
+	Keywords map[string]int `json:"keywords"`
+}
+
+// ThreatOverride pins the threat level of an organization until it expires.
+type ThreatOverride struct {
+	OrgID     int64     `json:"orgId"`
+	Level     string    `json:"level"`
+	Reason    string    `json:"reason,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ThreatSummary reports how long the threat level spent at each level over a
+// period, from hourly snapshots. Time not covered by a snapshot, e.g. while the
+// service was down, is reported as UncoveredHours.
+type ThreatSummary struct {
+	Start          time.Time          `json:"start"`
+	End            time.Time          `json:"end"`
+	Snapshots      int                `json:"snapshots"`
+	Hours          map[string]float64 `json:"hours"`
+	UncoveredHours float64            `json:"uncoveredHours"`
+}
+
+// ArticleBody is the readable content of an article page archived when the
+// article was ingested, kept in case the publisher later removes or paywalls it.
+type ArticleBody struct {
+	ArticleID int64     `json:"articleId"`
+	URL       string    `json:"url"`
+	HTML      string    `json:"html"`
+	Text      string    `json:"text"`
+	FetchedAt time.Time `json:"fetchedAt"`
+}
+
+// SourceHealth is the ingestion state of a feed. LastSuccessAt is nil if the
+// feed was never fetched successfully; LastError is that of the last failed
+// fetch and is cleared by the next successful one.
+type SourceHealth struct {
+	URL           string     `json:"url"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastErrorAt   *time.Time `json:"lastErrorAt,omitempty"`
+	// NewArticles is the number of articles stored from the last successful fetch.
+	NewArticles int `json:"newArticles"`
+}
+
+// IngestCycle summarizes one run of the caching job.
+type IngestCycle struct {
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Sources       int       `json:"sources"`
+	FailedSources int       `json:"failedSources"`
+	Articles      int       `json:"articles"`
+}
+
+// ScheduledJob is a background job and its last run.
+type ScheduledJob struct {
+	Name string `json:"name"`
+	// Schedule is a cron expression or "@every <duration>".
+	Schedule       string     `json:"schedule"`
+	Paused         bool       `json:"paused"`
+	Running        bool       `json:"running"`
+	NextRunAt      *time.Time `json:"nextRunAt,omitempty"`
+	LastStartedAt  *time.Time `json:"lastStartedAt,omitempty"`
+	LastFinishedAt *time.Time `json:"lastFinishedAt,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+}
+
+// FeedRecording is a request to record the next fetches of a source.
+type FeedRecording struct {
+	SourceURL string `json:"sourceUrl"`
+	// Remaining is the number of fetches left to record.
+	Remaining   int       `json:"remaining"`
+	RequestedAt time.Time `json:"requestedAt"`
+}
+
+// FeedFixture is a recorded feed response that can be replayed through the
+// caching pipeline.
+type FeedFixture struct {
+	Name        string    `json:"name"`
+	SourceURL   string    `json:"sourceUrl"`
+	RecordedAt  time.Time `json:"recordedAt"`
+	StatusCode  int       `json:"statusCode"`
+	Status      string    `json:"status"`
+	ContentType string    `json:"contentType,omitempty"`
+	Size        int       `json:"size"`
+}
+
+// CacheStats describes an in-memory cache for runtime diagnostics.
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes,omitempty"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	// HitRate is Hits / (Hits + Misses), or 0 before the first lookup.
+	HitRate float64 `json:"hitRate"`
+}
+
+// NewCacheStats returns the stats of a cache, computing its hit rate.
+func NewCacheStats(entries int, bytes, hits, misses int64) CacheStats {
+	s := CacheStats{Entries: entries, Bytes: bytes, Hits: hits, Misses: misses}
+	if hits+misses > 0 {
+		s.HitRate = float64(hits) / float64(hits+misses)
+	}
+	return s
+}
+
+// KEVEntry is a vulnerability of CISA's Known Exploited Vulnerabilities
+// catalog, which US federal agencies must remediate by DueDate.
+type KEVEntry struct {
+	CVE       string    `json:"cve"`
+	Vendor    string    `json:"vendor"`
+	Product   string    `json:"product"`
+	Name      string    `json:"name"`
+	DateAdded time.Time `json:"dateAdded"`
+	DueDate   time.Time `json:"dueDate"`
+	// Ransomware is set when the vulnerability is known to be used in
+	// ransomware campaigns.
+	Ransomware bool `json:"ransomware"`
+}
+
+// CVEMention counts the articles mentioning a CVE.
+type CVEMention struct {
+	CVE      string `json:"cve"`
+	Mentions int    `json:"mentions"`
+	// FirstSeen and LastSeen are the publish dates of the first and last
+	// article mentioning the CVE, also outside the counted period.
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// ShortID is the identifier of an article in its short link (/a/{shortid}):
+// the article ID in base 36, so links stay valid as long as the article exists.
+func ShortID(articleID int64) string {
+	return strconv.FormatInt(articleID, 36)
+}
+
+// ParseShortID returns the article ID of a short link identifier.
+func ParseShortID(shortID string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.ToLower(shortID), 36, 64)
+	return id, err == nil && id > 0
 }
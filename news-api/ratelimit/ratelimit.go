@@ -0,0 +1,76 @@
+// Package ratelimit limits the request rate of API clients. Limits are token
+// buckets kept per client key, either in memory, which limits each instance
+// of the service separately, or in Redis, which shares them across replicas.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Store decides whether a client may make a request.
+type Store interface {
+	// Allow reports whether the client identified by key may make a request
+	// now, taking a token from its bucket if so.
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// ClientKey returns the rate limiting key of a client address, an IP with or
+// without a port.
+func ClientKey(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// memoryIdle is how long a client's bucket is kept after its last request.
+const memoryIdle = 10 * time.Minute
+
+// Memory keeps token buckets in memory. It is the default store.
+type Memory struct {
+	limit rate.Limit
+	burst int
+
+	mu        sync.Mutex
+	clients   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewMemory returns a store allowing each client limit requests per second
+// with bursts of up to burst requests.
+func NewMemory(limit rate.Limit, burst int) *Memory {
+	return &Memory{limit: limit, burst: burst, clients: map[string]*memoryBucket{}}
+}
+
+// Allow implements Store. It never fails.
+func (m *Memory) Allow(_ context.Context, key string) (bool, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for client, b := range m.clients {
+			if now.Sub(b.lastSeen) > memoryIdle {
+				delete(m.clients, client)
+			}
+		}
+		m.lastSweep = now
+	}
+	b, ok := m.clients[key]
+	if !ok {
+		b = &memoryBucket{limiter: rate.NewLimiter(m.limit, m.burst)}
+		m.clients[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter.AllowN(now, 1), nil
+}
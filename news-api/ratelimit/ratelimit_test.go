@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientKey(t *testing.T) {
+	assert.Equal(t, "203.0.113.5", ClientKey("203.0.113.5:4321"))
+	assert.Equal(t, "2001:db8::1", ClientKey("[2001:db8::1]:443"))
+	assert.Equal(t, "203.0.113.5", ClientKey("203.0.113.5"))
+}
+
+// testStore checks that store allows a burst of 3 requests per client.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		allowed, err := store.Allow(ctx, "a")
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d should be allowed", i+1)
+	}
+	allowed, err := store.Allow(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, allowed, "burst should be exhausted")
+
+	allowed, err = store.Allow(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, allowed, "clients have separate buckets")
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory(0.001, 3))
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	testStore(t, NewRedis(client, 0.001, 3, "test:"))
+	assert.True(t, server.Exists("test:a"))
+
+	// A second replica shares the buckets.
+	other := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer other.Close()
+	allowed, err := NewRedis(other, 0.001, 3, "test:").Allow(context.Background(), "a")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	server.Close()
+	_, err = NewRedis(client, 0.001, 3, "test:").Allow(context.Background(), "c")
+	assert.Error(t, err)
+}
+
+func TestRedisRefills(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedis(client, 1000, 1, "test:")
+	ctx := context.Background()
+	allowed, err := store.Allow(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Eventually(t, func() bool {
+		allowed, err := store.Allow(ctx, "a")
+		return err == nil && allowed
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// tokenBucket refills and takes from a bucket stored as a hash of its tokens
+// and the time they were counted, atomically. Buckets expire once they would
+// be full again.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return allowed
+`)
+
+// Redis keeps token buckets in Redis, so that every replica of the service
+// draws from the same bucket for a client.
+type Redis struct {
+	client redis.Scripter
+	limit  rate.Limit
+	burst  int
+	prefix string
+}
+
+// NewRedis returns a store allowing each client limit requests per second with
+// bursts of up to burst requests across all instances sharing the Redis server.
+// Bucket keys start with prefix.
+func NewRedis(client redis.Scripter, limit rate.Limit, burst int, prefix string) *Redis {
+	return &Redis{client: client, limit: limit, burst: burst, prefix: prefix}
+}
+
+// Allow implements Store.
+func (r *Redis) Allow(ctx context.Context, key string) (bool, error) {
+	if r.limit == rate.Inf {
+		return true, nil
+	}
+	allowed, err := tokenBucket.Run(ctx, r.client, []string{r.prefix + key},
+		float64(r.limit), r.burst, time.Now().UnixMilli()).Int()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
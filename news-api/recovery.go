@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"news-api/handlers"
+)
+
+// PanicReporter is told about every panic recovered while serving a request,
+// e.g. to forward it to an error tracker. It must not block.
+type PanicReporter func(r *http.Request, recovered interface{}, stack []byte)
+
+// panicReporters are called by recoveryMiddleware, in registration order.
+var panicReporters []PanicReporter
+
+// registerPanicReporter adds a reporter for recovered panics.
+func registerPanicReporter(reporter PanicReporter) {
+	panicReporters = append(panicReporters, reporter)
+}
+
+// recoveryMiddleware turns a panic in a handler into a 500 JSON error, logs it
+// with its stack trace and passes it to the panic reporters. The server would
+// otherwise abort the connection without a response.
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				// Handlers abort responses deliberately with this panic.
+				panic(recovered)
+			}
+			stack := debug.Stack()
+			log.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, stack)
+			for _, report := range panicReporters {
+				report(r, recovered, stack)
+			}
+			handlers.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,406 @@
+// Package replica continuously copies a SQLite database to object storage, so
+// that it can be restored to any point in time after its disk is lost. The
+// replica is made of generations, each starting with a snapshot of the
+// database file followed by segments of its write-ahead log (WAL): a restore
+// downloads the newest snapshot before the requested time and replays the
+// segments written after it.
+//
+// While it runs, the replicator holds a read transaction open so that SQLite
+// never restarts the WAL before its frames are copied, and checkpoints the
+// WAL itself once it has grown. If frames are lost anyway, e.g. because the
+// database was written by a process that checkpointed it while the
+// replicator was stopped, a new generation is started.
+package replica
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Config tunes a Replicator.
+type Config struct {
+	// SyncInterval is how often committed transactions are copied, which
+	// bounds what is lost with the disk. Defaults to 10 seconds.
+	SyncInterval time.Duration
+	// SnapshotInterval is how often the whole database is copied, which
+	// bounds the segments to replay on restore. Defaults to 24 hours.
+	SnapshotInterval time.Duration
+	// Retention is how far back the database can be restored. Older
+	// snapshots and segments are deleted after each snapshot. Zero keeps
+	// everything.
+	Retention time.Duration
+	// CheckpointSize is the WAL size past which it is checkpointed into the
+	// database file and restarted. Defaults to 4 MiB.
+	CheckpointSize int64
+}
+
+// errWALLost reports frames that were checkpointed and overwritten before they
+// were copied.
+var errWALLost = errors.New("WAL was restarted before its frames were copied")
+
+// Replicator copies a database to a Store.
+type Replicator struct {
+	path  string
+	store Store
+	cfg   Config
+
+	mu sync.Mutex
+	db *sql.DB
+	// pin holds a read transaction, which keeps other connections from
+	// restarting the WAL, and lock takes the write lock for checkpoints.
+	pin  *sql.Conn
+	lock *sql.Conn
+
+	generation string
+	// index numbers the next segment of the generation.
+	index int
+	// header is the header of the WAL being copied, offset the end of the
+	// frames copied from it and checksum the checksum there.
+	header   *walHeader
+	offset   int
+	checksum [2]uint32
+	walSize  int64
+	// restarting is set after a checkpoint, when the next transaction may
+	// restart the WAL with new salts.
+	restarting bool
+	// pending are the segments and snapshot read but not uploaded yet.
+	pending      []object
+	lastSnapshot time.Time
+}
+
+// object is a gzip-compressed object waiting to be uploaded.
+type object struct {
+	key  string
+	body []byte
+}
+
+// New returns a replicator copying the database file at path to store.
+func New(path string, store Store, cfg Config) *Replicator {
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = 10 * time.Second
+	}
+	if cfg.SnapshotInterval <= 0 {
+		cfg.SnapshotInterval = 24 * time.Hour
+	}
+	if cfg.CheckpointSize <= 0 {
+		cfg.CheckpointSize = 4 << 20
+	}
+	return &Replicator{path: path, store: store, cfg: cfg}
+}
+
+// Start switches the database to WAL mode, starts a new generation with a
+// snapshot and then copies new transactions every SyncInterval until ctx is
+// done. The returned channel is closed after a last copy once ctx is done.
+func (r *Replicator) Start(ctx context.Context) (<-chan struct{}, error) {
+	if err := r.open(ctx); err != nil {
+		return nil, err
+	}
+	r.newGeneration()
+	if err := r.Sync(ctx); err != nil {
+		r.close()
+		return nil, err
+	}
+	log.Printf("Replicating the database to generation %s.", r.generation)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(r.cfg.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				finalCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				if err := r.Sync(finalCtx); err != nil {
+					log.Printf("Error replicating the database on shutdown: %v", err)
+				}
+				cancel()
+				r.close()
+				return
+			case <-ticker.C:
+				if err := r.Sync(ctx); err != nil {
+					log.Printf("Error replicating the database: %v", err)
+				}
+			}
+		}
+	}()
+	return done, nil
+}
+
+func (r *Replicator) open(ctx context.Context) error {
+	var err error
+	if r.db, err = sql.Open("sqlite3", "file:"+r.path+"?_busy_timeout=5000"); err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	var mode string
+	if err := r.db.QueryRowContext(ctx, "PRAGMA journal_mode=WAL").Scan(&mode); err != nil || mode != "wal" {
+		r.db.Close()
+		return fmt.Errorf("failed to switch the database to WAL mode: %v", err)
+	}
+	if r.pin, err = r.db.Conn(ctx); err == nil {
+		r.lock, err = r.db.Conn(ctx)
+	}
+	if err == nil {
+		err = r.acquirePin(ctx)
+	}
+	if err != nil {
+		r.close()
+		return err
+	}
+	return nil
+}
+
+func (r *Replicator) close() {
+	if r.pin != nil {
+		r.pin.ExecContext(context.Background(), "ROLLBACK")
+		r.pin.Close()
+	}
+	if r.lock != nil {
+		r.lock.Close()
+	}
+	r.db.Close()
+}
+
+func (r *Replicator) acquirePin(ctx context.Context) error {
+	if _, err := r.pin.ExecContext(ctx, "BEGIN"); err != nil {
+		return err
+	}
+	var n int
+	if err := r.pin.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&n); err != nil {
+		r.pin.ExecContext(ctx, "ROLLBACK")
+		return err
+	}
+	return nil
+}
+
+func (r *Replicator) releasePin(ctx context.Context) error {
+	_, err := r.pin.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+// newGeneration starts a generation, which begins with the next snapshot.
+func (r *Replicator) newGeneration() {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	r.generation = time.Now().UTC().Format(generationTime) + "-" + hex.EncodeToString(suffix)
+	r.index = 0
+	r.lastSnapshot = time.Time{}
+}
+
+// Sync copies the transactions committed since the last call, takes a
+// snapshot or checkpoints the WAL if due, and uploads what was copied.
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.readWAL()
+	if errors.Is(err, errWALLost) {
+		log.Printf("Warning: %v, starting a new replica generation.", err)
+		r.header, err = nil, nil
+		r.newGeneration()
+	} else if err != nil {
+		return err
+	}
+	switch {
+	case time.Since(r.lastSnapshot) >= r.cfg.SnapshotInterval:
+		if err = r.snapshot(ctx); err != nil {
+			err = fmt.Errorf("failed to snapshot the database: %v", err)
+		}
+	case r.walSize >= r.cfg.CheckpointSize:
+		if err = r.checkpoint(ctx, nil); err != nil {
+			err = fmt.Errorf("failed to checkpoint the database: %v", err)
+		}
+	}
+	// The segments copied are uploaded even if the checkpoint failed.
+	if uploadErr := r.upload(ctx); uploadErr != nil {
+		return uploadErr
+	}
+	return err
+}
+
+// readWAL queues the frames committed to the WAL since the last call as a
+// segment.
+func (r *Replicator) readWAL() error {
+	wal, err := os.ReadFile(r.path + "-wal")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r.walSize = int64(len(wal))
+	if len(wal) < walHeaderSize {
+		// The WAL is empty until the first transaction after a checkpoint.
+		if r.header != nil && !r.restarting {
+			return errWALLost
+		}
+		return nil
+	}
+	h, err := parseWALHeader(wal)
+	if err != nil {
+		return err
+	}
+	if r.header == nil || h.salt1 != r.header.salt1 || h.salt2 != r.header.salt2 {
+		if r.header != nil && !r.restarting {
+			return errWALLost
+		}
+		r.header, r.offset, r.checksum, r.restarting = &h, walHeaderSize, h.checksum, false
+	}
+	end, sum := committedFrames(h, wal, r.offset, r.checksum)
+	if end == r.offset {
+		return nil
+	}
+	segment := append(append([]byte{}, wal[:walHeaderSize]...), wal[r.offset:end]...)
+	body, err := compress(bytes.NewReader(segment))
+	if err != nil {
+		return err
+	}
+	r.pending = append(r.pending, object{key: objectKey(r.generation, segmentsDir, r.index, time.Now()), body: body})
+	r.index++
+	r.offset, r.checksum, r.restarting = end, sum, false
+	return nil
+}
+
+// checkpoint copies the database into its file and lets the next transaction
+// restart the WAL. It holds the write lock, so that no transaction is
+// committed between the last copy of the WAL and the checkpoint. The
+// database file is then copied to copyTo, if set.
+func (r *Replicator) checkpoint(ctx context.Context, copyTo io.Writer) error {
+	if _, err := r.lock.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	defer r.lock.ExecContext(context.Background(), "ROLLBACK")
+	if err := r.readWAL(); err != nil {
+		return err
+	}
+
+	// The pin keeps the checkpoint from completing, so it is released
+	// meanwhile. Nothing can be committed while the write lock is held.
+	if err := r.releasePin(ctx); err != nil {
+		return err
+	}
+	var busy, frames, checkpointed int
+	err := r.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &frames, &checkpointed)
+	if pinErr := r.acquirePin(ctx); pinErr != nil && err == nil {
+		err = pinErr
+	}
+	if err != nil {
+		return err
+	}
+	if frames != checkpointed {
+		// Readers of older transactions hold frames back; the next
+		// checkpoint will try again.
+		return fmt.Errorf("checkpointed %d of %d WAL frames", checkpointed, frames)
+	}
+	r.restarting = true
+	if copyTo == nil {
+		return nil
+	}
+	f, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(copyTo, f)
+	return err
+}
+
+// snapshot checkpoints the database and queues a copy of its file as a
+// snapshot. The file is copied to a temporary file first, which is quicker
+// than compressing it while holding the write lock.
+func (r *Replicator) snapshot(ctx context.Context) error {
+	tmp, err := os.CreateTemp("", "threatfeed-snapshot-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := r.checkpoint(ctx, tmp); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	body, err := compress(tmp)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	r.pending = append(r.pending, object{key: objectKey(r.generation, snapshotsDir, r.index, now), body: body})
+	r.lastSnapshot = now
+	return nil
+}
+
+// upload stores the pending objects in order, stopping at the first failure
+// so that segments are never missing in between. After a snapshot, the
+// objects older than the retention are deleted.
+func (r *Replicator) upload(ctx context.Context) error {
+	snapshotted := false
+	for len(r.pending) > 0 {
+		o := r.pending[0]
+		if err := r.store.Put(ctx, o.key, o.body); err != nil {
+			return fmt.Errorf("failed to upload %s: %v", o.key, err)
+		}
+		r.pending = r.pending[1:]
+		if k, ok := parseObjectKey(o.key); ok && k.kind == snapshotsDir {
+			snapshotted = true
+		}
+	}
+	if snapshotted && r.cfg.Retention > 0 {
+		if err := prune(ctx, r.store, time.Now().Add(-r.cfg.Retention)); err != nil {
+			return fmt.Errorf("failed to delete old replica objects: %v", err)
+		}
+	}
+	return nil
+}
+
+// prune deletes the objects that are not needed to restore the database to
+// any time after cutoff: everything before the newest snapshot taken by then.
+func prune(ctx context.Context, store Store, cutoff time.Time) error {
+	keys, err := store.List(ctx, generationsDir+"/")
+	if err != nil {
+		return err
+	}
+	var oldest *objectKeyInfo
+	for _, key := range keys {
+		k, ok := parseObjectKey(key)
+		if ok && k.kind == snapshotsDir && !k.time.After(cutoff) && (oldest == nil || k.after(*oldest)) {
+			oldest = &k
+		}
+	}
+	if oldest == nil {
+		return nil
+	}
+	for _, key := range keys {
+		k, ok := parseObjectKey(key)
+		if !ok || k.generation > oldest.generation || (k.generation == oldest.generation && k.index >= oldest.index) {
+			continue
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func compress(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gz, r); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,115 @@
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertRows(t *testing.T, db *sql.DB, from, to int) {
+	for i := from; i < to; i++ {
+		_, err := db.Exec("INSERT INTO items(id, body) VALUES(?, randomblob(3000))", i)
+		require.NoError(t, err)
+	}
+}
+
+func countRows(t *testing.T, path string) int {
+	db, err := sql.Open("sqlite3", "file:"+path)
+	require.NoError(t, err)
+	defer db.Close()
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n))
+	return n
+}
+
+func TestReplicateAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "news.db")
+	app, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer app.Close()
+	_, err = app.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, body BLOB)")
+	require.NoError(t, err)
+	insertRows(t, app, 0, 10)
+
+	store := DirStore(filepath.Join(dir, "replica"))
+	ctx, cancel := context.WithCancel(context.Background())
+	// Every sync checkpoints, so the WAL restarts between them.
+	r := New(path, store, Config{SyncInterval: time.Hour, CheckpointSize: 1})
+	done, err := r.Start(ctx)
+	require.NoError(t, err)
+	generation := r.generation
+
+	insertRows(t, app, 10, 20)
+	require.NoError(t, r.Sync(ctx))
+	time.Sleep(5 * time.Millisecond)
+	first := time.Now()
+	time.Sleep(5 * time.Millisecond)
+	insertRows(t, app, 20, 25)
+	require.NoError(t, r.Sync(ctx))
+	insertRows(t, app, 25, 30)
+	cancel()
+	<-done
+	assert.Equal(t, generation, r.generation, "checkpoints keep the generation")
+
+	latest := filepath.Join(dir, "latest.db")
+	_, err = Restore(context.Background(), store, latest, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 30, countRows(t, latest), "the last transactions are copied on shutdown")
+
+	earlier := filepath.Join(dir, "earlier.db")
+	restored, err := Restore(context.Background(), store, earlier, first)
+	require.NoError(t, err)
+	assert.False(t, restored.After(first))
+	assert.Equal(t, 20, countRows(t, earlier))
+
+	_, err = Restore(context.Background(), store, latest, time.Time{})
+	assert.Error(t, err, "restores never overwrite a database")
+	_, err = Restore(context.Background(), store, filepath.Join(dir, "none.db"), first.Add(-time.Hour))
+	assert.ErrorIs(t, err, ErrNoReplica)
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "news.db")
+	app, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer app.Close()
+	_, err = app.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, body BLOB)")
+	require.NoError(t, err)
+
+	store := DirStore(filepath.Join(dir, "replica"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := New(path, store, Config{SyncInterval: time.Hour})
+	_, err = r.Start(ctx)
+	require.NoError(t, err)
+	insertRows(t, app, 0, 5)
+	require.NoError(t, r.Sync(ctx))
+
+	// A second snapshot makes the first one and its segments unnecessary.
+	r.lastSnapshot = time.Time{}
+	insertRows(t, app, 5, 10)
+	require.NoError(t, r.Sync(ctx))
+	keys, err := store.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, keys, 4)
+
+	require.NoError(t, prune(ctx, store, time.Now()))
+	keys, err = store.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	k, ok := parseObjectKey(keys[0])
+	require.True(t, ok)
+	assert.Equal(t, snapshotsDir, k.kind)
+
+	restored := filepath.Join(dir, "restored.db")
+	_, err = Restore(ctx, store, restored, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, 10, countRows(t, restored))
+}
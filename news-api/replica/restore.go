@@ -0,0 +1,184 @@
+package replica
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The objects of a replica are stored under
+// generations/<generation>/snapshots/<index>-<time>.db.gz and
+// generations/<generation>/wal/<index>-<time>.wal.gz. A snapshot with index i
+// contains the segments before i.
+const (
+	generationsDir = "generations"
+	snapshotsDir   = "snapshots"
+	segmentsDir    = "wal"
+	generationTime = "20060102T150405Z"
+	objectTime     = "20060102T150405.000Z"
+)
+
+// ErrNoReplica is returned by Restore when the store holds no snapshot taken
+// by the requested time.
+var ErrNoReplica = errors.New("no replica snapshot found")
+
+// objectKeyInfo describes a snapshot or segment from its key.
+type objectKeyInfo struct {
+	key        string
+	generation string
+	kind       string
+	index      int
+	time       time.Time
+}
+
+// after reports whether k was stored after o.
+func (k objectKeyInfo) after(o objectKeyInfo) bool {
+	if k.generation != o.generation {
+		return k.generation > o.generation
+	}
+	return k.index > o.index
+}
+
+func objectKey(generation, kind string, index int, at time.Time) string {
+	ext := ".wal.gz"
+	if kind == snapshotsDir {
+		ext = ".db.gz"
+	}
+	return fmt.Sprintf("%s/%s/%s/%016x-%s%s", generationsDir, generation, kind, index, at.UTC().Format(objectTime), ext)
+}
+
+func parseObjectKey(key string) (objectKeyInfo, bool) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != generationsDir || (parts[2] != snapshotsDir && parts[2] != segmentsDir) {
+		return objectKeyInfo{}, false
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(parts[3], ".db.gz"), ".wal.gz")
+	index, at, ok := strings.Cut(name, "-")
+	if !ok {
+		return objectKeyInfo{}, false
+	}
+	i, err := strconv.ParseInt(index, 16, 64)
+	if err != nil {
+		return objectKeyInfo{}, false
+	}
+	t, err := time.Parse(objectTime, at)
+	if err != nil {
+		return objectKeyInfo{}, false
+	}
+	return objectKeyInfo{key: key, generation: parts[1], kind: parts[2], index: int(i), time: t}, true
+}
+
+// Restore writes the database as it was at the given time, or as last
+// replicated if at is zero, to path, which must not exist. It returns the
+// time of the last snapshot or segment applied, and ErrNoReplica if no
+// snapshot was taken by then.
+func Restore(ctx context.Context, store Store, path string, at time.Time) (time.Time, error) {
+	if _, err := os.Stat(path); err == nil {
+		return time.Time{}, fmt.Errorf("%s already exists", path)
+	}
+	keys, err := store.List(ctx, generationsDir+"/")
+	if err != nil {
+		return time.Time{}, err
+	}
+	var snapshot *objectKeyInfo
+	segments := map[string][]objectKeyInfo{}
+	for _, key := range keys {
+		k, ok := parseObjectKey(key)
+		if !ok || (!at.IsZero() && k.time.After(at)) {
+			continue
+		}
+		if k.kind == segmentsDir {
+			segments[k.generation] = append(segments[k.generation], k)
+		} else if snapshot == nil || k.after(*snapshot) {
+			snapshot = &k
+		}
+	}
+	if snapshot == nil {
+		return time.Time{}, ErrNoReplica
+	}
+
+	tmp := path + ".restoring"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+	if err := download(ctx, store, snapshot.key, f); err != nil {
+		return time.Time{}, fmt.Errorf("failed to restore snapshot %s: %v", snapshot.key, err)
+	}
+	restored := snapshot.time
+
+	following := segments[snapshot.generation]
+	sort.Slice(following, func(i, j int) bool { return following[i].index < following[j].index })
+	next := snapshot.index
+	for _, segment := range following {
+		if segment.index < next {
+			continue
+		}
+		if segment.index > next {
+			// A segment that failed to upload; the later ones cannot be
+			// applied without it.
+			break
+		}
+		var wal bytes.Buffer
+		if err := download(ctx, store, segment.key, &wal); err != nil {
+			return time.Time{}, fmt.Errorf("failed to restore segment %s: %v", segment.key, err)
+		}
+		if err := applySegment(f, wal.Bytes()); err != nil {
+			return time.Time{}, fmt.Errorf("failed to apply segment %s: %v", segment.key, err)
+		}
+		restored = segment.time
+		next++
+	}
+	if err := f.Sync(); err != nil {
+		return time.Time{}, err
+	}
+	if err := f.Close(); err != nil {
+		return time.Time{}, err
+	}
+	if err := checkIntegrity(tmp); err != nil {
+		return time.Time{}, err
+	}
+	return restored, os.Rename(tmp, path)
+}
+
+// download writes an object, decompressed, to w.
+func download(ctx context.Context, store Store, key string, w io.Writer) error {
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, gz)
+	return err
+}
+
+// checkIntegrity runs SQLite's quick check on a restored database.
+func checkIntegrity(path string) error {
+	db, err := sql.Open("sqlite3", "file:"+path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&result); err != nil {
+		return fmt.Errorf("restored database is unreadable: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("restored database is corrupt: %s", result)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package replica
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// The layout of SQLite's write-ahead log, see https://sqlite.org/fileformat.html#the_write_ahead_log.
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagic           = 0x377f0682
+)
+
+// walHeader is the header of a write-ahead log. The log restarts with new
+// salts after a checkpoint, and only the frames carrying the header's salts
+// belong to it.
+type walHeader struct {
+	// bigEndian is the byte order of the words checksums are computed over.
+	bigEndian    bool
+	pageSize     int
+	salt1, salt2 uint32
+	checksum     [2]uint32
+}
+
+func parseWALHeader(b []byte) (walHeader, error) {
+	var h walHeader
+	if len(b) < walHeaderSize {
+		return h, errors.New("WAL header is truncated")
+	}
+	magic := binary.BigEndian.Uint32(b)
+	if magic&^1 != walMagic {
+		return h, fmt.Errorf("invalid WAL magic number %#x", magic)
+	}
+	h.bigEndian = magic&1 == 1
+	h.pageSize = int(binary.BigEndian.Uint32(b[8:]))
+	if h.pageSize == 1 {
+		h.pageSize = 65536
+	}
+	if h.pageSize < 512 || h.pageSize&(h.pageSize-1) != 0 {
+		return h, fmt.Errorf("invalid WAL page size %d", h.pageSize)
+	}
+	h.salt1 = binary.BigEndian.Uint32(b[16:])
+	h.salt2 = binary.BigEndian.Uint32(b[20:])
+	h.checksum = walChecksum(h.bigEndian, [2]uint32{}, b[:24])
+	if h.checksum != [2]uint32{binary.BigEndian.Uint32(b[24:]), binary.BigEndian.Uint32(b[28:])} {
+		return h, errors.New("WAL header checksum mismatch")
+	}
+	return h, nil
+}
+
+// walChecksum continues the checksum s over b, whose length is a multiple of
+// 8 bytes.
+func walChecksum(bigEndian bool, s [2]uint32, b []byte) [2]uint32 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// committedFrames scans the frames of wal from offset, whose previous frame
+// left the checksum sum, and returns the end of the last frame committing a
+// transaction and the checksum there. The scan stops at the first frame of
+// another log, or one torn by a crash.
+func committedFrames(h walHeader, wal []byte, offset int, sum [2]uint32) (int, [2]uint32) {
+	frameSize := walFrameHeaderSize + h.pageSize
+	end, endSum := offset, sum
+	for pos := offset; pos+frameSize <= len(wal); pos += frameSize {
+		frame := wal[pos : pos+frameSize]
+		if binary.BigEndian.Uint32(frame[8:]) != h.salt1 || binary.BigEndian.Uint32(frame[12:]) != h.salt2 {
+			break
+		}
+		sum = walChecksum(h.bigEndian, sum, frame[:8])
+		sum = walChecksum(h.bigEndian, sum, frame[walFrameHeaderSize:])
+		if sum != [2]uint32{binary.BigEndian.Uint32(frame[16:]), binary.BigEndian.Uint32(frame[20:])} {
+			break
+		}
+		// Commit frames record the size of the database after the transaction.
+		if binary.BigEndian.Uint32(frame[4:]) != 0 {
+			end, endSum = pos+frameSize, sum
+		}
+	}
+	return end, endSum
+}
+
+// applySegment writes the pages of a segment, a WAL header followed by
+// committed frames, to the database file, as a checkpoint would.
+func applySegment(f *os.File, segment []byte) error {
+	h, err := parseWALHeader(segment)
+	if err != nil {
+		return err
+	}
+	frameSize := walFrameHeaderSize + h.pageSize
+	if (len(segment)-walHeaderSize)%frameSize != 0 {
+		return errors.New("segment holds a partial frame")
+	}
+	for pos := walHeaderSize; pos < len(segment); pos += frameSize {
+		frame := segment[pos : pos+frameSize]
+		page := int64(binary.BigEndian.Uint32(frame))
+		if page == 0 {
+			return errors.New("segment holds a frame for page 0")
+		}
+		if _, err := f.WriteAt(frame[walFrameHeaderSize:], (page-1)*int64(h.pageSize)); err != nil {
+			return err
+		}
+		if pages := int64(binary.BigEndian.Uint32(frame[4:])); pages != 0 {
+			if err := f.Truncate(pages * int64(h.pageSize)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
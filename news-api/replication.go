@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"news-api/integrations"
+	"news-api/replica"
+)
+
+// replicaStore returns the store of REPLICA_URL, nil if it is unset. An
+// s3://bucket/prefix URL uses the credentials of S3 report delivery, a
+// file:///path URL a local directory, e.g. on a network volume.
+func replicaStore() (replica.Store, error) {
+	raw := os.Getenv("REPLICA_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REPLICA_URL: %v", err)
+	}
+	switch u.Scheme {
+	case "s3":
+		uploader := newS3Uploader()
+		if uploader == nil {
+			return nil, fmt.Errorf("REPLICA_URL %s requires AWS_ACCESS_KEY_ID", raw)
+		}
+		return integrations.NewS3Store(uploader, raw)
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("REPLICA_URL %s has no path", raw)
+		}
+		return replica.DirStore(u.Path), nil
+	default:
+		return nil, fmt.Errorf("REPLICA_URL must be an s3:// or file:// URL")
+	}
+}
+
+// restoreMissingDB restores the database from the replica when its file is
+// missing, e.g. after a disk wipe, unless REPLICA_AUTO_RESTORE is false.
+func restoreMissingDB(path string) {
+	if _, err := os.Stat(path); !os.IsNotExist(err) || envDefault("REPLICA_AUTO_RESTORE", "true") != "true" {
+		return
+	}
+	store, err := replicaStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if store == nil {
+		return
+	}
+	log.Printf("Database %s is missing, restoring it from the replica...", path)
+	restored, err := replica.Restore(context.Background(), store, path, time.Time{})
+	if errors.Is(err, replica.ErrNoReplica) {
+		log.Println("No replica found, starting with an empty database.")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to restore database from replica: %v", err)
+	}
+	log.Printf("Restored the database as of %s.", restored.Format(time.RFC3339))
+}
+
+// startReplication copies the database to REPLICA_URL until ctx is done,
+// every REPLICA_SYNC_INTERVAL, with a snapshot every REPLICA_SNAPSHOT_INTERVAL,
+// keeping REPLICA_RETENTION of history. The returned channel is closed after
+// the last copy.
+func startReplication(ctx context.Context, path string) <-chan struct{} {
+	store, err := replicaStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if store == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	replicator := replica.New(path, store, replica.Config{
+		SyncInterval:     envDuration("REPLICA_SYNC_INTERVAL", 10*time.Second),
+		SnapshotInterval: envDuration("REPLICA_SNAPSHOT_INTERVAL", 24*time.Hour),
+		Retention:        envDuration("REPLICA_RETENTION", 7*24*time.Hour),
+	})
+	done, err := replicator.Start(ctx)
+	if err != nil {
+		log.Printf("Warning: database replication disabled: %v", err)
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return done
+}
+
+// runRestore implements the restore command: `news-api restore --to
+// 2024-05-02T09:30:00Z` writes the database as it was at that time, or as
+// last replicated without --to, from REPLICA_URL to DB_PATH or --output. It
+// returns the exit status: 2 for usage errors, 1 if the restore failed.
+func runRestore(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	to := flags.String("to", "", "time to restore the database to, as an RFC3339 timestamp")
+	output := flags.String("output", envDefault("DB_PATH", "./news.db"), "path of the restored database, which must not exist")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	var at time.Time
+	if *to != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, *to); err != nil {
+			fmt.Fprintln(stderr, "--to must be an RFC3339 timestamp, e.g. 2024-05-02T09:30:00Z")
+			return 2
+		}
+	}
+	store, err := replicaStore()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	if store == nil {
+		fmt.Fprintln(stderr, "restore requires REPLICA_URL")
+		return 2
+	}
+
+	restored, err := replica.Restore(context.Background(), store, *output, at)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to restore the database: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Restored the database as of %s to %s.\n", restored.Format(time.RFC3339), *output)
+	return 0
+}
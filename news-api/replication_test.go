@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRestoreUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	t.Setenv("REPLICA_URL", "")
+	assert.Equal(t, 2, runRestore(nil, &stdout, &stderr), "REPLICA_URL is required")
+	t.Setenv("REPLICA_URL", "https://example.com/replica")
+	assert.Equal(t, 2, runRestore(nil, &stdout, &stderr))
+	t.Setenv("REPLICA_URL", "file://"+t.TempDir())
+	assert.Equal(t, 2, runRestore([]string{"--to", "yesterday"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "RFC3339")
+
+	stderr.Reset()
+	output := filepath.Join(t.TempDir(), "news.db")
+	assert.Equal(t, 1, runRestore([]string{"--output", output}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "no replica snapshot found")
+}
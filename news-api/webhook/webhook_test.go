@@ -0,0 +1,127 @@
+package webhook
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedHeader(secret, body string, now time.Time) http.Header {
+	header := http.Header{}
+	for k, v := range Sign([]byte(secret), []byte(body), now) {
+		header.Set(k, v)
+	}
+	return header
+}
+
+func TestSignature(t *testing.T) {
+	// Receivers in other languages compute the same HMAC over "<timestamp>.<nonce>.<body>".
+	assert.Equal(t, "c298f98d541d2a5fa6efc81e6cfe35504abeb3847802a5791eeac7a19a12361b", Signature([]byte("secret"), "1700000000", "abc", []byte(`{}`)))
+	assert.NotEqual(t, Signature([]byte("secret"), "1700000000", "abc", []byte(`{}`)), Signature([]byte("secret"), "1700000001", "abc", []byte(`{}`)))
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	body := `{"text": "Code Red"}`
+	v := NewVerifier("old-secret", "new-secret")
+
+	header := signedHeader("new-secret", body, now)
+	assert.NoError(t, v.Verify(header, []byte(body), now.Add(time.Minute)))
+	assert.ErrorIs(t, v.Verify(header, []byte(body), now.Add(time.Minute)), ErrReplayed)
+
+	assert.NoError(t, v.Verify(signedHeader("old-secret", body, now), []byte(body), now), "rotated secrets stay valid")
+	assert.ErrorIs(t, v.Verify(signedHeader("other", body, now), []byte(body), now), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify(signedHeader("new-secret", body, now), []byte(`{"text": "All clear"}`), now), ErrInvalidSignature)
+	assert.ErrorIs(t, v.Verify(signedHeader("new-secret", body, now.Add(-10*time.Minute)), []byte(body), now), ErrStale)
+	assert.ErrorIs(t, v.Verify(signedHeader("new-secret", body, now.Add(10*time.Minute)), []byte(body), now), ErrStale)
+	assert.ErrorIs(t, v.Verify(http.Header{}, []byte(body), now), ErrMissingHeaders)
+
+	tampered := signedHeader("new-secret", body, now)
+	tampered.Set(NonceHeader, "another-nonce")
+	assert.ErrorIs(t, v.Verify(tampered, []byte(body), now), ErrInvalidSignature, "the nonce is signed")
+
+	// Several signatures may be listed; one valid one is enough.
+	multi := signedHeader("new-secret", body, now)
+	multi.Set(SignatureHeader, "v1=deadbeef, "+multi.Get(SignatureHeader))
+	assert.NoError(t, v.Verify(multi, []byte(body), now))
+
+	// Remembered nonces are forgotten once their deliveries would be stale anyway.
+	assert.Len(t, v.seen, 3)
+	v.Verify(signedHeader("new-secret", body, now.Add(time.Hour)), []byte(body), now.Add(time.Hour))
+	assert.Len(t, v.seen, 1)
+}
+
+func TestVerifyRequest(t *testing.T) {
+	body := `{"article": {}}`
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	for k, v := range Sign([]byte("secret"), []byte(body), time.Now()) {
+		req.Header.Set(k, v)
+	}
+	got, err := NewVerifier("secret").VerifyRequest(req)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(got))
+	again, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(again), "the body can be read again")
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"100.63.255.255", true},
+		{"100.128.0.1", true},
+		{"198.20.0.1", true},
+		{"2606:4700::1111", true},
+		{"2a00:1450:4001::200e", true},
+		{"::ffff:93.184.216.34", true},
+
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"10.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.100.100.200", false},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"172.16.5.4", false},
+		{"192.0.0.8", false},
+		{"192.0.2.1", false},
+		{"192.168.0.10", false},
+		{"198.18.0.1", false},
+		{"198.19.255.254", false},
+		{"198.51.100.7", false},
+		{"203.0.113.9", false},
+		{"224.0.0.1", false},
+		{"240.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b:1::1", false},
+		{"100::1", false},
+		{"2001::1", false},
+		{"2001:db8::1", false},
+		{"2002:a9fe:a9fe::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.public, IsPublicIP(net.ParseIP(test.ip)), test.ip)
+	}
+	assert.False(t, IsPublicIP(nil))
+	assert.ErrorContains(t, RefuseNonPublic("tcp", "127.0.0.1:80", nil), "non-public address")
+	assert.NoError(t, RefuseNonPublic("tcp", "93.184.216.34:443", nil))
+}